@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultAdvisoriesPath est le fichier écrit par "rules update" et lu par défaut par les
+// commandes d'analyse.
+const defaultAdvisoriesPath = ".phpanalyzer-advisories.yaml"
+
+// Adresses par défaut des flux d'avis de sécurité.
+const (
+	defaultOSVURL = "https://api.osv.dev/v1/vulns/"
+	defaultNVDURL = "https://services.nvd.nist.gov/rest/json/cves/2.0"
+)
+
+// Advisory regroupe les métadonnées d'une CVE issues d'un flux d'avis de sécurité :
+// description, versions de PHP affectées et sévérité.
+type Advisory struct {
+	ID          string `yaml:"id"`
+	Description string `yaml:"description,omitempty"`
+	PHP         string `yaml:"php,omitempty"`
+	Severity    string `yaml:"severity,omitempty"`
+	Modified    string `yaml:"modified,omitempty"`
+}
+
+// AdvisoryFeed est un flux d'avis de sécurité interrogé par identifiant de CVE.
+type AdvisoryFeed interface {
+	Fetch(id string) (Advisory, error)
+}
+
+// advisoryClient est le client HTTP partagé par les flux.
+var advisoryClient = &http.Client{Timeout: 30 * time.Second}
+
+// getJSON télécharge et décode un document JSON.
+func getJSON(rawURL string, header http.Header, v any) error {
+	req, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := advisoryClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s : statut HTTP %d", rawURL, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// osvFeed interroge l'API OSV (https://osv.dev).
+type osvFeed struct {
+	baseURL string
+}
+
+// NewOSVFeed crée un flux OSV ; baseURL vide désigne l'API publique.
+func NewOSVFeed(baseURL string) AdvisoryFeed {
+	if baseURL == "" {
+		baseURL = defaultOSVURL
+	}
+	return osvFeed{baseURL: strings.TrimSuffix(baseURL, "/") + "/"}
+}
+
+type osvVulnerability struct {
+	ID       string `json:"id"`
+	Summary  string `json:"summary"`
+	Details  string `json:"details"`
+	Modified string `json:"modified"`
+	Affected []struct {
+		Package struct {
+			Name string `json:"name"`
+		} `json:"package"`
+		Ranges []struct {
+			Type   string              `json:"type"`
+			Events []map[string]string `json:"events"`
+		} `json:"ranges"`
+	} `json:"affected"`
+	DatabaseSpecific struct {
+		Severity string `json:"severity"`
+	} `json:"database_specific"`
+}
+
+func (f osvFeed) Fetch(id string) (Advisory, error) {
+	var vuln osvVulnerability
+	if err := getJSON(f.baseURL+url.PathEscape(id), nil, &vuln); err != nil {
+		return Advisory{}, err
+	}
+	advisory := Advisory{ID: id, Description: vuln.Summary, Modified: vuln.Modified}
+	if advisory.Description == "" {
+		advisory.Description = vuln.Details
+	}
+	advisory.Severity = advisorySeverity(vuln.DatabaseSpecific.Severity)
+
+	// Les plages ECOSYSTEM et SEMVER sont des suites d'événements "introduced" / "fixed" ;
+	// les plages GIT (commits) ne donnent pas de version.
+	var ranges []string
+	for _, affected := range vuln.Affected {
+		if affected.Package.Name != "" && !strings.Contains(strings.ToLower(affected.Package.Name), "php") {
+			continue
+		}
+		for _, r := range affected.Ranges {
+			if r.Type != "ECOSYSTEM" && r.Type != "SEMVER" {
+				continue
+			}
+			var introduced string
+			for _, event := range r.Events {
+				if v, ok := event["introduced"]; ok {
+					introduced = v
+				}
+				if v, ok := event["fixed"]; ok {
+					ranges = append(ranges, versionRange(introduced, ">=", v, "<"))
+					introduced = ""
+				}
+			}
+			if introduced != "" {
+				ranges = append(ranges, versionRange(introduced, ">=", "", ""))
+			}
+		}
+	}
+	advisory.PHP = strings.Join(ranges, " || ")
+	return advisory, nil
+}
+
+// nvdFeed interroge l'API 2.0 de la National Vulnerability Database.
+type nvdFeed struct {
+	baseURL string
+	apiKey  string
+}
+
+// NewNVDFeed crée un flux NVD ; baseURL vide désigne l'API publique. Sans clé d'API,
+// la NVD limite le nombre de requêtes.
+func NewNVDFeed(baseURL, apiKey string) AdvisoryFeed {
+	if baseURL == "" {
+		baseURL = defaultNVDURL
+	}
+	return nvdFeed{baseURL: baseURL, apiKey: apiKey}
+}
+
+type nvdCVSSMetric struct {
+	BaseSeverity string `json:"baseSeverity"`
+	CVSSData     struct {
+		BaseSeverity string `json:"baseSeverity"`
+	} `json:"cvssData"`
+}
+
+type nvdResponse struct {
+	Vulnerabilities []struct {
+		CVE struct {
+			ID           string `json:"id"`
+			LastModified string `json:"lastModified"`
+			Descriptions []struct {
+				Lang  string `json:"lang"`
+				Value string `json:"value"`
+			} `json:"descriptions"`
+			Metrics struct {
+				V31 []nvdCVSSMetric `json:"cvssMetricV31"`
+				V30 []nvdCVSSMetric `json:"cvssMetricV30"`
+				V2  []nvdCVSSMetric `json:"cvssMetricV2"`
+			} `json:"metrics"`
+			Configurations []struct {
+				Nodes []struct {
+					CPEMatch []struct {
+						Vulnerable            bool   `json:"vulnerable"`
+						Criteria              string `json:"criteria"`
+						VersionStartIncluding string `json:"versionStartIncluding"`
+						VersionStartExcluding string `json:"versionStartExcluding"`
+						VersionEndIncluding   string `json:"versionEndIncluding"`
+						VersionEndExcluding   string `json:"versionEndExcluding"`
+					} `json:"cpeMatch"`
+				} `json:"nodes"`
+			} `json:"configurations"`
+		} `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+func (f nvdFeed) Fetch(id string) (Advisory, error) {
+	header := http.Header{}
+	if f.apiKey != "" {
+		header.Set("apiKey", f.apiKey)
+	}
+	var resp nvdResponse
+	if err := getJSON(f.baseURL+"?cveId="+url.QueryEscape(id), header, &resp); err != nil {
+		return Advisory{}, err
+	}
+	if len(resp.Vulnerabilities) == 0 {
+		return Advisory{}, fmt.Errorf("%s introuvable dans la NVD", id)
+	}
+	cve := resp.Vulnerabilities[0].CVE
+	advisory := Advisory{ID: id, Modified: cve.LastModified}
+	for _, description := range cve.Descriptions {
+		if description.Lang == "en" {
+			advisory.Description = description.Value
+		}
+	}
+	for _, metrics := range [][]nvdCVSSMetric{cve.Metrics.V31, cve.Metrics.V30, cve.Metrics.V2} {
+		if len(metrics) == 0 {
+			continue
+		}
+		severity := metrics[0].CVSSData.BaseSeverity
+		if severity == "" {
+			severity = metrics[0].BaseSeverity
+		}
+		advisory.Severity = advisorySeverity(severity)
+		break
+	}
+
+	// Seules les configurations CPE de PHP lui-même (cpe:2.3:a:php:php) sont retenues.
+	var ranges []string
+	for _, configuration := range cve.Configurations {
+		for _, node := range configuration.Nodes {
+			for _, match := range node.CPEMatch {
+				fields := strings.Split(match.Criteria, ":")
+				if !match.Vulnerable || len(fields) < 6 || fields[3] != "php" || fields[4] != "php" {
+					continue
+				}
+				if version := fields[5]; version != "*" && version != "-" {
+					ranges = append(ranges, "="+version)
+					continue
+				}
+				lower, lowerOp := match.VersionStartIncluding, ">="
+				if match.VersionStartExcluding != "" {
+					lower, lowerOp = match.VersionStartExcluding, ">"
+				}
+				upper, upperOp := match.VersionEndExcluding, "<"
+				if match.VersionEndIncluding != "" {
+					upper, upperOp = match.VersionEndIncluding, "<="
+				}
+				if r := versionRange(lower, lowerOp, upper, upperOp); r != "" {
+					ranges = append(ranges, r)
+				}
+			}
+		}
+	}
+	advisory.PHP = strings.Join(ranges, " || ")
+	return advisory, nil
+}
+
+// versionRange écrit une plage au format des règles (">=7.3, <7.3.1") ; une borne
+// inférieure "0" est omise.
+func versionRange(lower, lowerOp, upper, upperOp string) string {
+	var parts []string
+	if lower != "" && lower != "0" {
+		parts = append(parts, lowerOp+lower)
+	}
+	if upper != "" {
+		parts = append(parts, upperOp+upper)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// advisorySeverity convertit une sévérité CVSS ou GitHub (MODERATE) en sévérité de
+// l'analyseur ; une valeur inconnue donne "".
+func advisorySeverity(text string) string {
+	if strings.EqualFold(text, "moderate") {
+		return SeverityMedium.String()
+	}
+	severity, err := ParseSeverity(text)
+	if err != nil || severity == SeverityInfo {
+		return ""
+	}
+	return severity.String()
+}
+
+// FetchAdvisories interroge le flux pour chaque signature de CVE. Une CVE introuvable
+// ou une contrainte de version illisible n'interrompt pas la mise à jour : l'erreur est
+// retournée avec les autres.
+func FetchAdvisories(feed AdvisoryFeed, rules []*SignatureRule) ([]Advisory, []error) {
+	var advisories []Advisory
+	var errs []error
+	for _, rule := range rules {
+		if !strings.HasPrefix(rule.ID, "CVE-") {
+			continue
+		}
+		advisory, err := feed.Fetch(rule.ID)
+		if err == nil && advisory.PHP != "" {
+			_, err = parseVersionConstraint(advisory.PHP)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s : %w", rule.ID, err))
+			continue
+		}
+		advisories = append(advisories, advisory)
+	}
+	return advisories, errs
+}
+
+// advisoryFile est le format du fichier écrit par "rules update".
+type advisoryFile struct {
+	Source     string     `yaml:"source"`
+	Updated    string     `yaml:"updated"`
+	Advisories []Advisory `yaml:"advisories"`
+}
+
+// SaveAdvisories écrit les avis téléchargés depuis source.
+func SaveAdvisories(path, source string, advisories []Advisory) error {
+	data, err := yaml.Marshal(advisoryFile{
+		Source:     source,
+		Updated:    time.Now().UTC().Format(time.RFC3339),
+		Advisories: advisories,
+	})
+	if err != nil {
+		return err
+	}
+	header := "# Généré par \"php-analyzer rules update\" : métadonnées des CVE appliquées aux\n# signatures embarquées.\n"
+	return os.WriteFile(path, append([]byte(header), data...), 0o644)
+}
+
+// LoadAdvisories lit un fichier d'avis ; un fichier absent n'en donne aucun.
+func LoadAdvisories(path string) ([]Advisory, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file advisoryFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return file.Advisories, nil
+}
+
+// ApplyAdvisories retourne les signatures mises à jour par les avis : description,
+// versions affectées et sévérité des signatures de même identifiant sont remplacées
+// lorsque l'avis les renseigne. Les signatures d'origine ne sont pas modifiées.
+func ApplyAdvisories(rules []*SignatureRule, advisories []Advisory) ([]*SignatureRule, error) {
+	byID := make(map[string]Advisory, len(advisories))
+	for _, advisory := range advisories {
+		byID[advisory.ID] = advisory
+	}
+	updated := make([]*SignatureRule, len(rules))
+	for i, rule := range rules {
+		advisory, ok := byID[rule.ID]
+		if !ok {
+			updated[i] = rule
+			continue
+		}
+		copied := *rule
+		if advisory.Description != "" {
+			copied.Description = advisory.Description
+		}
+		if advisory.Severity != "" {
+			severity, err := ParseSeverity(advisory.Severity)
+			if err != nil {
+				return nil, fmt.Errorf("%s : %w", advisory.ID, err)
+			}
+			copied.Severity, copied.severity = advisory.Severity, severity
+		}
+		if advisory.PHP != "" {
+			versions, err := parseVersionConstraint(advisory.PHP)
+			if err != nil {
+				return nil, fmt.Errorf("%s : %w", advisory.ID, err)
+			}
+			copied.PHP, copied.versions = advisory.PHP, versions
+		}
+		updated[i] = &copied
+	}
+	return updated, nil
+}
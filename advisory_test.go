@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOSVFeed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/vulns/CVE-2019-9025" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{
+  "id": "CVE-2019-9025",
+  "summary": "mb_split heap over-read",
+  "modified": "2024-01-02T00:00:00Z",
+  "affected": [{
+    "package": {"name": "php"},
+    "ranges": [
+      {"type": "GIT", "events": [{"introduced": "0"}, {"fixed": "abc123"}]},
+      {"type": "ECOSYSTEM", "events": [{"introduced": "7.3.0"}, {"fixed": "7.3.1"}]}
+    ]
+  }],
+  "database_specific": {"severity": "CRITICAL"}
+}`))
+	}))
+	defer server.Close()
+
+	feed := NewOSVFeed(server.URL + "/v1/vulns")
+	advisory, err := feed.Fetch("CVE-2019-9025")
+	assert.NoError(t, err)
+	assert.Equal(t, Advisory{
+		ID:          "CVE-2019-9025",
+		Description: "mb_split heap over-read",
+		PHP:         ">=7.3.0, <7.3.1",
+		Severity:    "critical",
+		Modified:    "2024-01-02T00:00:00Z",
+	}, advisory)
+
+	_, err = feed.Fetch("CVE-0000-0000")
+	assert.ErrorContains(t, err, "statut HTTP 404")
+}
+
+func TestNVDFeed(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "CVE-2019-11039", r.URL.Query().Get("cveId"))
+		assert.Equal(t, "secret", r.Header.Get("apiKey"))
+		w.Write([]byte(`{"vulnerabilities": [{"cve": {
+  "id": "CVE-2019-11039",
+  "lastModified": "2023-05-06T07:08:09",
+  "descriptions": [{"lang": "es", "value": "..."}, {"lang": "en", "value": "iconv_mime_decode_headers out-of-bounds read"}],
+  "metrics": {"cvssMetricV31": [{"cvssData": {"baseSeverity": "HIGH"}}], "cvssMetricV2": [{"baseSeverity": "MEDIUM"}]},
+  "configurations": [{"nodes": [{"cpeMatch": [
+    {"vulnerable": true, "criteria": "cpe:2.3:a:php:php:*:*:*:*:*:*:*:*", "versionEndExcluding": "7.1.30"},
+    {"vulnerable": true, "criteria": "cpe:2.3:a:php:php:*:*:*:*:*:*:*:*", "versionStartIncluding": "7.2.0", "versionEndExcluding": "7.2.19"},
+    {"vulnerable": true, "criteria": "cpe:2.3:a:php:php:7.3.0:*:*:*:*:*:*:*"},
+    {"vulnerable": true, "criteria": "cpe:2.3:o:debian:debian_linux:9.0:*:*:*:*:*:*:*"}
+  ]}]}]
+}}]}`))
+	}))
+	defer server.Close()
+
+	advisory, err := NewNVDFeed(server.URL, "secret").Fetch("CVE-2019-11039")
+	assert.NoError(t, err)
+	assert.Equal(t, "iconv_mime_decode_headers out-of-bounds read", advisory.Description)
+	assert.Equal(t, "high", advisory.Severity)
+	assert.Equal(t, "<7.1.30 || >=7.2.0, <7.2.19 || =7.3.0", advisory.PHP)
+}
+
+type fakeFeed map[string]Advisory
+
+func (f fakeFeed) Fetch(id string) (Advisory, error) {
+	if advisory, ok := f[id]; ok {
+		return advisory, nil
+	}
+	return Advisory{}, assert.AnError
+}
+
+func TestAdvisoriesUpdateBuiltinSignatures(t *testing.T) {
+	feed := fakeFeed{
+		"CVE-2017-7189": {ID: "CVE-2017-7189", Description: "fsockopen", Severity: "low", PHP: "<7.1.5"},
+		"CVE-2019-9025": {ID: "CVE-2019-9025", PHP: ">=7.3, <<7.3.1"},
+	}
+	cves := 0
+	for _, rule := range builtinSignatures {
+		if strings.HasPrefix(rule.ID, "CVE-") {
+			cves++
+		}
+	}
+	advisories, errs := FetchAdvisories(feed, builtinSignatures)
+	assert.Len(t, advisories, 1)
+	assert.Len(t, errs, cves-1, "unknown CVEs and invalid ranges are reported, non-CVE rules skipped")
+
+	path := filepath.Join(t.TempDir(), "advisories.yaml")
+	assert.NoError(t, SaveAdvisories(path, "osv", advisories))
+	loaded, err := LoadAdvisories(path)
+	assert.NoError(t, err)
+	assert.Equal(t, advisories, loaded)
+
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, loadSignatures(analyzer, "", path))
+	rule := analyzer.Signatures.ForFunction("fsockopen")[0]
+	assert.Equal(t, "fsockopen", rule.Description)
+	assert.Equal(t, SeverityLow, rule.Finding(1).Severity)
+	assert.False(t, rule.appliesTo("7.2"))
+	assert.Empty(t, builtinSignatures[0].Description, "builtin rules are not modified")
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// BaselineEntry est un résultat existant enregistré dans la baseline. Seule l'empreinte
+// sert à la comparaison ; la règle, le fichier et la ligne aident à relire le fichier.
+type BaselineEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	Rule        string `json:"rule"`
+	File        string `json:"file"`
+	Line        uint32 `json:"line"`
+}
+
+// baselineFile est le format du fichier de baseline.
+type baselineFile struct {
+	Findings []BaselineEntry `json:"findings"`
+}
+
+// Baseline masque les résultats déjà présents lors de son enregistrement, pour adopter
+// l'analyseur sur un code existant en ne signalant que les nouveaux résultats. Si le
+// fichier n'existe pas, la première analyse l'enregistre : tous ses résultats sont
+// considérés comme existants.
+type Baseline struct {
+	path     string
+	creating bool
+	known    map[string]bool
+
+	mu         sync.Mutex
+	recorded   []BaselineEntry
+	suppressed int
+}
+
+// LoadBaseline lit une baseline ; un fichier absent donne une baseline à enregistrer.
+func LoadBaseline(path string) (*Baseline, error) {
+	baseline := &Baseline{path: path, known: make(map[string]bool)}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		baseline.creating = true
+		return baseline, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var file baselineFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	for _, entry := range file.Findings {
+		baseline.known[entry.Fingerprint] = true
+	}
+	return baseline, nil
+}
+
+// findingFingerprint calcule l'empreinte d'un résultat à partir de sa règle, de son
+// fichier et du texte de sa ligne, sans le numéro de ligne, pour qu'elle survive aux
+// ajouts et suppressions de lignes ailleurs dans le fichier. occurrence distingue les
+// résultats identiques d'un même fichier.
+func findingFingerprint(file string, source []byte, f Finding, occurrence int) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s\x00%s\x00%s\x00%d", f.RuleID, filepath.ToSlash(file), singleLine(sourceLine(source, f.Line)), occurrence)
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// sourceLine retourne le texte de la ligne line (à partir de 1) d'un fichier source.
+func sourceLine(source []byte, line uint32) string {
+	lines := bytes.Split(source, []byte("\n"))
+	if line == 0 || int(line) > len(lines) {
+		return ""
+	}
+	return string(lines[line-1])
+}
+
+// fingerprintFindings calcule les empreintes des résultats d'un fichier, dans l'ordre,
+// en numérotant les résultats identiques.
+func fingerprintFindings(file string, source []byte, findings []Finding) []string {
+	occurrences := make(map[string]int)
+	fingerprints := make([]string, len(findings))
+	for i, f := range findings {
+		key := f.RuleID + "\x00" + singleLine(sourceLine(source, f.Line))
+		fingerprints[i] = findingFingerprint(file, source, f, occurrences[key])
+		occurrences[key]++
+	}
+	return fingerprints
+}
+
+// assignFingerprints renseigne l'empreinte des résultats d'un fichier.
+func assignFingerprints(file string, source []byte, findings []Finding) {
+	for i, fingerprint := range fingerprintFindings(file, source, findings) {
+		findings[i].Fingerprint = fingerprint
+	}
+}
+
+// has indique si une empreinte figure dans la baseline ; une baseline nil n'en contient
+// aucune.
+func (b *Baseline) has(fingerprint string) bool {
+	return b != nil && b.known[fingerprint]
+}
+
+// Filter retire les résultats d'un fichier présents dans la baseline ; en cours
+// d'enregistrement, il les enregistre tous et n'en retourne aucun. Une baseline nil ne
+// filtre rien.
+func (b *Baseline) Filter(file string, source []byte, findings []Finding) []Finding {
+	if b == nil {
+		return findings
+	}
+	fingerprints := fingerprintFindings(file, source, findings)
+	var kept []Finding
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i, f := range findings {
+		fingerprint := fingerprints[i]
+		switch {
+		case b.creating:
+			b.recorded = append(b.recorded, BaselineEntry{Fingerprint: fingerprint, Rule: f.RuleID, File: filepath.ToSlash(file), Line: f.Line})
+		case b.known[fingerprint]:
+			b.suppressed++
+		default:
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// Finish enregistre la baseline si elle vient d'être créée, puis résume son effet.
+func (b *Baseline) Finish(out io.Writer) error {
+	if b == nil {
+		return nil
+	}
+	if !b.creating {
+		if b.suppressed > 0 {
+			fmt.Fprintf(out, "%d résultat(s) existant(s) masqué(s) par la baseline %q.\n", b.suppressed, b.path)
+		}
+		return nil
+	}
+	if err := writeBaseline(b.path, b.recorded); err != nil {
+		return fmt.Errorf("Erreur lors de l'écriture de la baseline %q: %v", b.path, err)
+	}
+	fmt.Fprintf(out, "Baseline %q créée avec %d résultat(s) existant(s) ; les analyses suivantes ne signaleront que les nouveaux résultats.\n", b.path, len(b.recorded))
+	return nil
+}
+
+// AddBaselineEntries ajoute des résultats à la baseline de path, créée si elle n'existe
+// pas ; les empreintes déjà présentes ne sont pas dupliquées.
+func AddBaselineEntries(path string, entries []BaselineEntry) error {
+	var file baselineFile
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if err := json.Unmarshal(data, &file); err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	known := make(map[string]bool)
+	for _, entry := range file.Findings {
+		known[entry.Fingerprint] = true
+	}
+	for _, entry := range entries {
+		if !known[entry.Fingerprint] {
+			known[entry.Fingerprint] = true
+			file.Findings = append(file.Findings, entry)
+		}
+	}
+	return writeBaseline(path, file.Findings)
+}
+
+// writeBaseline écrit les entrées d'une baseline, triées par fichier, ligne et règle.
+func writeBaseline(path string, entries []BaselineEntry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		a, c := entries[i], entries[j]
+		if a.File != c.File {
+			return a.File < c.File
+		}
+		if a.Line != c.Line {
+			return a.Line < c.Line
+		}
+		return a.Rule < c.Rule
+	})
+	data, err := json.MarshalIndent(baselineFile{Findings: entries}, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o644)
+}
+
+// fingerprintPath retourne le chemin d'un fichier tel qu'il entre dans son empreinte :
+// relatif au dossier analysé, pour que la baseline reste valable ailleurs, et sans lien
+// symbolique, pour qu'un fichier atteint par plusieurs chemins ait une seule empreinte.
+func fingerprintPath(root, path string) string {
+	if rel, err := filepath.Rel(canonicalPath(root), canonicalPath(path)); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// canonicalPath retourne le chemin absolu d'un fichier, liens symboliques résolus
+// lorsqu'il existe.
+func canonicalPath(path string) string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+		return resolved
+	}
+	return abs
+}
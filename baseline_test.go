@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaselineReportsOnlyNewFindings(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.php")
+	assert.NoError(t, os.WriteFile(file, []byte(`<?php
+var_dump($user);
+mysql_query("SELECT * FROM users WHERE id=" . $id);`), 0o644))
+	baselinePath := filepath.Join(t.TempDir(), "baseline.json")
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir, "-baseline", baselinePath}, &out))
+	assert.NotContains(t, out.String(), "debug-leftover")
+	assert.Contains(t, out.String(), "créée avec 2 résultat(s) existant(s)")
+
+	baseline, err := LoadBaseline(baselinePath)
+	assert.NoError(t, err)
+	assert.Len(t, baseline.known, 2)
+
+	// Les lignes existantes se décalent et un nouveau résultat apparaît.
+	assert.NoError(t, os.WriteFile(file, []byte(`<?php
+// Utilisateurs
+var_dump($user);
+print_r($order);
+mysql_query("SELECT * FROM users WHERE id=" . $id);`), 0o644))
+	out.Reset()
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir, "-baseline", baselinePath}, &out))
+	assert.Contains(t, out.String(), "print_r")
+	assert.NotContains(t, out.String(), "var_dump")
+	assert.NotContains(t, out.String(), "sqli-concat")
+	assert.Contains(t, out.String(), "2 résultat(s) existant(s) masqué(s)")
+
+	// Sans baseline, tous les résultats sont signalés.
+	out.Reset()
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir}, &out))
+	assert.Contains(t, out.String(), "var_dump")
+}
+
+func TestBaselineFingerprintOccurrences(t *testing.T) {
+	source := []byte("<?php\nvar_dump($a);\nvar_dump($a);")
+	findings := []Finding{newFinding("debug-leftover", 2, ""), newFinding("debug-leftover", 3, "")}
+
+	baseline := &Baseline{creating: true}
+	assert.Empty(t, baseline.Filter("a.php", source, findings))
+	assert.Len(t, baseline.recorded, 2)
+	assert.NotEqual(t, baseline.recorded[0].Fingerprint, baseline.recorded[1].Fingerprint)
+
+	known := &Baseline{known: map[string]bool{baseline.recorded[0].Fingerprint: true}}
+	kept := known.Filter("a.php", source, findings)
+	assert.Len(t, kept, 1)
+	assert.Equal(t, uint32(3), kept[0].Line)
+	assert.Len(t, known.Filter("b.php", source, findings), 2)
+
+	var nilBaseline *Baseline
+	assert.Equal(t, findings, nilBaseline.Filter("a.php", source, findings))
+}
+
+func TestFingerprintDeduplication(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.php"), []byte("<?php\nvar_dump($a);\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "copy.php"), []byte("<?php\nvar_dump($a);\n"), 0o644))
+	if err := os.Symlink("a.php", filepath.Join(dir, "link.php")); err != nil {
+		t.Skip("liens symboliques indisponibles")
+	}
+	assert.Equal(t, "a.php", fingerprintPath(dir, filepath.Join(dir, "link.php")))
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatJSON
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir, "-min-severity=low"}, &out))
+	var report jsonReport
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &report))
+	// Le lien symbolique n'est pas signalé une seconde fois ; la copie a son propre chemin.
+	if assert.Len(t, report.Findings, 2) {
+		assert.Equal(t, filepath.Join(dir, "a.php"), report.Findings[0].File)
+		assert.Equal(t, filepath.Join(dir, "copy.php"), report.Findings[1].File)
+	}
+	assert.Equal(t, 1, report.Summary.Duplicates)
+
+	out.Reset()
+	analyzer.Format = formatJUnit
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir, "-min-severity=low"}, &out))
+	assert.Contains(t, out.String(), "Empreinte : "+report.Findings[0].Fingerprint)
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"sort"
+	"sync"
+	"time"
+)
+
+// cfgTiming est le nom sous lequel bench compte la construction du CFG, partagée par
+// les détecteurs qui l'utilisent.
+const cfgTiming = "(cfg)"
+
+// detectorTimings cumule le temps passé dans chaque détecteur, pour la commande bench.
+// Il peut être alimenté par plusieurs goroutines.
+type detectorTimings struct {
+	mu      sync.Mutex
+	elapsed map[string]time.Duration
+}
+
+func newDetectorTimings() *detectorTimings {
+	return &detectorTimings{elapsed: make(map[string]time.Duration)}
+}
+
+// measure exécute fn et ajoute sa durée à celle du détecteur name ; sans mesure en
+// cours (t nil), fn est seulement exécuté.
+func (t *detectorTimings) measure(name string, fn func()) {
+	if t == nil {
+		fn()
+		return
+	}
+	start := time.Now()
+	fn()
+	elapsed := time.Since(start)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.elapsed[name] += elapsed
+}
+
+// benchResult mesure le scan d'un ensemble de fichiers. Parse et Analysis cumulent les
+// temps des workers et peuvent dépasser Wall avec un profil parallèle.
+type benchResult struct {
+	Files     int
+	Bytes     int64
+	Errors    int
+	Wall      time.Duration
+	Parse     time.Duration
+	Analysis  time.Duration
+	Detectors map[string]time.Duration
+}
+
+// benchFile mesure l'analyse d'un fichier.
+type benchFile struct {
+	size            int64
+	parse, analysis time.Duration
+	err             bool
+}
+
+// Benchmark analyse les fichiers PHP des chemins donnés comme analyze-dir, sans cache
+// des résultats ni filtre, en mesurant séparément le parsing, l'analyse et chaque
+// détecteur.
+func (pa *PHPAnalyzer) Benchmark(ctx context.Context, paths ...string) (benchResult, error) {
+	timings := newDetectorTimings()
+	pa.timings = timings
+	defer func() { pa.timings = nil }()
+	result := benchResult{}
+	start := time.Now()
+	err := scanPHPFiles(ctx, paths, pa, func(ctx context.Context, fa *PHPAnalyzer, path string) benchFile {
+		parseStart := time.Now()
+		tree, content, err := fa.ParseFile(ctx, path)
+		if err != nil {
+			if !fa.interrupted(ctx, path) {
+				fa.fileError("Erreur d'analyse du fichier %q: %v", path, err)
+			}
+			return benchFile{err: true}
+		}
+		file := benchFile{size: int64(len(content)), parse: time.Since(parseStart)}
+		analysisStart := time.Now()
+		fa.DetectVulnerabilities(ctx, tree, content)
+		file.analysis = time.Since(analysisStart)
+		fa.releaseTree(tree)
+		file.err = fa.interrupted(ctx, path)
+		return file
+	}, func(file benchFile) {
+		if file.err {
+			result.Errors++
+			return
+		}
+		result.Files++
+		result.Bytes += file.size
+		result.Parse += file.parse
+		result.Analysis += file.analysis
+	})
+	result.Wall = time.Since(start)
+	result.Detectors = timings.elapsed
+	return result, err
+}
+
+// writeBenchReport affiche les mesures de bench : débit, répartition entre parsing et
+// analyse, puis les détecteurs du plus lent au plus rapide.
+func writeBenchReport(out io.Writer, r benchResult) {
+	rate := 0.0
+	if r.Wall > 0 {
+		rate = float64(r.Files) / r.Wall.Seconds()
+	}
+	fmt.Fprintf(out, "Fichiers analysés : %d (%s) en %s, soit %.1f fichiers/s\n", r.Files, formatBytes(r.Bytes), r.Wall.Round(time.Millisecond), rate)
+	if r.Errors > 0 {
+		fmt.Fprintf(out, "Fichiers en erreur : %d\n", r.Errors)
+	}
+	total := r.Parse + r.Analysis
+	fmt.Fprintf(out, "Parsing : %10s  %5.1f %%\n", roundDuration(r.Parse), percent(r.Parse, total))
+	fmt.Fprintf(out, "Analyse : %10s  %5.1f %%\n", roundDuration(r.Analysis), percent(r.Analysis, total))
+
+	names := make([]string, 0, len(r.Detectors))
+	for name := range r.Detectors {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := r.Detectors[names[i]], r.Detectors[names[j]]
+		if a != b {
+			return a > b
+		}
+		return names[i] < names[j]
+	})
+	if len(names) > 0 {
+		fmt.Fprintln(out, "\nDétecteurs (part de l'analyse) :")
+	}
+	for _, name := range names {
+		fmt.Fprintf(out, "  %-12s %10s  %5.1f %%\n", name, roundDuration(r.Detectors[name]), percent(r.Detectors[name], r.Analysis))
+	}
+}
+
+// roundDuration arrondit une durée à la milliseconde, ou à la microseconde sous 100 ms.
+func roundDuration(d time.Duration) time.Duration {
+	if d >= 100*time.Millisecond {
+		return d.Round(time.Millisecond)
+	}
+	return d.Round(time.Microsecond)
+}
+
+func percent(part, total time.Duration) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return 100 * float64(part) / float64(total)
+}
+
+// formatBytes écrit une taille en octets avec l'unité la plus adaptée.
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<30:
+		return fmt.Sprintf("%.1f Gio", float64(n)/(1<<30))
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f Mio", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f Kio", float64(n)/(1<<10))
+	}
+	return fmt.Sprintf("%d octets", n)
+}
+
+// runBenchCommand exécute la commande bench.
+func runBenchCommand(ctx context.Context, analyzer *PHPAnalyzer, args []string, out io.Writer) error {
+	benchCmd := newFlagSet("bench", out)
+	dirPath := benchCmd.String("dir", "", "Dossier à analyser")
+	profiling := addProfileFlags(benchCmd)
+	rulesPath := benchCmd.String("rules", "", "Fichier ou dossier de règles YAML supplémentaires")
+	advisoriesPath := benchCmd.String("advisories", defaultAdvisoriesPath, "Fichier d'avis écrit par rules update")
+	selection := addSelectionFlags(benchCmd)
+	excludes := addExcludeFlags(benchCmd)
+	inputs, err := analyzer.parseCommandLine(benchCmd, args)
+	if err != nil {
+		return err
+	}
+	if err := applyExcludes(analyzer, excludes); err != nil {
+		return err
+	}
+	paths := scanPaths(*dirPath, inputs)
+	if len(paths) == 0 {
+		fmt.Fprintln(out, "Le flag -dir ou un chemin est requis pour la commande bench.")
+		benchCmd.Usage()
+		return errUsage
+	}
+	if err := loadSignatures(analyzer, *rulesPath, *advisoriesPath); err != nil {
+		return err
+	}
+	if err := applySelection(analyzer, selection); err != nil {
+		return err
+	}
+	if err := applyProfile(ctx, analyzer, profiling, paths...); err != nil {
+		return err
+	}
+	// Le cache des résultats fausserait les mesures, et le résumé de fin de scan
+	// ferait double emploi avec le rapport.
+	analyzer.Profile.UseCache = false
+	analyzer.outcome = nil
+	result, err := analyzer.Benchmark(ctx, paths...)
+	if err != nil {
+		return fmt.Errorf("Erreur lors de la traversée de %s: %v", quotePaths(paths), err)
+	}
+	writeBenchReport(out, result)
+	return nil
+}
+
+// startProfiling démarre le profil CPU de --cpuprofile ; la fonction retournée l'arrête
+// et écrit le profil mémoire de --memprofile. Un chemin vide désactive le profil.
+func startProfiling(cpuPath, memPath string) (func() error, error) {
+	var cpuFile *os.File
+	if cpuPath != "" {
+		var err error
+		if cpuFile, err = os.Create(cpuPath); err != nil {
+			return nil, fmt.Errorf("Erreur lors de la création du profil CPU %q: %v", cpuPath, err)
+		}
+		if err := pprof.StartCPUProfile(cpuFile); err != nil {
+			cpuFile.Close()
+			return nil, fmt.Errorf("Erreur lors du démarrage du profil CPU: %v", err)
+		}
+	}
+	return func() error {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			if err := cpuFile.Close(); err != nil {
+				return fmt.Errorf("Erreur lors de l'écriture du profil CPU %q: %v", cpuPath, err)
+			}
+		}
+		if memPath == "" {
+			return nil
+		}
+		memFile, err := os.Create(memPath)
+		if err != nil {
+			return fmt.Errorf("Erreur lors de la création du profil mémoire %q: %v", memPath, err)
+		}
+		defer memFile.Close()
+		runtime.GC() // statistiques du tas à jour
+		if err := pprof.WriteHeapProfile(memFile); err != nil {
+			return fmt.Errorf("Erreur lors de l'écriture du profil mémoire %q: %v", memPath, err)
+		}
+		return nil
+	}, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBenchCommand(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.php"), []byte("<?php\n$a = $_GET['x'];\necho $a;\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.php"), []byte("<?php\nmd5($password);\n"), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	result, err := analyzer.Benchmark(context.Background(), dir)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Files)
+	assert.Positive(t, result.Parse)
+	assert.Positive(t, result.Analysis)
+	assert.Contains(t, result.Detectors, "taint")
+	assert.Nil(t, analyzer.timings, "timings are only measured during the benchmark")
+
+	var out strings.Builder
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "bench", []string{"-only", "taint", dir}, &out))
+	assert.Contains(t, out.String(), "Fichiers analysés : 2")
+	assert.Contains(t, out.String(), "fichiers/s")
+	assert.Contains(t, out.String(), "  taint ")
+	assert.NotContains(t, out.String(), "  crypto ", "disabled detectors are not timed")
+	assert.NotContains(t, out.String(), "Résumé", "bench writes no scan summary")
+}
+
+func TestStartProfiling(t *testing.T) {
+	dir := t.TempDir()
+	cpu, mem := filepath.Join(dir, "cpu.out"), filepath.Join(dir, "mem.out")
+	stop, err := startProfiling(cpu, mem)
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NoError(t, stop())
+	for _, path := range []string{cpu, mem} {
+		info, err := os.Stat(path)
+		if assert.NoError(t, err) {
+			assert.Positive(t, info.Size())
+		}
+	}
+
+	stop, err = startProfiling("", "")
+	assert.NoError(t, err)
+	assert.NoError(t, stop())
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BlameInfo est la dernière modification d'une ligne signalée, d'après git blame. Un
+// commit vide désigne une ligne modifiée dans la copie de travail et pas encore commitée.
+type BlameInfo struct {
+	Commit string `json:"commit,omitempty"`
+	Author string `json:"author,omitempty"`
+	Email  string `json:"email,omitempty"`
+	Date   string `json:"date,omitempty"` // RFC 3339, en UTC
+}
+
+// String met en forme l'attribution : commit abrégé, auteur et date.
+func (b BlameInfo) String() string {
+	if b.Commit == "" {
+		return "non commitée"
+	}
+	date, _, _ := strings.Cut(b.Date, "T")
+	return fmt.Sprintf("%s (%s, %s)", b.Commit[:min(len(b.Commit), 8)], b.Author, date)
+}
+
+// annotateBlame attribue à chaque résultat d'un fichier le dernier commit de sa ligne
+// (-blame). Un fichier hors d'un dépôt git ou inconnu de git garde ses résultats sans
+// attribution.
+func (pa *PHPAnalyzer) annotateBlame(path string, findings []Finding) {
+	if !pa.Blame || len(findings) == 0 || path == stdinPath {
+		return
+	}
+	lines, err := gitBlame(path)
+	if err != nil {
+		log.Printf("Attribution git indisponible pour %q: %v", path, err)
+		return
+	}
+	for i := range findings {
+		if blame, ok := lines[findings[i].Line]; ok {
+			findings[i].Blame = &blame
+		}
+	}
+}
+
+// gitBlame retourne la dernière modification de chaque ligne d'un fichier.
+func gitBlame(path string) (map[uint32]BlameInfo, error) {
+	out, err := git("-C", filepath.Dir(path), "blame", "--line-porcelain", "--", filepath.Base(path))
+	if err != nil {
+		return nil, err
+	}
+	return parseBlame(out), nil
+}
+
+// parseBlame lit la sortie de git blame --line-porcelain : pour chaque ligne, un en-tête
+// "<commit> <ligne d'origine> <ligne>", les métadonnées du commit puis le texte de la
+// ligne précédé d'une tabulation.
+func parseBlame(out []byte) map[uint32]BlameInfo {
+	lines := map[uint32]BlameInfo{}
+	var current BlameInfo
+	var line uint64
+	header := true
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		text := scanner.Text()
+		if header {
+			fields := strings.Fields(text)
+			if len(fields) < 3 {
+				continue
+			}
+			current = BlameInfo{Commit: fields[0]}
+			if strings.Trim(current.Commit, "0") == "" {
+				current.Commit = ""
+			}
+			line, _ = strconv.ParseUint(fields[2], 10, 32)
+			header = false
+			continue
+		}
+		key, value, _ := strings.Cut(text, " ")
+		switch {
+		case strings.HasPrefix(text, "\t"):
+			if current.Commit == "" {
+				current = BlameInfo{}
+			}
+			lines[uint32(line)] = current
+			header = true
+		case key == "author":
+			current.Author = value
+		case key == "author-mail":
+			current.Email = strings.Trim(value, "<>")
+		case key == "author-time":
+			if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+				current.Date = time.Unix(seconds, 0).UTC().Format(time.RFC3339)
+			}
+		}
+	}
+	return lines
+}
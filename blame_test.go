@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseBlame(t *testing.T) {
+	out := "1f2e3d4c5b6a79881f2e3d4c5b6a79881f2e3d4c 1 1 1\n" +
+		"author Alice\nauthor-mail <alice@example.com>\nauthor-time 1700000000\nauthor-tz +0100\nfilename a.php\n\t<?php\n" +
+		"0000000000000000000000000000000000000000 2 2 1\n" +
+		"author Not Committed Yet\nauthor-mail <not.committed.yet>\nauthor-time 1800000000\nfilename a.php\n\tvar_dump($a);\n"
+	lines := parseBlame([]byte(out))
+	assert.Equal(t, BlameInfo{Commit: "1f2e3d4c5b6a79881f2e3d4c5b6a79881f2e3d4c", Author: "Alice", Email: "alice@example.com", Date: "2023-11-14T22:13:20Z"}, lines[1])
+	assert.Equal(t, "1f2e3d4c (Alice, 2023-11-14)", lines[1].String())
+	assert.Equal(t, BlameInfo{}, lines[2])
+	assert.Equal(t, "non commitée", lines[2].String())
+}
+
+func TestBlameFindings(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git indisponible")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=Alice", "-c", "user.email=alice@example.com"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(output))
+	}
+	path := filepath.Join(dir, "a.php")
+	assert.NoError(t, os.WriteFile(path, []byte("<?php\nvar_dump($a);\n"), 0o644))
+	run("init", "-q")
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+	assert.NoError(t, os.WriteFile(path, []byte("<?php\nvar_dump($a);\nprint_r($b);\n"), 0o644))
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatJSON
+	assert.NoError(t, runCommand(analyzer, "cve", []string{"-file", path, "-blame", "-min-severity=low"}, &out))
+	var report struct {
+		Findings []jsonFinding `json:"findings"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &report))
+	if assert.Len(t, report.Findings, 2) && assert.NotNil(t, report.Findings[0].Blame) && assert.NotNil(t, report.Findings[1].Blame) {
+		assert.Equal(t, "Alice", report.Findings[0].Blame.Author)
+		assert.Len(t, report.Findings[0].Blame.Commit, 40)
+		assert.Equal(t, "", report.Findings[1].Blame.Commit, "uncommitted line")
+	}
+
+	out.Reset()
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "cve", []string{"-file", path, "-blame", "-min-severity=low"}, &out))
+	assert.Contains(t, out.String(), "Dernière modification : ")
+	assert.Contains(t, out.String(), "(Alice, ")
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// analyzerVersion est la version des détecteurs : l'incrémenter à chaque changement
+// des résultats produits invalide le cache des résultats.
+const analyzerVersion = "1"
+
+// cacheVersionFile enregistre, dans le dossier du cache, la version qui l'a rempli.
+const cacheVersionFile = "VERSION"
+
+// cacheVersion identifie l'analyseur qui remplit le cache : analyzerVersion et, pour un
+// binaire compilé depuis un dépôt git, la révision dont il est issu.
+func cacheVersion() string {
+	version := analyzerVersion
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" || (setting.Key == "vcs.modified" && setting.Value == "true") {
+				version += " " + setting.Value
+			}
+		}
+	}
+	return version
+}
+
+// resultCache conserve sur disque les résultats des détecteurs par fichier, indexés par
+// l'empreinte SHA-256 du contenu du fichier et de la configuration des règles : un
+// fichier inchangé n'est pas réanalysé lors du scan suivant.
+type resultCache struct {
+	dir    string
+	config []byte // empreinte de la configuration des règles
+}
+
+// cacheFlags sont les options du cache des résultats.
+type cacheFlags struct {
+	cache   bool
+	noCache bool
+	dir     string
+}
+
+func addCacheFlags(fs *flag.FlagSet) *cacheFlags {
+	flags := &cacheFlags{}
+	fs.BoolVar(&flags.cache, "cache", false, "Réutilise les résultats des scans précédents, conservés dans le cache des résultats")
+	fs.BoolVar(&flags.noCache, "no-cache", false, "Réanalyse tous les fichiers sans lire ni écrire le cache des résultats")
+	fs.StringVar(&flags.dir, "cache-dir", "", "Dossier du cache des résultats, qui active le cache (défaut : <dossier de cache de l'utilisateur>/php-analyzer/results)")
+	return flags
+}
+
+// applyCache configure le cache des résultats, après le profil de scan. Le cache n'est
+// utilisé qu'avec -cache ou -cache-dir, -no-cache l'emportant sur les deux.
+func applyCache(analyzer *PHPAnalyzer, flags *cacheFlags) {
+	analyzer.CacheDir = flags.dir
+	analyzer.Profile.UseCache = (flags.cache || flags.dir != "") && !flags.noCache
+}
+
+// defaultCacheDir retourne le dossier du cache des résultats dans le dossier de cache
+// de l'utilisateur ($XDG_CACHE_HOME, ~/.cache...).
+func defaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "php-analyzer", "results"), nil
+}
+
+// openResultCache ouvre le cache des résultats du scan, ou retourne nil si le profil ne
+// l'utilise pas ou s'il est inaccessible : le scan se poursuit alors sans cache. Les
+// entrées d'une autre version de l'analyseur sont supprimées.
+func (pa *PHPAnalyzer) openResultCache() *resultCache {
+	if !pa.Profile.UseCache {
+		return nil
+	}
+	dir := pa.CacheDir
+	if dir == "" {
+		var err error
+		if dir, err = defaultCacheDir(); err != nil {
+			log.Printf("Cache des résultats désactivé : %v", err)
+			return nil
+		}
+	}
+	if err := resetStaleCache(dir); err != nil {
+		log.Printf("Cache des résultats désactivé : %v", err)
+		return nil
+	}
+	return &resultCache{dir: dir, config: pa.ruleConfigDigest()}
+}
+
+// resetStaleCache supprime les entrées du cache écrites par une autre version de
+// l'analyseur et enregistre la version courante. Seuls les sous-dossiers des entrées
+// sont supprimés, jamais le reste du dossier.
+func resetStaleCache(dir string) error {
+	version := cacheVersion()
+	versionPath := filepath.Join(dir, cacheVersionFile)
+	if data, err := os.ReadFile(versionPath); err == nil && string(data) == version {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	for _, entry := range entries {
+		if _, err := hex.DecodeString(entry.Name()); err == nil && len(entry.Name()) == 2 && entry.IsDir() {
+			if err := os.RemoveAll(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(versionPath, []byte(version), 0o644)
+}
+
+// ruleConfigDigest calcule l'empreinte de ce qui détermine les résultats d'un fichier
+// en plus de son contenu : détecteurs et règles retenus, signatures, sévérités,
+// calibration, options d'analyse et fonctions du projet, dont dépend l'analyse des
+// appels.
+func (pa *PHPAnalyzer) ruleConfigDigest() []byte {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00", cacheVersion())
+	for _, info := range RegisteredDetectors() {
+		fmt.Fprintf(h, "%s=%v\x00", info.Name, pa.DetectorEnabled(info))
+	}
+	fmt.Fprintf(h, "%v\x00%v\x00%v\x00%s\x00%v\x00%v\x00%v\x00%v\x00", pa.rules, pa.severities, pa.Calibration, pa.PHPVersion, pa.TaintDBReads, pa.Lint, pa.functions, pa.classes)
+	if pa.Signatures != nil {
+		for _, rule := range pa.Signatures.rules {
+			data, _ := json.Marshal(rule)
+			h.Write(append(data, 0))
+		}
+	}
+	return h.Sum(nil)
+}
+
+// path retourne le fichier de l'entrée d'un contenu, réparti dans des sous-dossiers
+// selon les deux premiers chiffres de sa clé.
+func (c *resultCache) path(content []byte) string {
+	h := sha256.New()
+	h.Write(c.config)
+	h.Write(content)
+	key := hex.EncodeToString(h.Sum(nil))
+	return filepath.Join(c.dir, key[:2], key[2:]+".json")
+}
+
+// load retourne les résultats enregistrés pour un contenu.
+func (c *resultCache) load(content []byte) ([]Finding, bool) {
+	if c == nil {
+		return nil, false
+	}
+	data, err := os.ReadFile(c.path(content))
+	if err != nil {
+		return nil, false
+	}
+	var findings []Finding
+	if err := json.Unmarshal(data, &findings); err != nil {
+		return nil, false
+	}
+	return findings, true
+}
+
+// store enregistre les résultats d'un contenu. L'entrée est écrite dans un fichier
+// temporaire renommé, pour qu'un scan concurrent ne lise jamais une entrée incomplète ;
+// une erreur d'écriture ne fait que priver le scan suivant de l'entrée.
+func (c *resultCache) store(content []byte, findings []Finding) {
+	if c == nil {
+		return
+	}
+	data, err := json.Marshal(findings)
+	if err != nil {
+		return
+	}
+	path := c.path(content)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "*"+tempSuffix)
+	if err != nil {
+		return
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+}
+
+// detectCached retourne les résultats des détecteurs pour un fichier, depuis le cache
+// des résultats lorsque son contenu y figure. Les résultats partiels d'une analyse
+// interrompue ne sont pas enregistrés.
+func (pa *PHPAnalyzer) detectCached(ctx context.Context, tree *sitter.Tree, content []byte) []Finding {
+	if findings, ok := pa.results.load(content); ok {
+		return findings
+	}
+	findings := pa.DetectVulnerabilities(ctx, tree, content)
+	if ctx.Err() == nil {
+		pa.results.store(content, findings)
+	}
+	return findings
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMain keeps the result cache of the scans run by the tests out of the user's
+// cache directory.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "php-analyzer-cache")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("XDG_CACHE_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func TestResultCache(t *testing.T) {
+	dir := t.TempDir()
+	cacheDir := filepath.Join(dir, "cache")
+	src := filepath.Join(dir, "src")
+	assert.NoError(t, os.MkdirAll(src, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(src, "a.php"), []byte("<?php\nmysql_query(\"SELECT * FROM t WHERE id = \" . $_GET['id']);\n"), 0o644))
+
+	scan := func(args ...string) string {
+		var out strings.Builder
+		analyzer := NewPHPAnalyzer()
+		analyzer.Out = &out
+		assert.NoError(t, runCommand(analyzer, "analyze-dir", append(args, "-cache-dir", cacheDir, src), &out))
+		return out.String()
+	}
+	first := scan()
+	assert.Contains(t, first, "sqli")
+
+	entries, err := filepath.Glob(filepath.Join(cacheDir, "*", "*.json"))
+	assert.NoError(t, err)
+	if !assert.Len(t, entries, 1) {
+		return
+	}
+	assert.Equal(t, first, scan(), "cached findings are reported like fresh ones")
+
+	// An unchanged file is not analyzed again: its cached findings are reported.
+	var findings []Finding
+	data, err := os.ReadFile(entries[0])
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(data, &findings))
+	for i := range findings {
+		findings[i].Message = "from the cache"
+	}
+	data, err = json.Marshal(findings)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(entries[0], data, 0o644))
+	assert.Contains(t, scan(), "from the cache")
+	assert.NotContains(t, scan("-no-cache"), "from the cache")
+	assert.NotContains(t, scan("-disable", "debug"), "from the cache", "the rule configuration is part of the key")
+
+	// Without -cache nor -cache-dir, whatever the profile, the cache is neither read nor written.
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Out = &out
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-profile", "default", src}, &out))
+	assert.NotContains(t, out.String(), "from the cache")
+	results := filepath.Join(os.Getenv("XDG_CACHE_HOME"), "php-analyzer", "results")
+	assert.NoDirExists(t, results)
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "analyze-dir", []string{"-cache", src}, &out))
+	assert.DirExists(t, results, "-cache uses the user's cache directory")
+
+	// Entries of another analyzer version are removed.
+	assert.NoError(t, os.WriteFile(filepath.Join(cacheDir, cacheVersionFile), []byte("0"), 0o644))
+	assert.NotContains(t, scan(), "from the cache")
+}
+
+func TestResetStaleCacheKeepsOtherFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "ab"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("x"), 0o644))
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "src"), 0o755))
+
+	assert.NoError(t, resetStaleCache(dir))
+	assert.NoDirExists(t, filepath.Join(dir, "ab"))
+	assert.FileExists(t, filepath.Join(dir, "notes.txt"))
+	assert.DirExists(t, filepath.Join(dir, "src"))
+	data, err := os.ReadFile(filepath.Join(dir, cacheVersionFile))
+	assert.NoError(t, err)
+	assert.Equal(t, cacheVersion(), string(data))
+}
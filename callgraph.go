@@ -0,0 +1,498 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Call est un appel du graphe d'appels du projet, d'une fonction ou méthode vers une
+// autre. Seuls les appels résolus vers une définition du projet y figurent, ainsi que les
+// appels dynamiques dont la cible n'a pas pu être déterminée (Callee vide).
+type Call struct {
+	Caller     string `json:"caller"`               // fonction ou méthode appelante, "" pour le code hors fonction du fichier
+	Callee     string `json:"callee"`               // fonction ou méthode appelée, "" si l'appel dynamique n'est pas résolu
+	File       string `json:"file"`                 // fichier de l'appel
+	Line       uint32 `json:"line"`                 // ligne de l'appel
+	Dynamic    bool   `json:"dynamic,omitempty"`    // call_user_func, $fn(), [$obj, 'method']...
+	Expression string `json:"expression,omitempty"` // appel dynamique non résolu, tel qu'écrit
+}
+
+// Resolved indique si la cible de l'appel est connue.
+func (c Call) Resolved() bool {
+	return c.Callee != ""
+}
+
+// CallGraph est le graphe d'appels du projet, construit par IndexProject.
+type CallGraph struct {
+	Calls []Call // triés par fichier et ligne
+
+	untyped []Call          // appels de méthodes d'objets de classe inconnue, Callee étant le nom de la méthode en minuscules
+	names   map[string]bool // noms (en minuscules, sans espace de noms ni classe) cités par des chaînes littérales
+}
+
+// Unresolved retourne les appels dynamiques dont la cible n'a pas pu être déterminée.
+func (g *CallGraph) Unresolved() []Call {
+	if g == nil {
+		return nil
+	}
+	var calls []Call
+	for _, call := range g.Calls {
+		if !call.Resolved() {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// callSite est un appel relevé lors de l'indexation d'un fichier, avant sa résolution
+// vers une définition du projet (newCallGraph) : appel de la fonction function, ou de la
+// méthode method de la classe class.
+type callSite struct {
+	call      Call
+	function  string // nom tel qu'écrit, ou complètement qualifié ("\" initial)
+	namespace string
+	class     string // nom qualifié ; la classe parente de class si parent est vrai
+	parent    bool
+	method    string
+}
+
+// dynamicCallFunctions appellent la fonction ou méthode passée en premier argument.
+var dynamicCallFunctions = map[string]bool{
+	"call_user_func":       true,
+	"call_user_func_array": true,
+}
+
+// collectCallSites relève les appels d'un fichier. Les appels de méthode sont retenus
+// lorsque la classe de l'objet est connue : $this, self, static, parent, classe nommée,
+// ou variable de la fonction affectée une seule fois par new. Les appels dynamiques
+// (call_user_func, call_user_func_array, $fn(), [$obj, 'method']()) sont résolus lorsque
+// leur cible est littérale ou une variable localement constante, et relevés comme non
+// résolus sinon.
+func collectCallSites(root *sitter.Node, source []byte, path string) []callSite {
+	resolver := newNameResolver(root, source)
+	var sites []callSite
+	traverseAST(root, func(n *sitter.Node) {
+		switch n.Type() {
+		case "function_call_expression", "member_call_expression", "nullsafe_member_call_expression", "scoped_call_expression":
+		default:
+			return
+		}
+		line := n.StartPoint().Row + 1
+		site := callSite{
+			call:      Call{Caller: callerName(n, root, source), File: path, Line: line},
+			namespace: namespaceAtLine(root, line, source),
+		}
+		ok := false
+		switch n.Type() {
+		case "function_call_expression":
+			function := n.ChildByFieldName("function")
+			if function == nil {
+				return
+			}
+			switch function.Type() {
+			case "name", "qualified_name":
+				name := function.Content(source)
+				if !dynamicCallFunctions[strings.ToLower(strings.TrimPrefix(name, `\`))] {
+					site.function = resolver.resolveFunction(name, line)
+					sites = append(sites, site)
+					return
+				}
+				site.call.Dynamic = true
+				if args := getArgumentNodes(n); len(args) > 0 {
+					ok = site.setCallable(argumentValue(args[0]), resolver, root, source, 0)
+				}
+			case "variable_name", "array_creation_expression", "string", "encapsed_string":
+				site.call.Dynamic = true
+				ok = site.setCallable(function, resolver, root, source, 0)
+			case "anonymous_function_creation_expression", "arrow_function":
+				return // fonction anonyme appelée sur place
+			default:
+				site.call.Dynamic = true
+			}
+		case "scoped_call_expression":
+			scope, name := n.ChildByFieldName("scope"), n.ChildByFieldName("name")
+			if scope == nil || name == nil {
+				return
+			}
+			site.call.Dynamic = name.Type() != "name"
+			ok = site.setClass(scope, resolver, root, source, 0) && site.setMethod(name, source)
+		default:
+			object, name := n.ChildByFieldName("object"), n.ChildByFieldName("name")
+			if object == nil || name == nil {
+				return
+			}
+			site.call.Dynamic = name.Type() != "name"
+			ok = site.setObject(object, resolver, root, source, 0) && site.setMethod(name, source)
+			if !ok && !site.call.Dynamic {
+				// Méthode d'un objet de classe inconnue, relevée par son seul nom.
+				site.class = ""
+				ok = site.setMethod(name, source)
+			}
+		}
+		if !ok {
+			if !site.call.Dynamic {
+				return
+			}
+			site.function, site.class, site.method = "", "", ""
+			site.call.Expression = clipSignature(n.Content(source))
+		}
+		sites = append(sites, site)
+	})
+	return sites
+}
+
+// callableNamePattern reconnaît une chaîne qui peut désigner une fonction ou une méthode :
+// 'mon_init', 'App\Hooks\init', 'App\Page::render'.
+var callableNamePattern = regexp.MustCompile(`^\\?[\pL_][\pL\pN_]*(\\[\pL_][\pL\pN_]*)*(::[\pL_][\pL\pN_]*)?$`)
+
+// collectCallableNames retourne les noms de fonction ou de méthode que citent les
+// chaînes littérales d'un fichier (callbacks de array_map, de usort, de hooks...), en
+// minuscules et sans espace de noms ni classe.
+func collectCallableNames(root *sitter.Node, source []byte) []string {
+	var names []string
+	traverseAST(root, func(n *sitter.Node) {
+		if n.Type() != "string" && n.Type() != "encapsed_string" {
+			return
+		}
+		value, ok := literalString(n, source)
+		if !ok || !callableNamePattern.MatchString(value) {
+			return
+		}
+		if i := strings.LastIndex(value, ":"); i >= 0 {
+			value = value[i+1:]
+		}
+		names = append(names, strings.ToLower(value[strings.LastIndex(value, `\`)+1:]))
+	})
+	return names
+}
+
+// maxCallableDepth borne le suivi des variables d'un callable ($a = $b; $b = [...]).
+const maxCallableDepth = 4
+
+// setCallable détermine la cible d'un callable : chaîne 'fonction' ou 'Classe::méthode',
+// tableau [objet ou classe, 'méthode'], ou variable localement constante qui en contient
+// un.
+func (s *callSite) setCallable(expr *sitter.Node, resolver *nameResolver, root *sitter.Node, source []byte, depth int) bool {
+	if expr == nil || depth > maxCallableDepth {
+		return false
+	}
+	switch expr.Type() {
+	case "string", "encapsed_string":
+		value, ok := literalString(expr, source)
+		if !ok || value == "" {
+			return false
+		}
+		// Le nom d'un callable est toujours complètement qualifié.
+		if class, method, found := strings.Cut(value, "::"); found {
+			s.class, s.method = strings.TrimPrefix(class, `\`), strings.ToLower(method)
+			return s.class != "" && s.method != ""
+		}
+		s.function = `\` + strings.TrimPrefix(value, `\`)
+		return true
+	case "array_creation_expression":
+		var elements []*sitter.Node
+		for i := 0; i < int(expr.NamedChildCount()); i++ {
+			if element := expr.NamedChild(i); element.Type() == "array_element_initializer" {
+				if element.NamedChildCount() != 1 {
+					return false // clé explicite
+				}
+				elements = append(elements, element.NamedChild(0))
+			}
+		}
+		if len(elements) != 2 {
+			return false
+		}
+		method, ok := literalString(elements[1], source)
+		if !ok || method == "" || !s.setObject(elements[0], resolver, root, source, depth) {
+			return false
+		}
+		s.method = strings.ToLower(method)
+		return true
+	case "variable_name":
+		return s.setCallable(localConstant(expr, source), resolver, root, source, depth+1)
+	case "parenthesized_expression":
+		return expr.NamedChildCount() == 1 && s.setCallable(expr.NamedChild(0), resolver, root, source, depth)
+	}
+	return false
+}
+
+// setObject détermine la classe d'un objet ou d'une classe désignée par une expression :
+// $this, new Classe, 'Classe', Classe::class, ou variable localement constante.
+func (s *callSite) setObject(expr *sitter.Node, resolver *nameResolver, root *sitter.Node, source []byte, depth int) bool {
+	if expr == nil || depth > maxCallableDepth {
+		return false
+	}
+	switch expr.Type() {
+	case "variable_name":
+		if expr.Content(source) == "$this" {
+			s.class = enclosingClass(expr, root, source)
+			return s.class != ""
+		}
+		return s.setObject(localConstant(expr, source), resolver, root, source, depth+1)
+	case "object_creation_expression":
+		for i := 0; i < int(expr.NamedChildCount()); i++ {
+			if child := expr.NamedChild(i); child.Type() == "name" || child.Type() == "qualified_name" {
+				return s.setClass(child, resolver, root, source, depth)
+			}
+		}
+	case "class_constant_access_expression":
+		if expr.NamedChildCount() == 2 && strings.EqualFold(expr.NamedChild(1).Content(source), "class") {
+			return s.setClass(expr.NamedChild(0), resolver, root, source, depth)
+		}
+	case "string", "encapsed_string":
+		if class, ok := literalString(expr, source); ok && class != "" {
+			s.class = strings.TrimPrefix(class, `\`)
+			return true
+		}
+	case "parenthesized_expression":
+		return expr.NamedChildCount() == 1 && s.setObject(expr.NamedChild(0), resolver, root, source, depth)
+	}
+	return false
+}
+
+// setClass détermine la classe désignée par la portée d'un appel statique : nom de
+// classe, self, static, parent, ou variable localement constante.
+func (s *callSite) setClass(scope *sitter.Node, resolver *nameResolver, root *sitter.Node, source []byte, depth int) bool {
+	switch scope.Type() {
+	case "name", "qualified_name", "relative_scope":
+		name := scope.Content(source)
+		switch strings.ToLower(name) {
+		case "self", "static":
+			s.class = enclosingClass(scope, root, source)
+		case "parent":
+			s.class, s.parent = enclosingClass(scope, root, source), true
+		default:
+			s.class = resolver.resolveClass(name, scope.StartPoint().Row+1)
+		}
+		return s.class != ""
+	case "variable_name":
+		return s.setObject(scope, resolver, root, source, depth)
+	}
+	return false
+}
+
+// setMethod retient le nom de la méthode appelée : nom écrit, ou variable localement
+// constante contenant une chaîne ($obj->$method()).
+func (s *callSite) setMethod(name *sitter.Node, source []byte) bool {
+	switch name.Type() {
+	case "name":
+		s.method = strings.ToLower(name.Content(source))
+		return true
+	case "variable_name":
+		if value := localConstant(name, source); value != nil {
+			method, ok := literalString(value, source)
+			s.method = strings.ToLower(method)
+			return ok && method != ""
+		}
+	}
+	return false
+}
+
+// literalString retourne la valeur d'une chaîne littérale, sans interpolation.
+func literalString(expr *sitter.Node, source []byte) (string, bool) {
+	if expr.Type() != "string" && expr.Type() != "encapsed_string" {
+		return "", false
+	}
+	// Les chaînes sont évaluées comme les chemins d'inclusion, qui n'en acceptent pas
+	// d'autres échappements que ceux des guillemets et de la barre oblique inverse.
+	return includePath(expr, source, "")
+}
+
+// localConstant retourne la valeur d'une variable localement constante, affectée une
+// seule fois dans sa fonction (ou hors fonction), avant son utilisation, et qui n'est ni
+// un paramètre ni modifiée autrement (affectation composée, foreach, list(), référence,
+// global, static) ; nil sinon.
+func localConstant(variable *sitter.Node, source []byte) *sitter.Node {
+	name := variable.Content(source)
+	scope := enclosingScope(variable)
+	var value *sitter.Node
+	writes := 0
+	traverseAST(scope, func(n *sitter.Node) {
+		switch n.Type() {
+		case "assignment_expression":
+			if left := n.ChildByFieldName("left"); left != nil && left.Content(source) == name {
+				writes++
+				if n.StartByte() < variable.StartByte() {
+					value = n.ChildByFieldName("right")
+				}
+			}
+		case "augmented_assignment_expression", "reference_assignment_expression":
+			if left := n.ChildByFieldName("left"); left != nil && left.Content(source) == name {
+				writes++
+			}
+		case "variable_name":
+			if n.Content(source) == name && writtenIndirectly(n, scope) {
+				writes++
+			}
+		}
+	})
+	if writes != 1 {
+		return nil
+	}
+	return value
+}
+
+// writtenIndirectly vérifie si une variable est écrite autrement que par une affectation
+// simple : paramètre, variable d'un foreach, d'un list() ou de global/static, ou
+// référence (&$v, $a = &$v).
+func writtenIndirectly(variable, scope *sitter.Node) bool {
+	child := variable
+	for p := variable.Parent(); p != nil && !p.Equal(scope); child, p = p, p.Parent() {
+		switch p.Type() {
+		case "simple_parameter", "variadic_parameter", "property_promotion_parameter", "by_ref",
+			"list_literal", "global_declaration", "static_variable_declaration":
+			return true
+		case "reference_assignment_expression":
+			return true
+		case "foreach_statement":
+			body := p.ChildByFieldName("body")
+			return p.NamedChild(0) != nil && !p.NamedChild(0).Equal(child) && (body == nil || !body.Equal(child))
+		case "function_definition", "method_declaration", "anonymous_function_creation_expression", "arrow_function":
+			return false
+		}
+	}
+	return false
+}
+
+// callerName retourne le nom qualifié de la fonction ou méthode qui contient un appel, ""
+// hors fonction. Les fonctions anonymes sont rattachées à la fonction qui les contient.
+func callerName(n *sitter.Node, root *sitter.Node, source []byte) string {
+	scope := enclosingScope(n)
+	name := scope.ChildByFieldName("name")
+	if name == nil {
+		return ""
+	}
+	switch scope.Type() {
+	case "function_definition":
+		if namespace := namespaceAtLine(root, scope.StartPoint().Row+1, source); namespace != "" {
+			return namespace + `\` + name.Content(source)
+		}
+		return name.Content(source)
+	case "method_declaration":
+		if class := enclosingClass(scope, root, source); class != "" {
+			return class + "::" + name.Content(source)
+		}
+	}
+	return ""
+}
+
+// enclosingClass retourne le nom qualifié de la classe, de l'interface, du trait ou de
+// l'énumération qui contient un nœud, "" hors classe ou dans une classe anonyme.
+func enclosingClass(n *sitter.Node, root *sitter.Node, source []byte) string {
+	class := enclosingClassName(n, source)
+	if class == "" {
+		return ""
+	}
+	if namespace := namespaceAtLine(root, n.StartPoint().Row+1, source); namespace != "" {
+		return namespace + `\` + class
+	}
+	return class
+}
+
+// newCallGraph résout les appels relevés vers les définitions du projet : fonctions de
+// l'index des symboles (selon les inclusions du fichier appelant) et méthodes de l'index
+// des classes (traits et héritage compris). Les appels vers une fonction ou une méthode
+// hors du projet (fonction native, dépendance) sont écartés.
+func newCallGraph(sites []callSite, names []string, symbols *SymbolIndex, classes classIndex) *CallGraph {
+	graph := &CallGraph{names: map[string]bool{}}
+	for _, name := range names {
+		graph.names[name] = true
+	}
+	for _, site := range sites {
+		call := site.call
+		switch {
+		case site.method != "" && site.class == "":
+			call.Callee = site.method
+			graph.untyped = append(graph.untyped, call)
+			continue
+		case site.function != "":
+			if symbol, ok := symbols.ResolveFunction(site.call.File, site.function, site.namespace); ok {
+				call.Callee = symbol.Name
+			}
+		case site.method != "":
+			class := site.class
+			if site.parent {
+				class = classes[strings.ToLower(class)].Parent
+			}
+			if method, ok := classes.resolveMethod(class, site.method); ok {
+				call.Callee = method.Name
+			}
+		}
+		if call.Resolved() || call.Expression != "" {
+			graph.Calls = append(graph.Calls, call)
+		}
+	}
+	sort.SliceStable(graph.Calls, func(i, j int) bool {
+		a, b := graph.Calls[i], graph.Calls[j]
+		if a.File != b.File {
+			return pathLess(a.File, b.File)
+		}
+		return a.Line < b.Line
+	})
+	return graph
+}
+
+// writeCalls affiche des appels, un par ligne : appelant, appelé et position. Le code
+// hors fonction est désigné par son fichier, un appel dynamique non résolu par "?" suivi
+// de l'appel tel qu'écrit.
+func writeCalls(out io.Writer, calls []Call) {
+	unresolved := 0
+	for _, call := range calls {
+		caller := call.Caller
+		if caller == "" {
+			caller = call.File
+		}
+		callee := call.Callee
+		switch {
+		case !call.Resolved():
+			callee = "? " + call.Expression
+			unresolved++
+		case call.Dynamic:
+			callee += " (dynamique)"
+		}
+		fmt.Fprintf(out, "%s -> %s  %s:%d\n", caller, callee, call.File, call.Line)
+	}
+	fmt.Fprintf(out, "%d appel(s), dont %d appel(s) dynamique(s) non résolu(s)\n", len(calls), unresolved)
+}
+
+// runCallsCommand exécute la commande calls : le graphe d'appels des chemins donnés (le
+// dossier courant par défaut), ou ses seuls appels dynamiques non résolus avec
+// -unresolved, en texte ou en JSON avec --format=json.
+func runCallsCommand(ctx context.Context, analyzer *PHPAnalyzer, args []string, out io.Writer) error {
+	cmd := newFlagSet("calls", out)
+	dirPath := cmd.String("dir", "", "Chemin vers le dossier à indexer récursivement")
+	unresolved := cmd.Bool("unresolved", false, "N'affiche que les appels dynamiques dont la cible n'a pas pu être déterminée")
+	excludes := addExcludeFlags(cmd)
+	inputs, err := analyzer.parseCommandLine(cmd, args)
+	if err != nil {
+		return err
+	}
+	if err := applyExcludes(analyzer, excludes); err != nil {
+		return err
+	}
+	paths := scanPaths(*dirPath, inputs)
+	if len(paths) == 0 {
+		paths = []string{analyzer.abs(".")}
+	}
+	if err := analyzer.IndexProject(ctx, paths...); err != nil {
+		return err
+	}
+	calls := analyzer.calls.Calls
+	if *unresolved {
+		calls = analyzer.calls.Unresolved()
+	}
+	if analyzer.Format == formatJSON {
+		if calls == nil {
+			calls = []Call{}
+		}
+		return writeIndentedJSON(out, calls)
+	}
+	writeCalls(out, calls)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallGraph(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"util.php": "<?php\nnamespace App\\Util;\nfunction fmt($a) { return $a; }\n",
+		"page.php": `<?php
+namespace App;
+use function App\Util\fmt as f;
+function helper($x) { return strtoupper($x); }
+class Base { public function boot() {} }
+trait Greets { public function greet() { return helper('hi'); } }
+class Page extends Base {
+    use Greets;
+    public function __construct() { parent::boot(); }
+    public function render() {
+        $this->greet();
+        $cb = [$this, 'greet'];
+        call_user_func($cb);
+        $fn = 'App\helper';
+        $fn('x');
+        $o = new Page();
+        $o->$method();
+        call_user_func_array([self::class, 'boot'], []);
+        call_user_func($_GET['cb']);
+        $cb2 = 'App\helper';
+        $cb2 = 'trim';
+        $cb2();
+        f(1);
+        $unknown->render();
+    }
+}
+foreach ($handlers as $h) { $h(); }
+(new Page)->render();
+`,
+	})
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, analyzer.IndexProject(context.Background(), dir))
+	page := filepath.Join(dir, "page.php")
+	var got []string
+	for _, call := range analyzer.calls.Calls {
+		assert.Equal(t, page, call.File)
+		callee := call.Callee
+		if !call.Resolved() {
+			callee = "? " + call.Expression
+		} else if call.Dynamic {
+			callee += " (dynamic)"
+		}
+		got = append(got, call.Caller+" -> "+callee)
+	}
+	assert.Equal(t, []string{
+		`App\Greets::greet -> App\helper`,
+		`App\Page::__construct -> App\Base::boot`,
+		`App\Page::render -> App\Greets::greet`,
+		`App\Page::render -> App\Greets::greet (dynamic)`,
+		`App\Page::render -> App\helper (dynamic)`,
+		`App\Page::render -> ? $o->$method()`,
+		`App\Page::render -> App\Base::boot (dynamic)`,
+		`App\Page::render -> ? call_user_func($_GET['cb'])`,
+		`App\Page::render -> ? $cb2()`,
+		`App\Page::render -> App\Util\fmt`,
+		` -> ? $h()`,
+		` -> App\Page::render`,
+	}, got, "native functions and methods of unknown objects are left out")
+	assert.Len(t, analyzer.calls.Unresolved(), 4)
+}
+
+func TestCallsCommand(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.php": "<?php\nfunction run() {}\n$action = 'run';\n$action();\ncall_user_func($_GET['action']);\n",
+	})
+	var out strings.Builder
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "calls", []string{dir}, &out))
+	index := filepath.Join(dir, "index.php")
+	assert.Equal(t, index+" -> run (dynamique)  "+index+":4\n"+
+		index+" -> ? call_user_func($_GET['action'])  "+index+":5\n"+
+		"2 appel(s), dont 1 appel(s) dynamique(s) non résolu(s)\n", out.String())
+
+	out.Reset()
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatJSON
+	assert.NoError(t, runCommand(analyzer, "calls", []string{"-unresolved", dir}, &out))
+	var calls []Call
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &calls))
+	assert.Equal(t, []Call{{File: index, Line: 5, Dynamic: true, Expression: "call_user_func($_GET['action'])"}}, calls)
+}
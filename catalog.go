@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// RuleInfo décrit une règle chargée, codée dans l'analyseur ou issue d'un fichier de
+// règles YAML, pour "rules list" et "rules describe".
+type RuleInfo struct {
+	ID          string
+	Category    string // détecteur qui produit la règle (cve, taint, crypto...)
+	CWE         string
+	Severity    Severity
+	Confidence  Confidence
+	Description string
+	PHP         string // versions de PHP affectées (signatures)
+	OWASP       string
+	References  []string
+	Remediation string
+	Example     RuleExample
+}
+
+// RuleCatalog retourne les règles chargées, regroupées par détecteur dans l'ordre
+// d'enregistrement : règles codées dans l'analyseur et signatures du jeu courant.
+func (pa *PHPAnalyzer) RuleCatalog() []RuleInfo {
+	var catalog []RuleInfo
+	for _, info := range RegisteredDetectors() {
+		if info.Name == "cve" {
+			for _, rule := range pa.Signatures.Rules() {
+				catalog = append(catalog, signatureRuleInfo(rule))
+			}
+			continue
+		}
+		for _, id := range info.Rules {
+			rule := builtinRules[id]
+			catalog = append(catalog, RuleInfo{
+				ID:          rule.ID,
+				Category:    info.Name,
+				CWE:         rule.CWE,
+				Severity:    rule.Severity,
+				Confidence:  rule.Confidence,
+				Description: rule.Description,
+				OWASP:       rule.OWASP,
+				References:  rule.References,
+				Remediation: rule.Remediation,
+				Example:     rule.Example,
+			})
+		}
+	}
+	return catalog
+}
+
+// signatureRuleInfo décrit une signature ; à défaut de description (renseignée par
+// "rules update"), le message des résultats en tient lieu.
+func signatureRuleInfo(rule *SignatureRule) RuleInfo {
+	description := rule.Description
+	if description == "" {
+		description = rule.Message
+	}
+	return RuleInfo{
+		ID:          rule.ID,
+		Category:    "cve",
+		CWE:         rule.CWE,
+		Severity:    rule.severity,
+		Confidence:  rule.confidence,
+		Description: description,
+		PHP:         rule.PHP,
+		OWASP:       rule.OWASP,
+		References:  rule.References,
+		Remediation: rule.Remediation,
+		Example:     rule.Example,
+	}
+}
+
+// DescribeRule retourne la règle d'identifiant id (insensible à la casse).
+func (pa *PHPAnalyzer) DescribeRule(id string) (RuleInfo, error) {
+	for _, rule := range pa.RuleCatalog() {
+		if strings.EqualFold(rule.ID, id) {
+			return rule, nil
+		}
+	}
+	return RuleInfo{}, fmt.Errorf("règle inconnue : %q", id)
+}
+
+// WriteRuleList affiche une règle par ligne : identifiant, catégorie, sévérité, CWE et
+// description, seule tronquée à la largeur d'affichage.
+func (pa *PHPAnalyzer) WriteRuleList(out io.Writer) {
+	for _, rule := range pa.RuleCatalog() {
+		columns := fmt.Sprintf("%-26s %-9s %-9s %-9s ", rule.ID, rule.Category, rule.Severity, orDash(rule.CWE))
+		width := pa.MaxWidth
+		if width > 0 {
+			width = max(width-utf8.RuneCountInString(columns), 1)
+		}
+		fmt.Fprintln(out, columns+fitLine(rule.Description, "", width))
+	}
+}
+
+// WriteRuleDescription affiche toutes les métadonnées d'une règle, puis ses exemples de
+// code vulnérable et corrigé, en retrait.
+func WriteRuleDescription(out io.Writer, rule RuleInfo) {
+	field := func(label, value string) {
+		fmt.Fprintf(out, "  %-12s : %s\n", label, value)
+	}
+	fmt.Fprintln(out, rule.ID)
+	field("Catégorie", rule.Category)
+	field("CWE", orDash(rule.CWE))
+	field("Sévérité", rule.Severity.String())
+	field("Confiance", rule.Confidence.String())
+	if rule.PHP != "" {
+		field("Versions PHP", rule.PHP)
+	}
+	field("OWASP", orDash(rule.OWASP))
+	field("Description", orDash(rule.Description))
+	field("Correction", orDash(rule.Remediation))
+	for _, reference := range rule.References {
+		field("Référence", reference)
+	}
+	writeExample(out, "Exemple vulnérable", rule.Example.Vulnerable)
+	writeExample(out, "Exemple corrigé", rule.Example.Fixed)
+}
+
+// writeExample affiche un extrait de code sous son titre, avec un retrait de 4 espaces.
+func writeExample(out io.Writer, title, code string) {
+	code = strings.TrimRight(code, "\n")
+	if code == "" {
+		return
+	}
+	fmt.Fprintf(out, "\n  %s :\n", title)
+	for _, line := range strings.Split(code, "\n") {
+		fmt.Fprintf(out, "    %s\n", line)
+	}
+}
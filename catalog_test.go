@@ -0,0 +1,79 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleCatalog(t *testing.T) {
+	analyzer := NewPHPAnalyzer()
+	catalog := analyzer.RuleCatalog()
+
+	ids := make(map[string]string)
+	for _, rule := range catalog {
+		ids[rule.ID] = rule.Category
+		assert.NotEmpty(t, rule.Description, rule.ID)
+	}
+	for id := range builtinRules {
+		assert.Contains(t, ids, id)
+	}
+	assert.Equal(t, "taint", ids["sqli"])
+	assert.Equal(t, "cve", ids["CVE-2019-9025"])
+	assert.Equal(t, "cve", ids["eval-user-input"])
+
+	var out strings.Builder
+	analyzer.WriteRuleList(&out)
+	assert.Len(t, strings.Split(strings.TrimSpace(out.String()), "\n"), len(catalog))
+	assert.Regexp(t, `(?m)^sqli +taint +critical +CWE-89 +Requête SQL`, out.String())
+}
+
+func TestDescribeRule(t *testing.T) {
+	analyzer := NewPHPAnalyzer()
+	rule, err := analyzer.DescribeRule("cve-2022-31626")
+	assert.NoError(t, err)
+	assert.Equal(t, "CVE-2022-31626", rule.ID)
+
+	var out strings.Builder
+	WriteRuleDescription(&out, rule)
+	assert.Contains(t, out.String(), "  Versions PHP : >=7.4, <7.4.30")
+	assert.Contains(t, out.String(), "  Exemple vulnérable :\n    $db = new PDO($dsn, $user, $_POST['password']);\n")
+	assert.Contains(t, out.String(), "  Exemple corrigé :\n    if (strlen($password) > 256) {\n")
+
+	rule, err = analyzer.DescribeRule("xxe")
+	assert.NoError(t, err)
+	out.Reset()
+	WriteRuleDescription(&out, rule)
+	assert.Contains(t, out.String(), "  Catégorie    : security\n")
+	assert.NotContains(t, out.String(), "Versions PHP")
+
+	_, err = analyzer.DescribeRule("unknown")
+	assert.Error(t, err)
+}
+
+func TestRulesListCommandLoadsCustomRules(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "custom.yaml"), []byte(`rules:
+  - id: custom-exec
+    cwe: CWE-78
+    severity: critical
+    confidence: high
+    functions: [exec]
+    message: exec() détecté
+    example:
+      vulnerable: exec($_GET['cmd']);
+      fixed: exec(escapeshellcmd($cmd));
+`), 0o644))
+	var out strings.Builder
+	assert.NoError(t, runRulesCommand(NewPHPAnalyzer(), []string{"list", "-rules", dir}, &out))
+	assert.Regexp(t, `(?m)^custom-exec +cve +critical +CWE-78 +exec\(\) détecté$`, out.String())
+
+	out.Reset()
+	assert.NoError(t, runRulesCommand(NewPHPAnalyzer(), []string{"describe", "-rules", dir, "custom-exec"}, &out))
+	assert.Contains(t, out.String(), "  Exemple corrigé :\n    exec(escapeshellcmd($cmd));\n")
+
+	assert.ErrorIs(t, runRulesCommand(NewPHPAnalyzer(), []string{"describe"}, &out), errUsage)
+}
@@ -2,26 +2,39 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strconv"
 	"strings"
 
 	sitter "github.com/smacker/go-tree-sitter"
-	php "github.com/smacker/go-tree-sitter/php"
+
+	"github/behouba/log6302A/pkg/phpfmt"
 )
 
 const Terminal = -1
 
+// maxCFGDepth limite l'imbrication des nœuds visités par le CFGBuilder, dont la visite
+// est récursive : au-delà, la construction du CFG échoue au lieu d'épuiser la pile.
+const maxCFGDepth = 5000
+
+// errTooDeep signale un fichier trop imbriqué pour construire son CFG.
+var errTooDeep = errors.New("imbrication trop profonde")
+
 type CFG struct {
 	Nodes map[int]*CFGNode
 	Edges map[int][]int
+
+	position sitter.Point // position du nœud de l'AST en cours de visite
 }
 
 type CFGNode struct {
-	ID   int
-	Type string
-	code string // info for debug
+	ID     int
+	Type   string
+	Line   uint32 // position dans le source du nœud de l'AST visité à sa création
+	Column uint32
+	code   string // info for debug
 }
 
 func NewCFG() *CFG {
@@ -33,9 +46,11 @@ func NewCFG() *CFG {
 
 func (cfg *CFG) AddNode(nodeType, codeSnippet string, id int) {
 	cfg.Nodes[id] = &CFGNode{
-		ID:   id,
-		Type: nodeType,
-		code: codeSnippet,
+		ID:     id,
+		Type:   nodeType,
+		Line:   cfg.position.Row + 1,
+		Column: cfg.position.Column + 1,
+		code:   codeSnippet,
 	}
 }
 
@@ -78,18 +93,17 @@ func (ds *depthStack) isEmpty() bool {
 }
 
 type CFGBuilder struct {
-	parser *sitter.Parser
 	cfg    *CFG
 	nextID int
 	source []byte
 	depth  *depthStack
+
+	level   int   // imbrication du nœud en cours de visite
+	tooDeep error // premier nœud dépassant maxCFGDepth
 }
 
 func NewCFGBuilder() *CFGBuilder {
-	p := sitter.NewParser()
-	p.SetLanguage(php.GetLanguage())
 	return &CFGBuilder{
-		parser: p,
 		cfg:    NewCFG(),
 		nextID: 1,
 		depth:  &depthStack{},
@@ -102,19 +116,24 @@ func (b *CFGBuilder) newID() int {
 	return id
 }
 
-func (b *CFGBuilder) BuildCFG(source []byte) (*CFG, error) {
+func (b *CFGBuilder) BuildCFG(ctx context.Context, source []byte) (*CFG, error) {
 	b.source = source
 
-	tree, err := b.parser.ParseCtx(context.Background(), nil, source)
+	tree, err := parsePHP(ctx, source)
 	if err != nil {
 		return nil, fmt.Errorf("parsing error: %w", err)
 	}
+	// Le CFG ne garde aucun nœud de l'arbre : sa mémoire est libérée dès sa construction.
+	defer tree.Close()
 	root := tree.RootNode()
 
 	entryID := b.newID()
 	b.cfg.AddNode(NodeEntry, NodeEntry, entryID)
 
 	lastNodeID := b.visit(root, entryID)
+	if b.tooDeep != nil {
+		return nil, b.tooDeep
+	}
 
 	exitID := b.newID()
 	b.cfg.AddNode(NodeExit, NodeExit, exitID)
@@ -133,6 +152,15 @@ func (b *CFGBuilder) visit(node *sitter.Node, parentID int) int {
 	if node == nil {
 		return parentID
 	}
+	if b.level >= maxCFGDepth {
+		if b.tooDeep == nil {
+			b.tooDeep = fmt.Errorf("%w : plus de %d niveaux à la ligne %d", errTooDeep, maxCFGDepth, node.StartPoint().Row+1)
+		}
+		return parentID
+	}
+	b.level++
+	defer func() { b.level-- }()
+	b.cfg.position = node.StartPoint()
 
 	switch node.Type() {
 
@@ -140,27 +168,15 @@ func (b *CFGBuilder) visit(node *sitter.Node, parentID int) int {
 		return b.addGenericNode(NodeHtml, node, parentID)
 
 	case "assignment_expression":
-		lNode := node.Child(int(node.ChildCount()) - 1)
-		lNodeID := b.visit(lNode, parentID)
+		nodes := children(node)
+		if len(nodes) < 2 {
+			return b.visitSequence(nodes, parentID)
+		}
+		lNodeID := b.visit(nodes[len(nodes)-1], parentID)
 		// Process first child.
-		rNode := node.Child(0)
-		rNodeID := b.visit(rNode, lNodeID)
+		rNodeID := b.visit(nodes[0], lNodeID)
 		// Process any remaining children.
-		seq := rNodeID
-		for i := 1; i < int(node.ChildCount())-1; i++ {
-			if seq == Terminal {
-				_ = b.visit(node.Child(i), Terminal)
-				continue
-			}
-			child := node.Child(i)
-			res := b.visit(child, seq)
-			if res == Terminal {
-				seq = Terminal
-			} else {
-				seq = res
-			}
-		}
-		return seq
+		return b.visitSequence(nodes[1:len(nodes)-1], rNodeID)
 
 	case "binary_expression":
 		leftOperand := node.Child(0)
@@ -217,17 +233,14 @@ func (b *CFGBuilder) visit(node *sitter.Node, parentID int) int {
 		return ifEndID
 
 	case "echo_statement":
-		echoID := b.newID()
-		b.cfg.AddNode(NodeEcho, "Echo", echoID)
-		if parentID != Terminal {
-			b.cfg.AddEdge(parentID, echoID)
-		}
-		argNode := node.Child(1)
-		if argNode != nil {
-			argID := b.visit(argNode, echoID)
-			return argID
+		return b.addEcho(node.Child(1), parentID)
+
+	case "expression_statement":
+		// <?= $x ?> echoes its expression.
+		if phpfmt.IsShortEcho(node, b.source) {
+			return b.addEcho(node.NamedChild(0), parentID)
 		}
-		return echoID
+		return b.visitChildren(node, parentID)
 
 	case "function_call_expression":
 		funcCallID := b.newID()
@@ -249,8 +262,7 @@ func (b *CFGBuilder) visit(node *sitter.Node, parentID int) int {
 			b.cfg.AddEdge(funcNameID, argsID)
 
 			seq := argsID
-			for i := 0; i < int(argumentsNode.ChildCount()); i++ {
-				argNode := argumentsNode.Child(i)
+			for _, argNode := range children(argumentsNode) {
 				if argNode.Type() != "(" && argNode.Type() != ")" {
 					argumentID := b.newID()
 					b.cfg.AddNode(NodeArgument, NodeArgument, argumentID)
@@ -330,22 +342,11 @@ func (b *CFGBuilder) visit(node *sitter.Node, parentID int) int {
 
 	case "compound_statement":
 		// Assume the first and last children are "{" and "}".
-		seq := parentID
-		for i := 1; i < int(node.ChildCount())-1; i++ {
-			child := node.Child(i)
-			// If we are already in dead code, process the child without linking.
-			if seq == Terminal {
-				_ = b.visit(child, Terminal)
-				continue
-			}
-			res := b.visit(child, seq)
-			if res == Terminal {
-				seq = Terminal
-			} else {
-				seq = res
-			}
+		nodes := children(node)
+		if len(nodes) < 2 {
+			return parentID
 		}
-		return seq
+		return b.visitSequence(nodes[1:len(nodes)-1], parentID)
 
 	case "name":
 		return b.addGenericNode(NodeId, node, parentID)
@@ -363,24 +364,40 @@ func (b *CFGBuilder) visit(node *sitter.Node, parentID int) int {
 		return b.addGenericNode(NodeStringLiteral, node, parentID)
 
 	default:
-		// Process children sequentially.
-		seq := parentID
-		for i := 0; i < int(node.ChildCount()); i++ {
-			if seq == Terminal {
-				// Already in dead code: process without linking.
-				_ = b.visit(node.Child(i), Terminal)
-				continue
-			}
-			res := b.visit(node.Child(i), seq)
-			if res == Terminal {
-				seq = Terminal
-			} else {
-				seq = res
-			}
+		return b.visitChildren(node, parentID)
+	}
+}
+
+// visitChildren processes the children of a node sequentially.
+func (b *CFGBuilder) visitChildren(node *sitter.Node, parentID int) int {
+	return b.visitSequence(children(node), parentID)
+}
+
+// visitSequence processes nodes sequentially, each one following the previous one.
+func (b *CFGBuilder) visitSequence(nodes []*sitter.Node, parentID int) int {
+	seq := parentID
+	for _, child := range nodes {
+		if seq == Terminal {
+			// Already in dead code: process without linking.
+			_ = b.visit(child, Terminal)
+			continue
 		}
-		return seq
+		seq = b.visit(child, seq)
+	}
+	return seq
+}
 
+// addEcho adds an Echo node followed by its argument.
+func (b *CFGBuilder) addEcho(argNode *sitter.Node, parentID int) int {
+	echoID := b.newID()
+	b.cfg.AddNode(NodeEcho, "Echo", echoID)
+	if parentID != Terminal {
+		b.cfg.AddEdge(parentID, echoID)
+	}
+	if argNode != nil {
+		return b.visit(argNode, echoID)
 	}
+	return echoID
 }
 
 // func (b *CFGBuilder) isInsideBreakOrContinue(parentID int) bool {
@@ -444,6 +461,7 @@ func (b *CFGBuilder) processCondition(node *sitter.Node, parentID int) int {
 
 func (b *CFGBuilder) addGenericNode(nodeType string, node *sitter.Node, parentID int) int {
 	strID := b.newID()
+	b.cfg.position = node.StartPoint()
 	b.cfg.AddNode(nodeType, node.Content(b.source), strID)
 	if parentID != Terminal {
 		b.cfg.AddEdge(parentID, strID)
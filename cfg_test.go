@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"strings"
 	"testing"
 
+	sitter "github.com/smacker/go-tree-sitter"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -18,7 +20,7 @@ func TestCFGBuilderOnIFStatement(t *testing.T) {
 
 	// Create a new CFG builder and generate the CFG
 	builder := NewCFGBuilder()
-	cfg, err := builder.BuildCFG([]byte(phpCode))
+	cfg, err := builder.BuildCFG(context.Background(), []byte(phpCode))
 
 	// Ensure no errors
 	assert.NoError(t, err, "CFG generation should not return an error")
@@ -53,7 +55,7 @@ mysql_query('SELECT *');`
 
 	// Create a new CFG builder and generate the CFG
 	builder := NewCFGBuilder()
-	cfg, err := builder.BuildCFG([]byte(phpCode))
+	cfg, err := builder.BuildCFG(context.Background(), []byte(phpCode))
 
 	// Ensure no errors
 	assert.NoError(t, err, "CFG generation should not return an error")
@@ -100,7 +102,7 @@ func TestCFGOnWhileLoop(t *testing.T) {
 	echo "Done";`
 
 	builder := NewCFGBuilder()
-	cfg, err := builder.BuildCFG([]byte(phpCode))
+	cfg, err := builder.BuildCFG(context.Background(), []byte(phpCode))
 	assert.NoError(t, err, "CFG generation should not return an error")
 
 	// Print CFG
@@ -262,7 +264,7 @@ func TestDetectDeadCode(t *testing.T) {
 	`)
 
 	builder := NewCFGBuilder()
-	cfg, err := builder.BuildCFG(source)
+	cfg, err := builder.BuildCFG(context.Background(), source)
 	if err != nil {
 		t.Fatalf("Failed to build CFG: %v", err)
 	}
@@ -291,3 +293,87 @@ func TestDetectDeadCode(t *testing.T) {
 		t.Errorf("Expected dead code chain (Echo and 'Dead') not fully detected; foundEcho=%v, foundDead=%v", foundEcho, foundDead)
 	}
 }
+
+func TestCFGShortEchoTag(t *testing.T) {
+	builder := NewCFGBuilder()
+	cfg, err := builder.BuildCFG(context.Background(), []byte("<p><?= $name ?></p>"))
+	assert.NoError(t, err)
+
+	var echoID int
+	for id, node := range cfg.Nodes {
+		if node.Type == NodeEcho {
+			echoID = id
+		}
+	}
+	if !assert.NotZero(t, echoID, "<?= should produce an Echo node") {
+		return
+	}
+	if assert.Len(t, cfg.Edges[echoID], 1) {
+		arg := cfg.Nodes[cfg.Edges[echoID][0]]
+		assert.Equal(t, NodeVariable, arg.Type)
+		assert.Equal(t, "$name", arg.code)
+	}
+}
+
+func TestDeeplyNestedCode(t *testing.T) {
+	depth := 2 * maxCFGDepth
+	source := []byte("<?php\n$x = " + strings.Repeat("(", depth) + "$y" + strings.Repeat(")", depth) + ";\necho $x;\n")
+
+	_, err := NewCFGBuilder().BuildCFG(context.Background(), source)
+	assert.ErrorIs(t, err, errTooDeep)
+	assert.ErrorContains(t, err, "ligne 2")
+
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), source)
+	if !assert.NoError(t, err) {
+		return
+	}
+	parentheses := 0
+	traverseAST(tree.RootNode(), func(node *sitter.Node) {
+		if node.Type() == "parenthesized_expression" {
+			parentheses++
+		}
+	})
+	assert.Equal(t, depth, parentheses)
+	assert.NotPanics(t, func() { analyzer.DetectVulnerabilities(context.Background(), tree, source) })
+}
+
+func TestTraverseASTOrder(t *testing.T) {
+	analyzer := NewPHPAnalyzer()
+	source := []byte("<?php f($a, $b);")
+	tree, err := analyzer.Parse(context.Background(), source)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var names []string
+	traverseAST(tree.RootNode(), func(node *sitter.Node) {
+		if node.Type() == "name" {
+			names = append(names, node.Content(source))
+		}
+	})
+	assert.Equal(t, []string{"f", "a", "b"}, names)
+}
+
+func TestChildren(t *testing.T) {
+	analyzer := NewPHPAnalyzer()
+	source := []byte("<?php f($a, $b);")
+	tree, err := analyzer.Parse(context.Background(), source)
+	if !assert.NoError(t, err) {
+		return
+	}
+	var args *sitter.Node
+	traverseAST(tree.RootNode(), func(node *sitter.Node) {
+		if node.Type() == "arguments" {
+			args = node
+		}
+	})
+	if !assert.NotNil(t, args) {
+		return
+	}
+	var types []string
+	for _, child := range children(args) {
+		types = append(types, child.Type())
+	}
+	assert.Equal(t, []string{"(", "argument", ",", "argument", ")"}, types)
+	assert.Empty(t, children(args.Child(0)))
+}
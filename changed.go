@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// lineRange est un intervalle de lignes modifiées d'un fichier (bornes incluses).
+type lineRange struct {
+	start, end uint32
+}
+
+// changeSet associe aux fichiers modifiés depuis une révision (chemins absolus) leurs
+// lignes ajoutées ou modifiées ; une liste nil désigne un fichier entièrement nouveau.
+type changeSet map[string][]lineRange
+
+// changedFlags regroupe les options qui restreignent une analyse aux modifications
+// apportées depuis une révision git.
+type changedFlags struct {
+	since string
+	lines bool
+	dir   string // dossier courant de la commande, renseigné par applyChanged
+}
+
+func addChangedFlags(fs *flag.FlagSet) *changedFlags {
+	flags := &changedFlags{}
+	fs.StringVar(&flags.since, "changed-since", "", "N'analyse que les fichiers modifiés depuis cette révision git (branche, tag ou commit)")
+	fs.BoolVar(&flags.lines, "changed-lines", false, "Avec -changed-since, ne signale que les résultats des lignes modifiées")
+	return flags
+}
+
+// defaultInputs retourne les chemins à analyser : avec -changed-since et sans autre
+// chemin, le dossier courant, dont seuls les fichiers modifiés seront retenus.
+func (c *changedFlags) defaultInputs(inputs []string, others ...string) []string {
+	if c.since == "" || len(inputs) > 0 {
+		return inputs
+	}
+	for _, path := range others {
+		if path != "" {
+			return inputs
+		}
+	}
+	return []string{c.dir}
+}
+
+// applyChanged demande à git les fichiers modifiés depuis la révision de -changed-since.
+func applyChanged(analyzer *PHPAnalyzer, flags *changedFlags) error {
+	analyzer.changed = nil
+	analyzer.changedLines = flags.lines
+	flags.dir = analyzer.abs(".")
+	if flags.since == "" {
+		if flags.lines {
+			return fmt.Errorf("option -changed-lines : -changed-since est requis")
+		}
+		return nil
+	}
+	changes, err := gitChanges(flags.dir, flags.since)
+	if err != nil {
+		return fmt.Errorf("option -changed-since : %v", err)
+	}
+	analyzer.changed = changes
+	return nil
+}
+
+// git exécute une commande git dans le dossier courant, ou celui de son option -C.
+func git(args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		// Le message nomme la sous-commande, après les options globales -C et -c.
+		command := args
+		for len(command) > 2 && (command[0] == "-C" || command[0] == "-c") {
+			command = command[2:]
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return nil, fmt.Errorf("git %s : %s", command[0], message)
+		}
+		return nil, fmt.Errorf("git %s : %v", command[0], err)
+	}
+	return out, nil
+}
+
+// gitChanges retourne les fichiers modifiés, dans la copie de travail de dir, depuis le point
+// de divergence entre ref et HEAD : seules les modifications de la branche sont
+// retenues, pas celles apportées à ref depuis. Les fichiers non suivis (hors
+// .gitignore) sont considérés comme entièrement nouveaux.
+func gitChanges(dir, ref string) (changeSet, error) {
+	top, err := git("-C", dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(top))
+	base, err := git("-C", root, "merge-base", ref, "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	diff, err := git("-C", root, "-c", "core.quotePath=false", "diff", "-U0", "--no-color", "--no-ext-diff", "--diff-filter=d", strings.TrimSpace(string(base)), "--")
+	if err != nil {
+		return nil, err
+	}
+	changes := parseDiffHunks(root, diff)
+	untracked, err := git("-C", root, "ls-files", "-z", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range strings.Split(string(untracked), "\x00") {
+		if name != "" {
+			changes[filepath.Join(root, filepath.FromSlash(name))] = nil
+		}
+	}
+	return changes, nil
+}
+
+// parseDiffHunks lit les fichiers et les lignes ajoutées d'un diff unifié sans contexte
+// (git diff -U0), dont les chemins sont relatifs à root.
+func parseDiffHunks(root string, diff []byte) changeSet {
+	changes := changeSet{}
+	file := ""
+	scanner := bufio.NewScanner(bytes.NewReader(diff))
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = ""
+			if name, ok := strings.CutPrefix(line, "+++ b/"); ok {
+				file = filepath.Join(root, filepath.FromSlash(name))
+				changes[file] = []lineRange{}
+			}
+		case strings.HasPrefix(line, "@@ ") && file != "":
+			// @@ -a,b +c,d @@ : d lignes à partir de la ligne c (1 si d est omis).
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				continue
+			}
+			start, count, _ := strings.Cut(strings.TrimPrefix(fields[2], "+"), ",")
+			first, err := strconv.ParseUint(start, 10, 32)
+			if err != nil {
+				continue
+			}
+			n := uint64(1)
+			if count != "" {
+				n, _ = strconv.ParseUint(count, 10, 32)
+			}
+			if n > 0 {
+				changes[file] = append(changes[file], lineRange{uint32(first), uint32(first + n - 1)})
+			}
+		}
+	}
+	return changes
+}
+
+// lookup retourne les lignes modifiées d'un fichier et s'il a été modifié.
+func (c changeSet) lookup(path string) ([]lineRange, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, false
+	}
+	ranges, ok := c[abs]
+	if !ok {
+		// git donne les chemins sans lien symbolique (/tmp sous macOS).
+		if resolved, err := filepath.EvalSymlinks(abs); err == nil {
+			ranges, ok = c[resolved]
+		}
+	}
+	return ranges, ok
+}
+
+// keepChangedFiles retire des fichiers d'un scan ceux qui n'ont pas été modifiés.
+func (pa *PHPAnalyzer) keepChangedFiles(files []string) []string {
+	if pa.changed == nil {
+		return files
+	}
+	var kept []string
+	for _, path := range files {
+		if _, ok := pa.changed.lookup(path); ok || path == stdinPath {
+			kept = append(kept, path)
+		}
+	}
+	return kept
+}
+
+// filterChanged retire les résultats d'un fichier non modifié et, avec -changed-lines,
+// ceux qui ne touchent aucune ligne modifiée.
+func (pa *PHPAnalyzer) filterChanged(path string, findings []Finding) []Finding {
+	if pa.changed == nil || path == stdinPath {
+		return findings
+	}
+	ranges, ok := pa.changed.lookup(path)
+	if !ok {
+		return nil
+	}
+	if !pa.changedLines || ranges == nil {
+		return findings
+	}
+	var kept []Finding
+	for _, f := range findings {
+		end := max(f.EndLine, f.Line)
+		for _, r := range ranges {
+			if f.Line <= r.end && end >= r.start {
+				kept = append(kept, f)
+				break
+			}
+		}
+	}
+	return kept
+}
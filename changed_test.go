@@ -0,0 +1,74 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDiffHunks(t *testing.T) {
+	diff := "diff --git a/src/a.php b/src/a.php\n" +
+		"--- a/src/a.php\n+++ b/src/a.php\n" +
+		"@@ -2,0 +3,2 @@\n+x\n+y\n" +
+		"@@ -10 +12 @@\n-z\n+w\n" +
+		"@@ -20,3 +21,0 @@\n-a\n-b\n-c\n" +
+		"diff --git a/b.php b/b.php\nnew file mode 100644\n--- /dev/null\n+++ b/b.php\n@@ -0,0 +1 @@\n+<?php\n"
+	changes := parseDiffHunks("/repo", []byte(diff))
+	assert.Equal(t, changeSet{
+		filepath.Join("/repo", "src", "a.php"): {{3, 4}, {12, 12}},
+		filepath.Join("/repo", "b.php"):        {{1, 1}},
+	}, changes)
+}
+
+func TestChangedSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git indisponible")
+	}
+	dir := t.TempDir()
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		output, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(output))
+	}
+	write := func(name, content string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+	run("init", "-q")
+	write("old.php", "<?php\nvar_dump($a);\n")
+	write("edited.php", "<?php\nvar_dump($a);\n\n\n")
+	run("add", ".")
+	run("commit", "-q", "-m", "initial")
+	run("branch", "base")
+	write("edited.php", "<?php\nvar_dump($a);\n\nvar_dump($b);\n")
+	write("new.php", "<?php\nvar_dump($c);\n")
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	// Résultats signalés pour edited.php.
+	edited := func(out string) string {
+		_, section, _ := strings.Cut(out, "Analyse du fichier : edited.php\n")
+		section, _, _ = strings.Cut(section, "\nAnalyse du fichier")
+		return section
+	}
+	var out strings.Builder
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "cve", []string{"--changed-since=base", "-min-severity=low"}, &out))
+	results, _, _ := strings.Cut(out.String(), "\nRésumé")
+	assert.NotContains(t, results, "old.php")
+	assert.Equal(t, 2, strings.Count(edited(results), "var_dump() oublié"))
+	assert.Contains(t, results, "new.php")
+
+	out.Reset()
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "cve", []string{"--changed-since=base", "--changed-lines", "-min-severity=low"}, &out))
+	results, _, _ = strings.Cut(out.String(), "\nRésumé")
+	assert.Equal(t, 1, strings.Count(edited(results), "var_dump() oublié"))
+	assert.Contains(t, edited(results), "(ligne 4,")
+
+	assert.ErrorContains(t, runCommand(NewPHPAnalyzer(), "cve", []string{"--changed-since=inconnue"}, &out), "git merge-base")
+}
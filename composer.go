@@ -0,0 +1,146 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// composerAutoload est le chargement automatique déclaré par le composer.json du projet
+// (sections autoload et autoload-dev) : correspondance PSR-4 des espaces de noms vers
+// des dossiers, et fichiers toujours chargés ("files").
+type composerAutoload struct {
+	prefixes []psr4Prefix // du préfixe le plus long au plus court
+	files    []string
+
+	mu      sync.Mutex
+	classes map[string]string // fichiers des classes déjà résolues (classFile)
+}
+
+// psr4Prefix associe un préfixe d'espace de noms (App\, ou "" pour tous) à ses dossiers.
+type psr4Prefix struct {
+	namespace string
+	dirs      []string
+}
+
+// composerSection est une section autoload de composer.json. Les dossiers d'un
+// préfixe PSR-4 sont une chaîne ou une liste.
+type composerSection struct {
+	PSR4  map[string]json.RawMessage `json:"psr-4"`
+	Files []string                   `json:"files"`
+}
+
+// findComposerJSON cherche composer.json dans le dossier dir puis dans ses parents et
+// retourne son chemin, "" s'il n'y en a pas.
+func findComposerJSON(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, "composer.json")
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// loadComposerAutoload lit les sections autoload et autoload-dev d'un composer.json ;
+// les dossiers et fichiers sont relatifs au dossier de composer.json.
+func loadComposerAutoload(path string) (*composerAutoload, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var manifest struct {
+		Autoload    composerSection `json:"autoload"`
+		AutoloadDev composerSection `json:"autoload-dev"`
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("%s : %v", path, err)
+	}
+	base := filepath.Dir(path)
+	autoload := &composerAutoload{classes: map[string]string{}}
+	byNamespace := map[string]int{}
+	for _, section := range []composerSection{manifest.Autoload, manifest.AutoloadDev} {
+		for namespace, raw := range section.PSR4 {
+			var dirs []string
+			if err := json.Unmarshal(raw, &dirs); err != nil {
+				var dir string
+				if err := json.Unmarshal(raw, &dir); err != nil {
+					return nil, fmt.Errorf("%s : dossiers du préfixe PSR-4 %q : %v", path, namespace, err)
+				}
+				dirs = []string{dir}
+			}
+			namespace = strings.Trim(namespace, `\`)
+			i, ok := byNamespace[namespace]
+			if !ok {
+				i = len(autoload.prefixes)
+				byNamespace[namespace] = i
+				autoload.prefixes = append(autoload.prefixes, psr4Prefix{namespace: namespace})
+			}
+			for _, dir := range dirs {
+				autoload.prefixes[i].dirs = append(autoload.prefixes[i].dirs, filepath.Join(base, filepath.FromSlash(dir)))
+			}
+		}
+		for _, file := range section.Files {
+			autoload.files = append(autoload.files, filepath.Join(base, filepath.FromSlash(file)))
+		}
+	}
+	sort.SliceStable(autoload.prefixes, func(i, j int) bool {
+		a, b := autoload.prefixes[i].namespace, autoload.prefixes[j].namespace
+		if len(a) != len(b) {
+			return len(a) > len(b)
+		}
+		return a < b
+	})
+	return autoload, nil
+}
+
+// classFile retourne le fichier qui définit une classe (nom complètement qualifié) selon
+// la correspondance PSR-4, "" si aucun préfixe ne la couvre ou si le fichier n'existe
+// pas. Comme avec Composer, les préfixes les plus longs sont essayés en premier et la
+// casse des noms compte.
+func (a *composerAutoload) classFile(class string) string {
+	if a == nil {
+		return ""
+	}
+	class = strings.TrimPrefix(class, `\`)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	file, ok := a.classes[class]
+	if !ok {
+		file = a.lookup(class)
+		a.classes[class] = file
+	}
+	return file
+}
+
+// lookup cherche le fichier d'une classe dans les dossiers de ses préfixes PSR-4.
+func (a *composerAutoload) lookup(class string) string {
+	for _, prefix := range a.prefixes {
+		rest := class
+		if prefix.namespace != "" {
+			if len(class) <= len(prefix.namespace) || class[:len(prefix.namespace)] != prefix.namespace || class[len(prefix.namespace)] != '\\' {
+				continue
+			}
+			rest = class[len(prefix.namespace)+1:]
+		}
+		for _, dir := range prefix.dirs {
+			path := filepath.Join(dir, filepath.FromSlash(strings.ReplaceAll(rest, `\`, "/"))+".php")
+			if info, err := os.Stat(path); err == nil && !info.IsDir() {
+				return path
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNameResolution(t *testing.T) {
+	source := []byte(`<?php
+namespace App\Http;
+use App\Service\Mailer;
+use App\Repo\{Users, Posts as PostRepository};
+use function App\helpers\h;
+use Vendor\Lib as L;
+
+$m = new Mailer();
+PostRepository::find(1);
+$x = \App\Other\Bar::X;
+$t = new L\Thing;
+$r = new Request();
+$c = new $class();
+static::boot();
+if ($e instanceof Users) {}
+`)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), source)
+	assert.NoError(t, err)
+	resolver := newNameResolver(tree.RootNode(), source)
+	assert.Equal(t, []useImport{
+		{Kind: SymbolClass, Name: `App\Service\Mailer`, Alias: "Mailer", Namespace: `App\Http`, Line: 3},
+		{Kind: SymbolClass, Name: `App\Repo\Users`, Alias: "Users", Namespace: `App\Http`, Line: 4},
+		{Kind: SymbolClass, Name: `App\Repo\Posts`, Alias: "PostRepository", Namespace: `App\Http`, Line: 4},
+		{Kind: SymbolFunction, Name: `App\helpers\h`, Alias: "h", Namespace: `App\Http`, Line: 5},
+		{Kind: SymbolClass, Name: `Vendor\Lib`, Alias: "L", Namespace: `App\Http`, Line: 6},
+	}, resolver.imports)
+
+	var names []string
+	for _, reference := range resolver.classReferences() {
+		names = append(names, reference.Name)
+	}
+	assert.Equal(t, []string{`App\Service\Mailer`, `App\Repo\Posts`, `App\Other\Bar`, `Vendor\Lib\Thing`, `App\Http\Request`, `App\Repo\Users`}, names,
+		"dynamic and relative class names are skipped")
+}
+
+func TestComposerAutoload(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"composer.json": `{
+  "autoload": {"psr-4": {"App\\": "src/", "App\\Legacy\\": ["legacy/", "lib/"]}, "files": ["src/helpers.php"]},
+  "autoload-dev": {"psr-4": {"Tests\\": "tests/"}}
+}`,
+		"src/Service/Mailer.php":    "<?php\nnamespace App\\Service;\nclass Mailer extends Transport { public static function send($to) {} }\n",
+		"src/Service/Transport.php": "<?php\nnamespace App\\Service;\nabstract class Transport {}\n",
+		"src/helpers.php":           "<?php\nfunction h($v) { return htmlspecialchars($v, ENT_QUOTES); }\n",
+		"lib/Db.php":                "<?php\nnamespace App\\Legacy;\nclass Db {}\n",
+		"web/index.php":             "<?php\nuse App\\Service\\Mailer;\nMailer::send($_GET['to']);\n$db = new \\App\\Legacy\\Db();\necho h($_GET['name']);\n",
+	})
+
+	autoload, err := loadComposerAutoload(filepath.Join(dir, "composer.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "lib", "Db.php"), autoload.classFile(`App\Legacy\Db`), "the longest prefix wins, its directories are tried in order")
+	assert.Equal(t, filepath.Join(dir, "src", "Service", "Mailer.php"), autoload.classFile(`\App\Service\Mailer`))
+	assert.Empty(t, autoload.classFile(`app\service\Mailer`), "autoloading is case-sensitive, like Composer's")
+	assert.Empty(t, autoload.classFile(`Tests\MissingTest`))
+	assert.Empty(t, autoload.classFile(`Other\Mailer`))
+	assert.Equal(t, filepath.Join(dir, "composer.json"), findComposerJSON(filepath.Join(dir, "web")))
+
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, analyzer.IndexProject(context.Background(), filepath.Join(dir, "web")))
+	for _, class := range []string{`App\Service\Mailer`, `App\Service\Transport`, `App\Legacy\Db`} {
+		_, ok := analyzer.symbols.ResolveClass(class)
+		assert.True(t, ok, class)
+	}
+	method := analyzer.symbols.Lookup(`App\Service\Mailer::send`)
+	assert.Len(t, method, 1)
+	assert.Equal(t, filepath.Join(dir, "src", "Service", "Mailer.php"), method[0].File)
+
+	// Le fichier toujours chargé fournit la fonction de nettoyage h().
+	var out strings.Builder
+	analyzer = NewPHPAnalyzer()
+	analyzer.ContextLines = -1
+	assert.NoError(t, runCommand(analyzer, "cve", []string{"-file", filepath.Join(dir, "web", "index.php"), "-fail-on", "critical"}, &out))
+	assert.NotContains(t, out.String(), "[xss")
+}
@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "time"
+
+// cpuTime n'est pas mesuré hors des systèmes Unix.
+func cpuTime() time.Duration {
+	return 0
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// cryptoArgument désigne un argument d'une fonction de chiffrement, par position ou par
+// nom (arguments nommés de PHP 8).
+type cryptoArgument struct {
+	Position int
+	Name     string
+}
+
+// nonceArguments associe les fonctions de chiffrement à leur argument IV/nonce.
+var nonceArguments = map[string]cryptoArgument{
+	"openssl_encrypt":                                   {Position: 4, Name: "iv"},
+	"sodium_crypto_secretbox":                           {Position: 1, Name: "nonce"},
+	"sodium_crypto_box":                                 {Position: 1, Name: "nonce"},
+	"sodium_crypto_stream_xor":                          {Position: 1, Name: "nonce"},
+	"sodium_crypto_stream_xchacha20_xor":                {Position: 1, Name: "nonce"},
+	"sodium_crypto_aead_aes256gcm_encrypt":              {Position: 2, Name: "nonce"},
+	"sodium_crypto_aead_chacha20poly1305_encrypt":       {Position: 2, Name: "nonce"},
+	"sodium_crypto_aead_chacha20poly1305_ietf_encrypt":  {Position: 2, Name: "nonce"},
+	"sodium_crypto_aead_xchacha20poly1305_ietf_encrypt": {Position: 2, Name: "nonce"},
+}
+
+// findArgument retourne la valeur de l'argument désigné, passé par nom ou par position.
+func findArgument(node *sitter.Node, want cryptoArgument, source []byte) *sitter.Node {
+	args := getArgumentNodes(node)
+	for _, arg := range args {
+		if nameNode := arg.ChildByFieldName("name"); nameNode != nil && strings.EqualFold(nameNode.Content(source), want.Name) {
+			return argumentValue(arg)
+		}
+	}
+	if want.Position < len(args) && args[want.Position].ChildByFieldName("name") == nil {
+		return argumentValue(args[want.Position])
+	}
+	return nil
+}
+
+// staticNonce vérifie si l'IV ou le nonce passé à une fonction de chiffrement est une
+// valeur fixe, et retourne alors sa nature ("chaîne littérale", "constante X" ou
+// "variable $x initialisée par un littéral"). Un IV fixe rend le chiffrement
+// déterministe et, pour les modes à flot (CTR, GCM, ChaCha20), permet de retrouver le
+// clair par XOR de deux messages.
+func staticNonce(node *sitter.Node, funcName string, source []byte) string {
+	want, ok := nonceArguments[strings.ToLower(funcName)]
+	if !ok || node.Type() != "function_call_expression" {
+		return ""
+	}
+	// Le mode ECB n'utilise pas d'IV : il est traité par la règle sur les chiffrements faibles.
+	if want.Name == "iv" {
+		if cipher := findArgument(node, cryptoArgument{Position: 1, Name: "cipher_algo"}, source); cipher != nil &&
+			strings.Contains(strings.ToLower(cipher.Content(source)), "ecb") {
+			return ""
+		}
+	}
+	value := findArgument(node, want, source)
+	if value == nil {
+		return ""
+	}
+	switch value.Type() {
+	case "name", "qualified_name", "class_constant_access_expression":
+		return "constante " + value.Content(source)
+	case "variable_name":
+		if isLiteralVariable(value, source) {
+			return "variable " + value.Content(source) + " initialisée par un littéral"
+		}
+		return ""
+	}
+	if isLiteral(value) {
+		return "chaîne littérale"
+	}
+	return ""
+}
+
+// isLiteralVariable vérifie que toutes les affectations d'une variable précédant son
+// utilisation dans la même fonction sont des littéraux.
+func isLiteralVariable(variable *sitter.Node, source []byte) bool {
+	name := variable.Content(source)
+	assigned, literal := false, true
+	traverseAST(enclosingScope(variable), func(n *sitter.Node) {
+		if n.Type() != "assignment_expression" && n.Type() != "augmented_assignment_expression" {
+			return
+		}
+		left := n.ChildByFieldName("left")
+		if left == nil || left.Content(source) != name || n.StartByte() > variable.StartByte() {
+			return
+		}
+		assigned = true
+		if right := n.ChildByFieldName("right"); right == nil || !isLiteral(right) {
+			literal = false
+		}
+	})
+	return assigned && literal
+}
+
+// weakCipherPattern reconnaît les algorithmes obsolètes (DES, 3DES, RC4) dans un nom de
+// chiffrement OpenSSL.
+var weakCipherPattern = regexp.MustCompile(`^(des|des3|des-ede3?|rc4)(-|$)`)
+
+// weakCipher retourne la raison pour laquelle un appel de chiffrement est faible, ou ""
+// : chiffrement OpenSSL obsolète ou en mode ECB, ou utilisation de l'extension mcrypt,
+// abandonnée depuis PHP 7.1 et retirée en 7.2.
+func weakCipher(node *sitter.Node, funcName string, source []byte) string {
+	if node.Type() != "function_call_expression" {
+		return ""
+	}
+	name := strings.ToLower(funcName)
+	if strings.HasPrefix(name, "mcrypt_") {
+		return "extension mcrypt obsolète"
+	}
+	if name != "openssl_encrypt" {
+		return ""
+	}
+	cipher := findArgument(node, cryptoArgument{Position: 1, Name: "cipher_algo"}, source)
+	if cipher == nil || !isLiteral(cipher) {
+		return ""
+	}
+	algo := strings.ToLower(strings.Trim(cipher.Content(source), `"'`))
+	switch {
+	case strings.HasSuffix(algo, "-ecb"):
+		return "mode ECB (" + algo + ")"
+	case weakCipherPattern.MatchString(algo):
+		return "algorithme obsolète (" + algo + ")"
+	}
+	return ""
+}
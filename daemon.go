@@ -0,0 +1,282 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// daemonRequest est envoyée par le client : la ligne de commande à exécuter, le
+// répertoire courant du client (pour résoudre les chemins relatifs) et la largeur
+// d'affichage de son terminal.
+type daemonRequest struct {
+	Args     []string `json:"args"`
+	Cwd      string   `json:"cwd"`
+	MaxWidth int      `json:"max_width"`
+	Context  int      `json:"context"`
+	Format   string   `json:"format,omitempty"`
+}
+
+// daemonResponse contient la sortie de la commande et son code de retour.
+type daemonResponse struct {
+	Output   string `json:"output"`
+	Error    string `json:"error,omitempty"`
+	ExitCode int    `json:"exit_code"`
+}
+
+// treeCacheSize borne le nombre d'arbres conservés par le cache du démon : au-delà, les
+// arbres les moins récemment utilisés sont fermés.
+const treeCacheSize = 5000
+
+// parsedFile est une entrée du cache d'arbres, invalidée dès que le fichier change.
+type parsedFile struct {
+	key     string
+	modTime time.Time
+	size    int64
+	tree    *sitter.Tree
+	content []byte
+	refs    int           // analyses en cours de l'arbre, qui ne peut être fermé avant leur fin
+	element *list.Element // position dans l'ordre d'utilisation, nil une fois retirée du cache
+}
+
+// treeCache conserve les arbres syntaxiques entre les requêtes du démon. Une entrée
+// évincée ou remplacée pendant qu'une analyse utilise son arbre est retirée du cache,
+// et son arbre fermé lorsque la dernière analyse le libère (release) ou à la fin de
+// la requête (sweep).
+type treeCache struct {
+	mu      sync.Mutex
+	limit   int
+	files   map[string]*parsedFile
+	recent  *list.List                   // entrées, de la plus récemment utilisée à la plus ancienne
+	entries map[*sitter.Tree]*parsedFile // entrées du cache et entrées retirées encore ouvertes
+}
+
+func newTreeCache(limit int) *treeCache {
+	return &treeCache{limit: limit, files: make(map[string]*parsedFile), recent: list.New(), entries: make(map[*sitter.Tree]*parsedFile)}
+}
+
+// parse retourne l'arbre en cache si le fichier n'a pas changé depuis le dernier
+// parsing, sinon parse le fichier avec pa et met le cache à jour. L'arbre retourné
+// reste ouvert jusqu'à son release.
+func (c *treeCache) parse(ctx context.Context, pa *PHPAnalyzer, filePath string) (*sitter.Tree, []byte, error) {
+	key, err := filepath.Abs(filePath)
+	if err != nil {
+		key = filePath
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c.mu.Lock()
+	if entry, ok := c.files[key]; ok && entry.modTime.Equal(info.ModTime()) && entry.size == info.Size() {
+		entry.refs++
+		c.recent.MoveToFront(entry.element)
+		c.mu.Unlock()
+		return entry.tree, entry.content, nil
+	}
+	c.mu.Unlock()
+
+	uncached := *pa
+	uncached.cache = nil
+	tree, content, err := uncached.ParseFile(ctx, filePath)
+	if err != nil {
+		return tree, content, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if previous, ok := c.files[key]; ok {
+		c.retire(previous)
+	}
+	entry := &parsedFile{key: key, modTime: info.ModTime(), size: info.Size(), tree: tree, content: content, refs: 1}
+	entry.element = c.recent.PushFront(entry)
+	c.files[key] = entry
+	c.entries[tree] = entry
+	for c.limit > 0 && c.recent.Len() > c.limit {
+		c.retire(c.recent.Back().Value.(*parsedFile))
+	}
+	return tree, content, nil
+}
+
+// retire sort une entrée du cache et ferme son arbre s'il n'est plus utilisé.
+func (c *treeCache) retire(entry *parsedFile) {
+	c.recent.Remove(entry.element)
+	entry.element = nil
+	delete(c.files, entry.key)
+	if entry.refs == 0 {
+		c.close(entry)
+	}
+}
+
+func (c *treeCache) close(entry *parsedFile) {
+	delete(c.entries, entry.tree)
+	entry.tree.Close()
+}
+
+// release signale la fin de l'analyse d'un arbre retourné par parse. Un arbre qui ne
+// vient pas du cache (entrée standard, parsing interrompu) est fermé.
+func (c *treeCache) release(tree *sitter.Tree) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[tree]
+	if !ok {
+		tree.Close()
+		return
+	}
+	if entry.refs > 0 {
+		entry.refs--
+	}
+	if entry.refs == 0 && entry.element == nil {
+		c.close(entry)
+	}
+}
+
+// sweep termine une requête : les arbres qu'elle n'a pas libérés sont considérés comme
+// libres et ceux retirés du cache entre-temps sont fermés.
+func (c *treeCache) sweep() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, entry := range c.entries {
+		entry.refs = 0
+		if entry.element == nil {
+			c.close(entry)
+		}
+	}
+}
+
+// defaultSocketPath retourne le socket Unix utilisé par défaut, propre à l'utilisateur.
+func defaultSocketPath() string {
+	return filepath.Join(os.TempDir(), fmt.Sprintf("php-analyzer-%d.sock", os.Getuid()))
+}
+
+// Daemon garde un analyseur et ses arbres parsés en mémoire et répond aux requêtes
+// des clients sur un socket Unix. Les requêtes sont traitées une à la fois, le parseur
+// n'étant pas réentrant ; leurs chemins relatifs sont résolus par rapport au dossier
+// courant du client, sans changer celui du démon.
+type Daemon struct {
+	mu       sync.Mutex
+	analyzer *PHPAnalyzer
+}
+
+// NewDaemon crée un démon avec un cache d'arbres vide.
+func NewDaemon() *Daemon {
+	analyzer := NewPHPAnalyzer()
+	analyzer.cache = newTreeCache(treeCacheSize)
+	return &Daemon{analyzer: analyzer}
+}
+
+// ServeDaemon écoute sur socketPath jusqu'à réception de SIGINT ou SIGTERM.
+func ServeDaemon(socketPath string) error {
+	// Un socket restant d'une exécution précédente empêcherait l'écoute.
+	if conn, err := net.Dial("unix", socketPath); err == nil {
+		conn.Close()
+		return fmt.Errorf("un démon écoute déjà sur %q", socketPath)
+	}
+	os.Remove(socketPath)
+
+	listener, err := net.Listen("unix", socketPath)
+	if err != nil {
+		return err
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		listener.Close()
+	}()
+
+	log.Printf("Démon php-analyzer à l'écoute sur %q", socketPath)
+	err = NewDaemon().Serve(listener)
+	os.Remove(socketPath)
+	return err
+}
+
+// Serve accepte les connexions jusqu'à la fermeture du listener.
+func (d *Daemon) Serve(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *Daemon) handle(conn net.Conn) {
+	defer conn.Close()
+	var req daemonRequest
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		log.Printf("Requête invalide: %v", err)
+		return
+	}
+	json.NewEncoder(conn).Encode(d.execute(req))
+}
+
+// execute lance la commande demandée avec l'analyseur partagé.
+func (d *Daemon) execute(req daemonRequest) daemonResponse {
+	if len(req.Args) == 0 {
+		return daemonResponse{Error: "commande manquante", ExitCode: exitError}
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	var out bytes.Buffer
+	d.analyzer.Dir = req.Cwd
+	d.analyzer.Profile = scanProfiles["default"]
+	d.analyzer.MaxWidth = req.MaxWidth
+	d.analyzer.ContextLines = req.Context
+	d.analyzer.Format = req.Format
+	if d.analyzer.Format == "" {
+		d.analyzer.Format = formatText
+	}
+	err := runCommand(d.analyzer, req.Args[0], req.Args[1:], &out)
+	d.analyzer.cache.sweep()
+	resp := daemonResponse{Output: out.String(), ExitCode: d.analyzer.exitCode(err)}
+	if err != nil {
+		if !errors.Is(err, errUsage) {
+			resp.Error = err.Error()
+		}
+	}
+	return resp
+}
+
+// RunViaDaemon transmet la commande au démon et recopie sa sortie sur out. Une erreur
+// est retournée si le démon est injoignable, pour permettre un repli sur l'analyse locale.
+func RunViaDaemon(socketPath string, args []string, maxWidth, contextLines int, format string, out io.Writer) (int, error) {
+	conn, err := net.DialTimeout("unix", socketPath, time.Second)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	cwd, _ := os.Getwd()
+	if err := json.NewEncoder(conn).Encode(daemonRequest{Args: args, Cwd: cwd, MaxWidth: maxWidth, Context: contextLines, Format: format}); err != nil {
+		return 0, err
+	}
+	var resp daemonResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return 0, err
+	}
+	io.WriteString(out, resp.Output)
+	if resp.Error != "" {
+		log.Print(resp.Error)
+	}
+	return resp.ExitCode, nil
+}
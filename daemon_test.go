@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDaemonAnswersRequests(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.php")
+	assert.NoError(t, os.WriteFile(file, []byte("<?php\nlibxml_disable_entity_loader(false);"), 0o644))
+
+	socketPath := filepath.Join(dir, "d.sock")
+	listener, err := net.Listen("unix", socketPath)
+	assert.NoError(t, err)
+	daemon := NewDaemon()
+	go daemon.Serve(listener)
+	defer listener.Close()
+
+	var out strings.Builder
+	exitCode, err := RunViaDaemon(socketPath, []string{"cve", "-file", file}, 0, 0, formatText, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, exitFindings, exitCode)
+	assert.Contains(t, out.String(), "libxml_disable_entity_loader(false) détecté (ligne 2, confiance high)")
+
+	// La seconde requête réutilise l'arbre en cache.
+	assert.Len(t, daemon.analyzer.cache.files, 1)
+	out.Reset()
+	_, err = RunViaDaemon(socketPath, []string{"cve", "-file", file}, 0, 0, formatText, &out)
+	assert.NoError(t, err)
+	assert.Contains(t, out.String(), "(ligne 2,")
+
+	out.Reset()
+	exitCode, err = RunViaDaemon(socketPath, []string{"cve"}, 0, 0, formatText, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, exitError, exitCode, "usage errors are reported through the exit code")
+	assert.Contains(t, out.String(), "Le flag -file ou un chemin est requis")
+}
+
+func TestDaemonResolvesPathsAgainstClientDirectory(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.php"), []byte("<?php\nlibxml_disable_entity_loader(false);"), 0o644))
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+
+	resp := NewDaemon().execute(daemonRequest{Args: []string{"cve", "-file", "a.php"}, Cwd: dir, Format: formatText})
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, exitFindings, resp.ExitCode)
+	assert.Contains(t, resp.Output, "(ligne 2,")
+
+	after, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.Equal(t, wd, after, "the daemon does not change its working directory")
+}
+
+func TestTreeCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.php", "b.php", "c.php"} {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(file, []byte("<?php\necho 1;"), 0o644))
+		files = append(files, file)
+	}
+	analyzer := NewPHPAnalyzer()
+	cache := newTreeCache(2)
+	analyzer.cache = cache
+	parse := func(file string) {
+		tree, _, err := analyzer.ParseFile(context.Background(), file)
+		assert.NoError(t, err)
+		analyzer.releaseTree(tree)
+	}
+	parse(files[0])
+	parse(files[1])
+	parse(files[0])
+	parse(files[2])
+	assert.Len(t, cache.files, 2)
+	assert.Contains(t, cache.files, files[0])
+	assert.NotContains(t, cache.files, files[1], "the least recently used tree is evicted")
+	assert.Len(t, cache.entries, 2, "the evicted tree is closed")
+
+	// Un arbre évincé pendant son analyse n'est fermé qu'une fois libéré.
+	tree, _, err := analyzer.ParseFile(context.Background(), files[0])
+	assert.NoError(t, err)
+	parse(files[1])
+	parse(files[2])
+	assert.NotContains(t, cache.files, files[0])
+	assert.Len(t, cache.entries, 3)
+	assert.Equal(t, "program", tree.RootNode().Type())
+	analyzer.releaseTree(tree)
+	assert.Len(t, cache.entries, 2)
+}
+
+func TestScanDBFileReleasesTree(t *testing.T) {
+	dir := t.TempDir()
+	analyzer := NewPHPAnalyzer()
+	cache := newTreeCache(1)
+	analyzer.cache = cache
+	for _, name := range []string{"a.php", "b.php"} {
+		file := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(file, []byte("<?php\nfunction f() { mysqli_query($db, \"SELECT 1\"); }"), 0o644))
+		scanned, err := analyzer.scanDBFile(context.Background(), dir, file)
+		assert.NoError(t, err)
+		assert.Len(t, scanned.findings, 1)
+		assert.Equal(t, "f", functionAt(scanned.functions, scanned.findings[0].Line))
+	}
+	// L'arbre de a.php, évincé par b.php, est fermé : l'analyse l'a déjà libéré.
+	assert.Len(t, cache.entries, 1)
+}
+
+func TestRunViaDaemonUnavailable(t *testing.T) {
+	_, err := RunViaDaemon(filepath.Join(t.TempDir(), "missing.sock"), []string{"count"}, 0, 0, formatText, &strings.Builder{})
+	assert.Error(t, err)
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// EntryPoints désigne le code appelé de l'extérieur du projet (serveur web, routeur,
+// framework), à partir duquel les fonctions et méthodes atteignables sont cherchées.
+type EntryPoints struct {
+	// Files sont des motifs de fichiers dont le code hors fonction est exécuté : un motif
+	// sans "/" s'applique au nom du fichier (index.php), les autres à la fin de son
+	// chemin (routes/*.php, public/**).
+	Files []string `yaml:"files"`
+	// Functions sont des motifs de fonctions et de méthodes appelées de l'extérieur,
+	// comme ceux de symbols -name (App\Http\Controllers\*::*, *::handle).
+	Functions []string `yaml:"functions"`
+}
+
+// defaultEntryFiles sont les fichiers d'entrée usuels : contrôleur frontal et fichiers
+// de routes. Les méthodes publiques des classes *Controller sont aussi des points
+// d'entrée par défaut.
+var defaultEntryFiles = []string{"index.php", "routes/**"}
+
+// magicMethods sont appelées implicitement par PHP.
+var magicMethods = map[string]bool{
+	"__construct": true, "__destruct": true, "__call": true, "__callstatic": true,
+	"__get": true, "__set": true, "__isset": true, "__unset": true, "__sleep": true,
+	"__wakeup": true, "__serialize": true, "__unserialize": true, "__tostring": true,
+	"__invoke": true, "__set_state": true, "__clone": true, "__debuginfo": true,
+}
+
+// DeadFunctionOptions règle la recherche des fonctions inatteignables.
+type DeadFunctionOptions struct {
+	Entries EntryPoints
+	// Conservative écarte des résultats les fonctions et méthodes référencées
+	// dynamiquement : cible d'un appel dynamique résolu, ou nom cité par une chaîne.
+	Conservative bool
+}
+
+// DeadFunctions retourne les fonctions et méthodes du projet indexé (IndexProject) qui
+// ne sont atteignables par le graphe d'appels depuis aucun point d'entrée. Les fichiers
+// inclus par un fichier d'entrée en sont aussi. Lorsqu'une méthode est atteinte, ses
+// redéfinitions dans les classes filles le sont également ; une méthode appelée sur un
+// objet de classe inconnue atteint toutes les méthodes de ce nom. Les méthodes
+// magiques, abstraites et d'interfaces ne sont jamais signalées.
+func (pa *PHPAnalyzer) DeadFunctions(options DeadFunctionOptions) []Symbol {
+	if pa.symbols == nil || pa.calls == nil {
+		return nil
+	}
+	var candidates []Symbol
+	byName := map[string][]Symbol{} // méthodes par nom court en minuscules
+	for _, symbol := range pa.symbols.Symbols {
+		switch symbol.Kind {
+		case SymbolFunction:
+			candidates = append(candidates, symbol)
+		case SymbolMethod:
+			candidates = append(candidates, symbol)
+			name := strings.ToLower(symbol.Name[strings.LastIndex(symbol.Name, "::")+2:])
+			byName[name] = append(byName[name], symbol)
+		}
+	}
+
+	// Arcs du graphe : appels résolus et appels de méthodes par leur seul nom, par
+	// appelant (nom en minuscules, ou fichier pour le code hors fonction).
+	node := func(caller, file string) string {
+		if caller == "" {
+			return "\x00" + filepath.Clean(file)
+		}
+		return strings.ToLower(caller)
+	}
+	callees := map[string][]string{}
+	for _, call := range pa.calls.Calls {
+		if call.Resolved() {
+			caller := node(call.Caller, call.File)
+			callees[caller] = append(callees[caller], strings.ToLower(call.Callee))
+		}
+	}
+	for _, call := range pa.calls.untyped {
+		caller := node(call.Caller, call.File)
+		for _, method := range byName[call.Callee] {
+			callees[caller] = append(callees[caller], strings.ToLower(method.Name))
+		}
+	}
+
+	reached := map[string]bool{}
+	var pending []string
+	reach := func(name string) {
+		if !reached[name] {
+			reached[name] = true
+			pending = append(pending, name)
+		}
+	}
+	entryFiles := append(slices.Clone(defaultEntryFiles), options.Entries.Files...)
+	var matchers []func(Symbol) bool
+	for _, pattern := range options.Entries.Functions {
+		matchers = append(matchers, symbolMatcher(pattern))
+	}
+	files := map[string]bool{}
+	for _, symbol := range pa.symbols.Symbols {
+		files[filepath.Clean(symbol.File)] = true
+	}
+	for file := range pa.symbols.includes {
+		files[file] = true
+	}
+	for file := range files {
+		if isEntryFile(file, entryFiles) {
+			reach(node("", file))
+			for _, included := range pa.symbols.Includes(file) {
+				reach(node("", included))
+			}
+		}
+	}
+	for _, symbol := range candidates {
+		if pa.isEntryFunction(symbol, matchers) {
+			reach(strings.ToLower(symbol.Name))
+		}
+	}
+	for len(pending) > 0 {
+		name := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		for _, callee := range callees[name] {
+			reach(callee)
+		}
+		// Redéfinitions de la méthode atteinte dans les classes filles.
+		if class, method, ok := strings.Cut(name, "::"); ok {
+			for _, override := range byName[method] {
+				overrideClass := strings.ToLower(override.Name[:strings.LastIndex(override.Name, "::")])
+				if overrideClass != class && pa.classes.inherits(overrideClass, class) {
+					reach(strings.ToLower(override.Name))
+				}
+			}
+		}
+	}
+
+	dynamic := map[string]bool{}
+	if options.Conservative {
+		for _, call := range pa.calls.Calls {
+			if call.Dynamic && call.Resolved() {
+				dynamic[strings.ToLower(call.Callee)] = true
+			}
+		}
+	}
+	var dead []Symbol
+	for _, symbol := range candidates {
+		name := strings.ToLower(symbol.Name)
+		short := name[max(strings.LastIndex(name, `\`), strings.LastIndex(name, ":"))+1:]
+		switch {
+		case reached[name], pa.isImplicitMethod(symbol, short):
+		case options.Conservative && (dynamic[name] || pa.calls.names[short]):
+		default:
+			dead = append(dead, symbol)
+		}
+	}
+	return dead
+}
+
+// isEntryFile vérifie si un fichier correspond à l'un des motifs de fichiers d'entrée.
+func isEntryFile(file string, patterns []string) bool {
+	parts := strings.Split(filepath.ToSlash(file), "/")
+	for _, pattern := range patterns {
+		pattern = strings.Trim(pattern, "/")
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := filepath.Match(pattern, parts[len(parts)-1]); ok {
+				return true
+			}
+			continue
+		}
+		if matchSegments(append([]string{"**"}, strings.Split(pattern, "/")...), parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// isEntryFunction vérifie si une fonction ou une méthode est un point d'entrée : motif
+// de la configuration, ou méthode publique d'une classe *Controller.
+func (pa *PHPAnalyzer) isEntryFunction(symbol Symbol, matchers []func(Symbol) bool) bool {
+	for _, match := range matchers {
+		if match(symbol) {
+			return true
+		}
+	}
+	if symbol.Kind != SymbolMethod {
+		return false
+	}
+	class := strings.ToLower(symbol.Name[:strings.LastIndex(symbol.Name, "::")])
+	modifiers := strings.Fields(symbol.Signature)
+	return strings.HasSuffix(class, "controller") && !slices.Contains(modifiers, "private") && !slices.Contains(modifiers, "protected")
+}
+
+// isImplicitMethod vérifie si une méthode n'est pas appelée explicitement par le code :
+// méthode magique, abstraite ou d'une interface.
+func (pa *PHPAnalyzer) isImplicitMethod(symbol Symbol, short string) bool {
+	if symbol.Kind != SymbolMethod {
+		return false
+	}
+	if magicMethods[short] || slices.Contains(strings.Fields(symbol.Signature), "abstract") {
+		return true
+	}
+	for _, class := range pa.symbols.Lookup(symbol.Name[:strings.LastIndex(symbol.Name, "::")]) {
+		if class.Kind == SymbolInterface {
+			return true
+		}
+	}
+	return false
+}
+
+// inherits vérifie si la classe class hérite de ancestor, par ses classes parentes ou
+// ses traits.
+func (idx classIndex) inherits(class, ancestor string) bool {
+	seen := map[string]bool{}
+	var visit func(name string) bool
+	visit = func(name string) bool {
+		definition, ok := idx[strings.ToLower(name)]
+		if !ok || seen[strings.ToLower(name)] {
+			return false
+		}
+		seen[strings.ToLower(name)] = true
+		for _, parent := range append(slices.Clone(definition.Traits), definition.Parent) {
+			if parent != "" && (strings.EqualFold(parent, ancestor) || visit(parent)) {
+				return true
+			}
+		}
+		return false
+	}
+	return visit(class)
+}
+
+// runDeadFunctionsCommand exécute la commande dead-functions : les fonctions et méthodes
+// des chemins donnés (le dossier courant par défaut) qu'aucun point d'entrée n'atteint.
+func runDeadFunctionsCommand(ctx context.Context, analyzer *PHPAnalyzer, args []string, out io.Writer) error {
+	cmd := newFlagSet("dead-functions", out)
+	dirPath := cmd.String("dir", "", "Chemin vers le dossier à indexer récursivement")
+	configPath := cmd.String("config", defaultConfigPath, "Fichier de configuration du projet (section entry-points)")
+	var entryFiles, entryFunctions listFlag
+	cmd.Var(&entryFiles, "entry-files", fmt.Sprintf("Motifs des fichiers d'entrée, en plus de %s", strings.Join(defaultEntryFiles, ", ")))
+	cmd.Var(&entryFunctions, "entry-functions", `Motifs des fonctions et méthodes d'entrée (App\Http\*::*, *::handle)`)
+	conservative := cmd.Bool("conservative", false, "Ne signale pas les fonctions et méthodes référencées dynamiquement (appel dynamique, nom cité par une chaîne)")
+	excludes := addExcludeFlags(cmd)
+	inputs, err := analyzer.parseCommandLine(cmd, args)
+	if err != nil {
+		return err
+	}
+	if err := applyExcludes(analyzer, excludes); err != nil {
+		return err
+	}
+	config, err := LoadProjectConfig(*configPath)
+	if err != nil {
+		return fmt.Errorf("Erreur lors de la lecture de la configuration %q: %v", *configPath, err)
+	}
+	options := DeadFunctionOptions{Entries: config.EntryPoints, Conservative: *conservative}
+	options.Entries.Files = append(options.Entries.Files, entryFiles...)
+	options.Entries.Functions = append(options.Entries.Functions, entryFunctions...)
+	paths := scanPaths(*dirPath, inputs)
+	if len(paths) == 0 {
+		paths = []string{analyzer.abs(".")}
+	}
+	if err := analyzer.IndexProject(ctx, paths...); err != nil {
+		return err
+	}
+	dead := analyzer.DeadFunctions(options)
+	if analyzer.Format == formatJSON {
+		if dead == nil {
+			dead = []Symbol{}
+		}
+		return writeIndentedJSON(out, dead)
+	}
+	for _, s := range dead {
+		fmt.Fprintf(out, "%-9s %s  %s:%d  %s\n", s.Kind, s.Name, s.File, s.Line, s.Signature)
+	}
+	fmt.Fprintf(out, "%d fonction(s) ou méthode(s) inatteignable(s)\n", len(dead))
+	if unresolved := len(analyzer.calls.Unresolved()); unresolved > 0 && !*conservative {
+		fmt.Fprintf(out, "%d appel(s) dynamique(s) non résolu(s) peuvent en atteindre (calls -unresolved) ; -conservative écarte celles référencées dynamiquement.\n", unresolved)
+	}
+	return nil
+}
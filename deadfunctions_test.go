@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadFunctions(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"index.php":    "<?php\nrequire __DIR__ . '/lib/boot.php';\nused();\n$page = new Page();\n$page->render();\narray_map('by_string', []);\n$cb = 'dynamic';\n$cb();\n",
+		"lib/boot.php": "<?php\nboot_helper();\n",
+		"lib/lib.php": `<?php
+function used() { helper(); }
+function helper() {}
+function unused() { unused_helper(); }
+function unused_helper() {}
+function by_string() {}
+function dynamic() {}
+function boot_helper() {}
+function cron_job() {}
+interface Renderable { public function render(); }
+abstract class Base implements Renderable {
+    abstract protected function title();
+    public function render() { return $this->title(); }
+    public function orphan() {}
+}
+class Page extends Base {
+    public function __construct() {}
+    protected function title() { return $this->format(); }
+    private function format() {}
+}
+class Mailer { public function send() {} }
+class HomeController {
+    public function index($mailer) { $mailer->send(); }
+    private function unusedPrivate() {}
+}
+`,
+	})
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, analyzer.IndexProject(context.Background(), dir))
+	names := func(symbols []Symbol) []string {
+		var result []string
+		for _, s := range symbols {
+			result = append(result, s.Name)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"unused", "unused_helper", "by_string", "cron_job", "Base::orphan", "HomeController::unusedPrivate"},
+		names(analyzer.DeadFunctions(DeadFunctionOptions{})),
+		"overrides of reached methods, methods called on unknown objects and included files are reachable")
+	assert.Equal(t, []string{"unused", "unused_helper", "Base::orphan", "HomeController::unusedPrivate"},
+		names(analyzer.DeadFunctions(DeadFunctionOptions{Conservative: true, Entries: EntryPoints{Functions: []string{"cron_*"}}})),
+		"the conservative mode leaves out functions named by strings")
+}
+
+func TestDeadFunctionsCommand(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"public/app.php": "<?php\nstart();\n",
+		"src/app.php":    "<?php\nfunction start() {}\nfunction legacy() {}\nfunction hook() {}\n",
+		"config.yaml":    "entry-points:\n  files: [public/*.php]\n",
+	})
+	var out strings.Builder
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "dead-functions", []string{"-config", filepath.Join(dir, "config.yaml"), "-entry-functions", "hook", dir}, &out))
+	assert.Equal(t, "function  legacy  "+filepath.Join(dir, "src", "app.php")+":3  function legacy()\n1 fonction(s) ou méthode(s) inatteignable(s)\n", out.String())
+
+	out.Reset()
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatJSON
+	assert.NoError(t, runCommand(analyzer, "dead-functions", []string{"-config", filepath.Join(dir, "missing.yaml"), dir}, &out))
+	var dead []Symbol
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &dead))
+	assert.Len(t, dead, 3, "without entry points, every function is unreachable")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "bad.yaml"), []byte("entry-points: [\n"), 0o644))
+	assert.Error(t, runCommand(NewPHPAnalyzer(), "dead-functions", []string{"-config", filepath.Join(dir, "bad.yaml"), dir}, &out))
+}
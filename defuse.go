@@ -0,0 +1,185 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// superglobals sont accessibles dans toutes les portées : une affectation n'y est jamais
+// locale.
+var superglobals = map[string]bool{
+	"$GLOBALS": true, "$_SERVER": true, "$_GET": true, "$_POST": true, "$_FILES": true,
+	"$_COOKIE": true, "$_SESSION": true, "$_REQUEST": true, "$_ENV": true,
+}
+
+// defUse relève les définitions et les utilisations des variables d'une portée :
+// fonction, méthode ou fonction anonyme. Les fonctions fléchées lisent les variables de
+// la portée qui les contient et en font partie ; les fonctions anonymes n'y lisent que
+// les variables de leur clause use.
+type defUse struct {
+	defs  map[string][]*sitter.Node // affectations (=, op=, list()) de chaque variable, dans l'ordre du code
+	uses  map[string]int            // lectures de chaque variable
+	bound map[string]bool           // variables liées à l'extérieur de la portée : paramètre ou capture par référence, global, static, référence
+	// opaque indique que la portée lit des variables sans les nommer ($$name,
+	// compact($names), get_defined_vars(), eval, include) : toutes peuvent être lues.
+	opaque bool
+}
+
+// collectDefUse relève les définitions et les utilisations des variables de la portée
+// scope (function_definition, method_declaration, anonymous_function_creation_expression
+// ou arrow_function).
+func collectDefUse(scope *sitter.Node, source []byte) defUse {
+	du := defUse{defs: map[string][]*sitter.Node{}, uses: map[string]int{}, bound: map[string]bool{}}
+	if parameters := scope.ChildByFieldName("parameters"); parameters != nil {
+		for i := 0; i < int(parameters.NamedChildCount()); i++ {
+			parameter := parameters.NamedChild(i)
+			name := parameter.ChildByFieldName("name")
+			if name != nil && parameter.ChildByFieldName("reference_modifier") != nil {
+				du.bound[name.Content(source)] = true
+			}
+		}
+	}
+	if scope.Type() == "anonymous_function_creation_expression" {
+		for i := 0; i < int(scope.NamedChildCount()); i++ {
+			if clause := scope.NamedChild(i); clause.Type() == "anonymous_function_use_clause" {
+				du.bindAll(clause, source) // les captures par valeur sont lues par la portée
+			}
+		}
+	}
+	if body := scope.ChildByFieldName("body"); body != nil {
+		du.walk(body, source)
+	}
+	return du
+}
+
+// walk relève les définitions et les utilisations d'un nœud de la portée.
+func (du *defUse) walk(n *sitter.Node, source []byte) {
+	switch n.Type() {
+	case "function_definition", "class_declaration", "interface_declaration", "trait_declaration",
+		"enum_declaration", "anonymous_class":
+		return // portées distinctes
+	case "anonymous_function_creation_expression":
+		// Seules les variables de la clause use sont lues par la fonction anonyme.
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			clause := n.NamedChild(i)
+			if clause.Type() != "anonymous_function_use_clause" {
+				continue
+			}
+			for j := 0; j < int(clause.NamedChildCount()); j++ {
+				switch captured := clause.NamedChild(j); captured.Type() {
+				case "variable_name":
+					du.uses[captured.Content(source)]++
+				case "by_ref":
+					du.bindAll(captured, source)
+				}
+			}
+		}
+		return
+	case "arrow_function":
+		if body := n.ChildByFieldName("body"); body != nil {
+			du.walk(body, source)
+		}
+		return
+	case "assignment_expression", "augmented_assignment_expression":
+		left, right := n.ChildByFieldName("left"), n.ChildByFieldName("right")
+		if left != nil {
+			switch left.Type() {
+			case "variable_name":
+				du.defs[left.Content(source)] = append(du.defs[left.Content(source)], n)
+			case "list_literal":
+				du.walkList(left, n, source)
+			default:
+				du.walk(left, source)
+			}
+		}
+		if right != nil {
+			du.walk(right, source)
+		}
+		return
+	case "reference_assignment_expression", "by_ref", "global_declaration", "function_static_declaration":
+		du.bindAll(n, source)
+		return
+	case "variable_name":
+		du.uses[n.Content(source)]++
+		return
+	case "dynamic_variable_name", "include_expression", "include_once_expression", "require_expression", "require_once_expression":
+		du.opaque = true
+	case "function_call_expression":
+		du.walkCall(n, source)
+	}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		du.walk(n.NamedChild(i), source)
+	}
+}
+
+// walkList relève les variables affectées par une déstructuration ([$a, $b] = ... ou
+// list($a, 'k' => $b) = ...) ; les clés et les autres expressions sont des lectures.
+func (du *defUse) walkList(list, assignment *sitter.Node, source []byte) {
+	for i := 0; i < int(list.NamedChildCount()); i++ {
+		element := list.NamedChild(i)
+		switch element.Type() {
+		case "variable_name":
+			du.defs[element.Content(source)] = append(du.defs[element.Content(source)], assignment)
+		case "list_literal":
+			du.walkList(element, assignment, source)
+		default:
+			du.walk(element, source)
+		}
+	}
+}
+
+// walkCall relève les variables lues par leur nom : compact('a', 'b'). Les fonctions qui
+// lisent des variables sans les nommer rendent la portée opaque.
+func (du *defUse) walkCall(call *sitter.Node, source []byte) {
+	function := call.ChildByFieldName("function")
+	if function == nil {
+		return
+	}
+	switch strings.ToLower(strings.TrimPrefix(function.Content(source), `\`)) {
+	case "compact":
+		for _, arg := range getArgumentNodes(call) {
+			value := argumentValue(arg)
+			name, ok := "", false
+			if value != nil {
+				name, ok = literalString(value, source)
+			}
+			if !ok {
+				du.opaque = true
+				continue
+			}
+			du.uses["$"+name]++
+		}
+	case "get_defined_vars", "eval", "func_get_args":
+		du.opaque = true
+	}
+}
+
+// bindAll marque les variables d'un nœud comme liées à l'extérieur de la portée.
+func (du *defUse) bindAll(n *sitter.Node, source []byte) {
+	traverseAST(n, func(v *sitter.Node) {
+		if v.Type() == "variable_name" {
+			du.bound[v.Content(source)] = true
+			du.uses[v.Content(source)]++
+		}
+	})
+}
+
+// unused retourne les variables affectées mais jamais lues, dans l'ordre de leur première
+// affectation, hors superglobales, $this et variables liées à l'extérieur de la portée.
+func (du defUse) unused() []string {
+	if du.opaque {
+		return nil
+	}
+	var names []string
+	for name, defs := range du.defs {
+		if du.uses[name] == 0 && !du.bound[name] && !superglobals[name] && name != "$this" && len(defs) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return du.defs[names[i]][0].StartByte() < du.defs[names[j]][0].StartByte()
+	})
+	return names
+}
@@ -0,0 +1,418 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Detector est une analyse branchable exécutée sur chaque fichier. cfg n'est fourni
+// qu'aux détecteurs qui le déclarent nécessaire (DetectorInfo.NeedsCFG), nil sinon.
+type Detector interface {
+	Name() string
+	Run(tree *sitter.Tree, source []byte, cfg *CFG) []Finding
+}
+
+// DetectorInfo décrit un détecteur enregistré.
+type DetectorInfo struct {
+	Name        string
+	Description string
+	Enabled     bool // activé par défaut
+	NeedsCFG    bool
+	Rules       []string // règles produites, hors signatures des fichiers de règles
+	New         func(pa *PHPAnalyzer) Detector
+}
+
+// detectorRegistry contient les détecteurs disponibles, indexés par nom.
+var detectorRegistry = make(map[string]DetectorInfo)
+
+// RegisterDetector ajoute un détecteur au registre. Un nom déjà enregistré est une
+// erreur de programmation.
+func RegisterDetector(info DetectorInfo) {
+	if _, exists := detectorRegistry[info.Name]; exists {
+		panic(fmt.Sprintf("détecteur %q déjà enregistré", info.Name))
+	}
+	detectorRegistry[info.Name] = info
+}
+
+// RegisteredDetectors retourne les détecteurs enregistrés, triés par nom.
+func RegisteredDetectors() []DetectorInfo {
+	var infos []DetectorInfo
+	for _, info := range detectorRegistry {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// SetDetectorEnabled active ou désactive un détecteur pour cet analyseur.
+func (pa *PHPAnalyzer) SetDetectorEnabled(name string, enabled bool) error {
+	if _, ok := detectorRegistry[name]; !ok {
+		return fmt.Errorf("détecteur inconnu : %q", name)
+	}
+	if pa.detectors == nil {
+		pa.detectors = make(map[string]bool)
+	}
+	pa.detectors[name] = enabled
+	return nil
+}
+
+// DetectorEnabled indique si un détecteur est exécuté par cet analyseur.
+func (pa *PHPAnalyzer) DetectorEnabled(info DetectorInfo) bool {
+	if enabled, ok := pa.detectors[info.Name]; ok {
+		return enabled
+	}
+	return info.Enabled
+}
+
+// WriteDetectorList affiche les détecteurs enregistrés et leur état par défaut.
+func WriteDetectorList(out io.Writer) {
+	for _, info := range RegisteredDetectors() {
+		state := "activé"
+		if !info.Enabled {
+			state = "désactivé"
+		}
+		fmt.Fprintf(out, "%-10s %-10s %s\n", info.Name, state, info.Description)
+	}
+}
+
+// DetectVulnerabilities exécute les détecteurs activés sur un fichier et retourne leurs
+// résultats triés par ligne, après sélection des règles, résolution des appels vers les
+// fonctions du projet, application de la calibration et des sévérités du projet.
+// Lorsque ctx est annulé, les détecteurs restants ne sont pas exécutés : les résultats
+// sont alors partiels, ce que l'appelant vérifie avec ctx.Err().
+func (pa *PHPAnalyzer) DetectVulnerabilities(ctx context.Context, tree *sitter.Tree, source []byte) []Finding {
+	var cfg *CFG
+	cfgBuilt := false
+	var findings []Finding
+	for _, info := range RegisteredDetectors() {
+		if ctx.Err() != nil {
+			break
+		}
+		if !pa.DetectorEnabled(info) {
+			continue
+		}
+		if info.NeedsCFG && !cfgBuilt {
+			cfgBuilt = true
+			pa.timings.measure(cfgTiming, func() {
+				var err error
+				if cfg, err = NewCFGBuilder().BuildCFG(ctx, source); err != nil && ctx.Err() == nil {
+					log.Printf("Construction du CFG impossible : %v", err)
+				}
+			})
+		}
+		if info.NeedsCFG && cfg == nil {
+			continue
+		}
+		var results []Finding
+		pa.timings.measure(info.Name, func() { results = info.New(pa).Run(tree, source, cfg) })
+		for _, f := range results {
+			if pa.rules.allows(info.Name, f.RuleID) {
+				findings = append(findings, f)
+			}
+		}
+	}
+	findings = pa.resolveShims(findings, tree.RootNode(), source)
+	sort.SliceStable(findings, func(i, j int) bool { return findingLess(findings[i], findings[j]) })
+	pa.Calibration.Apply(findings)
+	pa.applySeverityOverrides(findings)
+	return findings
+}
+
+// funcDetector adapte une fonction d'analyse de l'AST à l'interface Detector.
+type funcDetector struct {
+	name string
+	run  func(root *sitter.Node, source []byte) []Finding
+}
+
+func (d funcDetector) Name() string { return d.name }
+
+func (d funcDetector) Run(tree *sitter.Tree, source []byte, cfg *CFG) []Finding {
+	return d.run(tree.RootNode(), source)
+}
+
+// astDetector enregistre un détecteur qui n'a besoin que de l'AST.
+func astDetector(name, description string, enabled bool, rules []string, run func(pa *PHPAnalyzer, root *sitter.Node, source []byte) []Finding) {
+	RegisterDetector(DetectorInfo{
+		Name:        name,
+		Description: description,
+		Enabled:     enabled,
+		Rules:       rules,
+		New: func(pa *PHPAnalyzer) Detector {
+			return funcDetector{name: name, run: func(root *sitter.Node, source []byte) []Finding {
+				return run(pa, root, source)
+			}}
+		},
+	})
+}
+
+func init() {
+	astDetector("cve", "Signatures de CVE, requêtes tree-sitter et motifs de code des fichiers de règles YAML", true, nil, detectSignatures)
+	astDetector("taint", "Flux de données contaminées : injection SQL, XSS, phar://, fixation de session", true,
+		[]string{"session-fixation", "phar-deserialization", "sqli", "sqli-concat", "xss"}, detectTaintFlows)
+	astDetector("crypto", "Chiffrements faibles et IV/nonces statiques", true, []string{"static-iv", "weak-cipher"}, detectCryptoMisuse)
+	astDetector("debug", "Traces de débogage et divulgation d'erreurs", true, []string{"debug-leftover", "error-disclosure"}, detectDebugOutput)
+	astDetector("security", "XXE, cookies, sessions, téléversements, configuration, comparaisons", true,
+		[]string{"type-juggling", "xxe", "insecure-cookie", "unsafe-upload", "insecure-config", "register-globals", "session-regeneration"}, detectInsecurePractices)
+	astDetector("transactions", "Transactions ouvertes sans commit ni rollback sur un chemin", true,
+		[]string{"transaction-unclosed"}, detectUnclosedTransactions)
+	astDetector("lint", "Qualité du code : variables affectées mais jamais lues, imports inutilisés", false, []string{"unused-variable", "unused-import"}, detectLint)
+	astDetector("dbcalls", "Inventaire des appels à la base de données", false, []string{"dbcall"}, func(pa *PHPAnalyzer, root *sitter.Node, source []byte) []Finding {
+		return pa.DetectDatabaseCalls(root, source)
+	})
+	RegisterDetector(DetectorInfo{
+		Name:        "metrics",
+		Description: "Nombre de branchements et complexité cyclomatique",
+		NeedsCFG:    true,
+		Rules:       []string{"metrics"},
+		New:         func(pa *PHPAnalyzer) Detector { return metricsDetector{pa} },
+	})
+}
+
+// callNodes parcourt les appels de fonctions et de méthodes de l'AST.
+func callNodes(root *sitter.Node, source []byte, visit func(n *sitter.Node, funcName string, line uint32)) {
+	traverseAST(root, func(n *sitter.Node) {
+		if n.Type() == "function_call_expression" || n.Type() == "member_call_expression" {
+			visit(n, extractFunctionName(n, source), n.StartPoint().Row+1)
+		}
+	})
+}
+
+// onCall place le résultat sur l'appel n et l'associe à la fonction appelée, pour la
+// résolution des polyfills du projet. Les appels de méthodes ne sont pas concernés.
+func (f Finding) onCall(n *sitter.Node, funcName string) Finding {
+	if n.Type() == "function_call_expression" {
+		f.Function = funcName
+	}
+	return f.at(n)
+}
+
+// at place le résultat sur l'étendue du nœud n : colonnes et octets de début et de fin.
+func (f Finding) at(n *sitter.Node) Finding {
+	start, end := n.StartPoint(), n.EndPoint()
+	f.Column = start.Column + 1
+	f.EndLine, f.EndColumn = end.Row+1, end.Column+1
+	f.StartByte, f.EndByte = n.StartByte(), n.EndByte()
+	return f
+}
+
+// detectSignatures applique les signatures chargées depuis les fichiers de règles : les
+// signatures d'appels et les motifs de code, puis les requêtes tree-sitter et les
+// expressions régulières.
+func detectSignatures(pa *PHPAnalyzer, root *sitter.Node, source []byte) []Finding {
+	var findings []Finding
+	traverseAST(root, func(n *sitter.Node) {
+		if n.Type() == "function_call_expression" || n.Type() == "member_call_expression" {
+			funcName := extractFunctionName(n, source)
+			for _, rule := range pa.Signatures.ForFunction(funcName) {
+				if finding, ok := rule.Match(n, source, pa.PHPVersion); ok {
+					findings = append(findings, finding.onCall(n, funcName))
+				}
+			}
+		}
+		for _, rule := range pa.Signatures.ForKind(n.Type()) {
+			if finding, ok := rule.MatchPattern(n, source, pa.PHPVersion); ok {
+				findings = append(findings, finding.at(n))
+			}
+		}
+	})
+	for _, rule := range pa.Signatures.Queries() {
+		findings = append(findings, rule.MatchQuery(root, source, pa.PHPVersion)...)
+	}
+	for _, rule := range pa.Signatures.Regexes() {
+		findings = append(findings, rule.MatchRegex(root, source, pa.PHPVersion)...)
+	}
+	return findings
+}
+
+// detectTaintFlows signale les données contaminées atteignant une requête SQL, un
+// affichage, une fonction de fichiers (phar://) ou session_id().
+func detectTaintFlows(pa *PHPAnalyzer, root *sitter.Node, source []byte) []Finding {
+	var findings []Finding
+	// Les fonctions du projet qui enveloppent une fonction de nettoyage en sont une
+	// également, où qu'elles soient définies, comme les méthodes des classes du fichier,
+	// y compris celles de leurs traits.
+	functions := pa.projectFunctions(root, source)
+	classes, declared := pa.projectClasses(root, source)
+	sanitizers := func(builtin map[string]bool) map[string]bool {
+		return classes.sanitizers(declared, functions.sanitizers(builtin))
+	}
+	taint := NewTaintTracker(root, source, TaintOptions{})
+	xssTaint := NewTaintTracker(root, source, TaintOptions{DBReads: pa.TaintDBReads, Sanitizers: sanitizers(xssSanitizers)})
+	sqlTaint := NewTaintTracker(root, source, TaintOptions{DBReads: pa.TaintDBReads, Sanitizers: sanitizers(sqlSanitizers)})
+	pharTaint := NewTaintTracker(root, source, TaintOptions{DBReads: pa.TaintDBReads, Sanitizers: sanitizers(pharSanitizers)})
+	traverseAST(root, func(n *sitter.Node) {
+		line := n.StartPoint().Row + 1
+		switch n.Type() {
+		case "function_call_expression", "member_call_expression", "scoped_call_expression":
+			funcName := extractFunctionName(n, source)
+			// Fixation de session : identifiant imposé par l'utilisateur
+			if funcName == "session_id" && isTaintedSessionID(n, taint) {
+				findings = append(findings, newFinding("session-fixation", line, "session_id() défini à partir d'une entrée utilisateur").onCall(n, funcName))
+			}
+			// Désérialisation via le wrapper phar://
+			if isPharSink(n, funcName, pharTaint, source) {
+				findings = append(findings, newFinding("phar-deserialization", line, fmt.Sprintf("%s reçoit un chemin contaminé pouvant utiliser le wrapper phar:// (désérialisation)", funcName)).onCall(n, funcName))
+			}
+			// Injection SQL : donnée contaminée dans la requête, sinon requête construite
+			// par concaténation (heuristique, confiance moyenne)
+			query := sqlQueryArgument(n, funcName, source)
+			if origin := sqlTaint.Origin(query); origin != "" {
+				findings = append(findings, newFinding("sqli", line, fmt.Sprintf("requête SQL passée à %s contenant une donnée contaminée (%s)", funcName, origin)).onCall(n, funcName))
+			} else if isConcatenatedQuery(query, source) {
+				findings = append(findings, newFinding("sqli-concat", line, fmt.Sprintf("requête SQL construite par concaténation passée à %s", funcName)).onCall(n, funcName))
+			}
+		// XSS : donnée contaminée affichée sans échappement
+		case "echo_statement", "print_intrinsic":
+			if origin := xssTaint.Origin(n); origin != "" {
+				findings = append(findings, newFinding("xss", line, fmt.Sprintf("%s affiche une donnée contaminée (%s) sans échappement", n.Child(0).Content(source), origin)).at(n))
+			}
+		}
+	})
+	return findings
+}
+
+// detectCryptoMisuse signale les chiffrements faibles et les IV/nonces statiques.
+func detectCryptoMisuse(pa *PHPAnalyzer, root *sitter.Node, source []byte) []Finding {
+	var findings []Finding
+	callNodes(root, source, func(n *sitter.Node, funcName string, line uint32) {
+		if reason := weakCipher(n, funcName, source); reason != "" {
+			findings = append(findings, newFinding("weak-cipher", line, fmt.Sprintf("%s : %s", funcName, reason)).onCall(n, funcName))
+		}
+		if kind := staticNonce(n, funcName, source); kind != "" {
+			findings = append(findings, newFinding("static-iv", line, fmt.Sprintf("%s avec un IV/nonce statique (%s)", funcName, kind)).onCall(n, funcName))
+		}
+	})
+	return findings
+}
+
+// detectDebugOutput signale les appels de débogage oubliés et les messages d'erreur
+// affichés dans la réponse.
+func detectDebugOutput(pa *PHPAnalyzer, root *sitter.Node, source []byte) []Finding {
+	var findings []Finding
+	traverseAST(root, func(n *sitter.Node) {
+		line := n.StartPoint().Row + 1
+		switch n.Type() {
+		case "function_call_expression", "member_call_expression":
+			funcName := extractFunctionName(n, source)
+			switch funcName {
+			// Divulgation d'erreurs dans la réponse
+			case "die", "printf", "vprintf":
+				if disclosed := disclosedError(n, source); disclosed != "" {
+					findings = append(findings, newFinding("error-disclosure", line, fmt.Sprintf("%s() affiche le détail d'une erreur : %s", funcName, disclosed)).onCall(n, funcName))
+				}
+			// Traces de débogage oubliées
+			case "var_dump", "print_r", "debug_print_backtrace", "debug_zval_dump", "phpinfo":
+				if n.Type() == "function_call_expression" && isDebugLeftover(n, funcName, source) {
+					findings = append(findings, newFinding("debug-leftover", line, fmt.Sprintf("appel de débogage %s() oublié", funcName)).onCall(n, funcName))
+				}
+			}
+		// Divulgation d'erreurs via echo, print ou exit
+		case "echo_statement", "print_intrinsic", "exit_statement":
+			if disclosed := disclosedError(n, source); disclosed != "" {
+				findings = append(findings, newFinding("error-disclosure", line, fmt.Sprintf("%s affiche le détail d'une erreur : %s", n.Child(0).Content(source), disclosed)).at(n))
+			}
+		}
+	})
+	return findings
+}
+
+// detectInsecurePractices regroupe les règles de configuration et d'usage des API :
+// XXE, cookies, régénération de session, téléversements, durcissement de la
+// configuration et comparaisons non strictes.
+func detectInsecurePractices(pa *PHPAnalyzer, root *sitter.Node, source []byte) []Finding {
+	var findings []Finding
+	traverseAST(root, func(n *sitter.Node) {
+		line := n.StartPoint().Row + 1
+		// Comparaison non stricte sur une valeur sensible (type juggling)
+		if n.Type() == "binary_expression" && isLooseSensitiveComparison(n, source) {
+			findings = append(findings, newFinding("type-juggling", line, fmt.Sprintf("comparaison non stricte %q sur une valeur sensible, utiliser hash_equals() ou ===", n.Content(source))).at(n))
+		}
+		if n.Type() != "function_call_expression" && n.Type() != "member_call_expression" && n.Type() != "scoped_call_expression" {
+			return
+		}
+		funcName := extractFunctionName(n, source)
+		switch funcName {
+		// XXE : chargement XML avec substitution des entités externes
+		case "simplexml_load_file", "simplexml_load_string", "loadXML":
+			if hasLibxmlNoent(n, source) {
+				findings = append(findings, newFinding("xxe", line, fmt.Sprintf("%s avec LIBXML_NOENT détecté", funcName)).onCall(n, funcName))
+			}
+		// XXE : réactivation du chargeur d'entités externes
+		case "libxml_disable_entity_loader":
+			if isEntityLoaderEnabled(n, source) {
+				findings = append(findings, newFinding("xxe", line, "libxml_disable_entity_loader(false) détecté").onCall(n, funcName))
+			}
+		// Cookies sans les options secure/httponly/samesite
+		case "setcookie", "setrawcookie", "session_set_cookie_params":
+			if missing := missingCookieFlags(n, funcName, source); len(missing) > 0 {
+				findings = append(findings, newFinding("insecure-cookie", line, fmt.Sprintf("%s sans les options %s détecté", funcName, strings.Join(missing, ", "))).onCall(n, funcName))
+			}
+		// Téléversement : destination dérivée du nom fourni par le client
+		case "move_uploaded_file":
+			if issues := unsafeUploadIssues(n, root, source); len(issues) > 0 {
+				findings = append(findings, newFinding("unsafe-upload", line, fmt.Sprintf("move_uploaded_file vers un nom fourni par le client %s", strings.Join(issues, ", "))).onCall(n, funcName))
+			}
+		// Durcissement de la configuration
+		case "ini_set":
+			if option := dangerousIniSetting(n, source); option != "" {
+				findings = append(findings, newFinding("insecure-config", line, fmt.Sprintf("ini_set active l'option dangereuse %s", option)).onCall(n, funcName))
+			}
+		case "error_reporting":
+			if isErrorReportingDisabled(n, source) {
+				findings = append(findings, newFinding("insecure-config", line, "error_reporting(0) désactive le signalement des erreurs hors environnement de développement").onCall(n, funcName))
+			}
+		case "extract":
+			if isRegisterGlobalsExtract(n, source) {
+				findings = append(findings, newFinding("register-globals", line, "extract() sur une superglobale (émulation de register_globals)").onCall(n, funcName))
+			}
+		// Fixation de session : pas de session_regenerate_id après l'authentification
+		default:
+			if region, implicit := authenticatedRegion(n, source); region != nil && missesSessionRegeneration(n, region, implicit, source) {
+				findings = append(findings, newFinding("session-regeneration", line, fmt.Sprintf("%s sans session_regenerate_id() ensuite", funcName)).at(n))
+			}
+		}
+	})
+	return findings
+}
+
+// metricsDetector produit un résultat informatif par fichier : nombre de branchements
+// et complexité cyclomatique (cyclomaticComplexity).
+type metricsDetector struct {
+	pa *PHPAnalyzer
+}
+
+func (d metricsDetector) Name() string { return "metrics" }
+
+func (d metricsDetector) Run(tree *sitter.Tree, source []byte, cfg *CFG) []Finding {
+	message := fmt.Sprintf("%d branchements, complexité cyclomatique %d", d.pa.CountBranches(tree.RootNode()), cyclomaticComplexity(tree.RootNode()))
+	return []Finding{newFinding("metrics", 1, message)}
+}
+
+// cyclomaticComplexity retourne la complexité cyclomatique d'un fichier : 1 plus le
+// nombre de points de décision de l'AST (conditions, boucles, case, catch, bras de
+// match, ternaires et opérateurs logiques). Contrairement à arcs - nœuds + 2, elle ne
+// dépend pas du code mort ni des nœuds que le CFG laisse déconnectés.
+func cyclomaticComplexity(root *sitter.Node) int {
+	complexity := 1
+	traverseAST(root, func(n *sitter.Node) {
+		switch n.Type() {
+		case "if_statement", "else_if_clause", "while_statement", "for_statement", "foreach_statement", "do_statement",
+			"case_statement", "catch_clause", "conditional_expression", "match_conditional_expression":
+			complexity++
+		case "binary_expression":
+			if operator := n.ChildByFieldName("operator"); operator != nil {
+				switch operator.Type() {
+				case "&&", "||", "and", "or":
+					complexity++
+				}
+			}
+		}
+	})
+	return complexity
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetectorRegistry(t *testing.T) {
+	var names []string
+	for _, info := range RegisteredDetectors() {
+		names = append(names, info.Name)
+	}
+	assert.Equal(t, []string{"crypto", "cve", "dbcalls", "debug", "lint", "metrics", "security", "taint", "transactions"}, names)
+
+	assert.Panics(t, func() {
+		RegisterDetector(DetectorInfo{Name: "cve"})
+	})
+
+	var out strings.Builder
+	WriteDetectorList(&out)
+	assert.Contains(t, out.String(), "dbcalls    désactivé")
+}
+
+func TestEnableAndDisableDetectors(t *testing.T) {
+	phpCode := []byte(`<?php
+if ($a) { mysql_query("SELECT 1"); }
+var_dump($a);`)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+
+	findings := analyzer.DetectVulnerabilities(context.Background(), tree, phpCode)
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "debug-leftover", findings[0].RuleID)
+
+	assert.NoError(t, analyzer.SetDetectorEnabled("debug", false))
+	assert.NoError(t, analyzer.SetDetectorEnabled("dbcalls", true))
+	assert.NoError(t, analyzer.SetDetectorEnabled("metrics", true))
+	assert.Error(t, analyzer.SetDetectorEnabled("unknown", true))
+
+	findings = analyzer.DetectVulnerabilities(context.Background(), tree, phpCode)
+	assert.Len(t, findings, 2)
+	assert.Equal(t, "metrics", findings[0].RuleID)
+	assert.Equal(t, "1 branchements, complexité cyclomatique 2", findings[0].Message)
+	assert.Equal(t, "dbcall", findings[1].RuleID)
+}
+
+func TestMetricsComplexity(t *testing.T) {
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, analyzer.SetDetectorEnabled("metrics", true))
+	metrics := func(phpCode string) string {
+		tree, err := analyzer.Parse(context.Background(), []byte(phpCode))
+		assert.NoError(t, err)
+		for _, f := range analyzer.DetectVulnerabilities(context.Background(), tree, []byte(phpCode)) {
+			if f.RuleID == "metrics" {
+				return f.Message
+			}
+		}
+		return ""
+	}
+	assert.Equal(t, "1 branchements, complexité cyclomatique 4", metrics(`<?php
+switch ($v) { case 1: echo 1; break; case 2: echo 2; break; default: continue; }
+function f() { return 1; echo "mort"; }
+try { g(); } catch (Exception $e) { h(); }
+echo 3;`), "dead code and disconnected nodes do not lower the complexity")
+	assert.Equal(t, "1 branchements, complexité cyclomatique 5", metrics(`<?php
+if ($a && $b || $c) { echo $d ? 1 : 2; }`))
+}
+
+func TestAnalyzerConcurrentUse(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.php")
+	assert.NoError(t, os.WriteFile(path, []byte("<?php\n$id = $_GET['id'];\nmysql_query(\"SELECT * FROM t WHERE id = \" . $id);\nif ($id) { echo $id; }\n"), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	var wg sync.WaitGroup
+	counts := make([]int, 8)
+	for i := range counts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				tree, content, err := analyzer.ParseFile(context.Background(), path)
+				if !assert.NoError(t, err) {
+					return
+				}
+				counts[i] = len(analyzer.DetectVulnerabilities(context.Background(), tree, content)) + len(analyzer.DetectDatabaseCalls(tree.RootNode(), content))
+				tree.Close()
+			}
+		}()
+	}
+	wg.Wait()
+	for _, count := range counts {
+		assert.Equal(t, counts[0], count)
+	}
+	assert.Positive(t, counts[0])
+}
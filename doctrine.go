@@ -0,0 +1,181 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// doctrineQueryMethods liste les méthodes de Doctrine dont le premier argument est une
+// requête SQL (Connection de DBAL) ou DQL (EntityManager de l'ORM).
+var doctrineQueryMethods = map[string]bool{
+	"executeQuery":               true,
+	"executeStatement":           true,
+	"executeUpdate":              true,
+	"executeCacheQuery":          true,
+	"fetchAllAssociative":        true,
+	"fetchAllAssociativeIndexed": true,
+	"fetchAllKeyValue":           true,
+	"fetchAllNumeric":            true,
+	"fetchAssociative":           true,
+	"fetchFirstColumn":           true,
+	"fetchNumeric":               true,
+	"fetchOne":                   true,
+	"iterateAssociative":         true,
+	"iterateNumeric":             true,
+	"createQuery":                true,
+	"createNativeQuery":          true,
+}
+
+// doctrineConditionMethods liste les méthodes du QueryBuilder dont le premier argument
+// est un fragment de requête inséré tel quel.
+var doctrineConditionMethods = map[string]bool{
+	"where":     true,
+	"andWhere":  true,
+	"orWhere":   true,
+	"having":    true,
+	"andHaving": true,
+	"orHaving":  true,
+}
+
+// doctrineBuilderStatements associe les méthodes du QueryBuilder qui fixent le type de
+// la requête construite à ce type ; leur premier argument est l'entité ou la table,
+// sauf pour select().
+var doctrineBuilderStatements = map[string]string{
+	"select":    sqlSelect,
+	"addSelect": sqlSelect,
+	"from":      sqlSelect,
+	"update":    sqlUpdate,
+	"delete":    sqlDelete,
+	"insert":    sqlInsert,
+}
+
+// doctrineBuilder retourne les appels de méthodes qui construisent le QueryBuilder sur
+// lequel node est appelé : la chaîne d'appels de node, et lorsqu'elle part d'une
+// variable, la valeur affectée à cette variable et les appels faits sur elle plus haut
+// dans la même fonction. Le résultat est nil si aucun createQueryBuilder() n'en fait
+// partie.
+func doctrineBuilder(node *sitter.Node, source []byte) []*sitter.Node {
+	var calls []*sitter.Node
+	builder := false
+	var chain func(n *sitter.Node)
+	chain = func(n *sitter.Node) {
+		for n != nil && n.Type() == "member_call_expression" {
+			calls = append(calls, n)
+			if extractFunctionName(n, source) == "createQueryBuilder" {
+				builder = true
+			}
+			n = n.ChildByFieldName("object")
+		}
+		if n == nil || n.Type() != "variable_name" {
+			return
+		}
+		variable := n
+		for _, value := range variableAssignments(variable, source) {
+			chain(value)
+		}
+		name := variable.Content(source)
+		traverseAST(enclosingScope(variable), func(m *sitter.Node) {
+			if m.Type() != "expression_statement" || m.EndByte() > variable.StartByte() {
+				return
+			}
+			call := m.NamedChild(0)
+			root := call
+			for root != nil && root.Type() == "member_call_expression" {
+				root = root.ChildByFieldName("object")
+			}
+			if root != nil && root != call && root.Type() == "variable_name" && root.Content(source) == name {
+				for call != root {
+					calls = append(calls, call)
+					call = call.ChildByFieldName("object")
+				}
+			}
+		})
+	}
+	chain(node.ChildByFieldName("object"))
+	if !builder {
+		return nil
+	}
+	return calls
+}
+
+// doctrineBuilderStatement déduit l'instruction construite par un QueryBuilder de ses
+// appels select(), from(), update(), delete() et insert(), dans l'ordre du code.
+func doctrineBuilderStatement(calls []*sitter.Node, source []byte) *SQLStatement {
+	sort.Slice(calls, func(i, j int) bool {
+		return calls[i].ChildByFieldName("name").StartByte() < calls[j].ChildByFieldName("name").StartByte()
+	})
+	statement := &SQLStatement{}
+	for _, call := range calls {
+		method := extractFunctionName(call, source)
+		kind, ok := doctrineBuilderStatements[method]
+		if !ok {
+			continue
+		}
+		if statement.Kind == "" || method != "from" {
+			statement.Kind = kind
+		}
+		if args := getArgumentNodes(call); method != "select" && method != "addSelect" && len(args) > 0 {
+			if table := doctrineEntity(argumentValue(args[0]), source); table != "" {
+				statement.Tables = append(statement.Tables, table)
+			}
+		}
+	}
+	return statement
+}
+
+// doctrineEntity retourne le nom de l'entité (User::class) ou de la table désignée par
+// une expression, "" si elle n'est pas résoluble.
+func doctrineEntity(expr *sitter.Node, source []byte) string {
+	if expr == nil {
+		return ""
+	}
+	if expr.Type() == "class_constant_access_expression" {
+		if class, constant, ok := strings.Cut(expr.Content(source), "::"); ok && strings.EqualFold(constant, "class") {
+			return strings.TrimPrefix(class, `\`)
+		}
+	}
+	if table, ok := resolveSQL(expr, source); ok {
+		return table
+	}
+	return ""
+}
+
+// doctrineDatabaseCalls inventorie les appels à la base de données de Doctrine : requêtes
+// SQL et DQL passées à la Connection ou à l'EntityManager, exécution d'un QueryBuilder
+// (getQuery(), ou executeQuery() sans argument pour DBAL), et conditions du QueryBuilder
+// construites dynamiquement, signalées comme plus risquées.
+func doctrineDatabaseCalls(n *sitter.Node, funcName string, source []byte) []Finding {
+	if n.Type() != "member_call_expression" {
+		return nil
+	}
+	line := n.StartPoint().Row + 1
+	args := getArgumentNodes(n)
+	switch {
+	case doctrineQueryMethods[funcName] && len(args) > 0:
+		return []Finding{rawSQLFinding(n, "->"+funcName+"(...)", line, sqlStatement(n, funcName, source), source)}
+	case funcName == "getQuery" || (len(args) == 0 && (funcName == "executeQuery" || funcName == "executeStatement")):
+		if calls := doctrineBuilder(n, source); calls != nil {
+			call := "QueryBuilder->" + funcName + "()"
+			return []Finding{dbCallFinding(call, line, "Appel trouvé : "+call, doctrineBuilderStatement(calls, source), doctrineBuilderConstruction(calls, source))}
+		}
+	case doctrineConditionMethods[funcName] && len(args) > 0 && isConcatenatedQuery(argumentValue(args[0]), source):
+		if doctrineBuilder(n, source) != nil {
+			return []Finding{rawSQLFinding(n, "QueryBuilder->"+funcName+"(...)", line, nil, source)}
+		}
+	}
+	return nil
+}
+
+// doctrineBuilderConstruction détermine la construction d'une requête du QueryBuilder :
+// concaténée si l'une de ses conditions l'est, paramétrée sinon (setParameter()).
+func doctrineBuilderConstruction(calls []*sitter.Node, source []byte) QueryConstruction {
+	for _, call := range calls {
+		args := getArgumentNodes(call)
+		if doctrineConditionMethods[extractFunctionName(call, source)] && len(args) > 0 && isConcatenatedQuery(argumentValue(args[0]), source) {
+			return QueryConcatenated
+		}
+	}
+	return QueryParameterized
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// sqlOptionalConnection associe les fonctions dont la connexion est facultative au nombre
+// de leurs arguments obligatoires lorsqu'elle est passée : avec moins d'arguments, la
+// requête est décalée d'une position vers la gauche.
+var sqlOptionalConnection = map[string]int{
+	"pg_query":        2,
+	"pg_query_params": 3,
+	"pg_prepare":      3,
+}
+
+// driverFunctions liste les fonctions d'exécution des extensions pgsql et sqlsrv,
+// inventoriées comme mysqli_query.
+var driverFunctions = map[string]bool{
+	"pg_query":             true,
+	"pg_query_params":      true,
+	"pg_send_query":        true,
+	"pg_send_query_params": true,
+	"pg_prepare":           true,
+	"sqlsrv_query":         true,
+	"sqlsrv_prepare":       true,
+}
+
+// sqlite3Methods liste les méthodes de SQLite3 qui exécutent une requête.
+var sqlite3Methods = map[string]bool{
+	"query":       true,
+	"querySingle": true,
+	"exec":        true,
+}
+
+// createdClass retourne le nom, sans espace de noms, de la classe instanciée par une
+// expression new, "" si l'expression n'en est pas une.
+func createdClass(expr *sitter.Node, source []byte) string {
+	for expr != nil && expr.Type() == "parenthesized_expression" {
+		expr = expr.NamedChild(0)
+	}
+	if expr == nil || expr.Type() != "object_creation_expression" {
+		return ""
+	}
+	for i := 0; i < int(expr.NamedChildCount()); i++ {
+		if child := expr.NamedChild(i); child.Type() == "name" || child.Type() == "qualified_name" {
+			return classBaseName(child.Content(source))
+		}
+	}
+	return ""
+}
+
+// isSQLite3Call vérifie si une méthode est appelée sur une instance de SQLite3, créée
+// dans l'expression ou affectée plus haut dans la même fonction.
+func isSQLite3Call(n *sitter.Node, source []byte) bool {
+	object := n.ChildByFieldName("object")
+	values := []*sitter.Node{object}
+	if object != nil && object.Type() == "variable_name" {
+		values = variableAssignments(object, source)
+	}
+	for _, value := range values {
+		if strings.EqualFold(createdClass(value, source), "SQLite3") {
+			return true
+		}
+	}
+	return false
+}
+
+// ociParse retourne l'appel oci_parse() qui a préparé la requête exécutée par
+// oci_execute(), affectée plus haut dans la même fonction, ou nil.
+func ociParse(n *sitter.Node, source []byte) *sitter.Node {
+	args := getArgumentNodes(n)
+	if len(args) == 0 {
+		return nil
+	}
+	values := []*sitter.Node{argumentValue(args[0])}
+	if values[0] != nil && values[0].Type() == "variable_name" {
+		values = variableAssignments(values[0], source)
+	}
+	for _, value := range values {
+		if value != nil && value.Type() == "function_call_expression" && strings.EqualFold(extractFunctionName(value, source), "oci_parse") {
+			return value
+		}
+	}
+	return nil
+}
+
+// driverDatabaseCalls inventorie les appels à la base de données des extensions pgsql,
+// sqlite3, sqlsrv et oci8 : fonctions d'exécution, méthodes d'une instance de SQLite3,
+// et oci_execute(), classé d'après la requête passée à son oci_parse().
+func driverDatabaseCalls(n *sitter.Node, funcName string, source []byte) []Finding {
+	line := n.StartPoint().Row + 1
+	switch n.Type() {
+	case "function_call_expression":
+		name := strings.ToLower(funcName)
+		switch {
+		case driverFunctions[name]:
+			statement := sqlStatement(n, name, source)
+			construction := sqlConstruction(sqlQueryArgument(n, name, source), source)
+			return []Finding{dbCallFinding(name, line, "Appel trouvé : "+name, statement, construction)}
+		case name == "oci_execute":
+			var statement *SQLStatement
+			construction := QueryUnknown
+			if parse := ociParse(n, source); parse != nil {
+				statement = sqlStatement(parse, "oci_parse", source)
+				construction = sqlConstruction(sqlQueryArgument(parse, "oci_parse", source), source)
+			}
+			return []Finding{dbCallFinding(name, line, "Appel trouvé : "+name, statement, construction)}
+		}
+	case "member_call_expression":
+		if sqlite3Methods[funcName] && isSQLite3Call(n, source) {
+			call := fmt.Sprintf("SQLite3->%s(...)", funcName)
+			return []Finding{dbCallFinding(call, line, "Appel trouvé : "+call, sqlStatement(n, funcName, source), sqlConstruction(sqlQueryArgument(n, funcName, source), source))}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// defaultExcludes liste les dossiers ignorés par défaut lors du parcours d'un dossier :
+// dépendances installées et métadonnées de git, dont l'analyse domine la durée du scan
+// sans concerner le code du projet.
+var defaultExcludes = []string{"vendor/", "node_modules/", ".git/"}
+
+// ignoreFileName est le fichier de motifs propre à l'analyseur, lu dans chaque dossier
+// parcouru ; les .gitignore ne sont lus qu'avec -gitignore.
+const (
+	ignoreFileName = ".phpanalyzerignore"
+	gitignoreName  = ".gitignore"
+)
+
+// excludeFlags regroupe les options d'exclusion et de parcours des commandes qui
+// parcourent des dossiers.
+type excludeFlags struct {
+	patterns   listFlag
+	noDefault  bool
+	gitignore  bool
+	follow     bool
+	maxDepth   int
+	hidden     bool
+	extensions listFlag
+}
+
+func addExcludeFlags(fs *flag.FlagSet) *excludeFlags {
+	flags := &excludeFlags{}
+	fs.Var(&flags.patterns, "exclude", fmt.Sprintf("Motif de chemins à ignorer dans les dossiers parcourus, répétable (en plus de %s)", strings.Join(defaultExcludes, ", ")))
+	fs.BoolVar(&flags.noDefault, "no-default-excludes", false, "Parcourt aussi "+strings.Join(defaultExcludes, ", "))
+	fs.BoolVar(&flags.gitignore, "gitignore", false, "Ignore aussi les chemins exclus par les fichiers .gitignore")
+	fs.BoolVar(&flags.follow, "follow-symlinks", false, "Parcourt les dossiers désignés par des liens symboliques")
+	fs.IntVar(&flags.maxDepth, "max-depth", 0, "Profondeur maximale du parcours, 1 pour les seuls fichiers du dossier (0 = aucune limite)")
+	fs.BoolVar(&flags.hidden, "hidden", false, "Parcourt aussi les dossiers cachés (.cache, .idea...)")
+	fs.Var(&flags.extensions, "extensions", fmt.Sprintf("Extensions des fichiers analysés, séparées par des virgules (défaut %s)", strings.Join(defaultExtensions, ",")))
+	return flags
+}
+
+// applyExcludes configure les motifs ignorés et les options du parcours des dossiers.
+func applyExcludes(analyzer *PHPAnalyzer, flags *excludeFlags) error {
+	if flags.maxDepth < 0 {
+		return fmt.Errorf("option -max-depth : profondeur %d invalide", flags.maxDepth)
+	}
+	var extensions []string
+	for _, ext := range flags.extensions {
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		extensions = append(extensions, ext)
+	}
+	var patterns []string
+	if !flags.noDefault {
+		patterns = append(patterns, defaultExcludes...)
+	}
+	for _, pattern := range flags.patterns {
+		if _, err := filepath.Match(strings.Trim(pattern, "/"), ""); err != nil {
+			return fmt.Errorf("option -exclude : motif %q invalide : %v", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	analyzer.Exclude = patterns
+	analyzer.Gitignore = flags.gitignore
+	analyzer.Walk = WalkOptions{FollowSymlinks: flags.follow, MaxDepth: flags.maxDepth, Hidden: flags.hidden, Extensions: extensions}
+	return nil
+}
+
+// ignoreRule est un motif d'exclusion, écrit comme dans un .gitignore. Un motif terminé
+// par "/" ne s'applique qu'aux dossiers ; un motif sans autre "/" s'applique au nom du
+// fichier ou du dossier, à toute profondeur (vendor/, *.min.php) ; les autres
+// s'appliquent au chemin relatif au dossier du motif, "**" désignant un nombre
+// quelconque de dossiers (src/**/generated, /cache). Un motif précédé de "!" réinclut
+// les chemins exclus par un motif précédent.
+type ignoreRule struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+	anchored bool
+}
+
+// parseIgnoreRule analyse une ligne de fichier d'exclusion ; les lignes vides et les
+// commentaires ne produisent pas de motif.
+func parseIgnoreRule(line string) (ignoreRule, bool) {
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false
+	}
+	var rule ignoreRule
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:] // \# et \! désignent un nom commençant par # ou !
+	}
+	rule.dirOnly = strings.HasSuffix(line, "/")
+	line = strings.TrimSuffix(line, "/")
+	rule.anchored = strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+	if line == "" {
+		return ignoreRule{}, false
+	}
+	rule.segments = strings.Split(line, "/")
+	return rule, true
+}
+
+// match indique si le motif s'applique à un chemin relatif à son dossier, avec des "/".
+func (r ignoreRule) match(rel string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	if !r.anchored {
+		ok, _ := path.Match(r.segments[0], path.Base(rel))
+		return ok
+	}
+	return matchSegments(r.segments, strings.Split(rel, "/"))
+}
+
+// ignoreSet rassemble les motifs d'un dossier. prefix est le chemin de la racine du
+// parcours vu de ce dossier, lorsqu'il la contient (fichier d'un dossier parent) ; dir
+// est sinon son chemin relatif à la racine ("" pour la racine elle-même).
+type ignoreSet struct {
+	dir, prefix string
+	rules       []ignoreRule
+}
+
+// relative retourne le chemin rel, relatif à la racine, vu du dossier des motifs, et
+// false si ce dossier ne le contient pas.
+func (s ignoreSet) relative(rel string) (string, bool) {
+	switch {
+	case s.prefix != "":
+		return s.prefix + "/" + rel, true
+	case s.dir == "":
+		return rel, true
+	case strings.HasPrefix(rel, s.dir+"/"):
+		return rel[len(s.dir)+1:], true
+	}
+	return "", false
+}
+
+// walkFilter décide des chemins ignorés lors du parcours d'un dossier : motifs des
+// fichiers .phpanalyzerignore (et .gitignore avec -gitignore) rencontrés, des plus
+// généraux aux plus proches du chemin, puis motifs de -exclude, qui l'emportent.
+type walkFilter struct {
+	root     string
+	files    []string
+	sets     []ignoreSet
+	excludes ignoreSet
+}
+
+// newWalkFilter prépare le filtre du parcours de root. Les fichiers d'exclusion des
+// dossiers parents de root sont lus jusqu'à la racine du dépôt git qui le contient, pour
+// qu'analyser un sous-dossier ignore les mêmes chemins que le dépôt entier.
+func (pa *PHPAnalyzer) newWalkFilter(root string) *walkFilter {
+	wf := &walkFilter{root: root, files: []string{ignoreFileName}}
+	if pa.Gitignore {
+		wf.files = []string{gitignoreName, ignoreFileName}
+	}
+	for _, pattern := range pa.Exclude {
+		if rule, ok := parseIgnoreRule(pattern); ok {
+			wf.excludes.rules = append(wf.excludes.rules, rule)
+		}
+	}
+	if abs, err := filepath.Abs(root); err == nil {
+		var parents []ignoreSet
+		prefix := ""
+		for dir := abs; !isRepositoryRoot(dir); {
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				parents = nil // hors d'un dépôt : seuls les fichiers du dossier parcouru
+				break
+			}
+			prefix = path.Join(filepath.Base(dir), prefix)
+			dir = parent
+			parents = append(parents, ignoreSet{prefix: prefix, rules: wf.read(dir)})
+		}
+		for i := len(parents) - 1; i >= 0; i-- {
+			wf.sets = append(wf.sets, parents[i])
+		}
+	}
+	wf.enter(root)
+	return wf
+}
+
+// isRepositoryRoot indique si dir est la racine d'un dépôt git.
+func isRepositoryRoot(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// read retourne les motifs des fichiers d'exclusion d'un dossier.
+func (wf *walkFilter) read(dir string) []ignoreRule {
+	var rules []ignoreRule
+	for _, name := range wf.files {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if rule, ok := parseIgnoreRule(scanner.Text()); ok {
+				rules = append(rules, rule)
+			}
+		}
+		f.Close()
+	}
+	return rules
+}
+
+// enter lit les fichiers d'exclusion d'un dossier retenu par le parcours.
+func (wf *walkFilter) enter(dir string) {
+	if rules := wf.read(dir); len(rules) > 0 {
+		wf.sets = append(wf.sets, ignoreSet{dir: wf.rel(dir), rules: rules})
+	}
+}
+
+// rel retourne le chemin d'un fichier du parcours relatif à sa racine, avec des "/".
+func (wf *walkFilter) rel(path string) string {
+	rel, _ := filepath.Rel(wf.root, path)
+	if rel == "." {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
+
+// ignored indique si un chemin rencontré lors du parcours est ignoré : le dernier motif
+// qui s'y applique l'emporte.
+func (wf *walkFilter) ignored(path string, isDir bool) bool {
+	rel := wf.rel(path)
+	ignored := false
+	for _, set := range wf.sets {
+		ignored = set.apply(rel, isDir, ignored)
+	}
+	return wf.excludes.apply(rel, isDir, ignored)
+}
+
+// apply retourne l'état d'un chemin après les motifs de l'ensemble, ignored étant son
+// état d'après les motifs précédents.
+func (s ignoreSet) apply(rel string, isDir, ignored bool) bool {
+	local, ok := s.relative(rel)
+	if !ok {
+		return ignored
+	}
+	for _, rule := range s.rules {
+		if rule.match(local, isDir) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
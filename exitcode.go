@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// Codes de sortie du programme, pour que les scripts puissent conditionner leur suite
+// aux résultats de l'analyse.
+const (
+	exitClean          = 0 // aucun résultat
+	exitFindings       = 1 // résultats de sévérité supérieure ou égale au seuil -fail-on
+	exitError          = 2 // erreur d'analyse ou d'utilisation, résultats incomplets
+	exitBelowThreshold = 3 // uniquement des résultats sous le seuil -fail-on
+)
+
+// scanOutcome compte les résultats signalés et les fichiers en erreur d'une commande,
+// pour en déduire son code de sortie. Il est partagé par les analyseurs d'un même scan.
+type scanOutcome struct {
+	mu       sync.Mutex
+	findings int
+	failing  int
+	errors   int
+	reported int // résultats signalés sous la limite -max-findings
+	omitted  int // résultats écartés par la limite -max-findings
+
+	// Empreintes des résultats déjà signalés (dedupFindings).
+	seen       map[string]bool
+	duplicates int
+
+	// Statistiques du résumé de fin de scan (summary.go).
+	start      time.Time
+	cpu        time.Duration
+	scans      int // parcours de fichiers effectués par la commande
+	files      int
+	skipped    int
+	bySeverity map[Severity]int // résultats signalés
+	byRule     map[string]int
+	summarized bool
+}
+
+// newScanOutcome prépare les compteurs d'une commande qui commence.
+func newScanOutcome() *scanOutcome {
+	return &scanOutcome{start: time.Now(), cpu: cpuTime(), bySeverity: map[Severity]int{}, byRule: map[string]int{}, seen: map[string]bool{}}
+}
+
+// parseFailOn lit le seuil de l'option -fail-on.
+func parseFailOn(analyzer *PHPAnalyzer, name string) error {
+	severity, err := ParseSeverity(name)
+	if err != nil {
+		return fmt.Errorf("option -fail-on : %v", err)
+	}
+	analyzer.FailOn = severity
+	return nil
+}
+
+// recordFindings compte les résultats signalés pour un fichier.
+func (pa *PHPAnalyzer) recordFindings(findings []Finding) {
+	if pa.outcome == nil {
+		return
+	}
+	pa.outcome.mu.Lock()
+	defer pa.outcome.mu.Unlock()
+	for _, f := range findings {
+		pa.outcome.findings++
+		if f.Severity >= pa.FailOn {
+			pa.outcome.failing++
+		}
+	}
+}
+
+// fileError signale un fichier qui n'a pas pu être analysé ; le scan continue mais se
+// termine avec le code exitError.
+func (pa *PHPAnalyzer) fileError(format string, args ...any) {
+	log.Printf(format, args...)
+	if pa.outcome == nil {
+		return
+	}
+	pa.outcome.mu.Lock()
+	defer pa.outcome.mu.Unlock()
+	pa.outcome.errors++
+}
+
+// exitCode retourne le code de sortie de la dernière commande exécutée, err étant
+// l'erreur qu'elle a retournée.
+func (pa *PHPAnalyzer) exitCode(err error) int {
+	if err != nil {
+		return exitError
+	}
+	if pa.outcome == nil {
+		return exitClean
+	}
+	pa.outcome.mu.Lock()
+	defer pa.outcome.mu.Unlock()
+	switch {
+	case pa.outcome.errors > 0:
+		return exitError
+	case pa.outcome.failing > 0:
+		return exitFindings
+	case pa.outcome.findings > 0:
+		return exitBelowThreshold
+	}
+	return exitClean
+}
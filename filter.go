@@ -0,0 +1,97 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+)
+
+// filterFlags regroupe les options qui restreignent les résultats signalés par cve,
+// analyze-dir et dbcalls, quel que soit le format de sortie.
+type filterFlags struct {
+	minSeverity string
+	maxFindings int
+}
+
+func addFilterFlags(fs *flag.FlagSet) *filterFlags {
+	flags := &filterFlags{}
+	fs.StringVar(&flags.minSeverity, "min-severity", "info", "Sévérité minimale des résultats signalés")
+	fs.IntVar(&flags.maxFindings, "max-findings", 0, "Nombre maximal de résultats signalés (0 = aucune limite)")
+	return flags
+}
+
+// applyFilters configure l'analyseur d'après les options de filtrage.
+func applyFilters(analyzer *PHPAnalyzer, flags *filterFlags) error {
+	severity, err := ParseSeverity(flags.minSeverity)
+	if err != nil {
+		return fmt.Errorf("option -min-severity : %v", err)
+	}
+	if flags.maxFindings < 0 {
+		return fmt.Errorf("option -max-findings : %d n'est pas un nombre de résultats", flags.maxFindings)
+	}
+	analyzer.MinSeverity = severity
+	analyzer.MaxFindings = flags.maxFindings
+	return nil
+}
+
+// filterSeverity retire les résultats sous la sévérité minimale.
+func (pa *PHPAnalyzer) filterSeverity(findings []Finding) []Finding {
+	var kept []Finding
+	for _, f := range findings {
+		if f.Severity >= pa.MinSeverity {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
+
+// limitFindings retourne les résultats d'un fichier qui restent à signaler sous la limite
+// -max-findings, en comptant les autres. Appelé dans l'ordre des fichiers, il retient les
+// premiers résultats du parcours, qui sont comptés pour le résumé du scan.
+func (pa *PHPAnalyzer) limitFindings(findings []Finding) []Finding {
+	if pa.outcome == nil {
+		return findings
+	}
+	pa.outcome.mu.Lock()
+	defer pa.outcome.mu.Unlock()
+	kept := len(findings)
+	if pa.MaxFindings > 0 {
+		kept = min(kept, max(pa.MaxFindings-pa.outcome.reported, 0))
+	}
+	pa.outcome.reported += kept
+	pa.outcome.omitted += len(findings) - kept
+	for _, f := range findings[:kept] {
+		pa.outcome.bySeverity[f.Severity]++
+		pa.outcome.byRule[f.RuleID]++
+	}
+	return findings[:kept]
+}
+
+// dedupFindings retire les résultats dont l'empreinte a déjà été signalée : un fichier
+// atteint par un lien symbolique ou par plusieurs chemins d'un scan n'est signalé qu'une
+// fois. Appelé dans l'ordre des fichiers, comme limitFindings.
+func (pa *PHPAnalyzer) dedupFindings(findings []Finding) []Finding {
+	if pa.outcome == nil || len(findings) == 0 {
+		return findings
+	}
+	pa.outcome.mu.Lock()
+	defer pa.outcome.mu.Unlock()
+	var kept []Finding
+	for _, f := range findings {
+		if f.Fingerprint != "" && pa.outcome.seen[f.Fingerprint] {
+			pa.outcome.duplicates++
+			continue
+		}
+		pa.outcome.seen[f.Fingerprint] = true
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// writeOmitted signale les résultats écartés par la limite -max-findings.
+func (pa *PHPAnalyzer) writeOmitted(out io.Writer) {
+	if pa.outcome == nil || pa.outcome.omitted == 0 {
+		return
+	}
+	fmt.Fprintf(pa.messages(out), "%d résultat(s) supplémentaire(s) non signalé(s) (limite -max-findings=%d).\n", pa.outcome.omitted, pa.MaxFindings)
+}
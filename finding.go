@@ -0,0 +1,410 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Severity est la gravité d'un résultat, de info à critical. blocker n'est attribuée par
+// aucune règle : elle sert aux projets qui surchargent la sévérité d'une règle pour en
+// faire un critère bloquant.
+type Severity int
+
+const (
+	SeverityInfo Severity = iota
+	SeverityLow
+	SeverityMedium
+	SeverityHigh
+	SeverityCritical
+	SeverityBlocker
+)
+
+var severityNames = []string{"info", "low", "medium", "high", "critical", "blocker"}
+
+func (s Severity) String() string {
+	if s < SeverityInfo || s > SeverityBlocker {
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+	return severityNames[s]
+}
+
+// ParseSeverity convertit un nom de sévérité (info, low, medium, high, critical, blocker).
+func ParseSeverity(name string) (Severity, error) {
+	for i, n := range severityNames {
+		if strings.EqualFold(name, n) {
+			return Severity(i), nil
+		}
+	}
+	return SeverityInfo, fmt.Errorf("sévérité inconnue : %q", name)
+}
+
+// Confidence est la probabilité qu'un résultat soit un vrai positif.
+type Confidence int
+
+const (
+	ConfidenceLow Confidence = iota
+	ConfidenceMedium
+	ConfidenceHigh
+)
+
+var confidenceNames = []string{"low", "medium", "high"}
+
+func (c Confidence) String() string {
+	if c < ConfidenceLow || c > ConfidenceHigh {
+		return fmt.Sprintf("Confidence(%d)", int(c))
+	}
+	return confidenceNames[c]
+}
+
+// ParseConfidence convertit un nom de confiance (low, medium, high).
+func ParseConfidence(name string) (Confidence, error) {
+	for i, n := range confidenceNames {
+		if strings.EqualFold(name, n) {
+			return Confidence(i), nil
+		}
+	}
+	return ConfidenceLow, fmt.Errorf("confiance inconnue : %q", name)
+}
+
+// Finding est un résultat d'analyse : vulnérabilité, mauvaise pratique ou simple
+// inventaire (appel à la base de données).
+type Finding struct {
+	RuleID     string
+	CWE        string
+	Severity   Severity
+	Confidence Confidence
+	Line       uint32
+	Column     uint32 // colonne du nœud signalé (à partir de 1), 0 si elle n'est pas connue
+	EndLine    uint32 // ligne et colonne suivant la fin du nœud signalé, lorsque Column est connue
+	EndColumn  uint32
+	StartByte  uint32 // position du nœud signalé dans la source, en octets
+	EndByte    uint32
+	Message    string
+	Function   string            // fonction ou méthode concernée, lorsque la règle en désigne une
+	SQL        *SQLStatement     // instruction exécutée par un appel à la base, lorsqu'elle est résolue
+	Query      QueryConstruction // construction de la requête d'un appel à la base
+
+	// Métadonnées de la règle : catégorie OWASP Top 10, liens de référence et correction
+	// recommandée.
+	OWASP       string
+	References  []string
+	Remediation string
+
+	// Blame est la dernière modification de la ligne signalée (-blame), nil sinon.
+	Blame *BlameInfo
+
+	// Fingerprint identifie le résultat indépendamment de sa ligne (règle, code signalé
+	// et chemin relatif) : c'est la clé de la baseline, écrite dans les sorties JSON et
+	// JUnit. Vide tant qu'elle n'est pas calculée (assignFingerprints).
+	Fingerprint string
+}
+
+// Label retourne l'identifiant de la règle suivi de sa CWE, par exemple "sqli / CWE-89".
+func (f Finding) Label() string {
+	if f.CWE == "" {
+		return f.RuleID
+	}
+	return f.RuleID + " / " + f.CWE
+}
+
+// findingLess ordonne les résultats d'un fichier par position, puis par règle et par
+// message : l'ordre d'un rapport ne dépend ni de l'ordre des détecteurs ni de celui de
+// leurs parcours.
+func findingLess(a, b Finding) bool {
+	if a.Line != b.Line {
+		return a.Line < b.Line
+	}
+	if a.Column != b.Column {
+		return a.Column < b.Column
+	}
+	if a.RuleID != b.RuleID {
+		return a.RuleID < b.RuleID
+	}
+	return a.Message < b.Message
+}
+
+// Catégories OWASP Top 10 (2021) associées aux règles.
+const (
+	owaspCrypto         = "A02:2021 - Cryptographic Failures"
+	owaspInjection      = "A03:2021 - Injection"
+	owaspInsecureDesign = "A04:2021 - Insecure Design"
+	owaspMisconfig      = "A05:2021 - Security Misconfiguration"
+	owaspAuth           = "A07:2021 - Identification and Authentication Failures"
+	owaspIntegrity      = "A08:2021 - Software and Data Integrity Failures"
+)
+
+// cheatSheet retourne l'adresse d'une fiche OWASP Cheat Sheet Series.
+func cheatSheet(name string) string {
+	return "https://cheatsheetseries.owasp.org/cheatsheets/" + name + "_Cheat_Sheet.html"
+}
+
+// Rule décrit les valeurs par défaut et les métadonnées des résultats produits par une
+// règle.
+type Rule struct {
+	ID          string
+	CWE         string
+	Severity    Severity
+	Confidence  Confidence
+	Description string
+	OWASP       string
+	References  []string
+	Remediation string
+	Example     RuleExample
+}
+
+// RuleExample illustre une règle par un extrait de code vulnérable et sa correction,
+// affichés par "rules describe".
+type RuleExample struct {
+	Vulnerable string `yaml:"vulnerable"`
+	Fixed      string `yaml:"fixed"`
+}
+
+// builtinRules référence les règles codées dans l'analyseur, indexées par identifiant.
+// Les signatures de CVE sont décrites dans les fichiers de règles YAML (rules.go).
+var builtinRules = map[string]Rule{
+	"xxe": {
+		ID: "xxe", CWE: "CWE-611", Severity: SeverityHigh, Confidence: ConfidenceHigh,
+		OWASP: owaspMisconfig, References: []string{cheatSheet("XML_External_Entity_Prevention")},
+		Description: "Chargement XML avec résolution des entités externes.",
+		Remediation: "Désactiver le chargement des entités externes (LIBXML_NONET, sans LIBXML_NOENT ni libxml_disable_entity_loader(false)).",
+		Example: RuleExample{
+			Vulnerable: "$doc->loadXML($xml, LIBXML_NOENT);",
+			Fixed:      "$doc->loadXML($xml, LIBXML_NONET);",
+		},
+	},
+	"insecure-cookie": {
+		ID: "insecure-cookie", CWE: "CWE-614", Severity: SeverityLow, Confidence: ConfidenceHigh,
+		OWASP: owaspMisconfig, References: []string{cheatSheet("Session_Management")},
+		Description: "Cookie ou cookie de session sans les attributs secure, httponly ou samesite.",
+		Remediation: "Passer secure, httponly et samesite à setcookie() ou session_set_cookie_params().",
+		Example: RuleExample{
+			Vulnerable: "setcookie('token', $token);",
+			Fixed:      "setcookie('token', $token, ['secure' => true, 'httponly' => true, 'samesite' => 'Lax']);",
+		},
+	},
+	"session-fixation": {
+		ID: "session-fixation", CWE: "CWE-384", Severity: SeverityHigh, Confidence: ConfidenceHigh,
+		OWASP: owaspAuth, References: []string{cheatSheet("Session_Management")},
+		Description: "Identifiant de session fixé à partir d'une donnée de l'utilisateur.",
+		Remediation: "Ne jamais accepter un identifiant de session fourni par l'utilisateur ; laisser PHP le générer.",
+		Example: RuleExample{
+			Vulnerable: "session_id($_GET['sid']);\nsession_start();",
+			Fixed:      "session_start();",
+		},
+	},
+	"session-regeneration": {
+		ID: "session-regeneration", CWE: "CWE-384", Severity: SeverityMedium, Confidence: ConfidenceMedium,
+		OWASP: owaspAuth, References: []string{cheatSheet("Session_Management")},
+		Description: "Connexion sans régénération de l'identifiant de session.",
+		Remediation: "Appeler session_regenerate_id(true) après l'authentification.",
+		Example: RuleExample{
+			Vulnerable: "if (password_verify($password, $user['hash'])) {\n    $_SESSION['user'] = $user;\n}",
+			Fixed:      "if (password_verify($password, $user['hash'])) {\n    session_regenerate_id(true);\n    $_SESSION['user'] = $user;\n}",
+		},
+	},
+	"unsafe-upload": {
+		ID: "unsafe-upload", CWE: "CWE-434", Severity: SeverityHigh, Confidence: ConfidenceMedium,
+		OWASP: owaspInsecureDesign, References: []string{cheatSheet("File_Upload")},
+		Description: "Fichier téléversé déplacé ou copié sans contrôle, sous le nom choisi par le client.",
+		Remediation: "Déplacer les fichiers avec move_uploaded_file() vers un nom généré, hors de la racine web, après vérification du type.",
+		Example: RuleExample{
+			Vulnerable: "copy($_FILES['f']['tmp_name'], 'uploads/' . $_FILES['f']['name']);",
+			Fixed:      "move_uploaded_file($_FILES['f']['tmp_name'], $storage . '/' . bin2hex(random_bytes(16)));",
+		},
+	},
+	"insecure-config": {
+		ID: "insecure-config", CWE: "CWE-16", Severity: SeverityMedium, Confidence: ConfidenceHigh,
+		OWASP: owaspMisconfig, References: []string{cheatSheet("PHP_Configuration")},
+		Description: "Directive PHP sensible modifiée à l'exécution avec une valeur non sûre.",
+		Remediation: "Configurer ces directives dans php.ini avec des valeurs sûres plutôt qu'à l'exécution.",
+		Example: RuleExample{
+			Vulnerable: "ini_set('allow_url_include', '1');",
+			Fixed:      "// allow_url_include = Off dans php.ini",
+		},
+	},
+	"register-globals": {
+		ID: "register-globals", CWE: "CWE-621", Severity: SeverityHigh, Confidence: ConfidenceHigh,
+		OWASP: owaspMisconfig, References: []string{cheatSheet("PHP_Configuration")},
+		Description: "Import des entrées de la requête comme variables (extract, import_request_variables).",
+		Remediation: "Lire explicitement les entrées dans $_GET, $_POST ou $_COOKIE au lieu de les importer comme variables.",
+		Example: RuleExample{
+			Vulnerable: "extract($_GET);",
+			Fixed:      "$page = $_GET['page'] ?? 'accueil';",
+		},
+	},
+	"error-disclosure": {
+		ID: "error-disclosure", CWE: "CWE-209", Severity: SeverityLow, Confidence: ConfidenceMedium,
+		OWASP: owaspInsecureDesign, References: []string{cheatSheet("Error_Handling")},
+		Description: "Affichage des erreurs ou de détails techniques à l'utilisateur.",
+		Remediation: "Journaliser les erreurs (log_errors) et désactiver display_errors en production.",
+		Example: RuleExample{
+			Vulnerable: "ini_set('display_errors', '1');\necho $e->getMessage();",
+			Fixed:      "error_log($e->getMessage());\necho 'Une erreur est survenue.';",
+		},
+	},
+	"debug-leftover": {
+		ID: "debug-leftover", CWE: "CWE-489", Severity: SeverityLow, Confidence: ConfidenceMedium,
+		OWASP: owaspMisconfig, References: []string{cheatSheet("Error_Handling")},
+		Description: "Trace de débogage oubliée (var_dump, print_r, phpinfo...).",
+		Remediation: "Retirer les traces de débogage avant la mise en production.",
+		Example: RuleExample{
+			Vulnerable: "var_dump($user);",
+			Fixed:      "$logger->debug('utilisateur', ['id' => $user->id]);",
+		},
+	},
+	"sqli": {
+		ID: "sqli", CWE: "CWE-89", Severity: SeverityCritical, Confidence: ConfidenceHigh,
+		OWASP: owaspInjection, References: []string{cheatSheet("SQL_Injection_Prevention"), cheatSheet("Query_Parameterization")},
+		Description: "Requête SQL contenant une donnée contaminée par l'utilisateur.",
+		Remediation: "Utiliser des requêtes préparées avec des paramètres liés.",
+		Example: RuleExample{
+			Vulnerable: "mysqli_query($db, \"SELECT * FROM users WHERE id = \" . $_GET['id']);",
+			Fixed:      "$stmt = $db->prepare('SELECT * FROM users WHERE id = ?');\n$stmt->bind_param('i', $_GET['id']);\n$stmt->execute();",
+		},
+	},
+	"sqli-concat": {
+		ID: "sqli-concat", CWE: "CWE-89", Severity: SeverityHigh, Confidence: ConfidenceMedium,
+		OWASP: owaspInjection, References: []string{cheatSheet("SQL_Injection_Prevention"), cheatSheet("Query_Parameterization")},
+		Description: "Requête SQL construite par concaténation ou interpolation.",
+		Remediation: "Utiliser des requêtes préparées avec des paramètres liés.",
+		Example: RuleExample{
+			Vulnerable: "mysqli_query($db, \"SELECT * FROM users WHERE id = $id\");",
+			Fixed:      "$stmt = $db->prepare('SELECT * FROM users WHERE id = ?');\n$stmt->bind_param('i', $id);\n$stmt->execute();",
+		},
+	},
+	"xss": {
+		ID: "xss", CWE: "CWE-79", Severity: SeverityHigh, Confidence: ConfidenceHigh,
+		OWASP: owaspInjection, References: []string{cheatSheet("Cross_Site_Scripting_Prevention")},
+		Description: "Donnée contaminée affichée sans échappement.",
+		Remediation: "Échapper la valeur à l'affichage avec htmlspecialchars($v, ENT_QUOTES) ou l'échappement du moteur de gabarits.",
+		Example: RuleExample{
+			Vulnerable: "echo 'Bonjour ' . $_GET['name'];",
+			Fixed:      "echo 'Bonjour ' . htmlspecialchars($_GET['name'], ENT_QUOTES);",
+		},
+	},
+	"type-juggling": {
+		ID: "type-juggling", CWE: "CWE-697", Severity: SeverityMedium, Confidence: ConfidenceMedium,
+		OWASP: owaspAuth, References: []string{"https://www.php.net/manual/fr/language.operators.comparison.php"},
+		Description: "Comparaison lâche (==) d'un jeton, d'un mot de passe ou d'un condensat.",
+		Remediation: "Comparer avec === ou hash_equals() pour les jetons et condensats.",
+		Example: RuleExample{
+			Vulnerable: "if ($token == $_POST['token']) {",
+			Fixed:      "if (hash_equals($token, $_POST['token'])) {",
+		},
+	},
+	"static-iv": {
+		ID: "static-iv", CWE: "CWE-1204", Severity: SeverityHigh, Confidence: ConfidenceHigh,
+		OWASP: owaspCrypto, References: []string{cheatSheet("Cryptographic_Storage")},
+		Description: "IV ou nonce constant passé à une fonction de chiffrement.",
+		Remediation: "Générer un IV ou nonce aléatoire par message avec random_bytes() et le transmettre avec le chiffré.",
+		Example: RuleExample{
+			Vulnerable: "openssl_encrypt($data, 'aes-256-gcm', $key, 0, '000000000000', $tag);",
+			Fixed:      "$iv = random_bytes(12);\nopenssl_encrypt($data, 'aes-256-gcm', $key, 0, $iv, $tag);",
+		},
+	},
+	"weak-cipher": {
+		ID: "weak-cipher", CWE: "CWE-327", Severity: SeverityMedium, Confidence: ConfidenceHigh,
+		OWASP: owaspCrypto, References: []string{cheatSheet("Cryptographic_Storage")},
+		Description: "Algorithme ou mode de chiffrement faible (DES, RC4, ECB...).",
+		Remediation: "Utiliser un chiffrement authentifié (aes-256-gcm ou sodium_crypto_aead_*).",
+		Example: RuleExample{
+			Vulnerable: "openssl_encrypt($data, 'des-ecb', $key);",
+			Fixed:      "openssl_encrypt($data, 'aes-256-gcm', $key, 0, $iv, $tag);",
+		},
+	},
+	"phar-deserialization": {
+		ID: "phar-deserialization", CWE: "CWE-502", Severity: SeverityHigh, Confidence: ConfidenceMedium,
+		OWASP: owaspIntegrity, References: []string{cheatSheet("Deserialization")},
+		Description: "Chemin contaminé passé à une fonction de fichiers, exploitable avec le wrapper phar://.",
+		Remediation: "Refuser les chemins fournis par l'utilisateur ou en retirer le wrapper (basename(), liste blanche).",
+		Example: RuleExample{
+			Vulnerable: "file_exists($_GET['path']);",
+			Fixed:      "file_exists($uploads . '/' . basename($_GET['path']));",
+		},
+	},
+	"metrics": {
+		ID: "metrics", Severity: SeverityInfo, Confidence: ConfidenceHigh,
+		Description: "Nombre de branchements et complexité cyclomatique du fichier.",
+	},
+	"transaction-unclosed": {
+		ID: "transaction-unclosed", CWE: "CWE-404", Severity: SeverityMedium, Confidence: ConfidenceMedium,
+		OWASP:       owaspInsecureDesign,
+		Description: "Transaction ouverte qui reste sans commit ni rollback sur un chemin menant à la sortie de la fonction.",
+		Remediation: "Terminer la transaction sur tous les chemins : commit en fin de traitement, rollBack dans un catch ou un finally.",
+		Example: RuleExample{
+			Vulnerable: "$pdo->beginTransaction();\nif (!$stock->reserve($id)) {\n    return false;\n}\n$pdo->commit();",
+			Fixed:      "$pdo->beginTransaction();\nif (!$stock->reserve($id)) {\n    $pdo->rollBack();\n    return false;\n}\n$pdo->commit();",
+		},
+	},
+	"unused-variable": {
+		ID: "unused-variable", CWE: "CWE-563", Severity: SeverityInfo, Confidence: ConfidenceMedium,
+		Description: "Variable affectée dans une fonction mais jamais lue.",
+		Remediation: "Supprimer l'affectation, ou utiliser la variable si elle devait l'être.",
+		Example: RuleExample{
+			Vulnerable: "function total($items) {\n    $count = count($items);\n    return array_sum($items);\n}",
+			Fixed:      "function total($items) {\n    return array_sum($items);\n}",
+		},
+	},
+	"unused-import": {
+		ID: "unused-import", CWE: "CWE-1164", Severity: SeverityInfo, Confidence: ConfidenceMedium,
+		Description: "Import (use) dont le nom n'est jamais utilisé par le fichier.",
+		Remediation: "Supprimer l'import.",
+		Example: RuleExample{
+			Vulnerable: "use App\\Models\\User;\nuse App\\Models\\Post;\n\nfunction author(Post $post) {\n    return $post->author;\n}",
+			Fixed:      "use App\\Models\\Post;\n\nfunction author(Post $post) {\n    return $post->author;\n}",
+		},
+	},
+	"dbcall": {
+		ID: "dbcall", Severity: SeverityInfo, Confidence: ConfidenceHigh,
+		Description: "Inventaire des appels à la base de données.",
+	},
+}
+
+// newFinding crée un résultat de la règle ruleID avec la CWE, la sévérité, la confiance
+// et les métadonnées déclarées dans builtinRules.
+func newFinding(ruleID string, line uint32, message string) Finding {
+	rule := builtinRules[ruleID]
+	return Finding{
+		RuleID:      ruleID,
+		CWE:         rule.CWE,
+		Severity:    rule.Severity,
+		Confidence:  rule.Confidence,
+		Line:        line,
+		Message:     message,
+		OWASP:       rule.OWASP,
+		References:  rule.References,
+		Remediation: rule.Remediation,
+	}
+}
+
+// dbCallFinding crée l'entrée d'inventaire d'un appel à la base de données, complétée
+// par l'instruction SQL qu'il exécute lorsqu'elle est résolue (statement non nil) et par
+// la construction de sa requête. Une requête concaténée passe en sévérité low, le
+// premier signal du triage des injections SQL.
+func dbCallFinding(function string, line uint32, message string, statement *SQLStatement, construction QueryConstruction) Finding {
+	f := newFinding("dbcall", line, message)
+	f.Function = function
+	f.Query = construction
+	if statement != nil {
+		f.SQL = statement
+		f.Message += " (" + statement.String() + ")"
+	}
+	f.Message += " [" + construction.Label() + "]"
+	if construction == QueryConcatenated {
+		f.Severity = SeverityLow
+	}
+	return f
+}
+
+// Apply abaisse la sévérité et la confiance des résultats des règles déclassées par le
+// triage, sans descendre sous info et low.
+func (c Calibration) Apply(findings []Finding) {
+	for i := range findings {
+		levels := c[findings[i].RuleID]
+		if levels <= 0 {
+			continue
+		}
+		findings[i].Severity = max(findings[i].Severity-Severity(levels), SeverityInfo)
+		findings[i].Confidence = max(findings[i].Confidence-Confidence(levels), ConfidenceLow)
+	}
+}
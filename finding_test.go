@@ -0,0 +1,54 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseSeverity(t *testing.T) {
+	severity, err := ParseSeverity("High")
+	assert.NoError(t, err)
+	assert.Equal(t, SeverityHigh, severity)
+	assert.Equal(t, "critical", SeverityCritical.String())
+
+	_, err = ParseSeverity("urgent")
+	assert.Error(t, err)
+
+	confidence, err := ParseConfidence("medium")
+	assert.NoError(t, err)
+	assert.Equal(t, ConfidenceMedium, confidence)
+}
+
+func TestFindingCarriesRuleMetadata(t *testing.T) {
+	detections := detect(t, `<?php
+mysql_query("SELECT * FROM users WHERE id=" . $id);
+iconv_mime_decode_headers($headers);`)
+
+	assert.Len(t, detections, 2)
+	assert.Equal(t, "sqli-concat", detections[0].RuleID)
+	assert.Equal(t, SeverityHigh, detections[0].Severity)
+	assert.Equal(t, ConfidenceMedium, detections[0].Confidence)
+	assert.Equal(t, "CVE-2019-11039", detections[1].RuleID)
+	assert.Equal(t, "CWE-125", detections[1].CWE)
+	assert.Equal(t, ConfidenceLow, detections[1].Confidence)
+	assert.Equal(t, "A03:2021 - Injection", detections[0].OWASP)
+	assert.NotEmpty(t, detections[0].Remediation)
+	assert.Equal(t, "A06:2021 - Vulnerable and Outdated Components", detections[1].OWASP)
+	assert.Equal(t, []string{"https://nvd.nist.gov/vuln/detail/CVE-2019-11039"}, detections[1].References)
+}
+
+func TestCalibrationDowngradesFindings(t *testing.T) {
+	findings := []Finding{
+		newFinding("sqli", 1, ""),
+		newFinding("insecure-cookie", 2, ""),
+		newFinding("xss", 3, ""),
+	}
+	Calibration{"sqli": 1, "insecure-cookie": 2}.Apply(findings)
+
+	assert.Equal(t, SeverityHigh, findings[0].Severity)
+	assert.Equal(t, ConfidenceMedium, findings[0].Confidence)
+	assert.Equal(t, SeverityInfo, findings[1].Severity, "severity never drops below info")
+	assert.Equal(t, ConfidenceLow, findings[1].Confidence)
+	assert.Equal(t, SeverityHigh, findings[2].Severity, "rules absent from the calibration are untouched")
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/php"
+)
+
+// Suffixes des fichiers intermédiaires créés à côté des fichiers modifiés.
+const (
+	tempSuffix   = ".phpanalyzer-tmp"
+	backupSuffix = ".phpanalyzer-bak"
+)
+
+// FileEdit représente le nouveau contenu complet à écrire dans un fichier.
+type FileEdit struct {
+	Path    string
+	Content []byte
+}
+
+// journalEntry décrit l'état d'un fichier pendant une transaction, afin de pouvoir
+// restaurer l'original si l'exécution est interrompue. Created indique un fichier créé
+// par la transaction, sans original à sauvegarder : il est supprimé par la restauration.
+type journalEntry struct {
+	Path    string `json:"path"`
+	Temp    string `json:"temp"`
+	Backup  string `json:"backup"`
+	Created bool   `json:"created,omitempty"`
+}
+
+// ApplyEdits applique un ensemble de modifications de façon transactionnelle :
+// chaque nouveau contenu doit d'abord être re-parsé sans erreur, puis il est écrit
+// dans un fichier temporaire, les originaux sont sauvegardés et les fichiers temporaires
+// sont renommés à leur place. En cas d'échec, tous les fichiers sont restaurés.
+// Le journal (journalPath) permet de récupérer un état cohérent avec RecoverEdits
+// si le processus est interrompu.
+func ApplyEdits(edits []FileEdit, journalPath string) error {
+	parser := sitter.NewParser()
+	parser.SetLanguage(php.GetLanguage())
+	for _, edit := range edits {
+		tree, err := parser.ParseCtx(context.Background(), nil, edit.Content)
+		if err != nil {
+			return fmt.Errorf("parsing %q: %w", edit.Path, err)
+		}
+		if tree.RootNode().HasError() {
+			return fmt.Errorf("le résultat pour %q ne se parse pas correctement", edit.Path)
+		}
+	}
+
+	var entries []journalEntry
+	for _, edit := range edits {
+		_, err := os.Stat(edit.Path)
+		entries = append(entries, journalEntry{
+			Path:    edit.Path,
+			Temp:    edit.Path + tempSuffix,
+			Backup:  edit.Path + backupSuffix,
+			Created: errors.Is(err, os.ErrNotExist),
+		})
+	}
+	if err := writeJournal(journalPath, entries); err != nil {
+		return err
+	}
+
+	for i, edit := range edits {
+		if err := writeTempFile(entries[i], edit.Content); err != nil {
+			rollback(entries)
+			os.Remove(journalPath)
+			return err
+		}
+	}
+	for _, entry := range entries {
+		if err := os.Rename(entry.Temp, entry.Path); err != nil {
+			rollback(entries)
+			os.Remove(journalPath)
+			return fmt.Errorf("remplacement de %q: %w", entry.Path, err)
+		}
+	}
+
+	// Le journal est supprimé avant les sauvegardes : une interruption entre les deux
+	// laisse des sauvegardes inutiles, mais jamais un journal qui en désigne d'absentes.
+	if err := os.Remove(journalPath); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		os.Remove(entry.Backup)
+	}
+	return nil
+}
+
+// RecoverEdits restaure les fichiers d'une transaction interrompue à partir du journal.
+// Aucun traitement n'est effectué si le journal n'existe pas.
+func RecoverEdits(journalPath string) error {
+	data, err := os.ReadFile(journalPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var entries []journalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("journal %q invalide: %w", journalPath, err)
+	}
+	rollback(entries)
+	return os.Remove(journalPath)
+}
+
+func writeJournal(journalPath string, entries []journalEntry) error {
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(journalPath, data, 0o644)
+}
+
+// writeTempFile sauvegarde l'original puis écrit le nouveau contenu dans le fichier
+// temporaire, en conservant les permissions du fichier d'origine.
+func writeTempFile(entry journalEntry, content []byte) error {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(entry.Path); err == nil && !entry.Created {
+		mode = info.Mode().Perm()
+		if err := copyFile(entry.Path, entry.Backup, mode); err != nil {
+			return fmt.Errorf("sauvegarde de %q: %w", entry.Path, err)
+		}
+	}
+	if err := os.WriteFile(entry.Temp, content, mode); err != nil {
+		return fmt.Errorf("écriture de %q: %w", entry.Temp, err)
+	}
+	return nil
+}
+
+// rollback restaure chaque original sauvegardé, supprime les fichiers créés par la
+// transaction et les fichiers temporaires.
+func rollback(entries []journalEntry) {
+	for _, entry := range entries {
+		if entry.Created {
+			os.Remove(entry.Path)
+		} else if _, err := os.Stat(entry.Backup); err == nil {
+			os.Rename(entry.Backup, entry.Path)
+		}
+		os.Remove(entry.Temp)
+	}
+}
+
+func copyFile(src, dst string, mode os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyEdits(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.php")
+	b := filepath.Join(dir, "b.php")
+	assert.NoError(t, os.WriteFile(a, []byte("<?php echo 1;"), 0o600))
+	assert.NoError(t, os.WriteFile(b, []byte("<?php echo 2;"), 0o644))
+	journal := filepath.Join(dir, "journal.json")
+
+	err := ApplyEdits([]FileEdit{
+		{Path: a, Content: []byte("<?php echo 10;")},
+		{Path: b, Content: []byte("<?php echo 20;")},
+	}, journal)
+	assert.NoError(t, err)
+
+	content, _ := os.ReadFile(a)
+	assert.Equal(t, "<?php echo 10;", string(content))
+	content, _ = os.ReadFile(b)
+	assert.Equal(t, "<?php echo 20;", string(content))
+
+	info, _ := os.Stat(a)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "permissions should be preserved")
+	assert.NoFileExists(t, journal)
+	assert.NoFileExists(t, a+backupSuffix)
+
+	c := filepath.Join(dir, "c.php")
+	assert.NoError(t, ApplyEdits([]FileEdit{{Path: c, Content: []byte("<?php echo 30;")}}, journal))
+	content, _ = os.ReadFile(c)
+	assert.Equal(t, "<?php echo 30;", string(content), "edits may create files")
+	assert.NoFileExists(t, journal)
+}
+
+func TestApplyEditsRejectsInvalidResult(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.php")
+	b := filepath.Join(dir, "b.php")
+	assert.NoError(t, os.WriteFile(a, []byte("<?php echo 1;"), 0o644))
+	assert.NoError(t, os.WriteFile(b, []byte("<?php echo 2;"), 0o644))
+
+	err := ApplyEdits([]FileEdit{
+		{Path: a, Content: []byte("<?php echo 10;")},
+		{Path: b, Content: []byte("<?php echo (;")},
+	}, filepath.Join(dir, "journal.json"))
+	assert.Error(t, err)
+
+	content, _ := os.ReadFile(a)
+	assert.Equal(t, "<?php echo 1;", string(content), "no file should be modified")
+}
+
+func TestRecoverEdits(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a.php")
+	journal := filepath.Join(dir, "journal.json")
+
+	// Simule une exécution interrompue après le remplacement du fichier.
+	assert.NoError(t, os.WriteFile(a+backupSuffix, []byte("<?php echo 1;"), 0o644))
+	assert.NoError(t, os.WriteFile(a, []byte("<?php echo 10;"), 0o644))
+	created := filepath.Join(dir, "created.php")
+	assert.NoError(t, os.WriteFile(created, []byte("<?php echo 3;"), 0o644))
+	assert.NoError(t, writeJournal(journal, []journalEntry{
+		{Path: a, Temp: a + tempSuffix, Backup: a + backupSuffix},
+		{Path: created, Temp: created + tempSuffix, Backup: created + backupSuffix, Created: true},
+	}))
+
+	assert.NoError(t, RecoverEdits(journal))
+	content, _ := os.ReadFile(a)
+	assert.Equal(t, "<?php echo 1;", string(content))
+	assert.NoFileExists(t, created, "files created by the interrupted transaction are removed")
+	assert.NoFileExists(t, journal)
+	assert.NoError(t, RecoverEdits(journal), "recovering without a journal is a no-op")
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github/behouba/log6302A/pkg/phpfmt"
+)
+
+// formatJournal est le journal des réécritures de fmt -w, placé dans le dossier commun
+// des fichiers formatés (formatRoot) : les fichiers d'une exécution interrompue sont
+// restaurés au lancement suivant sur la même cible, quel que soit le dossier courant.
+const formatJournal = ".phpanalyzer-fmt-journal.json"
+
+// formatRoot retourne le plus proche dossier contenant tous les chemins désignés (le
+// dossier parent pour un fichier), en chemin absolu.
+func formatRoot(paths []string) (string, error) {
+	root := ""
+	for _, path := range paths {
+		if path == stdinPath {
+			continue
+		}
+		dir, err := filepath.Abs(path)
+		if err != nil {
+			return "", err
+		}
+		if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+			dir = filepath.Dir(dir)
+		}
+		if root == "" {
+			root = dir
+		}
+		for !containsPath(root, dir) && filepath.Dir(root) != root {
+			root = filepath.Dir(root)
+		}
+	}
+	return root, nil
+}
+
+// containsPath indique si path est root ou l'un de ses descendants.
+func containsPath(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// formatFlags sont les options de mise en forme de la commande fmt.
+type formatFlags struct {
+	indent        *int
+	maxLineLength *int
+	sortImports   *bool
+	alignArrows   *bool
+	braces        *bool
+	expandEcho    *bool
+	maxBlank      *int
+	separateFuncs *bool
+	blankAfterTag *bool
+}
+
+// newPrinter retourne le formateur configuré par les options.
+func (f formatFlags) newPrinter() *phpfmt.Printer {
+	indent := "\t"
+	if *f.indent > 0 {
+		indent = strings.Repeat(" ", *f.indent)
+	}
+	return phpfmt.NewPrinter(phpfmt.Options{
+		Indent:                 indent,
+		MaxLineLength:          *f.maxLineLength,
+		SortImports:            *f.sortImports,
+		AlignArrows:            *f.alignArrows,
+		BraceAlternativeSyntax: *f.braces,
+		ExpandShortEcho:        *f.expandEcho,
+		MaxBlankLines:          *f.maxBlank,
+		SeparateFunctions:      *f.separateFuncs,
+		BlankLineAfterTag:      *f.blankAfterTag,
+	})
+}
+
+// runFormatCommand formate les fichiers PHP désignés : le résultat est écrit sur out ou,
+// avec -w, remplace les fichiers modifiés (écriture dans un fichier temporaire renommé à
+// la place de l'original, dont les permissions sont conservées). Avec -check, rien n'est
+// modifié : le diff des fichiers à formater est écrit sur out et la commande se termine
+// avec le code exitFindings.
+func runFormatCommand(analyzer *PHPAnalyzer, args []string, out io.Writer) error {
+	fmtCmd := newFlagSet("fmt", out)
+	filePath := fmtCmd.String("file", "", "Chemin vers le fichier PHP à formater (- pour l'entrée standard)")
+	dirPath := fmtCmd.String("dir", "", "Dossier à formater récursivement")
+	write := fmtCmd.Bool("w", false, "Réécrit les fichiers modifiés plutôt que d'afficher le résultat")
+	check := fmtCmd.Bool("check", false, "Affiche le diff des fichiers à formater sans les modifier et échoue s'il y en a")
+	lines := fmtCmd.String("lines", "", "Ne formate que les instructions des lignes DÉBUT:FIN d'un seul fichier")
+	flags := formatFlags{
+		indent:        fmtCmd.Int("indent", 4, "Nombre d'espaces par niveau d'indentation (0 = tabulation)"),
+		maxLineLength: fmtCmd.Int("max-line-length", phpfmt.DefaultMaxLineLength, "Largeur au-delà de laquelle les lignes sont coupées (0 = aucune limite)"),
+		sortImports:   fmtCmd.Bool("sort-imports", false, "Trie les déclarations use"),
+		alignArrows:   fmtCmd.Bool("align-arrows", false, "Aligne les => des tableaux sur plusieurs lignes"),
+		braces:        fmtCmd.Bool("braces", false, "Réécrit la syntaxe alternative (if: ... endif;) avec des accolades"),
+		expandEcho:    fmtCmd.Bool("expand-short-echo", false, "Réécrit <?= $x ?> en <?php echo $x; ?>"),
+		maxBlank:      fmtCmd.Int("max-blank-lines", 1, "Nombre maximal de lignes vides consécutives conservées entre les instructions"),
+		separateFuncs: fmtCmd.Bool("separate-functions", true, "Sépare les fonctions et les méthodes consécutives par une ligne vide"),
+		blankAfterTag: fmtCmd.Bool("blank-line-after-tag", false, "Ajoute une ligne vide après la balise <?php"),
+	}
+	excludes := addExcludeFlags(fmtCmd)
+	inputs, err := analyzer.parseCommandLine(fmtCmd, args)
+	if err != nil {
+		return err
+	}
+	if err := applyExcludes(analyzer, excludes); err != nil {
+		return err
+	}
+	analyzer.stdinDefault(filePath, append(inputs, *dirPath)...)
+	paths := scanPaths(*dirPath, scanPaths(*filePath, inputs))
+	if len(paths) == 0 {
+		fmt.Fprintln(out, "Le flag -file ou -dir, ou un chemin, est requis pour la commande fmt.")
+		fmtCmd.Usage()
+		return errUsage
+	}
+	if *write && *check {
+		return fmt.Errorf("Les options -w et -check sont incompatibles.")
+	}
+	var start, end int
+	if *lines != "" {
+		if _, err := fmt.Sscanf(*lines, "%d:%d", &start, &end); err != nil || start < 1 || end < start {
+			return fmt.Errorf("L'option -lines attend une plage DÉBUT:FIN, par exemple 10:20 : %q", *lines)
+		}
+	}
+	files, err := analyzer.listPHPFiles(paths...)
+	if err != nil {
+		return err
+	}
+	if *lines != "" && len(files) != 1 {
+		return fmt.Errorf("L'option -lines s'applique à un seul fichier.")
+	}
+	journal := ""
+	if *write {
+		if slices.Contains(files, stdinPath) {
+			return fmt.Errorf("L'option -w ne s'applique pas à l'entrée standard.")
+		}
+		root, err := formatRoot(paths)
+		if err != nil {
+			return err
+		}
+		journal = filepath.Join(root, formatJournal)
+		if err := RecoverEdits(journal); err != nil {
+			return fmt.Errorf("Erreur lors de la restauration de %q: %v", journal, err)
+		}
+	}
+
+	printer := flags.newPrinter()
+	var edits []FileEdit
+	for _, file := range files {
+		var source []byte
+		if file == stdinPath {
+			source, err = analyzer.readStdin()
+		} else {
+			source, err = os.ReadFile(file)
+		}
+		if err != nil {
+			analyzer.fileError("Erreur lors de la lecture de %q: %v", file, err)
+			continue
+		}
+		var formatted []byte
+		if *lines != "" {
+			formatted, err = printer.FormatRange(source, start, end)
+		} else {
+			formatted, err = printer.Format(source)
+		}
+		if err != nil {
+			analyzer.fileError("Erreur lors du formatage de %q: %v", file, err)
+			continue
+		}
+		switch {
+		case *check:
+			if diff := unifiedDiff(filepath.ToSlash(file), source, formatted); diff != "" {
+				io.WriteString(out, diff)
+				// Un fichier à formater compte comme un résultat (code exitFindings).
+				analyzer.recordFindings([]Finding{{Severity: analyzer.FailOn}})
+			}
+		case !*write:
+			out.Write(formatted)
+		case string(formatted) != string(source):
+			edits = append(edits, FileEdit{Path: file, Content: formatted})
+		}
+	}
+
+	if len(edits) > 0 {
+		if err := ApplyEdits(edits, journal); err != nil {
+			return fmt.Errorf("Erreur lors de la réécriture des fichiers: %v", err)
+		}
+		for _, edit := range edits {
+			fmt.Fprintln(out, edit.Path)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatCommand(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), mode))
+		return path
+	}
+	messy := write("src/messy.php", "<?php\nfunction f(){\nreturn 1;}", 0o600)
+	clean := write("src/clean.php", "<?php\n$a = 1;\n", 0o644)
+	broken := write("broken/broken.php", "<?php\nfunction f( {\n", 0o644)
+
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(dir))
+	defer os.Chdir(wd)
+
+	// Sans -w, le résultat est écrit sur la sortie et les fichiers restent inchangés.
+	var out strings.Builder
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "fmt", []string{messy}, &out))
+	assert.Equal(t, "<?php\nfunction f() {\n    return 1;\n}\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "fmt", []string{"-w", "-indent=2", "-dir", filepath.Join(dir, "src")}, &out))
+	assert.Equal(t, messy+"\n", out.String(), "seuls les fichiers modifiés sont listés")
+	content, err := os.ReadFile(messy)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\nfunction f() {\n  return 1;\n}\n", string(content))
+	info, err := os.Stat(messy)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0o600), info.Mode().Perm(), "les permissions sont conservées")
+	content, err = os.ReadFile(clean)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n$a = 1;\n", string(content))
+	assert.NoFileExists(t, filepath.Join(dir, "src", formatJournal))
+
+	// Un fichier qui ne se parse pas n'est pas modifié.
+	out.Reset()
+	analyzer := NewPHPAnalyzer()
+	err = runCommand(analyzer, "fmt", []string{"-w", broken}, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, exitError, analyzer.exitCode(err))
+	content, err = os.ReadFile(broken)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\nfunction f( {\n", string(content))
+
+	// Une instruction que le formateur ne reproduirait pas fidèlement est conservée
+	// telle quelle, sans empêcher le formatage du reste du fichier.
+	unsupported := write("unsupported.php", "<?php\nswitch ($a) { case 1: echo 1; }\n$b=2;\n", 0o644)
+	analyzer = NewPHPAnalyzer()
+	err = runCommand(analyzer, "fmt", []string{"-w", unsupported}, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, exitClean, analyzer.exitCode(err))
+	content, err = os.ReadFile(unsupported)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\nswitch ($a) { case 1: echo 1; }\n$b = 2;\n", string(content))
+
+	analyzer = NewPHPAnalyzer()
+	analyzer.Stdin = strings.NewReader("<?php\necho 1;")
+	out.Reset()
+	assert.NoError(t, runCommand(analyzer, "fmt", []string{"-"}, &out))
+	assert.Equal(t, "<?php\necho 1;\n", out.String())
+
+	// -file a la même signification que pour count ou cve, -file=- désignant l'entrée standard.
+	out.Reset()
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "fmt", []string{"-file", clean}, &out))
+	assert.Equal(t, "<?php\n$a = 1;\n", out.String())
+	analyzer = NewPHPAnalyzer()
+	analyzer.Stdin = strings.NewReader("<?php\necho  1;")
+	out.Reset()
+	assert.NoError(t, runCommand(analyzer, "fmt", []string{"-file=-"}, &out))
+	assert.Equal(t, "<?php\necho 1;\n", out.String())
+	analyzer = NewPHPAnalyzer()
+	analyzer.Stdin = strings.NewReader("<?php\necho 1;")
+	assert.ErrorContains(t, runCommand(analyzer, "fmt", []string{"-w", "-file=-"}, &out), "entrée standard")
+
+	analyzer = NewPHPAnalyzer()
+	analyzer.Stdin = strings.NewReader("<?php\necho 1;\n\n\necho 2;")
+	out.Reset()
+	assert.NoError(t, runCommand(analyzer, "fmt", []string{"-max-blank-lines=0", "-blank-line-after-tag", "-"}, &out))
+	assert.Equal(t, "<?php\n\necho 1;\necho 2;\n", out.String())
+}
+
+func TestFormatRecoversFromTargetRoot(t *testing.T) {
+	dir := t.TempDir()
+	a := filepath.Join(dir, "src", "a.php")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(a), 0o755))
+
+	// Simule une exécution de fmt -w interrompue après le remplacement du fichier.
+	assert.NoError(t, os.WriteFile(a+backupSuffix, []byte("<?php\necho 1;\n"), 0o644))
+	assert.NoError(t, os.WriteFile(a, []byte("<?php\necho 10;\n"), 0o644))
+	journal := filepath.Join(dir, "src", formatJournal)
+	assert.NoError(t, writeJournal(journal, []journalEntry{{Path: a, Temp: a + tempSuffix, Backup: a + backupSuffix}}))
+
+	// Le journal est retrouvé quel que soit le dossier courant.
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(t.TempDir()))
+	defer os.Chdir(wd)
+
+	var out strings.Builder
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "fmt", []string{"-w", filepath.Join(dir, "src")}, &out))
+	content, err := os.ReadFile(a)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\necho 1;\n", string(content))
+	assert.NoFileExists(t, journal)
+
+	root, err := formatRoot([]string{filepath.Join(dir, "src", "a.php"), filepath.Join(dir, "lib")})
+	assert.NoError(t, err)
+	assert.Equal(t, dir, root)
+}
+
+func TestFormatCheck(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.php")
+	source := "<?php\n$a = 1;\n$b = 2;\n$c = 3;\n$d = 4;\nif($a){echo 1;}\n$e = 5;\n"
+	assert.NoError(t, os.WriteFile(path, []byte(source), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.php"), []byte("<?php\n$a = 1;\n"), 0o644))
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	err := runCommand(analyzer, "fmt", []string{"-check", dir}, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, exitFindings, analyzer.exitCode(err))
+	name := filepath.ToSlash(path)
+	assert.Equal(t, "--- a/"+name+"\n+++ b/"+name+"\n"+
+		"@@ -3,5 +3,7 @@\n $b = 2;\n $c = 3;\n $d = 4;\n-if($a){echo 1;}\n+if ($a) {\n+    echo 1;\n+}\n $e = 5;\n", out.String())
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, source, string(content), "-check ne modifie pas les fichiers")
+
+	out.Reset()
+	err = runCommand(analyzer, "fmt", []string{"-check", filepath.Join(dir, "b.php")}, &out)
+	assert.NoError(t, err)
+	assert.Equal(t, exitClean, analyzer.exitCode(err))
+	assert.Empty(t, out.String())
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	assert.Equal(t, "", unifiedDiff("f", []byte("a\nb\n"), []byte("a\nb\n")))
+	assert.Equal(t, "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n", unifiedDiff("f", []byte("a\nb"), []byte("a\nb\n")))
+	assert.Equal(t, "--- a/f\n+++ b/f\n@@ -0,0 +1 @@\n+x\n", unifiedDiff("f", nil, []byte("x\n")))
+
+	// Deux modifications éloignées forment deux blocs.
+	var before, after []string
+	for i := 1; i <= 20; i++ {
+		before = append(before, fmt.Sprint(i))
+		after = append(after, fmt.Sprint(i))
+	}
+	after[1], after[17] = "two", "eighteen"
+	diff := unifiedDiff("f", []byte(strings.Join(before, "\n")+"\n"), []byte(strings.Join(after, "\n")+"\n"))
+	assert.Equal(t, "--- a/f\n+++ b/f\n"+
+		"@@ -1,5 +1,5 @@\n 1\n-2\n+two\n 3\n 4\n 5\n"+
+		"@@ -15,6 +15,6 @@\n 15\n 16\n 17\n-18\n+eighteen\n 19\n 20\n", diff)
+}
+
+func TestFormatLines(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.php")
+	assert.NoError(t, os.WriteFile(path, []byte("<?php\n$a=1;\n$b=2;\n$c=3;\n"), 0o644))
+
+	var out strings.Builder
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "fmt", []string{"-w", "--lines=3:3", path}, &out))
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n$a=1;\n$b = 2;\n$c=3;\n", string(content))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.php"), []byte("<?php\n"), 0o644))
+	assert.ErrorContains(t, runCommand(NewPHPAnalyzer(), "fmt", []string{"--lines=1:2", dir}, &out), "un seul fichier")
+	assert.ErrorContains(t, runCommand(NewPHPAnalyzer(), "fmt", []string{"--lines=3", path}, &out), "DÉBUT:FIN")
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github/behouba/log6302A/pkg/analyzerpb"
+)
+
+// grpcService implémente le service gRPC analyzerpb.Analyzer (serve -grpc-addr). Chaque
+// appel utilise un analyseur neuf, sans cache d'arbres : les appels sont indépendants
+// et traités en parallèle.
+type grpcService struct {
+	analyzerpb.UnimplementedAnalyzerServer
+	rulesPath, advisoriesPath string
+}
+
+// newAnalyzer crée l'analyseur d'un appel, configuré par ses options.
+func (s *grpcService) newAnalyzer(ctx context.Context, opts *analyzerpb.Options) (*PHPAnalyzer, error) {
+	analyzer := NewPHPAnalyzer()
+	if err := loadSignatures(analyzer, s.rulesPath, s.advisoriesPath); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if name := opts.GetMinSeverity(); name != "" {
+		severity, err := ParseSeverity(name)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "min_severity : %v", err)
+		}
+		analyzer.MinSeverity = severity
+	}
+	analyzer.PHPVersion = opts.GetPhpVersion()
+	if len(opts.GetOnly()) > 0 {
+		if err := analyzer.SelectRules(RuleSelection{Only: opts.GetOnly()}); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "only : %v", err)
+		}
+	}
+	return analyzer, ctx.Err()
+}
+
+// AnalyzeFile analyse un fichier du serveur ou le source de la requête, comme cve.
+func (s *grpcService) AnalyzeFile(ctx context.Context, req *analyzerpb.AnalyzeFileRequest) (*analyzerpb.AnalyzeFileResponse, error) {
+	analyzer, err := s.newAnalyzer(ctx, req.GetOptions())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	var path string
+	var content []byte
+	switch input := req.GetInput().(type) {
+	case *analyzerpb.AnalyzeFileRequest_Path:
+		path = input.Path
+		if content, err = os.ReadFile(path); err != nil {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+	case *analyzerpb.AnalyzeFileRequest_Source:
+		path, content = stdinPath, input.Source
+	default:
+		return nil, status.Error(codes.InvalidArgument, "path ou source requis")
+	}
+	tree, err := analyzer.Parse(ctx, content)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	findings := analyzer.DetectVulnerabilities(ctx, tree, content)
+	analyzer.releaseTree(tree)
+	if err := ctx.Err(); err != nil {
+		return nil, grpcError(err)
+	}
+	assignFingerprints(fingerprintPath(".", path), content, findings)
+	return &analyzerpb.AnalyzeFileResponse{Findings: protoFindings(analyzer.filterSeverity(findings))}, nil
+}
+
+// AnalyzeTree analyse les fichiers PHP d'un dossier comme analyze-dir et envoie les
+// résultats de chaque fichier dans l'ordre du parcours, au fil du scan.
+func (s *grpcService) AnalyzeTree(req *analyzerpb.AnalyzeTreeRequest, stream analyzerpb.Analyzer_AnalyzeTreeServer) error {
+	ctx := stream.Context()
+	analyzer, err := s.newAnalyzer(ctx, req.GetOptions())
+	if err != nil {
+		return grpcError(err)
+	}
+	paths := []string{req.GetPath()}
+	if info, err := os.Stat(req.GetPath()); err != nil || !info.IsDir() {
+		return status.Errorf(codes.InvalidArgument, "%q n'est pas un dossier", req.GetPath())
+	}
+	if analyzer.Profile, err = analyzer.ResolveProfile(ctx, "auto", paths...); err != nil {
+		return grpcError(err)
+	}
+	if err := analyzer.IndexProject(ctx, paths...); err != nil {
+		return grpcError(err)
+	}
+	root := scanRoot(paths)
+	var sendErr error
+	err = scanPHPFiles(ctx, paths, analyzer, func(ctx context.Context, fa *PHPAnalyzer, path string) *analyzerpb.FileResult {
+		tree, content, err := fa.ParseFile(ctx, path)
+		if err != nil {
+			return &analyzerpb.FileResult{Path: path, Error: err.Error()}
+		}
+		findings := fa.DetectVulnerabilities(ctx, tree, content)
+		fa.releaseTree(tree)
+		if ctx.Err() != nil {
+			return nil
+		}
+		assignFingerprints(fingerprintPath(root, path), content, findings)
+		return &analyzerpb.FileResult{Path: path, Findings: protoFindings(fa.filterSeverity(findings))}
+	}, func(result *analyzerpb.FileResult) {
+		if result != nil && sendErr == nil {
+			sendErr = stream.Send(result)
+		}
+	})
+	if sendErr != nil {
+		return sendErr
+	}
+	return grpcError(err)
+}
+
+// ListRules liste les règles chargées, comme rules list.
+func (s *grpcService) ListRules(ctx context.Context, _ *analyzerpb.ListRulesRequest) (*analyzerpb.ListRulesResponse, error) {
+	analyzer, err := s.newAnalyzer(ctx, nil)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &analyzerpb.ListRulesResponse{}
+	for _, rule := range analyzer.RuleCatalog() {
+		resp.Rules = append(resp.Rules, &analyzerpb.Rule{
+			Id:          rule.ID,
+			Category:    rule.Category,
+			Cwe:         rule.CWE,
+			Severity:    rule.Severity.String(),
+			Confidence:  rule.Confidence.String(),
+			Description: rule.Description,
+			Php:         rule.PHP,
+			Owasp:       rule.OWASP,
+			References:  rule.References,
+			Remediation: rule.Remediation,
+		})
+	}
+	return resp, nil
+}
+
+// protoFindings convertit des résultats pour les réponses gRPC.
+func protoFindings(findings []Finding) []*analyzerpb.Finding {
+	converted := make([]*analyzerpb.Finding, 0, len(findings))
+	for _, f := range findings {
+		converted = append(converted, &analyzerpb.Finding{
+			Rule:        f.RuleID,
+			Cwe:         f.CWE,
+			Severity:    f.Severity.String(),
+			Confidence:  f.Confidence.String(),
+			Message:     f.Message,
+			Line:        f.Line,
+			Column:      f.Column,
+			EndLine:     f.EndLine,
+			EndColumn:   f.EndColumn,
+			Fingerprint: f.Fingerprint,
+			Owasp:       f.OWASP,
+			Remediation: f.Remediation,
+			References:  f.References,
+		})
+	}
+	return converted
+}
+
+// grpcError convertit une erreur en statut gRPC, l'annulation de l'appel et le
+// dépassement de son échéance gardant leur code.
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return status.FromContextError(err).Err()
+	}
+	return status.Error(codes.Internal, err.Error())
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github/behouba/log6302A/pkg/analyzerpb"
+)
+
+// grpcClient démarre le service gRPC en mémoire et retourne un client connecté.
+func grpcClient(t *testing.T) analyzerpb.AnalyzerClient {
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	analyzerpb.RegisterAnalyzerServer(server, &grpcService{advisoriesPath: filepath.Join(t.TempDir(), "advisories.json")})
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return analyzerpb.NewAnalyzerClient(conn)
+}
+
+func TestGRPCAnalyzeFile(t *testing.T) {
+	client := grpcClient(t)
+	resp, err := client.AnalyzeFile(context.Background(), &analyzerpb.AnalyzeFileRequest{
+		Input: &analyzerpb.AnalyzeFileRequest_Source{Source: []byte("<?php\nlibxml_disable_entity_loader(false);\nvar_dump($a);\n")},
+	})
+	assert.NoError(t, err)
+	rules := map[string]uint32{}
+	for _, f := range resp.GetFindings() {
+		rules[f.GetRule()] = f.GetLine()
+		assert.NotEmpty(t, f.GetFingerprint())
+	}
+	assert.Equal(t, uint32(3), rules["debug-leftover"])
+	assert.Len(t, rules, 2)
+
+	resp, err = client.AnalyzeFile(context.Background(), &analyzerpb.AnalyzeFileRequest{
+		Input:   &analyzerpb.AnalyzeFileRequest_Source{Source: []byte("<?php\nvar_dump($a);\n")},
+		Options: &analyzerpb.Options{MinSeverity: "high"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, resp.GetFindings(), "debug-leftover is low")
+
+	_, err = client.AnalyzeFile(context.Background(), &analyzerpb.AnalyzeFileRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.AnalyzeFile(context.Background(), &analyzerpb.AnalyzeFileRequest{
+		Input: &analyzerpb.AnalyzeFileRequest_Path{Path: filepath.Join(t.TempDir(), "missing.php")},
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCAnalyzeTree(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.php"), []byte("<?php\nvar_dump($a);\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.php"), []byte("<?php\necho 1;\n"), 0o644))
+
+	stream, err := grpcClient(t).AnalyzeTree(context.Background(), &analyzerpb.AnalyzeTreeRequest{Path: dir})
+	assert.NoError(t, err)
+	var results []*analyzerpb.FileResult
+	for {
+		result, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if !assert.NoError(t, err) {
+			return
+		}
+		results = append(results, result)
+	}
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, filepath.Join(dir, "a.php"), results[0].GetPath())
+		assert.Len(t, results[0].GetFindings(), 1)
+		assert.Empty(t, results[1].GetFindings())
+	}
+}
+
+func TestGRPCListRules(t *testing.T) {
+	resp, err := grpcClient(t).ListRules(context.Background(), &analyzerpb.ListRulesRequest{})
+	assert.NoError(t, err)
+	ids := map[string]string{}
+	for _, rule := range resp.GetRules() {
+		ids[rule.GetId()] = rule.GetCategory()
+	}
+	assert.Contains(t, ids, "debug-leftover")
+}
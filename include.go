@@ -0,0 +1,232 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// includeTargets retourne les fichiers inclus par un fichier (include, require et leurs
+// variantes _once) dont le chemin se résout statiquement : chaîne littérale, __DIR__,
+// __FILE__ et dirname(), et concaténations de ces valeurs. Les inclusions dont le chemin
+// dépend d'une variable, ou dont la cible n'existe pas, sont ignorées.
+func includeTargets(root *sitter.Node, source []byte, path string) []string {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil
+	}
+	var targets []string
+	seen := map[string]bool{}
+	traverseAST(root, func(n *sitter.Node) {
+		switch n.Type() {
+		case "include_expression", "include_once_expression", "require_expression", "require_once_expression":
+		default:
+			return
+		}
+		if n.NamedChildCount() == 0 {
+			return
+		}
+		value, ok := includePath(n.NamedChild(int(n.NamedChildCount())-1), source, abs)
+		if !ok {
+			return
+		}
+		if target := resolveInclude(value, abs, !filepath.IsAbs(path)); target != "" && !seen[target] {
+			seen[target] = true
+			targets = append(targets, target)
+		}
+	})
+	return targets
+}
+
+// includePath évalue le chemin d'une inclusion dans le fichier file (chemin absolu).
+func includePath(expr *sitter.Node, source []byte, file string) (string, bool) {
+	switch expr.Type() {
+	case "string", "encapsed_string":
+		if hasInterpolation(expr) {
+			return "", false
+		}
+		var value strings.Builder
+		for i := 0; i < int(expr.NamedChildCount()); i++ {
+			switch child := expr.NamedChild(i); child.Type() {
+			case "string_content":
+				value.WriteString(child.Content(source))
+			case "escape_sequence":
+				// Seuls les échappements des guillemets et de la barre oblique inverse
+				// sont attendus dans un chemin.
+				escaped := child.Content(source)
+				if escaped != `\\` && escaped != `\'` && escaped != `\"` {
+					return "", false
+				}
+				value.WriteString(escaped[1:])
+			}
+		}
+		return value.String(), true
+	case "parenthesized_expression":
+		if expr.NamedChildCount() != 1 {
+			return "", false
+		}
+		return includePath(expr.NamedChild(0), source, file)
+	case "binary_expression":
+		if operator := expr.ChildByFieldName("operator"); operator == nil || operator.Type() != "." {
+			return "", false
+		}
+		left, ok := includePath(expr.ChildByFieldName("left"), source, file)
+		if !ok {
+			return "", false
+		}
+		right, ok := includePath(expr.ChildByFieldName("right"), source, file)
+		return left + right, ok
+	case "name":
+		switch expr.Content(source) {
+		case "__DIR__":
+			return filepath.Dir(file), true
+		case "__FILE__":
+			return file, true
+		}
+	case "function_call_expression":
+		// dirname($path) et dirname($path, $levels)
+		if !strings.EqualFold(strings.TrimPrefix(extractFunctionName(expr, source), `\`), "dirname") {
+			return "", false
+		}
+		args := getArgumentNodes(expr)
+		if len(args) == 0 || len(args) > 2 {
+			return "", false
+		}
+		dir, ok := includePath(argumentValue(args[0]), source, file)
+		if !ok {
+			return "", false
+		}
+		levels := 1
+		if len(args) == 2 {
+			value := argumentValue(args[1])
+			if value.Type() != "integer" {
+				return "", false
+			}
+			if levels, _ = strconv.Atoi(value.Content(source)); levels < 1 {
+				return "", false
+			}
+		}
+		for ; levels > 0; levels-- {
+			dir = filepath.Dir(dir)
+		}
+		return dir, true
+	}
+	return "", false
+}
+
+// resolveInclude retourne le fichier désigné par le chemin d'une inclusion du fichier
+// from (chemin absolu), "" s'il n'existe pas. Comme PHP avec l'include_path par défaut,
+// un chemin relatif est cherché dans le dossier courant puis dans celui du fichier. Le
+// chemin retourné est relatif au dossier courant si relative est vrai.
+func resolveInclude(value, from string, relative bool) string {
+	value = filepath.FromSlash(value)
+	candidates := []string{value}
+	if !filepath.IsAbs(value) {
+		candidates = append(candidates, filepath.Join(filepath.Dir(from), value))
+	}
+	for _, candidate := range candidates {
+		abs, err := filepath.Abs(candidate)
+		if err != nil {
+			continue
+		}
+		if info, err := os.Stat(abs); err != nil || info.IsDir() {
+			continue
+		}
+		return displayPath(abs, relative)
+	}
+	return ""
+}
+
+// displayPath retourne un chemin absolu, ou relatif au dossier courant si relative est
+// vrai, comme les chemins parcourus depuis un dossier relatif.
+func displayPath(abs string, relative bool) string {
+	if relative {
+		if wd, err := os.Getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, abs); err == nil {
+				return rel
+			}
+		}
+	}
+	return abs
+}
+
+// ResolveClass retourne la définition d'une classe, d'une interface, d'un trait ou
+// d'une énumération d'après son nom complètement qualifié (nameResolver.resolveClass).
+func (idx *SymbolIndex) ResolveClass(class string) (Symbol, bool) {
+	for _, symbol := range idx.Lookup(class) {
+		switch symbol.Kind {
+		case SymbolClass, SymbolInterface, SymbolTrait, SymbolEnum:
+			return symbol, true
+		}
+	}
+	return Symbol{}, false
+}
+
+// Includes retourne les fichiers inclus par un fichier du projet, directement ou par
+// l'intermédiaire d'autres inclusions, dans l'ordre où ils sont rencontrés.
+func (idx *SymbolIndex) Includes(file string) []string {
+	if idx == nil {
+		return nil
+	}
+	var files []string
+	seen := map[string]bool{filepath.Clean(file): true}
+	var visit func(file string)
+	visit = func(file string) {
+		for _, target := range idx.includes[filepath.Clean(file)] {
+			if !seen[target] {
+				seen[target] = true
+				files = append(files, target)
+				visit(target)
+			}
+		}
+	}
+	visit(file)
+	return files
+}
+
+// ResolveFunction retourne la définition vers laquelle un appel à function, écrit dans
+// l'espace de noms namespace du fichier file, est résolu. Les noms sont résolus comme
+// par functionIndex.resolve ; parmi plusieurs définitions d'un même nom, celle du
+// fichier ou des fichiers qu'il inclut l'emporte, puis la première du projet.
+func (idx *SymbolIndex) ResolveFunction(file, function, namespace string) (Symbol, bool) {
+	if idx == nil {
+		return Symbol{}, false
+	}
+	var candidates []string
+	switch {
+	case strings.HasPrefix(function, `\`):
+		candidates = []string{function[1:]}
+	case namespace == "":
+		candidates = []string{function}
+	case strings.Contains(function, `\`):
+		candidates = []string{namespace + `\` + function}
+	default:
+		candidates = []string{namespace + `\` + function, function}
+	}
+	visible := map[string]bool{filepath.Clean(file): true}
+	for _, included := range idx.Includes(file) {
+		visible[included] = true
+	}
+	for _, name := range candidates {
+		var fallback Symbol
+		found := false
+		for _, symbol := range idx.Lookup(name) {
+			if symbol.Kind != SymbolFunction {
+				continue
+			}
+			if visible[filepath.Clean(symbol.File)] {
+				return symbol, true
+			}
+			if !found {
+				fallback, found = symbol, true
+			}
+		}
+		if found {
+			return fallback, true
+		}
+	}
+	return Symbol{}, false
+}
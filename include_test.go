@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeFiles crée des fichiers sous dir, les noms utilisant "/" comme séparateur.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+}
+
+func TestIncludeTargets(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"lib/a.php": "<?php", "lib/b.php": "<?php", "c.php": "<?php", "d.php": "<?php", "e.php": "<?php",
+		"web/admin/page.php": `<?php
+include '../../lib/a.php';
+require_once __DIR__ . '/../../lib/b.php';
+require(dirname(__FILE__, 3) . "/c.php");
+include_once dirname(dirname(__DIR__)) . '/d.php';
+include "` + filepath.ToSlash(filepath.Join(dir, "e.php")) + `";
+require $base . '/e.php';
+include __DIR__ . '/missing.php';
+require_once __DIR__ . '/../../lib/a.php';`,
+	})
+	page := filepath.Join(dir, "web", "admin", "page.php")
+	analyzer := NewPHPAnalyzer()
+	tree, content, err := analyzer.ParseFile(context.Background(), page)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "lib", "a.php"),
+		filepath.Join(dir, "lib", "b.php"),
+		filepath.Join(dir, "c.php"),
+		filepath.Join(dir, "d.php"),
+		filepath.Join(dir, "e.php"),
+	}, includeTargets(tree.RootNode(), content, page), "dynamic and missing targets are ignored, duplicates listed once")
+}
+
+func TestIndexProjectFollowsIncludes(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"lib/helpers.php": "<?php\nrequire_once __DIR__ . '/escape.php';\nfunction h($v) { return e($v); }\n",
+		"lib/escape.php":  "<?php\nrequire_once __DIR__ . '/helpers.php';\nfunction e($v) { return htmlspecialchars($v, ENT_QUOTES); }\n",
+		"lib/other.php":   "<?php\nfunction h($v) { return $v; }\n",
+		"web/page.php":    "<?php\nrequire_once dirname(__DIR__) . '/lib/helpers.php';\necho h($_GET['name']);\necho $_GET['raw'];\n",
+	})
+	web := filepath.Join(dir, "web")
+	page := filepath.Join(web, "page.php")
+
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, analyzer.IndexProject(context.Background(), web))
+	assert.Equal(t, []string{filepath.Join(dir, "lib", "helpers.php"), filepath.Join(dir, "lib", "escape.php")},
+		analyzer.symbols.Includes(page), "includes are followed outside the indexed paths, cycles once")
+	assert.Len(t, analyzer.symbols.Query([]string{SymbolFunction}, ""), 2)
+
+	// Une définition visible par les inclusions l'emporte sur une homonyme du projet.
+	assert.NoError(t, analyzer.IndexProject(context.Background(), filepath.Join(dir, "lib", "other.php"), web))
+	symbol, ok := analyzer.symbols.ResolveFunction(page, "h", "")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(dir, "lib", "helpers.php"), symbol.File)
+	symbol, ok = analyzer.symbols.ResolveFunction(filepath.Join(dir, "lib", "other.php"), `\h`, "App")
+	assert.True(t, ok)
+	assert.Equal(t, filepath.Join(dir, "lib", "other.php"), symbol.File)
+
+	// cve sur le seul fichier résout le nettoyage défini dans les fichiers inclus.
+	var out strings.Builder
+	analyzer = NewPHPAnalyzer()
+	analyzer.ContextLines = -1
+	assert.NoError(t, runCommand(analyzer, "cve", []string{"-file", page, "-fail-on", "critical"}, &out))
+	assert.Equal(t, 1, strings.Count(out.String(), "[xss"))
+	assert.Contains(t, out.String(), "ligne 4")
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// parseArgs parse les flags d'une sous-commande et retourne ses arguments positionnels
+// (chemins et motifs), les flags pouvant les suivre : cve src/*.php -fail-on=high. Après
+// "--", tous les arguments sont positionnels.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+		if len(args) > len(rest) && args[len(args)-len(rest)-1] == "--" {
+			return append(positional, rest...), nil
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+}
+
+// parseCommandLine parse les flags d'une sous-commande et développe ses chemins et motifs
+// positionnels, suivis des chemins de la liste de -files-from.
+func (pa *PHPAnalyzer) parseCommandLine(fs *flag.FlagSet, args []string) ([]string, error) {
+	filesFrom := fs.String("files-from", "", "Fichier listant les chemins à analyser, un par ligne ou séparés par des octets nuls (- pour l'entrée standard)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return nil, errUsage
+	}
+	pa.resolvePathFlags(fs)
+	for i, path := range positional {
+		positional[i] = pa.abs(path)
+	}
+	paths, err := expandInputs(positional)
+	if err != nil || *filesFrom == "" {
+		return paths, err
+	}
+	listed, err := pa.readFileList(*filesFrom)
+	if err != nil {
+		return nil, fmt.Errorf("option -files-from : %v", err)
+	}
+	for i, path := range listed {
+		listed[i] = pa.abs(path)
+	}
+	return append(paths, listed...), nil
+}
+
+// pathFlags sont les flags des sous-commandes qui désignent un fichier ou un dossier.
+var pathFlags = map[string]bool{
+	"advisories": true, "baseline": true, "cache-dir": true, "calibration": true, "config": true, "dir": true,
+	"file": true, "files-from": true, "history": true, "out": true, "project": true, "rules": true,
+}
+
+// abs rapporte un chemin relatif au dossier pa.Dir (celui du client, dans le démon).
+// Sans pa.Dir, ou pour "" et l'entrée standard, le chemin est conservé.
+func (pa *PHPAnalyzer) abs(path string) string {
+	if pa.Dir == "" || path == "" || path == stdinPath || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(pa.Dir, path)
+}
+
+// resolvePathFlags rapporte à pa.Dir les chemins des flags de fs, valeurs par défaut
+// comprises (.phpanalyzer.yaml...).
+func (pa *PHPAnalyzer) resolvePathFlags(fs *flag.FlagSet) {
+	fs.VisitAll(func(f *flag.Flag) {
+		if pathFlags[f.Name] {
+			f.Value.Set(pa.abs(f.Value.String()))
+		}
+	})
+}
+
+// readFileList lit une liste de chemins, un par ligne ou séparés par des octets nuls
+// (find -print0), depuis un fichier ou l'entrée standard. Les chemins ne sont pas
+// développés comme des motifs. Une liste vide est une erreur, pour qu'une sélection
+// vide par erreur ne passe pas pour un scan sans résultat.
+func (pa *PHPAnalyzer) readFileList(name string) ([]string, error) {
+	var data []byte
+	var err error
+	if name == stdinPath {
+		data, err = pa.readStdin()
+		pa.stdinPiped = false // l'entrée standard ne contient pas de code PHP
+	} else {
+		data, err = os.ReadFile(name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	separator := "\n"
+	if bytes.IndexByte(data, 0) >= 0 {
+		separator = "\x00"
+	}
+	var paths []string
+	for _, path := range strings.Split(string(data), separator) {
+		if path = strings.TrimRight(path, "\r"); path != "" {
+			paths = append(paths, path)
+		}
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("aucun chemin dans %q", name)
+	}
+	return paths, nil
+}
+
+// scanPaths retourne les chemins d'un scan : celui du flag -dir (ou -file), s'il est
+// renseigné, suivi des chemins positionnels.
+func scanPaths(flagPath string, inputs []string) []string {
+	if flagPath == "" {
+		return inputs
+	}
+	return append([]string{flagPath}, inputs...)
+}
+
+// hasGlobMeta indique si un argument est un motif plutôt qu'un chemin.
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+// expandInputs développe les chemins et motifs passés en arguments : un chemin est
+// conservé tel quel (fichier, dossier ou "-" pour l'entrée standard), un motif est
+// remplacé par les chemins qui lui correspondent, "**" désignant un nombre quelconque
+// de dossiers. Un motif sans correspondance est une erreur.
+func expandInputs(args []string) ([]string, error) {
+	var paths []string
+	for _, arg := range args {
+		if arg == stdinPath || !hasGlobMeta(arg) {
+			paths = append(paths, arg)
+			continue
+		}
+		matches, err := globPaths(arg)
+		if err != nil {
+			return nil, fmt.Errorf("motif %q invalide : %v", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("aucun fichier ne correspond au motif %q", arg)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// globPaths retourne, triés, les chemins correspondant à un motif. Sans "**", il s'agit
+// de filepath.Glob ; sinon, le dossier qui précède le premier segment contenant un
+// caractère spécial est parcouru et chaque chemin comparé au motif segment par segment.
+func globPaths(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+	segments := strings.Split(filepath.ToSlash(pattern), "/")
+	base := 0
+	for base < len(segments) && !hasGlobMeta(segments[base]) {
+		base++
+	}
+	root := strings.Join(segments[:base], "/")
+	if root == "" && base > 0 {
+		root = "/"
+	} else if root == "" {
+		root = "."
+	}
+	if _, err := filepath.Match(strings.Join(segments[base:], "/"), ""); err != nil {
+		return nil, err
+	}
+	var matches []string
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == root {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, path)
+		if matchSegments(segments[base:], strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, path)
+		}
+		return nil
+	})
+	sort.Strings(matches)
+	return matches, err
+}
+
+// matchSegments compare les segments d'un chemin à ceux d'un motif, où "**" correspond
+// à zéro, un ou plusieurs segments.
+func matchSegments(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchSegments(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 {
+		return false
+	}
+	ok, _ := filepath.Match(pattern[0], path[0])
+	return ok && matchSegments(pattern[1:], path[1:])
+}
+
+// scanRoot retourne le dossier auquel sont relatifs les chemins des empreintes d'un scan
+// (baseline) : le dossier analysé s'il est le seul chemin, le dossier courant sinon.
+func scanRoot(paths []string) string {
+	if len(paths) == 1 {
+		if info, err := os.Stat(paths[0]); err == nil && info.IsDir() {
+			return paths[0]
+		}
+	}
+	return "."
+}
+
+// quotePaths cite les chemins d'un scan dans un message.
+func quotePaths(paths []string) string {
+	quoted := make([]string, len(paths))
+	for i, path := range paths {
+		quoted[i] = fmt.Sprintf("%q", path)
+	}
+	return strings.Join(quoted, ", ")
+}
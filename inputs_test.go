@@ -0,0 +1,96 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArgsInterspersed(t *testing.T) {
+	fs := flag.NewFlagSet("cve", flag.ContinueOnError)
+	failOn := fs.String("fail-on", "info", "")
+	positional, err := parseArgs(fs, []string{"a.php", "-fail-on", "high", "src/", "--", "-b.php"})
+	assert.NoError(t, err)
+	assert.Equal(t, "high", *failOn)
+	assert.Equal(t, []string{"a.php", "src/", "-b.php"}, positional)
+}
+
+func TestMatchSegments(t *testing.T) {
+	match := func(pattern, path string) bool {
+		return matchSegments(strings.Split(pattern, "/"), strings.Split(path, "/"))
+	}
+	assert.True(t, match("**/*.php", "a.php"))
+	assert.True(t, match("**/*.php", "src/lib/a.php"))
+	assert.True(t, match("src/**/test/*.php", "src/test/a.php"))
+	assert.False(t, match("src/*.php", "src/lib/a.php"))
+	assert.False(t, match("**/*.php", "src/a.inc"))
+}
+
+func TestPositionalInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"src/a.php", "src/lib/b.php", "legacy/c.inc", "legacy/d.txt"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte("<?php\nvar_dump($a);\n"), 0o644))
+	}
+
+	paths, err := expandInputs([]string{filepath.Join(dir, "src", "**", "*.php"), filepath.Join(dir, "legacy", "*.inc")})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "src", "a.php"), filepath.Join(dir, "src", "lib", "b.php"), filepath.Join(dir, "legacy", "c.inc")}, paths)
+
+	_, err = expandInputs([]string{filepath.Join(dir, "*.java")})
+	assert.ErrorContains(t, err, "aucun fichier ne correspond")
+
+	// Les résultats de tous les chemins sont rassemblés ; un fichier désigné deux fois
+	// n'est analysé qu'une fois.
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.ContextLines = -1
+	args := []string{filepath.Join(dir, "legacy", "*.inc"), filepath.Join(dir, "src"), "-min-severity", "low", filepath.Join(dir, "src", "a.php")}
+	assert.NoError(t, runCommand(analyzer, "cve", args, &out))
+	results, summary, _ := strings.Cut(out.String(), "\nRésumé")
+	assert.Equal(t, 3, strings.Count(results, "debug-leftover"))
+	assert.Equal(t, 1, strings.Count(results, "c.inc"))
+	assert.Contains(t, summary, "Règles : debug-leftover 3")
+
+	out.Reset()
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "deadcount", []string{filepath.Join(dir, "src")}, &out))
+	assert.Contains(t, out.String(), "b.php")
+}
+
+func TestFilesFrom(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.php"), filepath.Join(dir, "b file.php")
+	for _, path := range []string{a, b} {
+		assert.NoError(t, os.WriteFile(path, []byte("<?php\nvar_dump($a);\n"), 0o644))
+	}
+
+	analyzer := NewPHPAnalyzer()
+	list := filepath.Join(dir, "list.txt")
+	assert.NoError(t, os.WriteFile(list, []byte(a+"\r\n\n"+b+"\n"), 0o644))
+	paths, err := analyzer.readFileList(list)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{a, b}, paths)
+
+	analyzer.Stdin = strings.NewReader(a + "\x00" + b + "\x00")
+	paths, err = analyzer.readFileList("-")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{a, b}, paths, "find -print0 output is split on NUL bytes")
+
+	assert.NoError(t, os.WriteFile(list, []byte("\n"), 0o644))
+	_, err = analyzer.readFileList(list)
+	assert.ErrorContains(t, err, "aucun chemin")
+
+	var out strings.Builder
+	analyzer = NewPHPAnalyzer()
+	analyzer.ContextLines = -1
+	analyzer.Stdin, analyzer.stdinPiped = strings.NewReader(a+"\n"+b+"\n"), true
+	assert.NoError(t, runCommand(analyzer, "cve", []string{"-files-from", "-", "-min-severity", "low"}, &out))
+	results, _, _ := strings.Cut(out.String(), "\nRésumé")
+	assert.Equal(t, 2, strings.Count(results, "debug-leftover"))
+	assert.Contains(t, results, "b file.php")
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// mainScope désigne, dans l'inventaire, le code situé hors de toute fonction.
+const mainScope = "{main}"
+
+// dbInventory est l'inventaire des accès à la base de données d'un fichier ou d'un
+// dossier, écrit par dbcalls -format=json : les appels par fichier et par fonction, pour
+// chaque table citée les opérations qui la touchent, et le schéma déduit des
+// instructions DDL du code.
+type dbInventory struct {
+	Files  []dbFileInventory         `json:"files"`
+	Tables map[string][]string       `json:"tables"`
+	Schema map[string][]SchemaColumn `json:"schema"`
+
+	Summary *scanSummary `json:"summary,omitempty"`
+}
+
+type dbFileInventory struct {
+	Path      string                `json:"path"`
+	Functions []dbFunctionInventory `json:"functions"`
+	Schema    []SchemaTable         `json:"schema,omitempty"`
+}
+
+type dbFunctionInventory struct {
+	Name  string         `json:"name"`
+	Calls []dbCallRecord `json:"calls"`
+}
+
+// dbCallRecord décrit un appel à la base : la fonction appelée, l'opération SQL et les
+// tables lorsque la requête est résolue, et la construction de la requête.
+type dbCallRecord struct {
+	Line        uint32            `json:"line"`
+	Call        string            `json:"call"`
+	Operation   string            `json:"operation,omitempty"`
+	Tables      []string          `json:"tables,omitempty"`
+	Query       QueryConstruction `json:"query"`
+	Severity    string            `json:"severity"`
+	Fingerprint string            `json:"fingerprint,omitempty"`
+}
+
+// functionSpan est l'étendue, en lignes, d'une fonction ou méthode nommée.
+type functionSpan struct {
+	name       string
+	start, end uint32
+}
+
+// functionSpans relève les fonctions et méthodes (Classe::méthode) d'un fichier, dans
+// l'ordre du code.
+func functionSpans(root *sitter.Node, source []byte) []functionSpan {
+	var spans []functionSpan
+	traverseAST(root, func(n *sitter.Node) {
+		if n.Type() != "function_definition" && n.Type() != "method_declaration" {
+			return
+		}
+		name := n.ChildByFieldName("name")
+		if name == nil {
+			return
+		}
+		qualified := name.Content(source)
+		if n.Type() == "method_declaration" {
+			for p := n.Parent(); p != nil; p = p.Parent() {
+				if class := p.ChildByFieldName("name"); class != nil && isClassLike(p.Type()) {
+					qualified = class.Content(source) + "::" + qualified
+					break
+				}
+			}
+		}
+		spans = append(spans, functionSpan{qualified, n.StartPoint().Row + 1, n.EndPoint().Row + 1})
+	})
+	return spans
+}
+
+// isClassLike indique si un type de nœud déclare une classe, une interface, un trait ou
+// une énumération.
+func isClassLike(nodeType string) bool {
+	switch nodeType {
+	case "class_declaration", "interface_declaration", "trait_declaration", "enum_declaration":
+		return true
+	}
+	return false
+}
+
+// functionAt retourne la fonction la plus interne contenant une ligne, mainScope hors de
+// toute fonction.
+func functionAt(spans []functionSpan, line uint32) string {
+	name := mainScope
+	for _, s := range spans {
+		if s.start <= line && line <= s.end {
+			name = s.name
+		}
+	}
+	return name
+}
+
+// inventoryFile regroupe par fonction les appels à la base d'un fichier.
+func inventoryFile(path string, spans []functionSpan, calls []Finding, schema []SchemaTable) dbFileInventory {
+	file := dbFileInventory{Path: path, Functions: []dbFunctionInventory{}, Schema: schema}
+	index := map[string]int{}
+	for _, call := range calls {
+		record := dbCallRecord{Line: call.Line, Call: call.Function, Query: call.Query, Severity: call.Severity.String(), Fingerprint: call.Fingerprint}
+		if call.SQL != nil {
+			record.Operation = call.SQL.Kind
+			record.Tables = call.SQL.Tables
+		}
+		name := functionAt(spans, call.Line)
+		i, ok := index[name]
+		if !ok {
+			i = len(file.Functions)
+			index[name] = i
+			file.Functions = append(file.Functions, dbFunctionInventory{Name: name})
+		}
+		file.Functions[i].Calls = append(file.Functions[i].Calls, record)
+	}
+	return file
+}
+
+// newDBInventory assemble l'inventaire des fichiers, triés par chemin, associe à chaque
+// table les opérations qui la touchent et fusionne les définitions de tables.
+func newDBInventory(files []dbFileInventory) dbInventory {
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	inventory := dbInventory{Files: files, Tables: map[string][]string{}}
+	seen := map[string]map[string]bool{}
+	var definitions []SchemaTable
+	for _, file := range files {
+		definitions = append(definitions, file.Schema...)
+		for _, function := range file.Functions {
+			for _, call := range function.Calls {
+				for _, table := range call.Tables {
+					if seen[table] == nil {
+						seen[table] = map[string]bool{}
+						inventory.Tables[table] = []string{}
+					}
+					if call.Operation != "" && !seen[table][call.Operation] {
+						seen[table][call.Operation] = true
+						inventory.Tables[table] = append(inventory.Tables[table], call.Operation)
+					}
+				}
+			}
+		}
+	}
+	for _, operations := range inventory.Tables {
+		sort.Strings(operations)
+	}
+	inventory.Schema = mergeSchema(definitions)
+	if inventory.Files == nil {
+		inventory.Files = []dbFileInventory{}
+	}
+	return inventory
+}
+
+// writeDBInventory écrit l'inventaire en JSON indenté.
+func writeDBInventory(out io.Writer, inventory dbInventory) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(inventory)
+}
+
+// inventoryDBFile inventorie les appels à la base de données et les définitions de
+// tables d'un fichier ; ok est faux si le fichier ne contient ni l'un ni l'autre.
+func (pa *PHPAnalyzer) inventoryDBFile(ctx context.Context, path string) (file dbFileInventory, ok bool, err error) {
+	scanned, err := pa.scanDBFile(ctx, pa.abs("."), path)
+	if err != nil {
+		return file, false, err
+	}
+	file, ok = pa.inventoryScanned(scanned)
+	return file, ok, nil
+}
+
+// inventoryScanned inventorie un fichier analysé, sous la limite -max-findings.
+func (pa *PHPAnalyzer) inventoryScanned(scanned *scannedFile) (dbFileInventory, bool) {
+	calls := pa.limitFindings(pa.dedupFindings(scanned.findings))
+	if len(calls) == 0 && len(scanned.schema) == 0 {
+		return dbFileInventory{}, false
+	}
+	return inventoryFile(scanned.path, scanned.functions, calls, scanned.schema), true
+}
+
+// InventoryDirectoryDBCalls inventorie les appels à la base de données des fichiers PHP
+// des chemins donnés ; les fichiers sans appel ni définition de table sont omis.
+func (pa *PHPAnalyzer) InventoryDirectoryDBCalls(ctx context.Context, paths ...string) ([]dbFileInventory, error) {
+	var files []dbFileInventory
+	err := pa.inventoryFiles(ctx, paths, func(file dbFileInventory) { files = append(files, file) })
+	return files, err
+}
+
+// inventoryFiles inventorie les fichiers PHP des chemins donnés et passe à emit, dans
+// l'ordre du parcours, ceux qui ont des appels ou des définitions de tables.
+func (pa *PHPAnalyzer) inventoryFiles(ctx context.Context, paths []string, emit func(dbFileInventory)) error {
+	root := scanRoot(paths)
+	return scanPHPFiles(ctx, paths, pa, func(ctx context.Context, fa *PHPAnalyzer, path string) *scannedFile {
+		file, err := fa.scanDBFile(ctx, root, path)
+		if err != nil && !fa.interrupted(ctx, path) {
+			fa.fileError("Erreur d'analyse du fichier %q: %v", path, err)
+		}
+		return file
+	}, func(scanned *scannedFile) {
+		if scanned == nil {
+			return
+		}
+		if file, ok := pa.inventoryScanned(scanned); ok {
+			emit(file)
+		}
+	})
+}
+
+// inventoryDBCalls inventorie le fichier -file puis les chemins d'un scan (dbcalls
+// -format=json ou jsonl).
+func (pa *PHPAnalyzer) inventoryDBCalls(ctx context.Context, filePath string, dirPaths []string, profiling *profileFlags, emit func(dbFileInventory)) error {
+	if filePath != "" {
+		file, ok, err := pa.inventoryDBFile(ctx, filePath)
+		if err != nil {
+			return fmt.Errorf("Erreur lors du parsing du fichier %q: %v", filePath, err)
+		}
+		if ok {
+			emit(file)
+		}
+	}
+	if len(dirPaths) == 0 {
+		return nil
+	}
+	if err := applyProfile(ctx, pa, profiling, dirPaths...); err != nil {
+		return err
+	}
+	if err := pa.inventoryFiles(ctx, dirPaths, emit); err != nil {
+		return fmt.Errorf("Erreur lors de la traversée de %s: %v", quotePaths(dirPaths), err)
+	}
+	return nil
+}
+
+// jsonlDBFile est l'inventaire d'un fichier dans la sortie JSON Lines de dbcalls.
+type jsonlDBFile struct {
+	Type string `json:"type"` // "file"
+	dbFileInventory
+}
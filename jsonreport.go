@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Formats de sortie des commandes, choisis par l'option globale -format.
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatJSONL    = "jsonl"
+	formatJUnit    = "junit"
+	formatMarkdown = "markdown"
+	formatTemplate = "template"
+)
+
+// jsonFinding est un résultat d'analyse dans la sortie JSON.
+type jsonFinding struct {
+	File        string   `json:"file"`
+	Line        uint32   `json:"line"`
+	Column      uint32   `json:"column"`
+	EndLine     uint32   `json:"endLine,omitempty"`
+	EndColumn   uint32   `json:"endColumn,omitempty"`
+	Rule        string   `json:"rule"`
+	CWE         string   `json:"cwe,omitempty"`
+	Severity    string   `json:"severity"`
+	Confidence  string   `json:"confidence"`
+	Message     string   `json:"message"`
+	OWASP       string   `json:"owasp,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+	References  []string `json:"references,omitempty"`
+	Fingerprint string   `json:"fingerprint,omitempty"`
+
+	Blame *BlameInfo `json:"blame,omitempty"` // dernière modification de la ligne (-blame)
+
+	code string // ligne de code du résultat, pour la sortie Markdown
+}
+
+// jsonDeadCode est un nœud de code mort du CFG dans la sortie JSON.
+type jsonDeadCode struct {
+	File   string `json:"file"`
+	Line   uint32 `json:"line"`
+	Column uint32 `json:"column"`
+	Node   int    `json:"node"`
+	Type   string `json:"type"`
+	Code   string `json:"code"`
+}
+
+// jsonMetric est une mesure portant sur un fichier (branches, deadCode).
+type jsonMetric struct {
+	File   string `json:"file"`
+	Metric string `json:"metric"`
+	Value  int    `json:"value"`
+}
+
+// jsonReport rassemble les résultats d'une commande pour les écrire en un seul document
+// JSON, JUnit ou Markdown. Les messages qui accompagnent la sortie texte (baseline...) y sont repris.
+// Il peut être alimenté par plusieurs goroutines.
+type jsonReport struct {
+	mu       sync.Mutex
+	Findings []jsonFinding  `json:"findings"`
+	DeadCode []jsonDeadCode `json:"deadCode"`
+	Metrics  []jsonMetric   `json:"metrics"`
+	Messages []string       `json:"messages,omitempty"`
+	Summary  *scanSummary   `json:"summary,omitempty"` // scans de plusieurs fichiers
+
+	analyzed []string // fichiers analysés par cve et analyze-dir, avec ou sans résultat
+
+	// stream reçoit les résultats au fil de l'analyse, un objet JSON par ligne
+	// (-format=jsonl), plutôt que de les rassembler (nil = rassemblés).
+	stream *json.Encoder
+
+	// template met en forme le rapport avec -format=template.
+	template *template.Template
+}
+
+func newJSONReport() *jsonReport {
+	return &jsonReport{Findings: []jsonFinding{}, DeadCode: []jsonDeadCode{}, Metrics: []jsonMetric{}}
+}
+
+// newJSONLinesReport crée un rapport qui écrit chaque résultat sur out dès que son
+// fichier est analysé, pour les consommateurs qui traitent un gros scan au fil de l'eau.
+func newJSONLinesReport(out io.Writer) *jsonReport {
+	r := newJSONReport()
+	r.stream = newJSONLinesEncoder(out)
+	return r
+}
+
+func newJSONLinesEncoder(out io.Writer) *json.Encoder {
+	encoder := json.NewEncoder(out)
+	encoder.SetEscapeHTML(false)
+	return encoder
+}
+
+// Enregistrements de la sortie JSON Lines, distingués par leur champ "type".
+type (
+	jsonlFinding struct {
+		Type string `json:"type"` // "finding"
+		jsonFinding
+	}
+	jsonlDeadCode struct {
+		Type string `json:"type"` // "deadCode"
+		jsonDeadCode
+	}
+	jsonlMetric struct {
+		Type string `json:"type"` // "metric"
+		jsonMetric
+	}
+	jsonlMessage struct {
+		Type    string `json:"type"` // "message"
+		Message string `json:"message"`
+	}
+	jsonlSummary struct {
+		Type string `json:"type"` // "summary"
+		scanSummary
+	}
+)
+
+// jsonCommands liste les commandes dont les résultats sont rassemblés dans un jsonReport
+// avec -format=json ou template ; dbcalls écrit son propre inventaire.
+var jsonCommands = map[string]bool{"count": true, "cve": true, "analyze-dir": true, "dead": true, "deadcount": true}
+
+// messages retourne la destination des messages d'information d'une commande : le
+// rapport lorsqu'il y en a un, out sinon.
+func (pa *PHPAnalyzer) messages(out io.Writer) io.Writer {
+	if pa.report != nil {
+		return pa.report
+	}
+	return out
+}
+
+// lineColumn retourne la colonne du premier caractère non blanc d'une ligne, à défaut de
+// position plus précise pour un résultat.
+func lineColumn(source []byte, line uint32) uint32 {
+	text := sourceLine(source, line)
+	return uint32(len(text)-len(strings.TrimLeft(text, " \t"))) + 1
+}
+
+func (r *jsonReport) addFindings(path string, source []byte, findings []Finding) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.analyzed = append(r.analyzed, path)
+	records := make([]jsonFinding, 0, len(findings))
+	for _, f := range findings {
+		column := f.Column
+		if column == 0 {
+			column = lineColumn(source, f.Line)
+		}
+		records = append(records, jsonFinding{
+			File: path, Line: f.Line, Column: column, EndLine: f.EndLine, EndColumn: f.EndColumn, Rule: f.RuleID, CWE: f.CWE,
+			Severity: f.Severity.String(), Confidence: f.Confidence.String(), Message: f.Message,
+			OWASP: f.OWASP, Remediation: f.Remediation, References: f.References, Fingerprint: f.Fingerprint, Blame: f.Blame,
+			code: strings.TrimRight(sourceLine(source, f.Line), "\r"),
+		})
+	}
+	if r.stream == nil {
+		r.Findings = append(r.Findings, records...)
+		return
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].less(records[j]) })
+	for _, record := range records {
+		r.stream.Encode(jsonlFinding{"finding", record})
+	}
+}
+
+func (r *jsonReport) addDeadCode(path string, cfg *CFG, ids []int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, id := range ids {
+		if node, exists := cfg.Nodes[id]; exists {
+			record := jsonDeadCode{File: path, Line: node.Line, Column: node.Column, Node: node.ID, Type: node.Type, Code: node.code}
+			if r.stream != nil {
+				r.stream.Encode(jsonlDeadCode{"deadCode", record})
+			} else {
+				r.DeadCode = append(r.DeadCode, record)
+			}
+		}
+	}
+}
+
+func (r *jsonReport) addMetric(path, metric string, value int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	record := jsonMetric{File: path, Metric: metric, Value: value}
+	if r.stream != nil {
+		r.stream.Encode(jsonlMetric{"metric", record})
+		return
+	}
+	r.Metrics = append(r.Metrics, record)
+}
+
+// Write ajoute aux messages du rapport les lignes écrites, pour les commandes qui
+// informent l'utilisateur en plus de leurs résultats.
+func (r *jsonReport) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, line := range strings.Split(strings.TrimRight(string(p), "\n"), "\n") {
+		if line == "" {
+			continue
+		}
+		if r.stream != nil {
+			r.stream.Encode(jsonlMessage{"message", line})
+		} else {
+			r.Messages = append(r.Messages, line)
+		}
+	}
+	return len(p), nil
+}
+
+// less ordonne les résultats d'un fichier comme findingLess, la colonne étant celle du
+// rapport.
+func (f jsonFinding) less(g jsonFinding) bool {
+	if f.Line != g.Line {
+		return f.Line < g.Line
+	}
+	if f.Column != g.Column {
+		return f.Column < g.Column
+	}
+	if f.Rule != g.Rule {
+		return f.Rule < g.Rule
+	}
+	return f.Message < g.Message
+}
+
+// sort trie les résultats par fichier, position et règle, indépendamment de l'ordre dans
+// lequel les fichiers ont été analysés.
+func (r *jsonReport) sort() {
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.File != b.File {
+			return pathLess(a.File, b.File)
+		}
+		return a.less(b)
+	})
+	sort.SliceStable(r.DeadCode, func(i, j int) bool {
+		if r.DeadCode[i].File != r.DeadCode[j].File {
+			return pathLess(r.DeadCode[i].File, r.DeadCode[j].File)
+		}
+		return r.DeadCode[i].Node < r.DeadCode[j].Node
+	})
+	sort.SliceStable(r.Metrics, func(i, j int) bool { return pathLess(r.Metrics[i].File, r.Metrics[j].File) })
+}
+
+// write écrit le rapport dans le format de sortie : JSON indenté, JUnit XML ou Markdown.
+// En JSON Lines, les résultats sont déjà écrits et seul le résumé reste à écrire.
+func (r *jsonReport) write(out io.Writer, format string) error {
+	switch format {
+	case formatJUnit:
+		return writeJUnit(out, r)
+	case formatMarkdown:
+		return writeMarkdown(out, r)
+	case formatTemplate:
+		return writeTemplate(out, r)
+	case formatJSONL:
+		return writeJSONLinesSummary(r.stream, r.Summary)
+	}
+	r.sort()
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(r)
+}
+
+// writeJSONLinesSummary termine une sortie JSON Lines par le résumé du scan, s'il y en a un.
+func writeJSONLinesSummary(encoder *json.Encoder, summary *scanSummary) error {
+	if summary == nil {
+		return nil
+	}
+	return encoder.Encode(jsonlSummary{"summary", *summary})
+}
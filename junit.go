@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// findingCommands liste les commandes dont les résultats sont écrits en JUnit XML ou en
+// Markdown avec -format=junit ou -format=markdown ; les autres gardent leur sortie texte.
+var findingCommands = map[string]bool{"cve": true, "analyze-dir": true}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// junitFailureText détaille un résultat dans le corps de son échec : position, sévérité,
+// confiance, métadonnées de la règle et empreinte.
+func junitFailureText(f jsonFinding) string {
+	lines := []string{fmt.Sprintf("%s:%d:%d [%s] %s (confiance %s)", f.File, f.Line, f.Column, f.Severity, f.Message, f.Confidence)}
+	if f.CWE != "" {
+		lines = append(lines, f.CWE)
+	}
+	if f.OWASP != "" {
+		lines = append(lines, "OWASP "+f.OWASP)
+	}
+	if f.Remediation != "" {
+		lines = append(lines, "Correction : "+f.Remediation)
+	}
+	for _, ref := range f.References {
+		lines = append(lines, "Référence : "+ref)
+	}
+	if f.Fingerprint != "" {
+		lines = append(lines, "Empreinte : "+f.Fingerprint)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// writeJUnit écrit les résultats du rapport en JUnit XML : une suite par fichier analysé,
+// un cas en échec par résultat, et un cas réussi pour un fichier sans résultat. Les
+// messages d'information sont placés en commentaires.
+func writeJUnit(out io.Writer, r *jsonReport) error {
+	suites := map[string]*junitTestSuite{}
+	suite := func(file string) *junitTestSuite {
+		if suites[file] == nil {
+			suites[file] = &junitTestSuite{Name: file}
+		}
+		return suites[file]
+	}
+	for _, file := range r.analyzed {
+		suite(file)
+	}
+	r.sort()
+	for _, f := range r.Findings {
+		s := suite(f.File)
+		s.Cases = append(s.Cases, junitTestCase{
+			Name:      fmt.Sprintf("%s (ligne %d, colonne %d)", f.Rule, f.Line, f.Column),
+			ClassName: f.File,
+			Failure:   &junitFailure{Message: f.Message, Type: f.Rule, Text: junitFailureText(f)},
+		})
+		s.Failures++
+	}
+
+	report := junitTestSuites{Name: "php-analyzer"}
+	for _, s := range suites {
+		if len(s.Cases) == 0 {
+			s.Cases = []junitTestCase{{Name: "analyse", ClassName: s.Name}}
+		}
+		s.Tests = len(s.Cases)
+		report.Tests += s.Tests
+		report.Failures += s.Failures
+		report.Suites = append(report.Suites, *s)
+	}
+	sort.Slice(report.Suites, func(i, j int) bool { return report.Suites[i].Name < report.Suites[j].Name })
+
+	if _, err := io.WriteString(out, xml.Header); err != nil {
+		return err
+	}
+	for _, message := range r.Messages {
+		fmt.Fprintf(out, "<!-- %s -->\n", strings.ReplaceAll(message, "--", "- -"))
+	}
+	encoder := xml.NewEncoder(out)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(out, "\n")
+	return err
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// laravelDBStatements associe les méthodes de la façade DB dont le premier argument est
+// une requête SQL au type d'instruction qu'elles exécutent ("" si la requête le dit).
+// DB::raw() n'exécute rien mais insère son argument tel quel dans la requête.
+var laravelDBStatements = map[string]string{
+	"select":             sqlSelect,
+	"selectone":          sqlSelect,
+	"scalar":             sqlSelect,
+	"cursor":             sqlSelect,
+	"insert":             sqlInsert,
+	"update":             sqlUpdate,
+	"delete":             sqlDelete,
+	"statement":          "",
+	"affectingstatement": "",
+	"unprepared":         "",
+	"raw":                "",
+}
+
+// laravelRawMethods liste les méthodes du Query Builder qui insèrent leur premier
+// argument tel quel dans la requête.
+var laravelRawMethods = map[string]bool{
+	"selectRaw":   true,
+	"fromRaw":     true,
+	"whereRaw":    true,
+	"orWhereRaw":  true,
+	"havingRaw":   true,
+	"orHavingRaw": true,
+	"orderByRaw":  true,
+	"groupByRaw":  true,
+}
+
+// laravelBuilderStarters liste les méthodes statiques d'un modèle Eloquent qui
+// commencent une requête du Query Builder.
+var laravelBuilderStarters = map[string]bool{
+	"query": true, "where": true, "orWhere": true, "whereIn": true, "whereNotIn": true,
+	"whereNull": true, "whereNotNull": true, "whereBetween": true, "whereHas": true,
+	"whereRaw": true, "select": true, "selectRaw": true, "with": true, "withCount": true,
+	"orderBy": true, "latest": true, "oldest": true, "limit": true, "take": true,
+	"join": true, "leftJoin": true, "withTrashed": true, "onlyTrashed": true,
+}
+
+// laravelQueries associe les méthodes qui exécutent une requête du Query Builder (ou,
+// appelées statiquement, sur un modèle Eloquent) au type d'instruction exécutée.
+var laravelQueries = map[string]string{
+	"get": sqlSelect, "first": sqlSelect, "firstOrFail": sqlSelect, "firstWhere": sqlSelect,
+	"find": sqlSelect, "findOrFail": sqlSelect, "findMany": sqlSelect, "all": sqlSelect,
+	"pluck": sqlSelect, "value": sqlSelect, "count": sqlSelect, "exists": sqlSelect,
+	"sum": sqlSelect, "avg": sqlSelect, "min": sqlSelect, "max": sqlSelect,
+	"paginate": sqlSelect, "simplePaginate": sqlSelect, "cursor": sqlSelect, "chunk": sqlSelect,
+	"insert": sqlInsert, "insertOrIgnore": sqlInsert, "insertGetId": sqlInsert, "create": sqlInsert,
+	"firstOrCreate": sqlInsert, "updateOrCreate": sqlUpdate, "upsert": sqlInsert,
+	"update": sqlUpdate, "increment": sqlUpdate, "decrement": sqlUpdate,
+	"delete": sqlDelete, "destroy": sqlDelete, "forceDelete": sqlDelete, "truncate": sqlDDL,
+}
+
+// laravelFacades liste les façades courantes qui ne sont pas des modèles Eloquent, bien
+// qu'elles partagent des noms de méthodes (Cache::get, Request::all...).
+var laravelFacades = map[string]bool{
+	"app": true, "arr": true, "auth": true, "cache": true, "config": true, "cookie": true,
+	"event": true, "gate": true, "http": true, "input": true, "lang": true, "log": true,
+	"mail": true, "queue": true, "redirect": true, "redis": true, "request": true,
+	"response": true, "route": true, "session": true, "storage": true, "str": true,
+	"url": true, "validator": true, "view": true, "collection": true, "carbon": true,
+}
+
+// classBaseName retourne le nom d'une classe sans son espace de noms.
+func classBaseName(name string) string {
+	return name[strings.LastIndex(name, `\`)+1:]
+}
+
+// laravelDBCall découpe le nom d'un appel statique à la façade DB (DB::select,
+// \Illuminate\Support\Facades\DB::statement...) et retourne sa méthode en minuscules.
+func laravelDBCall(funcName string) (string, bool) {
+	class, method, ok := strings.Cut(funcName, "::")
+	if !ok || classBaseName(class) != "DB" {
+		return "", false
+	}
+	return strings.ToLower(method), true
+}
+
+// laravelQuery reconnaît l'exécution d'une requête Eloquent ou du Query Builder :
+// appel statique sur un modèle (User::find(1)), ou méthode d'exécution au bout d'une
+// chaîne commençant par un modèle ou par DB::table() (User::where(...)->get()). Elle
+// retourne l'appel tel qu'il est affiché et l'instruction exécutée.
+func laravelQuery(node *sitter.Node, source []byte) (string, *SQLStatement, bool) {
+	name := node.ChildByFieldName("name")
+	if name == nil {
+		return "", nil, false
+	}
+	method := name.Content(source)
+	kind, ok := laravelQueries[method]
+	if !ok {
+		return "", nil, false
+	}
+	root := node
+	if node.Type() == "member_call_expression" {
+		for root.Type() == "member_call_expression" {
+			root = root.ChildByFieldName("object")
+		}
+		if root == nil || root.Type() != "scoped_call_expression" {
+			return "", nil, false
+		}
+	}
+	scope, rootName := root.ChildByFieldName("scope"), root.ChildByFieldName("name")
+	if scope == nil || rootName == nil {
+		return "", nil, false
+	}
+	class := classBaseName(scope.Content(source))
+	statement := &SQLStatement{Kind: kind}
+	switch {
+	case class == "DB":
+		if node == root || rootName.Content(source) != "table" {
+			return "", nil, false
+		}
+		if args := getArgumentNodes(root); len(args) > 0 {
+			if table, ok := resolveSQL(argumentValue(args[0]), source); ok {
+				statement.Tables = []string{table}
+			}
+		}
+	case laravelFacades[strings.ToLower(class)]:
+		return "", nil, false
+	case node != root && !laravelBuilderStarters[rootName.Content(source)]:
+		return "", nil, false
+	}
+	call := fmt.Sprintf("%s::%s(...)", class, rootName.Content(source))
+	if node != root {
+		call += fmt.Sprintf("->%s()", method)
+	}
+	return call, statement, true
+}
+
+// laravelDatabaseCalls inventorie les appels à la base de données de Laravel : façade
+// DB, requêtes Eloquent et du Query Builder, et fragments SQL bruts (*Raw), signalés
+// comme plus risqués lorsque leur argument est construit dynamiquement.
+func laravelDatabaseCalls(n *sitter.Node, funcName string, source []byte) []Finding {
+	line := n.StartPoint().Row + 1
+	var calls []Finding
+	if method, ok := laravelDBCall(funcName); ok && n.Type() == "scoped_call_expression" {
+		if kind, ok := laravelDBStatements[method]; ok {
+			statement := sqlStatement(n, funcName, source)
+			if statement == nil && kind != "" {
+				statement = &SQLStatement{Kind: kind}
+			}
+			call := fmt.Sprintf("DB::%s(...)", n.ChildByFieldName("name").Content(source))
+			calls = append(calls, rawSQLFinding(n, call, line, statement, source))
+		}
+	}
+	if n.Type() == "member_call_expression" && laravelRawMethods[funcName] {
+		calls = append(calls, rawSQLFinding(n, fmt.Sprintf("->%s(...)", funcName), line, nil, source))
+	}
+	if call, statement, ok := laravelQuery(n, source); ok {
+		// Le Query Builder lie les valeurs ; les fragments bruts sont inventoriés à part.
+		calls = append(calls, dbCallFinding(call, line, "Appel trouvé : "+call, statement, QueryParameterized))
+	}
+	return calls
+}
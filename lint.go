@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// LintOptions règle le détecteur lint.
+type LintOptions struct {
+	// DocblockImports signale aussi les imports que seuls des docblocks citent
+	// (@param User $user) ; par défaut, ces citations valent une utilisation.
+	DocblockImports bool `yaml:"docblock-imports"`
+}
+
+// detectLint signale les défauts de qualité du code qui ne sont pas des vulnérabilités :
+// variables affectées mais jamais lues, imports jamais utilisés.
+func detectLint(pa *PHPAnalyzer, root *sitter.Node, source []byte) []Finding {
+	var findings []Finding
+	traverseAST(root, func(n *sitter.Node) {
+		switch n.Type() {
+		case "function_definition", "method_declaration", "anonymous_function_creation_expression":
+		default:
+			return
+		}
+		du := collectDefUse(n, source)
+		for _, name := range du.unused() {
+			assignment := du.defs[name][0]
+			findings = append(findings, newFinding("unused-variable", assignment.StartPoint().Row+1,
+				fmt.Sprintf("variable %s affectée mais jamais lue", name)).at(assignment))
+		}
+	})
+	for _, imported := range unusedImports(root, source, !pa.Lint.DocblockImports) {
+		clause := imported.Name
+		if imported.Alias != imported.Name[strings.LastIndex(imported.Name, `\`)+1:] {
+			clause += " as " + imported.Alias
+		}
+		switch imported.Kind {
+		case SymbolFunction:
+			clause = "function " + clause
+		case SymbolConstant:
+			clause = "const " + clause
+		}
+		findings = append(findings, newFinding("unused-import", imported.Line,
+			fmt.Sprintf("use %s importé mais jamais utilisé", clause)))
+	}
+	return findings
+}
+
+// unusedImports retourne les imports d'un fichier dont le nom n'est jamais utilisé par
+// son code : classe nommée (new, extends, type, attribut, User::class...), espace de noms
+// préfixant un nom qualifié (Models\User), fonction appelée ou constante lue. Avec
+// docblocks, un import de classe cité par un docblock de son espace de noms est utilisé.
+func unusedImports(root *sitter.Node, source []byte, docblocks bool) []useImport {
+	resolver := newNameResolver(root, source)
+	if len(resolver.imports) == 0 {
+		return nil
+	}
+	classes := map[string]bool{}   // noms qualifiés des classes nommées, en minuscules
+	functions := map[string]bool{} // fonctions appelées par un nom importé, en minuscules
+	constants := map[string]bool{} // noms de constantes importées lus, précédés de leur espace de noms
+	aliases := map[string]bool{}   // noms locaux des constantes importées
+	for _, imported := range resolver.imports {
+		if imported.Kind == SymbolConstant {
+			aliases[imported.Alias] = true
+		}
+	}
+	var comments []*sitter.Node
+	for _, reference := range resolver.classReferences() {
+		if !strings.HasPrefix(reference.Written, `\`) {
+			classes[strings.ToLower(reference.Name)] = true
+		}
+	}
+	// addClass retient la classe nommée par n, sauf si son nom complètement qualifié
+	// (\App\User) se passe des imports.
+	addClass := func(n *sitter.Node) {
+		if n != nil && (n.Type() == "name" || n.Type() == "qualified_name") && !strings.HasPrefix(n.Content(source), `\`) {
+			if name := resolver.resolveClass(n.Content(source), n.StartPoint().Row+1); name != "" {
+				classes[strings.ToLower(name)] = true
+			}
+		}
+	}
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		switch n.Type() {
+		case "namespace_use_declaration":
+			return
+		case "namespace_definition":
+			if body := n.ChildByFieldName("body"); body != nil {
+				walk(body)
+			}
+			return
+		case "named_type", "attribute":
+			addClass(n.NamedChild(0))
+		case "qualified_name":
+			addClass(n) // le premier segment peut être un espace de noms importé
+			return
+		case "function_call_expression":
+			if function := n.ChildByFieldName("function"); function != nil && function.Type() == "name" {
+				line := function.StartPoint().Row + 1
+				if name := resolver.resolveFunction(function.Content(source), line); strings.HasPrefix(name, `\`) {
+					functions[strings.ToLower(name[1:])] = true
+				}
+			}
+		case "name":
+			if aliases[n.Content(source)] {
+				line := n.StartPoint().Row + 1
+				constants[namespaceAtLine(root, line, source)+`\`+n.Content(source)] = true
+			}
+			return
+		case "comment":
+			if strings.HasPrefix(n.Content(source), "/**") {
+				comments = append(comments, n)
+			}
+			return
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			walk(n.NamedChild(i))
+		}
+	}
+	walk(root)
+
+	var unused []useImport
+	for _, imported := range resolver.imports {
+		switch imported.Kind {
+		case SymbolFunction:
+			if functions[strings.ToLower(imported.Name)] {
+				continue
+			}
+		case SymbolConstant:
+			if constants[imported.Namespace+`\`+imported.Alias] {
+				continue
+			}
+		default:
+			if namesClass(classes, strings.ToLower(imported.Name)) ||
+				docblocks && citedByDocblock(root, source, comments, imported) {
+				continue
+			}
+		}
+		unused = append(unused, imported)
+	}
+	return unused
+}
+
+// namesClass vérifie si la classe name, ou une classe de l'espace de noms name, fait
+// partie des classes nommées.
+func namesClass(classes map[string]bool, name string) bool {
+	if classes[name] {
+		return true
+	}
+	for class := range classes {
+		if strings.HasPrefix(class, name+`\`) {
+			return true
+		}
+	}
+	return false
+}
+
+// citedByDocblock vérifie si un docblock de l'espace de noms d'un import de classe cite
+// son nom local (@param User $user, @return Models\User[]).
+func citedByDocblock(root *sitter.Node, source []byte, comments []*sitter.Node, imported useImport) bool {
+	pattern := regexp.MustCompile(`(?i)(^|[^\w\\$])` + regexp.QuoteMeta(imported.Alias) + `\b`)
+	for _, comment := range comments {
+		if namespaceAtLine(root, comment.StartPoint().Row+1, source) == imported.Namespace && pattern.MatchString(comment.Content(source)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnusedVariables(t *testing.T) {
+	source := []byte(`<?php
+$global = 1;
+function total(array $items, &$out, $param) {
+    $count = count($items);
+    $sum = 0;
+    foreach ($items as $item) { $sum += $item; }
+    $out = $sum;
+    [$a, $b] = explode(',', 'x,y');
+    $tmp = 'x';
+    $tmp .= 'y';
+    $cb = function () use ($sum, &$shared) { $inner = 1; $shared = 2; return $sum; };
+    $fn = fn($x) => $x + $a;
+    static $calls = 0;
+    $calls = 1;
+    global $config;
+    $config = [];
+    $_SESSION = [];
+    $view = 'v';
+    return $cb() + $fn(1) + compact('view');
+}
+function opaque() { $x = 1; return get_defined_vars(); }
+function variable($name) { $y = 1; return $$name; }
+class Page { public function show() { $this->title = 't'; $unused = $this->title; } }
+`)
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, analyzer.SelectRules(RuleSelection{Only: []string{"lint"}}))
+	tree, err := analyzer.Parse(context.Background(), source)
+	assert.NoError(t, err)
+	var got []string
+	for _, f := range analyzer.DetectVulnerabilities(context.Background(), tree, source) {
+		assert.Equal(t, "unused-variable", f.RuleID)
+		got = append(got, f.Message)
+	}
+	assert.Equal(t, []string{
+		"variable $count affectée mais jamais lue",
+		"variable $b affectée mais jamais lue",
+		"variable $tmp affectée mais jamais lue",
+		"variable $inner affectée mais jamais lue",
+		"variable $unused affectée mais jamais lue",
+	}, got, "superglobals, references, static and global variables, and scopes reading variables by name are left out")
+
+	analyzer = NewPHPAnalyzer()
+	assert.Empty(t, analyzer.DetectVulnerabilities(context.Background(), tree, source), "the lint category is disabled by default")
+}
+
+func TestUnusedImports(t *testing.T) {
+	source := []byte(`<?php
+namespace App\Http;
+
+use App\Models\User;
+use App\Models\Post as Article;
+use App\Models\Comment;
+use App\Models\Tag;
+use App\Services;
+use App\Events\{Created, Deleted};
+use Psr\Log\LoggerInterface;
+use function App\Support\format_date;
+use function App\Support\slugify;
+use const App\Support\MAX_ITEMS;
+use const App\Support\MIN_ITEMS;
+
+/**
+ * @param LoggerInterface $logger
+ */
+#[Created]
+class Controller extends Base {
+    public function show(?User $user): Article {
+        $tags = Services\TagService::all();
+        return format_date(MAX_ITEMS, $tags, $user);
+    }
+    public function comment() { return new \App\Models\Comment(); }
+}
+`)
+	run := func(options LintOptions) []string {
+		analyzer := NewPHPAnalyzer()
+		analyzer.Lint = options
+		assert.NoError(t, analyzer.SelectRules(RuleSelection{Only: []string{"unused-import"}}))
+		tree, err := analyzer.Parse(context.Background(), source)
+		assert.NoError(t, err)
+		var got []string
+		for _, f := range analyzer.DetectVulnerabilities(context.Background(), tree, source) {
+			got = append(got, f.Message)
+		}
+		return got
+	}
+	assert.Equal(t, []string{
+		"use App\\Models\\Comment importé mais jamais utilisé",
+		"use App\\Models\\Tag importé mais jamais utilisé",
+		"use App\\Events\\Deleted importé mais jamais utilisé",
+		"use function App\\Support\\slugify importé mais jamais utilisé",
+		"use const App\\Support\\MIN_ITEMS importé mais jamais utilisé",
+	}, run(LintOptions{}), "fully qualified names do not use the import, docblock mentions do")
+	assert.Contains(t, run(LintOptions{DocblockImports: true}), "use Psr\\Log\\LoggerInterface importé mais jamais utilisé")
+}
@@ -2,67 +2,232 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"path/filepath"
-	"regexp"
+	"os/signal"
 	"strings"
+	"sync"
+	"syscall"
+	"text/template"
+	"time"
 
 	sitter "github.com/smacker/go-tree-sitter"
-	"github.com/smacker/go-tree-sitter/php"
 )
 
-// DatabaseCall représente un appel à une base de données détecté dans le code.
-type DatabaseCall struct {
-	Function    string
-	Line        uint32
-	Description string
-}
+// PHPAnalyzer fournit des méthodes pour analyser le code PHP. Une fois configuré, un
+// analyseur peut être utilisé depuis plusieurs goroutines : Parse, ParseFile et les
+// méthodes Detect* empruntent leurs parseurs à un pool, l'arbre d'un fichier restant
+// propre à la goroutine qui l'a parsé.
+type PHPAnalyzer struct {
+	Profile      ScanProfile
+	Out          io.Writer    // destination des résultats des analyses de dossier
+	MaxWidth     int          // largeur maximale des lignes affichées (0 = aucune limite)
+	ContextLines int          // lignes de contexte autour du code signalé (-1 = aucun extrait)
+	Format       string       // format de sortie : text ou json
+	report       *jsonReport  // résultats rassemblés de la commande en cours (-format=json)
+	cache        *treeCache   // arbres déjà parsés, conservés par le démon
+	results      *resultCache // résultats des scans précédents (nil = sans cache)
+	Stdin        io.Reader    // entrée lue avec -file=- (nil = indisponible, dans le démon)
+	stdinPiped   bool         // entrée standard redirigée, lue lorsque -file et -dir sont omis
+	Dir          string       // dossier des chemins relatifs des commandes ("" = dossier courant)
 
-// Detection regroupe les informations relatives à une vulnérabilité détectée.
-type Detection struct {
-	CVE     string
-	Line    uint32
-	Message string
-}
+	// Template met en forme les résultats avec -format=template.
+	Template *template.Template
 
-// PHPAnalyzer encapsule le parseur et fournit des méthodes pour analyser le code PHP.
-type PHPAnalyzer struct {
-	parser *sitter.Parser
+	// TaintDBReads considère les valeurs lues en base comme contaminées, afin de
+	// signaler les XSS stockés et les injections SQL de second ordre.
+	TaintDBReads bool
+
+	// Calibration déclasse les règles souvent marquées comme faux positifs au triage.
+	Calibration Calibration
+
+	// Baseline masque les résultats déjà présents lors de son enregistrement (nil = aucune).
+	Baseline *Baseline
+
+	// Signatures de CVE appliquées aux appels, et version de PHP ciblée pour les
+	// restreindre aux versions affectées ("" = toutes les versions).
+	Signatures *SignatureSet
+	PHPVersion string
+
+	detectors  map[string]bool   // détecteurs activés ou désactivés explicitement
+	rules      ruleFilter        // règles retenues (SelectRules)
+	severities severityOverrides // sévérités surchargées par le projet (OverrideSeverities)
+	functions  functionIndex     // fonctions définies par le projet (IndexProject)
+	symbols    *SymbolIndex      // symboles définis par le projet (IndexProject)
+	classes    classIndex        // classes définies par le projet (IndexProject)
+	calls      *CallGraph        // graphe d'appels du projet (IndexProject)
+	timings    *detectorTimings  // temps passé dans chaque détecteur (bench, nil = non mesuré)
+
+	// MinSeverity et MaxFindings restreignent les résultats signalés (-min-severity,
+	// -max-findings, 0 = aucune limite).
+	MinSeverity Severity
+	MaxFindings int
+
+	// Exclude liste les motifs des chemins ignorés lors du parcours des dossiers
+	// (-exclude, defaultExcludes par défaut) ; Gitignore applique aussi les fichiers
+	// .gitignore, en plus des fichiers .phpanalyzerignore.
+	Exclude   []string
+	Gitignore bool
+
+	// Walk règle le parcours des dossiers : liens symboliques, profondeur, dossiers
+	// cachés et extensions des fichiers analysés.
+	Walk WalkOptions
+
+	// Lint règle le détecteur lint (section lint de la configuration du projet).
+	Lint LintOptions
+
+	// changed restreint l'analyse aux fichiers modifiés depuis une révision git
+	// (-changed-since, nil = aucune restriction) ; changedLines, à leurs lignes modifiées.
+	changed      changeSet
+	changedLines bool
+
+	// Blame attribue chaque résultat au dernier commit de sa ligne (-blame).
+	Blame bool
+
+	// MaxFileSize ignore, avec un avertissement, les fichiers plus gros (-max-file-size,
+	// 0 = celle du profil en mode "fast", aucune sinon) ; MemoryBudget remplace la limite
+	// mémoire du profil (-memory-budget, 0 = celle du profil).
+	MaxFileSize  int64
+	MemoryBudget int64
+
+	// FileTimeout interrompt l'analyse d'un fichier qui dure plus longtemps
+	// (-timeout-per-file, 0 = aucune limite) ; le fichier est signalé en erreur.
+	FileTimeout time.Duration
+
+	// CacheDir est le dossier du cache des résultats ("" = dossier de cache de
+	// l'utilisateur), utilisé lorsque Profile.UseCache est vrai.
+	CacheDir string
+
+	// FailOn est la sévérité à partir de laquelle un résultat fait échouer la commande
+	// (code de sortie exitFindings) ; outcome compte les résultats et les erreurs.
+	FailOn  Severity
+	outcome *scanOutcome
+
+	// Store est la base SQLite où cve et analyze-dir enregistrent leurs résultats
+	// (--store, "" = aucune) ; run rassemble ceux de la commande en cours.
+	Store string
+	run   *storedRun
 }
 
 // NewPHPAnalyzer crée et initialise un analyseur pour le langage PHP.
 func NewPHPAnalyzer() *PHPAnalyzer {
-	p := sitter.NewParser()
-	p.SetLanguage(php.GetLanguage())
-	return &PHPAnalyzer{parser: p}
+	return &PHPAnalyzer{Profile: scanProfiles["default"], Out: os.Stdout, Format: formatText, Signatures: NewSignatureSet(builtinSignatures), Exclude: defaultExcludes}
+}
+
+// fork crée un analyseur partageant la configuration et le cache de pa, pour un worker
+// du scan.
+func (pa *PHPAnalyzer) fork() *PHPAnalyzer {
+	fa := NewPHPAnalyzer()
+	fa.Profile = pa.Profile
+	fa.Out = pa.Out
+	fa.MaxWidth = pa.MaxWidth
+	fa.ContextLines = pa.ContextLines
+	fa.Format = pa.Format
+	fa.report = pa.report
+	fa.MinSeverity = pa.MinSeverity
+	fa.MaxFindings = pa.MaxFindings
+	fa.Exclude = pa.Exclude
+	fa.Gitignore = pa.Gitignore
+	fa.Walk = pa.Walk
+	fa.Lint = pa.Lint
+	fa.changed = pa.changed
+	fa.changedLines = pa.changedLines
+	fa.Blame = pa.Blame
+	fa.FailOn = pa.FailOn
+	fa.outcome = pa.outcome
+	fa.run = pa.run
+	fa.TaintDBReads = pa.TaintDBReads
+	fa.Calibration = pa.Calibration
+	fa.Baseline = pa.Baseline
+	fa.Signatures = pa.Signatures
+	fa.PHPVersion = pa.PHPVersion
+	fa.detectors = pa.detectors
+	fa.rules = pa.rules
+	fa.severities = pa.severities
+	fa.functions = pa.functions
+	fa.symbols = pa.symbols
+	fa.classes = pa.classes
+	fa.cache = pa.cache
+	fa.Dir = pa.Dir
+	fa.CacheDir = pa.CacheDir
+	fa.MaxFileSize = pa.MaxFileSize
+	fa.MemoryBudget = pa.MemoryBudget
+	fa.FileTimeout = pa.FileTimeout
+	fa.results = pa.results
+	fa.timings = pa.timings
+	return fa
 }
 
 // ParseFile lit et parse un fichier PHP, renvoyant son AST et le contenu source.
-func (pa *PHPAnalyzer) ParseFile(filePath string) (*sitter.Tree, []byte, error) {
-	content, err := os.ReadFile(filePath)
+// Le chemin "-" désigne l'entrée standard. Le parsing s'arrête avec l'erreur du
+// contexte lorsque ctx est annulé.
+func (pa *PHPAnalyzer) ParseFile(ctx context.Context, filePath string) (*sitter.Tree, []byte, error) {
+	if pa.cache != nil && filePath != stdinPath {
+		return pa.cache.parse(ctx, pa, filePath)
+	}
+	var content []byte
+	var err error
+	if filePath == stdinPath {
+		content, err = pa.readStdin()
+	} else {
+		content, err = os.ReadFile(filePath)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
-	tree, err := pa.parser.ParseCtx(context.Background(), nil, content)
+	tree, err := pa.Parse(ctx, content)
 	if err != nil {
 		return nil, content, err
 	}
 	return tree, content, nil
 }
 
-// traverseAST effectue un parcours récursif de l’AST en appliquant la fonction visit à chaque nœud.
+// Parse parse du code PHP. Les parseurs tree-sitter étant empruntés à un pool, Parse
+// peut être appelé depuis plusieurs goroutines.
+func (pa *PHPAnalyzer) Parse(ctx context.Context, source []byte) (*sitter.Tree, error) {
+	return parsePHP(ctx, source)
+}
+
+// traverseAST effectue un parcours en profondeur (préfixe) de l’AST en appliquant la
+// fonction visit à chaque nœud. Le parcours suit un TreeCursor, sans récursion : un code
+// minifié ou généré très imbriqué ne peut pas épuiser la pile, et chaque déplacement ne
+// coûte qu'un appel cgo au lieu d'un appel par accès à un enfant.
 func traverseAST(node *sitter.Node, visit func(node *sitter.Node)) {
 	if node == nil {
 		return
 	}
-	// fmt.Println("Visiting node:", node.Type())
-	visit(node)
-	for i := 0; i < int(node.ChildCount()); i++ {
-		child := node.Child(i)
-		traverseAST(child, visit)
+	cursor := sitter.NewTreeCursor(node)
+	defer cursor.Close()
+	for {
+		visit(cursor.CurrentNode())
+		if cursor.GoToFirstChild() {
+			continue
+		}
+		for !cursor.GoToNextSibling() {
+			if !cursor.GoToParent() {
+				return
+			}
+		}
+	}
+}
+
+// children retourne les enfants d'un nœud, lus avec un TreeCursor.
+func children(node *sitter.Node) []*sitter.Node {
+	cursor := sitter.NewTreeCursor(node)
+	defer cursor.Close()
+	if !cursor.GoToFirstChild() {
+		return nil
+	}
+	nodes := make([]*sitter.Node, 0, node.ChildCount())
+	for {
+		nodes = append(nodes, cursor.CurrentNode())
+		if !cursor.GoToNextSibling() {
+			return nodes
+		}
 	}
 }
 
@@ -87,48 +252,41 @@ func (pa *PHPAnalyzer) CountBranches(root *sitter.Node) int {
 }
 
 // DetectDatabaseCalls recherche dans l’AST les appels a la base de données.
-func (pa *PHPAnalyzer) DetectDatabaseCalls(root *sitter.Node, source []byte) []DatabaseCall {
-	var calls []DatabaseCall
+func (pa *PHPAnalyzer) DetectDatabaseCalls(root *sitter.Node, source []byte) []Finding {
+	var calls []Finding
 
 	traverseAST(root, func(n *sitter.Node) {
-		if n.Type() == "function_call_expression" || n.Type() == "member_call_expression" {
+		if n.Type() == "function_call_expression" || n.Type() == "member_call_expression" || n.Type() == "scoped_call_expression" {
 			funcName := extractFunctionName(n, source)
 			line := n.StartPoint().Row + 1
+			found := len(calls)
+			statement := sqlStatement(n, funcName, source)
+			construction := sqlConstruction(sqlQueryArgument(n, funcName, source), source)
+			calls = append(calls, laravelDatabaseCalls(n, funcName, source)...)
+			calls = append(calls, doctrineDatabaseCalls(n, funcName, source)...)
+			calls = append(calls, driverDatabaseCalls(n, funcName, source)...)
 
 			switch funcName {
 			case "mysql_query", "mysqli_query":
-				calls = append(calls, DatabaseCall{
-					Function:    funcName,
-					Line:        line,
-					Description: fmt.Sprintf("Appel trouvé : %s", funcName),
-				})
+				calls = append(calls, dbCallFinding(funcName, line, fmt.Sprintf("Appel trouvé : %s", funcName), statement, construction))
 
 			case "execute":
 				if n.Parent() != nil && n.Parent().Type() == "member_call_expression" {
-					calls = append(calls, DatabaseCall{
-						Function:    "$object->execute()",
-						Line:        line,
-						Description: "Appel trouvé : $object->execute()",
-					})
+					calls = append(calls, dbCallFinding("$object->execute()", line, "Appel trouvé : $object->execute()", statement, executeConstruction(n, source)))
 				}
 
 			case "exec":
+				if isSQLite3Call(n, source) {
+					break
+				}
 				codeSnippet := string(source[n.StartByte():n.EndByte()])
 
 				// Vérifie si c’est la forme $object->mysql->exec()
 				if strings.Contains(codeSnippet, "->mysql->exec") {
-					calls = append(calls, DatabaseCall{
-						Function:    "$object->mysql->exec",
-						Line:        line,
-						Description: "Appel trouvé : $object->mysql->exec(*)",
-					})
+					calls = append(calls, dbCallFinding("$object->mysql->exec", line, "Appel trouvé : $object->mysql->exec(*)", statement, construction))
 				} else {
 					// Sinon, $object->exec() (générique)
-					calls = append(calls, DatabaseCall{
-						Function:    "$object->exec()",
-						Line:        line,
-						Description: "Appel trouvé : $object->exec(...)",
-					})
+					calls = append(calls, dbCallFinding("$object->exec()", line, "Appel trouvé : $object->exec(...)", statement, construction))
 				}
 
 			case "query", "get_results", "get_row", "get_col", "prepare",
@@ -136,151 +294,146 @@ func (pa *PHPAnalyzer) DetectDatabaseCalls(root *sitter.Node, source []byte) []D
 				codeSnippet := string(source[n.StartByte():n.EndByte()])
 				// Vérification qu’il s’agit bien d’un appel du type $wpdb->Xxx()
 				if strings.Contains(codeSnippet, "$wpdb->") {
-					calls = append(calls, DatabaseCall{
-						Function:    fmt.Sprintf("$wpdb->%s", funcName),
-						Line:        line,
-						Description: fmt.Sprintf("Appel trouvé : $wpdb->%s(...)", funcName),
-					})
+					// insert(), update(), delete() et replace() échappent les valeurs reçues.
+					if _, ok := wpdbStatementKinds[funcName]; ok {
+						construction = QueryParameterized
+					}
+					calls = append(calls, dbCallFinding(fmt.Sprintf("$wpdb->%s", funcName), line, fmt.Sprintf("Appel trouvé : $wpdb->%s(...)", funcName), statement, construction))
 				}
 			}
+			for i := found; i < len(calls); i++ {
+				calls[i] = calls[i].at(n)
+			}
 		}
 	})
 
 	return calls
 }
 
-// DetectVulnerabilities parcourt l’AST à la recherche de vulnérabilités connues (CVEs).
-func (pa *PHPAnalyzer) DetectVulnerabilities(root *sitter.Node, source []byte) []Detection {
-	var detections []Detection
-	traverseAST(root, func(n *sitter.Node) {
-		if n.Type() == "function_call_expression" || n.Type() == "member_call_expression" {
-			funcName := extractFunctionName(n, source)
-			line := n.StartPoint().Row + 1
-			switch funcName {
-			// CVE-2017-7189 : fsockopen avec port confusion (exemple sur UDP)
-			case "fsockopen":
-				if isFsockopenPortConfusion(n, source) {
-					detections = append(detections, Detection{
-						CVE:     "CVE-2017-7189",
-						Line:    line,
-						Message: "fsockopen UDP détecté avec conflit de port",
-					})
-				}
-			// CVE-2019-9025 : mb_split avec "\w" en premier argument
-			case "mb_split":
-				if isMbSplitW(n, source) {
-					detections = append(detections, Detection{
-						CVE:     "CVE-2019-9025",
-						Line:    line,
-						Message: `mb_split("\w") détecté`,
-					})
-				}
-			// CVE-2019-11039 : iconv_mime_decode_headers détecté
-			case "iconv_mime_decode_headers":
-				detections = append(detections, Detection{
-					CVE:     "CVE-2019-11039",
-					Line:    line,
-					Message: "iconv_mime_decode_headers(...) détecté",
-				})
-			// CVE-2020-7069 : openssl_encrypt avec AES-GCM/CCM
-			case "openssl_encrypt":
-				if isUsingGCmorCCM(n, source) {
-					detections = append(detections, Detection{
-						CVE:     "CVE-2020-7069",
-						Line:    line,
-						Message: "openssl_encrypt avec AES-GCM/CCM détecté",
-					})
-				}
-			// CVE-2020-7071 / CVE-2021-21705 : filter_var avec FILTER_VALIDATE_URL
-			case "filter_var":
-				if isFilterVarValidateURL(n, source) {
-					detections = append(detections, Detection{
-						CVE:     "CVE-2020-7071 / CVE-2021-21705",
-						Line:    line,
-						Message: "filter_var(..., FILTER_VALIDATE_URL) détecté",
-					})
-				}
-			// CVE-2021-21707 : simplexml_load_file avec chemin dynamique
-			case "simplexml_load_file":
-				if isSimplexmlLoadDynamic(n, source) {
-					detections = append(detections, Detection{
-						CVE:     "CVE-2021-21707",
-						Line:    line,
-						Message: "simplexml_load_file avec chemin dynamique détecté",
-					})
-				}
-			}
+// scannedFile est le résultat de l'analyse d'un fichier d'un dossier, transmis dans
+// l'ordre du parcours pour y appliquer la limite -max-findings.
+type scannedFile struct {
+	path      string
+	content   []byte
+	findings  []Finding
+	schema    []SchemaTable  // définitions de tables (dbcalls)
+	functions []functionSpan // fonctions du fichier, pour l'inventaire (dbcalls)
+}
+
+// AnalyzeDirectory parcourt récursivement un dossier, ou plusieurs dossiers et fichiers,
+// et analyse chaque fichier PHP pour détecter des vulnérabilités après avoir indexé les
+// fonctions qu'ils définissent. Aucun message n'est affiché si aucun résultat n'est trouvé.
+func (pa *PHPAnalyzer) AnalyzeDirectory(ctx context.Context, paths ...string) {
+	if err := pa.IndexProject(ctx, paths...); err != nil {
+		pa.fileError("Erreur lors de l'indexation des fonctions de %s: %v", quotePaths(paths), err)
+		if ctx.Err() != nil {
+			return
 		}
-	})
-	return detections
+	}
+	pa.analyzeFiles(ctx, paths)
 }
 
-// AnalyzeDirectory parcourt récursivement un dossier et analyse chaque fichier PHP pour détecter des vulnérabilités.
-// Aucun message n'est affiché si aucun résultat n'est trouvé.
-func (pa *PHPAnalyzer) AnalyzeDirectory(dirPath string) {
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+// analyzeFiles analyse les fichiers PHP des chemins d'un scan avec l'index des
+// fonctions déjà constitué.
+func (pa *PHPAnalyzer) analyzeFiles(ctx context.Context, paths []string) {
+	root := scanRoot(paths)
+	pa.results = pa.openResultCache()
+	defer func() { pa.results = nil }()
+	err := scanPHPFiles(ctx, paths, pa, func(ctx context.Context, fa *PHPAnalyzer, path string) *scannedFile {
+		tree, content, err := fa.ParseFile(ctx, path)
 		if err != nil {
-			log.Printf("Erreur d'accès à %q: %v", path, err)
+			if !fa.interrupted(ctx, path) {
+				fa.fileError("Erreur d'analyse du fichier %q: %v", path, err)
+			}
 			return nil
 		}
-		if info.IsDir() || !strings.HasSuffix(strings.ToLower(info.Name()), ".php") {
+		detections := fa.detectCached(ctx, tree, content)
+		fa.releaseTree(tree)
+		if fa.interrupted(ctx, path) {
 			return nil
 		}
-
-		tree, content, err := pa.ParseFile(path)
-		if err != nil {
-			log.Printf("Erreur d'analyse du fichier %q: %v", path, err)
-			return nil
+		assignFingerprints(fingerprintPath(root, path), content, detections)
+		detections = fa.filterChanged(path, fa.filterSeverity(fa.Baseline.Filter(fingerprintPath(root, path), content, detections)))
+		fa.annotateBlame(path, detections)
+		fa.recordFindings(detections)
+		return &scannedFile{path: path, content: content, findings: detections}
+	}, func(file *scannedFile) {
+		if file == nil {
+			return
+		}
+		detections := pa.dedupFindings(file.findings)
+		pa.run.add(root, file.path, detections)
+		detections = pa.limitFindings(detections)
+		if pa.report != nil {
+			pa.report.addFindings(file.path, file.content, detections)
+			return
 		}
-
-		detections := pa.DetectVulnerabilities(tree.RootNode(), content)
 		if len(detections) > 0 {
-			fmt.Printf("\nAnalyse du fichier : %s\n", path)
+			fmt.Fprintf(pa.Out, "\n%s\n", pa.formatHeader("Analyse du fichier : ", file.path, ""))
 			for _, d := range detections {
-				fmt.Printf("[%s] %s (ligne %d)\n", d.CVE, d.Message, d.Line)
+				pa.writeFinding(pa.Out, d, file.content)
 			}
 		}
-		return nil
 	})
 	if err != nil {
-		log.Printf("Erreur lors de la traversée du dossier %q: %v", dirPath, err)
+		pa.fileError("Erreur lors de la traversée de %s: %v", quotePaths(paths), err)
 	}
 }
 
-// AnalyzeDirectoryDBCalls parcourt récursivement un dossier et analyse chaque fichier PHP
-// pour détecter les appels à la base de données.
+// scanDBFile détecte les appels à la base de données et les définitions de tables d'un
+// fichier du scan de scanRoot, les appels étant filtrés par la sévérité minimale. L'arbre
+// est libéré dès la détection : seuls les résultats sont conservés.
+func (pa *PHPAnalyzer) scanDBFile(ctx context.Context, scanRoot, path string) (*scannedFile, error) {
+	tree, content, err := pa.ParseFile(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer pa.releaseTree(tree)
+	root := tree.RootNode()
+	findings := pa.DetectDatabaseCalls(root, content)
+	assignFingerprints(fingerprintPath(scanRoot, path), content, findings)
+	findings = pa.filterChanged(path, pa.filterSeverity(findings))
+	pa.annotateBlame(path, findings)
+	return &scannedFile{
+		path:      path,
+		content:   content,
+		findings:  findings,
+		schema:    extractSchema(root, content),
+		functions: functionSpans(root, content),
+	}, nil
+}
+
+// AnalyzeDirectoryDBCalls parcourt récursivement les dossiers donnés et analyse chaque
+// fichier PHP pour détecter les appels à la base de données.
 // Aucun message n'est affiché si aucun appel n'est trouvé.
-func (pa *PHPAnalyzer) AnalyzeDirectoryDBCalls(dirPath string) {
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			log.Printf("Erreur d'accès à %q: %v", path, err)
-			return nil
-		}
-		if info.IsDir() || !strings.HasSuffix(strings.ToLower(info.Name()), ".php") {
-			return nil
+func (pa *PHPAnalyzer) AnalyzeDirectoryDBCalls(ctx context.Context, paths ...string) {
+	root := scanRoot(paths)
+	err := scanPHPFiles(ctx, paths, pa, func(ctx context.Context, fa *PHPAnalyzer, path string) *scannedFile {
+		file, err := fa.scanDBFile(ctx, root, path)
+		if err != nil && !fa.interrupted(ctx, path) {
+			fa.fileError("Erreur d'analyse du fichier %q: %v", path, err)
 		}
-
-		tree, content, err := pa.ParseFile(path)
-		if err != nil {
-			log.Printf("Erreur d'analyse du fichier %q: %v", path, err)
-			return nil
+		return file
+	}, func(file *scannedFile) {
+		if file == nil {
+			return
 		}
-
-		calls := pa.DetectDatabaseCalls(tree.RootNode(), content)
-		if len(calls) > 0 {
-			fmt.Printf("\nAnalyse du fichier : %s\n", path)
+		calls := pa.limitFindings(pa.dedupFindings(file.findings))
+		if len(calls) > 0 || len(file.schema) > 0 {
+			fmt.Fprintf(pa.Out, "\n%s\n", pa.formatHeader("Analyse du fichier : ", file.path, ""))
 			for _, call := range calls {
-				fmt.Printf("- %s (ligne %d)\n", call.Description, call.Line)
+				pa.writeFinding(pa.Out, call, file.content)
 			}
+			writeSchema(pa.Out, file.schema)
 		}
-		return nil
 	})
 	if err != nil {
-		log.Printf("Erreur lors de la traversée du dossier %q: %v", dirPath, err)
+		pa.fileError("Erreur lors de la traversée de %s: %v", quotePaths(paths), err)
 	}
 }
 
-// extractFunctionName retourne le nom de la fonction pour un nœud d'appel (function ou member).
+// extractFunctionName retourne le nom de la fonction pour un nœud d'appel (function ou
+// member), ou "Classe::méthode" pour un appel statique.
 func extractFunctionName(node *sitter.Node, source []byte) string {
 	if node.Type() == "function_call_expression" {
 		if node.ChildCount() > 0 {
@@ -293,6 +446,11 @@ func extractFunctionName(node *sitter.Node, source []byte) string {
 		if nameNode := node.ChildByFieldName("name"); nameNode != nil {
 			return string(source[nameNode.StartByte():nameNode.EndByte()])
 		}
+	} else if node.Type() == "scoped_call_expression" {
+		scope, nameNode := node.ChildByFieldName("scope"), node.ChildByFieldName("name")
+		if scope != nil && nameNode != nil {
+			return scope.Content(source) + "::" + nameNode.Content(source)
+		}
 	}
 	return ""
 }
@@ -304,8 +462,7 @@ func getArguments(node *sitter.Node, source []byte) []string {
 	if argsNode == nil {
 		return args
 	}
-	for i := 0; i < int(argsNode.ChildCount()); i++ {
-		child := argsNode.Child(i)
+	for _, child := range children(argsNode) {
 		if child.IsNamed() {
 			raw := string(source[child.StartByte():child.EndByte()])
 			args = append(args, raw)
@@ -314,62 +471,60 @@ func getArguments(node *sitter.Node, source []byte) []string {
 	return args
 }
 
-// isFsockopenPortConfusion vérifie si le premier argument est une URL UDP contenant déjà un port
-// et si un second argument numérique (port) est fourni.
-func isFsockopenPortConfusion(node *sitter.Node, source []byte) bool {
-	args := getArguments(node, source)
-	if len(args) < 2 {
-		return false
+// hasLibxmlNoent vérifie si l'un des arguments (options libxml) contient LIBXML_NOENT.
+func hasLibxmlNoent(node *sitter.Node, source []byte) bool {
+	for _, arg := range getArguments(node, source) {
+		if strings.Contains(arg, "LIBXML_NOENT") {
+			return true
+		}
 	}
-	hostArg := args[0]
-	portArg := args[1]
-	isUDP := strings.Contains(strings.ToLower(hostArg), "udp://") && strings.Contains(hostArg, ":")
-	isPortNumeric, _ := regexp.MatchString(`^\d+$`, portArg)
-	return isUDP && isPortNumeric
+	return false
 }
 
-// isMbSplitW vérifie si le premier argument vaut littéralement "\w".
-func isMbSplitW(node *sitter.Node, source []byte) bool {
+// isEntityLoaderEnabled vérifie si libxml_disable_entity_loader est appelé avec false.
+func isEntityLoaderEnabled(node *sitter.Node, source []byte) bool {
 	args := getArguments(node, source)
 	if len(args) == 0 {
 		return false
 	}
-	firstArg := args[0]
-	return firstArg == `"\w"`
+	return strings.EqualFold(strings.TrimSpace(args[0]), "false")
 }
 
-// isUsingGCmorCCM vérifie si openssl_encrypt utilise un cipher contenant "gcm" ou "ccm".
-func isUsingGCmorCCM(node *sitter.Node, source []byte) bool {
-	args := getArguments(node, source)
-	if len(args) < 2 {
-		return false
-	}
-	cipherArg := strings.Trim(strings.ToLower(args[1]), `"' `)
-	return strings.Contains(cipherArg, "-gcm") || strings.Contains(cipherArg, "-ccm")
-}
+func printUsage(out io.Writer) {
+	usage := `Usage: php-analyzer [--use-daemon] [--socket path] [--max-width n] [--context n] [--format text|json|jsonl|junit|markdown|template] [--template fichier] [-o fichier|dossier/] [--cpuprofile fichier] [--memprofile fichier] [--store base.db] <command> [options]
 
-// isFilterVarValidateURL vérifie que le deuxième argument de filter_var correspond à FILTER_VALIDATE_URL.
-func isFilterVarValidateURL(node *sitter.Node, source []byte) bool {
-	args := getArguments(node, source)
-	if len(args) < 2 {
-		return false
-	}
-	secondArg := args[1]
-	return strings.Contains(secondArg, "FILTER_VALIDATE_URL")
-}
-
-// isSimplexmlLoadDynamic vérifie si le premier argument de simplexml_load_file est une variable (chemin dynamique).
-func isSimplexmlLoadDynamic(node *sitter.Node, source []byte) bool {
-	args := getArguments(node, source)
-	if len(args) == 0 {
-		return false
-	}
-	firstArg := args[0]
-	return strings.HasPrefix(firstArg, "$")
-}
-
-func printUsage() {
-	usage := `Usage: php-analyzer <command> [options]
+Options globales:
+  --use-daemon      Envoie la commande au démon (analyse locale s'il est indisponible).
+  --socket string   Chemin du socket Unix du démon.
+  --max-width int   Largeur maximale des lignes affichées ; les extraits de code et
+                    les chemins trop longs sont tronqués (0 = aucune limite).
+  --context int     Lignes de contexte affichées autour de la ligne signalée par
+                    un résultat, soulignée par des ^ (défaut : 0, -1 = aucun
+                    extrait de code).
+  -o string         Fichier où écrire les résultats plutôt que sur la sortie
+                    standard, ou dossier (terminé par /) où écrire rapport.txt,
+                    .json, .xml ou .md selon le format. Le fichier n'est remplacé
+                    qu'à la fin d'une analyse complète.
+  --format string   Format de sortie : text (défaut), json, jsonl, junit, markdown
+                    ou template. En JSON, count, cve, analyze-dir, dead et deadcount
+                    écrivent un document unique (findings, deadCode, metrics) et
+                    dbcalls son inventaire. En JSON Lines, chaque résultat est écrit
+                    sur une ligne dès que son fichier est analysé, avec un champ
+                    "type" (finding, deadCode, metric, message, file pour dbcalls,
+                    summary). En JUnit XML, cve et analyze-dir écrivent une suite
+                    par fichier et un échec par résultat ; en Markdown, un résumé
+                    puis les résultats de chaque fichier avec leur code. Avec
+                    template, les données de la sortie JSON sont mises en forme
+                    par le modèle de --template.
+  --template string Modèle text/template (Go) des résultats, avec
+                    --format=template.
+  --cpuprofile string
+                    Fichier où écrire le profil CPU de la commande (go tool pprof).
+  --memprofile string
+                    Fichier où écrire le profil mémoire en fin de commande.
+  --store string    Base SQLite où cve et analyze-dir enregistrent, à chaque
+                    exécution, leurs résultats et leurs empreintes (history,
+                    trends).
 
 Commands:
   count       - Compte les branchements dans un fichier PHP.
@@ -380,259 +535,1269 @@ Commands:
                 Options:
                   -file string    Chemin vers le fichier PHP à analyser.
                   -dir  string    Chemin vers le dossier à analyser récursivement.
+                  -profile string Profil de scan (auto, small, medium, large).
+                  -max-file-size string
+                                  Ignore les fichiers plus gros (2M, 512K...).
+                  -memory-budget string
+                                  Budget mémoire souple du scan (512M, 2G...).
+                  -timeout-per-file duration
+                                  Durée maximale de l'analyse d'un fichier (30s...).
+                  -format string  text (défaut) ou json : inventaire par fichier et
+                                  par fonction (opération, tables, construction).
+                  -min-severity string
+                                  Sévérité minimale des résultats signalés.
+                  -max-findings int
+                                  Nombre maximal de résultats signalés, dans
+                                  l'ordre du parcours (0 = aucune limite).
+                  -blame          Attribue chaque résultat au dernier commit de
+                                  sa ligne (commit, auteur, date).
 
   cve         - Détecte les vulnérabilités (CVE) dans un fichier PHP.
                 Options:
                   -file string    Chemin vers le fichier PHP à analyser.
+                  -taint-db-reads Considère les lectures en base comme contaminées
+                                  (XSS stockés, injections SQL de second ordre).
+                  -rules string   Fichier ou dossier de règles YAML supplémentaires.
+                  -php-version string
+                                  Version de PHP ciblée : les CVE ne sont signalées
+                                  que pour les versions affectées.
+                  -advisories string
+                                  Fichier d'avis écrit par rules update.
+                  -calibration string
+                                  Fichier de calibration issu du triage.
+                  -enable, -disable, -only string
+                                  Règles (sqli, CVE-2019-9025...) ou catégories
+                                  (cve, taint, crypto, debug, security, dbcalls,
+                                  metrics, lint) à activer, désactiver ou seules à
+                                  exécuter ; séparées par des virgules.
+                  -config string  Configuration du projet (.phpanalyzer.yaml).
+                  -baseline string
+                                  Baseline des résultats existants, créée si
+                                  absente : seuls les nouveaux sont signalés.
+                  -project string Dossier du projet dont les fonctions sont
+                                  indexées (polyfills, fonctions de nettoyage) ;
+                                  sans -project, les fichiers inclus par le
+                                  fichier analysé, ou dont il nomme les classes
+                                  (PSR-4 de composer.json), le sont.
+                  -fail-on string Sévérité à partir de laquelle un résultat fait
+                                  échouer la commande (défaut : info).
+                  -min-severity string
+                                  Sévérité minimale des résultats signalés.
+                  -max-findings int
+                                  Nombre maximal de résultats signalés, dans
+                                  l'ordre du parcours (0 = aucune limite).
+                  -blame          Attribue chaque résultat au dernier commit de
+                                  sa ligne (commit, auteur, date).
+                  -cache          Réutilise les résultats des scans précédents
+                                  (cache des résultats, désactivé par défaut).
+                  -no-cache       Réanalyse tous les fichiers sans utiliser le
+                                  cache des résultats.
+                  -cache-dir string
+                                  Dossier du cache des résultats, qui active le
+                                  cache (défaut : <dossier de cache de
+                                  l'utilisateur>/php-analyzer/results).
 
   analyze-dir - Analyse récursivement un dossier contenant des fichiers PHP
                 à la recherche de vulnérabilités.
                 Options:
                   -dir string     Chemin vers le dossier à analyser.
+                  -profile string Profil de scan (auto, small, medium, large).
+                  -max-file-size string
+                                  Ignore les fichiers plus gros (2M, 512K...).
+                  -memory-budget string
+                                  Budget mémoire souple du scan (512M, 2G...).
+                  -timeout-per-file duration
+                                  Durée maximale de l'analyse d'un fichier (30s...).
+                  -taint-db-reads Considère les lectures en base comme contaminées.
+                  -rules string   Fichier ou dossier de règles YAML supplémentaires.
+                  -php-version string
+                                  Version de PHP ciblée.
+                  -advisories string
+                                  Fichier d'avis écrit par rules update.
+                  -calibration string
+                                  Fichier de calibration issu du triage.
+                  -enable, -disable, -only string
+                                  Règles (sqli, CVE-2019-9025...) ou catégories
+                                  (cve, taint, crypto, debug, security, dbcalls,
+                                  metrics, lint) à activer, désactiver ou seules à
+                                  exécuter ; séparées par des virgules.
+                  -config string  Configuration du projet (.phpanalyzer.yaml).
+                  -baseline string
+                                  Baseline des résultats existants, créée si
+                                  absente : seuls les nouveaux sont signalés.
+                  -fail-on string Sévérité à partir de laquelle un résultat fait
+                                  échouer la commande (défaut : info).
+                  -min-severity string
+                                  Sévérité minimale des résultats signalés.
+                  -max-findings int
+                                  Nombre maximal de résultats signalés, dans
+                                  l'ordre du parcours (0 = aucune limite).
+                  -blame          Attribue chaque résultat au dernier commit de
+                                  sa ligne (commit, auteur, date).
+                  -cache          Réutilise les résultats des scans précédents
+                                  (cache des résultats, désactivé par défaut).
+                  -no-cache       Réanalyse tous les fichiers sans utiliser le
+                                  cache des résultats.
+                  -cache-dir string
+                                  Dossier du cache des résultats, qui active le
+                                  cache (défaut : <dossier de cache de
+                                  l'utilisateur>/php-analyzer/results).
+
+  detectors   - Liste les détecteurs disponibles et leur état par défaut.
+
+  rules list  - Liste les règles chargées : identifiant, catégorie, sévérité,
+                CWE et description.
+                Options:
+                  -rules string   Fichier ou dossier de règles YAML supplémentaires.
+                  -advisories string
+                                  Fichier d'avis écrit par rules update.
+
+  rules describe <règle>
+              - Affiche toutes les métadonnées d'une règle et des exemples de
+                code vulnérable et corrigé. Mêmes options que rules list.
+
+  rules update - Télécharge les métadonnées des CVE des signatures embarquées
+                (description, versions affectées, sévérité) depuis OSV ou la NVD.
+                Options:
+                  -source string  Flux d'avis : osv (défaut) ou nvd.
+                  -url string     Adresse d'un miroir du flux.
+                  -api-key string Clé d'API de la NVD (défaut : $NVD_API_KEY).
+                  -out string     Fichier d'avis à écrire.
+
+  tui         - Trie interactivement les résultats des chemins donnés (défaut :
+                dossier courant) : liste navigable au clavier (↑/↓, j/k, pages),
+                aperçu du code signalé, f pour un faux positif, t pour un vrai
+                positif, u pour annuler, q pour enregistrer et quitter, Ctrl-C
+                pour abandonner. Les faux positifs sont ajoutés à la baseline et
+                toutes les décisions à l'historique de triage.
+                Options:
+                  -dir string         Dossier à analyser.
+                  -baseline string    Baseline où ajouter les faux positifs
+                                      (défaut .phpanalyzer-baseline.json) ; ses
+                                      résultats ne sont pas proposés.
+                  -history string     Historique de triage où ajouter les décisions
+                                      (défaut .phpanalyzer-triage.jsonl).
+                  Mêmes options de sélection, de filtre et d'exclusion que
+                  analyze-dir.
+
+  fmt         - Met en forme les fichiers PHP et écrit le résultat sur la
+                sortie standard.
+                Options:
+                  -dir string         Dossier à formater récursivement.
+                  -w                  Réécrit les fichiers modifiés sur place.
+                  -check              Écrit le diff des fichiers à formater sans
+                                      les modifier ; code de sortie 1 s'il y en a.
+                  -lines string       Ne formate que les instructions des lignes
+                                      DÉBUT:FIN d'un seul fichier.
+                  -indent int         Espaces par niveau d'indentation (défaut 4,
+                                      0 = tabulation).
+                  -max-line-length int Largeur au-delà de laquelle les lignes sont
+                                      coupées (défaut 120, 0 = aucune limite).
+                  -sort-imports       Trie les déclarations use.
+                  -align-arrows       Aligne les => des tableaux.
+                  -braces             Réécrit la syntaxe alternative (if: ...
+                                      endif;) avec des accolades.
+                  -expand-short-echo  Réécrit <?= $x ?> en <?php echo $x; ?>.
+                  -max-blank-lines int Lignes vides consécutives conservées
+                                      (défaut 1, 0 = aucune).
+                  -separate-functions Une ligne vide entre les fonctions et les
+                                      méthodes (défaut true).
+                  -blank-line-after-tag Une ligne vide après la balise <?php.
+                  Mêmes options d'exclusion que analyze-dir.
+
+  bench       - Mesure l'analyse des fichiers PHP des chemins donnés : débit
+                (fichiers/s), temps de parsing et d'analyse, et temps de chaque
+                détecteur, sans cache des résultats.
+                Options:
+                  -dir string     Dossier à analyser.
+                  -profile string Profil de scan (auto, small, medium, large).
+                  -rules string   Fichier ou dossier de règles YAML supplémentaires.
+                  -enable, -disable, -only string
+                                  Règles ou catégories mesurées.
+                  Mêmes options d'exclusion et de limites que analyze-dir.
+
+  triage-stats - Calcule le taux de faux positifs de chaque règle à partir de
+                l'historique de triage et suggère des déclassements.
+                Options:
+                  -history string     Historique de triage (JSON Lines).
+                  -apply              Enregistre les déclassements suggérés.
+                  -calibration string Fichier de calibration.
+
+  history     - Liste les exécutions enregistrées avec --store pour un projet,
+                avec les résultats apparus et corrigés depuis la précédente.
+                Options:
+                  -project string Dossier du projet (défaut : dossier courant).
+                  -run int        Détaille les résultats apparus et corrigés
+                                  d'une exécution.
+
+  trends      - Montre l'évolution du nombre de résultats par sévérité entre
+                les exécutions enregistrées avec --store.
+                Options:
+                  -project string Dossier du projet (défaut : dossier courant).
+
+  symbols     - Liste les fonctions, classes, interfaces, traits, énumérations,
+                méthodes et constantes définies dans les chemins donnés (dossier
+                courant par défaut), avec leur fichier, leur ligne et leur
+                signature.
+                Options:
+                  -dir string     Dossier à indexer récursivement.
+                  -kind string    Genres retenus, séparés par des virgules
+                                  (function, class, interface, trait, enum,
+                                  method, constant).
+                  -name string    Motif du nom ("*", "?"), court (find*) ou
+                                  qualifié (App\Models\*, User::find).
+                  Mêmes options d'exclusion que analyze-dir.
+
+  calls       - Affiche le graphe d'appels des chemins donnés (dossier courant
+                par défaut) : appels entre les fonctions et méthodes du projet,
+                y compris les appels dynamiques (call_user_func, $fn(),
+                [$obj, 'method']) dont la cible est littérale ou localement
+                constante. Les appels dynamiques non résolus sont marqués "?".
+                Options:
+                  -dir string     Dossier à indexer récursivement.
+                  -unresolved     N'affiche que les appels dynamiques non
+                                  résolus.
+                  Mêmes options d'exclusion que analyze-dir.
+
+  dead-functions
+              - Liste les fonctions et méthodes des chemins donnés (dossier
+                courant par défaut) qu'aucun point d'entrée n'atteint par le
+                graphe d'appels. Points d'entrée par défaut : index.php, routes/,
+                méthodes publiques des classes *Controller.
+                Options:
+                  -dir string     Dossier à indexer récursivement.
+                  -config string  Configuration du projet, dont la section
+                                  entry-points (.phpanalyzer.yaml).
+                  -entry-files string
+                                  Motifs de fichiers d'entrée supplémentaires
+                                  (public/*.php).
+                  -entry-functions string
+                                  Motifs de fonctions et méthodes d'entrée
+                                  supplémentaires (App\Jobs\*::handle).
+                  -conservative   Écarte les fonctions et méthodes référencées
+                                  dynamiquement (appel dynamique, nom cité par
+                                  une chaîne).
+                  Mêmes options d'exclusion que analyze-dir.
+
+  daemon      - Lance un démon qui garde les arbres syntaxiques en mémoire et
+                répond aux commandes envoyées avec --use-daemon.
+
+  serve       - Lance un serveur HTTP pour les services web et les plugins
+                d'éditeur : POST /analyze (source ou chemin), GET /rules et
+                GET /results/{id}, les parseurs et les caches restant chargés.
+                Options:
+                  -addr string    Adresse d'écoute (défaut localhost:8080).
+                  -grpc-addr string
+                                  Adresse d'écoute du service gRPC (AnalyzeFile,
+                                  AnalyzeTree, ListRules), désactivé par défaut.
+                  -rules string   Fichier ou dossier de règles YAML supplémentaires.
 
 Exemples:
   php-analyzer count -file=/chemin/vers/fichier.php
+  cat fichier.php | php-analyzer cve -file=-
   php-analyzer dbcalls -file=/chemin/vers/fichier.php
   php-analyzer dbcalls -dir=/chemin/vers/dossier
+  php-analyzer dbcalls -dir=/chemin/vers/dossier -format=json
   php-analyzer cve -file=/chemin/vers/fichier.php
+  php-analyzer --context=2 cve -file=/chemin/vers/fichier.php
   php-analyzer analyze-dir -dir=/chemin/vers/dossier
+  php-analyzer cve 'src/**/*.php' 'legacy/*.inc' --fail-on=high
+  php-analyzer analyze-dir -dir=/chemin/vers/dossier -profile=auto
+  php-analyzer analyze-dir -dir=/chemin/vers/dossier -cache
+  php-analyzer analyze-dir -dir=/chemin/vers/dossier -max-file-size=2M -memory-budget=1G
+  php-analyzer analyze-dir -dir=/chemin/vers/dossier -exclude='*.min.php' -exclude=tests/
+  php-analyzer analyze-dir -dir=/chemin/vers/dossier -taint-db-reads
+  php-analyzer analyze-dir -dir=/chemin/vers/dossier -rules=regles/ -php-version=7.4.3
+  php-analyzer analyze-dir -dir=/chemin/vers/dossier --only=taint,crypto --disable=xss
+  php-analyzer analyze-dir -dir=/chemin/vers/dossier -baseline=baseline.json
+  php-analyzer -format=json analyze-dir -dir=/chemin/vers/dossier
+  php-analyzer -format=jsonl analyze-dir -dir=/chemin/vers/dossier | jq -c 'select(.severity == "critical")'
+  php-analyzer -format=junit analyze-dir -dir=/chemin/vers/dossier > rapport.xml
+  php-analyzer -format=markdown analyze-dir -dir=/chemin/vers/dossier > rapport.md
+  php-analyzer -format=template -template=confluence.tmpl analyze-dir -dir=/chemin/vers/dossier
+  php-analyzer -format=json -o results.json analyze-dir -dir=/chemin/vers/dossier
+  php-analyzer -format=junit -o rapports/ analyze-dir -dir=/chemin/vers/dossier
+  php-analyzer analyze-dir -dir=/chemin/vers/dossier --fail-on=high
+  php-analyzer analyze-dir --changed-since=origin/main --changed-lines
+  php-analyzer analyze-dir -dir=/chemin/vers/dossier --min-severity=medium --max-findings=50
+  php-analyzer tui src/ --min-severity=medium
+  php-analyzer fmt -w -dir=/chemin/vers/dossier
+  php-analyzer fmt --check src/
+  php-analyzer fmt -w --lines=10:20 src/Controller.php
+  php-analyzer symbols -kind=class,interface src/
+  php-analyzer -format=json symbols -name='App\Models\User::*'
+  php-analyzer calls -unresolved src/
+  php-analyzer dead-functions -conservative -entry-files='public/*.php' .
+  php-analyzer rules list
+  php-analyzer rules describe sqli
+  php-analyzer rules update -source=nvd
+  php-analyzer daemon &
+  php-analyzer --use-daemon cve -file=/chemin/vers/fichier.php
+
+Les commandes d'analyse acceptent aussi, avant ou après leurs options, des chemins
+(fichiers ou dossiers) et des motifs, "**" désignant un nombre quelconque de dossiers :
+leurs résultats sont rassemblés, et les fichiers désignés explicitement sont analysés
+quelle que soit leur extension. -files-from=<fichier> ajoute les chemins listés dans ce
+fichier, un par ligne ou séparés par des octets nuls (- pour l'entrée standard) :
+  find . -name '*.php' | php-analyzer cve -files-from -
+
+Lors du parcours d'un dossier, les dossiers vendor/, node_modules/ et .git/ sont
+ignorés ; -exclude (répétable) ajoute des motifs à ignorer (generated/, *.min.php,
+src/**/cache) et -no-default-excludes parcourt aussi les dossiers ignorés par défaut.
+Les motifs des fichiers .phpanalyzerignore, et des .gitignore avec -gitignore, sont
+appliqués comme par git, y compris ceux des dossiers parents jusqu'à la racine du dépôt.
+Les fichiers .php, .phtml, .inc et .php5 sont analysés (-extensions remplace cette
+liste) et les dossiers cachés ignorés (-hidden les parcourt) ; -max-depth limite la
+profondeur du parcours et -follow-symlinks suit les liens vers des dossiers.
+
+-changed-since=<révision> restreint l'analyse aux fichiers modifiés depuis le point de
+divergence avec cette révision (copie de travail et fichiers non suivis compris), le
+dossier courant étant analysé si aucun chemin n'est donné ; -changed-lines ne signale
+alors que les résultats des lignes modifiées.
+
+Après un scan de plusieurs fichiers ou d'un dossier, un résumé indique les fichiers
+analysés, ignorés et en erreur, les résultats signalés par sévérité et par règle, et la
+durée de l'analyse (clé "summary" en JSON).
+
+Avec -file=-, count, dbcalls, cve, dead et deadcount lisent le code sur l'entrée
+standard, qui est aussi lue lorsqu'elle est redirigée et que -file et -dir sont omis.
+
+Codes de sortie:
+  0  aucun résultat
+  1  résultats de sévérité supérieure ou égale au seuil -fail-on
+  2  erreur d'analyse ou d'utilisation
+  3  uniquement des résultats sous le seuil -fail-on
 `
-	fmt.Println(usage)
+	fmt.Fprintln(out, usage)
 }
 
-// Ajout de la méthode AnalyzeDirectoryDeadCode dans PHPAnalyzer.
-func (pa *PHPAnalyzer) AnalyzeDirectoryDeadCode(dirPath string) {
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			log.Printf("Erreur d'accès à %q: %v", path, err)
-			return nil
-		}
-		if info.IsDir() || !strings.HasSuffix(strings.ToLower(info.Name()), ".php") {
-			return nil
-		}
+// AnalyzeDirectoryDeadCode parcourt récursivement les dossiers donnés et affiche le code
+// mort détecté dans le CFG de chaque fichier PHP.
+func (pa *PHPAnalyzer) AnalyzeDirectoryDeadCode(ctx context.Context, paths ...string) {
+	err := forEachPHPFile(ctx, paths, pa, func(ctx context.Context, fa *PHPAnalyzer, path string) string {
 		// Parse le fichier PHP
-		_, content, err := pa.ParseFile(path)
+		tree, content, err := fa.ParseFile(ctx, path)
 		if err != nil {
-			log.Printf("Erreur lors du parsing du fichier %q: %v", path, err)
-			return nil
+			if !fa.interrupted(ctx, path) {
+				fa.fileError("Erreur lors du parsing du fichier %q: %v", path, err)
+			}
+			return ""
 		}
+		fa.releaseTree(tree)
 		// Construire le CFG à l'aide du CFGBuilder
 		builder := NewCFGBuilder()
-		cfg, err := builder.BuildCFG(content)
+		cfg, err := builder.BuildCFG(ctx, content)
+		if fa.interrupted(ctx, path) {
+			return ""
+		}
 		if err != nil {
-			log.Printf("Erreur lors de la construction du CFG pour le fichier %q: %v", path, err)
-			return nil
+			fa.fileError("Erreur lors de la construction du CFG pour le fichier %q: %v", path, err)
+			return ""
 		}
 		// Détection du code mort dans le CFG
+		var out strings.Builder
 		deadNodes := cfg.DetectDeadCode()
+		if fa.report != nil {
+			fa.report.addDeadCode(path, cfg, deadNodes)
+			return ""
+		}
 		if len(deadNodes) > 0 {
-			fmt.Printf("\nDead code trouvé dans %q:\n", path)
+			fmt.Fprintf(&out, "\n%s\n", fa.formatHeader(`Dead code trouvé dans "`, path, `":`))
 			for _, id := range deadNodes {
 				if node, exists := cfg.Nodes[id]; exists {
-					fmt.Printf(" - Node %d: %s [%s]\n", node.ID, node.Type, node.code)
+					fmt.Fprintln(&out, fa.formatDeadNode(node))
 				}
 			}
 		}
-		return nil
+		return out.String()
 	})
 	if err != nil {
-		log.Printf("Erreur lors de l'analyse du dossier %q: %v", dirPath, err)
+		pa.fileError("Erreur lors de l'analyse de %s: %v", quotePaths(paths), err)
 	}
 }
 
-func main() {
-	if len(os.Args) < 2 {
-		printUsage()
-		os.Exit(1)
+// AnalyzeDirectoryDeadCount affiche le nombre de nœuds de code mort de chaque fichier
+// PHP des dossiers donnés, puis le total.
+func (pa *PHPAnalyzer) AnalyzeDirectoryDeadCount(ctx context.Context, paths ...string) {
+	var mu sync.Mutex
+	totalDead := 0
+	err := forEachPHPFile(ctx, paths, pa, func(ctx context.Context, fa *PHPAnalyzer, path string) string {
+		tree, content, err := fa.ParseFile(ctx, path)
+		if err != nil {
+			if !fa.interrupted(ctx, path) {
+				fa.fileError("Erreur lors du parsing du fichier %q: %v", path, err)
+			}
+			return ""
+		}
+		fa.releaseTree(tree)
+		builder := NewCFGBuilder()
+		cfg, err := builder.BuildCFG(ctx, content)
+		if fa.interrupted(ctx, path) {
+			return ""
+		}
+		if err != nil {
+			fa.fileError("Erreur lors de la construction du CFG pour le fichier %q: %v", path, err)
+			return ""
+		}
+		deadNodes := cfg.DetectDeadCode()
+		mu.Lock()
+		totalDead += len(deadNodes)
+		mu.Unlock()
+		if fa.report != nil {
+			fa.report.addMetric(path, "deadCode", len(deadNodes))
+			return ""
+		}
+		return fmt.Sprintf("Dead code détecté dans %q : %d\n", path, len(deadNodes))
+	})
+	if err != nil {
+		pa.fileError("Erreur lors de la traversée de %s: %v", quotePaths(paths), err)
+	}
+	if pa.report == nil {
+		fmt.Fprintf(pa.Out, "\nNombre total de dead code détecté dans %s : %d\n", quotePaths(paths), totalDead)
 	}
+}
 
-	command := os.Args[1]
-	analyzer := NewPHPAnalyzer()
+// errUsage signale une utilisation invalide de la ligne de commande (flag manquant,
+// commande inconnue). Le message d'aide a déjà été affiché.
+var errUsage = errors.New("utilisation invalide")
+
+// newFlagSet crée le jeu de flags d'une sous-commande. Les erreurs de parsing sont
+// retournées plutôt que de terminer le processus, pour que le démon puisse survivre
+// à une requête invalide.
+func newFlagSet(name string, out io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	return fs
+}
+
+// applyProfile résout le flag -profile et les limites -max-file-size, -memory-budget et
+// -timeout-per-file d'un scan de dossier.
+func applyProfile(ctx context.Context, analyzer *PHPAnalyzer, flags *profileFlags, paths ...string) error {
+	analyzer.FileTimeout = flags.fileTimeout
+	profile, err := analyzer.ResolveProfile(ctx, flags.name, paths...)
+	if err != nil {
+		return fmt.Errorf("Erreur lors de la sélection du profil de scan: %v", err)
+	}
+	analyzer.Profile = profile
+	analyzer.MaxFileSize = int64(flags.maxFileSize)
+	analyzer.MemoryBudget = int64(flags.memoryBudget)
+	return nil
+}
+
+// loadCalibration charge la calibration issue du triage ; un fichier absent n'en applique aucune.
+func loadCalibration(analyzer *PHPAnalyzer, path string) error {
+	calibration, err := LoadCalibration(path)
+	if err != nil {
+		return fmt.Errorf("Erreur lors de la lecture de la calibration %q: %v", path, err)
+	}
+	analyzer.Calibration = calibration
+	return nil
+}
+
+// loadBaseline charge la baseline de path, ou n'en applique aucune si path est vide.
+func loadBaseline(analyzer *PHPAnalyzer, path string) error {
+	analyzer.Baseline = nil
+	if path == "" {
+		return nil
+	}
+	baseline, err := LoadBaseline(path)
+	if err != nil {
+		return fmt.Errorf("Erreur lors de la lecture de la baseline %q: %v", path, err)
+	}
+	analyzer.Baseline = baseline
+	return nil
+}
+
+// loadSignatures remplace les signatures de l'analyseur par les signatures embarquées,
+// mises à jour par le fichier d'avis advisoriesPath s'il existe, puis complétées ou
+// surchargées par celles de rulesPath s'il est renseigné.
+func loadSignatures(analyzer *PHPAnalyzer, rulesPath, advisoriesPath string) error {
+	advisories, err := LoadAdvisories(advisoriesPath)
+	if err != nil {
+		return fmt.Errorf("Erreur lors de la lecture des avis %q: %v", advisoriesPath, err)
+	}
+	builtin, err := ApplyAdvisories(builtinSignatures, advisories)
+	if err != nil {
+		return fmt.Errorf("Erreur lors de l'application des avis %q: %v", advisoriesPath, err)
+	}
+	if rulesPath == "" {
+		analyzer.Signatures = NewSignatureSet(builtin)
+		return nil
+	}
+	rules, err := LoadSignatureRules(rulesPath)
+	if err != nil {
+		return fmt.Errorf("Erreur lors du chargement des règles %q: %v", rulesPath, err)
+	}
+	analyzer.Signatures = NewSignatureSet(builtin, rules)
+	return nil
+}
+
+// selectionFlags regroupe les options de sélection des règles des commandes d'analyse.
+type selectionFlags struct {
+	config                string
+	enable, disable, only listFlag
+}
+
+func addSelectionFlags(fs *flag.FlagSet) *selectionFlags {
+	flags := &selectionFlags{}
+	fs.StringVar(&flags.config, "config", defaultConfigPath, "Fichier de configuration du projet")
+	fs.Var(&flags.enable, "enable", "Règles ou catégories à activer (séparées par des virgules)")
+	fs.Var(&flags.disable, "disable", "Règles ou catégories à désactiver")
+	fs.Var(&flags.only, "only", "N'exécuter que ces règles ou catégories")
+	return flags
+}
+
+// applySelection applique la sélection de règles et les sévérités de la configuration du
+// projet, puis la sélection de la ligne de commande. La sélection d'une commande
+// précédente du démon est d'abord oubliée.
+func applySelection(analyzer *PHPAnalyzer, flags *selectionFlags) error {
+	analyzer.detectors = nil
+	analyzer.rules = ruleFilter{}
+	config, err := LoadProjectConfig(flags.config)
+	if err != nil {
+		return fmt.Errorf("Erreur lors de la lecture de la configuration %q: %v", flags.config, err)
+	}
+	if err := analyzer.SelectRules(config.RuleSelection); err != nil {
+		return fmt.Errorf("Configuration %q: %v", flags.config, err)
+	}
+	if err := analyzer.OverrideSeverities(config.Severity); err != nil {
+		return fmt.Errorf("Configuration %q: %v", flags.config, err)
+	}
+	analyzer.Lint = config.Lint
+	return analyzer.SelectRules(RuleSelection{Enable: flags.enable, Disable: flags.disable, Only: flags.only})
+}
+
+// runRulesCommand exécute les sous-commandes de "rules".
+func runRulesCommand(analyzer *PHPAnalyzer, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		fmt.Fprintln(out, "Sous-commande requise : rules list, rules describe ou rules update")
+		return errUsage
+	}
+	switch args[0] {
+	case "list", "describe":
+		cmd := newFlagSet("rules "+args[0], out)
+		rulesPath := cmd.String("rules", "", "Fichier ou dossier de règles YAML supplémentaires")
+		advisoriesPath := cmd.String("advisories", defaultAdvisoriesPath, "Fichier d'avis écrit par rules update")
+		if err := cmd.Parse(args[1:]); err != nil {
+			return errUsage
+		}
+		analyzer.resolvePathFlags(cmd)
+		if err := loadSignatures(analyzer, *rulesPath, *advisoriesPath); err != nil {
+			return err
+		}
+		if args[0] == "list" {
+			analyzer.WriteRuleList(out)
+			return nil
+		}
+		if cmd.NArg() != 1 {
+			fmt.Fprintln(out, "Usage : rules describe [-rules chemin] <règle>")
+			return errUsage
+		}
+		rule, err := analyzer.DescribeRule(cmd.Arg(0))
+		if err != nil {
+			return err
+		}
+		WriteRuleDescription(out, rule)
+
+	case "update":
+		updateCmd := newFlagSet("rules update", out)
+		source := updateCmd.String("source", "osv", "Flux d'avis de sécurité : osv ou nvd")
+		feedURL := updateCmd.String("url", "", "Adresse du flux (miroir), à la place de l'API publique")
+		apiKey := updateCmd.String("api-key", os.Getenv("NVD_API_KEY"), "Clé d'API de la NVD")
+		outPath := updateCmd.String("out", defaultAdvisoriesPath, "Fichier d'avis à écrire")
+		if err := updateCmd.Parse(args[1:]); err != nil {
+			return errUsage
+		}
+		analyzer.resolvePathFlags(updateCmd)
+		var feed AdvisoryFeed
+		switch *source {
+		case "osv":
+			feed = NewOSVFeed(*feedURL)
+		case "nvd":
+			feed = NewNVDFeed(*feedURL, *apiKey)
+		default:
+			fmt.Fprintf(out, "Flux inconnu : %q (osv ou nvd)\n", *source)
+			return errUsage
+		}
+		advisories, errs := FetchAdvisories(feed, builtinSignatures)
+		for _, err := range errs {
+			fmt.Fprintf(out, "Avis ignoré : %v\n", err)
+		}
+		for _, advisory := range advisories {
+			fmt.Fprintf(out, "%s : sévérité %s, versions %s\n", advisory.ID, orDash(advisory.Severity), orDash(advisory.PHP))
+		}
+		if len(advisories) == 0 {
+			return fmt.Errorf("Aucun avis récupéré depuis %s", *source)
+		}
+		if err := SaveAdvisories(*outPath, *source, advisories); err != nil {
+			return fmt.Errorf("Erreur lors de l'écriture des avis %q: %v", *outPath, err)
+		}
+		fmt.Fprintf(out, "%d avis enregistrés dans %q.\n", len(advisories), *outPath)
+
+	default:
+		fmt.Fprintf(out, "Sous-commande inconnue : rules %s\n", args[0])
+		return errUsage
+	}
+	return nil
+}
+
+// orDash remplace une valeur vide par "-" dans les sorties texte.
+func orDash(text string) string {
+	if text == "" {
+		return "-"
+	}
+	return text
+}
 
+// runCommand exécute une sous-commande et écrit ses résultats sur out, dans le format
+// de l'analyseur.
+func runCommand(analyzer *PHPAnalyzer, command string, args []string, out io.Writer) error {
+	return runCommandContext(context.Background(), analyzer, command, args, out)
+}
+
+// runCommandContext exécute une sous-commande comme runCommand ; l'annulation de ctx
+// (Ctrl-C) interrompt l'analyse, dont les résultats partiels sont écrits.
+func runCommandContext(ctx context.Context, analyzer *PHPAnalyzer, command string, args []string, out io.Writer) error {
+	analyzer.Out = out
+	analyzer.report = nil
+	analyzer.MinSeverity = SeverityInfo
+	analyzer.MaxFindings = 0
+	analyzer.Exclude = defaultExcludes
+	analyzer.Gitignore = false
+	analyzer.Walk = WalkOptions{}
+	analyzer.changed = nil
+	analyzer.changedLines = false
+	analyzer.Blame = false
+	analyzer.FailOn = SeverityInfo
+	analyzer.FileTimeout = 0
+	analyzer.outcome = newScanOutcome()
+	analyzer.run = nil
+	if analyzer.Store != "" && storeCommands[command] {
+		analyzer.run = newStoredRun(command)
+	}
+	switch {
+	case (analyzer.Format == formatJSON || analyzer.Format == formatTemplate) && jsonCommands[command],
+		(analyzer.Format == formatJUnit || analyzer.Format == formatMarkdown) && findingCommands[command]:
+		analyzer.report = newJSONReport()
+		analyzer.report.template = analyzer.Template
+	case analyzer.Format == formatJSONL && jsonCommands[command]:
+		analyzer.report = newJSONLinesReport(out)
+	}
+	if err := runSubcommand(ctx, analyzer, command, args, out); err != nil {
+		return err
+	}
+	if analyzer.run != nil {
+		if err := analyzer.saveRun(); err != nil {
+			return fmt.Errorf("Erreur lors de l'enregistrement des résultats dans %q: %v", analyzer.Store, err)
+		}
+	}
+	summary := analyzer.takeSummary()
+	if analyzer.report != nil {
+		analyzer.report.Summary = summary
+		return analyzer.report.write(out, analyzer.Format)
+	}
+	if summary != nil {
+		writeSummary(out, summary)
+	}
+	return nil
+}
+
+// runSubcommand exécute une sous-commande ; avec un rapport JSON, JUnit ou Markdown, ses résultats y sont
+// rassemblés plutôt qu'écrits sur out.
+func runSubcommand(ctx context.Context, analyzer *PHPAnalyzer, command string, args []string, out io.Writer) error {
 	switch command {
 	case "count":
-		countCmd := flag.NewFlagSet("count", flag.ExitOnError)
+		countCmd := newFlagSet("count", out)
 		filePath := countCmd.String("file", "", "Chemin vers le fichier PHP à analyser")
-		countCmd.Parse(os.Args[2:])
-		if *filePath == "" {
-			fmt.Println("Le flag -file est requis pour la commande count.")
+		excludes := addExcludeFlags(countCmd)
+		changed := addChangedFlags(countCmd)
+		inputs, err := analyzer.parseCommandLine(countCmd, args)
+		if err != nil {
+			return err
+		}
+		if err := applyExcludes(analyzer, excludes); err != nil {
+			return err
+		}
+		if err := applyChanged(analyzer, changed); err != nil {
+			return err
+		}
+		inputs = changed.defaultInputs(inputs, *filePath)
+		analyzer.stdinDefault(filePath, inputs...)
+		if *filePath == "" && len(inputs) == 0 {
+			fmt.Fprintln(out, "Le flag -file ou un chemin est requis pour la commande count.")
 			countCmd.Usage()
-			os.Exit(1)
+			return errUsage
 		}
-		tree, _, err := analyzer.ParseFile(*filePath)
+		files, err := analyzer.listPHPFiles(scanPaths(*filePath, inputs)...)
 		if err != nil {
-			log.Fatalf("Erreur lors du parsing du fichier %q: %v", *filePath, err)
+			return fmt.Errorf("Erreur lors de la traversée de %s: %v", quotePaths(inputs), err)
 		}
-		branches := analyzer.CountBranches(tree.RootNode())
-		if branches > 0 {
-			fmt.Printf("Nombre de branchements dans %q : %d\n", *filePath, branches)
+		for _, path := range files {
+			tree, _, err := analyzer.ParseFile(ctx, path)
+			if err != nil && len(files) == 1 {
+				return fmt.Errorf("Erreur lors du parsing du fichier %q: %v", path, err)
+			} else if err != nil {
+				analyzer.fileError("Erreur lors du parsing du fichier %q: %v", path, err)
+				continue
+			}
+			branches := analyzer.CountBranches(tree.RootNode())
+			analyzer.releaseTree(tree)
+			if analyzer.report != nil {
+				analyzer.report.addMetric(path, "branches", branches)
+			} else if branches > 0 {
+				fmt.Fprintf(out, "Nombre de branchements dans %q : %d\n", path, branches)
+			}
 		}
 
 	case "dbcalls":
-		dbCmd := flag.NewFlagSet("dbcalls", flag.ExitOnError)
+		dbCmd := newFlagSet("dbcalls", out)
 		filePath := dbCmd.String("file", "", "Chemin vers le fichier PHP à analyser")
 		dirPath := dbCmd.String("dir", "", "Chemin vers le dossier à analyser récursivement")
-		dbCmd.Parse(os.Args[2:])
+		profiling := addProfileFlags(dbCmd)
+		format := dbCmd.String("format", analyzer.Format, "Format de sortie : text, ou json (jsonl : une ligne par fichier) pour l'inventaire par fichier et par fonction")
+		filters := addFilterFlags(dbCmd)
+		blame := dbCmd.Bool("blame", false, "Attribue chaque résultat au dernier commit de sa ligne (git blame)")
+		excludes := addExcludeFlags(dbCmd)
+		changed := addChangedFlags(dbCmd)
+		inputs, err := analyzer.parseCommandLine(dbCmd, args)
+		if err != nil {
+			return err
+		}
+		if err := applyExcludes(analyzer, excludes); err != nil {
+			return err
+		}
+		if err := applyChanged(analyzer, changed); err != nil {
+			return err
+		}
+		inputs = changed.defaultInputs(inputs, *filePath, *dirPath)
+		analyzer.stdinDefault(filePath, append(inputs, *dirPath)...)
+		dirPaths := scanPaths(*dirPath, inputs)
+		if err := applyFilters(analyzer, filters); err != nil {
+			return err
+		}
+		analyzer.Blame = *blame
+		if *format != formatText && *format != formatJSON && *format != formatJSONL {
+			fmt.Fprintf(out, "Format inconnu : %q (text, json ou jsonl).\n", *format)
+			dbCmd.Usage()
+			return errUsage
+		}
 
-		if *filePath == "" && *dirPath == "" {
-			fmt.Println("Le flag -file ou -dir est requis pour la commande dbcalls.")
+		if *filePath == "" && len(dirPaths) == 0 {
+			fmt.Fprintln(out, "Le flag -file ou -dir, ou un chemin, est requis pour la commande dbcalls.")
 			dbCmd.Usage()
-			os.Exit(1)
+			return errUsage
+		}
+
+		if *format == formatJSON || *format == formatJSONL {
+			var files []dbFileInventory
+			emit := func(file dbFileInventory) { files = append(files, file) }
+			stream := newJSONLinesEncoder(out)
+			if *format == formatJSONL {
+				emit = func(file dbFileInventory) { stream.Encode(jsonlDBFile{"file", file}) }
+			}
+			if err := analyzer.inventoryDBCalls(ctx, *filePath, dirPaths, profiling, emit); err != nil {
+				return err
+			}
+			analyzer.writeOmitted(log.Writer())
+			if *format == formatJSONL {
+				return writeJSONLinesSummary(stream, analyzer.takeSummary())
+			}
+			inventory := newDBInventory(files)
+			inventory.Summary = analyzer.takeSummary()
+			return writeDBInventory(out, inventory)
 		}
 
 		// Analyse d'un fichier
 		if *filePath != "" {
-			tree, content, err := analyzer.ParseFile(*filePath)
+			tree, content, err := analyzer.ParseFile(ctx, *filePath)
 			if err != nil {
-				log.Fatalf("Erreur lors du parsing du fichier %q: %v", *filePath, err)
+				return fmt.Errorf("Erreur lors du parsing du fichier %q: %v", *filePath, err)
 			}
-			calls := analyzer.DetectDatabaseCalls(tree.RootNode(), content)
-			if len(calls) > 0 {
-				fmt.Printf("Appels de base de données détectés dans %q :\n", *filePath)
+			calls := analyzer.limitFindings(analyzer.filterSeverity(analyzer.DetectDatabaseCalls(tree.RootNode(), content)))
+			schema := extractSchema(tree.RootNode(), content)
+			if len(calls) > 0 || len(schema) > 0 {
+				fmt.Fprintln(out, analyzer.formatHeader(`Appels de base de données détectés dans "`, *filePath, `" :`))
 				for _, call := range calls {
-					fmt.Printf("- %s (ligne %d)\n", call.Description, call.Line)
+					analyzer.writeFinding(out, call, content)
 				}
+				writeSchema(out, schema)
 			}
 		}
 
-		// Analyse d'un dossier récursif
-		if *dirPath != "" {
-			analyzer.AnalyzeDirectoryDBCalls(*dirPath)
+		// Analyse des dossiers et chemins positionnels
+		if len(dirPaths) > 0 {
+			if err := applyProfile(ctx, analyzer, profiling, dirPaths...); err != nil {
+				return err
+			}
+			analyzer.AnalyzeDirectoryDBCalls(ctx, dirPaths...)
 		}
+		analyzer.writeOmitted(out)
 
 	case "cve":
-		cveCmd := flag.NewFlagSet("cve", flag.ExitOnError)
+		cveCmd := newFlagSet("cve", out)
 		filePath := cveCmd.String("file", "", "Chemin vers le fichier PHP à analyser")
-		cveCmd.Parse(os.Args[2:])
-		if *filePath == "" {
-			fmt.Println("Le flag -file est requis pour la commande cve.")
+		taintDBReads := cveCmd.Bool("taint-db-reads", false, "Considérer les valeurs lues en base comme contaminées (injections de second ordre)")
+		calibrationPath := cveCmd.String("calibration", defaultCalibrationPath, "Fichier de calibration issu du triage")
+		rulesPath := cveCmd.String("rules", "", "Fichier ou dossier de règles YAML supplémentaires")
+		advisoriesPath := cveCmd.String("advisories", defaultAdvisoriesPath, "Fichier d'avis écrit par rules update")
+		selection := addSelectionFlags(cveCmd)
+		baselinePath := cveCmd.String("baseline", "", "Baseline des résultats existants (créée si absente), pour ne signaler que les nouveaux")
+		projectDir := cveCmd.String("project", "", "Dossier du projet dont les fonctions sont indexées (polyfills, fonctions de nettoyage)")
+		phpVersion := cveCmd.String("php-version", "", "Version de PHP ciblée (restreint les CVE aux versions affectées)")
+		failOn := cveCmd.String("fail-on", "info", "Sévérité à partir de laquelle un résultat fait échouer la commande (code de sortie 1)")
+		filters := addFilterFlags(cveCmd)
+		blame := cveCmd.Bool("blame", false, "Attribue chaque résultat au dernier commit de sa ligne (git blame)")
+		excludes := addExcludeFlags(cveCmd)
+		changed := addChangedFlags(cveCmd)
+		caching := addCacheFlags(cveCmd)
+		inputs, err := analyzer.parseCommandLine(cveCmd, args)
+		if err != nil {
+			return err
+		}
+		if err := applyExcludes(analyzer, excludes); err != nil {
+			return err
+		}
+		if err := applyChanged(analyzer, changed); err != nil {
+			return err
+		}
+		inputs = changed.defaultInputs(inputs, *filePath)
+		analyzer.stdinDefault(filePath, inputs...)
+		if err := applyFilters(analyzer, filters); err != nil {
+			return err
+		}
+		analyzer.Blame = *blame
+		if err := parseFailOn(analyzer, *failOn); err != nil {
+			return err
+		}
+		analyzer.TaintDBReads = *taintDBReads
+		analyzer.PHPVersion = *phpVersion
+		if err := loadCalibration(analyzer, *calibrationPath); err != nil {
+			return err
+		}
+		if err := loadSignatures(analyzer, *rulesPath, *advisoriesPath); err != nil {
+			return err
+		}
+		if err := applySelection(analyzer, selection); err != nil {
+			return err
+		}
+		if err := loadBaseline(analyzer, *baselinePath); err != nil {
+			return err
+		}
+		if *filePath == "" && len(inputs) == 0 {
+			fmt.Fprintln(out, "Le flag -file ou un chemin est requis pour la commande cve.")
 			cveCmd.Usage()
-			os.Exit(1)
+			return errUsage
+		}
+		analyzer.functions, analyzer.symbols, analyzer.classes, analyzer.calls = nil, nil, nil, nil
+		if *projectDir != "" {
+			if err := analyzer.IndexProject(ctx, *projectDir); err != nil {
+				return fmt.Errorf("Erreur lors de l'indexation du projet %q: %v", *projectDir, err)
+			}
 		}
-		tree, content, err := analyzer.ParseFile(*filePath)
+		// Plusieurs chemins : les résultats sont rassemblés comme ceux d'analyze-dir.
+		if len(inputs) > 0 {
+			paths := scanPaths(*filePath, inputs)
+			if *projectDir == "" {
+				if err := analyzer.IndexProject(ctx, paths...); err != nil {
+					analyzer.fileError("Erreur lors de l'indexation des fonctions de %s: %v", quotePaths(paths), err)
+				}
+			}
+			applyCache(analyzer, caching)
+			analyzer.analyzeFiles(ctx, paths)
+			analyzer.writeOmitted(out)
+			return analyzer.Baseline.Finish(analyzer.messages(out))
+		}
+		tree, content, err := analyzer.ParseFile(ctx, *filePath)
 		if err != nil {
-			log.Fatalf("Erreur lors du parsing du fichier %q: %v", *filePath, err)
+			return fmt.Errorf("Erreur lors du parsing du fichier %q: %v", *filePath, err)
 		}
-		detections := analyzer.DetectVulnerabilities(tree.RootNode(), content)
-		if len(detections) > 0 {
-			for _, d := range detections {
-				fmt.Printf("[%s] %s (ligne %d)\n", d.CVE, d.Message, d.Line)
+		// Sans -project, le fichier analysé est indexé avec les fichiers qu'il inclut ou
+		// dont il nomme les classes (PSR-4).
+		if *projectDir == "" && *filePath != stdinPath {
+			if err := analyzer.IndexProject(ctx, *filePath); err != nil {
+				return fmt.Errorf("Erreur lors de l'indexation des dépendances de %q: %v", *filePath, err)
 			}
 		}
+		findings := analyzer.DetectVulnerabilities(ctx, tree, content)
+		assignFingerprints(fingerprintPath(analyzer.abs("."), *filePath), content, findings)
+		findings = analyzer.filterChanged(*filePath, analyzer.filterSeverity(analyzer.Baseline.Filter(fingerprintPath(analyzer.abs("."), *filePath), content, findings)))
+		analyzer.annotateBlame(*filePath, findings)
+		analyzer.recordFindings(findings)
+		analyzer.run.add(analyzer.abs("."), *filePath, findings)
+		findings = analyzer.limitFindings(findings)
+		if analyzer.report != nil {
+			analyzer.report.addFindings(*filePath, content, findings)
+		} else {
+			for _, f := range findings {
+				analyzer.writeFinding(out, f, content)
+			}
+		}
+		analyzer.writeOmitted(out)
+		return analyzer.Baseline.Finish(analyzer.messages(out))
 
 	case "analyze-dir":
-		dirCmd := flag.NewFlagSet("analyze-dir", flag.ExitOnError)
+		dirCmd := newFlagSet("analyze-dir", out)
 		dirPath := dirCmd.String("dir", "", "Chemin vers le dossier à analyser")
-		dirCmd.Parse(os.Args[2:])
-		if *dirPath == "" {
-			fmt.Println("Le flag -dir est requis pour la commande analyze-dir.")
+		profiling := addProfileFlags(dirCmd)
+		taintDBReads := dirCmd.Bool("taint-db-reads", false, "Considérer les valeurs lues en base comme contaminées (injections de second ordre)")
+		calibrationPath := dirCmd.String("calibration", defaultCalibrationPath, "Fichier de calibration issu du triage")
+		rulesPath := dirCmd.String("rules", "", "Fichier ou dossier de règles YAML supplémentaires")
+		advisoriesPath := dirCmd.String("advisories", defaultAdvisoriesPath, "Fichier d'avis écrit par rules update")
+		selection := addSelectionFlags(dirCmd)
+		baselinePath := dirCmd.String("baseline", "", "Baseline des résultats existants (créée si absente), pour ne signaler que les nouveaux")
+		phpVersion := dirCmd.String("php-version", "", "Version de PHP ciblée (restreint les CVE aux versions affectées)")
+		failOn := dirCmd.String("fail-on", "info", "Sévérité à partir de laquelle un résultat fait échouer la commande (code de sortie 1)")
+		filters := addFilterFlags(dirCmd)
+		blame := dirCmd.Bool("blame", false, "Attribue chaque résultat au dernier commit de sa ligne (git blame)")
+		excludes := addExcludeFlags(dirCmd)
+		changed := addChangedFlags(dirCmd)
+		caching := addCacheFlags(dirCmd)
+		inputs, err := analyzer.parseCommandLine(dirCmd, args)
+		if err != nil {
+			return err
+		}
+		if err := applyExcludes(analyzer, excludes); err != nil {
+			return err
+		}
+		if err := applyChanged(analyzer, changed); err != nil {
+			return err
+		}
+		inputs = changed.defaultInputs(inputs, *dirPath)
+		dirPaths := scanPaths(*dirPath, inputs)
+		if err := applyFilters(analyzer, filters); err != nil {
+			return err
+		}
+		analyzer.Blame = *blame
+		if err := parseFailOn(analyzer, *failOn); err != nil {
+			return err
+		}
+		analyzer.TaintDBReads = *taintDBReads
+		analyzer.PHPVersion = *phpVersion
+		if err := loadCalibration(analyzer, *calibrationPath); err != nil {
+			return err
+		}
+		if err := loadSignatures(analyzer, *rulesPath, *advisoriesPath); err != nil {
+			return err
+		}
+		if err := applySelection(analyzer, selection); err != nil {
+			return err
+		}
+		if err := loadBaseline(analyzer, *baselinePath); err != nil {
+			return err
+		}
+		if len(dirPaths) == 0 {
+			fmt.Fprintln(out, "Le flag -dir ou un chemin est requis pour la commande analyze-dir.")
 			dirCmd.Usage()
-			os.Exit(1)
+			return errUsage
+		}
+		if err := applyProfile(ctx, analyzer, profiling, dirPaths...); err != nil {
+			return err
 		}
-		analyzer.AnalyzeDirectory(*dirPath)
+		applyCache(analyzer, caching)
+		analyzer.AnalyzeDirectory(ctx, dirPaths...)
+		analyzer.writeOmitted(out)
+		return analyzer.Baseline.Finish(analyzer.messages(out))
 
 	// Nouvelle commande "dead" pour la détection du code mort
 	case "dead":
-		deadCmd := flag.NewFlagSet("dead", flag.ExitOnError)
+		deadCmd := newFlagSet("dead", out)
 		filePath := deadCmd.String("file", "", "Chemin vers le fichier PHP à analyser")
 		dirPath := deadCmd.String("dir", "", "Chemin vers le dossier à analyser récursivement")
-		deadCmd.Parse(os.Args[2:])
-		if *filePath == "" && *dirPath == "" {
-			fmt.Println("Le flag -file ou -dir est requis pour la commande dead.")
+		profiling := addProfileFlags(deadCmd)
+		excludes := addExcludeFlags(deadCmd)
+		changed := addChangedFlags(deadCmd)
+		inputs, err := analyzer.parseCommandLine(deadCmd, args)
+		if err != nil {
+			return err
+		}
+		if err := applyExcludes(analyzer, excludes); err != nil {
+			return err
+		}
+		if err := applyChanged(analyzer, changed); err != nil {
+			return err
+		}
+		inputs = changed.defaultInputs(inputs, *filePath, *dirPath)
+		analyzer.stdinDefault(filePath, append(inputs, *dirPath)...)
+		dirPaths := scanPaths(*dirPath, inputs)
+		if *filePath == "" && len(dirPaths) == 0 {
+			fmt.Fprintln(out, "Le flag -file ou -dir, ou un chemin, est requis pour la commande dead.")
 			deadCmd.Usage()
-			os.Exit(1)
+			return errUsage
 		}
 		// Analyse d'un fichier
 		if *filePath != "" {
-			_, content, err := analyzer.ParseFile(*filePath)
+			_, content, err := analyzer.ParseFile(ctx, *filePath)
 			if err != nil {
-				log.Fatalf("Erreur lors du parsing du fichier %q: %v", *filePath, err)
+				return fmt.Errorf("Erreur lors du parsing du fichier %q: %v", *filePath, err)
 			}
 			builder := NewCFGBuilder()
-			cfg, err := builder.BuildCFG(content)
+			cfg, err := builder.BuildCFG(ctx, content)
 			if err != nil {
-				log.Fatalf("Erreur lors de la construction du CFG pour le fichier %q: %v", *filePath, err)
+				return fmt.Errorf("Erreur lors de la construction du CFG pour le fichier %q: %v", *filePath, err)
 			}
 			deadNodes := cfg.DetectDeadCode()
-			if len(deadNodes) > 0 {
-				fmt.Printf("Dead code trouvé dans %q:\n", *filePath)
+			if analyzer.report != nil {
+				analyzer.report.addDeadCode(*filePath, cfg, deadNodes)
+			} else if len(deadNodes) > 0 {
+				fmt.Fprintln(out, analyzer.formatHeader(`Dead code trouvé dans "`, *filePath, `":`))
 				for _, id := range deadNodes {
 					if node, exists := cfg.Nodes[id]; exists {
-						fmt.Printf(" - Node %d: %s [%s]\n", node.ID, node.Type, node.code)
+						fmt.Fprintln(out, analyzer.formatDeadNode(node))
 					}
 				}
 			} else {
-				fmt.Printf("Aucun dead code trouvé dans %q.\n", *filePath)
+				fmt.Fprintf(out, "Aucun dead code trouvé dans %q.\n", *filePath)
 			}
 		}
-		// Analyse d'un dossier récursif
-		if *dirPath != "" {
-			analyzer.AnalyzeDirectoryDeadCode(*dirPath)
+		// Analyse des dossiers et chemins positionnels
+		if len(dirPaths) > 0 {
+			if err := applyProfile(ctx, analyzer, profiling, dirPaths...); err != nil {
+				return err
+			}
+			analyzer.AnalyzeDirectoryDeadCode(ctx, dirPaths...)
 		}
 
 	case "deadcount":
-		deadCountCmd := flag.NewFlagSet("deadcount", flag.ExitOnError)
+		deadCountCmd := newFlagSet("deadcount", out)
 		filePath := deadCountCmd.String("file", "", "Chemin vers le fichier PHP à analyser")
 		dirPath := deadCountCmd.String("dir", "", "Chemin vers le dossier à analyser récursivement")
-		deadCountCmd.Parse(os.Args[2:])
+		profiling := addProfileFlags(deadCountCmd)
+		excludes := addExcludeFlags(deadCountCmd)
+		changed := addChangedFlags(deadCountCmd)
+		inputs, err := analyzer.parseCommandLine(deadCountCmd, args)
+		if err != nil {
+			return err
+		}
+		if err := applyExcludes(analyzer, excludes); err != nil {
+			return err
+		}
+		if err := applyChanged(analyzer, changed); err != nil {
+			return err
+		}
+		inputs = changed.defaultInputs(inputs, *filePath, *dirPath)
+		analyzer.stdinDefault(filePath, append(inputs, *dirPath)...)
+		dirPaths := scanPaths(*dirPath, inputs)
 
-		if *filePath == "" && *dirPath == "" {
-			fmt.Println("Le flag -file ou -dir est requis pour la commande deadcount.")
+		if *filePath == "" && len(dirPaths) == 0 {
+			fmt.Fprintln(out, "Le flag -file ou -dir, ou un chemin, est requis pour la commande deadcount.")
 			deadCountCmd.Usage()
-			os.Exit(1)
+			return errUsage
 		}
 
 		// Analyse d'un fichier
 		if *filePath != "" {
-			_, content, err := analyzer.ParseFile(*filePath)
+			_, content, err := analyzer.ParseFile(ctx, *filePath)
 			if err != nil {
-				log.Fatalf("Erreur lors du parsing du fichier %q: %v", *filePath, err)
+				return fmt.Errorf("Erreur lors du parsing du fichier %q: %v", *filePath, err)
 			}
 			builder := NewCFGBuilder()
-			cfg, err := builder.BuildCFG(content)
+			cfg, err := builder.BuildCFG(ctx, content)
 			if err != nil {
-				log.Fatalf("Erreur lors de la construction du CFG pour le fichier %q: %v", *filePath, err)
+				return fmt.Errorf("Erreur lors de la construction du CFG pour le fichier %q: %v", *filePath, err)
 			}
 			deadNodes := cfg.DetectDeadCode()
-			fmt.Printf("Nombre de dead code détecté dans %q : %d\n", *filePath, len(deadNodes))
+			if analyzer.report != nil {
+				analyzer.report.addMetric(*filePath, "deadCode", len(deadNodes))
+			} else {
+				fmt.Fprintf(out, "Nombre de dead code détecté dans %q : %d\n", *filePath, len(deadNodes))
+			}
 		}
 
-		// Analyse d'un dossier récursif
-		if *dirPath != "" {
-			totalDead := 0
-			err := filepath.Walk(*dirPath, func(path string, info os.FileInfo, err error) error {
-				if err != nil {
-					log.Printf("Erreur d'accès à %q: %v", path, err)
-					return nil
-				}
-				if info.IsDir() || !strings.HasSuffix(strings.ToLower(info.Name()), ".php") {
-					return nil
-				}
-				_, content, err := analyzer.ParseFile(path)
-				if err != nil {
-					log.Printf("Erreur lors du parsing du fichier %q: %v", path, err)
-					return nil
-				}
-				builder := NewCFGBuilder()
-				cfg, err := builder.BuildCFG(content)
-				if err != nil {
-					log.Printf("Erreur lors de la construction du CFG pour le fichier %q: %v", path, err)
-					return nil
-				}
-				deadNodes := cfg.DetectDeadCode()
-				fmt.Printf("Dead code détecté dans %q : %d\n", path, len(deadNodes))
-				totalDead += len(deadNodes)
-				return nil
-			})
+		// Analyse des dossiers et chemins positionnels
+		if len(dirPaths) > 0 {
+			if err := applyProfile(ctx, analyzer, profiling, dirPaths...); err != nil {
+				return err
+			}
+			analyzer.AnalyzeDirectoryDeadCount(ctx, dirPaths...)
+		}
+
+	case "detectors":
+		WriteDetectorList(out)
+
+	case "rules":
+		return runRulesCommand(analyzer, args, out)
+
+	case "tui":
+		return runTUICommand(ctx, analyzer, args, out)
+
+	case "fmt":
+		return runFormatCommand(analyzer, args, out)
+
+	case "bench":
+		return runBenchCommand(ctx, analyzer, args, out)
+
+	case "history", "trends":
+		return runHistoryCommand(analyzer, command, args, out)
+
+	case "symbols":
+		return runSymbolsCommand(ctx, analyzer, args, out)
+
+	case "calls":
+		return runCallsCommand(ctx, analyzer, args, out)
+
+	case "dead-functions":
+		return runDeadFunctionsCommand(ctx, analyzer, args, out)
+
+	case "triage-stats":
+		triageCmd := newFlagSet("triage-stats", out)
+		historyPath := triageCmd.String("history", "", "Historique de triage (JSON Lines)")
+		apply := triageCmd.Bool("apply", false, "Enregistre les déclassements suggérés dans le fichier de calibration")
+		calibrationPath := triageCmd.String("calibration", defaultCalibrationPath, "Fichier de calibration")
+		if err := triageCmd.Parse(args); err != nil {
+			return errUsage
+		}
+		analyzer.resolvePathFlags(triageCmd)
+		if *historyPath == "" {
+			fmt.Fprintln(out, "Le flag -history est requis pour la commande triage-stats.")
+			triageCmd.Usage()
+			return errUsage
+		}
+		records, err := LoadTriageHistory(*historyPath)
+		if err != nil {
+			return fmt.Errorf("Erreur lors de la lecture de l'historique %q: %v", *historyPath, err)
+		}
+		stats := ComputeTriageStats(records)
+		suggested := SuggestCalibration(stats)
+		WriteTriageReport(out, stats, suggested)
+		if *apply {
+			calibration, err := LoadCalibration(*calibrationPath)
 			if err != nil {
-				log.Printf("Erreur lors de la traversée du dossier %q: %v", *dirPath, err)
+				return fmt.Errorf("Erreur lors de la lecture de la calibration %q: %v", *calibrationPath, err)
+			}
+			for rule, levels := range suggested {
+				calibration[rule] = levels
+			}
+			if err := SaveCalibration(*calibrationPath, calibration); err != nil {
+				return fmt.Errorf("Erreur lors de l'écriture de la calibration %q: %v", *calibrationPath, err)
 			}
-			fmt.Printf("\nNombre total de dead code détecté dans %q : %d\n", *dirPath, totalDead)
+			fmt.Fprintf(out, "Calibration enregistrée dans %q.\n", *calibrationPath)
 		}
 
 	default:
-		fmt.Printf("Commande inconnue : %q\n", command)
-		printUsage()
-		os.Exit(1)
+		fmt.Fprintf(out, "Commande inconnue : %q\n", command)
+		printUsage(out)
+		return errUsage
+	}
+	return nil
+}
+
+func main() {
+	globalFlags := flag.NewFlagSet("php-analyzer", flag.ExitOnError)
+	useDaemon := globalFlags.Bool("use-daemon", false, "Envoie la commande au démon php-analyzer")
+	socketPath := globalFlags.String("socket", defaultSocketPath(), "Chemin du socket du démon")
+	maxWidth := globalFlags.Int("max-width", -1, "Largeur maximale des lignes (0 = aucune limite, par défaut : largeur du terminal)")
+	format := globalFlags.String("format", formatText, "Format de sortie : text, json, jsonl, junit, markdown ou template")
+	templatePath := globalFlags.String("template", "", "Modèle text/template des résultats, avec -format=template")
+	contextLines := globalFlags.Int("context", 0, "Lignes de contexte autour du code signalé (-1 = aucun extrait)")
+	outputTarget := globalFlags.String("o", "", "Fichier ou dossier où écrire les résultats, remplacé seulement si l'analyse est complète")
+	cpuProfile := globalFlags.String("cpuprofile", "", "Fichier où écrire le profil CPU de la commande (pprof)")
+	memProfile := globalFlags.String("memprofile", "", "Fichier où écrire le profil mémoire en fin de commande (pprof)")
+	storePath := globalFlags.String("store", "", "Base SQLite où enregistrer les résultats de cve et analyze-dir à chaque exécution")
+	globalFlags.Usage = func() { printUsage(os.Stdout) }
+	globalFlags.Parse(os.Args[1:])
+
+	args := globalFlags.Args()
+	if len(args) < 1 {
+		printUsage(os.Stdout)
+		os.Exit(exitError)
+	}
+	command := args[0]
+	switch *format {
+	case formatText, formatJSON, formatJSONL, formatJUnit, formatMarkdown, formatTemplate:
+	default:
+		fmt.Fprintf(os.Stderr, "Format inconnu : %q (text, json, jsonl, junit, markdown ou template).\n", *format)
+		os.Exit(exitError)
+	}
+	var tmpl *template.Template
+	if (*format == formatTemplate) != (*templatePath != "") {
+		fmt.Fprintln(os.Stderr, "Les options -format=template et -template vont ensemble.")
+		os.Exit(exitError)
+	} else if *templatePath != "" {
+		var err error
+		if tmpl, err = loadTemplate(*templatePath); err != nil {
+			fmt.Fprintf(os.Stderr, "Erreur lors de la lecture du modèle %q: %v\n", *templatePath, err)
+			os.Exit(exitError)
+		}
+	}
+	if *maxWidth < 0 && *outputTarget != "" {
+		*maxWidth = 0
+	} else if *maxWidth < 0 {
+		*maxWidth = detectWidth()
+	}
+
+	if command == "daemon" {
+		if err := ServeDaemon(*socketPath); err != nil {
+			log.Fatalf("Erreur du démon: %v", err)
+		}
+		return
+	}
+	if command == "serve" {
+		if err := runServeCommand(args[1:], os.Stdout); err != nil {
+			if !errors.Is(err, errUsage) {
+				log.Printf("Erreur du serveur: %v", err)
+			}
+			os.Exit(exitError)
+		}
+		return
+	}
+	outFile, err := openOutput(*outputTarget, *format)
+	if err != nil {
+		log.Print(err)
+		os.Exit(exitError)
+	}
+	out := io.Writer(os.Stdout)
+	var stopProfiling func() error
+	exit := func(code int) {
+		if stopProfiling != nil {
+			if err := stopProfiling(); err != nil {
+				log.Print(err)
+			}
+		}
+		if outFile != nil {
+			code = outFile.finish(code)
+		}
+		os.Exit(code)
+	}
+	if outFile != nil {
+		out = outFile
+	}
+
+	if *useDaemon && readsStdin(args, isPiped(os.Stdin)) {
+		log.Print("L'entrée standard n'est pas transmise au démon : analyse locale.")
+	} else if *useDaemon && tmpl != nil {
+		log.Print("Le modèle -template n'est pas transmis au démon : analyse locale.")
+	} else if *useDaemon && *storePath != "" {
+		log.Print("L'option --store n'est pas transmise au démon : analyse locale.")
+	} else if *useDaemon {
+		exitCode, err := RunViaDaemon(*socketPath, args, *maxWidth, *contextLines, *format, out)
+		if err == nil {
+			exit(exitCode)
+		}
+		log.Printf("Démon indisponible (%v), analyse locale.", err)
+	}
+
+	if stopProfiling, err = startProfiling(*cpuProfile, *memProfile); err != nil {
+		log.Print(err)
+		exit(exitError)
+	}
+	analyzer := NewPHPAnalyzer()
+	analyzer.MaxWidth = *maxWidth
+	analyzer.ContextLines = *contextLines
+	analyzer.Format = *format
+	analyzer.Template = tmpl
+	analyzer.Store = *storePath
+	analyzer.Stdin = os.Stdin
+	analyzer.stdinPiped = isPiped(os.Stdin)
+	// Ctrl-C interrompt l'analyse : les résultats déjà obtenus sont écrits et la commande
+	// se termine en erreur. Un second Ctrl-C termine le processus immédiatement.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err = runCommandContext(ctx, analyzer, command, args[1:], out)
+	if err != nil && !errors.Is(err, errUsage) {
+		log.Print(err)
 	}
+	exit(analyzer.exitCode(err))
 }
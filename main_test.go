@@ -0,0 +1,833 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// detect parse le code PHP et retourne les vulnérabilités détectées.
+func detect(t *testing.T, phpCode string) []Finding {
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), []byte(phpCode))
+	assert.NoError(t, err)
+	return analyzer.DetectVulnerabilities(context.Background(), tree, []byte(phpCode))
+}
+
+func TestDetectXXE(t *testing.T) {
+	phpCode := `<?php
+$a = simplexml_load_string($xml, "SimpleXMLElement", LIBXML_NOENT);
+$dom->loadXML($xml, LIBXML_NOENT | LIBXML_DTDLOAD);
+libxml_disable_entity_loader(false);
+$safe = simplexml_load_string($xml);
+libxml_disable_entity_loader(true);`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 3)
+	for _, d := range detections {
+		assert.Equal(t, "xxe / CWE-611", d.Label())
+	}
+	assert.Equal(t, uint32(2), detections[0].Line)
+	assert.Equal(t, uint32(3), detections[1].Line)
+	assert.Equal(t, uint32(4), detections[2].Line)
+}
+
+func TestDetectInsecureCookies(t *testing.T) {
+	phpCode := `<?php
+setcookie("a", "b");
+setcookie("a", "b", time() + 3600, "/", "", true, true);
+setcookie("a", "b", ["secure" => true, "httponly" => true, "samesite" => "Strict"]);
+setcookie("a", "b", secure: true, httponly: true);
+session_set_cookie_params(["lifetime" => 0, "secure" => true]);
+setcookie("a", "b", options: $opts);`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 4)
+	assert.Equal(t, "setcookie sans les options secure, httponly, samesite détecté", detections[0].Message)
+	assert.Equal(t, "setcookie sans les options samesite détecté", detections[1].Message)
+	assert.Equal(t, uint32(5), detections[2].Line)
+	assert.Equal(t, "session_set_cookie_params sans les options httponly, samesite détecté", detections[3].Message)
+}
+
+func TestDetectSessionFixation(t *testing.T) {
+	phpCode := `<?php
+session_start();
+$sid = $_GET["sid"];
+session_id($sid);
+session_id(bin2hex(random_bytes(16)));
+
+function login_ok($user, $hash) {
+	if (password_verify($user, $hash)) {
+		session_regenerate_id(true);
+		$_SESSION["user"] = $user;
+	}
+}
+
+function login_bad($user, $hash) {
+	if (password_verify($user, $hash)) {
+		$_SESSION["user"] = $user;
+	}
+}`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 2)
+	assert.Equal(t, uint32(4), detections[0].Line)
+	assert.Equal(t, "session_id() défini à partir d'une entrée utilisateur", detections[0].Message)
+	assert.Equal(t, uint32(15), detections[1].Line)
+	assert.Equal(t, "session-regeneration / CWE-384", detections[1].Label())
+}
+
+func TestDetectSessionRegeneration(t *testing.T) {
+	phpCode := `<?php
+function check($user, $hash) {
+	if (!password_verify($user, $hash)) {
+		return false;
+	}
+	$_SESSION["user"] = $user;
+}
+
+function laravel($credentials, $request) {
+	if (Auth::attempt($credentials)) {
+		return redirect('/');
+	}
+}
+
+function laravel_ok($credentials, $request) {
+	if (\Illuminate\Support\Facades\Auth::attempt($credentials)) {
+		$request->session()->regenerate();
+	}
+}
+
+function service($auth, $user) {
+	$auth->login($user);
+	$_SESSION["id"] = $user->id;
+}
+
+function unrelated($user) {
+	$url = loginUrl($user);
+	$count = attemptCount($user);
+	$_SESSION["url"] = $url;
+	$this->mailer->login($user);
+	if (password_verify($user, $hash)) {
+		log_failure();
+	} else {
+		$_SESSION["failed"] = true;
+	}
+	$ok = password_verify($user, $hash);
+}`
+
+	var lines []uint32
+	for _, d := range detect(t, phpCode) {
+		assert.Equal(t, "session-regeneration / CWE-384", d.Label())
+		lines = append(lines, d.Line)
+	}
+	assert.Equal(t, []uint32{3, 10, 22}, lines,
+		"login*/attempt* helpers, non-auth objects and failure branches of password_verify are not authentication")
+}
+
+func TestDetectLooseSensitiveComparison(t *testing.T) {
+	phpCode := `<?php
+if (md5($input) == $stored) {}
+if (strcmp($a, $b) == 0) {}
+if ($password != $confirm) {}
+if ($user->token == $token) {}
+if (hash_equals($stored, md5($input))) {}
+if (md5($input) === $stored) {}
+if ($count == 3) {}`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 4)
+	for i, d := range detections {
+		assert.Equal(t, "type-juggling / CWE-697", d.Label())
+		assert.Equal(t, uint32(i+2), d.Line)
+	}
+}
+
+func TestDetectUnsafeUpload(t *testing.T) {
+	phpCode := `<?php
+function unsafe() {
+	$name = $_FILES["avatar"]["name"];
+	move_uploaded_file($_FILES["avatar"]["tmp_name"], "uploads/" . $name);
+}
+
+function mimeOnly() {
+	if ($_FILES["doc"]["size"] < 100000) {
+		move_uploaded_file($_FILES["doc"]["tmp_name"], "docs/" . $_FILES["doc"]["name"]);
+	}
+}
+
+function whitelisted() {
+	$ext = pathinfo($_FILES["img"]["name"], PATHINFO_EXTENSION);
+	if (in_array($ext, ["png", "jpg"])) {
+		move_uploaded_file($_FILES["img"]["tmp_name"], "img/" . $_FILES["img"]["name"]);
+	}
+}
+
+function renamed() {
+	move_uploaded_file($_FILES["f"]["tmp_name"], "files/" . bin2hex(random_bytes(8)));
+}`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 2)
+	assert.Equal(t, "unsafe-upload / CWE-434", detections[0].Label())
+	assert.Equal(t, uint32(4), detections[0].Line)
+	assert.Contains(t, detections[0].Message, "sans validation du type MIME ni de la taille")
+	assert.Equal(t, uint32(9), detections[1].Line)
+	assert.NotContains(t, detections[1].Message, "MIME")
+}
+
+func TestDetectUnsafeUploadChecksOnEveryPath(t *testing.T) {
+	phpCode := `<?php
+function checkedAfter() {
+	move_uploaded_file($_FILES["a"]["tmp_name"], "up/" . $_FILES["a"]["name"]);
+	$ext = pathinfo($_FILES["a"]["name"], PATHINFO_EXTENSION);
+	$type = mime_content_type("up/" . $_FILES["a"]["name"]);
+}
+
+function oneBranch($strict) {
+	if ($strict) {
+		$ext = pathinfo($_FILES["b"]["name"], PATHINFO_EXTENSION);
+	}
+	move_uploaded_file($_FILES["b"]["tmp_name"], "up/" . $_FILES["b"]["name"]);
+}
+
+function everyBranch($strict) {
+	if ($strict) {
+		$ext = pathinfo($_FILES["c"]["name"], PATHINFO_EXTENSION);
+	} else {
+		if (!preg_match('/\\.(png|jpe?g)$/', $_FILES["c"]["name"])) {
+			return;
+		}
+	}
+	move_uploaded_file($_FILES["c"]["tmp_name"], "up/" . $_FILES["c"]["name"]);
+}`
+
+	var lines []uint32
+	for _, d := range detect(t, phpCode) {
+		assert.Equal(t, "unsafe-upload / CWE-434", d.Label())
+		lines = append(lines, d.Line)
+	}
+	assert.Equal(t, []uint32{3, 12}, lines, "checks after the move or on a single branch do not protect it")
+}
+
+func TestDetectDangerousConfiguration(t *testing.T) {
+	phpCode := `<?php
+ini_set('allow_url_include', '1');
+ini_set("display_errors", "On");
+ini_set('display_errors', '0');
+error_reporting(0);
+if (APP_ENV === 'dev') {
+	error_reporting(0);
+}
+extract($_GET);
+extract($row);
+if (APP_ENV === 'dev') {
+	error_reporting(E_ALL);
+} elseif (DEBUG) {
+	error_reporting(0);
+} else {
+	error_reporting(0);
+}`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 5)
+	assert.Equal(t, "ini_set active l'option dangereuse allow_url_include", detections[0].Message)
+	assert.Equal(t, "ini_set active l'option dangereuse display_errors", detections[1].Message)
+	assert.Equal(t, uint32(5), detections[2].Line)
+	assert.Equal(t, "register-globals / CWE-621", detections[3].Label())
+	assert.Equal(t, uint32(16), detections[4].Line, "the else branch of a development condition is production code")
+}
+
+func TestDetectDebugLeftovers(t *testing.T) {
+	phpCode := `<?php
+var_dump($user);
+print_r($config);
+$s = print_r($config, true);
+debug_print_backtrace();
+phpinfo();
+$logger->var_dump($x);`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 4)
+	assert.Equal(t, "appel de débogage var_dump() oublié", detections[0].Message)
+	assert.Equal(t, uint32(3), detections[1].Line)
+	assert.Equal(t, uint32(5), detections[2].Line)
+	assert.Equal(t, "debug-leftover / CWE-489", detections[3].Label())
+}
+
+func TestDetectErrorDisclosure(t *testing.T) {
+	phpCode := `<?php
+$link = mysql_connect("h", "u", "p") or die(mysql_error());
+try {
+	run();
+} catch (Exception $e) {
+	echo "Erreur : " . $e->getMessage();
+	log_error($e->getMessage());
+}
+print mysqli_error($c);
+exit("Echec : {$db->error}");
+echo "ok";`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 4)
+	assert.Equal(t, "die() affiche le détail d'une erreur : mysql_error()", detections[0].Message)
+	assert.Equal(t, "echo affiche le détail d'une erreur : $e->getMessage()", detections[1].Message)
+	assert.Equal(t, uint32(9), detections[2].Line)
+	assert.Equal(t, "error-disclosure / CWE-209", detections[3].Label())
+}
+
+func TestDetectConcatenatedSQL(t *testing.T) {
+	phpCode := `<?php
+mysql_query("SELECT * FROM users WHERE id=" . $id);
+mysqli_query($link, "SELECT * FROM users WHERE name='$name'");
+$sql = "DELETE FROM posts WHERE id=";
+$sql .= $postId;
+$pdo->query($sql);
+$wpdb->get_results("SELECT * FROM " . "wp_posts");
+$stmt = $pdo->prepare("SELECT * FROM users WHERE id = ?");
+mysqli_query($link, "SELECT 1");`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 3)
+	assert.Equal(t, "sqli-concat / CWE-89", detections[0].Label())
+	assert.Equal(t, "requête SQL construite par concaténation passée à mysql_query", detections[0].Message)
+	assert.Equal(t, uint32(3), detections[1].Line)
+	assert.Equal(t, uint32(6), detections[2].Line)
+}
+
+func TestDatabaseCallStatements(t *testing.T) {
+	phpCode := []byte(`<?php
+mysql_query("SELECT u.name FROM users u JOIN ` + "`orders`" + ` o ON o.user_id = u.id WHERE u.id=" . $id);
+$sql = "UPDATE accounts SET balance = 0";
+$sql .= " WHERE id = $id";
+$pdo->exec($sql);
+$wpdb->get_results($wpdb->prepare("SELECT * FROM {$wpdb->posts}, {$wpdb->postmeta} WHERE ID = %d", $id));
+$wpdb->insert($wpdb->prefix . "log", $data);
+mysqli_query($link, <<<SQL
+  CREATE TABLE IF NOT EXISTS sessions (id INT)
+SQL);
+mysqli_query($link, "INSERT INTO stats (n) VALUES (1) ON DUPLICATE KEY UPDATE n = n + 1");
+mysqli_query($link, $query);`)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+	calls := analyzer.DetectDatabaseCalls(tree.RootNode(), phpCode)
+
+	var statements []string
+	for _, call := range calls {
+		if call.SQL == nil {
+			statements = append(statements, "")
+		} else {
+			statements = append(statements, call.SQL.String())
+		}
+	}
+	assert.Equal(t, []string{
+		"SELECT users, orders",
+		"UPDATE accounts",
+		"SELECT {$wpdb->posts}, {$wpdb->postmeta}",
+		"SELECT {$wpdb->posts}, {$wpdb->postmeta}",
+		"INSERT {$wpdb->prefix}log",
+		"DDL sessions",
+		"INSERT stats",
+		"",
+	}, statements)
+	assert.Equal(t, "Appel trouvé : mysql_query (SELECT users, orders) [requête concaténée]", calls[0].Message)
+	assert.Equal(t, SQLStatement{Kind: sqlSelect, Tables: []string{"a", "b"}}, classifySQL("/* stats */ (SELECT x.id FROM a AS x, b WHERE 1)"))
+}
+
+func TestLaravelDatabaseCalls(t *testing.T) {
+	phpCode := []byte(`<?php
+$users = DB::select("SELECT * FROM users WHERE active = ?", [1]);
+DB::statement("DROP TABLE " . $table);
+$posts = Post::where("user_id", $id)->whereRaw("score > " . $min)->get();
+$count = DB::table("orders")->selectRaw("count(*) as n")->first();
+$user = \App\Models\User::find($id);
+$value = Cache::get("key");
+$all = $request->all();`)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+
+	var calls []string
+	for _, call := range analyzer.DetectDatabaseCalls(tree.RootNode(), phpCode) {
+		calls = append(calls, fmt.Sprintf("%d %s %s", call.Line, call.Severity, call.Message))
+	}
+	assert.Equal(t, []string{
+		"2 info Appel trouvé : DB::select(...) (SELECT users) [requête paramétrée]",
+		"3 medium Appel trouvé : DB::statement(...) (DDL {$table}) [requête concaténée]",
+		"4 info Appel trouvé : Post::where(...)->get() (SELECT) [requête paramétrée]",
+		"4 medium Appel trouvé : ->whereRaw(...) [requête concaténée]",
+		"5 info Appel trouvé : DB::table(...)->first() (SELECT orders) [requête paramétrée]",
+		"5 info Appel trouvé : ->selectRaw(...) [requête littérale]",
+		"6 info Appel trouvé : User::find(...) (SELECT) [requête paramétrée]",
+	}, calls)
+
+	detections := detect(t, string(phpCode))
+	assert.Len(t, detections, 2)
+	assert.Equal(t, "requête SQL construite par concaténation passée à DB::statement", detections[0].Message)
+	assert.Equal(t, "requête SQL construite par concaténation passée à whereRaw", detections[1].Message)
+}
+
+func TestDoctrineDatabaseCalls(t *testing.T) {
+	phpCode := []byte(`<?php
+function report($conn, $em, $id, $status) {
+	$rows = $conn->fetchAllAssociative("SELECT id, total FROM orders WHERE status = ?", [$status]);
+	$conn->executeStatement("DELETE FROM sessions WHERE user_id = " . $id);
+	$users = $em->createQuery("SELECT u FROM App\Entity\User u WHERE u.active = 1")->getResult();
+	$qb = $em->createQueryBuilder();
+	$qb->select("u")->from(User::class, "u");
+	$result = $qb->where("u.id = " . $id)->getQuery()->getResult();
+	$conn->createQueryBuilder()->update("accounts")->set("balance", 0)->executeStatement();
+}`)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+
+	var calls []string
+	for _, call := range analyzer.DetectDatabaseCalls(tree.RootNode(), phpCode) {
+		calls = append(calls, fmt.Sprintf("%d %s %s", call.Line, call.Severity, call.Message))
+	}
+	assert.Equal(t, []string{
+		"3 info Appel trouvé : ->fetchAllAssociative(...) (SELECT orders) [requête paramétrée]",
+		"4 medium Appel trouvé : ->executeStatement(...) (DELETE sessions) [requête concaténée]",
+		`5 info Appel trouvé : ->createQuery(...) (SELECT App\Entity\User) [requête littérale]`,
+		"8 low Appel trouvé : QueryBuilder->getQuery() (SELECT User) [requête concaténée]",
+		"8 medium Appel trouvé : QueryBuilder->where(...) [requête concaténée]",
+		"9 info Appel trouvé : QueryBuilder->executeStatement() (UPDATE accounts) [requête paramétrée]",
+	}, calls)
+
+	detections := detect(t, string(phpCode))
+	assert.Len(t, detections, 2)
+	assert.Equal(t, "requête SQL construite par concaténation passée à where", detections[1].Message)
+}
+
+func TestQueryConstruction(t *testing.T) {
+	phpCode := []byte(`<?php
+mysqli_query($link, "SELECT * FROM users");
+mysqli_query($link, "SELECT * FROM users WHERE name = '$name'");
+mysqli_query($link, sprintf("SELECT * FROM users WHERE id = %d", $id));
+$pdo->prepare("SELECT * FROM users WHERE id = :id AND role = 'a:b'")->execute(["id" => $id])->fetch();
+$wpdb->get_results($wpdb->prepare("SELECT * FROM wp_posts WHERE ID = %d", $id));
+$wpdb->insert("wp_log", ["msg" => $msg]);
+$check = $pdo->prepare("SELECT 1 WHERE note = '?'");
+$check->execute()->fetch();
+$pdo->exec($query);`)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+
+	var constructions []string
+	for _, call := range analyzer.DetectDatabaseCalls(tree.RootNode(), phpCode) {
+		constructions = append(constructions, fmt.Sprintf("%d %s", call.Line, call.Query))
+	}
+	assert.Equal(t, []string{
+		"2 literal", "3 concatenated", "4 concatenated", "5 parameterized",
+		"6 parameterized", "6 parameterized", "7 parameterized", "9 literal", "10 unknown",
+	}, constructions)
+}
+
+func TestDetectTaintedSinks(t *testing.T) {
+	phpCode := `<?php
+$name = $_GET["name"];
+echo "Bonjour " . $name;
+echo htmlspecialchars($name);
+print (int) $_GET["page"];
+mysqli_query($link, "SELECT * FROM users WHERE name='" . $name . "'");
+$id = mysqli_real_escape_string($link, $_POST["id"]);
+mysqli_query($link, "SELECT * FROM users WHERE id='$id'");`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 3)
+	assert.Equal(t, "xss / CWE-79", detections[0].Label())
+	assert.Equal(t, "echo affiche une donnée contaminée (entrée utilisateur) sans échappement", detections[0].Message)
+	assert.Equal(t, "requête SQL passée à mysqli_query contenant une donnée contaminée (entrée utilisateur)", detections[1].Message)
+	assert.Equal(t, uint32(8), detections[2].Line)
+	assert.Contains(t, detections[2].Message, "concaténation")
+}
+
+func TestDetectSecondOrderInjection(t *testing.T) {
+	phpCode := `<?php
+$row = mysqli_fetch_assoc($result);
+echo "<h1>" . $row["title"] . "</h1>";
+$comment = $stmt->fetch();
+$pdo->query("DELETE FROM comments WHERE author = '" . $comment["author"] . "'");`
+
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), []byte(phpCode))
+	assert.NoError(t, err)
+
+	detections := analyzer.DetectVulnerabilities(context.Background(), tree, []byte(phpCode))
+	assert.Len(t, detections, 1, "database reads are not sources by default")
+	assert.Contains(t, detections[0].Message, "concaténation")
+
+	analyzer.TaintDBReads = true
+	detections = analyzer.DetectVulnerabilities(context.Background(), tree, []byte(phpCode))
+	assert.Len(t, detections, 2)
+	assert.Equal(t, "echo affiche une donnée contaminée (lecture en base de données) sans échappement", detections[0].Message)
+	assert.Equal(t, "requête SQL passée à query contenant une donnée contaminée (lecture en base de données)", detections[1].Message)
+}
+
+func TestFrameworkEscapingIsSanitizer(t *testing.T) {
+	phpCode := `<?php
+$name = $_GET["name"];
+echo e($name);
+echo \e($name);
+echo twig_escape_filter($this->env, $name, "html", null, true);
+echo $this->env->getRuntime('Twig\Runtime\EscaperRuntime')->escape($name, "html", null, true);
+echo esc_html($name);
+echo '<a title="' . esc_attr($name) . '" href="' . ESC_URL($name) . '">';
+echo $name;`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 1)
+	assert.Equal(t, uint32(9), detections[0].Line)
+}
+
+func TestDetectStaticNonce(t *testing.T) {
+	phpCode := `<?php
+$a = openssl_encrypt($data, "aes-256-cbc", $key, 0, "1234567890123456");
+$b = openssl_encrypt($data, "aes-256-cbc", $key, OPENSSL_RAW_DATA, ENCRYPTION_IV);
+$c = sodium_crypto_secretbox($msg, self::NONCE, $key);
+$iv = "abcdefghijklmnop";
+$d = openssl_encrypt($data, "aes-256-ctr", $key, 0, $iv);
+$e = openssl_encrypt($data, "aes-256-cbc", $key, iv: str_repeat("0", 16));
+$f = openssl_encrypt($data, "aes-256-cbc", $key, 0, random_bytes(16));
+$g = openssl_encrypt($data, "aes-128-ecb", $key, 0, "");
+$nonce = random_bytes(SODIUM_CRYPTO_SECRETBOX_NONCEBYTES);
+$h = sodium_crypto_secretbox($msg, $nonce, $key);`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 5)
+	for _, d := range detections[:4] {
+		assert.Equal(t, "static-iv / CWE-1204", d.Label())
+	}
+	assert.Equal(t, "weak-cipher", detections[4].RuleID, "ECB is reported by the weak-cipher rule only")
+	assert.Equal(t, "openssl_encrypt avec un IV/nonce statique (chaîne littérale)", detections[0].Message)
+	assert.Equal(t, "openssl_encrypt avec un IV/nonce statique (constante ENCRYPTION_IV)", detections[1].Message)
+	assert.Equal(t, "sodium_crypto_secretbox avec un IV/nonce statique (constante self::NONCE)", detections[2].Message)
+	assert.Equal(t, uint32(6), detections[3].Line)
+}
+
+func TestDetectWeakCipher(t *testing.T) {
+	phpCode := `<?php
+$a = openssl_encrypt($data, "des-ede3-cbc", $key, 0, $iv);
+$b = openssl_encrypt($data, 'RC4', $key);
+$c = openssl_encrypt($data, "aes-256-ecb", $key);
+$d = mcrypt_encrypt(MCRYPT_RIJNDAEL_128, $key, $data, MCRYPT_MODE_CBC, $iv);
+$e = openssl_encrypt($data, "aes-256-cbc", $key, 0, $iv);
+$f = openssl_encrypt($data, $cipher, $key, 0, $iv);`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 4)
+	assert.Equal(t, "weak-cipher / CWE-327", detections[0].Label())
+	assert.Equal(t, "openssl_encrypt : algorithme obsolète (des-ede3-cbc)", detections[0].Message)
+	assert.Equal(t, "openssl_encrypt : algorithme obsolète (rc4)", detections[1].Message)
+	assert.Equal(t, "openssl_encrypt : mode ECB (aes-256-ecb)", detections[2].Message)
+	assert.Equal(t, "mcrypt_encrypt : extension mcrypt obsolète", detections[3].Message)
+}
+
+func TestDetectPharDeserialization(t *testing.T) {
+	phpCode := `<?php
+$file = $_GET["file"];
+if (file_exists($file)) {
+    $size = filesize("uploads/" . $file);
+}
+$hash = hash_file("sha256", $_POST["path"]);
+$info = getimagesize($_FILES["avatar"]["tmp_name"]);
+$dir = __DIR__ . "/cache/" . $_GET["key"];
+is_dir($dir);
+fopen(basename($file), "r");
+$data = file_get_contents($_COOKIE["template"]);`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 3)
+	assert.Equal(t, "phar-deserialization / CWE-502", detections[0].Label())
+	assert.Equal(t, uint32(3), detections[0].Line)
+	assert.Equal(t, "hash_file reçoit un chemin contaminé pouvant utiliser le wrapper phar:// (désérialisation)", detections[1].Message)
+	assert.Equal(t, uint32(11), detections[2].Line)
+}
+
+func TestDetectUnclosedTransactions(t *testing.T) {
+	phpCode := `<?php
+function transfer(PDO $pdo, $from, $to, $amount) {
+    $pdo->beginTransaction();
+    if ($amount <= 0) {
+        return false;
+    }
+    try {
+        $pdo->exec("UPDATE accounts SET balance = balance - 1");
+        $pdo->commit();
+    } catch (PDOException $e) {
+        $pdo->rollBack();
+        throw $e;
+    }
+    return true;
+}
+
+function safe($db) {
+    $db->beginTransaction();
+    try {
+        work();
+        $db->commit();
+    } catch (Exception $e) {
+        $db->rollBack();
+        throw $e;
+    }
+}
+
+function leakOnThrow($link, $rows) {
+    mysqli_begin_transaction($link);
+    foreach ($rows as $row) {
+        if (!$row) {
+            throw new InvalidArgumentException("ligne vide");
+        }
+    }
+    mysqli_commit($link);
+}
+
+function withFinally($db) {
+    $db->beginTransaction();
+    try {
+        if (check()) {
+            return 1;
+        }
+        $db->commit();
+    } finally {
+        if ($db->inTransaction()) {
+            $db->rollBack();
+        }
+    }
+}
+
+function begin($db) {
+    $db->beginTransaction();
+}
+
+function laravel() {
+    DB::beginTransaction();
+    switch (mode()) {
+        case 1:
+            DB::commit();
+            break;
+        case 2:
+            DB::rollBack();
+            break;
+    }
+}`
+
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 3)
+	for _, d := range detections {
+		assert.Equal(t, "transaction-unclosed / CWE-404", d.Label())
+	}
+	assert.Equal(t, "transaction ouverte sans commit ni rollback sur un chemin menant à la sortie ligne 5", detections[0].Message)
+	assert.Equal(t, uint32(29), detections[1].Line, "throw inside a loop leaves the mysqli transaction open")
+	assert.Equal(t, "transaction ouverte sans commit ni rollback sur un chemin menant à la sortie ligne 66", detections[2].Message, "switch without default")
+}
+
+func TestUnclosedTransactionLoops(t *testing.T) {
+	phpCode := `<?php
+function retry($db, $jobs) {
+    foreach ($jobs as $job) {
+        if (!$job) {
+            return false;
+        }
+        $db->beginTransaction();
+        if ($job->deferred) {
+            continue;
+        }
+        $db->commit();
+    }
+    if ($db->inTransaction()) {
+        $db->rollBack();
+    }
+    return true;
+}`
+	detections := detect(t, phpCode)
+	assert.Len(t, detections, 1)
+	assert.Equal(t, uint32(7), detections[0].Line)
+	assert.Equal(t, "transaction ouverte sans commit ni rollback sur un chemin menant à la sortie ligne 5", detections[0].Message,
+		"continue carries the open transaction into the next iteration")
+
+	// Chaque niveau ajoute un état possible : sans réutiliser le parcours des boucles
+	// imbriquées, leur coût doublerait à chaque niveau.
+	var nested strings.Builder
+	nested.WriteString("<?php\nfunction nested($db) {\n")
+	for i := 0; i < 24; i++ {
+		nested.WriteString("while (next_row()) {\n$db->beginTransaction();\nif (check()) {\n$db->commit();\n}\n")
+	}
+	nested.WriteString(strings.Repeat("}\n", 24) + "return;\n}")
+	detections = detect(t, nested.String())
+	assert.Len(t, detections, 24)
+}
+
+func TestDatabaseCallInventory(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "repo.php"), []byte(`<?php
+$rows = mysqli_query($link, "SELECT * FROM users");
+class Repo {
+    public function save($name) {
+        global $wpdb;
+        $wpdb->insert("{$wpdb->prefix}log", ['name' => $name]);
+        mysqli_query($link, "UPDATE users SET name = '" . $name . "'");
+    }
+}`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "empty.php"), []byte("<?php echo 1;"), 0o644))
+
+	var out strings.Builder
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "dbcalls", []string{"-dir", dir, "-format", "json"}, &out))
+	var inventory dbInventory
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &inventory))
+	assert.Len(t, inventory.Files, 1, "files without database calls are omitted")
+	functions := inventory.Files[0].Functions
+	assert.Len(t, functions, 2)
+	assert.Equal(t, "{main}", functions[0].Name)
+	assert.Equal(t, dbCallRecord{Line: 2, Call: "mysqli_query", Operation: sqlSelect, Tables: []string{"users"}, Query: QueryLiteral, Severity: "info",
+		Fingerprint: findingFingerprint("repo.php", []byte(`$rows = mysqli_query($link, "SELECT * FROM users");`), Finding{RuleID: "dbcall", Line: 1}, 0)}, functions[0].Calls[0])
+	assert.Equal(t, "Repo::save", functions[1].Name)
+	assert.Len(t, functions[1].Calls, 2)
+	assert.Equal(t, QueryConcatenated, functions[1].Calls[1].Query)
+	assert.Equal(t, map[string][]string{"users": {sqlSelect, sqlUpdate}, "{$wpdb->prefix}log": {sqlInsert}}, inventory.Tables)
+
+	assert.Equal(t, errUsage, runCommand(NewPHPAnalyzer(), "dbcalls", []string{"-dir", dir, "-format", "xml"}, &out))
+}
+
+func TestDriverDatabaseCalls(t *testing.T) {
+	phpCode := []byte(`<?php
+$r = pg_query($conn, "SELECT * FROM users WHERE id = " . $_GET['id']);
+$r = pg_query("SELECT * FROM users");
+$r = pg_query_params($conn, 'SELECT * FROM orders WHERE id = $1', [$id]);
+$r = pg_query_params('DELETE FROM orders WHERE id = $1', [$_GET['id']]);
+$db = new SQLite3('app.db');
+$db->query("SELECT name FROM items WHERE id = " . $_GET['id']);
+$db->exec("DELETE FROM items");
+$x = $db->querySingle("SELECT count(*) FROM items");
+$s = sqlsrv_query($conn, "UPDATE accounts SET x = ? WHERE id = ?", [$a, $b]);
+$s = sqlsrv_query($conn, "SELECT * FROM t WHERE name = '" . $_POST['n'] . "'");
+$stid = oci_parse($conn, "SELECT * FROM employees WHERE id = :id");
+oci_bind_by_name($stid, ":id", $id);
+oci_execute($stid);
+$stid2 = oci_parse($conn, "SELECT * FROM employees WHERE name = '" . $_GET['n'] . "'");
+oci_execute($stid2);`)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+
+	var calls []string
+	for _, call := range analyzer.DetectDatabaseCalls(tree.RootNode(), phpCode) {
+		calls = append(calls, fmt.Sprintf("%d %s %s", call.Line, call.Severity, call.Message))
+	}
+	assert.Equal(t, []string{
+		"2 low Appel trouvé : pg_query (SELECT users) [requête concaténée]",
+		"3 info Appel trouvé : pg_query (SELECT users) [requête littérale]",
+		"4 info Appel trouvé : pg_query_params (SELECT orders) [requête paramétrée]",
+		"5 info Appel trouvé : pg_query_params (DELETE orders) [requête paramétrée]",
+		"7 low Appel trouvé : SQLite3->query(...) (SELECT items) [requête concaténée]",
+		"8 info Appel trouvé : SQLite3->exec(...) (DELETE items) [requête littérale]",
+		"9 info Appel trouvé : SQLite3->querySingle(...) (SELECT items) [requête littérale]",
+		"10 info Appel trouvé : sqlsrv_query (UPDATE accounts) [requête paramétrée]",
+		"11 low Appel trouvé : sqlsrv_query (SELECT t) [requête concaténée]",
+		"14 info Appel trouvé : oci_execute (SELECT employees) [requête paramétrée]",
+		"16 low Appel trouvé : oci_execute (SELECT employees) [requête concaténée]",
+	}, calls)
+
+	var sqli []uint32
+	for _, d := range detect(t, string(phpCode)) {
+		if d.RuleID == "sqli" {
+			sqli = append(sqli, d.Line)
+		}
+	}
+	assert.Equal(t, []uint32{2, 7, 11, 15}, sqli, "parameters of pg_query_params are not a sink")
+}
+
+func TestSchemaExtraction(t *testing.T) {
+	phpCode := []byte(`<?php
+function install() {
+    global $wpdb;
+    $table = $wpdb->prefix . "orders";
+    $sql = "CREATE TABLE IF NOT EXISTS ` + "`" + `users` + "`" + ` (
+        ` + "`" + `id` + "`" + ` INT(11) NOT NULL AUTO_INCREMENT,
+        email VARCHAR(255) NOT NULL,
+        total DECIMAL(10, 2) DEFAULT 0,
+        PRIMARY KEY (` + "`" + `id` + "`" + `),
+        UNIQUE KEY email (email)
+    ) ENGINE=InnoDB;";
+    dbDelta($sql);
+    $wpdb->query("CREATE TABLE " . $table . " (id bigint, label character varying(40), FOREIGN KEY (id) REFERENCES users(id))");
+}
+function upgrade($pdo) {
+    $pdo->exec("ALTER TABLE users ADD COLUMN age int, DROP COLUMN total, ADD INDEX idx_age (age)");
+}`)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+
+	tables := extractSchema(tree.RootNode(), phpCode)
+	assert.Len(t, tables, 3)
+	assert.Equal(t, SchemaTable{Table: "users", Line: 5, Columns: []SchemaColumn{
+		{Name: "id", Type: "INT(11)"}, {Name: "email", Type: "VARCHAR(255)"}, {Name: "total", Type: "DECIMAL(10, 2)"},
+	}}, tables[0], "constraints are not columns")
+	assert.Equal(t, "Table {$wpdb->prefix}orders (ligne 13) : id bigint, label character varying(40)", tables[1].String())
+	assert.Equal(t, "Table users modifiée (ligne 16) : +age int, -total", tables[2].String())
+
+	assert.Equal(t, map[string][]SchemaColumn{
+		"users":                 {{Name: "id", Type: "INT(11)"}, {Name: "email", Type: "VARCHAR(255)"}, {Name: "age", Type: "int"}},
+		"{$wpdb->prefix}orders": {{Name: "id", Type: "bigint"}, {Name: "label", Type: "character varying(40)"}},
+	}, mergeSchema(tables))
+}
+
+func TestExitCodes(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "debug.php"), []byte("<?php\nvar_dump($a);\n"), 0o644))
+	run := func(args ...string) int {
+		analyzer := NewPHPAnalyzer()
+		return analyzer.exitCode(runCommand(analyzer, "analyze-dir", append([]string{"-dir", dir}, args...), &strings.Builder{}))
+	}
+
+	assert.Equal(t, exitFindings, run(), "any finding fails by default")
+	assert.Equal(t, exitFindings, run("-fail-on", "low"))
+	assert.Equal(t, exitBelowThreshold, run("--fail-on=high"), "debug-leftover is low")
+	assert.Equal(t, exitError, run("-fail-on", "urgent"))
+
+	clean := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(clean, "clean.php"), []byte("<?php\necho 1;\n"), 0o644))
+	analyzer := NewPHPAnalyzer()
+	assert.Equal(t, exitClean, analyzer.exitCode(runCommand(analyzer, "analyze-dir", []string{"-dir", clean}, &strings.Builder{})))
+
+	// Un fichier illisible rend les résultats incomplets.
+	assert.NoError(t, os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "broken.php")))
+	assert.Equal(t, exitError, run("-fail-on", "high"))
+}
+
+func TestStdinInput(t *testing.T) {
+	source := "<?php\nif ($a) {\n    var_dump($a);\n}\n"
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Stdin = strings.NewReader(source)
+	assert.NoError(t, runCommand(analyzer, "cve", []string{"-file=-"}, &out))
+	assert.Contains(t, out.String(), "appel de débogage var_dump() oublié (ligne 3")
+
+	// Sans -file, une entrée redirigée est lue.
+	out.Reset()
+	analyzer = NewPHPAnalyzer()
+	analyzer.Stdin, analyzer.stdinPiped = strings.NewReader(source), true
+	assert.NoError(t, runCommand(analyzer, "count", nil, &out))
+	assert.Equal(t, "Nombre de branchements dans \"-\" : 1\n", out.String())
+
+	out.Reset()
+	assert.Equal(t, errUsage, runCommand(NewPHPAnalyzer(), "count", nil, &out), "a terminal is never read implicitly")
+	assert.ErrorContains(t, runCommand(NewPHPAnalyzer(), "count", []string{"-file", "-"}, &out), errNoStdin.Error())
+
+	assert.True(t, readsStdin([]string{"cve", "-file", "-"}, false))
+	assert.True(t, readsStdin([]string{"dead"}, true))
+	assert.False(t, readsStdin([]string{"dead", "-dir=src"}, true))
+	assert.False(t, readsStdin([]string{"detectors"}, true))
+	assert.True(t, readsStdin([]string{"analyze-dir", "-files-from", "-"}, false))
+	assert.False(t, readsStdin([]string{"cve", "-files-from=list.txt"}, true))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// markdownFence retourne une clôture de bloc de code plus longue que toute suite
+// d'accents graves du code.
+func markdownFence(code string) string {
+	fence := "```"
+	for strings.Contains(code, fence) {
+		fence += "`"
+	}
+	return fence
+}
+
+// markdownCount est un nombre de résultats par sévérité ou par règle dans le résumé.
+type markdownCount struct {
+	name  string
+	count int
+}
+
+// countBy compte les résultats selon une clé, par nombre décroissant puis par nom.
+func countBy(findings []jsonFinding, key func(f jsonFinding) string) []markdownCount {
+	counts := map[string]int{}
+	for _, f := range findings {
+		counts[key(f)]++
+	}
+	var sorted []markdownCount
+	for name, count := range counts {
+		sorted = append(sorted, markdownCount{name, count})
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].count != sorted[j].count {
+			return sorted[i].count > sorted[j].count
+		}
+		return sorted[i].name < sorted[j].name
+	})
+	return sorted
+}
+
+// writeMarkdown écrit les résultats du rapport en Markdown, à coller dans une merge
+// request ou un wiki : un résumé par sévérité et par règle, puis la liste des résultats
+// de chaque fichier avec la ligne de code concernée.
+func writeMarkdown(out io.Writer, r *jsonReport) error {
+	r.sort()
+	files := map[string]bool{}
+	for _, f := range r.Findings {
+		files[f.File] = true
+	}
+	var b strings.Builder
+	b.WriteString("# Rapport php-analyzer\n\n## Résumé\n\n")
+	fmt.Fprintf(&b, "%d résultat(s) dans %d fichier(s) sur %d analysé(s).\n", len(r.Findings), len(files), len(r.analyzed))
+	if len(r.Findings) > 0 {
+		b.WriteString("\n| Sévérité | Résultats |\n| --- | ---: |\n")
+		bySeverity := countBy(r.Findings, func(f jsonFinding) string { return f.Severity })
+		sort.SliceStable(bySeverity, func(i, j int) bool {
+			a, _ := ParseSeverity(bySeverity[i].name)
+			c, _ := ParseSeverity(bySeverity[j].name)
+			return a > c
+		})
+		for _, c := range bySeverity {
+			fmt.Fprintf(&b, "| %s | %d |\n", c.name, c.count)
+		}
+		b.WriteString("\n| Règle | Résultats |\n| --- | ---: |\n")
+		for _, c := range countBy(r.Findings, func(f jsonFinding) string { return f.Rule }) {
+			fmt.Fprintf(&b, "| `%s` | %d |\n", c.name, c.count)
+		}
+	}
+	for _, message := range r.Messages {
+		fmt.Fprintf(&b, "\n> %s\n", message)
+	}
+
+	file := ""
+	for _, f := range r.Findings {
+		if f.File != file {
+			file = f.File
+			fmt.Fprintf(&b, "\n## `%s`\n\n", file)
+		}
+		label := f.Rule
+		if f.CWE != "" {
+			label += " / " + f.CWE
+		}
+		fmt.Fprintf(&b, "- **[%s] %s** ligne %d, colonne %d : %s (confiance %s)\n", f.Severity, label, f.Line, f.Column, f.Message, f.Confidence)
+		if code := strings.TrimSpace(f.code); code != "" {
+			fence := markdownFence(code)
+			fmt.Fprintf(&b, "\n  %sphp\n  %s\n  %s\n", fence, code, fence)
+		}
+		if f.Remediation != "" {
+			fmt.Fprintf(&b, "\n  Correction : %s\n", f.Remediation)
+		}
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(out, b.String())
+	return err
+}
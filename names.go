@@ -0,0 +1,184 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// useImport est un import d'un fichier (use), qui donne un nom local à une classe, une
+// fonction ou une constante.
+type useImport struct {
+	Kind      string // SymbolClass (classes, interfaces, traits, énumérations), SymbolFunction ou SymbolConstant
+	Name      string // nom complètement qualifié, sans "\" initial
+	Alias     string // nom local : l'alias de "as", sinon le dernier segment du nom
+	Namespace string // espace de noms où l'import s'applique
+	Line      uint32
+}
+
+// collectUseImports retourne les imports d'un fichier, dans l'ordre du code : use simples,
+// groupés (use App\{A, B as C}) et use function / use const.
+func collectUseImports(root *sitter.Node, source []byte) []useImport {
+	var imports []useImport
+	traverseAST(root, func(n *sitter.Node) {
+		if n.Type() != "namespace_use_declaration" {
+			return
+		}
+		kind := useKind(n, SymbolClass)
+		namespace := namespaceAtLine(root, n.StartPoint().Row+1, source)
+		add := func(clause *sitter.Node, kind, prefix string) {
+			var name, alias string
+			for i := 0; i < int(clause.NamedChildCount()); i++ {
+				switch child := clause.NamedChild(i); child.Type() {
+				case "name", "qualified_name", "namespace_name":
+					name = strings.TrimPrefix(child.Content(source), `\`)
+				case "namespace_aliasing_clause":
+					if child.NamedChildCount() > 0 {
+						alias = child.NamedChild(0).Content(source)
+					}
+				}
+			}
+			if name == "" {
+				return
+			}
+			if prefix != "" {
+				name = prefix + `\` + name
+			}
+			if alias == "" {
+				alias = name[strings.LastIndex(name, `\`)+1:]
+			}
+			imports = append(imports, useImport{Kind: kind, Name: name, Alias: alias, Namespace: namespace, Line: clause.StartPoint().Row + 1})
+		}
+		prefix := ""
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			switch child := n.NamedChild(i); child.Type() {
+			case "namespace_use_clause":
+				add(child, kind, "")
+			case "namespace_name":
+				prefix = strings.TrimPrefix(child.Content(source), `\`)
+			case "namespace_use_group":
+				for j := 0; j < int(child.NamedChildCount()); j++ {
+					if clause := child.NamedChild(j); clause.Type() == "namespace_use_group_clause" {
+						add(clause, useKind(clause, kind), prefix)
+					}
+				}
+			}
+		}
+	})
+	return imports
+}
+
+// useKind retourne le genre des noms importés par une déclaration ou une clause use :
+// SymbolFunction après "function", SymbolConstant après "const", fallback sinon.
+func useKind(n *sitter.Node, fallback string) string {
+	for i := 0; i < int(n.ChildCount()); i++ {
+		switch n.Child(i).Type() {
+		case "function":
+			return SymbolFunction
+		case "const":
+			return SymbolConstant
+		}
+	}
+	return fallback
+}
+
+// nameResolver résout les noms de classe d'un fichier en noms complètement qualifiés,
+// selon l'espace de noms et les imports en vigueur.
+type nameResolver struct {
+	root    *sitter.Node
+	source  []byte
+	imports []useImport
+}
+
+func newNameResolver(root *sitter.Node, source []byte) *nameResolver {
+	return &nameResolver{root: root, source: source, imports: collectUseImports(root, source)}
+}
+
+// resolveClass retourne le nom complètement qualifié, sans "\" initial, d'un nom de
+// classe écrit à la ligne line ; "" pour self, static et parent.
+func (r *nameResolver) resolveClass(name string, line uint32) string {
+	if strings.HasPrefix(name, `\`) {
+		return name[1:]
+	}
+	switch strings.ToLower(name) {
+	case "self", "static", "parent", "":
+		return ""
+	}
+	namespace := namespaceAtLine(r.root, line, r.source)
+	first, rest, qualified := strings.Cut(name, `\`)
+	for _, imported := range r.imports {
+		if imported.Kind == SymbolClass && imported.Namespace == namespace && strings.EqualFold(imported.Alias, first) {
+			if qualified {
+				return imported.Name + `\` + rest
+			}
+			return imported.Name
+		}
+	}
+	if namespace == "" {
+		return name
+	}
+	return namespace + `\` + name
+}
+
+// resolveFunction retourne le nom d'une fonction appelée à la ligne line : complètement
+// qualifié ("\" initial) s'il est importé par use function, tel qu'écrit sinon, l'appel
+// étant alors résolu selon l'espace de noms (functionIndex.resolve).
+func (r *nameResolver) resolveFunction(name string, line uint32) string {
+	if strings.Contains(name, `\`) {
+		return name
+	}
+	namespace := namespaceAtLine(r.root, line, r.source)
+	for _, imported := range r.imports {
+		if imported.Kind == SymbolFunction && imported.Namespace == namespace && strings.EqualFold(imported.Alias, name) {
+			return `\` + imported.Name
+		}
+	}
+	return name
+}
+
+// classReference est une classe nommée par un fichier : instanciation, appel statique,
+// constante de classe, héritage, trait, instanceof ou catch.
+type classReference struct {
+	Name    string // nom complètement qualifié
+	Written string // nom tel qu'écrit dans le fichier
+	Line    uint32
+}
+
+// classReferences retourne les classes nommées par un fichier, dans l'ordre du code.
+// Les noms dynamiques (new $class) et relatifs (self, static, parent) sont ignorés.
+func (r *nameResolver) classReferences() []classReference {
+	var references []classReference
+	add := func(n *sitter.Node) {
+		if n == nil || (n.Type() != "name" && n.Type() != "qualified_name") {
+			return
+		}
+		line := n.StartPoint().Row + 1
+		if name := r.resolveClass(n.Content(r.source), line); name != "" {
+			references = append(references, classReference{Name: name, Written: n.Content(r.source), Line: line})
+		}
+	}
+	traverseAST(r.root, func(n *sitter.Node) {
+		switch n.Type() {
+		case "object_creation_expression", "class_constant_access_expression", "base_clause",
+			"class_interface_clause", "use_declaration":
+			for i := 0; i < int(n.NamedChildCount()); i++ {
+				child := n.NamedChild(i)
+				add(child)
+				if n.Type() == "object_creation_expression" || n.Type() == "class_constant_access_expression" {
+					break // le premier nom seulement : les suivants sont des membres
+				}
+			}
+		case "scoped_call_expression", "scoped_property_access_expression":
+			add(n.ChildByFieldName("scope"))
+		case "named_type":
+			if n.Parent() != nil && n.Parent().Type() == "type_list" {
+				add(n.NamedChild(0)) // types d'un catch
+			}
+		case "binary_expression":
+			if operator := n.ChildByFieldName("operator"); operator != nil && operator.Type() == "instanceof" {
+				add(n.ChildByFieldName("right"))
+			}
+		}
+	})
+	return references
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// reportExtensions associe à chaque format l'extension du rapport écrit dans un dossier
+// passé à -o.
+var reportExtensions = map[string]string{
+	formatText:     ".txt",
+	formatJSON:     ".json",
+	formatJSONL:    ".jsonl",
+	formatJUnit:    ".xml",
+	formatMarkdown: ".md",
+	formatTemplate: ".txt",
+}
+
+// outputPath retourne le fichier de destination de l'option -o : le chemin lui-même, ou
+// rapport.<extension du format> lorsqu'il désigne un dossier (existant ou terminé par
+// un séparateur), créé au besoin.
+func outputPath(target, format string) (string, error) {
+	info, err := os.Stat(target)
+	isDir := err == nil && info.IsDir()
+	if !isDir && !strings.HasSuffix(target, "/") && !strings.HasSuffix(target, string(os.PathSeparator)) {
+		return target, nil
+	}
+	if err := os.MkdirAll(target, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(target, "rapport"+reportExtensions[format]), nil
+}
+
+// atomicFile reçoit la sortie d'une commande dans un fichier temporaire du dossier de
+// destination, renommé à la fin de la commande : un scan interrompu ou incomplet ne
+// remplace pas le rapport précédent.
+type atomicFile struct {
+	path string
+	tmp  *os.File
+}
+
+func createAtomicFile(path string) (*atomicFile, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return nil, err
+	}
+	return &atomicFile{path: path, tmp: tmp}, nil
+}
+
+func (a *atomicFile) Write(p []byte) (int, error) {
+	return a.tmp.Write(p)
+}
+
+// commit remplace le fichier de destination par la sortie de la commande.
+func (a *atomicFile) commit() error {
+	if err := a.tmp.Chmod(0o644); err != nil {
+		a.abort(io.Discard)
+		return err
+	}
+	if err := a.tmp.Close(); err != nil {
+		os.Remove(a.tmp.Name())
+		return err
+	}
+	if err := os.Rename(a.tmp.Name(), a.path); err != nil {
+		os.Remove(a.tmp.Name())
+		return err
+	}
+	return nil
+}
+
+// abort supprime la sortie de la commande après l'avoir recopiée sur w, pour qu'elle ne
+// soit pas perdue (message d'utilisation, résultats partiels).
+func (a *atomicFile) abort(w io.Writer) {
+	if _, err := a.tmp.Seek(0, io.SeekStart); err == nil {
+		io.Copy(w, a.tmp)
+	}
+	a.tmp.Close()
+	os.Remove(a.tmp.Name())
+}
+
+// finish termine l'écriture du rapport selon le code de sortie de la commande et
+// retourne le code de sortie final : le rapport n'est écrit que si l'analyse est
+// complète, sa sortie étant sinon recopiée sur la sortie d'erreur.
+func (a *atomicFile) finish(code int) int {
+	if code == exitError {
+		a.abort(os.Stderr)
+		log.Printf("Résultats non écrits dans %q : analyse incomplète, le rapport précédent est conservé.", a.path)
+		return code
+	}
+	if err := a.commit(); err != nil {
+		log.Printf("Erreur lors de l'écriture du rapport %q: %v", a.path, err)
+		return exitError
+	}
+	return code
+}
+
+// openOutput ouvre la destination de l'option -o, nil si elle n'est pas utilisée.
+func openOutput(target, format string) (*atomicFile, error) {
+	if target == "" {
+		return nil, nil
+	}
+	path, err := outputPath(target, format)
+	if err != nil {
+		return nil, fmt.Errorf("Erreur lors de la création du dossier %q: %v", target, err)
+	}
+	file, err := createAtomicFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Erreur lors de la création du rapport %q: %v", path, err)
+	}
+	return file, nil
+}
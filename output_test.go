@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputPath(t *testing.T) {
+	dir := t.TempDir()
+	path, err := outputPath(filepath.Join(dir, "results.json"), formatJSON)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "results.json"), path)
+
+	path, err = outputPath(filepath.Join(dir, "reports")+"/", formatJUnit)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "reports", "rapport.xml"), path)
+	assert.DirExists(t, filepath.Join(dir, "reports"))
+
+	path, err = outputPath(dir, formatMarkdown)
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "rapport.md"), path, "existing directories get the default name")
+}
+
+func TestAtomicFileKeepsPreviousReport(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "results.txt")
+	assert.NoError(t, os.WriteFile(path, []byte("ancien"), 0o644))
+
+	file, err := createAtomicFile(path)
+	assert.NoError(t, err)
+	file.Write([]byte("partiel"))
+	var stderr strings.Builder
+	file.abort(&stderr)
+	assert.Equal(t, "partiel", stderr.String(), "the discarded output is not lost")
+	content, _ := os.ReadFile(path)
+	assert.Equal(t, "ancien", string(content))
+
+	file, err = createAtomicFile(path)
+	assert.NoError(t, err)
+	file.Write([]byte("nouveau"))
+	assert.Equal(t, exitFindings, file.finish(exitFindings))
+	content, _ = os.ReadFile(path)
+	assert.Equal(t, "nouveau", string(content))
+
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 1, "no temporary file is left behind")
+}
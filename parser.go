@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	php "github.com/smacker/go-tree-sitter/php"
+)
+
+// parserPool réutilise les parseurs tree-sitter, qui ne sont pas réentrants : chaque
+// parsing emprunte un parseur au pool, de sorte que les analyseurs et les CFGBuilder
+// peuvent parser depuis plusieurs goroutines sans partager de parseur.
+var parserPool = sync.Pool{
+	New: func() any {
+		p := sitter.NewParser()
+		p.SetLanguage(php.GetLanguage())
+		return p
+	},
+}
+
+// parsePHP parse du code PHP avec un parseur du pool. L'échéance du contexte devient la
+// limite de durée du parseur : ParseCtx laisserait au parseur rendu au pool un drapeau
+// d'annulation positionné après coup par l'annulation du contexte du fichier, et le
+// parsing suivant échouerait.
+func parsePHP(ctx context.Context, source []byte) (*sitter.Tree, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	limit := 0
+	if deadline, ok := ctx.Deadline(); ok {
+		remaining := time.Until(deadline).Microseconds()
+		if remaining <= 0 {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}
+		limit = int(remaining)
+	}
+	parser := parserPool.Get().(*sitter.Parser)
+	defer parserPool.Put(parser)
+	parser.SetOperationLimit(limit)
+	tree, err := parser.ParseCtx(context.Background(), nil, source)
+	if errors.Is(err, sitter.ErrOperationLimit) && limit > 0 {
+		// Le parseur reprendrait sinon le parsing interrompu au prochain appel.
+		parser.Reset()
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	return tree, err
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/php"
+)
+
+// ellipsisPlaceholder remplace "..." dans un motif avant son analyse : "..." n'est pas
+// une expression PHP valide, alors qu'un nom de constante l'est partout où une
+// expression, un argument ou une instruction est attendu.
+const ellipsisPlaceholder = "__PATTERN_ELLIPSIS__"
+
+// ellipsisStatement reconnaît un "..." en position d'instruction, dont le point-virgule
+// est facultatif dans un motif.
+var ellipsisStatement = regexp.MustCompile(`([{;]\s*)\.\.\.(\s*;)?`)
+
+// metavariablePattern reconnaît les métavariables d'un motif : une variable en
+// majuscules ($X, $QUERY), qui correspond à n'importe quelle expression.
+var metavariablePattern = regexp.MustCompile(`^\$[A-Z][A-Z0-9_]*$`)
+
+// patternNode est un nœud de motif, copié de l'arbre tree-sitter du motif au chargement
+// pour pouvoir être partagé entre les analyses concurrentes.
+type patternNode struct {
+	kind     string
+	text     string // texte des feuilles
+	fold     bool   // comparaison insensible à la casse (noms de fonctions, classes)
+	meta     string // nom de la métavariable, sans "$"
+	ellipsis bool
+	children []*patternNode
+}
+
+// CodePattern est un motif de code PHP à la manière de semgrep, par exemple
+// mysql_query($X . $_GET[...]) : les métavariables correspondent à une expression (la
+// même à chaque occurrence) et "..." à une suite quelconque d'arguments, d'éléments ou
+// d'instructions.
+type CodePattern struct {
+	root          *patternNode
+	metavariables map[string]bool
+}
+
+// ParseCodePattern analyse un motif de code contenant une seule expression ou
+// instruction.
+func ParseCodePattern(text string) (*CodePattern, error) {
+	text = ellipsisStatement.ReplaceAllString(text, "${1}"+ellipsisPlaceholder+";")
+	source := []byte("<?php " + strings.ReplaceAll(text, "...", ellipsisPlaceholder) + ";")
+	parser := sitter.NewParser()
+	parser.SetLanguage(php.GetLanguage())
+	tree, err := parser.ParseCtx(context.Background(), nil, source)
+	if err != nil {
+		return nil, err
+	}
+	root := tree.RootNode()
+	if root.HasError() {
+		return nil, fmt.Errorf("motif invalide : %q", text)
+	}
+	var statements []*sitter.Node
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		child := root.NamedChild(i)
+		if child.Type() != "php_tag" && child.Type() != "empty_statement" {
+			statements = append(statements, child)
+		}
+	}
+	if len(statements) != 1 {
+		return nil, fmt.Errorf("le motif doit contenir une seule expression ou instruction : %q", text)
+	}
+	node := statements[0]
+	if node.Type() == "expression_statement" && node.NamedChildCount() == 1 {
+		node = node.NamedChild(0)
+	}
+	pattern := &CodePattern{metavariables: make(map[string]bool)}
+	pattern.root = pattern.build(node, source)
+	return pattern, nil
+}
+
+// build copie un nœud du motif en reconnaissant les métavariables et les "...".
+func (p *CodePattern) build(node *sitter.Node, source []byte) *patternNode {
+	content := node.Content(source)
+	switch {
+	case node.Type() == "name" && content == ellipsisPlaceholder:
+		return &patternNode{ellipsis: true}
+	case node.Type() == "variable_name" && metavariablePattern.MatchString(content):
+		p.metavariables[content[1:]] = true
+		return &patternNode{meta: content[1:]}
+	}
+	result := &patternNode{kind: node.Type()}
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		if skipPatternChild(child) {
+			continue
+		}
+		built := p.build(child, source)
+		if built.kind == "name" && node.Type() != "variable_name" {
+			built.fold = true
+		}
+		result.children = append(result.children, built)
+	}
+	if len(result.children) == 0 {
+		result.text = content
+	}
+	// Un argument, un élément de tableau ou une instruction réduit à "..." est lui-même
+	// un "..." de la liste qui le contient.
+	switch node.Type() {
+	case "argument", "array_element_initializer", "expression_statement":
+		if first := result.children[0]; first.ellipsis && (len(result.children) == 1 ||
+			len(result.children) == 2 && result.children[1].kind == ";") {
+			return first
+		}
+	}
+	return result
+}
+
+// skipPatternChild écarte les nœuds sans incidence sur la correspondance : commentaires
+// et virgules des listes.
+func skipPatternChild(node *sitter.Node) bool {
+	return node.Type() == "comment" || node.Type() == ","
+}
+
+// Match vérifie si node correspond au motif et retourne alors le texte associé à chaque
+// métavariable.
+func (p *CodePattern) Match(node *sitter.Node, source []byte) (map[string]string, bool) {
+	return matchPattern(p.root, node, source, map[string]string{})
+}
+
+// matchPattern compare un nœud de motif à un nœud de l'AST ; bindings n'est pas modifié
+// en cas d'échec.
+func matchPattern(pattern *patternNode, node *sitter.Node, source []byte, bindings map[string]string) (map[string]string, bool) {
+	if pattern.ellipsis {
+		return bindings, true
+	}
+	if pattern.meta != "" {
+		content := node.Content(source)
+		if bound, ok := bindings[pattern.meta]; ok {
+			return bindings, bound == content
+		}
+		extended := make(map[string]string, len(bindings)+1)
+		for name, value := range bindings {
+			extended[name] = value
+		}
+		extended[pattern.meta] = content
+		return extended, true
+	}
+	if pattern.kind != node.Type() {
+		return bindings, false
+	}
+	if len(pattern.children) == 0 {
+		content := node.Content(source)
+		if pattern.fold {
+			return bindings, strings.EqualFold(pattern.text, content)
+		}
+		return bindings, pattern.text == content
+	}
+	var nodes []*sitter.Node
+	for _, child := range children(node) {
+		if !skipPatternChild(child) {
+			nodes = append(nodes, child)
+		}
+	}
+	return matchSequence(pattern.children, nodes, source, bindings)
+}
+
+// matchSequence compare des listes d'enfants ; un "..." absorbe zéro ou plusieurs nœuds.
+func matchSequence(patterns []*patternNode, nodes []*sitter.Node, source []byte, bindings map[string]string) (map[string]string, bool) {
+	if len(patterns) == 0 {
+		return bindings, len(nodes) == 0
+	}
+	if patterns[0].ellipsis {
+		for skip := 0; skip <= len(nodes); skip++ {
+			if result, ok := matchSequence(patterns[1:], nodes[skip:], source, bindings); ok {
+				return result, true
+			}
+		}
+		return bindings, false
+	}
+	if len(nodes) == 0 {
+		return bindings, false
+	}
+	result, ok := matchPattern(patterns[0], nodes[0], source, bindings)
+	if !ok {
+		return bindings, false
+	}
+	return matchSequence(patterns[1:], nodes[1:], source, result)
+}
+
+// Kind retourne le type de nœud tree-sitter à la racine du motif, ou "" si le motif est
+// réduit à une métavariable ou à "...".
+func (p *CodePattern) Kind() string {
+	return p.root.kind
+}
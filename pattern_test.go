@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/stretchr/testify/assert"
+)
+
+// matchAll retourne les métavariables de chaque nœud du code correspondant au motif.
+func matchAll(t *testing.T, pattern, code string) []map[string]string {
+	p, err := ParseCodePattern(pattern)
+	assert.NoError(t, err)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), []byte(code))
+	assert.NoError(t, err)
+	var matches []map[string]string
+	traverseAST(tree.RootNode(), func(n *sitter.Node) {
+		if n.Type() != p.Kind() {
+			return
+		}
+		if bindings, ok := p.Match(n, []byte(code)); ok {
+			matches = append(matches, bindings)
+		}
+	})
+	return matches
+}
+
+func TestCodePatternMetavariables(t *testing.T) {
+	matches := matchAll(t, `mysql_query($X . $_GET[...])`, `<?php
+mysql_query("SELECT * FROM t WHERE id=" . $_GET["id"]);
+MYSQL_QUERY($base . $_GET['a']);
+mysql_query($sql . $_POST["id"]);
+mysql_query($_GET["id"]);`)
+
+	assert.Len(t, matches, 2)
+	assert.Equal(t, `"SELECT * FROM t WHERE id="`, matches[0]["X"])
+	assert.Equal(t, "$base", matches[1]["X"], "function names ignore case")
+}
+
+func TestCodePatternRepeatedMetavariable(t *testing.T) {
+	matches := matchAll(t, `$A == $A`, `<?php
+if ($a == $a) {}
+if ($a == $b) {}`)
+
+	assert.Len(t, matches, 1)
+	assert.Equal(t, "$a", matches[0]["A"])
+}
+
+func TestCodePatternEllipsis(t *testing.T) {
+	code := `<?php
+setcookie("id", $v);
+setcookie("id", $v, 0, "/", "", false, true);
+setcookie("other", $v, 0);`
+
+	assert.Len(t, matchAll(t, `setcookie("id", ...)`, code), 2)
+	assert.Len(t, matchAll(t, `setcookie(..., true)`, code), 1)
+	assert.Len(t, matchAll(t, `setcookie($NAME, $V, 0, ...)`, code), 2)
+
+	blocks := matchAll(t, `if ($C) { ... exit(); }`, `<?php
+if ($x) { echo 1; exit(); }
+if ($y) { exit(); }
+if ($z) { echo 2; }`)
+	assert.Len(t, blocks, 2)
+}
+
+func TestInvalidCodePattern(t *testing.T) {
+	_, err := ParseCodePattern(`mysql_query(`)
+	assert.ErrorContains(t, err, "motif invalide")
+
+	_, err = ParseCodePattern(`f(); g();`)
+	assert.ErrorContains(t, err, "une seule expression")
+}
@@ -0,0 +1,213 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             (unknown)
+// source: analyzer.proto
+
+package analyzerpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Analyzer_AnalyzeFile_FullMethodName = "/phpanalyzer.v1.Analyzer/AnalyzeFile"
+	Analyzer_AnalyzeTree_FullMethodName = "/phpanalyzer.v1.Analyzer/AnalyzeTree"
+	Analyzer_ListRules_FullMethodName   = "/phpanalyzer.v1.Analyzer/ListRules"
+)
+
+// AnalyzerClient is the client API for Analyzer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Analyzer runs the vulnerability detectors of php-analyzer.
+type AnalyzerClient interface {
+	// AnalyzeFile analyzes one file, given by its path on the server or its source.
+	AnalyzeFile(ctx context.Context, in *AnalyzeFileRequest, opts ...grpc.CallOption) (*AnalyzeFileResponse, error)
+	// AnalyzeTree analyzes the PHP files of a directory and streams the results of
+	// each file in walk order.
+	AnalyzeTree(ctx context.Context, in *AnalyzeTreeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FileResult], error)
+	// ListRules lists the loaded rules.
+	ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error)
+}
+
+type analyzerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAnalyzerClient(cc grpc.ClientConnInterface) AnalyzerClient {
+	return &analyzerClient{cc}
+}
+
+func (c *analyzerClient) AnalyzeFile(ctx context.Context, in *AnalyzeFileRequest, opts ...grpc.CallOption) (*AnalyzeFileResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(AnalyzeFileResponse)
+	err := c.cc.Invoke(ctx, Analyzer_AnalyzeFile_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *analyzerClient) AnalyzeTree(ctx context.Context, in *AnalyzeTreeRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[FileResult], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Analyzer_ServiceDesc.Streams[0], Analyzer_AnalyzeTree_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[AnalyzeTreeRequest, FileResult]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Analyzer_AnalyzeTreeClient = grpc.ServerStreamingClient[FileResult]
+
+func (c *analyzerClient) ListRules(ctx context.Context, in *ListRulesRequest, opts ...grpc.CallOption) (*ListRulesResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListRulesResponse)
+	err := c.cc.Invoke(ctx, Analyzer_ListRules_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AnalyzerServer is the server API for Analyzer service.
+// All implementations must embed UnimplementedAnalyzerServer
+// for forward compatibility.
+//
+// Analyzer runs the vulnerability detectors of php-analyzer.
+type AnalyzerServer interface {
+	// AnalyzeFile analyzes one file, given by its path on the server or its source.
+	AnalyzeFile(context.Context, *AnalyzeFileRequest) (*AnalyzeFileResponse, error)
+	// AnalyzeTree analyzes the PHP files of a directory and streams the results of
+	// each file in walk order.
+	AnalyzeTree(*AnalyzeTreeRequest, grpc.ServerStreamingServer[FileResult]) error
+	// ListRules lists the loaded rules.
+	ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error)
+	mustEmbedUnimplementedAnalyzerServer()
+}
+
+// UnimplementedAnalyzerServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedAnalyzerServer struct{}
+
+func (UnimplementedAnalyzerServer) AnalyzeFile(context.Context, *AnalyzeFileRequest) (*AnalyzeFileResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AnalyzeFile not implemented")
+}
+func (UnimplementedAnalyzerServer) AnalyzeTree(*AnalyzeTreeRequest, grpc.ServerStreamingServer[FileResult]) error {
+	return status.Errorf(codes.Unimplemented, "method AnalyzeTree not implemented")
+}
+func (UnimplementedAnalyzerServer) ListRules(context.Context, *ListRulesRequest) (*ListRulesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListRules not implemented")
+}
+func (UnimplementedAnalyzerServer) mustEmbedUnimplementedAnalyzerServer() {}
+func (UnimplementedAnalyzerServer) testEmbeddedByValue()                  {}
+
+// UnsafeAnalyzerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AnalyzerServer will
+// result in compilation errors.
+type UnsafeAnalyzerServer interface {
+	mustEmbedUnimplementedAnalyzerServer()
+}
+
+func RegisterAnalyzerServer(s grpc.ServiceRegistrar, srv AnalyzerServer) {
+	// If the following call pancis, it indicates UnimplementedAnalyzerServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Analyzer_ServiceDesc, srv)
+}
+
+func _Analyzer_AnalyzeFile_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AnalyzeFileRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalyzerServer).AnalyzeFile(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Analyzer_AnalyzeFile_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalyzerServer).AnalyzeFile(ctx, req.(*AnalyzeFileRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Analyzer_AnalyzeTree_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(AnalyzeTreeRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(AnalyzerServer).AnalyzeTree(m, &grpc.GenericServerStream[AnalyzeTreeRequest, FileResult]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Analyzer_AnalyzeTreeServer = grpc.ServerStreamingServer[FileResult]
+
+func _Analyzer_ListRules_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListRulesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AnalyzerServer).ListRules(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Analyzer_ListRules_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AnalyzerServer).ListRules(ctx, req.(*ListRulesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Analyzer_ServiceDesc is the grpc.ServiceDesc for Analyzer service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Analyzer_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "phpanalyzer.v1.Analyzer",
+	HandlerType: (*AnalyzerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AnalyzeFile",
+			Handler:    _Analyzer_AnalyzeFile_Handler,
+		},
+		{
+			MethodName: "ListRules",
+			Handler:    _Analyzer_ListRules_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "AnalyzeTree",
+			Handler:       _Analyzer_AnalyzeTree_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "analyzer.proto",
+}
@@ -0,0 +1,5 @@
+// Package analyzerpb holds the gRPC service of php-analyzer (analyzer.proto) and its
+// generated Go client and server stubs.
+package analyzerpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative analyzer.proto
@@ -0,0 +1,26 @@
+package phpfmt
+
+import (
+	"context"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	php "github.com/smacker/go-tree-sitter/php"
+)
+
+// parsers reuses tree-sitter parsers across calls. A parser is not reentrant, so
+// each parse borrows one: Format and Equivalent may run from several goroutines.
+var parsers = sync.Pool{
+	New: func() any {
+		parser := sitter.NewParser()
+		parser.SetLanguage(php.GetLanguage())
+		return parser
+	},
+}
+
+// parseSource parses PHP source with a pooled parser.
+func parseSource(src []byte) (*sitter.Tree, error) {
+	parser := parsers.Get().(*sitter.Parser)
+	defer parsers.Put(parser)
+	return parser.ParseCtx(context.Background(), nil, src)
+}
@@ -0,0 +1,1365 @@
+// Package phpfmt formats PHP code. Each node type of the tree-sitter PHP grammar is
+// printed by a visitor; Printer.Handle replaces the visitor of a type to customize
+// the output.
+package phpfmt
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// ErrSyntax is returned by Format for code that does not parse: it is left as is
+// rather than formatted around the errors.
+var ErrSyntax = errors.New("le code contient des erreurs de syntaxe")
+
+// ErrInvalidOutput is returned by Format when the formatted code would not parse: the
+// input is valid, but a visitor printed it wrong.
+var ErrInvalidOutput = errors.New("le formatage produirait du code invalide")
+
+// VisitorFunc prints a node of a given type.
+type VisitorFunc func(p *Printer, node *sitter.Node)
+
+// Options configure the layout of the formatted code.
+type Options struct {
+	// Indent is the text of one indentation level.
+	Indent string
+	// SortImports sorts each run of use declarations: classes, then functions, then
+	// constants, alphabetically.
+	SortImports bool
+	// AlignArrows pads the keys of multiline arrays so that their "=>" line up.
+	AlignArrows bool
+	// BraceAlternativeSyntax rewrites "if (...): ... endif;" and the alternative
+	// syntax of while, for and foreach with braces, except in statements that hold
+	// inline HTML or heredocs.
+	BraceAlternativeSyntax bool
+	// ExpandShortEcho rewrites "<?= $x ?>" as "<?php echo $x; ?>".
+	ExpandShortEcho bool
+	// MaxLineLength is the width past which argument lists, arrays and binary
+	// expressions are wrapped (0 never wraps).
+	MaxLineLength int
+	// MaxBlankLines is the number of consecutive blank lines of the input kept
+	// between statements (0 removes them).
+	MaxBlankLines int
+	// SeparateFunctions puts exactly one blank line between consecutive functions,
+	// and between consecutive methods.
+	SeparateFunctions bool
+	// BlankLineAfterTag puts exactly one blank line after the opening tag.
+	BlankLineAfterTag bool
+}
+
+// DefaultOptions indents with four spaces, wraps at DefaultMaxLineLength and keeps
+// single blank lines, functions being separated by one (PSR-12).
+func DefaultOptions() Options {
+	return Options{Indent: "    ", MaxLineLength: DefaultMaxLineLength, MaxBlankLines: 1, SeparateFunctions: true}
+}
+
+// Printer formats PHP code with a visitor per node type, which Handle can replace.
+// A Printer formats one source at a time; use one per goroutine, or the Format
+// function, which is safe for concurrent use.
+type Printer struct {
+	Options
+
+	builder     *strings.Builder
+	indentLevel int
+	margin      string // written before the indentation of each line
+	from, to    uint32 // bytes of the statements formatted (see FormatRange)
+	flat        bool
+	template    bool // the file has inline HTML: one-line PHP islands stay on one line
+	sameLine    bool // the next writeLine continues the current line (after "<?php")
+	endsInHTML  bool // the output ends with the file's trailing HTML, written verbatim
+	visitors    map[string]VisitorFunc
+	input       []byte
+	verbatim    map[span]bool // statements printed as written (see faithfully)
+}
+
+// span identifies a node of the input by its bytes.
+type span struct {
+	start, end uint32
+}
+
+func spanOf(n *sitter.Node) span {
+	return span{n.StartByte(), n.EndByte()}
+}
+
+// NewPrinter returns a printer with the default visitors.
+func NewPrinter(opts Options) *Printer {
+	p := &Printer{
+		Options:  opts,
+		builder:  &strings.Builder{},
+		visitors: make(map[string]VisitorFunc),
+	}
+	for k, v := range defaultVisitors {
+		p.visitors[k] = v
+	}
+	return p
+}
+
+// Format formats src with opts.
+func Format(src []byte, opts Options) ([]byte, error) {
+	return NewPrinter(opts).Format(src)
+}
+
+// Format formats src, which ends with a single newline unless it ends with inline
+// HTML, kept as is. The result is checked to parse to the same syntax tree as src,
+// whitespace aside (see Equivalent): a statement holding a construct the visitors
+// would not reproduce faithfully is printed as written (see faithfully).
+func (p *Printer) Format(src []byte) ([]byte, error) {
+	root, err := p.parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return p.faithfully(root, func() []byte {
+		p.reset()
+		if root.ChildCount() > 0 {
+			// Whitespace before the first tag is output by PHP.
+			p.write(string(p.input[:root.Child(0).StartByte()]))
+		}
+		p.visitNode(root)
+
+		formatted := p.builder.String()
+		if !p.endsInHTML {
+			formatted = strings.TrimRight(formatted, "\n") + "\n"
+		}
+		return []byte(formatted)
+	})
+}
+
+// faithfully returns the output of print once it is Equivalent to the input. Until
+// then, the next attempt prints as written the innermost statement around the first
+// difference, or the statement that holds it if it already was: the visitors of the
+// other statements still apply. The difference is returned as an error when the
+// statements of the whole file are printed as written.
+func (p *Printer) faithfully(root *sitter.Node, print func() []byte) ([]byte, error) {
+	p.verbatim = make(map[span]bool)
+	for {
+		out := print()
+		at, err := firstDifference(p.input, out)
+		if err == nil {
+			return out, nil
+		}
+		stmt := p.unfaithful(root, at)
+		if stmt == nil {
+			return nil, err
+		}
+		p.verbatim[spanOf(stmt)] = true
+	}
+}
+
+// unfaithful returns the statement to print as written for a difference at offsets:
+// the innermost statement holding the first node that differs, or else the last
+// one that matched (a token dropped at the end of a statement), or else the
+// innermost statement holding them not yet printed as written. It is nil when none
+// is left.
+func (p *Printer) unfaithful(root *sitter.Node, offsets []uint32) *sitter.Node {
+	var statements [][]*sitter.Node
+	for i := len(offsets) - 1; i >= 0; i-- {
+		var enclosing []*sitter.Node // statements holding the offset, innermost first
+		for n := descendantAt(root, offsets[i]); n.Parent() != nil; n = n.Parent() {
+			if statementLists[n.Parent().Type()] && n.IsNamed() && !isVerbatim(n) {
+				enclosing = append(enclosing, n)
+			}
+		}
+		if len(enclosing) > 0 && !p.verbatim[spanOf(enclosing[0])] {
+			return enclosing[0]
+		}
+		statements = append(statements, enclosing)
+	}
+	for _, enclosing := range statements {
+		for _, stmt := range enclosing {
+			if !p.verbatim[spanOf(stmt)] {
+				return stmt
+			}
+		}
+	}
+	return nil
+}
+
+// descendantAt returns the innermost named node of root holding offset.
+func descendantAt(root *sitter.Node, offset uint32) *sitter.Node {
+	n := root
+	for descended := true; descended; {
+		descended = false
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			if child := n.NamedChild(i); child.StartByte() <= offset && offset < child.EndByte() {
+				n, descended = child, true
+				break
+			}
+		}
+	}
+	return n
+}
+
+// parse parses src and resets the printer to format it.
+func (p *Printer) parse(src []byte) (*sitter.Node, error) {
+	tree, err := parseSource(src)
+	if err != nil {
+		return nil, err
+	}
+	root := tree.RootNode()
+	if root.HasError() {
+		return nil, ErrSyntax
+	}
+
+	p.input = src
+	p.verbatim = nil
+	p.reset()
+	p.template = hasInlineHTML(root, p.input)
+	return root, nil
+}
+
+// reset empties the output before printing the input again.
+func (p *Printer) reset() {
+	p.builder.Reset()
+	p.indentLevel = 0
+	p.margin = ""
+	p.from, p.to = 0, uint32(len(p.input))
+	p.sameLine = false
+	p.endsInHTML = false
+}
+
+// Handle replaces the visitor of a node type and returns the previous one (nil if
+// the type had none), which the new visitor may call.
+func (p *Printer) Handle(nodeType string, visitor VisitorFunc) VisitorFunc {
+	previous := p.visitors[nodeType]
+	p.visitors[nodeType] = visitor
+	return previous
+}
+
+// Visit prints a node with the visitor of its type; a node without visitor prints
+// its children.
+func (p *Printer) Visit(node *sitter.Node) { p.visitNode(node) }
+
+// VisitChildren prints the children of a node.
+func (p *Printer) VisitChildren(node *sitter.Node) { defaultVisit(p, node) }
+
+// Write appends text to the current line.
+func (p *Printer) Write(s string) { p.write(s) }
+
+// WriteLine starts a new indented line with s.
+func (p *Printer) WriteLine(s string) { p.writeLine(s) }
+
+// Content returns the source text of a node.
+func (p *Printer) Content(node *sitter.Node) string { return p.content(node) }
+
+// Nest runs print one indentation level deeper.
+func (p *Printer) Nest(print func()) {
+	p.indent()
+	print()
+	p.unindent()
+}
+
+func (p *Printer) visitNode(node *sitter.Node) {
+	// fmt.Println("Visiting:", node.Type())
+	p.blankLines(node)
+	if p.verbatim[spanOf(node)] && node.Parent() != nil && statementLists[node.Parent().Type()] {
+		p.writeLine(p.content(node))
+		return
+	}
+	if handler, exists := p.visitors[node.Type()]; exists {
+		handler(p, node)
+	} else {
+		defaultVisit(p, node)
+	}
+}
+
+// blankLines separates a statement from the previous one by the blank lines that
+// separate them in the input, at most MaxBlankLines, or by exactly one between
+// functions (SeparateFunctions) and after the opening tag (BlankLineAfterTag).
+func (p *Printer) blankLines(n *sitter.Node) {
+	parent := n.Parent()
+	prev := n.PrevNamedSibling()
+	if parent == nil || !statementLists[parent.Type()] || prev == nil || !n.IsNamed() {
+		return
+	}
+	switch {
+	case isVerbatim(n) || prev.Type() == "text_interpolation":
+		return
+	case prev.Type() == n.Type() && n.Type() == "namespace_use_declaration":
+		// Printed with the first declaration of the run.
+		return
+	}
+
+	between := string(p.input[prev.EndByte():n.StartByte()])
+	want := min(strings.Count(between, "\n")-1, p.MaxBlankLines)
+	switch {
+	case prev.Type() == "php_tag":
+		if p.template {
+			return
+		}
+		if p.BlankLineAfterTag {
+			want = 1
+		}
+	case p.SeparateFunctions && isFunction(prev) && isFunction(documented(n)):
+		want = 1
+	}
+	if want <= 0 {
+		return
+	}
+	out := p.builder.String()
+	newlines := len(out) - len(strings.TrimRight(out, "\n"))
+	p.write(strings.Repeat("\n", max(0, want+1-newlines)))
+}
+
+// documented returns the declaration that a comment documents, written on the next
+// line, or n itself.
+func documented(n *sitter.Node) *sitter.Node {
+	for n.Type() == "comment" {
+		next := n.NextNamedSibling()
+		if next == nil || next.StartPoint().Row != n.EndPoint().Row+1 {
+			break
+		}
+		n = next
+	}
+	return n
+}
+
+func isFunction(n *sitter.Node) bool {
+	return n.Type() == "function_definition" || n.Type() == "method_declaration"
+}
+
+// isVerbatim reports whether a node is inline HTML or a tag, written as is.
+func isVerbatim(n *sitter.Node) bool {
+	switch n.Type() {
+	case "php_tag", "text", "text_interpolation":
+		return true
+	}
+	return false
+}
+
+func (p *Printer) write(s string) {
+	p.builder.WriteString(s)
+}
+
+// writeLine starts a new indented line, unless the output already ends with one.
+func (p *Printer) writeLine(s string) {
+	if p.sameLine {
+		p.sameLine = false
+		p.builder.WriteString(" " + s)
+		return
+	}
+	if !strings.HasSuffix(p.builder.String(), "\n") {
+		p.builder.WriteString("\n")
+	}
+	p.builder.WriteString(p.margin + strings.Repeat(p.Indent, p.indentLevel) + s)
+}
+
+// capture returns what write prints, without adding it to the output. Nothing is
+// wrapped while capturing.
+func (p *Printer) capture(write func()) string {
+	builder, flat := p.builder, p.flat
+	p.builder, p.flat = &strings.Builder{}, true
+	write()
+	s := p.builder.String()
+	p.builder, p.flat = builder, flat
+	return s
+}
+
+// layout prints flat when its first line fits in MaxLineLength, broken otherwise.
+func (p *Printer) layout(flat, broken func()) {
+	if p.flat {
+		flat()
+		return
+	}
+	s := p.capture(flat)
+	first, _, _ := strings.Cut(s, "\n")
+	if p.MaxLineLength <= 0 || p.column()+len(first) <= p.MaxLineLength {
+		p.write(s)
+	} else {
+		broken()
+	}
+}
+
+// column returns the length of the current output line.
+func (p *Printer) column() int {
+	out := p.builder.String()
+	return len(out) - strings.LastIndex(out, "\n") - 1
+}
+
+func (p *Printer) writeContent(node *sitter.Node) {
+	p.write(p.content(node))
+}
+
+func (p *Printer) indent() {
+	p.indentLevel++
+}
+
+func (p *Printer) unindent() {
+	if p.indentLevel > 0 {
+		p.indentLevel--
+	}
+}
+
+func (p *Printer) content(node *sitter.Node) string {
+	return node.Content(p.input)
+}
+
+// Helper functions for common visitor patterns
+func modifierVisitor(modifier string) VisitorFunc {
+	return func(p *Printer, _ *sitter.Node) {
+		p.write(modifier + " ")
+	}
+}
+
+func symbolVisitor(symbol string) VisitorFunc {
+	return func(p *Printer, node *sitter.Node) {
+		p.write(" " + symbol + " ")
+	}
+}
+
+func contentVisitor() VisitorFunc {
+	return func(p *Printer, node *sitter.Node) {
+		p.write(p.content(node))
+	}
+}
+
+func defaultVisit(p *Printer, node *sitter.Node) {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		p.visitNode(node.Child(i))
+	}
+}
+
+// Visitor definitions
+var defaultVisitors = map[string]VisitorFunc{
+	"program": defaultVisit,
+	"php_tag": func(p *Printer, node *sitter.Node) {
+		p.write(p.tag(node))
+		p.openTag(node, node.NextSibling())
+	},
+	"text":               contentVisitor(),
+	"text_interpolation": visitTextInterpolation,
+	"colon_block": func(p *Printer, n *sitter.Node) {
+		braces := p.bracesFor(n)
+		if braces {
+			p.write(" {")
+		} else {
+			p.write(":")
+		}
+		p.indent()
+		for i := 1; i < int(n.ChildCount()); i++ {
+			p.visitNode(n.Child(i))
+		}
+		p.unindent()
+		if braces {
+			p.writeLine("}")
+		}
+	},
+	"endif":    endKeywordVisitor("endif"),
+	"endwhile": endKeywordVisitor("endwhile"),
+	"echo_statement": func(p *Printer, n *sitter.Node) {
+		p.writeLine(p.content(n.Child(0)) + " ")
+		for i := 1; i < int(n.ChildCount()); i++ {
+			if n.Child(i).Type() == ";" {
+				p.visitNode(n.Child(i))
+			} else {
+				p.write(p.content(n.Child(i)))
+			}
+		}
+	},
+
+	// Declarations
+	"trait_declaration":     visitTypeDeclaration,
+	"interface_declaration": visitTypeDeclaration,
+	"enum_declaration":      visitTypeDeclaration,
+	"class_declaration":     visitTypeDeclaration,
+	"const_declaration":     visitMemberDeclaration,
+	"property_declaration":  visitMemberDeclaration,
+	"method_declaration": func(p *Printer, n *sitter.Node) {
+		p.writeLine("")
+		visitFunctionSignature(p, n)
+	},
+	"enum_case": func(p *Printer, n *sitter.Node) {
+		p.writeLine("case " + p.content(n.ChildByFieldName("name")))
+		if value := n.ChildByFieldName("value"); value != nil {
+			p.write(" = ")
+			p.visitNode(value)
+		}
+		p.write(";\n")
+	},
+	"const_element": func(p *Printer, n *sitter.Node) {
+		for i := 0; i < int(n.ChildCount()); i++ {
+			if child := n.Child(i); child.Type() == "name" {
+				p.writeContent(child)
+			} else {
+				p.visitNode(child)
+			}
+		}
+	},
+
+	// Modifiers
+	"final_modifier":      modifierVisitor("final"),
+	"abstract_modifier":   modifierVisitor("abstract"),
+	"readonly_modifier":   modifierVisitor("readonly"),
+	"static_modifier":     modifierVisitor("static"),
+	"visibility_modifier": func(p *Printer, n *sitter.Node) { p.write(p.content(n) + " ") },
+
+	// Control structures
+	"compound_statement": func(p *Printer, n *sitter.Node) {
+		p.write(" {")
+		p.indent()
+		defaultVisit(p, n)
+		p.unindent()
+		p.writeLine("}")
+	},
+	"if_statement":  statementVisitor("if"),
+	"do_statement":  visitDoStatement,
+	"try_statement": visitTryStatement,
+	"catch_clause":  visitCatchClause,
+	"finally_clause": func(p *Printer, n *sitter.Node) {
+		p.write(" finally")
+		p.visitNode(n.ChildByFieldName("body"))
+	},
+	"while_statement":   statementVisitor("while"),
+	"for_statement":     visitForStatement,
+	"foreach_statement": visitForeachStatement,
+	"by_ref": func(p *Printer, n *sitter.Node) {
+		p.write("&")
+		p.visitNode(n.Child(int(n.ChildCount()) - 1))
+	},
+	"else_if_clause": func(p *Printer, node *sitter.Node) {
+		if body := node.ChildByFieldName("body"); body != nil && body.Type() == "colon_block" && !p.bracesFor(node) {
+			p.writeLine("elseif ")
+		} else {
+			p.write(" elseif ")
+		}
+		for i := 1; i < int(node.ChildCount()); i++ {
+			p.visitNode(node.Child(i))
+		}
+	},
+
+	"else_clause": func(p *Printer, node *sitter.Node) {
+		if body := node.ChildByFieldName("body"); body != nil && body.Type() == "colon_block" && !p.bracesFor(node) {
+			p.writeLine("else")
+		} else {
+			p.write(" else")
+		}
+		for i := 0; i < int(node.ChildCount()); i++ {
+			child := node.Child(i)
+			switch child.Type() {
+			case "compound_statement", "colon_block":
+				p.visitNode(child)
+			case "if_statement":
+				// "else if" stays on the closing brace's line.
+				p.write(" if ")
+				defaultVisit(p, child)
+			}
+		}
+	},
+	"update_expression": func(p *Printer, n *sitter.Node) {
+		for i := 0; i < int(n.ChildCount()); i++ {
+			if child := n.Child(i); child.Type() == "++" || child.Type() == "--" {
+				p.write(child.Type())
+			} else {
+				p.visitNode(child)
+			}
+		}
+	},
+	"sequence_expression": func(p *Printer, n *sitter.Node) {
+		for i := 0; i < int(n.ChildCount()); i++ {
+			if child := n.Child(i); child.Type() == "," {
+				p.write(", ")
+			} else {
+				p.visitNode(child)
+			}
+		}
+	},
+	// Expressions
+	"parenthesized_expression": func(p *Printer, n *sitter.Node) {
+		p.write("(")
+		defaultVisit(p, n)
+		p.write(")")
+	},
+	"expression_statement": func(p *Printer, n *sitter.Node) {
+		if p.ExpandShortEcho && IsShortEcho(n, p.input) {
+			p.writeLine("echo ")
+			defaultVisit(p, n)
+			if n.Child(int(n.ChildCount())-1).Type() != ";" {
+				p.write(";")
+			}
+			return
+		}
+		p.writeLine("")
+		defaultVisit(p, n)
+	},
+	"assignment_expression":   binaryOperatorVisitor(""),
+	"binary_expression":       visitBinaryExpression,
+	"variadic_unpacking":      tokenVisitor,
+	"subscript_expression":    tokenVisitor,
+	"throw_expression":        keywordExpression,
+	"include_expression":      keywordExpression,
+	"include_once_expression": keywordExpression,
+	"require_expression":      keywordExpression,
+	"require_once_expression": keywordExpression,
+	"print_intrinsic":         keywordExpression,
+	"clone_expression":        keywordExpression,
+	"unary_op_expression":     tokenVisitor,
+	"cast_expression": func(p *Printer, n *sitter.Node) {
+		p.write("(" + p.content(n.ChildByFieldName("type")) + ") ")
+		p.visitNode(n.ChildByFieldName("value"))
+	},
+	"arguments": visitArguments,
+	"argument": func(p *Printer, n *sitter.Node) {
+		for i := 0; i < int(n.ChildCount()); i++ {
+			switch child := n.Child(i); {
+			case child.Type() == ":":
+				p.write(": ")
+			case child.IsNamed():
+				p.visitNode(child)
+			default:
+				p.writeContent(child)
+			}
+		}
+	},
+	"function_call_expression":          tokenVisitor,
+	"member_call_expression":            tokenVisitor,
+	"nullsafe_member_call_expression":   tokenVisitor,
+	"scoped_call_expression":            tokenVisitor,
+	"member_access_expression":          tokenVisitor,
+	"nullsafe_member_access_expression": tokenVisitor,
+	"class_constant_access_expression":  tokenVisitor,
+	"scoped_property_access_expression": tokenVisitor,
+	"object_creation_expression": func(p *Printer, n *sitter.Node) {
+		p.write("new ")
+		for i := 1; i < int(n.ChildCount()); i++ {
+			p.visitNode(n.Child(i))
+		}
+	},
+
+	// Literals
+	"integer":        contentVisitor(),
+	"float":          contentVisitor(),
+	"boolean":        contentVisitor(),
+	"string":         contentVisitor(),
+	"variable_name":  contentVisitor(),
+	"name":           contentVisitor(),
+	"null":           contentVisitor(),
+	"qualified_name": contentVisitor(),
+
+	// Heredoc and nowdoc bodies are part of the string value and the closing marker's
+	// indentation is stripped from every body line (PHP 7.3+): they are printed
+	// verbatim, from "<<<" to the closing marker, never re-indented or trimmed.
+	"heredoc": contentVisitor(),
+	"nowdoc":  contentVisitor(),
+
+	// Interpolated strings and shell commands ("{$obj->prop}", "${name}", `ls $dir`)
+	// are printed verbatim too: their children are parts of the string, where the
+	// expression visitors would drop or respace text.
+	"encapsed_string":          contentVisitor(),
+	"shell_command_expression": contentVisitor(),
+
+	// Namespaces
+	"namespace_definition": func(p *Printer, n *sitter.Node) {
+		p.writeLine("namespace")
+		if name := n.ChildByFieldName("name"); name != nil {
+			p.write(" " + p.content(name))
+		}
+		if body := n.ChildByFieldName("body"); body != nil {
+			p.visitNode(body)
+			p.write("\n")
+		} else {
+			p.write(";\n")
+		}
+		if n.NextNamedSibling() != nil {
+			p.write("\n")
+		}
+	},
+	"namespace_use_declaration": visitNamespaceUseDeclaration,
+
+	// Special cases
+	"use_declaration": func(p *Printer, n *sitter.Node) {
+		p.writeLine("")
+		writeNameList(p, n)
+	},
+	"break_statement":             keywordStatement,
+	"continue_statement":          keywordStatement,
+	"global_declaration":          keywordStatement,
+	"function_static_declaration": keywordStatement,
+	"goto_statement":              keywordStatement,
+	"named_label_statement": func(p *Printer, n *sitter.Node) {
+		p.writeLine(p.content(n.NamedChild(0)) + ":")
+	},
+	"unset_statement": func(p *Printer, n *sitter.Node) {
+		p.writeLine("unset(")
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.visitNode(n.NamedChild(i))
+		}
+		p.write(")")
+		p.visitNode(n.Child(int(n.ChildCount()) - 1))
+	},
+	"comment": visitComment,
+	"return_statement": func(p *Printer, n *sitter.Node) {
+		firstChild := n.Child(0)
+		p.writeLine(p.content(firstChild) + " ")
+		for i := 1; i < int(n.ChildCount()); i++ {
+			child := n.Child(i)
+			p.visitNode(child)
+		}
+	},
+
+	"array_creation_expression": visitArray,
+	"array_element_initializer": func(p *Printer, n *sitter.Node) {
+		writeArrayElement(p, n, 0)
+	},
+	"function_definition": visitFunctionDefinition,
+	"formal_parameters": func(p *Printer, n *sitter.Node) {
+		for i := 0; i < int(n.ChildCount()); i++ {
+			child := n.Child(i)
+			if p.content(child) == "," {
+				p.write(", ")
+			} else {
+				p.write(p.content(child))
+			}
+		}
+	},
+
+	";": func(p *Printer, node *sitter.Node) {
+		if prev := node.PrevSibling(); prev != nil && alternativeEnds[prev.Type()] && p.bracesFor(node.Parent()) {
+			return // "endif;" became "}"
+		}
+		p.write(p.content(node) + "\n")
+	},
+}
+
+func visitFunctionDefinition(p *Printer, node *sitter.Node) {
+	p.writeLine("")
+	visitFunctionSignature(p, node)
+}
+
+// visitFunctionSignature prints a function or method: modifiers, "function", the
+// by-reference marker, name, parameters, ": return type", then the body or the ";"
+// of an abstract method.
+func visitFunctionSignature(p *Printer, node *sitter.Node) {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch {
+		case child.Type() == "function":
+			p.write("function ")
+		case child.Type() == "reference_modifier":
+			p.write("&")
+		case child.Type() == "name":
+			p.writeContent(child)
+		case child.Type() == ":":
+			p.write(": ")
+		case node.FieldNameForChild(i) == "return_type":
+			p.writeContent(child)
+		default:
+			p.visitNode(child)
+		}
+	}
+}
+
+// visitTypeDeclaration prints a class, interface, trait or enum header on its own
+// line (modifiers, name, enum backing type, extends and implements lists), then its
+// members, one per line, indented in braces.
+func visitTypeDeclaration(p *Printer, node *sitter.Node) {
+	p.writeLine("")
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch child.Type() {
+		case "class", "interface", "trait", "enum":
+			p.write(child.Type() + " ")
+		case "name", "primitive_type":
+			p.writeContent(child)
+		case ":":
+			p.write(": ")
+		case "base_clause", "class_interface_clause":
+			p.write(" ")
+			writeNameList(p, child)
+		case "declaration_list", "enum_declaration_list":
+			p.write(" {")
+			p.indent()
+			for j := 0; j < int(child.NamedChildCount()); j++ {
+				p.visitNode(child.NamedChild(j))
+			}
+			p.unindent()
+			p.writeLine("}")
+		default:
+			p.visitNode(child)
+		}
+	}
+}
+
+// visitMemberDeclaration prints a constant or property declaration on its own line:
+// modifiers, "const" or the property type, then the elements separated by ", ".
+func visitMemberDeclaration(p *Printer, node *sitter.Node) {
+	p.writeLine("")
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch {
+		case child.Type() == "const":
+			p.write("const ")
+		case child.Type() == ",":
+			p.write(", ")
+		case node.FieldNameForChild(i) == "type":
+			p.write(p.content(child) + " ")
+		default:
+			p.visitNode(child)
+		}
+	}
+}
+
+// writeNameList prints a keyword followed by names separated by ", ", as in
+// "implements A, B" or "use A, B;".
+func writeNameList(p *Printer, node *sitter.Node) {
+	for i := 0; i < int(node.ChildCount()); i++ {
+		child := node.Child(i)
+		switch {
+		case child.Type() == ",":
+			p.write(", ")
+		case child.Type() == ";":
+			p.visitNode(child)
+		case child.IsNamed():
+			p.writeContent(child)
+		default:
+			p.write(child.Type() + " ")
+		}
+	}
+}
+
+// DefaultMaxLineLength is the PSR-12 soft line length limit.
+const DefaultMaxLineLength = 120
+
+// visitArray prints array() and [] literals on one line, or one element per line
+// with a trailing comma when they hold nested arrays or comments, or are too wide.
+func visitArray(p *Printer, n *sitter.Node) {
+	open, close := "[", "]"
+	if n.Child(0).Type() == "array" {
+		open, close = "array(", ")"
+	}
+	var elements []*sitter.Node
+	multiline := false
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		child := n.NamedChild(i)
+		elements = append(elements, child)
+		if child.Type() == "comment" || isNestedArray(child) {
+			multiline = true
+		}
+	}
+	broken := func() { writeBrokenArray(p, open, close, elements) }
+	if multiline {
+		broken()
+		return
+	}
+	p.layout(func() {
+		p.write(open)
+		for i, element := range elements {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.visitNode(element)
+		}
+		p.write(close)
+	}, broken)
+}
+
+// writeBrokenArray prints one array element per line, each followed by a comma.
+func writeBrokenArray(p *Printer, open, close string, elements []*sitter.Node) {
+	if len(elements) == 0 {
+		p.write(open + close)
+		return
+	}
+	keyWidth := 0
+	if p.AlignArrows {
+		for _, element := range elements {
+			if key := arrayKey(element); key != nil {
+				keyWidth = max(keyWidth, len(p.content(key)))
+			}
+		}
+	}
+	p.write(open + "\n")
+	p.indent()
+	for _, element := range elements {
+		p.writeLine("")
+		if element.Type() == "comment" {
+			p.write(p.content(element) + "\n")
+			continue
+		}
+		writeArrayElement(p, element, keyWidth)
+		p.write(",\n")
+	}
+	p.unindent()
+	p.writeLine(close)
+}
+
+// writeArrayElement prints "key => value", padding the key to keyWidth. Nested arrays
+// are formatted, other keys and values are kept as written.
+func writeArrayElement(p *Printer, n *sitter.Node, keyWidth int) {
+	for i := 0; i < int(n.ChildCount()); i++ {
+		child := n.Child(i)
+		switch child.Type() {
+		case "=>":
+			p.write(strings.Repeat(" ", max(0, keyWidth-len(p.content(n.Child(0))))) + " => ")
+		case "array_creation_expression":
+			p.visitNode(child)
+		default:
+			p.writeContent(child)
+		}
+	}
+}
+
+// arrayKey returns the key of an array element, or nil.
+func arrayKey(element *sitter.Node) *sitter.Node {
+	if element.ChildCount() == 3 && element.Child(1).Type() == "=>" {
+		return element.Child(0)
+	}
+	return nil
+}
+
+// isNestedArray reports whether an array element's value is a non-empty array.
+func isNestedArray(element *sitter.Node) bool {
+	value := element.Child(int(element.ChildCount()) - 1)
+	return value != nil && value.Type() == "array_creation_expression" && value.NamedChildCount() > 0
+}
+
+// visitArguments prints a call's arguments on one line, or one per line when they
+// do not fit.
+func visitArguments(p *Printer, n *sitter.Node) {
+	var args []*sitter.Node
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		args = append(args, n.NamedChild(i))
+	}
+	p.layout(func() {
+		p.write("(")
+		for i, arg := range args {
+			if i > 0 {
+				p.write(", ")
+			}
+			p.visitNode(arg)
+		}
+		p.write(")")
+	}, func() {
+		p.write("(")
+		p.indent()
+		for i, arg := range args {
+			p.writeLine("")
+			p.visitNode(arg)
+			if i < len(args)-1 {
+				p.write(",")
+			}
+		}
+		p.unindent()
+		p.writeLine(")")
+	})
+}
+
+// visitBinaryExpression prints "a op b", or breaks a chain of the same operator
+// ("a . b . c", "a && b && c") before each operator, one indentation further.
+func visitBinaryExpression(p *Printer, n *sitter.Node) {
+	operator := n.ChildByFieldName("operator").Type()
+	operands := []*sitter.Node{n.ChildByFieldName("right")}
+	left := n.ChildByFieldName("left")
+	for left.Type() == n.Type() && left.ChildByFieldName("operator").Type() == operator {
+		operands = append([]*sitter.Node{left.ChildByFieldName("right")}, operands...)
+		left = left.ChildByFieldName("left")
+	}
+	operands = append([]*sitter.Node{left}, operands...)
+
+	p.layout(func() {
+		for i, operand := range operands {
+			if i > 0 {
+				p.write(" " + operator + " ")
+			}
+			p.visitNode(operand)
+		}
+	}, func() {
+		p.visitNode(operands[0])
+		p.indent()
+		for _, operand := range operands[1:] {
+			p.writeLine(operator + " ")
+			p.visitNode(operand)
+		}
+		p.unindent()
+	})
+}
+
+// hasInlineHTML reports whether a file has HTML outside of its PHP tags.
+func hasInlineHTML(root *sitter.Node, input []byte) bool {
+	if root.Type() == "text" {
+		return strings.TrimSpace(root.Content(input)) != ""
+	}
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		if hasInlineHTML(root.NamedChild(i), input) {
+			return true
+		}
+	}
+	return false
+}
+
+// tag returns an opening tag as written, or "<?php" for a short echo tag expanded
+// with ExpandShortEcho.
+func (p *Printer) tag(n *sitter.Node) string {
+	if tag := p.content(n); tag != "<?=" || !p.ExpandShortEcho {
+		return tag
+	}
+	return "<?php"
+}
+
+// openTag ends the line after an opening tag, unless the file is a template and the
+// tag is followed by code on the same line: that island stays on one line.
+func (p *Printer) openTag(tag, next *sitter.Node) {
+	if p.template && next != nil && next.StartPoint().Row == tag.EndPoint().Row {
+		p.sameLine = true
+	} else {
+		p.write("\n")
+	}
+}
+
+// visitTextInterpolation prints "?>", the HTML that follows and the next opening tag
+// verbatim: the HTML is output by PHP as written. In a template, "?>" stays on the
+// line of the code it closes.
+func visitTextInterpolation(p *Printer, n *sitter.Node) {
+	prev := n.PrevSibling()
+	if p.template && prev != nil && prev.EndPoint().Row == n.StartPoint().Row {
+		out := strings.TrimSuffix(p.builder.String(), "\n")
+		p.builder.Reset()
+		p.write(out + " ")
+		p.sameLine = false
+	} else {
+		p.sameLine = false
+		p.writeLine("")
+	}
+	last := n.Child(int(n.ChildCount()) - 1)
+	if last.Type() != "php_tag" {
+		// The file ends in HTML: everything up to its end, trailing whitespace
+		// included, is output.
+		p.write(string(p.input[n.StartByte():]))
+		p.endsInHTML = true
+		return
+	}
+	p.write(string(p.input[n.StartByte():last.StartByte()]) + p.tag(last))
+	next := n.NextSibling()
+	if next == nil && n.Parent() != nil {
+		next = n.Parent().NextSibling()
+	}
+	p.openTag(last, next)
+}
+
+// keywordStatement prints a statement such as "break 2;" or "global $a, $b;" on its
+// own line.
+func keywordStatement(p *Printer, n *sitter.Node) {
+	p.writeLine("")
+	keywordExpression(p, n)
+}
+
+// keywordExpression prints a keyword followed by its operands, as in "throw $e" or
+// "require_once 'file.php'".
+func keywordExpression(p *Printer, n *sitter.Node) {
+	p.writeContent(n.Child(0))
+	for i := 1; i < int(n.ChildCount()); i++ {
+		switch child := n.Child(i); child.Type() {
+		case ";":
+			p.visitNode(child)
+		case ",":
+			p.write(", ")
+		default:
+			if i == 1 {
+				p.write(" ")
+			}
+			p.visitNode(child)
+		}
+	}
+}
+
+// visitComment keeps a comment that follows code on the same line at the end of that
+// line; other comments get a line of their own.
+func visitComment(p *Printer, n *sitter.Node) {
+	text := strings.TrimRight(p.content(n), " \t\r\n")
+	if prev := n.PrevSibling(); prev != nil && prev.EndPoint().Row == n.StartPoint().Row {
+		out := strings.TrimSuffix(p.builder.String(), "\n")
+		p.builder.Reset()
+		p.write(out + " " + text + "\n")
+		return
+	}
+	p.writeLine(text + "\n")
+}
+
+// tokenVisitor prints a node's anonymous tokens ("->", "::", "!") as written and
+// visits its named children.
+func tokenVisitor(p *Printer, n *sitter.Node) {
+	for i := 0; i < int(n.ChildCount()); i++ {
+		if child := n.Child(i); child.IsNamed() {
+			p.visitNode(child)
+		} else {
+			p.writeContent(child)
+		}
+	}
+}
+
+// useImport is one import of a use declaration: its kind ("", "function" or "const")
+// and the imported name with its alias or group.
+type useImport struct {
+	kind, text string
+}
+
+var useKindOrder = map[string]int{"": 0, "function": 1, "const": 2}
+
+// visitNamespaceUseDeclaration prints a run of consecutive use declarations when
+// visiting its first one, one import per line ("use A, B;" becomes two lines) and
+// sorted with SortImports. Grouped imports stay grouped on one line.
+func visitNamespaceUseDeclaration(p *Printer, n *sitter.Node) {
+	if prev := n.PrevNamedSibling(); prev != nil && prev.Type() == n.Type() && prev.StartByte() >= p.from {
+		return
+	}
+	var imports []useImport
+	for decl := n; decl != nil && decl.Type() == n.Type() && decl.EndByte() <= p.to; decl = decl.NextNamedSibling() {
+		imports = append(imports, p.useImports(decl)...)
+	}
+	if p.SortImports {
+		sort.SliceStable(imports, func(i, j int) bool {
+			if imports[i].kind != imports[j].kind {
+				return useKindOrder[imports[i].kind] < useKindOrder[imports[j].kind]
+			}
+			return strings.ToLower(imports[i].text) < strings.ToLower(imports[j].text)
+		})
+	}
+	for _, imported := range imports {
+		if imported.kind != "" {
+			p.writeLine("use " + imported.kind + " " + imported.text + ";\n")
+		} else {
+			p.writeLine("use " + imported.text + ";\n")
+		}
+	}
+}
+
+// useImports splits a use declaration into its imports.
+func (p *Printer) useImports(decl *sitter.Node) []useImport {
+	kind := ""
+	prefix := ""
+	var imports []useImport
+	for i := 0; i < int(decl.ChildCount()); i++ {
+		child := decl.Child(i)
+		switch child.Type() {
+		case "function", "const":
+			kind = child.Type()
+		case "namespace_name":
+			prefix = p.content(child)
+		case "namespace_use_clause":
+			imports = append(imports, useImport{kind, p.useClause(child)})
+		case "namespace_use_group":
+			var members []string
+			for j := 0; j < int(child.NamedChildCount()); j++ {
+				members = append(members, p.useClause(child.NamedChild(j)))
+			}
+			if p.SortImports {
+				sort.SliceStable(members, func(a, b int) bool { return strings.ToLower(members[a]) < strings.ToLower(members[b]) })
+			}
+			imports = append(imports, useImport{kind, prefix + "\\{" + strings.Join(members, ", ") + "}"})
+		}
+	}
+	return imports
+}
+
+// useClause prints an imported name with its kind inside a group and its alias.
+func (p *Printer) useClause(clause *sitter.Node) string {
+	var parts []string
+	for i := 0; i < int(clause.ChildCount()); i++ {
+		child := clause.Child(i)
+		if child.Type() == "namespace_aliasing_clause" {
+			parts = append(parts, "as", p.content(child.NamedChild(0)))
+		} else {
+			parts = append(parts, p.content(child))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// Additional helper constructors
+func statementVisitor(keyword string) VisitorFunc {
+	return func(p *Printer, n *sitter.Node) {
+		p.writeLine(keyword + " ")
+		defaultVisit(p, n)
+	}
+}
+
+// visitForStatement prints the init/condition/update clauses separated by "; "
+// (an empty clause keeps only its separator, as in for (;;)), then the body.
+func visitForStatement(p *Printer, n *sitter.Node) {
+	p.writeLine("for (")
+	for i, field := range []string{"initialize", "condition", "update"} {
+		clause := n.ChildByFieldName(field)
+		if i > 0 {
+			p.write(";")
+			if clause != nil {
+				p.write(" ")
+			}
+		}
+		if clause != nil {
+			p.visitNode(clause)
+		}
+	}
+	p.write(")")
+	for i := 0; i < int(n.ChildCount()); i++ {
+		if n.Child(i).Type() == ")" {
+			visitLoopBody(p, n, i+1, "endfor")
+			return
+		}
+	}
+}
+
+// alternativeEnds are the keywords that close the alternative syntax.
+var alternativeEnds = map[string]bool{"endif": true, "endwhile": true, "endfor": true, "endforeach": true}
+
+// bracesFor reports whether the alternative syntax of the statement holding n is
+// rewritten with braces: BraceAlternativeSyntax is set and the statement holds no
+// inline HTML nor heredoc, which are kept exactly as written.
+func (p *Printer) bracesFor(n *sitter.Node) bool {
+	if !p.BraceAlternativeSyntax {
+		return false
+	}
+	for n.Type() == "colon_block" || n.Type() == "else_if_clause" || n.Type() == "else_clause" {
+		n = n.Parent()
+	}
+	return !containsNode(n, "text_interpolation", "heredoc", "nowdoc")
+}
+
+// containsNode reports whether n or one of its descendants has one of the types.
+func containsNode(n *sitter.Node, types ...string) bool {
+	for _, t := range types {
+		if n.Type() == t {
+			return true
+		}
+	}
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		if containsNode(n.NamedChild(i), types...) {
+			return true
+		}
+	}
+	return false
+}
+
+// endKeywordVisitor prints the keyword that closes an alternative syntax block, or
+// the closing brace that replaces it.
+func endKeywordVisitor(keyword string) VisitorFunc {
+	return func(p *Printer, n *sitter.Node) {
+		if !p.bracesFor(n.Parent()) {
+			p.writeLine(keyword)
+		}
+	}
+}
+
+// visitLoopBody prints the body of a loop starting at child first: a braced block,
+// a single statement indented under the loop, or the alternative syntax
+// (": ... endfor;") with its statements indented.
+func visitLoopBody(p *Printer, n *sitter.Node, first int, endKeyword string) {
+	alternative := false
+	open, end := ":", endKeyword
+	if p.bracesFor(n) {
+		open, end = " {", "}"
+	}
+	for i := first; i < int(n.ChildCount()); i++ {
+		child := n.Child(i)
+		switch child.Type() {
+		case ":":
+			p.write(open)
+			p.indent()
+			alternative = true
+		case "colon_block":
+			p.write(open)
+			p.indent()
+			alternative = true
+			for j := 1; j < int(child.ChildCount()); j++ {
+				p.visitNode(child.Child(j))
+			}
+		case endKeyword:
+			p.unindent()
+			p.writeLine(end)
+		case ";", "compound_statement":
+			p.visitNode(child)
+		default:
+			if alternative {
+				p.visitNode(child)
+				continue
+			}
+			p.indent()
+			p.visitNode(child)
+			p.unindent()
+		}
+	}
+}
+
+// visitDoStatement prints "do", the body, then "while (...);" on the closing brace's
+// line, or on its own line after a single statement.
+func visitDoStatement(p *Printer, n *sitter.Node) {
+	p.writeLine("do")
+	body := n.ChildByFieldName("body")
+	if body.Type() == "compound_statement" {
+		p.visitNode(body)
+		p.write(" while ")
+	} else {
+		p.Nest(func() { p.visitNode(body) })
+		p.writeLine("while ")
+	}
+	p.visitNode(n.ChildByFieldName("condition"))
+	p.visitNode(n.Child(int(n.ChildCount()) - 1))
+}
+
+// visitTryStatement prints "try", its block, then its catch and finally clauses on
+// the closing braces' lines.
+func visitTryStatement(p *Printer, n *sitter.Node) {
+	p.writeLine("try")
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		p.visitNode(n.NamedChild(i))
+	}
+}
+
+// visitCatchClause prints "catch (A | B $e)", the variable being optional (PHP 8),
+// then the block.
+func visitCatchClause(p *Printer, n *sitter.Node) {
+	p.write(" catch (" + p.content(n.ChildByFieldName("type")))
+	if name := n.ChildByFieldName("name"); name != nil {
+		p.write(" " + p.content(name))
+	}
+	p.write(")")
+	p.visitNode(n.ChildByFieldName("body"))
+}
+
+// visitForeachStatement prints "foreach ($items as $key => &$value)" with single
+// spaces (the pair goes through the "=>" symbol visitor, the reference through
+// by_ref), then the body.
+func visitForeachStatement(p *Printer, n *sitter.Node) {
+	p.writeLine("foreach (")
+	for i := 0; i < int(n.ChildCount()); i++ {
+		child := n.Child(i)
+		switch child.Type() {
+		case "foreach", "(":
+		case "as":
+			p.write(" as ")
+		case ")":
+			p.write(")")
+			visitLoopBody(p, n, i+1, "endforeach")
+			return
+		default:
+			p.visitNode(child)
+		}
+	}
+}
+
+func binaryOperatorVisitor(operator string) VisitorFunc {
+	return func(p *Printer, n *sitter.Node) {
+		for i := 0; i < int(n.ChildCount()); i++ {
+			if i > 0 {
+				p.write(operator)
+			}
+			p.visitNode(n.Child(i))
+		}
+	}
+}
+
+// Initialize symbol visitors programmatically
+var symbolVisitors = []string{
+	"+", "-", "*", "/", "%", "**", "+=", "-=", "*=", "/=", "%=", "**=",
+	"=", "&", "|", "^", "<<", ">>", "&=", "|=", "^=", "<<=", ">>=",
+	"==", "===", "!=", "<>", "!==", "<", "<=", ">", ">=", "??", "&&", "||", "=>",
+	".=", "??=",
+}
+
+func init() {
+	for _, sym := range symbolVisitors {
+		defaultVisitors[sym] = symbolVisitor(sym)
+	}
+}
@@ -0,0 +1,502 @@
+package phpfmt
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/stretchr/testify/assert"
+)
+
+func formatPHP(input string) (string, error) {
+	return formatWith(DefaultOptions(), input)
+}
+
+func formatWith(opts Options, input string) (string, error) {
+	output, err := Format([]byte(input), opts)
+	return string(output), err
+}
+
+func TestPHPTag(t *testing.T) {
+	input := " <?php echo \"Hello, World!\";?>"
+	expected := "\necho \"Hello, World!\";\n"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+	t.Log(output)
+}
+
+func TestVariableDeclaration(t *testing.T) {
+	input := "<?php $x=5;"
+	expected := "$x = 5;"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+	t.Log("Input: " + input)
+	t.Log("Output: " + output)
+}
+
+func TestAssignment(t *testing.T) {
+	input := "<?php $x=$y;"
+	expected := "$x = $y;"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+}
+
+func TestArrayDeclaration(t *testing.T) {
+	input := "<?php $arr=array(1,2,3,4,5,   6,           7,8);"
+	expected := "$arr = array(1, 2, 3, 4, 5, 6, 7, 8);"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+	t.Log("Input: " + input)
+	t.Log("Output: " + output)
+}
+
+func TestMultilineArray(t *testing.T) {
+	input := "<?php $a=[1,'k'=>[2,3],...$x];$b=array('a'=>1,'long'=>array());"
+	expected := "$a = [\n    1,\n    'k' => [2, 3],\n    ...$x,\n];\n$b = array('a' => 1, 'long' => array());"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+
+	long := "<?php $c=['" + strings.Repeat("a", 60) + "'=>1,'" + strings.Repeat("b", 60) + "'=>2];"
+	opts := DefaultOptions()
+	opts.AlignArrows = true
+	output, err = formatWith(opts, long+"$d=array(/* c */ 'x'=>1,'yyy'=>2);")
+	assert.NoError(t, err)
+	assert.Contains(t, output, "$c = [\n    '"+strings.Repeat("a", 60)+"' => 1,\n    '"+strings.Repeat("b", 60)+"' => 2,\n];")
+	assert.Contains(t, output, "$d = array(\n    /* c */\n    'x'   => 1,\n    'yyy' => 2,\n);")
+}
+
+func TestMaxLineLength(t *testing.T) {
+	input := `<?php
+function f() {
+$message = sprintf("%s: %s", $this->translator->translate('some.translation.key'), $o->call(name: $v));
+if ($someCondition && $anotherConditionName && $yetAnotherCondition || $fallbackCondition) { return 1; }
+$s = 'The quick brown fox jumps over the lazy dog, ' . $subject . ' and then ' . $verb;
+$short = foo($a, 1, "x") . $b;
+}`
+	expected := `function f() {
+    $message = sprintf(
+        "%s: %s",
+        $this->translator->translate('some.translation.key'),
+        $o->call(name: $v)
+    );
+    if ($someCondition && $anotherConditionName && $yetAnotherCondition
+        || $fallbackCondition) {
+        return 1;
+    }
+    $s = 'The quick brown fox jumps over the lazy dog, '
+        . $subject
+        . ' and then '
+        . $verb;
+    $short = foo($a, 1, "x") . $b;
+}`
+
+	opts := DefaultOptions()
+	opts.MaxLineLength = 80
+	output, err := formatWith(opts, input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+
+	opts.MaxLineLength = 0
+	output, err = formatWith(opts, input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "    $s = 'The quick brown fox jumps over the lazy dog, ' . $subject . ' and then ' . $verb;\n")
+}
+
+func TestFunctionDefinition(t *testing.T) {
+	input := "<?php function test($param1,$param2){return $param1+$param2;}"
+	expected := "function test($param1, $param2) {\n    return $param1 + $param2;\n}"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+	t.Log("Input: " + input)
+	t.Log("Output: " + output)
+}
+
+func TestIfStatement(t *testing.T) {
+	input := `<?php if ($x>5) { echo "Greater"; } else { echo "Smaller"; }`
+	expected := "if ($x > 5) {\n    echo \"Greater\";\n} else {\n    echo \"Smaller\";\n}"
+
+	output, err := formatPHP(input)
+	fmt.Println(output)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+	t.Log("Input: " + input)
+	t.Log("Output: " + output)
+}
+
+func TestWhileLoop(t *testing.T) {
+	input := `<?php while($i<10){                  $i++; }`
+	expected := "while ($i < 10) {\n    $i++;\n}"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+	t.Log("Input: " + input)
+	t.Log("Output: " + output)
+}
+
+func TestForLoop(t *testing.T) {
+	input := `<?php for ($i=0;$i<10;             $i++) { echo $i; }`
+	expected := "for ($i = 0; $i < 10; $i++) {\n    echo $i;\n}"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+}
+
+func TestForLoopClauses(t *testing.T) {
+	input := `<?php for($i=0,$j=10;$i<$j;$i++,$j--){for(;;){$k++;}}
+for ($i=0;$i<3;$i++) echo $i;
+for ($i=0;$i<3;$i++): echo $i; endfor;`
+	expected := "for ($i = 0, $j = 10; $i < $j; $i++, $j--) {\n    for (;;) {\n        $k++;\n    }\n}\n" +
+		"for ($i = 0; $i < 3; $i++)\n    echo $i;\n" +
+		"for ($i = 0; $i < 3; $i++):\n    echo $i;\nendfor;\n"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n"+expected, output)
+}
+
+func TestForeachLoop(t *testing.T) {
+	input := `<?php foreach ($arr as   $val) { echo $val; }`
+	expected := "foreach ($arr as $val) {\n    echo $val;\n}"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+}
+
+func TestForeachKeyValueAndReference(t *testing.T) {
+	input := `<?php foreach($arr as $k=>$v){echo $k;}
+foreach ( $arr as&$v ) { $v++; }
+foreach ($arr as $k   =>   &$v): echo $k; endforeach;`
+	expected := "foreach ($arr as $k => $v) {\n    echo $k;\n}\n" +
+		"foreach ($arr as &$v) {\n    $v++;\n}\n" +
+		"foreach ($arr as $k => &$v):\n    echo $k;\nendforeach;\n"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n"+expected, output)
+}
+
+func TestEnumDeclaration(t *testing.T) {
+	input := `<?php enum Status:string implements HasLabel{case Active='a';case   Inactive = 'i';
+public function label():string{return 'label';}}
+enum Suit{case Hearts;case Spades;}`
+	expected := "enum Status: string implements HasLabel {\n" +
+		"    case Active = 'a';\n" +
+		"    case Inactive = 'i';\n" +
+		"    public function label(): string {\n" +
+		"        return 'label';\n" +
+		"    }\n" +
+		"}\n" +
+		"enum Suit {\n    case Hearts;\n    case Spades;\n}\n"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n"+expected, output)
+}
+
+func TestClassMembers(t *testing.T) {
+	input := `<?php abstract class A extends B implements C,D{public const X=1;private static ?int $y=2;abstract protected function h(): ?string;}`
+	expected := "abstract class A extends B implements C, D {\n" +
+		"    public const X = 1;\n" +
+		"    private static ?int $y = 2;\n" +
+		"    abstract protected function h(): ?string;\n" +
+		"}"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+}
+
+func TestHeredocVerbatim(t *testing.T) {
+	heredoc := "<<<EOT\n  Hello $name\n    {$a[1]}   \n\n  EOT"
+	nowdoc := "<<<'NOW'\nraw $x  \nNOW"
+	input := "<?php\nfunction f() {\n$x=" + heredoc + ";\necho " + nowdoc + ";\n}"
+	expected := "function f() {\n    $x = " + heredoc + ";\n    echo " + nowdoc + ";\n}"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+}
+
+func TestNamespaceAndUse(t *testing.T) {
+	input := "<?php\nnamespace Foo\\Bar;\nuse Z\\Y,A\\B   as C;\nuse const Foo\\X;\nuse function Foo\\g, Foo\\f;\nuse A\\{ D as E,function e ,B};\necho 1;"
+	expected := "namespace Foo\\Bar;\n\nuse Z\\Y;\nuse A\\B as C;\nuse const Foo\\X;\nuse function Foo\\g;\nuse function Foo\\f;\nuse A\\{D as E, function e, B};\n"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+
+	opts := DefaultOptions()
+	opts.SortImports = true
+	output, err = formatWith(opts, input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, "use A\\B as C;\nuse A\\{B, D as E, function e};\nuse Z\\Y;\nuse function Foo\\f;\nuse function Foo\\g;\nuse const Foo\\X;\n")
+}
+
+func TestBracedNamespaces(t *testing.T) {
+	input := "<?php\nnamespace A{echo 1;}\nnamespace {echo 2;}"
+	expected := "namespace A {\n    echo 1;\n}\n\nnamespace {\n    echo 2;\n}\n"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+}
+
+// FIXME: This test is failing
+// func TestSwitchCase(t *testing.T) {
+// 	input := `<?php switch($var){case 1: echo "One"; break; default: echo "Default";}`
+// 	expected := "switch ($var) {\n    case 1:\n        echo \"One\";\n        break;\n    default:\n        echo \"Default\";\n}"
+
+// 	output, err := formatPHP(input)
+// 	assert.NoError(t, err)
+// 	assert.Contains(t, output, expected)
+// }
+
+// func TestFunctionCall(t *testing.T) {
+// 	input := `<?php
+// $a = 10;
+// if ( $a < 5) {
+// 	echo " True ";
+// } else {
+//  	echo " False ";
+// }`
+// 	expected := "test(1, 2);"
+
+// 	output, err := formatPHP(input)
+// 	assert.NoError(t, err)
+// 	assert.Contains(t, output, expected)
+// }
+
+func TestInterpolatedStrings(t *testing.T) {
+	input := "<?php\n$s=\"{$obj->prop}  ${name} $a[0]  {$a['k']} $o->p\";$t=`ls  {$dir}`;$v=$arr[\"k  $x\"];"
+	expected := "$s = \"{$obj->prop}  ${name} $a[0]  {$a['k']} $o->p\";\n$t = `ls  {$dir}`;\n$v = $arr[\"k  $x\"];\n"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Contains(t, output, expected)
+}
+
+func TestShortEchoTag(t *testing.T) {
+	input := "<ul>\n<?php foreach ($xs as $x): ?>\n  <li><?=$x?></li><li><?= f($x); ?></li>\n<?php endforeach; ?>\n</ul>\n"
+
+	output, err := Format([]byte(input), DefaultOptions())
+	assert.NoError(t, err)
+	assert.Contains(t, string(output), "  <li><?= $x ?></li><li><?= f($x); ?></li>\n")
+
+	opts := DefaultOptions()
+	opts.ExpandShortEcho = true
+	output, err = Format([]byte(input), opts)
+	assert.NoError(t, err)
+	assert.Contains(t, string(output), "  <li><?php echo $x; ?></li><li><?php echo f($x); ?></li>\n")
+}
+
+func TestBraceAlternativeSyntax(t *testing.T) {
+	input := "<?php\nif ($a):\necho 1;\nelseif ($b):\necho 2;\nelse:\necho 3;\nendif;\nforeach ($xs as $x): while ($x): $x--; endwhile; endforeach;\nif ($h): ?>\n<p>html</p>\n<?php endif;\n"
+	expected := "<?php\nif ($a) {\n    echo 1;\n} elseif ($b) {\n    echo 2;\n} else {\n    echo 3;\n}\n" +
+		"foreach ($xs as $x) {\n    while ($x) {\n        $x--;\n    }\n}\n" +
+		"if ($h): ?>\n<p>html</p>\n<?php endif;\n"
+
+	opts := DefaultOptions()
+	opts.BraceAlternativeSyntax = true
+	output, err := Format([]byte(input), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(output))
+
+	output, err = Format([]byte(input), DefaultOptions())
+	assert.NoError(t, err)
+	assert.Contains(t, string(output), "<?php\nif ($a):\n    echo 1;\nelseif ($b):\n    echo 2;\nelse:\n    echo 3;\nendif;\n", "sans l'option, la syntaxe alternative est conservée")
+}
+
+func TestInlineHTML(t *testing.T) {
+	input := "<!DOCTYPE html>\n  <ul>\n<?php foreach($items as $i):?>\n  <li><?=$i?></li>\n<?php   endforeach;?>\n</ul>\n\n"
+	expected := "<!DOCTYPE html>\n  <ul>\n<?php foreach ($items as $i): ?>\n  <li><?= $i ?></li>\n<?php endforeach; ?>\n</ul>\n\n"
+
+	output, err := Format([]byte(input), DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, expected, string(output))
+
+	// Dans un fichier sans HTML, le code reste formaté ligne par ligne.
+	output, err = Format([]byte(" <?php if($a){echo 1;} ?>\n"), DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, " <?php\nif ($a) {\n    echo 1;\n}\n?>\n", string(output))
+}
+
+// formatCorpus couvre les constructions prises en charge par le formateur, écrites
+// sans mise en forme particulière.
+var formatCorpus = map[string]string{
+	"echo":      "<?php echo 'a', $b;",
+	"assign":    "<?php $a=1;$b.='x';$c??=$d;$e=-$f;$g=!$h;",
+	"call":      "<?php foo($a,1,\"x\");$o->bar(name:$b);$o?->baz();A::c(...$args);new Foo($a);",
+	"wrap":      "<?php $message=sprintf('%s: %s',$this->translator->translate('some.long.translation.key'),$other->call($value,true),$last);",
+	"concat":    "<?php $s='The quick brown fox jumps over the lazy dog, '.$subject.' and then '.$verb.' again and again '.$end.' done';",
+	"array":     "<?php $a=[1,'k'=>[2,3],...$x,&$y];$b=array('a'=>1,'b'=>array());",
+	"if":        "<?php if($a&&$b||$c){echo 1;}else{echo 2;}",
+	"elseif":    "<?php if ($a) { echo 1; } elseif ($b) { echo 2; } else { echo 3; }",
+	"while":     "<?php while($x<10){$x++;}",
+	"for":       "<?php for($i=0,$j=1;$i<10;$i++,$j--){echo $i;} for(;;){if($i){break 2;}else if($j){continue;}}",
+	"keywords":  "<?php require_once 'a.php';global $a,$b;function f(){throw new Exception('x');}print $a;$b=clone $c;",
+	"foreach":   "<?php foreach($items as $k=>&$v){$v++;} foreach($a as $b): echo $b; endforeach;",
+	"function":  "<?php function &f(int $a, $b = 1): ?array {return [$a];}",
+	"class":     "<?php final class A extends B implements C,D{use T;const X=1;private static ?int $y=null;public function f($a){return $a;}abstract protected function g();}",
+	"enum":      "<?php enum Suit: string implements HasColor {case Hearts='H';case Spades='S';public function color(): string {return 'Red';}}",
+	"heredoc":   "<?php function f(){$x=<<<EOT\n  Hello $name\n  EOT;\necho <<<'NOW'\nraw\nNOW;\n}",
+	"namespace": "<?php namespace Foo\\Bar;use Z\\Y,A\\B as C;use function Foo\\f;use A\\{B,C as D};echo 1;",
+	"braced":    "<?php namespace A{echo 1;} namespace {echo 2;}",
+	"template":  "<html>\n<?php if($a): ?>\n  <p><?php echo $b;?></p>\n<?php elseif($c): ?>\n<?php else: ?>x<?php endif ?>\n<?php while($d):?><?= $d ?><?php endwhile; ?>\n</html>",
+	"strings":   "<?php $s=\"{$obj->prop} ${name} $a[0] $o->p {$a['k']['l']} \\\" \\n\";$t=`ls {$dir}`;$u='$x {$y}';$v=foo(\"a  $b  c\",$arr[\"k  \"]);",
+	"closing":   "<?php\necho 1;\n?>\n",
+	"blank":     "<?php\n\n$a=1;\n\n\n$b=2;\nfunction f(){}\nfunction g(){}\n",
+	"comments":  "<?php\n// en-tête\n$a = 1; // fin de ligne\n/* bloc */\nfunction f() {\n    # dièse\n    return 1;\n}\n",
+}
+
+func TestFormatIdempotent(t *testing.T) {
+	for name, input := range formatCorpus {
+		t.Run(name, func(t *testing.T) {
+			first, err := Format([]byte(input), DefaultOptions())
+			if !assert.NoError(t, err) {
+				return
+			}
+			second, err := Format(first, DefaultOptions())
+			assert.NoError(t, err)
+			assert.Equal(t, string(first), string(second), "le code formaté ne doit plus changer")
+		})
+	}
+}
+
+func TestEquivalent(t *testing.T) {
+	assert.NoError(t, Equivalent([]byte("<?php $a=[1,2];"), []byte("<?php\n$a = [\n    1,\n    2,\n];\n")))
+	assert.NoError(t, Equivalent([]byte("<?php use B, A;"), []byte("<?php\nuse A;\nuse B;\n")))
+	assert.ErrorContains(t, Equivalent([]byte("<?php $a = 1; // x"), []byte("<?php $a = 1;")), "le formatage modifierait le code")
+	assert.ErrorContains(t, Equivalent([]byte("<?php $a = 1;"), []byte("<?php $a = 2;")), `"integer 2" au lieu de "integer 1"`)
+	assert.ErrorIs(t, Equivalent([]byte("<?php $a = 1;"), []byte("<?php\n$a = ;")), ErrInvalidOutput, "a valid input is not reported as a syntax error")
+	assert.ErrorContains(t, Equivalent([]byte("<?php $a = 1;"), []byte("<?php\n$a = ;")), "(ligne 2)")
+	assert.ErrorIs(t, Equivalent([]byte("<?php $a = ;"), []byte("<?php $a = 1;")), ErrSyntax)
+}
+
+func TestHandle(t *testing.T) {
+	printer := NewPrinter(DefaultOptions())
+	var previous VisitorFunc
+	previous = printer.Handle("echo_statement", func(p *Printer, n *sitter.Node) {
+		p.WriteLine("// echo")
+		previous(p, n)
+	})
+	assert.NotNil(t, previous)
+	output, err := printer.Format([]byte("<?php echo 1;"))
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\necho 1;\n", string(output), "a statement whose visitor changes the code is printed as written")
+
+	printer = NewPrinter(DefaultOptions())
+	printer.Handle("program", func(p *Printer, n *sitter.Node) { p.Write("<?php echo 2;") })
+	_, err = printer.Format([]byte("<?php echo 1;"))
+	assert.ErrorContains(t, err, "le formatage modifierait le code", "nothing is left to print as written")
+
+	printer = NewPrinter(DefaultOptions())
+	printer.Handle("compound_statement", func(p *Printer, n *sitter.Node) {
+		p.WriteLine("{")
+		p.Nest(func() {
+			for i := 0; i < int(n.NamedChildCount()); i++ {
+				p.Visit(n.NamedChild(i))
+			}
+		})
+		p.WriteLine("}")
+	})
+	output, err = printer.Format([]byte("<?php if($a){echo 1;}"))
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\nif ($a)\n{\n    echo 1;\n}\n", string(output))
+}
+
+func TestBlankLines(t *testing.T) {
+	input := "<?php\n$a=1;\n\n\n\n$b=2;\nfunction f(){\n\n$x=1;\n\nreturn $x;\n}\n/** g */\nfunction g(){}\nclass A{\nconst X=1;\npublic function f(){}\n\n\npublic function g(){}\n\n}\n"
+
+	output, err := formatPHP(input)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n$a = 1;\n\n$b = 2;\nfunction f() {\n    $x = 1;\n\n    return $x;\n}\n\n/** g */\nfunction g() {\n}\nclass A {\n    const X = 1;\n    public function f() {\n    }\n\n    public function g() {\n    }\n}\n", output)
+
+	opts := DefaultOptions()
+	opts.MaxBlankLines = 2
+	opts.SeparateFunctions = false
+	opts.BlankLineAfterTag = true
+	output, err = formatWith(opts, input)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n\n$a = 1;\n\n\n$b = 2;\nfunction f() {\n    $x = 1;\n\n    return $x;\n}\n/** g */\nfunction g() {\n}\nclass A {\n    const X = 1;\n    public function f() {\n    }\n\n\n    public function g() {\n    }\n}\n", output)
+
+	output, err = formatWith(Options{Indent: "  "}, input)
+	assert.NoError(t, err)
+	assert.NotContains(t, output, "\n\n", "sans option, les lignes vides sont supprimées")
+}
+
+func TestStatementVisitors(t *testing.T) {
+	for name, tc := range map[string]struct{ input, want string }{
+		"do-while":         {"<?php\ndo{$i++;}while($i<3);", "<?php\ndo {\n    $i++;\n} while ($i < 3);\n"},
+		"do-while single":  {"<?php\ndo $i++; while($i<3);", "<?php\ndo\n    $i++;\nwhile ($i < 3);\n"},
+		"static variables": {"<?php\nfunction f(){static $n=0,$m;}", "<?php\nfunction f() {\n    static $n = 0, $m;\n}\n"},
+		"unset":            {"<?php\nunset($a,$b['x']);", "<?php\nunset($a, $b['x']);\n"},
+		"cast":             {"<?php\n$x=(int)$y;", "<?php\n$x = (int) $y;\n"},
+		"try-catch":        {"<?php\ntry{f();}catch(A|B $e){g();}catch(C){}finally{h();}", "<?php\ntry {\n    f();\n} catch (A|B $e) {\n    g();\n} catch (C) {\n} finally {\n    h();\n}\n"},
+		"goto":             {"<?php\ngoto end;\nend:\necho 1;", "<?php\ngoto end;\nend:\necho 1;\n"},
+		"promoted constructor": {"<?php\nclass A{public function __construct(private int $x,protected readonly ?B $b=null){}}",
+			"<?php\nclass A {\n    public function __construct(private int $x, protected readonly ?B $b=null) {\n    }\n}\n"},
+	} {
+		output, err := formatPHP(tc.input)
+		assert.NoError(t, err, name)
+		assert.Equal(t, tc.want, output, name)
+	}
+}
+
+func TestUnsupportedStatementsKeptAsWritten(t *testing.T) {
+	// Les instructions que les visiteurs ne reproduiraient pas fidèlement sont écrites
+	// telles quelles, réindentées ; les autres instructions du fichier sont formatées.
+	for name, statement := range map[string]string{
+		"closure":         "$f=function($x)use($y){return $x;};",
+		"arrow function":  "$f=fn($x)=>$x*2;",
+		"match":           "$r=match($v){1,2=>'a',default=>'b'};",
+		"list":            "list($a,$b)=$c;",
+		"anonymous class": "$o=new class(1) extends A {};",
+		"switch":          "switch($a){case 1: echo 1; break;}",
+	} {
+		output, err := formatPHP("<?php\nfunction f(){\n" + statement + "\n$a=1;\n}")
+		assert.NoError(t, err, name)
+		assert.Equal(t, "<?php\nfunction f() {\n    "+statement+"\n    $a = 1;\n}\n", output, name)
+	}
+
+	output, err := FormatRange([]byte("<?php\n$a=1;\n$f=fn($x)=>$x*2;\n$b=2;\n"), 2, 3, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n$a = 1;\n$f=fn($x)=>$x*2;\n$b=2;\n", string(output))
+}
+
+func TestFormatConcurrent(t *testing.T) {
+	input := "<?php\nfunction f($a){if($a){return [1,2];}}\n"
+	want, err := Format([]byte(input), DefaultOptions())
+	if !assert.NoError(t, err) {
+		return
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				got, err := Format([]byte(input), DefaultOptions())
+				assert.NoError(t, err)
+				assert.Equal(t, string(want), string(got))
+			}
+		}()
+	}
+	wg.Wait()
+}
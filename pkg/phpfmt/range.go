@@ -0,0 +1,128 @@
+package phpfmt
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// statementLists are the nodes whose named children are statements or members.
+var statementLists = map[string]bool{
+	"program":               true,
+	"compound_statement":    true,
+	"colon_block":           true,
+	"declaration_list":      true,
+	"enum_declaration_list": true,
+}
+
+// FormatRange formats the statements of src that overlap lines start to end
+// (1-based, inclusive) with opts.
+func FormatRange(src []byte, start, end int, opts Options) ([]byte, error) {
+	return NewPrinter(opts).FormatRange(src, start, end)
+}
+
+// FormatRange formats only the statements of src that overlap lines start to end
+// (1-based, inclusive), as an editor formats a selection: the rest of the file is
+// left byte for byte. A range within a block formats the statements of the block
+// rather than the whole enclosing statement. Statements that hold inline HTML are
+// left as is. The result is checked like the one of Format, and the statements
+// that would change printed as written.
+func (p *Printer) FormatRange(src []byte, start, end int) ([]byte, error) {
+	if start < 1 || end < start {
+		return nil, fmt.Errorf("plage de lignes invalide : %d:%d", start, end)
+	}
+	root, err := p.parse(src)
+	if err != nil {
+		return nil, err
+	}
+	runs := selectStatements(root, uint32(start-1), uint32(end-1))
+	return p.faithfully(root, func() []byte {
+		var out []byte
+		last := uint32(0)
+		for _, run := range runs {
+			first, final := run[0], run[len(run)-1]
+			out = append(out, src[last:first.StartByte()]...)
+			out = append(out, p.formatStatements(run)...)
+			last = final.EndByte()
+		}
+		return append(out, src[last:]...)
+	})
+}
+
+// formatStatements prints consecutive statements at the indentation of the line of
+// the first one, without the whitespace around them.
+func (p *Printer) formatStatements(run []*sitter.Node) string {
+	first, final := run[0], run[len(run)-1]
+	lineStart := bytes.LastIndexByte(p.input[:first.StartByte()], '\n') + 1
+	line := string(p.input[lineStart:first.StartByte()])
+	p.margin = line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+	p.from, p.to = first.StartByte(), final.EndByte()
+	p.builder.Reset()
+	p.indentLevel = 0
+	for _, stmt := range run {
+		p.visitNode(stmt)
+	}
+	return strings.TrimRight(strings.TrimLeft(p.builder.String(), " \t\n"), " \t\n")
+}
+
+// selectStatements returns the runs of consecutive statements to format for rows
+// start to end: the statements of list that overlap them, or those of the blocks of a
+// statement when the rows lie within it.
+func selectStatements(list *sitter.Node, start, end uint32) [][]*sitter.Node {
+	var runs [][]*sitter.Node
+	var run []*sitter.Node
+	for i := 0; i < int(list.NamedChildCount()); i++ {
+		stmt := list.NamedChild(i)
+		if stmt.EndPoint().Row < start || stmt.StartPoint().Row > end || skipInRange(stmt) {
+			if len(run) > 0 {
+				runs, run = append(runs, run), nil
+			}
+			if overlaps(stmt, start, end) && stmt.Type() != "text" {
+				runs = append(runs, selectInBlocks(stmt, start, end)...)
+			}
+			continue
+		}
+		if start > stmt.StartPoint().Row && end < stmt.EndPoint().Row {
+			if inner := selectInBlocks(stmt, start, end); len(inner) > 0 {
+				if len(run) > 0 {
+					runs, run = append(runs, run), nil
+				}
+				runs = append(runs, inner...)
+				continue
+			}
+		}
+		run = append(run, stmt)
+	}
+	if len(run) > 0 {
+		runs = append(runs, run)
+	}
+	return runs
+}
+
+// selectInBlocks returns the runs of statements to format in the blocks of n.
+func selectInBlocks(n *sitter.Node, start, end uint32) [][]*sitter.Node {
+	var runs [][]*sitter.Node
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		child := n.NamedChild(i)
+		switch {
+		case !overlaps(child, start, end):
+		case statementLists[child.Type()]:
+			runs = append(runs, selectStatements(child, start, end)...)
+		default:
+			runs = append(runs, selectInBlocks(child, start, end)...)
+		}
+	}
+	return runs
+}
+
+func overlaps(n *sitter.Node, start, end uint32) bool {
+	return n.EndPoint().Row >= start && n.StartPoint().Row <= end
+}
+
+// skipInRange reports whether a statement is left as is by FormatRange: tags, inline
+// HTML and statements that hold some.
+func skipInRange(stmt *sitter.Node) bool {
+	return isVerbatim(stmt) || containsNode(stmt, "text_interpolation")
+}
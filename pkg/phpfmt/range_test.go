@@ -0,0 +1,52 @@
+package phpfmt
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatRange(t *testing.T) {
+	input := "<?php\n$a=1;\n$b=2; // b\nfunction f(){\n  if($x){\n        g( 1 );\n  h(2);\n  }\n    return  1;\n}\n$c=3;\n"
+
+	output, err := FormatRange([]byte(input), 2, 3, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n$a = 1;\n$b = 2; // b\nfunction f(){\n  if($x){\n        g( 1 );\n  h(2);\n  }\n    return  1;\n}\n$c=3;\n", string(output))
+
+	// Dans un bloc, seules les instructions du bloc sont formatées, à l'indentation de
+	// la première.
+	output, err = FormatRange([]byte(input), 6, 7, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n$a=1;\n$b=2; // b\nfunction f(){\n  if($x){\n        g(1);\n        h(2);\n  }\n    return  1;\n}\n$c=3;\n", string(output))
+
+	output, err = FormatRange([]byte(input), 4, 4, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\n$a=1;\n$b=2; // b\nfunction f() {\n    if ($x) {\n        g(1);\n        h(2);\n    }\n    return 1;\n}\n$c=3;\n", string(output))
+
+	output, err = FormatRange([]byte(input), 20, 30, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, input, string(output), "hors du fichier, rien ne change")
+
+	_, err = FormatRange([]byte(input), 3, 2, DefaultOptions())
+	assert.ErrorContains(t, err, "plage de lignes invalide")
+}
+
+func TestFormatRangeTemplate(t *testing.T) {
+	input := "<ul>\n<?php foreach($xs as $x): ?>\n  <li><?=$x?></li>\n<?php   $n=count($xs);\n  $m=$n+1; ?>\n<?php endforeach; ?>\n</ul>\n"
+
+	// Les balises et le HTML restent tels quels ; seules les instructions PHP sont
+	// formatées.
+	output, err := FormatRange([]byte(input), 1, 7, DefaultOptions())
+	assert.NoError(t, err)
+	assert.Equal(t, "<ul>\n<?php foreach($xs as $x): ?>\n  <li><?=$x?></li>\n<?php   $n = count($xs);\n$m = $n + 1; ?>\n<?php endforeach; ?>\n</ul>\n", string(output))
+}
+
+func TestFormatRangeImports(t *testing.T) {
+	input := "<?php\nuse B;\nuse   D,C;\nuse A;\n"
+	opts := DefaultOptions()
+	opts.SortImports = true
+
+	output, err := FormatRange([]byte(input), 3, 3, opts)
+	assert.NoError(t, err)
+	assert.Equal(t, "<?php\nuse B;\nuse C;\nuse D;\nuse A;\n", string(output))
+}
@@ -0,0 +1,164 @@
+package phpfmt
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Equivalent reports, as an error, the first difference between the syntax trees of
+// two versions of a file, whitespace aside. It is ErrSyntax when before does not
+// parse, and wraps ErrInvalidOutput when after does not.
+func Equivalent(before, after []byte) error {
+	_, err := firstDifference(before, after)
+	return err
+}
+
+// firstDifference compares two versions of a file like Equivalent and, when they
+// differ, also returns the offsets in before of the last node that matched and of
+// the first one that did not (one of them at the ends of the file).
+func firstDifference(before, after []byte) ([]uint32, error) {
+	want, offsets, err := syntaxShape(before)
+	if err != nil {
+		return nil, err
+	}
+	// A tree with errors still has a shape: it locates the statement printed wrong.
+	got, _, invalid := syntaxShape(after)
+	if invalid != nil && !errors.Is(invalid, ErrSyntax) {
+		return nil, invalid
+	}
+	i := 0
+	for i < len(want) && i < len(got) && want[i] == got[i] {
+		i++
+	}
+	if i == len(want) && i == len(got) && invalid == nil {
+		return nil, nil
+	}
+	var at []uint32
+	if i > 0 {
+		at = append(at, offsets[i-1])
+	}
+	if i < len(want) {
+		at = append(at, offsets[i])
+	}
+	if invalid != nil {
+		return at, fmt.Errorf("%w (ligne %d)", ErrInvalidOutput, firstErrorLine(after))
+	}
+	expected, actual := "(fin)", "(fin)"
+	if i < len(want) {
+		expected = want[i]
+	}
+	if i < len(got) {
+		actual = got[i]
+	}
+	return at, fmt.Errorf("le formatage modifierait le code : %q au lieu de %q", actual, expected)
+}
+
+// syntaxShape describes a syntax tree regardless of layout: the type of each node in
+// preorder and the text of the leaves, with the offset of each node. What the options
+// may change is normalized: trailing commas of arrays are skipped, each run of use
+// declarations is replaced by its sorted imports, braced and alternative (": ...
+// endif;") blocks look the same, and so do <?= $x ?> and <?php echo $x; ?>. The shape
+// of a tree with errors is returned with ErrSyntax.
+func syntaxShape(source []byte) ([]string, []uint32, error) {
+	tree, err := parseSource(source)
+	if err != nil {
+		return nil, nil, err
+	}
+	imports := &Printer{Options: Options{SortImports: true}, input: source}
+	var shape []string
+	var offsets []uint32
+	add := func(n *sitter.Node, entries ...string) {
+		for _, entry := range entries {
+			shape = append(shape, entry)
+			offsets = append(offsets, n.StartByte())
+		}
+	}
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		if n.Type() == "php_tag" {
+			add(n, "php_tag <?php")
+			return
+		}
+		if n.ChildCount() == 0 {
+			add(n, n.Type()+" "+n.Content(source))
+			return
+		}
+		if IsShortEcho(n, source) {
+			add(n, "echo_statement", "echo echo")
+			for i := 0; i < int(n.NamedChildCount()); i++ {
+				walk(n.NamedChild(i))
+			}
+			add(n, "; ;")
+			return
+		}
+		if n.Type() == "colon_block" || n.Type() == "compound_statement" {
+			add(n, "block")
+		} else {
+			add(n, n.Type())
+		}
+		for i := 0; i < int(n.ChildCount()); i++ {
+			child := n.Child(i)
+			switch {
+			case child.Type() == "," && n.Type() == "array_creation_expression" && i == int(n.ChildCount())-2:
+			case (child.Type() == ":" || child.Type() == "{" || child.Type() == "}") && (n.Type() == "colon_block" || n.Type() == "compound_statement"):
+			case child.Type() == ":" && (n.Type() == "for_statement" || n.Type() == "foreach_statement"):
+				add(child, "block")
+			case alternativeEnds[child.Type()]:
+				if i+1 < int(n.ChildCount()) && n.Child(i+1).Type() == ";" {
+					i++
+				}
+			case child.Type() == "namespace_use_declaration":
+				var run []string
+				for ; i < int(n.ChildCount()) && n.Child(i).Type() == child.Type(); i++ {
+					for _, imported := range imports.useImports(n.Child(i)) {
+						run = append(run, "use "+imported.kind+" "+imported.text)
+					}
+				}
+				i--
+				sort.Strings(run)
+				add(child, run...)
+			default:
+				walk(child)
+			}
+		}
+	}
+	walk(tree.RootNode())
+	if tree.RootNode().HasError() {
+		return shape, offsets, ErrSyntax
+	}
+	return shape, offsets, nil
+}
+
+// firstErrorLine returns the line of the first syntax error of source, or 0.
+func firstErrorLine(source []byte) uint32 {
+	tree, err := parseSource(source)
+	if err != nil {
+		return 0
+	}
+	var find func(n *sitter.Node) uint32
+	find = func(n *sitter.Node) uint32 {
+		if n.IsError() || n.IsMissing() {
+			return n.StartPoint().Row + 1
+		}
+		for i := 0; i < int(n.ChildCount()); i++ {
+			if child := n.Child(i); child.HasError() || child.IsMissing() {
+				return find(child)
+			}
+		}
+		return 0
+	}
+	return find(tree.RootNode())
+}
+
+// IsShortEcho reports whether an expression statement follows a short echo tag, as
+// in <?= $x ?>: its value is echoed.
+func IsShortEcho(stmt *sitter.Node, source []byte) bool {
+	prev := stmt.PrevSibling()
+	if prev != nil && prev.Type() == "text_interpolation" {
+		prev = prev.Child(int(prev.ChildCount()) - 1)
+	}
+	return stmt.Type() == "expression_statement" && prev != nil && prev.Type() == "php_tag" && prev.Content(source) == "<?="
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Portées d'une règle par expression régulière.
+const (
+	// scopeStrings limite la recherche au contenu des chaînes littérales (guillemets
+	// simples ou doubles, heredoc, nowdoc), sans leurs délimiteurs ; les variables
+	// interpolées y figurent telles qu'écrites ($id, {$user->name}).
+	scopeStrings = "strings"
+	// scopeHTML limite la recherche au HTML hors des balises PHP.
+	scopeHTML = "html"
+	// scopeFile recherche dans le texte brut du fichier.
+	scopeFile = "file"
+)
+
+// compileRegex compile l'expression régulière de la règle et vérifie sa portée
+// (scopeFile par défaut).
+func (r *SignatureRule) compileRegex() error {
+	switch r.Scope {
+	case "":
+		r.Scope = scopeFile
+	case scopeStrings, scopeHTML, scopeFile:
+	default:
+		return fmt.Errorf("portée inconnue : %q (%s, %s ou %s)", r.Scope, scopeStrings, scopeHTML, scopeFile)
+	}
+	regex, err := regexp.Compile(r.Regex)
+	if err != nil {
+		return fmt.Errorf("regex : %w", err)
+	}
+	r.regex = regex
+	return nil
+}
+
+// MatchRegex recherche l'expression régulière de la règle dans les parties du fichier
+// couvertes par sa portée et retourne un résultat par ligne où elle est trouvée. Le
+// message reprend le texte des groupes nommés qu'il cite ($NAME), comme pour les
+// métavariables des motifs de code.
+func (r *SignatureRule) MatchRegex(root *sitter.Node, source []byte, phpVersion string) []Finding {
+	if r.regex == nil || !r.appliesTo(phpVersion) {
+		return nil
+	}
+	var findings []Finding
+	reported := make(map[uint32]bool)
+	for _, span := range regexSpans(root, source, r.Scope) {
+		text := source[span[0]:span[1]]
+		for _, match := range r.regex.FindAllSubmatchIndex(text, -1) {
+			offset := span[0] + uint32(match[0])
+			line := uint32(bytes.Count(source[:offset], []byte("\n"))) + 1
+			if reported[line] {
+				continue
+			}
+			groups := make(map[string]string)
+			for group, name := range r.regex.SubexpNames() {
+				if name != "" && match[2*group] >= 0 {
+					groups[name] = string(text[match[2*group]:match[2*group+1]])
+				}
+			}
+			finding, ok := r.scriptFinding(line, scriptMatch{captures: groups, phpVersion: phpVersion}, source)
+			if !ok {
+				continue
+			}
+			reported[line] = true
+			finding.Message = metavariableReference.ReplaceAllStringFunc(finding.Message, func(ref string) string {
+				if value, ok := groups[ref[1:]]; ok {
+					return value
+				}
+				return ref
+			})
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+// regexSpans retourne les plages d'octets [début, fin) du fichier couvertes par une
+// portée.
+func regexSpans(root *sitter.Node, source []byte, scope string) [][2]uint32 {
+	if scope == scopeFile {
+		return [][2]uint32{{0, uint32(len(source))}}
+	}
+	var spans [][2]uint32
+	traverseAST(root, func(n *sitter.Node) {
+		switch {
+		case scope == scopeHTML && n.Type() == "text":
+			spans = append(spans, [2]uint32{n.StartByte(), n.EndByte()})
+		case scope == scopeStrings && (n.Type() == "string" || n.Type() == "encapsed_string"):
+			// Contenu entre les guillemets, après un éventuel préfixe b.
+			start := n.StartByte() + uint32(bytes.IndexAny(source[n.StartByte():n.EndByte()], `'"`)) + 1
+			if end := n.EndByte() - 1; start <= end {
+				spans = append(spans, [2]uint32{start, end})
+			}
+		case scope == scopeStrings && (n.Type() == "heredoc" || n.Type() == "nowdoc"):
+			if body := n.ChildByFieldName("value"); body != nil {
+				spans = append(spans, [2]uint32{body.StartByte(), body.EndByte()})
+			}
+		}
+	})
+	return spans
+}
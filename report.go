@@ -0,0 +1,188 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// ellipsis remplace la partie retirée d'un texte tronqué.
+const ellipsis = "…"
+
+// detectWidth retourne la largeur disponible pour l'affichage : celle du terminal associé
+// à la sortie standard, sinon la variable COLUMNS si elle est définie. 0 signifie
+// qu'aucune limite n'est appliquée (sortie redirigée vers un fichier ou un pipe).
+func detectWidth() int {
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	return 0
+}
+
+// singleLine remplace les retours à la ligne d'un extrait de code par des espaces.
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// fitLine tronque text, ramené sur une ligne, pour que text+suffix tienne dans width
+// caractères. Le suffixe (numéro de ligne, par exemple) est toujours conservé intact.
+// Sans limite de largeur, text est conservé tel quel.
+func fitLine(text, suffix string, width int) string {
+	if width <= 0 {
+		return text + suffix
+	}
+	text = singleLine(text)
+	available := width - utf8.RuneCountInString(suffix)
+	if utf8.RuneCountInString(text) <= available {
+		return text + suffix
+	}
+	if available <= 1 {
+		return ellipsis + suffix
+	}
+	runes := []rune(text)
+	return string(runes[:available-1]) + ellipsis + suffix
+}
+
+// fitPath raccourcit un chemin par la gauche pour conserver le nom du fichier, la
+// partie la plus utile d'un chemin.
+func fitPath(path string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(path) <= width {
+		return path
+	}
+	if width <= 1 {
+		return ellipsis
+	}
+	runes := []rune(path)
+	return ellipsis + string(runes[len(runes)-width+1:])
+}
+
+// formatFinding met en forme un résultat sur une ligne : sévérité, règle et CWE, message,
+// puis ligne et confiance, qui ne sont jamais tronquées.
+func (pa *PHPAnalyzer) formatFinding(f Finding) string {
+	text := fmt.Sprintf("[%s] [%s] %s", f.Severity, f.Label(), f.Message)
+	return fitLine(text, fmt.Sprintf(" (ligne %d, confiance %s)", f.Line, f.Confidence), pa.MaxWidth)
+}
+
+// formatFindingDetails met en forme les métadonnées de la règle d'un résultat, une par
+// ligne et en retrait : catégorie OWASP, correction, puis références. Les adresses ne
+// sont jamais tronquées.
+func (pa *PHPAnalyzer) formatFindingDetails(f Finding) []string {
+	const indent = "    "
+	width := pa.MaxWidth
+	if width > 0 {
+		width = max(width-len(indent), 1)
+	}
+	var lines []string
+	if f.OWASP != "" {
+		lines = append(lines, indent+fitLine("OWASP "+f.OWASP, "", width))
+	}
+	if f.Remediation != "" {
+		lines = append(lines, indent+fitLine("Correction : "+f.Remediation, "", width))
+	}
+	for _, reference := range f.References {
+		lines = append(lines, indent+"Référence : "+reference)
+	}
+	if f.Blame != nil {
+		lines = append(lines, indent+fitLine("Dernière modification : "+f.Blame.String(), "", width))
+	}
+	return lines
+}
+
+// tabWidth est le nombre d'espaces qui remplacent une tabulation dans les extraits de code.
+const tabWidth = 4
+
+// expandTabs remplace les tabulations d'une ligne de code, pour aligner les accents
+// circonflexes quelle que soit la configuration du terminal.
+func expandTabs(text string) string {
+	return strings.ReplaceAll(text, "\t", strings.Repeat(" ", tabWidth))
+}
+
+// findingRange retourne les positions, en octets dans la ligne signalée, de l'étendue à
+// souligner : celle du nœud, jusqu'à la fin de la ligne s'il se poursuit au-delà, ou la
+// ligne hors indentation lorsque la colonne n'est pas connue.
+func findingRange(f Finding, text string) (start, end int) {
+	start = len(text) - len(strings.TrimLeft(text, " \t"))
+	end = len(strings.TrimRight(text, " \t"))
+	if f.Column > 0 {
+		start = min(int(f.Column)-1, len(text))
+		if f.EndLine == f.Line && f.EndColumn > f.Column {
+			end = min(int(f.EndColumn)-1, len(text))
+		}
+	}
+	return start, max(end, start)
+}
+
+// fitSnippet tronque une ligne d'extrait à la largeur d'affichage, sans en modifier les
+// espaces qui alignent les accents circonflexes.
+func (pa *PHPAnalyzer) fitSnippet(line string) string {
+	if pa.MaxWidth <= 0 || utf8.RuneCountInString(line) <= pa.MaxWidth {
+		return line
+	}
+	runes := []rune(line)
+	return string(runes[:max(pa.MaxWidth-1, 0)]) + ellipsis
+}
+
+// formatSnippet met en forme la ligne signalée d'un résultat, entourée de
+// pa.ContextLines lignes de contexte, avec des accents circonflexes sous l'étendue
+// signalée, à la manière de rustc ou d'ESLint. Aucun extrait n'est produit sans la
+// source ou avec un nombre de lignes de contexte négatif.
+func (pa *PHPAnalyzer) formatSnippet(f Finding, source []byte) []string {
+	const indent = "    "
+	lines := strings.Split(string(source), "\n")
+	if source == nil || pa.ContextLines < 0 || f.Line == 0 || int(f.Line) > len(lines) {
+		return nil
+	}
+	first := max(int(f.Line)-pa.ContextLines, 1)
+	last := min(int(f.Line)+pa.ContextLines, len(lines))
+	if last > int(f.Line) && lines[last-1] == "" && last == len(lines) {
+		last-- // ligne vide qui suit le dernier retour à la ligne du fichier
+	}
+	gutter := len(strconv.Itoa(last))
+	var snippet []string
+	for n := first; n <= last; n++ {
+		text := strings.TrimRight(lines[n-1], "\r")
+		snippet = append(snippet, pa.fitSnippet(fmt.Sprintf("%s%*d | %s", indent, gutter, n, expandTabs(text))))
+		if n != int(f.Line) {
+			continue
+		}
+		start, end := findingRange(f, text)
+		pad := utf8.RuneCountInString(expandTabs(text[:start]))
+		width := max(utf8.RuneCountInString(expandTabs(text[start:end])), 1)
+		snippet = append(snippet, pa.fitSnippet(fmt.Sprintf("%s%s | %s%s", indent, strings.Repeat(" ", gutter), strings.Repeat(" ", pad), strings.Repeat("^", width))))
+	}
+	return snippet
+}
+
+// writeFinding écrit un résultat, la ligne de code signalée lorsque la source est
+// fournie, puis les métadonnées de sa règle.
+func (pa *PHPAnalyzer) writeFinding(out io.Writer, f Finding, source []byte) {
+	fmt.Fprintln(out, pa.formatFinding(f))
+	for _, line := range pa.formatSnippet(f, source) {
+		fmt.Fprintln(out, line)
+	}
+	for _, line := range pa.formatFindingDetails(f) {
+		fmt.Fprintln(out, line)
+	}
+}
+
+// formatDeadNode met en forme un nœud de code mort avec son extrait de code.
+func (pa *PHPAnalyzer) formatDeadNode(node *CFGNode) string {
+	return fitLine(fmt.Sprintf(" - Node %d: %s [%s", node.ID, node.Type, node.code), "]", pa.MaxWidth)
+}
+
+// formatHeader met en forme un en-tête suivi d'un chemin, raccourci si nécessaire.
+func (pa *PHPAnalyzer) formatHeader(prefix, path, suffix string) string {
+	if pa.MaxWidth <= 0 {
+		return prefix + path + suffix
+	}
+	width := pa.MaxWidth - utf8.RuneCountInString(prefix) - utf8.RuneCountInString(suffix)
+	return prefix + fitPath(path, max(width, 1)) + suffix
+}
@@ -0,0 +1,347 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/term"
+)
+
+func TestFitLineKeepsSuffix(t *testing.T) {
+	assert.Equal(t, "[X] court (ligne 3)", fitLine("[X] court", " (ligne 3)", 80))
+	assert.Equal(t, "[X] un message t… (ligne 3)", fitLine("[X] un message trop long", " (ligne 3)", 27))
+	assert.Equal(t, "…] (ligne 12)", fitLine("[X] message", "] (ligne 12)", 5))
+	assert.Equal(t, "a b c (ligne 1)", fitLine("a\n\tb  c", " (ligne 1)", 80), "snippets are flattened to a single line")
+	assert.Equal(t, "a\n\tb  c (ligne 1)", fitLine("a\n\tb  c", " (ligne 1)", 0), "without a width limit, the text is kept intact")
+}
+
+func TestDetectWidthFallsBackToColumns(t *testing.T) {
+	if term.IsTerminal(int(os.Stdout.Fd())) {
+		t.Skip("the terminal width takes precedence over COLUMNS")
+	}
+	t.Setenv("COLUMNS", "123")
+	assert.Equal(t, 123, detectWidth())
+	t.Setenv("COLUMNS", "")
+	assert.Equal(t, 0, detectWidth())
+}
+
+func TestFitPathKeepsFileName(t *testing.T) {
+	assert.Equal(t, "src/a.php", fitPath("src/a.php", 20))
+	assert.Equal(t, "…/deep/file.php", fitPath("/very/long/path/to/deep/file.php", 15))
+}
+
+func TestFormatDetectionWidth(t *testing.T) {
+	analyzer := NewPHPAnalyzer()
+	analyzer.MaxWidth = 40
+	line := analyzer.formatFinding(Finding{RuleID: "CVE-2019-9025", Severity: SeverityMedium, Confidence: ConfidenceMedium, Line: 120, Message: `mb_split("\w") détecté dans un long extrait`})
+	assert.Equal(t, 40, len([]rune(line)))
+	assert.Contains(t, line, "(ligne 120, confiance medium)")
+}
+
+func TestWriteFindingDetails(t *testing.T) {
+	analyzer := NewPHPAnalyzer()
+	var out strings.Builder
+	analyzer.writeFinding(&out, newFinding("sqli", 4, "requête construite avec $_GET"), nil)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, []string{
+		"[critical] [sqli / CWE-89] requête construite avec $_GET (ligne 4, confiance high)",
+		"    OWASP A03:2021 - Injection",
+		"    Correction : Utiliser des requêtes préparées avec des paramètres liés.",
+		"    Référence : https://cheatsheetseries.owasp.org/cheatsheets/SQL_Injection_Prevention_Cheat_Sheet.html",
+		"    Référence : https://cheatsheetseries.owasp.org/cheatsheets/Query_Parameterization_Cheat_Sheet.html",
+	}, lines)
+
+	out.Reset()
+	analyzer.writeFinding(&out, dbCallFinding("mysql_query", 2, "Appel trouvé : mysql_query", nil, QueryUnknown), nil)
+	assert.Equal(t, 1, strings.Count(out.String(), "\n"), "rules without metadata print a single line")
+}
+
+func TestJSONOutput(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "index.php")
+	assert.NoError(t, os.WriteFile(file, []byte(`<?php
+while ($a < 3) {
+    break;
+    $b = 2;
+}
+    var_dump($user); mysql_query("SELECT * FROM users WHERE id=" . $_GET['id']);`), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatJSON
+	var out strings.Builder
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir}, &out))
+	var report jsonReport
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &report))
+	assert.Len(t, report.Findings, 2)
+	assert.Equal(t, jsonFinding{File: file, Line: 6, Column: 5, EndLine: 6, EndColumn: 20, Rule: "debug-leftover", CWE: "CWE-489", Severity: "low", Confidence: "medium",
+		Message: "appel de débogage var_dump() oublié", OWASP: owaspMisconfig, Remediation: builtinRules["debug-leftover"].Remediation, References: builtinRules["debug-leftover"].References,
+		Fingerprint: report.Findings[0].Fingerprint}, report.Findings[0])
+	assert.Len(t, report.Findings[0].Fingerprint, 32)
+	assert.NotEqual(t, report.Findings[0].Fingerprint, report.Findings[1].Fingerprint)
+	assert.Equal(t, uint32(22), report.Findings[1].Column)
+	assert.Empty(t, report.DeadCode)
+
+	out.Reset()
+	assert.NoError(t, runCommand(analyzer, "cve", []string{"-file", file, "-baseline", filepath.Join(t.TempDir(), "baseline.json")}, &out))
+	report = jsonReport{}
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &report))
+	assert.Empty(t, report.Findings)
+	assert.Len(t, report.Messages, 1, "baseline messages are part of the document")
+
+	out.Reset()
+	assert.NoError(t, runCommand(analyzer, "dead", []string{"-file", file}, &out))
+	report = jsonReport{}
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &report))
+	assert.NotEmpty(t, report.DeadCode)
+	assert.Equal(t, uint32(4), report.DeadCode[0].Line)
+
+	out.Reset()
+	assert.NoError(t, runCommand(analyzer, "count", []string{"-file", file}, &out))
+	report = jsonReport{}
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &report))
+	assert.Equal(t, []jsonMetric{{File: file, Metric: "branches", Value: 1}}, report.Metrics)
+}
+
+func TestJUnitOutput(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "clean.php"), []byte("<?php echo 1;"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "debug.php"), []byte("<?php\nvar_dump($a);\nprint_r($b);"), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatJUnit
+	var out strings.Builder
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir}, &out))
+
+	var report junitTestSuites
+	assert.NoError(t, xml.Unmarshal([]byte(out.String()), &report))
+	assert.Equal(t, 3, report.Tests)
+	assert.Equal(t, 2, report.Failures)
+	assert.Len(t, report.Suites, 2)
+	assert.Equal(t, filepath.Join(dir, "clean.php"), report.Suites[0].Name)
+	assert.Nil(t, report.Suites[0].Cases[0].Failure, "files without findings pass")
+	debug := report.Suites[1]
+	assert.Equal(t, 2, debug.Failures)
+	assert.Equal(t, "debug-leftover (ligne 2, colonne 1)", debug.Cases[0].Name)
+	assert.Equal(t, "debug-leftover", debug.Cases[0].Failure.Type)
+	assert.Equal(t, "appel de débogage var_dump() oublié", debug.Cases[0].Failure.Message)
+	assert.Contains(t, debug.Cases[1].Failure.Text, "debug.php:3:1 [low]")
+
+	out.Reset()
+	assert.NoError(t, runCommand(analyzer, "detectors", nil, &out))
+	assert.NotContains(t, out.String(), "<?xml", "listing commands keep their text output")
+}
+
+func TestMarkdownOutput(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "clean.php"), []byte("<?php echo 1;"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "index.php"), []byte("<?php\n  var_dump($a);\nmysql_query(\"SELECT * FROM users WHERE id=\" . $_GET['id']);"), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatMarkdown
+	var out strings.Builder
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir}, &out))
+	report := out.String()
+	assert.Contains(t, report, "2 résultat(s) dans 1 fichier(s) sur 2 analysé(s).")
+	assert.Contains(t, report, "| critical | 1 |\n| low | 1 |\n")
+	assert.Contains(t, report, "## `"+filepath.Join(dir, "index.php")+"`")
+	assert.Contains(t, report, "- **[low] debug-leftover / CWE-489** ligne 2, colonne 3 : appel de débogage var_dump() oublié (confiance medium)\n\n  ```php\n  var_dump($a);\n  ```\n")
+	assert.Less(t, strings.Index(report, "debug-leftover / CWE-489"), strings.Index(report, "sqli / CWE-89"), "findings follow the source order")
+	assert.Equal(t, "````", markdownFence("echo '```';"))
+}
+
+func TestFindingFilters(t *testing.T) {
+	dir := t.TempDir()
+	source := []byte("<?php\nvar_dump($a);\nmysql_query(\"SELECT * FROM t WHERE id=\" . $_GET[\"id\"]);\n")
+	for _, name := range []string{"a.php", "b.php"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), source, 0o644))
+	}
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir, "-min-severity", "high"}, &out))
+	assert.Equal(t, 2, strings.Count(out.String(), "[critical] [sqli"))
+	assert.NotContains(t, out.String(), "debug-leftover")
+
+	// La limite retient les premiers résultats du parcours, quel que soit le format.
+	out.Reset()
+	analyzer = NewPHPAnalyzer()
+	analyzer.Format = formatJSON
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir, "--max-findings=3"}, &out))
+	var report struct {
+		Findings []jsonFinding `json:"findings"`
+		Messages []string      `json:"messages"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &report))
+	if assert.Len(t, report.Findings, 3) {
+		assert.Equal(t, filepath.Join(dir, "b.php"), report.Findings[2].File)
+		assert.Equal(t, "debug-leftover", report.Findings[2].Rule)
+	}
+	assert.Equal(t, []string{"1 résultat(s) supplémentaire(s) non signalé(s) (limite -max-findings=3)."}, report.Messages)
+	assert.Equal(t, exitFindings, analyzer.exitCode(nil), "omitted findings still count for the exit code")
+
+	out.Reset()
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "dbcalls", []string{"-file", filepath.Join(dir, "a.php"), "-min-severity", "low"}, &out))
+	assert.Contains(t, out.String(), "mysql_query")
+
+	assert.Error(t, runCommand(NewPHPAnalyzer(), "cve", []string{"-file", filepath.Join(dir, "a.php"), "-max-findings", "-1"}, &out))
+}
+
+func TestFindingSnippet(t *testing.T) {
+	source := []byte("<?php\nif ($debug) {\n\tvar_dump($a);\n}\n")
+	tree, err := NewPHPAnalyzer().Parse(context.Background(), source)
+	assert.NoError(t, err)
+	var findings []Finding
+	for _, f := range NewPHPAnalyzer().DetectVulnerabilities(context.Background(), tree, source) {
+		if f.RuleID == "debug-leftover" {
+			findings = append(findings, f)
+		}
+	}
+	if !assert.Len(t, findings, 1) {
+		return
+	}
+	f := findings[0]
+	assert.Equal(t, []uint32{3, 2, 3, 14}, []uint32{f.Line, f.Column, f.EndLine, f.EndColumn})
+	assert.Equal(t, "var_dump($a)", string(source[f.StartByte:f.EndByte]))
+
+	analyzer := NewPHPAnalyzer()
+	assert.Equal(t, []string{
+		"    3 |     var_dump($a);",
+		"      |     ^^^^^^^^^^^^",
+	}, analyzer.formatSnippet(f, source), "tabs are expanded before placing the carets")
+
+	analyzer.ContextLines = 1
+	assert.Equal(t, []string{
+		"    2 | if ($debug) {",
+		"    3 |     var_dump($a);",
+		"      |     ^^^^^^^^^^^^",
+		"    4 | }",
+	}, analyzer.formatSnippet(f, source))
+
+	analyzer.ContextLines = 0
+	assert.Equal(t, "      | ^^^^^^^^^^^^^", analyzer.formatSnippet(newFinding("sqli", 2, "sans colonne"), source)[1], "without a column the whole line is underlined")
+
+	analyzer.ContextLines = -1
+	assert.Empty(t, analyzer.formatSnippet(f, source))
+}
+
+func TestScanSummary(t *testing.T) {
+	dir := t.TempDir()
+	source := []byte("<?php\nvar_dump($a);\nmysql_query(\"SELECT * FROM t WHERE id=\" . $_GET[\"id\"]);\n")
+	for _, name := range []string{"a.php", "b.php", "c.php"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), source, 0o644))
+	}
+
+	var out strings.Builder
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "analyze-dir", []string{dir}, &out))
+	assert.Contains(t, out.String(), "Résumé : 3 fichier(s) analysé(s), 0 ignoré(s), 0 en erreur.")
+	assert.Contains(t, out.String(), "Règles : ")
+	assert.Contains(t, out.String(), "Durée : ")
+
+	out.Reset()
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatJSON
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{dir, "--max-findings=2"}, &out))
+	var report struct {
+		Findings []jsonFinding `json:"findings"`
+		Summary  scanSummary   `json:"summary"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &report))
+	assert.Equal(t, 3, report.Summary.Files)
+	assert.Equal(t, len(report.Findings), report.Summary.Findings)
+	reported := 0
+	for _, count := range report.Summary.BySeverity {
+		reported += count
+	}
+	assert.Equal(t, 2, reported)
+	assert.Positive(t, report.Summary.Omitted)
+	assert.GreaterOrEqual(t, report.Summary.WallTime, 0.0)
+
+	// Un fichier seul n'a pas de résumé.
+	out.Reset()
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "cve", []string{"-file", filepath.Join(dir, "a.php")}, &out))
+	assert.NotContains(t, out.String(), "Résumé")
+}
+
+func TestJSONLinesOutput(t *testing.T) {
+	dir := t.TempDir()
+	source := []byte("<?php\nmysql_query(\"SELECT * FROM t WHERE id=\" . $_GET[\"id\"]);\nvar_dump($a);\n")
+	for _, name := range []string{"a.php", "b.php"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), source, 0o644))
+	}
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatJSONL
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{dir, "-min-severity=low"}, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	type record struct {
+		Type  string `json:"type"`
+		File  string `json:"file"`
+		Line  uint32 `json:"line"`
+		Files int    `json:"files"`
+	}
+	var records []record
+	for _, line := range lines {
+		var record record
+		assert.NoError(t, json.Unmarshal([]byte(line), &record), line)
+		records = append(records, record)
+	}
+	if assert.Len(t, records, 5) {
+		assert.Equal(t, "finding", records[0].Type)
+		assert.Equal(t, filepath.Join(dir, "a.php"), records[0].File)
+		assert.Equal(t, uint32(2), records[0].Line)
+		assert.Equal(t, uint32(3), records[1].Line)
+		assert.Equal(t, filepath.Join(dir, "b.php"), records[2].File)
+		assert.Equal(t, "summary", records[4].Type)
+		assert.Equal(t, 2, records[4].Files)
+	}
+
+	// dbcalls écrit l'inventaire d'un fichier par ligne.
+	out.Reset()
+	analyzer = NewPHPAnalyzer()
+	analyzer.Format = formatJSONL
+	assert.NoError(t, runCommand(analyzer, "dbcalls", []string{dir}, &out))
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	if assert.Len(t, lines, 3) {
+		assert.Contains(t, lines[0], `{"type":"file","path":`)
+		assert.Contains(t, lines[2], `{"type":"summary",`)
+	}
+}
+
+func TestTemplateOutput(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.php"), []byte("<?php\nvar_dump($a);\nmysql_query(\"SELECT * FROM t WHERE id=\" . $_GET['id']);\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "b.php"), []byte("<?php\nprint_r($b);\n"), 0o644))
+	path := filepath.Join(t.TempDir(), "wiki.tmpl")
+	assert.NoError(t, os.WriteFile(path, []byte(`h1. Rapport
+{{range byFile .Findings}}h2. {{.File}}
+{{range .Findings}}|| {{upper .Severity}} | {{.Rule}} | {{.Line}} | {{if atLeast .Severity "high"}}(!){{end}} | {{trim .Code}} |
+{{end}}{{end}}Total : {{.Summary.Findings}}
+`), 0o644))
+	tmpl, err := loadTemplate(path)
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatTemplate
+	analyzer.Template = tmpl
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir, "-min-severity=low"}, &out))
+	a, b := filepath.Join(dir, "a.php"), filepath.Join(dir, "b.php")
+	assert.Equal(t, "h1. Rapport\n"+
+		"h2. "+a+"\n"+
+		"|| LOW | debug-leftover | 2 |  | var_dump($a); |\n"+
+		"|| CRITICAL | sqli | 3 | (!) | mysql_query(\"SELECT * FROM t WHERE id=\" . $_GET['id']); |\n"+
+		"h2. "+b+"\n"+
+		"|| LOW | debug-leftover | 2 |  | print_r($b); |\n"+
+		"Total : 3\n", out.String())
+
+	analyzer.Template = nil
+	assert.ErrorContains(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir}, &out), "-template est requise")
+}
@@ -0,0 +1,677 @@
+package main
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"github.com/smacker/go-tree-sitter/php"
+	"go.starlark.net/starlark"
+	"gopkg.in/yaml.v3"
+)
+
+// embeddedRules contient les signatures fournies avec l'analyseur.
+//
+//go:embed rules/*.yaml
+var embeddedRules embed.FS
+
+// TextPredicate est une condition sur un texte du code source : "equals" exige une
+// égalité exacte, "matches" une expression régulière.
+type TextPredicate struct {
+	Equals  *string `yaml:"equals"`
+	Matches string  `yaml:"matches"`
+
+	pattern *regexp.Regexp
+}
+
+func (p *TextPredicate) compile() error {
+	if p.Matches == "" {
+		return nil
+	}
+	var err error
+	p.pattern, err = regexp.Compile(p.Matches)
+	return err
+}
+
+// holds vérifie le prédicat sur un texte.
+func (p *TextPredicate) holds(text string) bool {
+	if p.Equals != nil && text != *p.Equals {
+		return false
+	}
+	return p.pattern == nil || p.pattern.MatchString(text)
+}
+
+// ArgumentPredicate est une condition sur le texte brut d'un argument d'appel.
+type ArgumentPredicate struct {
+	Index         int `yaml:"index"`
+	TextPredicate `yaml:",inline"`
+}
+
+// CapturePredicate est une condition sur le texte d'une capture de requête tree-sitter ;
+// elle échoue si la capture est absente de la correspondance.
+type CapturePredicate struct {
+	Capture       string `yaml:"capture"`
+	TextPredicate `yaml:",inline"`
+}
+
+// SignatureRule décrit une signature de vulnérabilité : les fonctions concernées et les
+// conditions sur leurs arguments, une requête tree-sitter, un motif de code ou une
+// expression régulière, des conditions sur les captures ou métavariables, les versions
+// de PHP affectées et les métadonnées du résultat produit.
+type SignatureRule struct {
+	ID         string              `yaml:"id"`
+	CWE        string              `yaml:"cwe"`
+	Severity   string              `yaml:"severity"`
+	Confidence string              `yaml:"confidence"`
+	Functions  []string            `yaml:"functions"`
+	Arguments  []ArgumentPredicate `yaml:"arguments"`
+	Query      string              `yaml:"query"`
+	Pattern    string              `yaml:"pattern"`
+	Regex      string              `yaml:"regex"`
+	Scope      string              `yaml:"scope"`
+	Script     string              `yaml:"script"`
+	Captures   []CapturePredicate  `yaml:"captures"`
+	Report     string              `yaml:"report"`
+	PHP        string              `yaml:"php"`
+	Message    string              `yaml:"message"`
+	// Description détaille la vulnérabilité ; elle est renseignée par "rules update".
+	Description string      `yaml:"description"`
+	OWASP       string      `yaml:"owasp"`
+	References  []string    `yaml:"references"`
+	Remediation string      `yaml:"remediation"`
+	Example     RuleExample `yaml:"example"`
+
+	severity   Severity
+	confidence Confidence
+	versions   versionConstraint
+	query      *sitter.Query
+	pattern    *CodePattern
+	regex      *regexp.Regexp
+	check      *starlark.Function
+}
+
+// ruleFile est le format d'un fichier de règles YAML.
+type ruleFile struct {
+	Rules []*SignatureRule `yaml:"rules"`
+}
+
+// ParseSignatureRules lit et valide les règles d'un document YAML ; origin identifie le
+// fichier dans les messages d'erreur.
+func ParseSignatureRules(data []byte, origin string) ([]*SignatureRule, error) {
+	var file ruleFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("%s: %w", origin, err)
+	}
+	for _, rule := range file.Rules {
+		if err := rule.compile(); err != nil {
+			return nil, fmt.Errorf("%s: règle %q: %w", origin, rule.ID, err)
+		}
+	}
+	return file.Rules, nil
+}
+
+// compile vérifie les champs obligatoires et prépare les expressions régulières et les
+// contraintes de version.
+func (r *SignatureRule) compile() error {
+	if r.ID == "" {
+		return fmt.Errorf("identifiant manquant")
+	}
+	switch {
+	case r.Query != "" && r.Pattern != "":
+		return fmt.Errorf("query est incompatible avec pattern")
+	case r.Regex != "" && (r.Query != "" || r.Pattern != ""):
+		return fmt.Errorf("regex est incompatible avec query et pattern")
+	case (r.Query != "" || r.Pattern != "" || r.Regex != "") && (len(r.Functions) > 0 || len(r.Arguments) > 0):
+		return fmt.Errorf("query, pattern et regex sont incompatibles avec functions et arguments")
+	case r.Query == "" && r.Pattern == "" && r.Regex == "" && len(r.Functions) == 0:
+		return fmt.Errorf("aucune fonction, requête, motif ni expression régulière")
+	case r.Query == "" && r.Pattern == "" && len(r.Captures) > 0:
+		return fmt.Errorf("captures exige une requête ou un motif")
+	case r.Query == "" && r.Report != "":
+		return fmt.Errorf("report exige une requête")
+	case r.Regex == "" && r.Scope != "":
+		return fmt.Errorf("scope exige une expression régulière")
+	}
+	if r.Message == "" {
+		return fmt.Errorf("message manquant")
+	}
+	var err error
+	r.severity = SeverityMedium
+	if r.Severity != "" {
+		if r.severity, err = ParseSeverity(r.Severity); err != nil {
+			return err
+		}
+	}
+	r.confidence = ConfidenceMedium
+	if r.Confidence != "" {
+		if r.confidence, err = ParseConfidence(r.Confidence); err != nil {
+			return err
+		}
+	}
+	for i := range r.Arguments {
+		predicate := &r.Arguments[i]
+		if predicate.Index < 0 {
+			return fmt.Errorf("indice d'argument négatif")
+		}
+		if err = predicate.compile(); err != nil {
+			return err
+		}
+	}
+	if r.Query != "" {
+		if err = r.compileQuery(); err != nil {
+			return err
+		}
+	}
+	if r.Pattern != "" {
+		if err = r.compilePattern(); err != nil {
+			return err
+		}
+	}
+	if r.Regex != "" {
+		if err = r.compileRegex(); err != nil {
+			return err
+		}
+	}
+	if r.Script != "" {
+		if err = r.compileScript(); err != nil {
+			return err
+		}
+	}
+	r.versions, err = parseVersionConstraint(r.PHP)
+	return err
+}
+
+// compileQuery compile la requête tree-sitter de la règle et vérifie que les captures
+// référencées existent et que les expressions de #match? sont valides : le curseur de
+// requête les compile sans contrôle à l'exécution.
+func (r *SignatureRule) compileQuery() error {
+	query, err := sitter.NewQuery([]byte(r.Query), php.GetLanguage())
+	if err != nil {
+		return fmt.Errorf("requête invalide : %w", err)
+	}
+	names := make(map[string]bool)
+	for id := uint32(0); id < query.CaptureCount(); id++ {
+		names[query.CaptureNameForId(id)] = true
+	}
+	if len(names) == 0 {
+		query.Close()
+		return fmt.Errorf("la requête ne capture aucun nœud")
+	}
+	for pattern := uint32(0); pattern < query.PatternCount(); pattern++ {
+		for _, steps := range query.PredicatesForPattern(pattern) {
+			operator := query.StringValueForId(steps[0].ValueId)
+			if (operator == "match?" || operator == "not-match?") && len(steps) > 2 {
+				if _, err := regexp.Compile(query.StringValueForId(steps[2].ValueId)); err != nil {
+					query.Close()
+					return fmt.Errorf("#%s : %w", operator, err)
+				}
+			}
+		}
+	}
+	for i := range r.Captures {
+		predicate := &r.Captures[i]
+		if !names[predicate.Capture] {
+			query.Close()
+			return fmt.Errorf("capture inconnue : @%s", predicate.Capture)
+		}
+		if err := predicate.compile(); err != nil {
+			query.Close()
+			return err
+		}
+	}
+	if r.Report != "" && !names[r.Report] {
+		query.Close()
+		return fmt.Errorf("capture inconnue : @%s", r.Report)
+	}
+	r.query = query
+	return nil
+}
+
+// compilePattern analyse le motif de code de la règle ; les conditions de "captures"
+// portent alors sur ses métavariables, nommées sans "$".
+func (r *SignatureRule) compilePattern() error {
+	pattern, err := ParseCodePattern(r.Pattern)
+	if err != nil {
+		return err
+	}
+	if pattern.Kind() == "" {
+		return fmt.Errorf("le motif ne peut pas se réduire à une métavariable ou à \"...\"")
+	}
+	for i := range r.Captures {
+		predicate := &r.Captures[i]
+		if !pattern.metavariables[predicate.Capture] {
+			return fmt.Errorf("métavariable inconnue : $%s", predicate.Capture)
+		}
+		if err := predicate.compile(); err != nil {
+			return err
+		}
+	}
+	r.pattern = pattern
+	return nil
+}
+
+// appliesTo vérifie si la règle concerne la version de PHP ciblée ("" si elle est
+// inconnue : toutes les règles s'appliquent).
+func (r *SignatureRule) appliesTo(phpVersion string) bool {
+	return phpVersion == "" || r.versions.allows(phpVersion)
+}
+
+// Match vérifie si l'appel node satisfait la signature pour la version de PHP ciblée
+// ("" si elle est inconnue : toutes les règles s'appliquent), puis son script.
+func (r *SignatureRule) Match(node *sitter.Node, source []byte, phpVersion string) (Finding, bool) {
+	if !r.appliesTo(phpVersion) {
+		return Finding{}, false
+	}
+	args := getArguments(node, source)
+	for _, predicate := range r.Arguments {
+		if predicate.Index >= len(args) || !predicate.holds(args[predicate.Index]) {
+			return Finding{}, false
+		}
+	}
+	return r.scriptFinding(node.StartPoint().Row+1, scriptMatch{
+		node:       node,
+		function:   extractFunctionName(node, source),
+		args:       args,
+		phpVersion: phpVersion,
+	}, source)
+}
+
+// scriptFinding crée le résultat d'une correspondance retenue par le script de la règle
+// (toujours, si elle n'en a pas), avec le message éventuellement retourné par le script.
+func (r *SignatureRule) scriptFinding(line uint32, match scriptMatch, source []byte) (Finding, bool) {
+	ok, message := r.runScript(match, source)
+	if !ok {
+		return Finding{}, false
+	}
+	finding := r.Finding(line)
+	if message != "" {
+		finding.Message = message
+	}
+	return finding, true
+}
+
+// MatchQuery exécute la requête de la règle sur l'arbre et retourne un résultat par nœud
+// signalé : la capture désignée par "report", ou à défaut la première capture de chaque
+// correspondance. Les prédicats #eq? et #match? de la requête sont appliqués, puis les
+// conditions sur les captures et le script.
+func (r *SignatureRule) MatchQuery(root *sitter.Node, source []byte, phpVersion string) []Finding {
+	if r.query == nil || !r.appliesTo(phpVersion) {
+		return nil
+	}
+	cursor := sitter.NewQueryCursor()
+	defer cursor.Close()
+	cursor.Exec(r.query, root)
+
+	var findings []Finding
+	reported := make(map[uint32]bool)
+	for {
+		match, ok := cursor.NextMatch()
+		if !ok {
+			break
+		}
+		match = cursor.FilterPredicates(match, source)
+		if len(match.Captures) == 0 || !r.capturesHold(match, source) {
+			continue
+		}
+		node := match.Captures[0].Node
+		for _, capture := range match.Captures {
+			if r.query.CaptureNameForId(capture.Index) == r.Report {
+				node = capture.Node
+				break
+			}
+		}
+		if reported[node.StartByte()] {
+			continue
+		}
+		captures := make(map[string]string)
+		nodes := make(map[string]*sitter.Node)
+		for _, capture := range match.Captures {
+			name := r.query.CaptureNameForId(capture.Index)
+			captures[name] = capture.Node.Content(source)
+			nodes[name] = capture.Node
+		}
+		finding, ok := r.scriptFinding(node.StartPoint().Row+1, scriptMatch{
+			node:       node,
+			captures:   captures,
+			nodes:      nodes,
+			phpVersion: phpVersion,
+		}, source)
+		if !ok {
+			continue
+		}
+		reported[node.StartByte()] = true
+		findings = append(findings, finding)
+	}
+	return findings
+}
+
+// MatchPattern vérifie si node correspond au motif de la règle, à ses conditions sur
+// les métavariables et à son script. Le message du résultat reprend le texte des métavariables qu'il
+// cite ($X).
+func (r *SignatureRule) MatchPattern(node *sitter.Node, source []byte, phpVersion string) (Finding, bool) {
+	if r.pattern == nil || node.Type() != r.pattern.Kind() || !r.appliesTo(phpVersion) {
+		return Finding{}, false
+	}
+	bindings, ok := r.pattern.Match(node, source)
+	if !ok {
+		return Finding{}, false
+	}
+	for _, predicate := range r.Captures {
+		if !predicate.holds(bindings[predicate.Capture]) {
+			return Finding{}, false
+		}
+	}
+	finding, ok := r.scriptFinding(node.StartPoint().Row+1, scriptMatch{
+		node:       node,
+		captures:   bindings,
+		phpVersion: phpVersion,
+	}, source)
+	if !ok {
+		return Finding{}, false
+	}
+	finding.Message = metavariableReference.ReplaceAllStringFunc(finding.Message, func(ref string) string {
+		if value, ok := bindings[ref[1:]]; ok {
+			return value
+		}
+		return ref
+	})
+	return finding, true
+}
+
+// metavariableReference reconnaît une métavariable citée dans un message.
+var metavariableReference = regexp.MustCompile(`\$[A-Z][A-Z0-9_]*`)
+
+// capturesHold vérifie les conditions de la règle sur les captures d'une correspondance.
+func (r *SignatureRule) capturesHold(match *sitter.QueryMatch, source []byte) bool {
+	for _, predicate := range r.Captures {
+		found := false
+		for _, capture := range match.Captures {
+			if r.query.CaptureNameForId(capture.Index) != predicate.Capture {
+				continue
+			}
+			if !predicate.holds(capture.Node.Content(source)) {
+				return false
+			}
+			found = true
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Finding crée le résultat de la signature pour un appel à la ligne donnée.
+func (r *SignatureRule) Finding(line uint32) Finding {
+	return Finding{
+		RuleID:      r.ID,
+		CWE:         r.CWE,
+		Severity:    r.severity,
+		Confidence:  r.confidence,
+		Line:        line,
+		Message:     r.Message,
+		OWASP:       r.OWASP,
+		References:  r.References,
+		Remediation: r.Remediation,
+	}
+}
+
+// SignatureSet indexe des signatures par nom de fonction ou par type de nœud (motifs de
+// code) et regroupe les signatures exprimées par une requête tree-sitter.
+type SignatureSet struct {
+	rules      []*SignatureRule
+	byID       map[string]*SignatureRule
+	byFunction map[string][]*SignatureRule
+	byKind     map[string][]*SignatureRule
+	queries    []*SignatureRule
+	regexes    []*SignatureRule
+}
+
+// NewSignatureSet construit un jeu de signatures. Une règle reprenant l'identifiant
+// d'une règle précédente la remplace, ce qui permet de surcharger les règles embarquées.
+func NewSignatureSet(rules ...[]*SignatureRule) *SignatureSet {
+	set := &SignatureSet{
+		byID:       make(map[string]*SignatureRule),
+		byFunction: make(map[string][]*SignatureRule),
+		byKind:     make(map[string][]*SignatureRule),
+	}
+	index := make(map[string]int)
+	for _, group := range rules {
+		for _, rule := range group {
+			if i, ok := index[rule.ID]; ok {
+				set.rules[i] = rule
+				continue
+			}
+			index[rule.ID] = len(set.rules)
+			set.rules = append(set.rules, rule)
+		}
+	}
+	for _, rule := range set.rules {
+		set.byID[rule.ID] = rule
+		if rule.query != nil {
+			set.queries = append(set.queries, rule)
+		}
+		if rule.regex != nil {
+			set.regexes = append(set.regexes, rule)
+		}
+		if rule.pattern != nil {
+			set.byKind[rule.pattern.Kind()] = append(set.byKind[rule.pattern.Kind()], rule)
+		}
+		for _, function := range rule.Functions {
+			name := strings.ToLower(function)
+			set.byFunction[name] = append(set.byFunction[name], rule)
+		}
+	}
+	return set
+}
+
+// Rules retourne les signatures du jeu, dans l'ordre de chargement.
+func (s *SignatureSet) Rules() []*SignatureRule {
+	return s.rules
+}
+
+// Rule retourne la signature d'identifiant id, ou nil.
+func (s *SignatureSet) Rule(id string) *SignatureRule {
+	return s.byID[id]
+}
+
+// ForFunction retourne les signatures portant sur une fonction.
+func (s *SignatureSet) ForFunction(name string) []*SignatureRule {
+	return s.byFunction[strings.ToLower(name)]
+}
+
+// ForKind retourne les signatures dont le motif de code a pour racine un nœud du type
+// donné.
+func (s *SignatureSet) ForKind(kind string) []*SignatureRule {
+	return s.byKind[kind]
+}
+
+// Queries retourne les signatures exprimées par une requête tree-sitter.
+func (s *SignatureSet) Queries() []*SignatureRule {
+	return s.queries
+}
+
+// Regexes retourne les signatures exprimées par une expression régulière.
+func (s *SignatureSet) Regexes() []*SignatureRule {
+	return s.regexes
+}
+
+// builtinSignatures sont les signatures embarquées, chargées au démarrage.
+var builtinSignatures = mustLoadEmbeddedRules()
+
+func mustLoadEmbeddedRules() []*SignatureRule {
+	paths, err := fs.Glob(embeddedRules, "rules/*.yaml")
+	if err != nil {
+		panic(err)
+	}
+	var rules []*SignatureRule
+	for _, path := range paths {
+		data, err := embeddedRules.ReadFile(path)
+		if err != nil {
+			panic(err)
+		}
+		parsed, err := ParseSignatureRules(data, path)
+		if err != nil {
+			panic(err)
+		}
+		rules = append(rules, parsed...)
+	}
+	return rules
+}
+
+// LoadSignatureRules charge les règles d'un fichier YAML ou de tous les fichiers .yaml
+// et .yml d'un dossier, par ordre alphabétique.
+func LoadSignatureRules(path string) ([]*SignatureRule, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	files := []string{path}
+	if info.IsDir() {
+		files = nil
+		for _, pattern := range []string{"*.yaml", "*.yml"} {
+			matches, err := filepath.Glob(filepath.Join(path, pattern))
+			if err != nil {
+				return nil, err
+			}
+			files = append(files, matches...)
+		}
+		sort.Strings(files)
+	}
+	var rules []*SignatureRule
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := ParseSignatureRules(data, file)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, parsed...)
+	}
+	return rules, nil
+}
+
+// versionConstraint est une disjonction de plages de versions ; chaque plage est une
+// conjonction de comparaisons (">=7.3, <7.3.1"). Une contrainte vide accepte toutes les
+// versions.
+type versionConstraint [][]versionComparison
+
+type versionComparison struct {
+	operator string
+	version  []int
+}
+
+// parseVersionConstraint lit une contrainte du type "<7.1.30 || >=7.2, <7.2.19".
+func parseVersionConstraint(text string) (versionConstraint, error) {
+	var constraint versionConstraint
+	if strings.TrimSpace(text) == "" {
+		return constraint, nil
+	}
+	for _, alternative := range strings.Split(text, "||") {
+		var comparisons []versionComparison
+		for _, part := range strings.Split(alternative, ",") {
+			part = strings.TrimSpace(part)
+			rest := strings.TrimLeft(part, "<>=!")
+			operator := part[:len(part)-len(rest)]
+			switch operator {
+			case "":
+				operator = "="
+			case "<", "<=", ">", ">=", "=", "==", "!=":
+			default:
+				return nil, fmt.Errorf("opérateur de version invalide dans %q", part)
+			}
+			version, err := parseVersion(rest)
+			if err != nil {
+				return nil, err
+			}
+			comparisons = append(comparisons, versionComparison{operator: operator, version: version})
+		}
+		constraint = append(constraint, comparisons)
+	}
+	return constraint, nil
+}
+
+// allows vérifie si la version satisfait l'une des plages de la contrainte. Une version
+// illisible est acceptée, pour ne pas masquer de résultat.
+func (c versionConstraint) allows(text string) bool {
+	if len(c) == 0 {
+		return true
+	}
+	version, err := parseVersion(text)
+	if err != nil {
+		return true
+	}
+	for _, comparisons := range c {
+		ok := true
+		for _, comparison := range comparisons {
+			if !comparison.holds(version) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (c versionComparison) holds(version []int) bool {
+	cmp := compareVersions(version, c.version)
+	switch c.operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "!=":
+		return cmp != 0
+	}
+	return cmp == 0
+}
+
+// parseVersion découpe une version "7.4.21" en composantes numériques.
+func parseVersion(text string) ([]int, error) {
+	var version []int
+	for _, part := range strings.Split(strings.TrimSpace(text), ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("version invalide : %q", text)
+		}
+		version = append(version, n)
+	}
+	return version, nil
+}
+
+// compareVersions compare deux versions composante par composante, les composantes
+// absentes valant 0 (7.3 == 7.3.0).
+func compareVersions(a, b []int) int {
+	for i := 0; i < max(len(a), len(b)); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
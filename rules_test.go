@@ -0,0 +1,292 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const cvePHPCode = `<?php
+$fp = fsockopen("udp://127.0.0.1:53", 53);
+$parts = mb_split("\w", $text);
+$headers = iconv_mime_decode_headers($raw);
+$enc = openssl_encrypt($data, "aes-128-gcm", $key, 0, $iv, $tag);
+$ok = filter_var($url, FILTER_VALIDATE_URL);
+$xml = simplexml_load_file($path);
+$safe = simplexml_load_file("config.xml");`
+
+func TestBuiltinSignatures(t *testing.T) {
+	detections := detect(t, cvePHPCode)
+
+	var ids []string
+	for _, d := range detections {
+		ids = append(ids, d.RuleID)
+	}
+	assert.Equal(t, []string{"CVE-2017-7189", "CVE-2019-9025", "CVE-2019-11039", "CVE-2020-7069", "CVE-2020-7071", "CVE-2021-21707"}, ids)
+	assert.Equal(t, "CWE-125", detections[1].CWE)
+	assert.Equal(t, `mb_split("\w") détecté`, detections[1].Message)
+}
+
+func TestSignatureVersionGating(t *testing.T) {
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), []byte(cvePHPCode))
+	assert.NoError(t, err)
+
+	analyzer.PHPVersion = "7.4.10"
+	var ids []string
+	for _, d := range analyzer.DetectVulnerabilities(context.Background(), tree, []byte(cvePHPCode)) {
+		ids = append(ids, d.RuleID)
+	}
+	assert.Equal(t, []string{"CVE-2017-7189", "CVE-2020-7069", "CVE-2020-7071", "CVE-2021-21707"}, ids)
+
+	analyzer.PHPVersion = "8.2.0"
+	ids = nil
+	for _, d := range analyzer.DetectVulnerabilities(context.Background(), tree, []byte(cvePHPCode)) {
+		ids = append(ids, d.RuleID)
+	}
+	assert.Equal(t, []string{"CVE-2017-7189"}, ids, "rules without a version range always apply")
+}
+
+func TestVersionConstraint(t *testing.T) {
+	constraint, err := parseVersionConstraint("<7.1.30 || >=7.2, <7.2.19")
+	assert.NoError(t, err)
+	assert.True(t, constraint.allows("7.1.29"))
+	assert.False(t, constraint.allows("7.1.30"))
+	assert.True(t, constraint.allows("7.2"))
+	assert.False(t, constraint.allows("7.2.19"))
+
+	_, err = parseVersionConstraint("~>7.2")
+	assert.Error(t, err)
+}
+
+func TestUserSignatureRules(t *testing.T) {
+	dir := t.TempDir()
+	rules := `rules:
+  - id: CUSTOM-1
+    cwe: CWE-78
+    severity: critical
+    functions: [shell_exec]
+    arguments:
+      - index: 0
+        matches: '^\$'
+    message: shell_exec sur une variable
+  - id: CVE-2019-11039
+    functions: [iconv_mime_decode_headers]
+    severity: low
+    message: surchargée par le projet
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "custom.yaml"), []byte(rules), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, loadSignatures(analyzer, dir, filepath.Join(dir, "absent.yaml")))
+	assert.Len(t, analyzer.Signatures.Rules(), len(builtinSignatures)+1)
+
+	phpCode := []byte(`<?php
+shell_exec($cmd);
+shell_exec("ls");
+iconv_mime_decode_headers($raw);`)
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+	detections := analyzer.DetectVulnerabilities(context.Background(), tree, phpCode)
+	assert.Len(t, detections, 2)
+	assert.Equal(t, "CUSTOM-1 / CWE-78", detections[0].Label())
+	assert.Equal(t, SeverityCritical, detections[0].Severity)
+	assert.Equal(t, "surchargée par le projet", detections[1].Message)
+	assert.Equal(t, SeverityLow, detections[1].Severity)
+}
+
+func TestInvalidSignatureRules(t *testing.T) {
+	_, err := ParseSignatureRules([]byte("rules:\n  - id: X\n    functions: [f]\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "message manquant")
+
+	_, err = ParseSignatureRules([]byte("rules:\n  - id: X\n    functions: [f]\n    message: m\n    arguments:\n      - index: 0\n        matches: '('\n"), "bad.yaml")
+	assert.ErrorContains(t, err, `bad.yaml: règle "X"`)
+}
+
+func TestQuerySignatureRules(t *testing.T) {
+	detections := detect(t, `<?php
+$data = unserialize($_COOKIE["prefs"]);
+$nested = UNSERIALIZE($_POST["a"]["b"]);
+$safe = unserialize($_SERVER["HTTP_X"]);
+$cached = unserialize($row["payload"]);`)
+
+	assert.Len(t, detections, 2)
+	assert.Equal(t, "unserialize-user-input / CWE-502", detections[0].Label())
+	assert.Equal(t, uint32(2), detections[0].Line)
+	assert.Equal(t, uint32(3), detections[1].Line)
+	assert.Equal(t, SeverityHigh, detections[1].Severity)
+}
+
+func TestInvalidQuerySignatureRules(t *testing.T) {
+	_, err := ParseSignatureRules([]byte("rules:\n  - id: X\n    query: '(function_call_expression'\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "requête invalide")
+
+	_, err = ParseSignatureRules([]byte("rules:\n  - id: X\n    query: '(name) @n'\n    captures:\n      - capture: other\n        equals: x\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "capture inconnue : @other")
+
+	_, err = ParseSignatureRules([]byte("rules:\n  - id: X\n    query: '((name) @n (#match? @n \"(\"))'\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "#match?")
+
+	_, err = ParseSignatureRules([]byte("rules:\n  - id: X\n    query: '(name) @n'\n    functions: [f]\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "incompatible")
+}
+
+func TestPatternSignatureRules(t *testing.T) {
+	detections := detect(t, `<?php
+eval("return " . $_GET["expr"] . ";");
+eval($code);
+$out = preg_replace("/(\w+)/e", "strtoupper('$1')", $in);
+$out = preg_replace("/(\w+)/i", "x", $in);`)
+
+	assert.Len(t, detections, 2)
+	assert.Equal(t, "eval-user-input / CWE-95", detections[0].Label())
+	assert.Equal(t, `eval() sur une entrée utilisateur ("return " . $_GET["expr"] . ";")`, detections[0].Message)
+	assert.Equal(t, "preg-replace-eval", detections[1].RuleID)
+	assert.Equal(t, uint32(4), detections[1].Line)
+
+	_, err := ParseSignatureRules([]byte("rules:\n  - id: X\n    pattern: f($A)\n    captures:\n      - capture: B\n        equals: x\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "métavariable inconnue : $B")
+}
+
+func TestRegexSignatureRules(t *testing.T) {
+	detections := detect(t, `<?php
+$sql = <<<SQL
+LOAD DATA LOCAL INFILE '/tmp/users.csv'
+INTO TABLE users
+SQL;
+$import = "load data local infile '$path' into table t";
+$label = 'LOAD DATA INFILE /srv/import.csv';
+// LOAD DATA LOCAL INFILE '/tmp/x.csv'
+?>
+<form method="post" action="http://example.com/login.php">
+<form method="post" action="https://example.com/login.php">`)
+
+	var ids []string
+	for _, f := range detections {
+		ids = append(ids, fmt.Sprintf("%s:%d", f.RuleID, f.Line))
+	}
+	assert.Equal(t, []string{"sql-load-data-local:3", "sql-load-data-local:6", "html-form-over-http:10"}, ids)
+	assert.Equal(t, "LOAD DATA LOCAL INFILE /tmp/users.csv (le serveur peut lire n'importe quel fichier du client)", detections[0].Message)
+
+	rules, err := ParseSignatureRules([]byte(`rules:
+  - id: todo-secret
+    regex: '(?i)password\s*=\s*(?P<VALUE>\w+)'
+    message: mot de passe $VALUE en clair
+`), "custom.yaml")
+	assert.NoError(t, err)
+	assert.Equal(t, scopeFile, rules[0].Scope)
+	analyzer := NewPHPAnalyzer()
+	analyzer.Signatures = NewSignatureSet(rules)
+	source := []byte("<?php\n# password = hunter2\n")
+	tree, err := analyzer.Parse(context.Background(), source)
+	assert.NoError(t, err)
+	findings := rules[0].MatchRegex(tree.RootNode(), source, "")
+	assert.Len(t, findings, 1)
+	assert.Equal(t, uint32(2), findings[0].Line)
+	assert.Equal(t, "mot de passe hunter2 en clair", findings[0].Message)
+
+	_, err = ParseSignatureRules([]byte("rules:\n  - id: X\n    regex: 'a'\n    scope: comments\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, `portée inconnue : "comments"`)
+	_, err = ParseSignatureRules([]byte("rules:\n  - id: X\n    regex: 'a('\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "regex")
+	_, err = ParseSignatureRules([]byte("rules:\n  - id: X\n    functions: [f]\n    scope: strings\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "scope exige une expression régulière")
+}
+
+func TestScriptSignatureRules(t *testing.T) {
+	rules, err := ParseSignatureRules([]byte(`rules:
+  - id: setcookie-insecure
+    functions: [setcookie]
+    script: |
+      def check(match):
+          if len(match.args) < 6:
+              return "setcookie(" + match.args[0] + ") sans l'option secure"
+          return match.args[5] == "false"
+    message: cookie sans l'option secure
+  - id: eval-concat
+    query: '(function_call_expression function: (name) @f (#eq? @f "eval")) @call'
+    report: call
+    script: |
+      def check(match):
+          arg = match.node.field("arguments").children[0].children[0]
+          return arg.type == "binary_expression" and match.captures["f"] == "eval"
+    message: eval() sur une concaténation
+  - id: loop
+    regex: 'forever'
+    script: |
+      def check(match):
+          n = 0
+          for i in range(1000000000):
+              n += i
+          return True
+    message: m
+`), "custom.yaml")
+	assert.NoError(t, err)
+	analyzer := NewPHPAnalyzer()
+	analyzer.Signatures = NewSignatureSet(rules)
+	phpCode := []byte(`<?php
+setcookie("sid", $id);
+setcookie("sid", $id, 0, "/", "", true);
+setcookie("sid", $id, 0, "/", "", false);
+eval("return " . $expr . ";");
+eval($code);
+// forever`)
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+
+	var ids []string
+	for _, f := range analyzer.DetectVulnerabilities(context.Background(), tree, phpCode) {
+		if f.RuleID == "setcookie-insecure" || f.RuleID == "eval-concat" || f.RuleID == "loop" {
+			ids = append(ids, fmt.Sprintf("%s:%d %s", f.RuleID, f.Line, f.Message))
+		}
+	}
+	assert.Equal(t, []string{
+		`setcookie-insecure:2 setcookie("sid") sans l'option secure`,
+		"setcookie-insecure:4 cookie sans l'option secure",
+		"eval-concat:5 eval() sur une concaténation",
+	}, ids)
+
+	_, err = ParseSignatureRules([]byte("rules:\n  - id: X\n    functions: [f]\n    script: 'x = 1'\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "fonction check(match) manquante")
+	_, err = ParseSignatureRules([]byte("rules:\n  - id: X\n    functions: [f]\n    script: 'def check(a, b): return True'\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "un seul paramètre")
+	_, err = ParseSignatureRules([]byte("rules:\n  - id: X\n    functions: [f]\n    script: 'def check(match) return'\n    message: m\n"), "bad.yaml")
+	assert.ErrorContains(t, err, "script")
+}
+
+func TestExpandedCVESignatures(t *testing.T) {
+	phpCode := []byte(`<?php
+$img = imagecreatefromgif($_FILES["f"]["tmp_name"]);
+$xbm = imagecreatefromxbm($path);
+$info = $_SERVER['PATH_INFO'];
+$env = getenv("PATH_INFO");
+$uri = $_SERVER["REQUEST_URI"];
+$res = pg_query_params($conn, "SELECT $1", $params);
+$db = new PDO("mysql:host=" . $host, $user, $password);
+$local = new PDO("sqlite:app.db", null, null);
+$entries = scandir("phar://" . $archive . "/dir");
+$meta = $phar->getMetadata();
+$safe = $phar->getMetadata(["allowed_classes" => false]);`)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+
+	rulesFor := func(version string) []string {
+		analyzer.PHPVersion = version
+		var ids []string
+		for _, f := range analyzer.DetectVulnerabilities(context.Background(), tree, phpCode) {
+			if f.RuleID != "phar-deserialization" {
+				ids = append(ids, f.RuleID)
+			}
+		}
+		return ids
+	}
+	assert.Equal(t, []string{"CVE-2018-5711", "CVE-2019-11038", "CVE-2019-11043", "CVE-2019-11043"}, rulesFor("7.1.0"))
+	assert.Equal(t, []string{"CVE-2022-31625", "CVE-2022-31626"}, rulesFor("7.4.29"))
+	assert.Equal(t, []string{"CVE-2022-31625", "CVE-2022-31626", "CVE-2023-3824", "phar-metadata-unserialize"}, rulesFor("8.1.0"))
+	assert.Equal(t, []string{"phar-metadata-unserialize"}, rulesFor("8.3.0"))
+}
@@ -0,0 +1,417 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"runtime/metrics"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// ScanProfile regroupe les réglages de performance d'un scan de dossier.
+type ScanProfile struct {
+	Name        string
+	Workers     int   // nombre de fichiers analysés en parallèle
+	MemoryLimit int64 // limite mémoire souple du runtime Go (0 = aucune)
+	MaxFileSize int64 // taille maximale d'un fichier en mode "fast" (0 = aucune)
+	UseCache    bool  // réutilisation des résultats des scans précédents (resultCache, -cache)
+	Tier        string
+}
+
+// Niveaux d'analyse : "full" analyse tous les fichiers, "fast" ignore les fichiers
+// plus gros que MaxFileSize (souvent générés ou minifiés).
+const (
+	TierFull = "full"
+	TierFast = "fast"
+)
+
+// Profils prédéfinis, sélectionnables avec -profile. Aucun n'utilise le cache des
+// résultats, activé explicitement par -cache ou -cache-dir (applyCache).
+var scanProfiles = map[string]ScanProfile{
+	"default": {Name: "default", Workers: 1, Tier: TierFull},
+	"small":   {Name: "small", Workers: 1, Tier: TierFull},
+	"medium":  {Name: "medium", Workers: runtime.NumCPU(), MemoryLimit: 1 << 30, Tier: TierFull},
+	"large":   {Name: "large", Workers: runtime.NumCPU(), MemoryLimit: 2 << 30, MaxFileSize: 1 << 20, Tier: TierFast},
+}
+
+// RepoStats résume l'échantillonnage d'un dépôt.
+type RepoStats struct {
+	Files      int
+	TotalBytes int64
+	AvgSize    int64
+	ParseRate  float64 // octets parsés par seconde sur l'échantillon
+}
+
+// sampleSize est le nombre maximal de fichiers parsés pour estimer la vitesse de parsing.
+const sampleSize = 20
+
+// listPHPFiles retourne les fichiers PHP des chemins d'un scan, triés par chemin
+// (pathLess) quel que soit l'ordre des chemins : les dossiers sont parcourus
+// récursivement selon pa.Walk, les fichiers désignés explicitement sont retenus quelle
+// que soit leur extension. Un fichier désigné par plusieurs chemins n'est retenu qu'une fois. Les fichiers et dossiers
+// rencontrés pendant le parcours qui correspondent à pa.Exclude ou aux fichiers
+// d'exclusion (walkFilter) sont ignorés ; les chemins désignés explicitement ne le sont
+// jamais. Avec -changed-since, seuls les fichiers modifiés sont retenus.
+func (pa *PHPAnalyzer) listPHPFiles(paths ...string) ([]string, error) {
+	var files []string
+	seen := map[string]bool{}
+	add := func(path string) {
+		if key := filepath.Clean(path); !seen[key] {
+			seen[key] = true
+			files = append(files, path)
+		}
+	}
+	for _, root := range paths {
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			add(root) // un fichier absent est signalé lors de son analyse
+			continue
+		}
+		pa.walk(root, add)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return pathLess(files[i], files[j]) })
+	return pa.keepChangedFiles(files), nil
+}
+
+// pathLess compare deux chemins dossier par dossier, dans l'ordre du parcours d'un
+// dossier : "src/a.php" précède "src-old.php", le séparateur étant classé avant tout
+// autre caractère.
+func pathLess(a, b string) bool {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] == b[i] {
+			continue
+		}
+		if os.IsPathSeparator(a[i]) || os.IsPathSeparator(b[i]) {
+			return os.IsPathSeparator(a[i])
+		}
+		return a[i] < b[i]
+	}
+	return len(a) < len(b)
+}
+
+// SampleRepository mesure le nombre de fichiers, leur taille moyenne et la vitesse de
+// parsing sur un échantillon de fichiers.
+func (pa *PHPAnalyzer) SampleRepository(ctx context.Context, paths ...string) (RepoStats, error) {
+	var stats RepoStats
+	files, err := pa.listPHPFiles(paths...)
+	if err != nil {
+		return stats, err
+	}
+	stats.Files = len(files)
+	for _, path := range files {
+		if info, err := os.Stat(path); err == nil {
+			stats.TotalBytes += info.Size()
+		}
+	}
+	if stats.Files == 0 {
+		return stats, nil
+	}
+	stats.AvgSize = stats.TotalBytes / int64(stats.Files)
+
+	analyzer := NewPHPAnalyzer()
+	step := max(len(files)/sampleSize, 1)
+	var parsed int64
+	start := time.Now()
+	for i := 0; i < len(files) && ctx.Err() == nil; i += step {
+		fileCtx, cancel := pa.fileContext(ctx)
+		if tree, content, err := analyzer.ParseFile(fileCtx, files[i]); err == nil {
+			parsed += int64(len(content))
+			analyzer.releaseTree(tree)
+		}
+		cancel()
+	}
+	if err := ctx.Err(); err != nil {
+		return stats, err
+	}
+	if elapsed := time.Since(start).Seconds(); elapsed > 0 {
+		stats.ParseRate = float64(parsed) / elapsed
+	}
+	return stats, nil
+}
+
+// SelectProfile choisit un profil à partir des statistiques du dépôt : les petits dépôts
+// (ou ceux dont le parsing complet est estimé à moins de deux secondes) sont analysés
+// séquentiellement, les gros dépôts en parallèle avec un plafond mémoire.
+func SelectProfile(stats RepoStats) ScanProfile {
+	estimated := 0.0
+	if stats.ParseRate > 0 {
+		estimated = float64(stats.TotalBytes) / stats.ParseRate
+	}
+	switch {
+	case stats.Files < 200 || estimated < 2:
+		return scanProfiles["small"]
+	case stats.Files < 5000:
+		return scanProfiles["medium"]
+	default:
+		return scanProfiles["large"]
+	}
+}
+
+// ResolveProfile retourne le profil demandé par le flag -profile. "auto" échantillonne
+// les chemins du scan et journalise le profil retenu.
+func (pa *PHPAnalyzer) ResolveProfile(ctx context.Context, name string, paths ...string) (ScanProfile, error) {
+	if name == "" {
+		return scanProfiles["default"], nil
+	}
+	if name != "auto" {
+		profile, ok := scanProfiles[name]
+		if !ok {
+			return profile, fmt.Errorf("profil inconnu : %q", name)
+		}
+		return profile, nil
+	}
+	stats, err := pa.SampleRepository(ctx, paths...)
+	if err != nil {
+		return scanProfiles["default"], err
+	}
+	profile := SelectProfile(stats)
+	log.Printf("Profil de scan %q choisi (%d fichiers, taille moyenne %d octets, %.0f octets/s) : %d workers, limite mémoire %d, niveau %s",
+		profile.Name, stats.Files, stats.AvgSize, stats.ParseRate,
+		profile.Workers, profile.MemoryLimit, profile.Tier)
+	return profile, nil
+}
+
+// profileFlags sont les options de performance d'un scan de dossier.
+type profileFlags struct {
+	name         string
+	maxFileSize  byteSize
+	memoryBudget byteSize
+	fileTimeout  time.Duration
+}
+
+func addProfileFlags(fs *flag.FlagSet) *profileFlags {
+	flags := &profileFlags{}
+	fs.StringVar(&flags.name, "profile", "", "Profil de scan : auto, small, medium ou large")
+	fs.Var(&flags.maxFileSize, "max-file-size", "Taille au-delà de laquelle un fichier est ignoré avec un avertissement, par exemple 2M (0 = aucune)")
+	fs.Var(&flags.memoryBudget, "memory-budget", "Budget mémoire souple du scan, par exemple 512M (défaut : celui du profil)")
+	fs.DurationVar(&flags.fileTimeout, "timeout-per-file", 0, "Durée maximale de l'analyse d'un fichier, par exemple 30s ; au-delà, le fichier est signalé en erreur (0 = aucune)")
+	return flags
+}
+
+// byteSize est une taille en octets, écrite avec un suffixe K, M ou G facultatif
+// (puissances de 1024).
+type byteSize int64
+
+func (b *byteSize) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(value string) error {
+	text := strings.TrimSuffix(strings.ToUpper(strings.TrimSpace(value)), "B")
+	unit := int64(1)
+	if n := len(text); n > 0 {
+		switch text[n-1] {
+		case 'K':
+			unit = 1 << 10
+		case 'M':
+			unit = 1 << 20
+		case 'G':
+			unit = 1 << 30
+		}
+		if unit > 1 {
+			text = text[:n-1]
+		}
+	}
+	size, err := strconv.ParseInt(text, 10, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("taille invalide : %q", value)
+	}
+	*b = byteSize(size * unit)
+	return nil
+}
+
+// memoryGuard surveille le budget mémoire d'un scan. Au-delà des trois quarts du
+// budget, le scan passe en mode streaming : les fichiers ne sont plus analysés en avance
+// sur l'écriture des résultats, dont l'ordre oblige sinon à conserver ceux des fichiers
+// déjà analysés (avec leurs arbres et leurs sources).
+type memoryGuard struct {
+	budget    int64
+	streaming atomic.Bool
+}
+
+// exceeded indique si le scan est passé en mode streaming, en mesurant le tas du
+// runtime tant qu'il ne l'est pas.
+func (g *memoryGuard) exceeded() bool {
+	if g.budget <= 0 {
+		return false
+	}
+	if g.streaming.Load() {
+		return true
+	}
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	metrics.Read(sample)
+	if sample[0].Value.Kind() != metrics.KindUint64 || int64(sample[0].Value.Uint64()) < g.budget/4*3 {
+		return false
+	}
+	if g.streaming.CompareAndSwap(false, true) {
+		log.Printf("Budget mémoire de %d octets bientôt atteint : passage en mode streaming", g.budget)
+	}
+	return true
+}
+
+// releaseTree libère la mémoire d'un arbre dès la fin de son analyse, sans attendre le
+// ramasse-miettes, qui ne voit pas la mémoire allouée par tree-sitter. Les arbres du
+// cache du démon sont rendus au cache, qui les conserve.
+func (pa *PHPAnalyzer) releaseTree(tree *sitter.Tree) {
+	switch {
+	case tree == nil:
+	case pa.cache != nil:
+		pa.cache.release(tree)
+	default:
+		tree.Close()
+	}
+}
+
+// errFileTimeout est la cause de l'annulation de l'analyse d'un fichier qui dépasse
+// pa.FileTimeout.
+var errFileTimeout = errors.New("délai dépassé")
+
+// fileContext retourne le contexte de l'analyse d'un fichier, annulé après
+// pa.FileTimeout.
+func (pa *PHPAnalyzer) fileContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if pa.FileTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, pa.FileTimeout, errFileTimeout)
+}
+
+// interrupted indique si l'analyse d'un fichier a été interrompue, ses résultats
+// partiels devant alors être écartés. Un fichier qui dépasse -timeout-per-file est
+// signalé comme une erreur ; l'annulation du scan (Ctrl-C) ne l'est pas.
+func (pa *PHPAnalyzer) interrupted(ctx context.Context, path string) bool {
+	if ctx.Err() == nil {
+		return false
+	}
+	if errors.Is(context.Cause(ctx), errFileTimeout) {
+		pa.fileError("Analyse du fichier %q interrompue : délai de %s dépassé", path, pa.FileTimeout)
+	}
+	return true
+}
+
+// forEachPHPFile applique fn à chaque fichier PHP des chemins d'un scan en répartissant
+// le travail sur pa.Profile.Workers goroutines, chacune avec son propre analyseur. Les
+// sorties retournées par fn sont écrites sur pa.Out dans l'ordre du parcours,
+// indépendamment de l'ordonnancement.
+func forEachPHPFile(ctx context.Context, paths []string, pa *PHPAnalyzer, fn func(ctx context.Context, fa *PHPAnalyzer, path string) string) error {
+	return scanPHPFiles(ctx, paths, pa, fn, func(text string) { fmt.Fprint(pa.Out, text) })
+}
+
+// scanPHPFiles répartit fn comme forEachPHPFile, puis passe ses résultats à emit, depuis
+// la goroutine appelante et dans l'ordre du parcours : emit peut ainsi limiter ou
+// numéroter les résultats sans dépendre de l'ordonnancement. fn reçoit le contexte du
+// fichier, annulé après pa.FileTimeout. Lorsque ctx est annulé, plus aucun fichier
+// n'est analysé : les résultats déjà obtenus sont passés à emit, puis une erreur
+// signale l'interruption.
+func scanPHPFiles[T any](ctx context.Context, paths []string, pa *PHPAnalyzer, fn func(ctx context.Context, fa *PHPAnalyzer, path string) T, emit func(T)) error {
+	profile := pa.Profile
+	files, err := pa.listPHPFiles(paths...)
+	if err != nil {
+		return err
+	}
+	guard := &memoryGuard{budget: profile.MemoryLimit}
+	if pa.MemoryBudget > 0 {
+		guard.budget = pa.MemoryBudget
+	}
+	if guard.budget > 0 {
+		defer debug.SetMemoryLimit(debug.SetMemoryLimit(guard.budget))
+	}
+	listed := len(files)
+	maxSize := pa.MaxFileSize
+	if maxSize == 0 && profile.Tier == TierFast {
+		maxSize = profile.MaxFileSize
+	}
+	if maxSize > 0 {
+		files = skipLargeFiles(files, maxSize)
+	}
+	pa.recordScan(paths, len(files), listed-len(files))
+
+	workers := max(profile.Workers, 1)
+	type indexed struct {
+		i      int
+		result T
+	}
+	results := make(chan indexed, workers)
+	jobs := make(chan int)
+	var running sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		running.Add(1)
+		go func() {
+			defer running.Done()
+			analyzer := pa.fork()
+			for i := range jobs {
+				fileCtx, cancel := pa.fileContext(ctx)
+				results <- indexed{i, fn(fileCtx, analyzer, files[i])}
+				cancel()
+			}
+		}()
+	}
+	// En mode streaming, au plus un fichier par worker est analysé en avance sur
+	// l'écriture des résultats.
+	emitted := make(chan struct{}, len(files))
+	go func() {
+		defer close(jobs)
+		done := 0
+		for i := range files {
+			for i-done >= workers && guard.exceeded() {
+				select {
+				case <-emitted:
+					done++
+				case <-ctx.Done():
+					return
+				}
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	go func() {
+		running.Wait()
+		close(results)
+	}()
+
+	// Les résultats arrivés avant leur tour attendent dans pending ; chacun en est retiré
+	// dès son écriture, pour que son arbre et sa source puissent être libérés.
+	pending := make(map[int]T)
+	next := 0
+	for r := range results {
+		pending[r.i] = r.result
+		for result, ok := pending[next]; ok; result, ok = pending[next] {
+			delete(pending, next)
+			emit(result)
+			emitted <- struct{}{}
+			next++
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("analyse interrompue après %d fichier(s) sur %d : %w", next, len(files), err)
+	}
+	return nil
+}
+
+// skipLargeFiles retire les fichiers dépassant maxSize, avec un avertissement.
+func skipLargeFiles(files []string, maxSize int64) []string {
+	var kept []string
+	for _, path := range files {
+		if info, err := os.Stat(path); err == nil && info.Size() > maxSize {
+			log.Printf("Fichier %q ignoré (%d octets > %d)", path, info.Size(), maxSize)
+			continue
+		}
+		kept = append(kept, path)
+	}
+	return kept
+}
@@ -0,0 +1,321 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectProfile(t *testing.T) {
+	assert.Equal(t, "small", SelectProfile(RepoStats{Files: 10, TotalBytes: 1000, ParseRate: 1e6}).Name)
+	assert.Equal(t, "small", SelectProfile(RepoStats{Files: 1000, TotalBytes: 1e6, ParseRate: 1e7}).Name,
+		"fast-to-parse repositories stay sequential")
+	assert.Equal(t, "medium", SelectProfile(RepoStats{Files: 1000, TotalBytes: 1e8, ParseRate: 1e6}).Name)
+	assert.Equal(t, "large", SelectProfile(RepoStats{Files: 10000, TotalBytes: 1e9, ParseRate: 1e6}).Name)
+}
+
+func TestForEachPHPFileKeepsWalkOrder(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("f%02d.php", i))
+		assert.NoError(t, os.WriteFile(name, []byte("<?php echo 1;"), 0o644))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "readme.txt"), []byte("x"), 0o644))
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Profile = ScanProfile{Workers: 4, Tier: TierFull}
+	analyzer.Out = &out
+	err := forEachPHPFile(context.Background(), []string{dir}, analyzer, func(_ context.Context, _ *PHPAnalyzer, path string) string {
+		return filepath.Base(path) + "\n"
+	})
+	assert.NoError(t, err)
+	seen := strings.Fields(out.String())
+	assert.Len(t, seen, 20)
+	for i, name := range seen {
+		assert.Equal(t, fmt.Sprintf("f%02d.php", i), name)
+	}
+}
+
+func TestDeterministicOrder(t *testing.T) {
+	assert.True(t, pathLess("src/a.php", "src-old.php"), "a directory sorts like in the walk")
+	assert.True(t, pathLess("a.php", "b.php"))
+	assert.False(t, pathLess("b.php", "b.php"))
+
+	findings := []Finding{{Line: 2, RuleID: "xss"}, {Line: 1, Column: 5, RuleID: "sqli"}, {Line: 1, Column: 5, RuleID: "debug-leftover"}}
+	sort.SliceStable(findings, func(i, j int) bool { return findingLess(findings[i], findings[j]) })
+	assert.Equal(t, []string{"debug-leftover", "sqli", "xss"}, []string{findings[0].RuleID, findings[1].RuleID, findings[2].RuleID})
+
+	dir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"src-old.php", "src/b.php", "src/a.php", "lib/c.php"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte("<?php\nvar_dump($a); echo $_GET['x'];\n"), 0o644))
+		paths = append(paths, path)
+	}
+	report := func(args ...string) string {
+		var out strings.Builder
+		analyzer := NewPHPAnalyzer()
+		analyzer.Format = formatJSONL
+		analyzer.Profile = ScanProfile{Workers: 4, Tier: TierFull}
+		assert.NoError(t, runCommand(analyzer, "cve", append(args, "-min-severity", "low"), &out))
+		findings, _, _ := strings.Cut(out.String(), `{"type":"summary"`) // without the timings
+		return findings
+	}
+	first := report(paths...)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, first, report(paths[3], paths[1], paths[0], paths[2]), "the order does not depend on the workers nor the arguments")
+	}
+	var files []string
+	for _, line := range strings.Split(first, "\n") {
+		if _, rest, ok := strings.Cut(line, `"file":"`); ok {
+			file, _, _ := strings.Cut(rest, `"`)
+			if rel, _ := filepath.Rel(dir, file); len(files) == 0 || files[len(files)-1] != rel {
+				files = append(files, filepath.ToSlash(rel))
+			}
+		}
+	}
+	assert.Equal(t, []string{"lib/c.php", "src/a.php", "src/b.php", "src-old.php"}, files)
+}
+
+func TestScanEmitsFilesAsTheyComplete(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("f%02d.php", i))
+		assert.NoError(t, os.WriteFile(name, []byte("<?php echo 1;"), 0o644))
+	}
+
+	analyzer := NewPHPAnalyzer()
+	analyzer.Profile = ScanProfile{Workers: 2, Tier: TierFull}
+	var emitted atomic.Int32
+	last := make(chan int32, 1)
+	err := scanPHPFiles(context.Background(), []string{dir}, analyzer, func(_ context.Context, _ *PHPAnalyzer, path string) string {
+		if filepath.Base(path) == "f09.php" {
+			// The last file only completes once earlier results have been written.
+			for emitted.Load() == 0 {
+				runtime.Gosched()
+			}
+			last <- emitted.Load()
+		}
+		return path
+	}, func(string) { emitted.Add(1) })
+	assert.NoError(t, err)
+	assert.Greater(t, <-last, int32(0), "results are written before the scan ends")
+	assert.Equal(t, int32(10), emitted.Load())
+}
+
+func TestFastTierSkipsLargeFiles(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "small.php"), []byte("<?php echo 1;"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "big.php"), []byte("<?php "+strings.Repeat("echo 1;", 100)), 0o644))
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Profile = ScanProfile{Workers: 1, Tier: TierFast, MaxFileSize: 100}
+	analyzer.Out = &out
+	_ = forEachPHPFile(context.Background(), []string{dir}, analyzer, func(_ context.Context, _ *PHPAnalyzer, path string) string {
+		return filepath.Base(path) + "\n"
+	})
+	assert.Equal(t, "small.php\n", out.String())
+}
+
+func TestExcludePatterns(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"src/a.php", "src/generated/b.php", "vendor/lib/c.php", "lib/vendor/d.php", "web/app.min.php", "node_modules/x/e.php"} {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte("<?php\nvar_dump($a);\n"), 0o644))
+	}
+	list := func(args ...string) []string {
+		analyzer := NewPHPAnalyzer()
+		fs := newFlagSet("test", io.Discard)
+		excludes := addExcludeFlags(fs)
+		assert.NoError(t, fs.Parse(args))
+		assert.NoError(t, applyExcludes(analyzer, excludes))
+		files, err := analyzer.listPHPFiles(dir)
+		assert.NoError(t, err)
+		var rel []string
+		for _, path := range files {
+			name, _ := filepath.Rel(dir, path)
+			rel = append(rel, filepath.ToSlash(name))
+		}
+		return rel
+	}
+
+	assert.Equal(t, []string{"src/a.php", "src/generated/b.php", "web/app.min.php"}, list())
+	assert.Equal(t, []string{"src/a.php"}, list("-exclude", "src/**/generated", "-exclude", "*.min.php"))
+	assert.Len(t, list("-no-default-excludes"), 6)
+
+	// Un chemin désigné explicitement n'est jamais exclu.
+	analyzer := NewPHPAnalyzer()
+	files, err := analyzer.listPHPFiles(filepath.Join(dir, "vendor"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+
+	var out strings.Builder
+	assert.Error(t, runCommand(NewPHPAnalyzer(), "analyze-dir", []string{"-exclude", "[", dir}, &out))
+}
+
+func TestIgnoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		".git/HEAD":              "",
+		".gitignore":             "build/\n# commentaire\n/cache.php\n",
+		".phpanalyzerignore":     "*.tpl.php\n!keep.tpl.php\n",
+		"src/.phpanalyzerignore": "/legacy\n",
+		"src/a.php":              "<?php",
+		"src/cache.php":          "<?php",
+		"src/legacy/b.php":       "<?php",
+		"src/lib/legacy/c.php":   "<?php",
+		"src/view.tpl.php":       "<?php",
+		"src/keep.tpl.php":       "<?php",
+		"build/gen.php":          "<?php",
+		"cache.php":              "<?php",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	list := func(analyzer *PHPAnalyzer, root string) []string {
+		paths, err := analyzer.listPHPFiles(filepath.Join(dir, root))
+		assert.NoError(t, err)
+		var rel []string
+		for _, path := range paths {
+			name, _ := filepath.Rel(dir, path)
+			rel = append(rel, filepath.ToSlash(name))
+		}
+		return rel
+	}
+
+	analyzer := NewPHPAnalyzer()
+	assert.Equal(t, []string{"build/gen.php", "cache.php", "src/a.php", "src/cache.php", "src/keep.tpl.php", "src/lib/legacy/c.php"}, list(analyzer, "."))
+	analyzer.Gitignore = true
+	assert.Equal(t, []string{"src/a.php", "src/cache.php", "src/keep.tpl.php", "src/lib/legacy/c.php"}, list(analyzer, "."))
+	// Les fichiers d'exclusion des dossiers parents s'appliquent jusqu'à la racine du dépôt.
+	assert.Equal(t, []string{"src/a.php", "src/cache.php", "src/keep.tpl.php", "src/lib/legacy/c.php"}, list(analyzer, "src"))
+}
+
+func TestMaxFileSize(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "small.php"), []byte("<?php echo 1;"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "generated.php"), []byte("<?php "+strings.Repeat("echo 1;", 1000)), 0o644))
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Out = &out
+	assert.NoError(t, runCommand(analyzer, "dead", []string{"-max-file-size", "1K", dir}, &out))
+	assert.Equal(t, 1, analyzer.outcome.files)
+	assert.Equal(t, 1, analyzer.outcome.skipped)
+
+	assert.Error(t, runCommand(NewPHPAnalyzer(), "dead", []string{"-max-file-size", "1X", dir}, io.Discard))
+}
+
+func TestByteSize(t *testing.T) {
+	for value, expected := range map[string]int64{"512": 512, "2K": 2 << 10, "3m": 3 << 20, "1GB": 1 << 30, "0": 0} {
+		var size byteSize
+		assert.NoError(t, size.Set(value), value)
+		assert.Equal(t, expected, int64(size), value)
+	}
+	var size byteSize
+	assert.Error(t, size.Set("-1M"))
+	assert.Error(t, size.Set("M"))
+}
+
+func TestMemoryBudgetStreams(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 20; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("f%02d.php", i))
+		assert.NoError(t, os.WriteFile(name, []byte("<?php echo 1;"), 0o644))
+	}
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Profile = ScanProfile{Workers: 4, Tier: TierFull}
+	analyzer.MemoryBudget = 1 // exceeded from the first file
+	analyzer.Out = &out
+	var running, ahead atomic.Int32
+	err := scanPHPFiles(context.Background(), []string{dir}, analyzer, func(_ context.Context, _ *PHPAnalyzer, path string) string {
+		ahead.Store(max(ahead.Load(), running.Add(1)))
+		return filepath.Base(path) + "\n"
+	}, func(name string) {
+		running.Add(-1)
+		out.WriteString(name)
+	})
+	assert.NoError(t, err)
+	assert.Len(t, strings.Fields(out.String()), 20)
+	assert.LessOrEqual(t, ahead.Load(), int32(4), "at most one file per worker is analyzed ahead of the output")
+}
+
+func TestScanCancellationKeepsPartialResults(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 10; i++ {
+		name := filepath.Join(dir, fmt.Sprintf("f%02d.php", i))
+		assert.NoError(t, os.WriteFile(name, []byte("<?php echo 1;"), 0o644))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	analyzer := NewPHPAnalyzer()
+	analyzer.Profile = ScanProfile{Workers: 1, Tier: TierFull}
+	var seen []string
+	err := scanPHPFiles(ctx, []string{dir}, analyzer, func(_ context.Context, _ *PHPAnalyzer, path string) string {
+		if filepath.Base(path) == "f02.php" {
+			cancel()
+		}
+		return filepath.Base(path)
+	}, func(name string) { seen = append(seen, name) })
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Contains(t, err.Error(), "analyse interrompue")
+	assert.Equal(t, []string{"f00.php", "f01.php"}, seen[:2], "results obtained before the interruption are kept")
+	assert.Less(t, len(seen), 10)
+}
+
+func TestFileTimeout(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.php", "slow.php", "z.php"} {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("<?php echo 1;"), 0o644))
+	}
+
+	analyzer := NewPHPAnalyzer()
+	analyzer.Profile = ScanProfile{Workers: 2, Tier: TierFull}
+	analyzer.FileTimeout = 20 * time.Millisecond
+	var interrupted []string
+	err := scanPHPFiles(context.Background(), []string{dir}, analyzer, func(ctx context.Context, fa *PHPAnalyzer, path string) bool {
+		if filepath.Base(path) == "slow.php" {
+			<-ctx.Done()
+		}
+		if fa.interrupted(ctx, path) {
+			interrupted = append(interrupted, filepath.Base(path))
+		}
+		return true
+	}, func(bool) {})
+	assert.NoError(t, err, "a file timeout does not stop the scan")
+	assert.Equal(t, []string{"slow.php"}, interrupted)
+}
+
+func TestParseHonorsDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Nanosecond)
+	defer cancel()
+	<-ctx.Done()
+	_, err := parsePHP(ctx, []byte("<?php echo 1;"))
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	for i := 0; i < 4; i++ {
+		tree, err := parsePHP(context.Background(), []byte("<?php echo 1;"))
+		if assert.NoError(t, err, "pooled parsers stay usable after a timeout") {
+			tree.Close()
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// SchemaColumn est une colonne déclarée par une instruction DDL.
+type SchemaColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// SchemaTable décrit une table créée (CREATE TABLE) ou modifiée (ALTER TABLE) par une
+// instruction DDL trouvée dans une chaîne du code : colonnes déclarées ou ajoutées, et
+// colonnes supprimées.
+type SchemaTable struct {
+	Table   string         `json:"table"`
+	Line    uint32         `json:"line"`
+	Altered bool           `json:"altered,omitempty"`
+	Columns []SchemaColumn `json:"columns"`
+	Dropped []string       `json:"dropped,omitempty"`
+}
+
+// String résume la définition, par exemple "Table users (ligne 3) : id INT, name TEXT".
+func (t SchemaTable) String() string {
+	var parts []string
+	for _, c := range t.Columns {
+		part := strings.TrimSpace(c.Name + " " + c.Type)
+		if t.Altered {
+			part = "+" + part
+		}
+		parts = append(parts, part)
+	}
+	for _, name := range t.Dropped {
+		parts = append(parts, "-"+name)
+	}
+	verb := ""
+	if t.Altered {
+		verb = " modifiée"
+	}
+	return fmt.Sprintf("Table %s%s (ligne %d) : %s", t.Table, verb, t.Line, strings.Join(parts, ", "))
+}
+
+var (
+	// ddlTablePattern matche le début d'une instruction CREATE TABLE ou ALTER TABLE et
+	// le nom de la table.
+	ddlTablePattern = regexp.MustCompile("(?i)\\b(CREATE|ALTER)\\s+(?:(?:TEMPORARY|TEMP|GLOBAL|LOCAL|UNLOGGED|ONLINE|IGNORE)\\s+)*TABLE\\s+" +
+		"(?:(?:IF\\s+(?:NOT\\s+)?EXISTS|ONLY)\\s+)*(" + sqlTableName + ")")
+	// ddlColumn matche une définition de colonne : nom puis type, avec ses éventuels
+	// paramètres (VARCHAR(255), DECIMAL(10, 2)).
+	ddlColumn = regexp.MustCompile("^(" + ddlColumnName + ")\\s+([A-Za-z_]\\w*(?:\\s+(?i:VARYING|PRECISION))?(?:\\s*\\([^)]*\\))?)")
+	// ddlAddColumn et ddlDropColumn matchent les clauses d'ALTER TABLE qui ajoutent ou
+	// suppriment une colonne.
+	ddlAddColumn  = regexp.MustCompile("(?i)^ADD\\s+(?:COLUMN\\s+)?(?:IF\\s+NOT\\s+EXISTS\\s+)?(.*)$")
+	ddlDropColumn = regexp.MustCompile("(?i)^DROP\\s+(?:COLUMN\\s+)?(?:IF\\s+EXISTS\\s+)?(" + ddlColumnName + ")")
+)
+
+// ddlColumnName matche un nom de colonne, éventuellement entre guillemets ou crochets.
+const ddlColumnName = "`[^`]+`|\"[^\"]+\"|\\[[^\\]]+\\]|\\w+"
+
+// ddlConstraintKeywords liste les mots-clés qui introduisent, dans une définition de
+// table, une contrainte ou un index plutôt qu'une colonne.
+var ddlConstraintKeywords = map[string]bool{
+	"PRIMARY": true, "KEY": true, "INDEX": true, "UNIQUE": true, "CONSTRAINT": true,
+	"FOREIGN": true, "CHECK": true, "FULLTEXT": true, "SPATIAL": true, "EXCLUDE": true,
+	"PERIOD": true,
+}
+
+// splitTopLevel découpe une liste SQL sur les virgules hors parenthèses et hors chaînes.
+func splitTopLevel(list string) []string {
+	var parts []string
+	depth, start := 0, 0
+	var quote rune
+	for i, r := range list {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+		case r == ',' && depth == 0:
+			parts = append(parts, strings.TrimSpace(list[start:i]))
+			start = i + 1
+		}
+	}
+	if rest := strings.TrimSpace(list[start:]); rest != "" {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+// closingParen retourne la position de la parenthèse fermant celle ouverte en s[0], ou
+// -1 si elle manque.
+func closingParen(s string) int {
+	depth := 0
+	var quote rune
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '\'' || r == '"' || r == '`':
+			quote = r
+		case r == '(':
+			depth++
+		case r == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// unquoteIdentifier retire les guillemets, accents graves ou crochets d'un identifiant.
+func unquoteIdentifier(name string) string {
+	return strings.NewReplacer("`", "", `"`, "", "[", "", "]", "").Replace(name)
+}
+
+// parseColumn lit une définition de colonne, ok étant faux pour une contrainte.
+func parseColumn(definition string) (SchemaColumn, bool) {
+	keyword, _, _ := strings.Cut(definition, " ")
+	if ddlConstraintKeywords[strings.ToUpper(strings.TrimRight(keyword, "("))] {
+		return SchemaColumn{}, false
+	}
+	match := ddlColumn.FindStringSubmatch(definition)
+	if match == nil {
+		return SchemaColumn{}, false
+	}
+	return SchemaColumn{Name: unquoteIdentifier(match[1]), Type: strings.Join(strings.Fields(match[2]), " ")}, true
+}
+
+// parseDDL analyse sommairement les instructions CREATE TABLE et ALTER TABLE d'un
+// texte SQL.
+func parseDDL(query string, line uint32) []SchemaTable {
+	var tables []SchemaTable
+	for _, match := range ddlTablePattern.FindAllStringSubmatchIndex(query, -1) {
+		table := SchemaTable{
+			Table:   unquoteIdentifier(query[match[4]:match[5]]),
+			Line:    line,
+			Altered: strings.EqualFold(query[match[2]:match[3]], "ALTER"),
+			Columns: []SchemaColumn{},
+		}
+		rest := query[match[1]:]
+		if end := strings.IndexByte(rest, ';'); end >= 0 && table.Altered {
+			rest = rest[:end]
+		}
+		if table.Altered {
+			for _, clause := range splitTopLevel(rest) {
+				if add := ddlAddColumn.FindStringSubmatch(clause); add != nil {
+					if column, ok := parseColumn(add[1]); ok {
+						table.Columns = append(table.Columns, column)
+					}
+				} else if drop := ddlDropColumn.FindStringSubmatch(clause); drop != nil && !ddlConstraintKeywords[strings.ToUpper(drop[1])] {
+					table.Dropped = append(table.Dropped, unquoteIdentifier(drop[1]))
+				}
+			}
+		} else if body := strings.TrimLeft(rest, " \t\r\n"); strings.HasPrefix(body, "(") {
+			if end := closingParen(body); end > 0 {
+				for _, definition := range splitTopLevel(body[1:end]) {
+					if column, ok := parseColumn(definition); ok {
+						table.Columns = append(table.Columns, column)
+					}
+				}
+			}
+		}
+		tables = append(tables, table)
+	}
+	return tables
+}
+
+// isConcatenationPart vérifie si un nœud est une opérande d'une concaténation, analysée
+// en entier à partir de son nœud racine.
+func isConcatenationPart(n *sitter.Node) bool {
+	parent := n.Parent()
+	if parent == nil || parent.Type() != "binary_expression" {
+		return false
+	}
+	operator := parent.ChildByFieldName("operator")
+	return operator != nil && operator.Type() == "."
+}
+
+// extractSchema relève les tables créées ou modifiées par les instructions DDL des
+// chaînes d'un fichier (scripts d'installation, migrations), concaténations comprises.
+func extractSchema(root *sitter.Node, source []byte) []SchemaTable {
+	var tables []SchemaTable
+	traverseAST(root, func(n *sitter.Node) {
+		switch n.Type() {
+		case "string", "encapsed_string", "heredoc", "nowdoc":
+		case "binary_expression":
+			if operator := n.ChildByFieldName("operator"); operator == nil || operator.Type() != "." {
+				return
+			}
+		default:
+			return
+		}
+		if isConcatenationPart(n) || !ddlTablePattern.Match([]byte(n.Content(source))) {
+			return
+		}
+		if query, ok := resolveSQL(n, source); ok {
+			tables = append(tables, parseDDL(query, n.StartPoint().Row+1)...)
+		}
+	})
+	return tables
+}
+
+// mergeSchema applique les définitions dans l'ordre pour obtenir les colonnes de chaque
+// table.
+func mergeSchema(tables []SchemaTable) map[string][]SchemaColumn {
+	schema := map[string][]SchemaColumn{}
+	for _, table := range tables {
+		columns := schema[table.Table]
+		if !table.Altered {
+			columns = nil
+		}
+		for _, column := range table.Columns {
+			if !containsColumn(columns, column.Name) {
+				columns = append(columns, column)
+			}
+		}
+		for _, name := range table.Dropped {
+			for i, column := range columns {
+				if strings.EqualFold(column.Name, name) {
+					columns = append(columns[:i:i], columns[i+1:]...)
+					break
+				}
+			}
+		}
+		if columns == nil {
+			columns = []SchemaColumn{}
+		}
+		schema[table.Table] = columns
+	}
+	return schema
+}
+
+// containsColumn vérifie si une colonne figure déjà dans une liste.
+func containsColumn(columns []SchemaColumn, name string) bool {
+	for _, column := range columns {
+		if strings.EqualFold(column.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeSchema écrit les définitions de tables relevées dans un fichier.
+func writeSchema(out io.Writer, tables []SchemaTable) {
+	for _, table := range tables {
+		fmt.Fprintln(out, table.String())
+	}
+}
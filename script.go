@@ -0,0 +1,171 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	sitter "github.com/smacker/go-tree-sitter"
+	"go.starlark.net/starlark"
+)
+
+// scriptMaxSteps borne le nombre d'étapes d'exécution d'un script de règle, pour qu'une
+// boucle infinie ou un calcul démesuré ne bloque pas l'analyse.
+const scriptMaxSteps = 100000
+
+// compileScript exécute le script Starlark de la règle, qui doit définir une fonction
+// check(match) à un paramètre. Le module est gelé : la fonction peut alors être appelée
+// depuis plusieurs analyses concurrentes. Starlark n'offre aucun accès aux fichiers, au
+// réseau ni au système.
+func (r *SignatureRule) compileScript() error {
+	thread := &starlark.Thread{Name: r.ID}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	globals, err := starlark.ExecFile(thread, r.ID+".star", r.Script, nil)
+	if err != nil {
+		return fmt.Errorf("script : %w", err)
+	}
+	globals.Freeze()
+	check, ok := globals["check"].(*starlark.Function)
+	if !ok {
+		return fmt.Errorf("script : fonction check(match) manquante")
+	}
+	if check.NumParams() != 1 {
+		return fmt.Errorf("script : check doit prendre un seul paramètre (match)")
+	}
+	r.check = check
+	return nil
+}
+
+// scriptMatch est la correspondance d'une règle transmise à check(match).
+type scriptMatch struct {
+	node       *sitter.Node // nœud signalé (nil pour une expression régulière)
+	function   string       // fonction appelée (signatures d'appels)
+	args       []string     // texte brut des arguments (signatures d'appels)
+	captures   map[string]string
+	nodes      map[string]*sitter.Node // nœuds des captures (requêtes tree-sitter)
+	phpVersion string
+}
+
+// runScript appelle check(match). Une valeur vraie retient la correspondance ; une
+// chaîne non vide remplace en outre le message du résultat. Une erreur du script (y
+// compris le dépassement de scriptMaxSteps) écarte la correspondance.
+func (r *SignatureRule) runScript(match scriptMatch, source []byte) (bool, string) {
+	if r.check == nil {
+		return true, ""
+	}
+	thread := &starlark.Thread{Name: r.ID}
+	thread.SetMaxExecutionSteps(scriptMaxSteps)
+	result, err := starlark.Call(thread, r.check, starlark.Tuple{match.value(source)}, nil)
+	if err != nil {
+		log.Printf("Règle %q : erreur du script : %v", r.ID, err)
+		return false, ""
+	}
+	if message, ok := result.(starlark.String); ok {
+		return message != "", string(message)
+	}
+	return bool(result.Truth()), ""
+}
+
+// value convertit la correspondance en structure Starlark : match.node, match.function,
+// match.args, match.captures, match.nodes et match.php_version.
+func (m scriptMatch) value(source []byte) starlark.Value {
+	args := make([]starlark.Value, len(m.args))
+	for i, arg := range m.args {
+		args[i] = starlark.String(arg)
+	}
+	captures := starlark.NewDict(len(m.captures))
+	for _, name := range sortedKeys(m.captures) {
+		captures.SetKey(starlark.String(name), starlark.String(m.captures[name]))
+	}
+	nodes := starlark.NewDict(len(m.nodes))
+	for name, node := range m.nodes {
+		nodes.SetKey(starlark.String(name), nodeValue(node, source))
+	}
+	return &scriptStruct{name: "match", fields: starlark.StringDict{
+		"node":        nodeValue(m.node, source),
+		"function":    starlark.String(m.function),
+		"args":        starlark.NewList(args),
+		"captures":    captures,
+		"nodes":       nodes,
+		"php_version": starlark.String(m.phpVersion),
+	}}
+}
+
+// sortedKeys retourne les clés d'une table dans l'ordre alphabétique.
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// scriptStruct est une structure Starlark en lecture seule.
+type scriptStruct struct {
+	name   string
+	fields starlark.StringDict
+}
+
+func (s *scriptStruct) String() string        { return s.name }
+func (s *scriptStruct) Type() string          { return s.name }
+func (s *scriptStruct) Freeze()               {}
+func (s *scriptStruct) Truth() starlark.Bool  { return starlark.True }
+func (s *scriptStruct) Hash() (uint32, error) { return 0, fmt.Errorf("%s non hachable", s.name) }
+func (s *scriptStruct) Attr(name string) (starlark.Value, error) {
+	return s.fields[name], nil
+}
+func (s *scriptStruct) AttrNames() []string { return s.fields.Keys() }
+
+// scriptNode expose un nœud de l'AST aux scripts : node.type, node.text, node.line,
+// node.children (enfants nommés), node.parent et node.field(nom).
+type scriptNode struct {
+	node   *sitter.Node
+	source []byte
+}
+
+// nodeValue convertit un nœud, None s'il est absent.
+func nodeValue(node *sitter.Node, source []byte) starlark.Value {
+	if node == nil {
+		return starlark.None
+	}
+	return &scriptNode{node: node, source: source}
+}
+
+func (n *scriptNode) String() string        { return n.node.Content(n.source) }
+func (n *scriptNode) Type() string          { return "node" }
+func (n *scriptNode) Freeze()               {}
+func (n *scriptNode) Truth() starlark.Bool  { return starlark.True }
+func (n *scriptNode) Hash() (uint32, error) { return n.node.StartByte(), nil }
+
+func (n *scriptNode) AttrNames() []string {
+	return []string{"children", "field", "line", "parent", "text", "type"}
+}
+
+func (n *scriptNode) Attr(name string) (starlark.Value, error) {
+	switch name {
+	case "type":
+		return starlark.String(n.node.Type()), nil
+	case "text":
+		return starlark.String(n.node.Content(n.source)), nil
+	case "line":
+		return starlark.MakeInt(int(n.node.StartPoint().Row) + 1), nil
+	case "parent":
+		return nodeValue(n.node.Parent(), n.source), nil
+	case "children":
+		children := make([]starlark.Value, 0, n.node.NamedChildCount())
+		for i := 0; i < int(n.node.NamedChildCount()); i++ {
+			children = append(children, nodeValue(n.node.NamedChild(i), n.source))
+		}
+		return starlark.NewList(children), nil
+	case "field":
+		return starlark.NewBuiltin("field", func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+			var field string
+			if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &field); err != nil {
+				return nil, err
+			}
+			return nodeValue(n.node.ChildByFieldName(field), n.source), nil
+		}), nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,671 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Règles de sécurité qui ne correspondent pas à une CVE précise mais à des
+// points d'audit récurrents (mauvaise configuration, mauvais usage d'API).
+
+// cookieFlagPositions indique, pour chaque fonction de cookie, la position
+// des arguments positionnels secure et httponly.
+var cookieFlagPositions = map[string]map[string]int{
+	"setcookie":                 {"secure": 5, "httponly": 6},
+	"setrawcookie":              {"secure": 5, "httponly": 6},
+	"session_set_cookie_params": {"secure": 3, "httponly": 4},
+}
+
+// cookieFlags liste les options attendues sur un cookie sécurisé.
+var cookieFlags = []string{"secure", "httponly", "samesite"}
+
+// getArgumentNodes retourne les nœuds "argument" passés à un appel de fonction.
+func getArgumentNodes(node *sitter.Node) []*sitter.Node {
+	var args []*sitter.Node
+	argsNode := node.ChildByFieldName("arguments")
+	if argsNode == nil {
+		return args
+	}
+	for i := 0; i < int(argsNode.NamedChildCount()); i++ {
+		if child := argsNode.NamedChild(i); child.Type() == "argument" {
+			args = append(args, child)
+		}
+	}
+	return args
+}
+
+// argumentValue retourne l'expression d'un argument, sans son éventuel nom (argument nommé).
+func argumentValue(arg *sitter.Node) *sitter.Node {
+	for i := int(arg.NamedChildCount()) - 1; i >= 0; i-- {
+		child := arg.NamedChild(i)
+		if arg.FieldNameForChild(i) != "name" {
+			return child
+		}
+	}
+	return nil
+}
+
+// isFalsyLiteral vérifie si le texte correspond à une valeur littérale fausse en PHP.
+func isFalsyLiteral(text string) bool {
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(text), `"'`)) {
+	case "false", "0", "", "null":
+		return true
+	}
+	return false
+}
+
+// missingCookieFlags retourne les options secure/httponly/samesite absentes d'un appel
+// à setcookie, setrawcookie ou session_set_cookie_params. Le tableau d'options (PHP 7.3+),
+// les arguments nommés et la forme positionnelle sont pris en charge. Si les options sont
+// passées via une variable, aucune conclusion n'est possible et nil est retourné.
+func missingCookieFlags(node *sitter.Node, funcName string, source []byte) []string {
+	positions := cookieFlagPositions[funcName]
+	present := make(map[string]bool)
+	unknown := false
+
+	for i, arg := range getArgumentNodes(node) {
+		value := argumentValue(arg)
+		if value == nil {
+			continue
+		}
+		if nameNode := arg.ChildByFieldName("name"); nameNode != nil {
+			name := strings.ToLower(nameNode.Content(source))
+			if name == "options" || name == "lifetime_or_options" || name == "expires_or_options" {
+				if value.Type() != "array_creation_expression" {
+					unknown = true
+					continue
+				}
+				collectCookieOptions(value, source, present)
+				continue
+			}
+			if !isFalsyLiteral(value.Content(source)) {
+				present[name] = true
+			}
+			continue
+		}
+		if value.Type() == "array_creation_expression" {
+			collectCookieOptions(value, source, present)
+			continue
+		}
+		for flag, pos := range positions {
+			if pos == i && !isFalsyLiteral(value.Content(source)) {
+				present[flag] = true
+			}
+		}
+	}
+
+	if unknown {
+		return nil
+	}
+	var missing []string
+	for _, flag := range cookieFlags {
+		if !present[flag] {
+			missing = append(missing, flag)
+		}
+	}
+	return missing
+}
+
+// collectCookieOptions marque les clés secure/httponly/samesite présentes (et non fausses)
+// dans un tableau d'options de cookie.
+func collectCookieOptions(array *sitter.Node, source []byte, present map[string]bool) {
+	for i := 0; i < int(array.NamedChildCount()); i++ {
+		element := array.NamedChild(i)
+		if element.Type() != "array_element_initializer" || element.NamedChildCount() < 2 {
+			continue
+		}
+		key := strings.ToLower(strings.Trim(element.NamedChild(0).Content(source), `"'`))
+		value := element.NamedChild(int(element.NamedChildCount()) - 1)
+		if !isFalsyLiteral(value.Content(source)) {
+			present[key] = true
+		}
+	}
+}
+
+// authenticationFunctions liste les fonctions qui authentifient un utilisateur ;
+// password_verify n'authentifie que dans la branche où elle réussit.
+var authenticationFunctions = map[string]bool{
+	"password_verify":    true,
+	"wp_signon":          true,
+	"wp_set_auth_cookie": true,
+}
+
+// authenticationMethods liste les méthodes d'authentification des frameworks, appelées
+// sur la façade Auth (Auth::attempt) ou sur un objet d'authentification ($auth->login,
+// auth()->attempt), en minuscules.
+var authenticationMethods = map[string]bool{
+	"attempt":      true,
+	"attemptwhen":  true,
+	"login":        true,
+	"loginusingid": true,
+	"authenticate": true,
+}
+
+// authenticatedRegion retourne le code exécuté après une authentification réussie par
+// l'appel call, ou nil si call n'est pas une API d'authentification connue. Pour
+// password_verify, c'est la branche où elle réussit (passwordCheckSuccess). La façade
+// Auth utilise toujours la session (implicit).
+func authenticatedRegion(call *sitter.Node, source []byte) (region *sitter.Node, implicit bool) {
+	switch call.Type() {
+	case "function_call_expression":
+		name := strings.ToLower(strings.TrimPrefix(extractFunctionName(call, source), `\`))
+		switch {
+		case name == "password_verify":
+			return passwordCheckSuccess(call), false
+		case authenticationFunctions[name]:
+			return enclosingScope(call), false
+		}
+	case "member_call_expression":
+		object, method := call.ChildByFieldName("object"), call.ChildByFieldName("name")
+		if object != nil && method != nil && authenticationMethods[strings.ToLower(method.Content(source))] &&
+			strings.Contains(strings.ToLower(object.Content(source)), "auth") {
+			return enclosingScope(call), false
+		}
+	case "scoped_call_expression":
+		scope, method := call.ChildByFieldName("scope"), call.ChildByFieldName("name")
+		if scope != nil && method != nil && authenticationMethods[strings.ToLower(method.Content(source))] {
+			class := scope.Content(source)
+			if strings.EqualFold(class[strings.LastIndex(class, `\`)+1:], "Auth") {
+				return enclosingScope(call), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// passwordCheckSuccess retourne la branche où l'appel password_verify a réussi : le
+// corps du if qui le teste, ou la fonction englobante si le if teste son échec
+// (if (!password_verify(...)) return;). Un appel dont le résultat n'est pas testé
+// directement par un if retourne nil.
+func passwordCheckSuccess(call *sitter.Node) *sitter.Node {
+	negated := false
+	for parent := call.Parent(); parent != nil; parent = parent.Parent() {
+		switch parent.Type() {
+		case "parenthesized_expression":
+			if statement := parent.Parent(); statement != nil && (statement.Type() == "if_statement" || statement.Type() == "else_if_clause") {
+				if negated {
+					return enclosingScope(call)
+				}
+				return statement.ChildByFieldName("body")
+			}
+		case "unary_op_expression":
+			if operator := parent.ChildByFieldName("operator"); operator == nil || operator.Type() != "!" {
+				return nil
+			}
+			negated = !negated
+		case "binary_expression":
+			// Le succès de a && b implique celui de a, l'échec de a || b celui de a.
+			operator := parent.ChildByFieldName("operator")
+			if operator == nil {
+				return nil
+			}
+			switch op := strings.ToLower(operator.Type()); {
+			case (op == "&&" || op == "and") && !negated, (op == "||" || op == "or") && negated:
+			default:
+				return nil
+			}
+		default:
+			return nil
+		}
+	}
+	return nil
+}
+
+// isTaintedSessionID vérifie si session_id() reçoit un identifiant issu d'une entrée utilisateur.
+func isTaintedSessionID(node *sitter.Node, taint *TaintTracker) bool {
+	args := getArgumentNodes(node)
+	return len(args) > 0 && taint.IsTainted(argumentValue(args[0]))
+}
+
+// missesSessionRegeneration vérifie, pour un appel d'authentification, que la région
+// authentifiée utilise la session ($_SESSION, session_start, session() et Session:: des
+// frameworks) sans que l'identifiant de session soit régénéré après l'appel
+// (session_regenerate_id, $request->session()->regenerate(), $session->migrate()).
+func missesSessionRegeneration(node, region *sitter.Node, implicit bool, source []byte) bool {
+	usesSession := implicit
+	traverseAST(region, func(n *sitter.Node) {
+		switch n.Type() {
+		case "variable_name":
+			if n.Content(source) == "$_SESSION" {
+				usesSession = true
+			}
+		case "function_call_expression", "member_call_expression", "scoped_call_expression":
+			name := strings.ToLower(extractFunctionName(n, source))
+			if name == "session_start" || name == "session" || strings.HasPrefix(name, "session::") {
+				usesSession = true
+			}
+		}
+	})
+	regenerated := false
+	traverseAST(enclosingScope(node), func(n *sitter.Node) {
+		if n.StartByte() <= node.StartByte() {
+			return
+		}
+		switch name := strings.ToLower(extractFunctionName(n, source)); n.Type() {
+		case "function_call_expression":
+			regenerated = regenerated || name == "session_regenerate_id"
+		case "member_call_expression", "scoped_call_expression":
+			name = name[strings.LastIndex(name, ":")+1:]
+			regenerated = regenerated || (name == "regenerate" || name == "migrate") &&
+				strings.Contains(strings.ToLower(n.Content(source)), "session")
+		}
+	})
+	return usesSession && !regenerated
+}
+
+// hashFunctions liste les fonctions dont le résultat ne doit être comparé qu'avec
+// hash_equals() ou ===, une chaîne "0e..." étant interprétée comme un nombre avec ==.
+var hashFunctions = map[string]bool{
+	"md5":       true,
+	"sha1":      true,
+	"hash":      true,
+	"hash_hmac": true,
+	"crypt":     true,
+	"crc32":     true,
+}
+
+// strcmpFunctions retournent null (égal à 0 avec ==) lorsqu'ils reçoivent un tableau.
+var strcmpFunctions = map[string]bool{
+	"strcmp":     true,
+	"strcasecmp": true,
+}
+
+// sensitiveNamePattern reconnaît les variables et propriétés contenant un secret.
+var sensitiveNamePattern = regexp.MustCompile(`(?i)(pass(word|wd)?|pwd|hash|token|secret|hmac|signature|digest|api_?key)`)
+
+// isLooseSensitiveComparison vérifie si une comparaison == / != porte sur le résultat
+// d'une fonction de hachage, d'un strcmp ou sur une variable au nom sensible.
+func isLooseSensitiveComparison(node *sitter.Node, source []byte) bool {
+	operator := node.ChildByFieldName("operator")
+	if operator == nil {
+		return false
+	}
+	switch operator.Type() {
+	case "==", "!=", "<>":
+	default:
+		return false
+	}
+	for _, field := range []string{"left", "right"} {
+		if isSensitiveOperand(node.ChildByFieldName(field), source) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSensitiveOperand(operand *sitter.Node, source []byte) bool {
+	if operand == nil {
+		return false
+	}
+	switch operand.Type() {
+	case "function_call_expression":
+		name := strings.ToLower(extractFunctionName(operand, source))
+		return hashFunctions[name] || strcmpFunctions[name]
+	case "variable_name", "member_access_expression", "subscript_expression":
+		return sensitiveNamePattern.MatchString(operand.Content(source))
+	}
+	return false
+}
+
+// uploadNamePattern reconnaît le nom de fichier fourni par le client : $_FILES[...]['name'].
+var uploadNamePattern = regexp.MustCompile(`^\$_FILES\s*\[.*\]\s*\[\s*['"]name['"]\s*\]$`)
+
+// isUploadedFileName reconnaît l'accès au nom d'origine d'un fichier téléversé.
+func isUploadedFileName(n *sitter.Node, source []byte) bool {
+	return n.Type() == "subscript_expression" && uploadNamePattern.MatchString(n.Content(source))
+}
+
+// Contrôles d'un fichier téléversé, combinés dans les états suivis par uploadChecksAt.
+const (
+	uploadExtensionChecked uint32 = 1 << iota // liste blanche d'extensions ou renommage aléatoire
+	uploadContentChecked                      // validation du type MIME ou de la taille
+)
+
+// uploadCheckCalls associe les fonctions et méthodes (en minuscules) qui contrôlent un
+// fichier téléversé au contrôle qu'elles effectuent.
+var uploadCheckCalls = map[string]uint32{
+	"pathinfo":                   uploadExtensionChecked,
+	"getclientoriginalextension": uploadExtensionChecked,
+	"guessextension":             uploadExtensionChecked,
+	"uniqid":                     uploadExtensionChecked,
+	"random_bytes":               uploadExtensionChecked,
+	"random_int":                 uploadExtensionChecked,
+	"tempnam":                    uploadExtensionChecked,
+	"finfo_file":                 uploadContentChecked,
+	"finfo_buffer":               uploadContentChecked,
+	"mime_content_type":          uploadContentChecked,
+	"getimagesize":               uploadContentChecked,
+	"exif_imagetype":             uploadContentChecked,
+	"getmimetype":                uploadContentChecked,
+	"getclientmimetype":          uploadContentChecked,
+}
+
+// uploadCheck retourne les contrôles effectués par l'évaluation d'un nœud : appel d'une
+// fonction de uploadCheckCalls, preg_match sur une extension ('/\.(png|jpg)$/') ou
+// lecture de ['type'] ou ['size'].
+func uploadCheck(n *sitter.Node, source []byte) uint32 {
+	switch n.Type() {
+	case "function_call_expression", "member_call_expression", "scoped_call_expression":
+		name := transactionName(n, source)
+		if name == "preg_match" && strings.Contains(n.Content(source), `\.(`) {
+			return uploadExtensionChecked
+		}
+		return uploadCheckCalls[name]
+	case "subscript_expression":
+		if index := n.NamedChild(int(n.NamedChildCount()) - 1); index != nil && n.NamedChildCount() > 1 {
+			if key, ok := literalString(index, source); ok && (key == "type" || key == "size") {
+				return uploadContentChecked
+			}
+		}
+	}
+	return 0
+}
+
+// uploadChecksAt retourne les combinaisons de contrôles effectués sur les chemins qui
+// mènent de l'entrée de la fonction (ou du script) à l'appel call : un contrôle placé
+// après l'appel, ou dans une seule des branches qui y mènent, ne couvre pas tous les
+// chemins. Le résultat est vide si l'appel est inatteignable.
+func uploadChecksAt(call *sitter.Node, source []byte) txStates {
+	body := call
+	for body.Parent() != nil && !isNestedScope(body.Parent()) {
+		body = body.Parent()
+	}
+	reached := txStates{}
+	tp := &transactionPaths{source: source, exits: make(map[uint32]uint32)}
+	tp.transfer = func(n *sitter.Node, states txStates) txStates {
+		if n.Equal(call) {
+			reached = reached.union(states)
+			return states
+		}
+		check := uploadCheck(n, source)
+		if check == 0 {
+			return states
+		}
+		next := make(txStates, len(states))
+		for state := range states {
+			next[state|check] = true
+		}
+		return next
+	}
+	tp.exec(body, txStates{0: true})
+	return reached
+}
+
+// unsafeUploadIssues analyse un appel à move_uploaded_file. Il retourne les contrôles
+// manquants (extension, MIME/taille) sur au moins un chemin menant à l'appel lorsque la
+// destination dérive du nom fourni par le client, ou nil si la destination n'en dépend
+// pas ou si l'extension est contrôlée sur tous les chemins.
+func unsafeUploadIssues(node *sitter.Node, root *sitter.Node, source []byte) []string {
+	args := getArgumentNodes(node)
+	if len(args) < 2 {
+		return nil
+	}
+	names := newDerivationTracker(root, source, isUploadedFileName)
+	if !names.IsTainted(argumentValue(args[1])) {
+		return nil
+	}
+
+	checked := uploadExtensionChecked | uploadContentChecked
+	for state := range uploadChecksAt(node, source) {
+		checked &= state
+	}
+	if checked&uploadExtensionChecked != 0 {
+		return nil
+	}
+	issues := []string{"sans liste blanche d'extensions ni renommage aléatoire"}
+	if checked&uploadContentChecked == 0 {
+		issues = append(issues, "sans validation du type MIME ni de la taille")
+	}
+	return issues
+}
+
+// enclosingScope retourne la fonction ou méthode englobante, ou la racine du fichier.
+func enclosingScope(node *sitter.Node) *sitter.Node {
+	scope := node
+	for scope.Parent() != nil {
+		t := scope.Type()
+		if t == "function_definition" || t == "method_declaration" {
+			break
+		}
+		scope = scope.Parent()
+	}
+	return scope
+}
+
+// isTruthyIniValue vérifie si une valeur passée à ini_set active l'option.
+func isTruthyIniValue(text string) bool {
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(text), `"'`)) {
+	case "1", "on", "true", "yes", "stdout", "stderr":
+		return true
+	}
+	return false
+}
+
+// dangerousIniSetting retourne l'option dangereuse activée par un appel à ini_set, ou "".
+func dangerousIniSetting(node *sitter.Node, source []byte) string {
+	args := getArguments(node, source)
+	if len(args) < 2 || !isTruthyIniValue(args[1]) {
+		return ""
+	}
+	switch option := strings.Trim(strings.TrimSpace(args[0]), `"'`); option {
+	case "allow_url_include", "allow_url_fopen", "display_errors", "display_startup_errors", "expose_php":
+		return option
+	}
+	return ""
+}
+
+// devConditionPattern reconnaît une condition qui restreint le code à un environnement de développement.
+var devConditionPattern = regexp.MustCompile(`(?i)\b(dev|development|debug|local|test(ing)?)\b`)
+
+// isErrorReportingDisabled vérifie si error_reporting(0) est appelé en dehors d'un bloc
+// réservé au développement : le corps d'un if ou d'un elseif dont la condition mentionne
+// dev/debug/local/test. Les branches else de ces conditions sont le code de production.
+func isErrorReportingDisabled(node *sitter.Node, source []byte) bool {
+	args := getArguments(node, source)
+	if len(args) == 0 || strings.TrimSpace(args[0]) != "0" {
+		return false
+	}
+	for child, parent := node, node.Parent(); parent != nil; child, parent = parent, parent.Parent() {
+		if parent.Type() == "if_statement" || parent.Type() == "else_if_clause" {
+			condition, body := parent.ChildByFieldName("condition"), parent.ChildByFieldName("body")
+			if condition != nil && body != nil && body.Equal(child) &&
+				devConditionPattern.MatchString(condition.Content(source)) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isRegisterGlobalsExtract vérifie si extract() importe directement une superglobale,
+// ce qui reproduit le comportement de register_globals.
+func isRegisterGlobalsExtract(node *sitter.Node, source []byte) bool {
+	args := getArgumentNodes(node)
+	if len(args) == 0 {
+		return false
+	}
+	value := argumentValue(args[0])
+	return value != nil && value.Type() == "variable_name" && taintSources[value.Content(source)]
+}
+
+// debugFunctions liste les fonctions qui écrivent des informations de débogage dans la réponse.
+var debugFunctions = map[string]bool{
+	"var_dump":              true,
+	"print_r":               true,
+	"debug_print_backtrace": true,
+	"debug_zval_dump":       true,
+	"phpinfo":               true,
+}
+
+// isDebugLeftover vérifie si l'appel produit une sortie de débogage. print_r n'est
+// signalé que sans son second argument à true (mode retour de chaîne).
+func isDebugLeftover(node *sitter.Node, funcName string, source []byte) bool {
+	name := strings.ToLower(funcName)
+	if !debugFunctions[name] {
+		return false
+	}
+	if name == "print_r" {
+		args := getArguments(node, source)
+		return len(args) < 2 || isFalsyLiteral(args[1])
+	}
+	return true
+}
+
+// errorFunctions retournent le détail d'une erreur de base de données ou PHP.
+var errorFunctions = map[string]bool{
+	"mysql_error":          true,
+	"mysqli_error":         true,
+	"mysqli_connect_error": true,
+	"pg_last_error":        true,
+	"sqlsrv_errors":        true,
+	"oci_error":            true,
+	"error_get_last":       true,
+}
+
+// exceptionMethods exposent le message ou la pile d'appels d'une exception.
+var exceptionMethods = map[string]bool{
+	"getMessage":       true,
+	"getTraceAsString": true,
+	"getTrace":         true,
+	"errorInfo":        true,
+}
+
+// disclosedError retourne la première expression d'erreur écrite par un nœud de sortie
+// (echo, print, exit, die, printf), ou "" si aucune.
+func disclosedError(node *sitter.Node, source []byte) string {
+	disclosed := ""
+	traverseAST(node, func(n *sitter.Node) {
+		if disclosed != "" {
+			return
+		}
+		switch n.Type() {
+		case "function_call_expression":
+			if errorFunctions[strings.ToLower(extractFunctionName(n, source))] {
+				disclosed = n.Content(source)
+			}
+		case "member_call_expression":
+			if exceptionMethods[extractFunctionName(n, source)] {
+				disclosed = n.Content(source)
+			}
+		case "member_access_expression":
+			if name := n.ChildByFieldName("name"); name != nil && name.Content(source) == "error" {
+				disclosed = n.Content(source)
+			}
+		}
+	})
+	return disclosed
+}
+
+// pharFileFunctions sont les fonctions de fichiers qui déclenchent la désérialisation
+// des métadonnées d'une archive lorsque leur chemin utilise le wrapper phar://.
+var pharFileFunctions = map[string]bool{
+	"file_exists":       true,
+	"is_file":           true,
+	"is_dir":            true,
+	"is_link":           true,
+	"is_readable":       true,
+	"is_writable":       true,
+	"is_writeable":      true,
+	"is_executable":     true,
+	"fopen":             true,
+	"file":              true,
+	"file_get_contents": true,
+	"file_put_contents": true,
+	"readfile":          true,
+	"filesize":          true,
+	"filemtime":         true,
+	"fileatime":         true,
+	"filectime":         true,
+	"fileinode":         true,
+	"fileowner":         true,
+	"filegroup":         true,
+	"fileperms":         true,
+	"filetype":          true,
+	"stat":              true,
+	"lstat":             true,
+	"touch":             true,
+	"unlink":            true,
+	"copy":              true,
+	"rename":            true,
+	"opendir":           true,
+	"scandir":           true,
+	"md5_file":          true,
+	"sha1_file":         true,
+	"hash_file":         true,
+	"parse_ini_file":    true,
+	"getimagesize":      true,
+	"exif_read_data":    true,
+	"exif_thumbnail":    true,
+	"mime_content_type": true,
+	"finfo_file":        true,
+}
+
+// pharPathArgument retourne la position du chemin pour les fonctions dont ce n'est pas
+// le premier argument.
+var pharPathArgument = map[string]int{
+	"hash_file":  1,
+	"finfo_file": 1,
+}
+
+// isPharSink vérifie si un appel de fonction de fichiers reçoit un chemin contaminé
+// pouvant commencer par phar:// : un chemin préfixé par un littéral ou une constante
+// (__DIR__ . $f, "uploads/$f") ne peut pas changer de wrapper.
+func isPharSink(node *sitter.Node, funcName string, taint *TaintTracker, source []byte) bool {
+	name := strings.ToLower(funcName)
+	if node.Type() != "function_call_expression" || !pharFileFunctions[name] {
+		return false
+	}
+	args := getArgumentNodes(node)
+	position := pharPathArgument[name]
+	if position >= len(args) {
+		return false
+	}
+	path := argumentValue(args[position])
+	if path == nil || uploadTmpNamePattern.MatchString(path.Content(source)) {
+		return false
+	}
+	return taint.IsTainted(path) && !hasFixedPrefix(path, source)
+}
+
+// uploadTmpNamePattern reconnaît le chemin temporaire d'un fichier téléversé, choisi par PHP.
+var uploadTmpNamePattern = regexp.MustCompile(`^\$_FILES\s*\[.*\]\s*\[\s*['"]tmp_name['"]\s*\]$`)
+
+// hasFixedPrefix vérifie si une chaîne commence par une partie non vide qui ne dépend pas
+// de l'utilisateur : littéral, constante, ou variable dont toutes les affectations
+// précédentes dans la fonction ont un tel préfixe.
+func hasFixedPrefix(expr *sitter.Node, source []byte) bool {
+	if expr == nil {
+		return false
+	}
+	switch expr.Type() {
+	case "string":
+		return len(strings.Trim(expr.Content(source), `"'`)) > 0
+	case "encapsed_string", "heredoc":
+		return expr.NamedChildCount() > 0 && expr.NamedChild(0).Type() == "string_content"
+	case "name", "qualified_name", "class_constant_access_expression":
+		return true
+	case "parenthesized_expression":
+		return hasFixedPrefix(expr.NamedChild(0), source)
+	case "binary_expression":
+		operator := expr.ChildByFieldName("operator")
+		return operator != nil && operator.Type() == "." && hasFixedPrefix(expr.ChildByFieldName("left"), source)
+	case "variable_name":
+		assigned, fixed := false, true
+		traverseAST(enclosingScope(expr), func(n *sitter.Node) {
+			if n.Type() != "assignment_expression" || n.StartByte() > expr.StartByte() {
+				return
+			}
+			if left := n.ChildByFieldName("left"); left == nil || left.Content(source) != expr.Content(source) {
+				return
+			}
+			assigned = true
+			if !hasFixedPrefix(n.ChildByFieldName("right"), source) {
+				fixed = false
+			}
+		})
+		return assigned && fixed
+	}
+	return false
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultConfigPath est le fichier de configuration du projet lu par défaut.
+const defaultConfigPath = ".phpanalyzer.yaml"
+
+// RuleSelection choisit les règles exécutées. Chaque nom désigne une catégorie (nom de
+// détecteur : cve, taint, crypto, debug, security, dbcalls, metrics) ou une règle
+// (sqli, CVE-2019-9025...). Only restreint l'analyse aux noms listés, Enable et Disable
+// activent ou désactivent des noms, dans cet ordre. Activer une règle active sa
+// catégorie.
+type RuleSelection struct {
+	Enable  []string `yaml:"enable"`
+	Disable []string `yaml:"disable"`
+	Only    []string `yaml:"only"`
+}
+
+// ProjectConfig est la configuration d'un projet (.phpanalyzer.yaml).
+type ProjectConfig struct {
+	RuleSelection `yaml:",inline"`
+
+	// Severity remplace la sévérité de règles ou de catégories, par exemple
+	// debug-leftover: info ou sqli: blocker.
+	Severity map[string]string `yaml:"severity"`
+
+	// EntryPoints complète les points d'entrée par défaut de dead-functions.
+	EntryPoints EntryPoints `yaml:"entry-points"`
+
+	// Lint règle le détecteur lint.
+	Lint LintOptions `yaml:"lint"`
+}
+
+// LoadProjectConfig lit la configuration d'un projet ; un fichier absent donne une
+// configuration vide.
+func LoadProjectConfig(path string) (ProjectConfig, error) {
+	var config ProjectConfig
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return config, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ruleFilter retient les résultats selon la sélection de règles.
+type ruleFilter struct {
+	only      bool
+	detectors map[string]bool // catégories retenues entièrement (only)
+	rules     map[string]bool // règles retenues (only)
+	disabled  map[string]bool // règles désactivées
+}
+
+// allows indique si un résultat de la règle rule, produit par le détecteur detector,
+// est conservé.
+func (f ruleFilter) allows(detector, rule string) bool {
+	if f.disabled[rule] {
+		return false
+	}
+	return !f.only || f.detectors[detector] || f.rules[rule]
+}
+
+// ruleDetector retourne le détecteur qui produit la règle id, ou "" si elle est inconnue.
+func (pa *PHPAnalyzer) ruleDetector(id string) string {
+	for _, info := range RegisteredDetectors() {
+		for _, rule := range info.Rules {
+			if rule == id {
+				return info.Name
+			}
+		}
+	}
+	if pa.Signatures.Rule(id) != nil {
+		return "cve"
+	}
+	return ""
+}
+
+// SelectRules applique une sélection de règles à l'analyseur. Les sélections successives
+// se cumulent (configuration du projet puis options de la ligne de commande) ; un Only
+// non vide remplace la restriction précédente.
+func (pa *PHPAnalyzer) SelectRules(selection RuleSelection) error {
+	resolve := func(name string) (detector string, isDetector bool, err error) {
+		if _, ok := detectorRegistry[name]; ok {
+			return name, true, nil
+		}
+		if detector := pa.ruleDetector(name); detector != "" {
+			return detector, false, nil
+		}
+		return "", false, fmt.Errorf("règle ou catégorie inconnue : %q", name)
+	}
+	for _, names := range [][]string{selection.Only, selection.Enable, selection.Disable} {
+		for _, name := range names {
+			if _, _, err := resolve(name); err != nil {
+				return err
+			}
+		}
+	}
+
+	filter := ruleFilter{only: pa.rules.only, detectors: pa.rules.detectors, rules: pa.rules.rules, disabled: make(map[string]bool)}
+	for rule := range pa.rules.disabled {
+		filter.disabled[rule] = true
+	}
+	if len(selection.Only) > 0 {
+		filter.only = true
+		filter.detectors = make(map[string]bool)
+		filter.rules = make(map[string]bool)
+		for name := range detectorRegistry {
+			pa.SetDetectorEnabled(name, false)
+		}
+		for _, name := range selection.Only {
+			detector, isDetector, _ := resolve(name)
+			pa.SetDetectorEnabled(detector, true)
+			if isDetector {
+				filter.detectors[name] = true
+			} else {
+				filter.rules[name] = true
+			}
+		}
+	} else if filter.only {
+		filter.detectors = copySet(filter.detectors)
+		filter.rules = copySet(filter.rules)
+	}
+	for _, name := range selection.Enable {
+		detector, isDetector, _ := resolve(name)
+		pa.SetDetectorEnabled(detector, true)
+		delete(filter.disabled, name)
+		switch {
+		case !filter.only:
+		case isDetector:
+			filter.detectors[name] = true
+		default:
+			filter.rules[name] = true
+		}
+	}
+	for _, name := range selection.Disable {
+		if _, isDetector, _ := resolve(name); isDetector {
+			pa.SetDetectorEnabled(name, false)
+		} else {
+			filter.disabled[name] = true
+		}
+	}
+	pa.rules = filter
+	return nil
+}
+
+// copySet copie un ensemble, pour ne pas modifier celui d'un analyseur dont pa est issu.
+func copySet(set map[string]bool) map[string]bool {
+	copied := make(map[string]bool, len(set))
+	for key, value := range set {
+		copied[key] = value
+	}
+	return copied
+}
+
+// listFlag est un flag répétable dont chaque valeur peut contenir plusieurs noms séparés
+// par des virgules.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(value string) error {
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			*l = append(*l, name)
+		}
+	}
+	return nil
+}
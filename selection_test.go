@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const selectionPHPCode = `<?php
+echo $_GET["name"];
+mysql_query("SELECT * FROM t WHERE id=" . $_GET["id"]);
+$enc = openssl_encrypt($data, "des-ede3", $key, 0, $iv);
+var_dump($data);
+mb_split("\w", $text);`
+
+// selectedRules retourne les règles des résultats obtenus avec les sélections données.
+func selectedRules(t *testing.T, analyzer *PHPAnalyzer, selections ...RuleSelection) []string {
+	for _, selection := range selections {
+		assert.NoError(t, analyzer.SelectRules(selection))
+	}
+	tree, err := analyzer.Parse(context.Background(), []byte(selectionPHPCode))
+	assert.NoError(t, err)
+	var ids []string
+	for _, f := range analyzer.DetectVulnerabilities(context.Background(), tree, []byte(selectionPHPCode)) {
+		ids = append(ids, f.RuleID)
+	}
+	return ids
+}
+
+func TestEveryBuiltinRuleHasADetector(t *testing.T) {
+	analyzer := NewPHPAnalyzer()
+	seen := make(map[string]string)
+	for _, info := range RegisteredDetectors() {
+		for _, rule := range info.Rules {
+			assert.NotContains(t, seen, rule, "rule %q declared by %s and %s", rule, seen[rule], info.Name)
+			seen[rule] = info.Name
+		}
+	}
+	for id := range builtinRules {
+		assert.NotEmpty(t, analyzer.ruleDetector(id), id)
+	}
+	assert.Equal(t, "cve", analyzer.ruleDetector("CVE-2019-9025"))
+}
+
+func TestRuleSelection(t *testing.T) {
+	assert.Equal(t, []string{"xss", "sqli", "weak-cipher", "debug-leftover", "CVE-2019-9025"}, selectedRules(t, NewPHPAnalyzer()))
+
+	assert.Equal(t, []string{"xss", "sqli", "weak-cipher"},
+		selectedRules(t, NewPHPAnalyzer(), RuleSelection{Only: []string{"taint", "weak-cipher"}}))
+
+	assert.Equal(t, []string{"dbcall", "sqli"},
+		selectedRules(t, NewPHPAnalyzer(), RuleSelection{Only: []string{"taint"}, Enable: []string{"dbcalls"}, Disable: []string{"xss"}}))
+
+	assert.Equal(t, []string{"xss", "sqli", "weak-cipher"},
+		selectedRules(t, NewPHPAnalyzer(), RuleSelection{Disable: []string{"debug", "CVE-2019-9025"}}))
+
+	assert.ErrorContains(t, NewPHPAnalyzer().SelectRules(RuleSelection{Enable: []string{"nope"}}), `règle ou catégorie inconnue : "nope"`)
+}
+
+func TestCommandLineOverridesProjectConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, defaultConfigPath)
+	assert.NoError(t, os.WriteFile(config, []byte("only: [taint, debug]\ndisable: [xss, debug-leftover]\n"), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	flags := &selectionFlags{config: config}
+	assert.NoError(t, flags.enable.Set("debug-leftover, crypto"))
+	assert.NoError(t, applySelection(analyzer, flags))
+	assert.Equal(t, []string{"sqli", "weak-cipher", "debug-leftover"}, selectedRules(t, analyzer))
+
+	// Une nouvelle commande du démon repart de la configuration seule.
+	assert.NoError(t, applySelection(analyzer, &selectionFlags{config: config}))
+	assert.Equal(t, []string{"sqli"}, selectedRules(t, analyzer))
+}
+
+func TestProjectSeverityOverrides(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, defaultConfigPath)
+	assert.NoError(t, os.WriteFile(config, []byte("severity:\n  debug-leftover: info\n  sqli: blocker\n  crypto: low\n  cve: high\n  CVE-2019-9025: critical\n"), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	analyzer.Calibration = Calibration{"sqli": 2}
+	assert.NoError(t, applySelection(analyzer, &selectionFlags{config: config}))
+	tree, err := analyzer.Parse(context.Background(), []byte(selectionPHPCode))
+	assert.NoError(t, err)
+	severities := make(map[string]Severity)
+	for _, f := range analyzer.DetectVulnerabilities(context.Background(), tree, []byte(selectionPHPCode)) {
+		severities[f.RuleID] = f.Severity
+	}
+	assert.Equal(t, SeverityBlocker, severities["sqli"])
+	assert.Equal(t, SeverityInfo, severities["debug-leftover"])
+	assert.Equal(t, SeverityLow, severities["weak-cipher"])
+	assert.Equal(t, SeverityCritical, severities["CVE-2019-9025"])
+	assert.Equal(t, SeverityHigh, severities["xss"])
+
+	assert.NoError(t, os.WriteFile(config, []byte("severity:\n  sqli: urgent\n"), 0o644))
+	assert.ErrorContains(t, applySelection(analyzer, &selectionFlags{config: config}), `sévérité inconnue : "urgent"`)
+	assert.NoError(t, os.WriteFile(config, []byte("severity:\n  nope: low\n"), 0o644))
+	assert.ErrorContains(t, applySelection(analyzer, &selectionFlags{config: config}), `règle ou catégorie inconnue : "nope"`)
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+
+	"github/behouba/log6302A/pkg/analyzerpb"
+)
+
+// Limites du serveur HTTP : taille du corps d'une requête d'analyse et nombre de
+// résultats conservés pour GET /results/{id}.
+const (
+	serveMaxBody    = 16 << 20
+	serveMaxResults = 100
+)
+
+// serveOptions sont les options de la commande cve acceptées dans une requête d'analyse,
+// avec l'indication des options booléennes (sans valeur). Les autres (-baseline, -rules,
+// -config, -cache-dir, -blame...) lisent ou écrivent des fichiers du serveur.
+var serveOptions = map[string]bool{
+	"min-severity": false, "max-findings": false, "php-version": false, "fail-on": false,
+	"enable": false, "disable": false, "only": false, "taint-db-reads": true,
+}
+
+// analyzeRequest est le corps de POST /analyze : le code source à analyser, ou le
+// chemin d'un fichier ou d'un dossier lisible par le serveur, et des options de la
+// commande cve (serveOptions).
+type analyzeRequest struct {
+	Source  *string  `json:"source,omitempty"`
+	Path    string   `json:"path,omitempty"`
+	Options []string `json:"options,omitempty"`
+}
+
+// analyzeResponse est la réponse de POST /analyze et de GET /results/{id} : le rapport
+// JSON de la commande cve et son code de sortie.
+type analyzeResponse struct {
+	ID       string          `json:"id,omitempty"`
+	ExitCode int             `json:"exitCode"`
+	Report   json.RawMessage `json:"report,omitempty"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// jsonRule décrit une règle dans la réponse de GET /rules.
+type jsonRule struct {
+	ID          string   `json:"id"`
+	Category    string   `json:"category"`
+	CWE         string   `json:"cwe,omitempty"`
+	Severity    string   `json:"severity"`
+	Confidence  string   `json:"confidence"`
+	Description string   `json:"description"`
+	PHP         string   `json:"php,omitempty"`
+	OWASP       string   `json:"owasp,omitempty"`
+	References  []string `json:"references,omitempty"`
+	Remediation string   `json:"remediation,omitempty"`
+}
+
+// Server expose l'analyseur sur HTTP (commande serve). Comme le démon, il garde entre
+// les requêtes le cache d'arbres et les parseurs, et traite les analyses une à la fois :
+// les arbres du cache ne peuvent pas être lus par plusieurs goroutines.
+type Server struct {
+	mu    sync.Mutex
+	cache *treeCache
+
+	// Règles supplémentaires et avis chargés par chaque analyse (-rules, -advisories).
+	rulesPath, advisoriesPath string
+
+	resultsMu sync.Mutex
+	results   map[string]analyzeResponse
+	order     []string // identifiants des résultats, du plus ancien au plus récent
+}
+
+// NewServer crée un serveur dont les analyses chargent les règles supplémentaires de
+// rulesPath et les avis de advisoriesPath.
+func NewServer(rulesPath, advisoriesPath string) *Server {
+	return &Server{cache: newTreeCache(treeCacheSize), rulesPath: rulesPath, advisoriesPath: advisoriesPath, results: make(map[string]analyzeResponse)}
+}
+
+// Handler retourne les routes du serveur.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /analyze", s.handleAnalyze)
+	mux.HandleFunc("GET /rules", s.handleRules)
+	mux.HandleFunc("GET /results/{id}", s.handleResult)
+	return mux
+}
+
+// handleAnalyze traite POST /analyze. Le corps doit être du JSON et l'éventuel en-tête
+// Origin celui du serveur : une page web ne peut ainsi pas faire analyser les fichiers
+// du serveur par le navigateur d'un utilisateur.
+func (s *Server) handleAnalyze(w http.ResponseWriter, r *http.Request) {
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeJSON(w, http.StatusUnsupportedMediaType, analyzeResponse{ExitCode: exitError, Error: "le corps de la requête doit être de type application/json"})
+		return
+	}
+	if !sameOrigin(r) {
+		writeJSON(w, http.StatusForbidden, analyzeResponse{ExitCode: exitError, Error: fmt.Sprintf("origine %q refusée", r.Header.Get("Origin"))})
+		return
+	}
+	var req analyzeRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, serveMaxBody)).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, analyzeResponse{ExitCode: exitError, Error: fmt.Sprintf("requête invalide : %v", err)})
+		return
+	}
+	if (req.Source == nil) == (req.Path == "") {
+		writeJSON(w, http.StatusBadRequest, analyzeResponse{ExitCode: exitError, Error: `"source" ou "path" est requis, mais pas les deux`})
+		return
+	}
+	if err := checkServeOptions(req.Options); err != nil {
+		writeJSON(w, http.StatusBadRequest, analyzeResponse{ExitCode: exitError, Error: err.Error()})
+		return
+	}
+	resp, err := s.analyze(r.Context(), req)
+	switch {
+	case errors.Is(err, errUsage):
+		writeJSON(w, http.StatusBadRequest, resp)
+	case err != nil:
+		writeJSON(w, http.StatusUnprocessableEntity, resp)
+	default:
+		resp.ID = s.store(resp)
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// sameOrigin vérifie que la requête ne vient pas d'une page d'un autre site : sans
+// en-tête Origin (client qui n'est pas un navigateur), ou avec l'origine du serveur.
+func sameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && strings.EqualFold(u.Host, r.Host)
+}
+
+// checkServeOptions vérifie que les options d'une requête d'analyse sont toutes des
+// options autorisées (serveOptions), écrites -nom=valeur ou -nom valeur ; les arguments
+// qui ne sont pas des options (chemins, --) sont refusés.
+func checkServeOptions(options []string) error {
+	for i := 0; i < len(options); i++ {
+		option := options[i]
+		name, _, hasValue := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(option, "-"), "-"), "=")
+		boolean, ok := serveOptions[name]
+		if !ok || !strings.HasPrefix(option, "-") {
+			var names []string
+			for name := range serveOptions {
+				names = append(names, "-"+name)
+			}
+			slices.Sort(names)
+			return fmt.Errorf("option %q non autorisée (options acceptées : %s)", option, strings.Join(names, ", "))
+		}
+		if !boolean && !hasValue {
+			i++ // la valeur est l'élément suivant
+		}
+	}
+	return nil
+}
+
+// analyze exécute la commande cve sur le source ou le chemin de la requête, avec un
+// analyseur neuf qui partage le cache d'arbres du serveur.
+func (s *Server) analyze(ctx context.Context, req analyzeRequest) (analyzeResponse, error) {
+	analyzer := NewPHPAnalyzer()
+	analyzer.cache = s.cache
+	analyzer.Format = formatJSON
+	args := append([]string{"-rules=" + s.rulesPath, "-advisories=" + s.advisoriesPath}, req.Options...)
+	if req.Source != nil {
+		analyzer.Stdin = strings.NewReader(*req.Source)
+		args = append(args, "-file="+stdinPath)
+	} else {
+		args = append(args, "--", req.Path)
+	}
+
+	s.mu.Lock()
+	var out bytes.Buffer
+	err := runCommandContext(ctx, analyzer, "cve", args, &out)
+	s.cache.sweep()
+	s.mu.Unlock()
+
+	resp := analyzeResponse{ExitCode: analyzer.exitCode(err)}
+	switch {
+	case errors.Is(err, errUsage):
+		resp.Error = strings.TrimSpace(out.String())
+	case err != nil:
+		resp.Error = err.Error()
+	default:
+		resp.Report = json.RawMessage(bytes.TrimSpace(out.Bytes()))
+	}
+	return resp, err
+}
+
+// store conserve un résultat pour GET /results/{id} et retourne son identifiant ; au-delà
+// de serveMaxResults, le plus ancien est oublié.
+func (s *Server) store(resp analyzeResponse) string {
+	id := newResultID()
+	resp.ID = id
+	s.resultsMu.Lock()
+	defer s.resultsMu.Unlock()
+	s.results[id] = resp
+	s.order = append(s.order, id)
+	if len(s.order) > serveMaxResults {
+		delete(s.results, s.order[0])
+		s.order = s.order[1:]
+	}
+	return id
+}
+
+// newResultID retourne un identifiant de résultat aléatoire.
+func newResultID() string {
+	var b [8]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+func (s *Server) handleResult(w http.ResponseWriter, r *http.Request) {
+	s.resultsMu.Lock()
+	resp, ok := s.results[r.PathValue("id")]
+	s.resultsMu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusNotFound, analyzeResponse{ExitCode: exitError, Error: fmt.Sprintf("résultat %q inconnu", r.PathValue("id"))})
+		return
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleRules liste les règles chargées, avec les règles supplémentaires et les avis
+// passés au serveur.
+func (s *Server) handleRules(w http.ResponseWriter, r *http.Request) {
+	analyzer := NewPHPAnalyzer()
+	if err := loadSignatures(analyzer, s.rulesPath, s.advisoriesPath); err != nil {
+		writeJSON(w, http.StatusInternalServerError, analyzeResponse{ExitCode: exitError, Error: err.Error()})
+		return
+	}
+	rules := []jsonRule{}
+	for _, rule := range analyzer.RuleCatalog() {
+		rules = append(rules, jsonRule{
+			ID:          rule.ID,
+			Category:    rule.Category,
+			CWE:         rule.CWE,
+			Severity:    rule.Severity.String(),
+			Confidence:  rule.Confidence.String(),
+			Description: rule.Description,
+			PHP:         rule.PHP,
+			OWASP:       rule.OWASP,
+			References:  rule.References,
+			Remediation: rule.Remediation,
+		})
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+}
+
+// runServeCommand exécute la commande serve : le serveur HTTP, et le service gRPC avec
+// -grpc-addr, répondent jusqu'à réception de SIGINT ou SIGTERM, puis terminent les
+// requêtes en cours.
+func runServeCommand(args []string, out io.Writer) error {
+	serveCmd := newFlagSet("serve", out)
+	addr := serveCmd.String("addr", "localhost:8080", "Adresse d'écoute du serveur HTTP")
+	grpcAddr := serveCmd.String("grpc-addr", "", "Adresse d'écoute du service gRPC (désactivé par défaut)")
+	rulesPath := serveCmd.String("rules", "", "Fichier ou dossier de règles YAML supplémentaires")
+	advisoriesPath := serveCmd.String("advisories", defaultAdvisoriesPath, "Fichier d'avis écrit par rules update")
+	if err := serveCmd.Parse(args); err != nil {
+		return errUsage
+	}
+	// Les règles sont chargées une première fois pour signaler une erreur au démarrage.
+	if err := loadSignatures(NewPHPAnalyzer(), *rulesPath, *advisoriesPath); err != nil {
+		return err
+	}
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	var grpcServer *grpc.Server
+	if *grpcAddr != "" {
+		grpcListener, err := net.Listen("tcp", *grpcAddr)
+		if err != nil {
+			listener.Close()
+			return err
+		}
+		grpcServer = grpc.NewServer()
+		analyzerpb.RegisterAnalyzerServer(grpcServer, &grpcService{rulesPath: *rulesPath, advisoriesPath: *advisoriesPath})
+		log.Printf("Service gRPC php-analyzer à l'écoute sur %s", grpcListener.Addr())
+		go grpcServer.Serve(grpcListener)
+	}
+	server := &http.Server{
+		Handler:           NewServer(*rulesPath, *advisoriesPath).Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopped := make(chan error, 1)
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if grpcServer != nil {
+			grpcServer.GracefulStop()
+		}
+		stopped <- server.Shutdown(shutdown)
+	}()
+
+	log.Printf("Serveur php-analyzer à l'écoute sur http://%s", listener.Addr())
+	if err := server.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return <-stopped
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerAnalyze(t *testing.T) {
+	server := httptest.NewServer(NewServer("", filepath.Join(t.TempDir(), "advisories.json")).Handler())
+	defer server.Close()
+	post := func(body string) (int, analyzeResponse) {
+		resp, err := http.Post(server.URL+"/analyze", "application/json", strings.NewReader(body))
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var decoded analyzeResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+		return resp.StatusCode, decoded
+	}
+
+	status, result := post(`{"source": "<?php\nlibxml_disable_entity_loader(false);"}`)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, exitFindings, result.ExitCode)
+	assert.NotEmpty(t, result.ID)
+	var report struct {
+		Findings []jsonFinding `json:"findings"`
+	}
+	assert.NoError(t, json.Unmarshal(result.Report, &report))
+	if assert.Len(t, report.Findings, 1) {
+		assert.Equal(t, uint32(2), report.Findings[0].Line)
+	}
+
+	resp, err := http.Get(server.URL + "/results/" + result.ID)
+	assert.NoError(t, err)
+	var stored analyzeResponse
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&stored))
+	resp.Body.Close()
+	assert.Equal(t, result.ID, stored.ID)
+	assert.JSONEq(t, string(result.Report), string(stored.Report))
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.php"), []byte("<?php\necho 1;\n"), 0o644))
+	body, _ := json.Marshal(analyzeRequest{Path: dir, Options: []string{"-min-severity=high"}})
+	status, result = post(string(body))
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, exitClean, result.ExitCode)
+
+	status, result = post(`{"source": "<?php", "path": "a.php"}`)
+	assert.Equal(t, http.StatusBadRequest, status)
+	assert.Contains(t, result.Error, `"source" ou "path"`)
+	status, result = post(`{"source": "<?php", "options": ["-min-severity=urgent"]}`)
+	assert.NotEqual(t, http.StatusOK, status)
+	assert.NotEmpty(t, result.Error)
+
+	resp, err = http.Get(server.URL + "/results/unknown")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+}
+
+func TestServerRejectsUnsafeRequests(t *testing.T) {
+	server := httptest.NewServer(NewServer("", filepath.Join(t.TempDir(), "advisories.json")).Handler())
+	defer server.Close()
+	send := func(contentType, origin, body string) (int, analyzeResponse) {
+		req, err := http.NewRequest(http.MethodPost, server.URL+"/analyze", strings.NewReader(body))
+		assert.NoError(t, err)
+		req.Header.Set("Content-Type", contentType)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+		defer resp.Body.Close()
+		var decoded analyzeResponse
+		assert.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+		return resp.StatusCode, decoded
+	}
+	source := `{"source": "<?php echo 1;"}`
+
+	status, _ := send("text/plain", "", source)
+	assert.Equal(t, http.StatusUnsupportedMediaType, status, "a browser simple request is not JSON")
+	status, _ = send("application/json", "http://evil.example", source)
+	assert.Equal(t, http.StatusForbidden, status, "cross-origin requests are rejected")
+	status, _ = send("application/json; charset=utf-8", server.URL, source)
+	assert.Equal(t, http.StatusOK, status, "same-origin requests are accepted")
+
+	baseline := filepath.Join(t.TempDir(), "baseline.json")
+	for _, options := range [][]string{{"-baseline=" + baseline}, {"-rules", "/etc"}, {"--cache-dir=/tmp"}, {"-blame"}, {"extra.php"}, {"--", "/etc/passwd"}} {
+		body, _ := json.Marshal(analyzeRequest{Source: &source, Options: options})
+		status, result := send("application/json", "", string(body))
+		assert.Equal(t, http.StatusBadRequest, status, "options %v", options)
+		assert.Contains(t, result.Error, "non autorisée")
+	}
+	assert.NoFileExists(t, baseline)
+
+	body, _ := json.Marshal(analyzeRequest{Source: &source, Options: []string{"-php-version", "8.1", "--max-findings=5", "-taint-db-reads", "-only=crypto"}})
+	status, result := send("application/json", "", string(body))
+	assert.Equal(t, http.StatusOK, status, result.Error)
+}
+
+func TestServerRules(t *testing.T) {
+	server := httptest.NewServer(NewServer("", filepath.Join(t.TempDir(), "advisories.json")).Handler())
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/rules")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	var rules []jsonRule
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&rules))
+	assert.NotEmpty(t, rules)
+	ids := map[string]bool{}
+	for _, rule := range rules {
+		ids[rule.ID] = true
+	}
+	assert.True(t, ids["debug-leftover"])
+}
@@ -0,0 +1,48 @@
+package main
+
+import "fmt"
+
+// severityOverrides remplace la sévérité des résultats d'une règle ou d'une catégorie
+// (nom de détecteur) ; la sévérité d'une règle l'emporte sur celle de sa catégorie.
+type severityOverrides struct {
+	detectors map[string]Severity
+	rules     map[string]Severity
+}
+
+// OverrideSeverities fixe la sévérité des règles ou catégories du projet, par exemple
+// {"debug-leftover": "info", "sqli": "blocker"}. Les surcharges remplacent les
+// précédentes.
+func (pa *PHPAnalyzer) OverrideSeverities(overrides map[string]string) error {
+	result := severityOverrides{detectors: make(map[string]Severity), rules: make(map[string]Severity)}
+	for name, value := range overrides {
+		severity, err := ParseSeverity(value)
+		if err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+		if _, ok := detectorRegistry[name]; ok {
+			result.detectors[name] = severity
+		} else if pa.ruleDetector(name) != "" {
+			result.rules[name] = severity
+		} else {
+			return fmt.Errorf("règle ou catégorie inconnue : %q", name)
+		}
+	}
+	pa.severities = result
+	return nil
+}
+
+// applySeverityOverrides remplace la sévérité des résultats des règles surchargées par le
+// projet. Elle s'applique après la calibration : un choix explicite du projet n'est pas
+// déclassé par le triage.
+func (pa *PHPAnalyzer) applySeverityOverrides(findings []Finding) {
+	if len(pa.severities.detectors) == 0 && len(pa.severities.rules) == 0 {
+		return
+	}
+	for i := range findings {
+		if severity, ok := pa.severities.rules[findings[i].RuleID]; ok {
+			findings[i].Severity = severity
+		} else if severity, ok := pa.severities.detectors[pa.ruleDetector(findings[i].RuleID)]; ok {
+			findings[i].Severity = severity
+		}
+	}
+}
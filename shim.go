@@ -0,0 +1,403 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// FunctionDefinition est une fonction définie par le projet. Lorsqu'elle porte le nom
+// d'une fonction native (polyfill, couche de compatibilité mysql_* au-dessus de
+// mysqli...), les appels sont résolus vers elle et non vers la fonction native ;
+// lorsqu'elle enveloppe une fonction de nettoyage (htmlspecialchars...), elle en est
+// une à son tour dans tout le projet.
+type FunctionDefinition struct {
+	Name        string // nom qualifié, par exemple App\Db\mysql_query
+	File        string
+	Line        uint32
+	Conditional bool // définie sous condition, typiquement if (!function_exists(...))
+
+	returns []string // résumé de chaque return (returnSummary)
+}
+
+// Résumés d'un return qui n'est pas l'appel d'une fonction.
+const (
+	returnLiteral      = "#literal" // valeur littérale, sûre dans tous les contextes
+	returnNumericCast  = "#cast"    // conversion en nombre, qui neutralise la contamination
+	returnOtherSummary = "#other"
+)
+
+// collectFunctionDefinitions retourne les fonctions définies dans un fichier, hors
+// méthodes et fonctions anonymes.
+func collectFunctionDefinitions(root *sitter.Node, source []byte, path string) []FunctionDefinition {
+	var definitions []FunctionDefinition
+	traverseAST(root, func(n *sitter.Node) {
+		if n.Type() != "function_definition" {
+			return
+		}
+		nameNode := n.ChildByFieldName("name")
+		if nameNode == nil {
+			return
+		}
+		line := n.StartPoint().Row + 1
+		name := nameNode.Content(source)
+		if namespace := namespaceAtLine(root, line, source); namespace != "" {
+			name = namespace + `\` + name
+		}
+		definitions = append(definitions, FunctionDefinition{
+			Name:        name,
+			File:        path,
+			Line:        line,
+			Conditional: isConditionalDefinition(n),
+			returns:     returnSummaries(n.ChildByFieldName("body"), source),
+		})
+	})
+	return definitions
+}
+
+// returnSummaries résume les return du corps d'une fonction, hors fonctions et classes
+// imbriquées : nom de la fonction appelée (sans espace de noms et en minuscules, comme
+// sanitizerName), ou returnLiteral, returnNumericCast, returnOtherSummary.
+func returnSummaries(body *sitter.Node, source []byte) []string {
+	var summaries []string
+	var walk func(n *sitter.Node)
+	walk = func(n *sitter.Node) {
+		switch n.Type() {
+		case "function_definition", "anonymous_function_creation_expression", "arrow_function",
+			"class_declaration", "method_declaration":
+			return
+		case "return_statement":
+			summaries = append(summaries, returnSummary(n, source))
+			return
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			walk(n.NamedChild(i))
+		}
+	}
+	if body != nil {
+		for i := 0; i < int(body.NamedChildCount()); i++ {
+			walk(body.NamedChild(i))
+		}
+	}
+	return summaries
+}
+
+// returnSummary résume l'expression d'un return.
+func returnSummary(n *sitter.Node, source []byte) string {
+	var value *sitter.Node
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		if child := n.NamedChild(i); child.Type() != "comment" {
+			value = child
+		}
+	}
+	for value != nil && value.Type() == "parenthesized_expression" && value.NamedChildCount() == 1 {
+		value = value.NamedChild(0)
+	}
+	if value == nil {
+		return returnLiteral
+	}
+	switch value.Type() {
+	case "string", "integer", "float", "boolean", "null":
+		return returnLiteral
+	case "encapsed_string":
+		if value.NamedChildCount() <= 1 && (value.NamedChildCount() == 0 || value.NamedChild(0).Type() == "string_content") {
+			return returnLiteral
+		}
+	case "cast_expression":
+		if isNumericCast(value, source) {
+			return returnNumericCast
+		}
+	case "function_call_expression", "member_call_expression":
+		if name := sanitizerName(value, source); name != "" {
+			return name
+		}
+	}
+	return returnOtherSummary
+}
+
+// sanitizes vérifie si la fonction neutralise toujours la contamination de son résultat
+// dans le contexte des fonctions de nettoyage sanitizers : chacun de ses return est une
+// valeur littérale, une conversion numérique ou l'appel d'une fonction de nettoyage, et
+// au moins un ne se résume pas à une valeur littérale.
+func (d FunctionDefinition) sanitizes(sanitizers map[string]bool) bool {
+	sanitized := false
+	for _, summary := range d.returns {
+		switch {
+		case summary == returnLiteral:
+		case summary == returnNumericCast || sanitizers[summary]:
+			sanitized = true
+		default:
+			return false
+		}
+	}
+	return sanitized
+}
+
+// isConditionalDefinition vérifie si une fonction n'est définie que sous condition :
+// dans un if ou dans le corps d'une autre fonction.
+func isConditionalDefinition(n *sitter.Node) bool {
+	for parent := n.Parent(); parent != nil; parent = parent.Parent() {
+		switch parent.Type() {
+		case "if_statement", "function_definition", "method_declaration":
+			return true
+		}
+	}
+	return false
+}
+
+// namespaceAtLine retourne l'espace de noms en vigueur à une ligne du fichier, "" pour
+// l'espace de noms global.
+func namespaceAtLine(root *sitter.Node, line uint32, source []byte) string {
+	current := ""
+	for i := 0; i < int(root.NamedChildCount()); i++ {
+		child := root.NamedChild(i)
+		if child.Type() != "namespace_definition" || child.StartPoint().Row+1 > line {
+			continue
+		}
+		name := ""
+		if nameNode := child.ChildByFieldName("name"); nameNode != nil {
+			name = nameNode.Content(source)
+		}
+		if child.ChildByFieldName("body") == nil {
+			current = name
+		} else if child.EndPoint().Row+1 >= line {
+			return name
+		}
+	}
+	return current
+}
+
+// functionIndex associe les fonctions du projet à leur nom qualifié en minuscules (les
+// noms de fonctions PHP sont insensibles à la casse).
+type functionIndex map[string]FunctionDefinition
+
+// add enregistre une définition ; une définition inconditionnelle l'emporte sur un
+// polyfill conditionnel du même nom.
+func (idx functionIndex) add(definition FunctionDefinition) {
+	key := strings.ToLower(definition.Name)
+	if existing, ok := idx[key]; ok && !existing.Conditional {
+		return
+	}
+	idx[key] = definition
+}
+
+// resolve retourne la définition vers laquelle un appel à function, écrit dans
+// l'espace de noms namespace, est résolu : nom complètement qualifié, nom relatif à
+// l'espace de noms, ou nom non qualifié avec repli sur l'espace de noms global.
+func (idx functionIndex) resolve(function, namespace string) (FunctionDefinition, bool) {
+	function = strings.ToLower(function)
+	namespace = strings.ToLower(namespace)
+	if strings.HasPrefix(function, `\`) {
+		definition, ok := idx[function[1:]]
+		return definition, ok
+	}
+	if namespace != "" {
+		if definition, ok := idx[namespace+`\`+function]; ok {
+			return definition, true
+		}
+		if strings.Contains(function, `\`) {
+			return FunctionDefinition{}, false
+		}
+	}
+	definition, ok := idx[function]
+	return definition, ok
+}
+
+// sanitizers complète les fonctions de nettoyage builtin par les fonctions de l'index
+// qui les enveloppent, directement ou par l'intermédiaire d'une autre enveloppe. Les
+// noms sont ceux de sanitizerName : sans espace de noms et en minuscules.
+func (idx functionIndex) sanitizers(builtin map[string]bool) map[string]bool {
+	result := make(map[string]bool, len(builtin))
+	for name := range builtin {
+		result[name] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, definition := range idx {
+			name := strings.ToLower(definition.Name[strings.LastIndex(definition.Name, `\`)+1:])
+			if !result[name] && definition.sanitizes(result) {
+				result[name] = true
+				changed = true
+			}
+		}
+	}
+	return result
+}
+
+// IndexProject parse les fichiers PHP des chemins donnés (dossiers parcourus
+// récursivement ou fichiers) et enregistre les fonctions qu'ils définissent, ainsi que
+// l'ensemble de leurs symboles (SymbolIndex) et le graphe de leurs appels (CallGraph). Les fichiers qu'ils incluent
+// (includeTargets) et, d'après le composer.json du projet, les fichiers des classes
+// qu'ils nomment (PSR-4) et les fichiers toujours chargés sont indexés à leur tour, même
+// hors des chemins donnés, pour qu'une application répartie en plusieurs fichiers soit
+// analysée comme un tout. Les détecteurs consultent ensuite cet index du projet pour
+// résoudre les appels vers les polyfills et reconnaître les fonctions et méthodes de
+// nettoyage définies dans un autre fichier, traits compris.
+func (pa *PHPAnalyzer) IndexProject(ctx context.Context, paths ...string) error {
+	var mu sync.Mutex
+	index := make(functionIndex)
+	classes := make(classIndex)
+	var symbols []Symbol
+	var sites []callSite
+	var names []string
+	includes := map[string][]string{}
+	dependencies := map[string][]string{} // fichiers inclus et chargés automatiquement
+	autoload := projectAutoload(paths)
+	indexer := pa.fork()
+	indexer.outcome = nil // l'indexation ne compte pas dans le résumé du scan
+	indexFile := func(ctx context.Context, fa *PHPAnalyzer, path string) string {
+		tree, content, err := fa.ParseFile(ctx, path)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("Erreur d'analyse du fichier %q: %v", path, err)
+			}
+			mu.Lock()
+			dependencies[filepath.Clean(path)] = nil
+			mu.Unlock()
+			return ""
+		}
+		definitions := collectFunctionDefinitions(tree.RootNode(), content, path)
+		declared := collectClasses(tree.RootNode(), content, path)
+		calls := collectCallSites(tree.RootNode(), content, path)
+		cited := collectCallableNames(tree.RootNode(), content)
+		defined := collectSymbols(tree.RootNode(), content, path)
+		targets := includeTargets(tree.RootNode(), content, path)
+		loaded := append([]string(nil), targets...)
+		if autoload != nil {
+			for _, reference := range newNameResolver(tree.RootNode(), content).classReferences() {
+				if file := autoload.classFile(reference.Name); file != "" {
+					loaded = append(loaded, displayPath(file, !filepath.IsAbs(path)))
+				}
+			}
+		}
+		fa.releaseTree(tree)
+		mu.Lock()
+		for _, definition := range definitions {
+			index.add(definition)
+		}
+		for _, class := range declared {
+			classes.add(class)
+		}
+		symbols = append(symbols, defined...)
+		sites = append(sites, calls...)
+		names = append(names, cited...)
+		includes[filepath.Clean(path)] = targets
+		dependencies[filepath.Clean(path)] = loaded
+		mu.Unlock()
+		return ""
+	}
+	err := forEachPHPFile(ctx, paths, indexer, indexFile)
+
+	// Fichiers inclus ou chargés automatiquement hors des chemins donnés, indexés à leur
+	// tour jusqu'à épuisement.
+	var pending []string
+	if autoload != nil {
+		for _, file := range autoload.files {
+			pending = append(pending, displayPath(file, len(paths) > 0 && !filepath.IsAbs(paths[0])))
+		}
+	}
+	for _, loaded := range dependencies {
+		pending = append(pending, loaded...)
+	}
+	sort.Strings(pending)
+	for len(pending) > 0 && err == nil && ctx.Err() == nil {
+		path := pending[0]
+		pending = pending[1:]
+		if _, indexed := dependencies[path]; indexed {
+			continue
+		}
+		if info, statErr := os.Stat(path); statErr != nil || info.IsDir() {
+			dependencies[path] = nil
+			continue
+		}
+		fileCtx, cancel := indexer.fileContext(ctx)
+		indexFile(fileCtx, indexer, path)
+		cancel()
+		pending = append(pending, dependencies[path]...)
+	}
+	pa.functions = index
+	pa.classes = classes
+	pa.symbols = newSymbolIndex(symbols, includes)
+	pa.calls = newCallGraph(sites, names, pa.symbols, classes)
+	return err
+}
+
+// projectAutoload lit le chargement automatique du composer.json du projet, cherché
+// depuis le premier chemin indexé, nil s'il n'y en a pas ou s'il est illisible.
+func projectAutoload(paths []string) *composerAutoload {
+	if len(paths) == 0 {
+		return nil
+	}
+	start := paths[0]
+	if info, err := os.Stat(start); err == nil && !info.IsDir() {
+		start = filepath.Dir(start)
+	}
+	path := findComposerJSON(start)
+	if path == "" {
+		return nil
+	}
+	autoload, err := loadComposerAutoload(path)
+	if err != nil {
+		log.Printf("Chargement automatique de composer.json ignoré : %v", err)
+		return nil
+	}
+	return autoload
+}
+
+// projectFunctions retourne l'index des fonctions du projet complété par celles du
+// fichier analysé.
+func (pa *PHPAnalyzer) projectFunctions(root *sitter.Node, source []byte) functionIndex {
+	index := make(functionIndex)
+	for _, definition := range pa.functions {
+		index.add(definition)
+	}
+	for _, definition := range collectFunctionDefinitions(root, source, "") {
+		index.add(definition)
+	}
+	return index
+}
+
+// resolveShims traite les résultats portant sur l'appel d'une fonction native que le
+// projet redéfinit : ils sont supprimés si la définition du projet est inconditionnelle,
+// et déclassés d'un niveau de confiance s'il s'agit d'un polyfill conditionnel, qui ne
+// remplace la fonction native qu'en son absence.
+func (pa *PHPAnalyzer) resolveShims(findings []Finding, root *sitter.Node, source []byte) []Finding {
+	index := pa.projectFunctions(root, source)
+	if len(index) == 0 {
+		return findings
+	}
+	kept := findings[:0]
+	for _, f := range findings {
+		if f.Function == "" {
+			kept = append(kept, f)
+			continue
+		}
+		definition, ok := index.resolve(f.Function, namespaceAtLine(root, f.Line, source))
+		switch {
+		case !ok:
+		case !definition.Conditional:
+			continue
+		default:
+			f.Confidence = max(f.Confidence-1, ConfidenceLow)
+			f.Message += fmt.Sprintf(" (polyfill du projet %s)", definitionLocation(definition))
+		}
+		kept = append(kept, f)
+	}
+	return kept
+}
+
+// definitionLocation décrit l'emplacement d'une définition : "fichier:ligne", ou
+// "ligne N" pour le fichier analysé.
+func definitionLocation(definition FunctionDefinition) string {
+	if definition.File == "" {
+		return fmt.Sprintf("ligne %d", definition.Line)
+	}
+	return fmt.Sprintf("%s:%d", definition.File, definition.Line)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectShimSuppressesBuiltinFinding(t *testing.T) {
+	detections := detect(t, `<?php
+function mysql_query($sql) { return mysqli_query($GLOBALS['db'], $sql); }
+mysql_query("SELECT * FROM users WHERE id=" . $id);`)
+	assert.Empty(t, detections)
+}
+
+func TestConditionalPolyfillDowngradesFinding(t *testing.T) {
+	detections := detect(t, `<?php
+if (!function_exists('mysql_query')) {
+    function mysql_query($sql) { return mysqli_query($GLOBALS['db'], $sql); }
+}
+MYSQL_QUERY("SELECT * FROM users WHERE id=" . $id);`)
+	assert.Len(t, detections, 1)
+	assert.Equal(t, "sqli-concat", detections[0].RuleID)
+	assert.Equal(t, ConfidenceLow, detections[0].Confidence)
+	assert.Contains(t, detections[0].Message, "(polyfill du projet ligne 3)")
+}
+
+func TestNamespacedShimResolution(t *testing.T) {
+	detections := detect(t, `<?php
+namespace App\Db;
+function mysql_query($sql) { return mysqli_query($GLOBALS['db'], $sql); }
+mysql_query("SELECT * FROM users WHERE id=" . $id);
+namespace App\Web;
+mysql_query("SELECT * FROM users WHERE id=" . $id);`)
+	assert.Len(t, detections, 1)
+	assert.Equal(t, uint32(6), detections[0].Line)
+
+	detections = detect(t, `<?php
+namespace App\Db {
+    function mysql_query($sql) { return mysqli_query($GLOBALS['db'], $sql); }
+}
+namespace App\Web {
+    mysql_query("SELECT * FROM users WHERE id=" . $id);
+}`)
+	assert.Len(t, detections, 1)
+	assert.Equal(t, uint32(6), detections[0].Line)
+}
+
+func TestIndexProjectFunctions(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "compat.php"), []byte(`<?php
+function mysql_query($sql) { return mysqli_query($GLOBALS['db'], $sql); }`), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "index.php"), []byte(`<?php
+mysql_query("SELECT * FROM users WHERE id=" . $id);`), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, analyzer.IndexProject(context.Background(), dir))
+	assert.Equal(t, uint32(2), analyzer.functions["mysql_query"].Line)
+
+	tree, content, err := analyzer.ParseFile(context.Background(), filepath.Join(dir, "index.php"))
+	assert.NoError(t, err)
+	assert.Empty(t, analyzer.DetectVulnerabilities(context.Background(), tree, content))
+}
+
+func TestProjectSanitizerWrappers(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "helpers.php"), []byte(`<?php
+namespace App;
+function h($value) {
+    if ($value === null) {
+        return '';
+    }
+    return htmlspecialchars($value, ENT_QUOTES);
+}
+function e_attr($value) { return h($value); }
+function quote_id($id) { return (int) $id; }
+function raw($value) { return $value; }`), 0o644))
+	page := filepath.Join(dir, "page.php")
+	assert.NoError(t, os.WriteFile(page, []byte(`<?php
+echo h($_GET['name']);
+echo e_attr($_GET['title']);
+echo raw($_GET['bio']);
+mysqli_query($db, "SELECT * FROM t WHERE id=" . quote_id($_GET['id']));
+mysqli_query($db, "SELECT * FROM t WHERE name='" . h($_GET['q']) . "'");`), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	tree, content, err := analyzer.ParseFile(context.Background(), page)
+	assert.NoError(t, err)
+	var before []string
+	for _, f := range analyzer.DetectVulnerabilities(context.Background(), tree, content) {
+		before = append(before, f.RuleID)
+	}
+	assert.Equal(t, []string{"xss", "xss", "xss", "sqli", "sqli"}, before)
+
+	assert.NoError(t, analyzer.IndexProject(context.Background(), dir))
+	after := analyzer.DetectVulnerabilities(context.Background(), tree, content)
+	assert.Len(t, after, 3)
+	assert.Equal(t, "xss", after[0].RuleID)
+	assert.Equal(t, uint32(4), after[0].Line)
+	assert.Equal(t, "sqli-concat", after[1].RuleID)
+	assert.Equal(t, "sqli", after[2].RuleID)
+	assert.Equal(t, uint32(6), after[2].Line)
+}
@@ -0,0 +1,506 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// sqlFunctionSinks associe les fonctions d'exécution SQL à la position de leur argument requête.
+var sqlFunctionSinks = map[string]int{
+	"mysql_query":          0,
+	"mysql_db_query":       1,
+	"mysqli_query":         1,
+	"mysqli_multi_query":   1,
+	"mysqli_real_query":    1,
+	"pg_query":             1,
+	"pg_query_params":      1,
+	"pg_send_query":        1,
+	"pg_send_query_params": 1,
+	"pg_prepare":           2,
+	"sqlsrv_query":         1,
+	"sqlsrv_prepare":       1,
+	"oci_parse":            1,
+}
+
+// sqlMethodSinks liste les méthodes dont le premier argument est une requête SQL
+// (PDO, mysqli orienté objet, SQLite3, $wpdb).
+var sqlMethodSinks = map[string]bool{
+	"query":       true,
+	"querySingle": true,
+	"exec":        true,
+	"prepare":     true,
+	"multi_query": true,
+	"real_query":  true,
+	"get_results": true,
+	"get_row":     true,
+	"get_var":     true,
+	"get_col":     true,
+}
+
+// sqlQueryArgument retourne l'expression passée comme requête à un puits SQL, ou nil si
+// l'appel n'est pas un puits connu.
+func sqlQueryArgument(node *sitter.Node, funcName string, source []byte) *sitter.Node {
+	args := getArgumentNodes(node)
+	index := -1
+	switch node.Type() {
+	case "function_call_expression":
+		position, ok := sqlFunctionSinks[strings.ToLower(funcName)]
+		if !ok {
+			return nil
+		}
+		index = position
+		// pg_query accepte la connexion en option : la requête est alors le premier argument.
+		if required, ok := sqlOptionalConnection[strings.ToLower(funcName)]; ok && len(args) < required {
+			index--
+		}
+	case "member_call_expression":
+		switch {
+		case sqlMethodSinks[funcName], laravelRawMethods[funcName], doctrineQueryMethods[funcName]:
+		case doctrineConditionMethods[funcName] && doctrineBuilder(node, source) != nil:
+		default:
+			return nil
+		}
+		index = 0
+	case "scoped_call_expression":
+		// Façade DB de Laravel : DB::select(), DB::statement(), DB::raw()...
+		method, ok := laravelDBCall(funcName)
+		if _, statement := laravelDBStatements[method]; !ok || !statement {
+			return nil
+		}
+		index = 0
+	}
+	if index < 0 || index >= len(args) {
+		return nil
+	}
+	return argumentValue(args[index])
+}
+
+// isDynamicString vérifie si une expression de chaîne est construite par concaténation ou
+// interpolation d'une valeur non littérale.
+func isDynamicString(expr *sitter.Node) bool {
+	if expr == nil {
+		return false
+	}
+	switch expr.Type() {
+	case "binary_expression":
+		operator := expr.ChildByFieldName("operator")
+		if operator == nil || operator.Type() != "." {
+			return false
+		}
+		left, right := expr.ChildByFieldName("left"), expr.ChildByFieldName("right")
+		return !isLiteral(left) || !isLiteral(right) || isDynamicString(left) || isDynamicString(right)
+	case "encapsed_string", "heredoc":
+		return hasInterpolation(expr)
+	case "parenthesized_expression":
+		return isDynamicString(expr.NamedChild(0))
+	}
+	return false
+}
+
+// isLiteral indique si l'expression est une constante littérale (chaîne sans
+// interpolation ou nombre).
+func isLiteral(expr *sitter.Node) bool {
+	if expr == nil {
+		return true
+	}
+	switch expr.Type() {
+	case "string", "integer", "float":
+		return true
+	case "encapsed_string", "heredoc", "nowdoc":
+		return !hasInterpolation(expr)
+	case "binary_expression":
+		return !isDynamicString(expr)
+	}
+	return false
+}
+
+// hasInterpolation vérifie si une chaîne entre guillemets doubles ou un heredoc contient
+// une variable ou une expression interpolée.
+func hasInterpolation(str *sitter.Node) bool {
+	interpolated := false
+	traverseAST(str, func(n *sitter.Node) {
+		switch n.Type() {
+		case "variable_name", "member_access_expression", "subscript_expression", "function_call_expression", "member_call_expression":
+			interpolated = true
+		}
+	})
+	return interpolated
+}
+
+// isConcatenatedQuery vérifie si la requête passée à un puits SQL est construite
+// dynamiquement, directement ou via une variable affectée par concaténation ou
+// interpolation dans la même fonction.
+func isConcatenatedQuery(query *sitter.Node, source []byte) bool {
+	if query == nil {
+		return false
+	}
+	if isDynamicString(query) {
+		return true
+	}
+	if query.Type() != "variable_name" {
+		return false
+	}
+	name := query.Content(source)
+	dynamic := false
+	traverseAST(enclosingScope(query), func(n *sitter.Node) {
+		if n.Type() != "assignment_expression" && n.Type() != "augmented_assignment_expression" {
+			return
+		}
+		left := n.ChildByFieldName("left")
+		if left == nil || left.Content(source) != name || n.StartByte() > query.StartByte() {
+			return
+		}
+		right := n.ChildByFieldName("right")
+		if isDynamicString(right) || (n.Type() == "augmented_assignment_expression" && !isLiteral(right)) {
+			dynamic = true
+		}
+	})
+	return dynamic
+}
+
+// Types d'instructions SQL reconnus dans les appels à la base de données.
+const (
+	sqlSelect = "SELECT"
+	sqlInsert = "INSERT"
+	sqlUpdate = "UPDATE"
+	sqlDelete = "DELETE"
+	sqlDDL    = "DDL"
+)
+
+// sqlStatementKinds associe le premier mot-clé d'une instruction à son type.
+var sqlStatementKinds = map[string]string{
+	"SELECT":   sqlSelect,
+	"WITH":     sqlSelect,
+	"INSERT":   sqlInsert,
+	"REPLACE":  sqlInsert,
+	"UPDATE":   sqlUpdate,
+	"DELETE":   sqlDelete,
+	"CREATE":   sqlDDL,
+	"ALTER":    sqlDDL,
+	"DROP":     sqlDDL,
+	"TRUNCATE": sqlDDL,
+	"RENAME":   sqlDDL,
+}
+
+// wpdbStatementKinds associe les méthodes d'écriture de $wpdb, dont le premier argument
+// est la table, au type d'instruction qu'elles exécutent.
+var wpdbStatementKinds = map[string]string{
+	"insert":  sqlInsert,
+	"replace": sqlInsert,
+	"update":  sqlUpdate,
+	"delete":  sqlDelete,
+}
+
+// SQLStatement décrit l'instruction exécutée par un appel à la base de données, lorsque
+// sa requête est littérale ou une concaténation résoluble.
+type SQLStatement struct {
+	Kind   string   // SELECT, INSERT, UPDATE, DELETE, DDL, ou "" si le type est inconnu
+	Tables []string // tables citées, dans l'ordre d'apparition
+}
+
+// String résume l'instruction, par exemple "SELECT users, orders".
+func (s SQLStatement) String() string {
+	kind := s.Kind
+	if kind == "" {
+		kind = "tables"
+	}
+	if len(s.Tables) == 0 {
+		return kind
+	}
+	return kind + " " + strings.Join(s.Tables, ", ")
+}
+
+// sqlTableName matche un nom de table : identifiant, éventuellement qualifié, entre
+// guillemets ou avec espace de noms (entités DQL), pouvant contenir des parties
+// dynamiques d'une requête résolue, notées {expression}.
+const sqlTableName = "(?:[\\w.`\"\\\\]|\\{[^}]*\\})+"
+
+var (
+	// sqlTablePattern matche un mot-clé introduisant une table, ses éventuels
+	// modificateurs, puis le nom de la table.
+	sqlTablePattern = regexp.MustCompile("(?i)\\b(?:FROM|JOIN|INTO|UPDATE|TABLE|TRUNCATE)\\s+" +
+		"(?:(?:IGNORE|LOW_PRIORITY|ONLY|TABLE|IF\\s+(?:NOT\\s+)?EXISTS)\\s+)*(" + sqlTableName + ")")
+	// sqlNextTable matche la table suivante d'une liste "FROM a, b" après un éventuel alias.
+	sqlNextTable = regexp.MustCompile("^\\s*(?:(?i:AS)\\s+)?(?:[A-Za-z_]\\w*\\s*)?,\\s*(" + sqlTableName + ")")
+	// sqlAssignment matche une affectation (ON DUPLICATE KEY UPDATE col = ...).
+	sqlAssignment = regexp.MustCompile(`^\s*=`)
+	// sqlLeading matche les parenthèses et commentaires précédant le premier mot-clé.
+	sqlLeading = regexp.MustCompile(`^(?:\s+|\(|/\*(?s:.*?)\*/|(?:--|#)[^\n]*)*`)
+)
+
+// classifySQL détermine le type d'une requête résolue et les tables qu'elle cite.
+func classifySQL(query string) SQLStatement {
+	var statement SQLStatement
+	rest := query[len(sqlLeading.FindString(query)):]
+	keyword := rest
+	if end := strings.IndexFunc(rest, func(r rune) bool { return !unicode.IsLetter(r) }); end >= 0 {
+		keyword = rest[:end]
+	}
+	statement.Kind = sqlStatementKinds[strings.ToUpper(keyword)]
+
+	seen := make(map[string]bool)
+	addTable := func(name string) {
+		name = strings.NewReplacer("`", "", `"`, "").Replace(name)
+		if name != "" && !seen[strings.ToLower(name)] {
+			seen[strings.ToLower(name)] = true
+			statement.Tables = append(statement.Tables, name)
+		}
+	}
+	for _, match := range sqlTablePattern.FindAllStringSubmatchIndex(query, -1) {
+		end := match[3]
+		if sqlAssignment.MatchString(query[end:]) {
+			continue
+		}
+		addTable(query[match[2]:end])
+		for {
+			next := sqlNextTable.FindStringSubmatchIndex(query[end:])
+			if next == nil {
+				break
+			}
+			addTable(query[end+next[2] : end+next[3]])
+			end += next[3]
+		}
+	}
+	return statement
+}
+
+// resolveSQL reconstitue le texte d'une requête SQL : les chaînes littérales (heredoc
+// et nowdoc compris) sont reprises telles quelles, les valeurs interpolées ou
+// concaténées sont notées {expression}, et une variable est remplacée par la valeur
+// qui lui est affectée plus haut dans la même fonction (y compris les ajouts par .=).
+// sprintf() et $wpdb->prepare() sont résolus vers leur chaîne de format. Le résultat
+// est faux si la requête ne contient aucune partie littérale.
+func resolveSQL(expr *sitter.Node, source []byte) (string, bool) {
+	var text strings.Builder
+	literal := false
+	var resolve func(n *sitter.Node)
+	dynamic := func(n *sitter.Node) {
+		text.WriteString("{" + singleLine(n.Content(source)) + "}")
+	}
+	resolve = func(n *sitter.Node) {
+		if n == nil {
+			return
+		}
+		switch n.Type() {
+		case "string":
+			content := n.Content(source)
+			start := strings.IndexAny(content, `'"`)
+			if start >= 0 && len(content) >= start+2 {
+				text.WriteString(content[start+1 : len(content)-1])
+				literal = true
+			}
+		case "encapsed_string", "heredoc_body", "nowdoc_body":
+			for i := 0; i < int(n.NamedChildCount()); i++ {
+				switch child := n.NamedChild(i); child.Type() {
+				case "string_content", "escape_sequence", "nowdoc_string":
+					text.WriteString(child.Content(source))
+					literal = true
+				default:
+					dynamic(child)
+				}
+			}
+		case "heredoc", "nowdoc":
+			resolve(n.ChildByFieldName("value"))
+		case "parenthesized_expression":
+			resolve(n.NamedChild(0))
+		case "binary_expression":
+			operator := n.ChildByFieldName("operator")
+			if operator == nil || operator.Type() != "." {
+				dynamic(n)
+				return
+			}
+			resolve(n.ChildByFieldName("left"))
+			resolve(n.ChildByFieldName("right"))
+		case "function_call_expression", "member_call_expression":
+			name := strings.ToLower(extractFunctionName(n, source))
+			args := getArgumentNodes(n)
+			if (name == "sprintf" || name == "vsprintf" || (name == "prepare" && n.Type() == "member_call_expression")) && len(args) > 0 {
+				resolve(argumentValue(args[0]))
+				return
+			}
+			dynamic(n)
+		case "variable_name":
+			assignments := variableAssignments(n, source)
+			if len(assignments) == 0 {
+				dynamic(n)
+				return
+			}
+			for _, right := range assignments {
+				resolve(right)
+			}
+		default:
+			dynamic(n)
+		}
+	}
+	resolve(expr)
+	return text.String(), literal
+}
+
+// variableAssignments retourne les valeurs affectées à une variable avant son
+// utilisation dans la même fonction : la dernière affectation simple, suivie des ajouts
+// par .= qui lui succèdent. Le résultat est vide si la variable n'est pas affectée ou
+// l'est autrement.
+func variableAssignments(variable *sitter.Node, source []byte) []*sitter.Node {
+	name := variable.Content(source)
+	var values []*sitter.Node
+	traverseAST(enclosingScope(variable), func(n *sitter.Node) {
+		if n.EndByte() > variable.StartByte() {
+			return
+		}
+		left := n.ChildByFieldName("left")
+		if left == nil || left.Content(source) != name {
+			return
+		}
+		switch n.Type() {
+		case "assignment_expression":
+			values = []*sitter.Node{n.ChildByFieldName("right")}
+		case "augmented_assignment_expression":
+			if operator := n.ChildByFieldName("operator"); operator != nil && operator.Type() == ".=" && values != nil {
+				values = append(values, n.ChildByFieldName("right"))
+			} else {
+				values = []*sitter.Node{nil}
+			}
+		}
+	})
+	if len(values) > 0 && values[0] == nil {
+		return nil
+	}
+	return values
+}
+
+// sqlStatement classe l'instruction exécutée par un appel à la base de données, ou
+// retourne nil si sa requête n'est pas résoluble.
+func sqlStatement(node *sitter.Node, funcName string, source []byte) *SQLStatement {
+	if kind, ok := wpdbStatementKinds[funcName]; ok && node.Type() == "member_call_expression" {
+		statement := &SQLStatement{Kind: kind}
+		if args := getArgumentNodes(node); len(args) > 0 {
+			if table, ok := resolveSQL(argumentValue(args[0]), source); ok {
+				statement.Tables = []string{table}
+			}
+		}
+		return statement
+	}
+	query, ok := resolveSQL(sqlQueryArgument(node, funcName, source), source)
+	if !ok {
+		return nil
+	}
+	statement := classifySQL(query)
+	if statement.Kind == "" && len(statement.Tables) == 0 {
+		return nil
+	}
+	return &statement
+}
+
+// rawSQLFinding crée l'entrée d'inventaire d'un appel recevant du SQL brut en premier
+// argument ; elle passe en sévérité medium lorsque ce SQL est concaténé.
+func rawSQLFinding(n *sitter.Node, call string, line uint32, statement *SQLStatement, source []byte) Finding {
+	var query *sitter.Node
+	if args := getArgumentNodes(n); len(args) > 0 {
+		query = argumentValue(args[0])
+	}
+	f := dbCallFinding(call, line, "Appel trouvé : "+call, statement, sqlConstruction(query, source))
+	if f.Query == QueryConcatenated {
+		f.Severity = SeverityMedium
+	}
+	return f
+}
+
+// QueryConstruction indique comment la requête d'un appel à la base est construite.
+type QueryConstruction string
+
+const (
+	QueryLiteral       QueryConstruction = "literal"       // texte entièrement littéral
+	QueryParameterized QueryConstruction = "parameterized" // valeurs passées par des paramètres liés
+	QueryConcatenated  QueryConstruction = "concatenated"  // valeurs concaténées ou interpolées
+	QueryUnknown       QueryConstruction = "unknown"       // requête non résoluble
+)
+
+// Label retourne le libellé affiché de la construction.
+func (c QueryConstruction) Label() string {
+	switch c {
+	case QueryLiteral:
+		return "requête littérale"
+	case QueryParameterized:
+		return "requête paramétrée"
+	case QueryConcatenated:
+		return "requête concaténée"
+	}
+	return "construction inconnue"
+}
+
+var (
+	// sqlPlaceholder matche un paramètre lié : ?, :nom, $1 (PostgreSQL) ou %s, %d, %f,
+	// %i ($wpdb->prepare()).
+	sqlPlaceholder = regexp.MustCompile(`\?|(?:^|[^:\w]):[A-Za-z_]\w*|\$\d+|%[sdfi]`)
+	// sqlQuoted matche une chaîne SQL entre apostrophes, dont le contenu n'est pas un
+	// paramètre.
+	sqlQuoted = regexp.MustCompile(`'(?:[^'\\]|\\.)*'`)
+	// sqlDynamic matche une partie dynamique d'une requête résolue.
+	sqlDynamic = regexp.MustCompile(`\{[^}]*\}`)
+)
+
+// sqlConstruction détermine la construction de la requête query : concaténée si elle
+// est construite par concaténation, interpolation ou sprintf() (voir isConcatenatedQuery),
+// paramétrée si son texte résolu contient des paramètres liés, littérale sinon, et
+// inconnue si elle n'est pas résoluble.
+func sqlConstruction(query *sitter.Node, source []byte) QueryConstruction {
+	if query == nil {
+		return QueryUnknown
+	}
+	if isConcatenatedQuery(query, source) || isFormattedQuery(query, source) {
+		return QueryConcatenated
+	}
+	text, ok := resolveSQL(query, source)
+	if !ok || sqlDynamic.MatchString(text) {
+		return QueryUnknown
+	}
+	if sqlPlaceholder.MatchString(sqlQuoted.ReplaceAllString(text, "''")) {
+		return QueryParameterized
+	}
+	return QueryLiteral
+}
+
+// isFormattedQuery vérifie si une requête est construite par sprintf() ou vsprintf()
+// avec des valeurs, directement ou via une variable affectée plus haut dans la même
+// fonction.
+func isFormattedQuery(query *sitter.Node, source []byte) bool {
+	values := []*sitter.Node{query}
+	if query.Type() == "variable_name" {
+		values = variableAssignments(query, source)
+	}
+	for _, value := range values {
+		if value == nil || value.Type() != "function_call_expression" {
+			continue
+		}
+		switch strings.ToLower(extractFunctionName(value, source)) {
+		case "sprintf", "vsprintf":
+			if len(getArgumentNodes(value)) > 1 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// executeConstruction détermine la construction de la requête exécutée par
+// $stmt->execute() : celle de la requête préparée sur laquelle il est appelé, directement
+// ou via $stmt affectée plus haut dans la même fonction, ou à défaut paramétrée si
+// execute() reçoit les valeurs des paramètres.
+func executeConstruction(n *sitter.Node, source []byte) QueryConstruction {
+	values := []*sitter.Node{n.ChildByFieldName("object")}
+	if values[0] != nil && values[0].Type() == "variable_name" {
+		values = variableAssignments(values[0], source)
+	}
+	for _, value := range values {
+		if value != nil && value.Type() == "member_call_expression" && extractFunctionName(value, source) == "prepare" {
+			return sqlConstruction(sqlQueryArgument(value, "prepare", source), source)
+		}
+	}
+	if len(getArgumentNodes(n)) > 0 {
+		return QueryParameterized
+	}
+	return QueryUnknown
+}
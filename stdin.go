@@ -0,0 +1,90 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+)
+
+// stdinPath est le chemin qui désigne l'entrée standard, comme dans -file=-.
+const stdinPath = "-"
+
+// errNoStdin signale une lecture de l'entrée standard là où elle n'est pas disponible
+// (dans le démon, qui ne reçoit que la ligne de commande).
+var errNoStdin = errors.New("entrée standard indisponible")
+
+// isPiped indique si un fichier est redirigé (pipe ou fichier) plutôt qu'un terminal.
+func isPiped(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice == 0
+}
+
+// readStdin lit le code PHP de l'entrée standard.
+func (pa *PHPAnalyzer) readStdin() ([]byte, error) {
+	if pa.Stdin == nil {
+		return nil, errNoStdin
+	}
+	return io.ReadAll(pa.Stdin)
+}
+
+// stdinDefault désigne l'entrée standard lorsqu'aucun fichier ni dossier n'est précisé et
+// qu'elle est redirigée, pour utiliser l'outil dans un pipeline sans -file=-.
+func (pa *PHPAnalyzer) stdinDefault(filePath *string, others ...string) {
+	for _, path := range others {
+		if path != "" {
+			return
+		}
+	}
+	if *filePath == "" && pa.stdinPiped {
+		*filePath = stdinPath
+	}
+}
+
+// stdinCommands liste les commandes qui acceptent l'entrée standard à la place de -file.
+var stdinCommands = map[string]bool{"count": true, "dbcalls": true, "cve": true, "dead": true, "deadcount": true, "fmt": true}
+
+// readsStdin indique si une ligne de commande (commande puis options) lit l'entrée
+// standard : avec -file=-, -files-from=- ou le chemin positionnel -, ou, l'entrée étant
+// redirigée, sans -file, -dir, -files-from ni chemin positionnel. Dans le doute (valeur d'un flag ou chemin), la
+// commande est considérée comme lisant l'entrée standard, ce qui ne fait qu'écarter le
+// démon.
+func readsStdin(args []string, piped bool) bool {
+	// L'interface de triage lit le clavier sur l'entrée standard.
+	if len(args) > 0 && args[0] == "tui" {
+		return true
+	}
+	for i, arg := range args {
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if strings.HasPrefix(arg, "-") && name == "files-from" && (value == stdinPath || !hasValue && i+1 < len(args) && args[i+1] == stdinPath) {
+			return true
+		}
+	}
+	if len(args) == 0 || !stdinCommands[args[0]] {
+		return false
+	}
+	input := false
+	for i, arg := range args[1:] {
+		if arg == stdinPath {
+			return true
+		}
+		if !strings.HasPrefix(arg, "-") {
+			// Un argument qui suit un flag sans "=" peut en être la valeur.
+			previous := args[i]
+			input = input || !strings.HasPrefix(previous, "-") || strings.Contains(previous, "=")
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		if name != "file" && name != "dir" && name != "files-from" {
+			continue
+		}
+		input = true
+		if !hasValue && i+2 < len(args) {
+			value = args[i+2]
+		}
+		if name == "file" && value == stdinPath {
+			return true
+		}
+	}
+	return piped && !input
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// storeCommands liste les commandes dont les résultats sont enregistrés avec --store.
+var storeCommands = map[string]bool{"cve": true, "analyze-dir": true}
+
+// storeSchema crée les tables de la base des résultats : une ligne par exécution
+// (run), et ses résultats, identifiés par leur empreinte pour comparer les exécutions.
+const storeSchema = `
+CREATE TABLE IF NOT EXISTS runs (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	project    TEXT NOT NULL,
+	command    TEXT NOT NULL,
+	started_at TEXT NOT NULL,
+	duration   REAL NOT NULL,
+	revision   TEXT NOT NULL,
+	files      INTEGER NOT NULL,
+	errors     INTEGER NOT NULL
+);
+CREATE INDEX IF NOT EXISTS runs_project ON runs (project, id);
+CREATE TABLE IF NOT EXISTS findings (
+	run_id      INTEGER NOT NULL REFERENCES runs (id) ON DELETE CASCADE,
+	file        TEXT NOT NULL,
+	line        INTEGER NOT NULL,
+	rule        TEXT NOT NULL,
+	severity    TEXT NOT NULL,
+	message     TEXT NOT NULL,
+	fingerprint TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS findings_run ON findings (run_id);
+`
+
+// storedFinding est un résultat enregistré, son fichier étant relatif au projet.
+type storedFinding struct {
+	File        string `json:"file"`
+	Line        int    `json:"line"`
+	Rule        string `json:"rule"`
+	Severity    string `json:"severity"`
+	Message     string `json:"message"`
+	Fingerprint string `json:"fingerprint"`
+}
+
+// storedRun rassemble les résultats d'une commande pour --store. Il peut être alimenté
+// par plusieurs goroutines.
+type storedRun struct {
+	mu       sync.Mutex
+	command  string
+	start    time.Time
+	project  string // dossier absolu auquel les chemins sont relatifs
+	files    int
+	findings []storedFinding
+}
+
+func newStoredRun(command string) *storedRun {
+	return &storedRun{command: command, start: time.Now()}
+}
+
+// add enregistre les résultats d'un fichier du scan de root ; run nil n'enregistre rien.
+func (run *storedRun) add(root, path string, findings []Finding) {
+	if run == nil {
+		return
+	}
+	run.mu.Lock()
+	defer run.mu.Unlock()
+	if run.project == "" {
+		run.project, _ = filepath.Abs(root)
+	}
+	run.files++
+	file := fingerprintPath(root, path)
+	for _, f := range findings {
+		run.findings = append(run.findings, storedFinding{
+			File:        file,
+			Line:        int(f.Line),
+			Rule:        f.RuleID,
+			Severity:    f.Severity.String(),
+			Message:     f.Message,
+			Fingerprint: f.Fingerprint,
+		})
+	}
+}
+
+// openStore ouvre la base des résultats, créée si elle n'existe pas.
+func openStore(path string) (*sql.DB, error) {
+	db, err := sql.Open("sqlite3", path+"?_foreign_keys=on&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(storeSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("base des résultats %q : %v", path, err)
+	}
+	return db, nil
+}
+
+// saveRun enregistre l'exécution de la commande dans la base des résultats de pa.Store.
+// Un scan sans fichier analysé est enregistré pour le dossier courant.
+func (pa *PHPAnalyzer) saveRun() error {
+	run := pa.run
+	if run.project == "" {
+		run.project, _ = filepath.Abs(pa.abs("."))
+	}
+	failed := 0
+	if pa.outcome != nil {
+		pa.outcome.mu.Lock()
+		failed = pa.outcome.errors
+		pa.outcome.mu.Unlock()
+	}
+	revision := ""
+	if out, err := git("-C", run.project, "rev-parse", "HEAD"); err == nil {
+		revision = strings.TrimSpace(string(out))
+	}
+
+	db, err := openStore(pa.Store)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	result, err := tx.Exec(`INSERT INTO runs (project, command, started_at, duration, revision, files, errors) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		run.project, run.command, run.start.UTC().Format(time.RFC3339), time.Since(run.start).Seconds(), revision, run.files, failed)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	insert, err := tx.Prepare(`INSERT INTO findings (run_id, file, line, rule, severity, message, fingerprint) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer insert.Close()
+	for _, f := range run.findings {
+		if _, err := insert.Exec(id, f.File, f.Line, f.Rule, f.Severity, f.Message, f.Fingerprint); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// runRecord est une exécution enregistrée, avec ses résultats comparés à ceux de
+// l'exécution précédente du même projet.
+type runRecord struct {
+	ID       int64          `json:"id"`
+	Project  string         `json:"project"`
+	Command  string         `json:"command"`
+	Started  string         `json:"startedAt"`
+	Duration float64        `json:"duration"` // secondes
+	Revision string         `json:"revision,omitempty"`
+	Files    int            `json:"files"`
+	Errors   int            `json:"errors"`
+	Findings int            `json:"findings"`
+	Severity map[string]int `json:"bySeverity"`
+
+	// Résultats apparus et disparus depuis l'exécution précédente.
+	New   []storedFinding `json:"new"`
+	Fixed []storedFinding `json:"fixed"`
+}
+
+// loadHistory retourne les exécutions d'un projet, de la plus ancienne à la plus
+// récente, chacune comparée à la précédente.
+func loadHistory(db *sql.DB, project string) ([]*runRecord, error) {
+	rows, err := db.Query(`SELECT id, project, command, started_at, duration, revision, files, errors FROM runs WHERE project = ? ORDER BY id`, project)
+	if err != nil {
+		return nil, err
+	}
+	var runs []*runRecord
+	for rows.Next() {
+		run := &runRecord{Severity: map[string]int{}, New: []storedFinding{}, Fixed: []storedFinding{}}
+		if err := rows.Scan(&run.ID, &run.Project, &run.Command, &run.Started, &run.Duration, &run.Revision, &run.Files, &run.Errors); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var previous []storedFinding
+	for _, run := range runs {
+		findings, err := loadRunFindings(db, run.ID)
+		if err != nil {
+			return nil, err
+		}
+		run.Findings = len(findings)
+		for _, f := range findings {
+			run.Severity[f.Severity]++
+		}
+		run.New = append(run.New, diffFindings(findings, previous)...)
+		run.Fixed = append(run.Fixed, diffFindings(previous, findings)...)
+		previous = findings
+	}
+	return runs, nil
+}
+
+func loadRunFindings(db *sql.DB, runID int64) ([]storedFinding, error) {
+	rows, err := db.Query(`SELECT file, line, rule, severity, message, fingerprint FROM findings WHERE run_id = ? ORDER BY file, line, rule`, runID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var findings []storedFinding
+	for rows.Next() {
+		var f storedFinding
+		if err := rows.Scan(&f.File, &f.Line, &f.Rule, &f.Severity, &f.Message, &f.Fingerprint); err != nil {
+			return nil, err
+		}
+		findings = append(findings, f)
+	}
+	return findings, rows.Err()
+}
+
+// diffFindings retourne les résultats de a absents de b, comparés par empreinte : un
+// résultat présent deux fois dans a et une fois dans b est retenu une fois.
+func diffFindings(a, b []storedFinding) []storedFinding {
+	remaining := map[string]int{}
+	for _, f := range b {
+		remaining[f.Fingerprint]++
+	}
+	var diff []storedFinding
+	for _, f := range a {
+		if remaining[f.Fingerprint] > 0 {
+			remaining[f.Fingerprint]--
+			continue
+		}
+		diff = append(diff, f)
+	}
+	return diff
+}
+
+// runHistoryCommand exécute les commandes history et trends sur la base de --store :
+// history liste les exécutions d'un projet avec les résultats apparus et corrigés
+// depuis la précédente, ou détaille ceux d'une exécution avec -run ; trends montre
+// l'évolution du nombre de résultats par sévérité.
+func runHistoryCommand(analyzer *PHPAnalyzer, command string, args []string, out io.Writer) error {
+	cmd := newFlagSet(command, out)
+	project := cmd.String("project", ".", "Dossier du projet (dossier analysé par analyze-dir, dossier courant pour cve)")
+	runID := cmd.Int64("run", 0, "Exécution dont les résultats apparus et corrigés sont détaillés (history)")
+	if err := cmd.Parse(args); err != nil {
+		return errUsage
+	}
+	analyzer.resolvePathFlags(cmd)
+	if analyzer.Store == "" {
+		fmt.Fprintf(out, "L'option --store est requise pour la commande %s.\n", command)
+		return errUsage
+	}
+	if _, err := os.Stat(analyzer.Store); err != nil {
+		return fmt.Errorf("Base des résultats %q : %v", analyzer.Store, err)
+	}
+	abs, err := filepath.Abs(*project)
+	if err != nil {
+		return err
+	}
+	db, err := openStore(analyzer.Store)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	runs, err := loadHistory(db, abs)
+	if err != nil {
+		return fmt.Errorf("Erreur lors de la lecture de %q: %v", analyzer.Store, err)
+	}
+
+	if *runID != 0 {
+		for _, run := range runs {
+			if run.ID == *runID {
+				if analyzer.Format == formatJSON {
+					return writeIndentedJSON(out, run)
+				}
+				writeRunDetails(out, run)
+				return nil
+			}
+		}
+		return fmt.Errorf("Exécution %d inconnue pour le projet %q", *runID, abs)
+	}
+	if analyzer.Format == formatJSON {
+		if runs == nil {
+			runs = []*runRecord{}
+		}
+		return writeIndentedJSON(out, runs)
+	}
+	if len(runs) == 0 {
+		fmt.Fprintf(out, "Aucune exécution enregistrée pour le projet %q.\n", abs)
+		return nil
+	}
+	fmt.Fprintf(out, "Projet : %s\n\n", abs)
+	if command == "trends" {
+		writeTrends(out, runs)
+	} else {
+		writeHistory(out, runs)
+	}
+	return nil
+}
+
+func writeIndentedJSON(out io.Writer, value any) error {
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	encoder.SetEscapeHTML(false)
+	return encoder.Encode(value)
+}
+
+// shortRevision abrège une révision git pour l'affichage.
+func shortRevision(revision string) string {
+	if revision == "" {
+		return "-"
+	}
+	return revision[:min(len(revision), 8)]
+}
+
+// writeHistory affiche une ligne par exécution, de la plus récente à la plus ancienne.
+func writeHistory(out io.Writer, runs []*runRecord) {
+	fmt.Fprintf(out, "%-6s %-20s %-9s %-12s %8s %9s %9s %9s\n", "Exéc.", "Date", "Révision", "Commande", "Fichiers", "Résultats", "Nouveaux", "Corrigés")
+	for i := len(runs) - 1; i >= 0; i-- {
+		run := runs[i]
+		fmt.Fprintf(out, "#%-5d %-20s %-9s %-12s %8d %9d %9s %9s\n", run.ID, run.Started, shortRevision(run.Revision), run.Command,
+			run.Files, run.Findings, fmt.Sprintf("+%d", len(run.New)), fmt.Sprintf("-%d", len(run.Fixed)))
+	}
+}
+
+// writeRunDetails affiche les résultats apparus et corrigés lors d'une exécution.
+func writeRunDetails(out io.Writer, run *runRecord) {
+	fmt.Fprintf(out, "Exécution #%d du %s (%s, révision %s) : %d résultat(s)\n", run.ID, run.Started, run.Command, shortRevision(run.Revision), run.Findings)
+	for _, group := range []struct {
+		title, mark string
+		findings    []storedFinding
+	}{{"Nouveaux résultats", "+", run.New}, {"Résultats corrigés", "-", run.Fixed}} {
+		fmt.Fprintf(out, "\n%s : %d\n", group.title, len(group.findings))
+		for _, f := range group.findings {
+			fmt.Fprintf(out, "  %s %s:%d  %s [%s] %s\n", group.mark, f.File, f.Line, f.Rule, f.Severity, f.Message)
+		}
+	}
+}
+
+// writeTrends affiche le nombre de résultats de chaque exécution par sévérité, de la
+// plus ancienne à la plus récente, avec l'écart à l'exécution précédente.
+func writeTrends(out io.Writer, runs []*runRecord) {
+	fmt.Fprintf(out, "%-6s %-20s", "Exéc.", "Date")
+	for severity := SeverityBlocker; severity >= SeverityInfo; severity-- {
+		fmt.Fprintf(out, " %8s", severity)
+	}
+	fmt.Fprintf(out, " %9s %7s\n", "Total", "Écart")
+	previous := 0
+	for i, run := range runs {
+		fmt.Fprintf(out, "#%-5d %-20s", run.ID, run.Started)
+		for severity := SeverityBlocker; severity >= SeverityInfo; severity-- {
+			fmt.Fprintf(out, " %8d", run.Severity[severity.String()])
+		}
+		delta := ""
+		if i > 0 {
+			delta = fmt.Sprintf("%+d", run.Findings-previous)
+		}
+		fmt.Fprintf(out, " %9d %7s\n", run.Findings, delta)
+		previous = run.Findings
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStoreHistory(t *testing.T) {
+	dir := t.TempDir()
+	store := filepath.Join(t.TempDir(), "results.db")
+	scan := func(source string) {
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.php"), []byte(source), 0o644))
+		analyzer := NewPHPAnalyzer()
+		analyzer.Store = store
+		runCommand(analyzer, "analyze-dir", []string{"-no-cache", dir}, &strings.Builder{})
+	}
+	scan("<?php\nvar_dump($a);\n")
+	scan("<?php\nvar_dump($a);\nprint_r($b);\n")
+	scan("<?php\necho 1;\nprint_r($b);\n")
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Store = store
+	analyzer.Format = formatJSON
+	assert.NoError(t, runCommand(analyzer, "history", []string{"-project", dir}, &out))
+	var runs []runRecord
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &runs))
+	if assert.Len(t, runs, 3) {
+		assert.Equal(t, 1, runs[0].Findings)
+		assert.Len(t, runs[1].New, 1)
+		assert.Empty(t, runs[1].Fixed)
+		assert.Empty(t, runs[2].New, "a finding that moves is not new")
+		if assert.Len(t, runs[2].Fixed, 1) {
+			assert.Equal(t, "a.php", runs[2].Fixed[0].File)
+			assert.Contains(t, runs[2].Fixed[0].Message, "var_dump")
+		}
+	}
+
+	out.Reset()
+	analyzer.Format = formatText
+	assert.NoError(t, runCommand(analyzer, "history", []string{"-project", dir, "-run", "3"}, &out))
+	assert.Contains(t, out.String(), "Résultats corrigés : 1\n  - a.php:2  debug-leftover [low]")
+
+	out.Reset()
+	assert.NoError(t, runCommand(analyzer, "trends", []string{"-project", dir}, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 6)
+	assert.True(t, strings.HasSuffix(lines[5], "1      -1"), lines[5])
+}
+
+func TestStoreRequiresOption(t *testing.T) {
+	var out strings.Builder
+	err := runCommand(NewPHPAnalyzer(), "history", nil, &out)
+	assert.ErrorIs(t, err, errUsage)
+	assert.Contains(t, out.String(), "--store est requise")
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// scanSummary résume un scan de plusieurs fichiers : fichiers analysés, ignorés ou en
+// erreur, résultats signalés par sévérité et par règle, et durée de la commande.
+type scanSummary struct {
+	Files      int            `json:"files"`
+	Skipped    int            `json:"skipped"`
+	Errors     int            `json:"errors"`
+	Findings   int            `json:"findings"`
+	Omitted    int            `json:"omitted,omitempty"`
+	Duplicates int            `json:"duplicates,omitempty"` // résultats déjà signalés par un autre chemin
+	BySeverity map[string]int `json:"bySeverity"`
+	ByRule     map[string]int `json:"byRule"`
+	WallTime   float64        `json:"wallTime"` // secondes
+	CPUTime    float64        `json:"cpuTime"`  // secondes, tous les workers
+}
+
+// recordScan compte les fichiers d'un parcours : ceux qui sont analysés et ceux qui sont
+// écartés (taille maximale du niveau "fast"). Seuls les parcours de plusieurs chemins ou
+// d'un dossier donnent lieu à un résumé.
+func (pa *PHPAnalyzer) recordScan(paths []string, files, skipped int) {
+	if pa.outcome == nil {
+		return
+	}
+	multi := len(paths) > 1 || files+skipped > 1
+	if len(paths) == 1 {
+		if info, err := os.Stat(paths[0]); err == nil && info.IsDir() {
+			multi = true
+		}
+	}
+	pa.outcome.mu.Lock()
+	defer pa.outcome.mu.Unlock()
+	if multi {
+		pa.outcome.scans++
+	}
+	pa.outcome.files += files
+	pa.outcome.skipped += skipped
+}
+
+// takeSummary retourne le résumé de la commande en cours, nil si elle n'a pas parcouru
+// plusieurs fichiers ou si le résumé a déjà été écrit.
+func (pa *PHPAnalyzer) takeSummary() *scanSummary {
+	if pa.outcome == nil {
+		return nil
+	}
+	o := pa.outcome
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.summarized || o.scans == 0 {
+		return nil
+	}
+	o.summarized = true
+	summary := &scanSummary{
+		Files:      o.files,
+		Skipped:    o.skipped,
+		Errors:     o.errors,
+		Omitted:    o.omitted,
+		Duplicates: o.duplicates,
+		BySeverity: map[string]int{},
+		ByRule:     map[string]int{},
+		WallTime:   time.Since(o.start).Seconds(),
+		CPUTime:    (cpuTime() - o.cpu).Seconds(),
+	}
+	for severity, count := range o.bySeverity {
+		summary.BySeverity[severity.String()] = count
+		summary.Findings += count
+	}
+	for rule, count := range o.byRule {
+		summary.ByRule[rule] = count
+	}
+	return summary
+}
+
+// writeSummary écrit le résumé d'un scan en texte, après ses résultats.
+func writeSummary(out io.Writer, s *scanSummary) {
+	fmt.Fprintf(out, "\nRésumé : %d fichier(s) analysé(s), %d ignoré(s), %d en erreur.\n", s.Files, s.Skipped, s.Errors)
+	line := fmt.Sprintf("Résultats : %d", s.Findings)
+	if s.Omitted > 0 {
+		line += fmt.Sprintf(" (+%d non signalé(s))", s.Omitted)
+	}
+	if s.Duplicates > 0 {
+		line += fmt.Sprintf(" (%d doublon(s) écarté(s))", s.Duplicates)
+	}
+	var severities []string
+	for severity := SeverityBlocker; severity >= SeverityInfo; severity-- {
+		if count := s.BySeverity[severity.String()]; count > 0 {
+			severities = append(severities, fmt.Sprintf("%s %d", severity, count))
+		}
+	}
+	if len(severities) > 0 {
+		line += " — " + strings.Join(severities, ", ")
+	}
+	fmt.Fprintln(out, line)
+	if len(s.ByRule) > 0 {
+		rules := make([]string, 0, len(s.ByRule))
+		for rule := range s.ByRule {
+			rules = append(rules, rule)
+		}
+		sort.Slice(rules, func(i, j int) bool {
+			if s.ByRule[rules[i]] != s.ByRule[rules[j]] {
+				return s.ByRule[rules[i]] > s.ByRule[rules[j]]
+			}
+			return rules[i] < rules[j]
+		})
+		for i, rule := range rules {
+			rules[i] = fmt.Sprintf("%s %d", rule, s.ByRule[rule])
+		}
+		fmt.Fprintf(out, "Règles : %s\n", strings.Join(rules, ", "))
+	}
+	fmt.Fprintf(out, "Durée : %.2fs (CPU %.2fs)\n", s.WallTime, s.CPUTime)
+}
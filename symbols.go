@@ -0,0 +1,301 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Genres des symboles de l'index du projet.
+const (
+	SymbolFunction  = "function"
+	SymbolClass     = "class"
+	SymbolInterface = "interface"
+	SymbolTrait     = "trait"
+	SymbolEnum      = "enum"
+	SymbolMethod    = "method"
+	SymbolConstant  = "constant"
+)
+
+// symbolKinds liste les genres de symboles, dans l'ordre de l'aide de la commande symbols.
+var symbolKinds = []string{SymbolFunction, SymbolClass, SymbolInterface, SymbolTrait, SymbolEnum, SymbolMethod, SymbolConstant}
+
+// maxSignatureLength borne la signature d'un symbole, la valeur d'une constante pouvant
+// être un long tableau.
+const maxSignatureLength = 200
+
+// Symbol est une définition du projet : fonction, classe, interface, trait, énumération,
+// méthode ou constante.
+type Symbol struct {
+	Kind string `json:"kind"`
+	// Name est le nom qualifié : App\helper, App\Models\User, App\Models\User::find,
+	// App\VERSION ou App\Models\User::TABLE.
+	Name string `json:"name"`
+	File string `json:"file"`
+	Line uint32 `json:"line"`
+	// Signature est la déclaration sans son corps, sur une ligne : paramètres et type de
+	// retour d'une fonction, parents d'une classe, valeur d'une constante.
+	Signature string `json:"signature"`
+}
+
+// shortName retourne le nom du symbole sans espace de noms ni classe.
+func (s Symbol) shortName() string {
+	name := s.Name[strings.LastIndex(s.Name, `\`)+1:]
+	if i := strings.LastIndex(name, "::"); i >= 0 {
+		name = name[i+2:]
+	}
+	return name
+}
+
+// SymbolIndex est l'index des symboles définis par les fichiers d'un projet
+// (IndexProject), partagé par les analyses qui suivent les appels d'un fichier à l'autre.
+type SymbolIndex struct {
+	Symbols  []Symbol            // triés par fichier et par ligne
+	byName   map[string][]int    // positions dans Symbols, par nom qualifié en minuscules
+	includes map[string][]string // fichiers inclus par chaque fichier (includeTargets)
+}
+
+// newSymbolIndex indexe des symboles et les inclusions des fichiers qui les définissent.
+func newSymbolIndex(symbols []Symbol, includes map[string][]string) *SymbolIndex {
+	sort.SliceStable(symbols, func(i, j int) bool {
+		if symbols[i].File != symbols[j].File {
+			return pathLess(symbols[i].File, symbols[j].File)
+		}
+		return symbols[i].Line < symbols[j].Line
+	})
+	idx := &SymbolIndex{Symbols: symbols, byName: make(map[string][]int, len(symbols)), includes: includes}
+	for i, symbol := range symbols {
+		key := strings.ToLower(symbol.Name)
+		idx.byName[key] = append(idx.byName[key], i)
+	}
+	return idx
+}
+
+// Lookup retourne les définitions d'un nom qualifié, sans distinction de casse ; un nom
+// peut être défini plusieurs fois (polyfills, classes de plusieurs versions).
+func (idx *SymbolIndex) Lookup(name string) []Symbol {
+	if idx == nil {
+		return nil
+	}
+	var symbols []Symbol
+	for _, i := range idx.byName[strings.ToLower(strings.TrimPrefix(name, `\`))] {
+		symbols = append(symbols, idx.Symbols[i])
+	}
+	return symbols
+}
+
+// Query retourne les symboles des genres kinds (tous si kinds est vide) dont le nom
+// correspond au motif pattern ("" pour tous), dans l'ordre de l'index.
+func (idx *SymbolIndex) Query(kinds []string, pattern string) []Symbol {
+	if idx == nil {
+		return nil
+	}
+	match := symbolMatcher(pattern)
+	var symbols []Symbol
+	for _, symbol := range idx.Symbols {
+		if (len(kinds) == 0 || slices.Contains(kinds, symbol.Kind)) && match(symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	return symbols
+}
+
+// symbolMatcher compile un motif de nom sans distinction de casse, "*" désignant une
+// suite quelconque de caractères et "?" un caractère. Un motif sans "\" ni "::" porte
+// sur le nom court du symbole, sinon sur son nom qualifié.
+func symbolMatcher(pattern string) func(Symbol) bool {
+	if pattern == "" {
+		return func(Symbol) bool { return true }
+	}
+	expr := regexp.QuoteMeta(strings.TrimPrefix(pattern, `\`))
+	expr = strings.NewReplacer(`\*`, ".*", `\?`, ".").Replace(expr)
+	re := regexp.MustCompile("(?i)^" + expr + "$")
+	if strings.Contains(pattern, `\`) || strings.Contains(pattern, "::") {
+		return func(s Symbol) bool { return re.MatchString(s.Name) }
+	}
+	return func(s Symbol) bool { return re.MatchString(s.shortName()) }
+}
+
+// collectSymbols retourne les symboles définis dans un fichier. Les méthodes et
+// constantes des classes anonymes, et les fonctions anonymes, ne sont pas retenues ; les
+// constantes de define() le sont lorsque leur nom est une chaîne littérale.
+func collectSymbols(root *sitter.Node, source []byte, path string) []Symbol {
+	var symbols []Symbol
+	add := func(kind, name string, n *sitter.Node, signature string) {
+		symbols = append(symbols, Symbol{Kind: kind, Name: name, File: path, Line: n.StartPoint().Row + 1, Signature: signature})
+	}
+	qualify := func(n *sitter.Node, name string) string {
+		if namespace := namespaceAtLine(root, n.StartPoint().Row+1, source); namespace != "" {
+			return namespace + `\` + name
+		}
+		return name
+	}
+	traverseAST(root, func(n *sitter.Node) {
+		switch n.Type() {
+		case "function_definition":
+			if name := n.ChildByFieldName("name"); name != nil {
+				add(SymbolFunction, qualify(n, name.Content(source)), n, declarationSignature(n, source))
+			}
+		case "class_declaration", "interface_declaration", "trait_declaration", "enum_declaration":
+			if name := n.ChildByFieldName("name"); name != nil {
+				add(strings.TrimSuffix(n.Type(), "_declaration"), qualify(n, name.Content(source)), n, declarationSignature(n, source))
+			}
+		case "method_declaration":
+			name := n.ChildByFieldName("name")
+			if class := enclosingClassName(n, source); name != nil && class != "" {
+				add(SymbolMethod, qualify(n, class)+"::"+name.Content(source), n, declarationSignature(n, source))
+			}
+		case "const_declaration":
+			class := ""
+			if n.Parent() != nil && n.Parent().Type() != "program" && n.Parent().Type() != "compound_statement" {
+				if class = enclosingClassName(n, source); class == "" {
+					return
+				}
+			}
+			prefix := ""
+			for i := 0; i < int(n.NamedChildCount()); i++ {
+				if child := n.NamedChild(i); child.Type() != "const_element" {
+					prefix += child.Content(source) + " "
+				}
+			}
+			for i := 0; i < int(n.NamedChildCount()); i++ {
+				element := n.NamedChild(i)
+				if element.Type() != "const_element" || element.NamedChildCount() == 0 {
+					continue
+				}
+				name := element.NamedChild(0).Content(source)
+				if class != "" {
+					name = class + "::" + name
+				}
+				add(SymbolConstant, qualify(element, name), element, clipSignature(prefix+"const "+element.Content(source)))
+			}
+		case "function_call_expression":
+			if name := definedConstant(n, source); name != "" {
+				add(SymbolConstant, name, n, clipSignature(n.Content(source)))
+			}
+		}
+	})
+	return symbols
+}
+
+// enclosingClassName retourne le nom de la classe, de l'interface, du trait ou de
+// l'énumération déclarant un membre, "" pour une classe anonyme.
+func enclosingClassName(member *sitter.Node, source []byte) string {
+	for p := member.Parent(); p != nil; p = p.Parent() {
+		switch {
+		case isClassLike(p.Type()):
+			if name := p.ChildByFieldName("name"); name != nil {
+				return name.Content(source)
+			}
+			return ""
+		case p.Type() == "anonymous_class", p.Type() == "object_creation_expression":
+			return ""
+		}
+	}
+	return ""
+}
+
+// definedConstant retourne le nom de la constante définie par un appel à define(), ""
+// s'il ne s'agit pas d'un tel appel ou si le nom n'est pas une chaîne littérale.
+// define() ignore l'espace de noms du fichier.
+func definedConstant(call *sitter.Node, source []byte) string {
+	function := call.ChildByFieldName("function")
+	if function == nil || !strings.EqualFold(strings.TrimPrefix(function.Content(source), `\`), "define") {
+		return ""
+	}
+	args := call.ChildByFieldName("arguments")
+	if args == nil || args.NamedChildCount() < 2 {
+		return ""
+	}
+	first := args.NamedChild(0)
+	if first.NamedChildCount() != 1 || first.NamedChild(0).Type() != "string" {
+		return ""
+	}
+	literal := first.NamedChild(0)
+	if literal.NamedChildCount() != 1 || literal.NamedChild(0).Type() != "string_content" {
+		return ""
+	}
+	return strings.TrimPrefix(literal.NamedChild(0).Content(source), `\`)
+}
+
+// declarationSignature retourne une déclaration sans son corps, ses blancs réduits à
+// une espace.
+func declarationSignature(n *sitter.Node, source []byte) string {
+	end := n.EndByte()
+	if body := n.ChildByFieldName("body"); body != nil {
+		end = body.StartByte()
+	}
+	signature := strings.TrimRight(string(source[n.StartByte():end]), " \t\r\n;")
+	return clipSignature(signature)
+}
+
+// clipSignature réduit les blancs d'une signature à une espace et la tronque à
+// maxSignatureLength octets.
+func clipSignature(signature string) string {
+	signature = strings.Join(strings.Fields(signature), " ")
+	if len(signature) <= maxSignatureLength {
+		return signature
+	}
+	cut := maxSignatureLength
+	for cut > 0 && !utf8.RuneStart(signature[cut]) {
+		cut--
+	}
+	return signature[:cut] + "…"
+}
+
+// writeSymbols affiche des symboles, un par ligne : genre, nom qualifié, position et
+// signature.
+func writeSymbols(out io.Writer, symbols []Symbol) {
+	for _, s := range symbols {
+		fmt.Fprintf(out, "%-9s %s  %s:%d  %s\n", s.Kind, s.Name, s.File, s.Line, s.Signature)
+	}
+	fmt.Fprintf(out, "%d symbole(s)\n", len(symbols))
+}
+
+// runSymbolsCommand exécute la commande symbols : les symboles des chemins donnés (le
+// dossier courant par défaut), filtrés par genre (-kind) et par nom (-name), en texte
+// ou en JSON avec --format=json.
+func runSymbolsCommand(ctx context.Context, analyzer *PHPAnalyzer, args []string, out io.Writer) error {
+	cmd := newFlagSet("symbols", out)
+	dirPath := cmd.String("dir", "", "Chemin vers le dossier à indexer récursivement")
+	var kinds listFlag
+	cmd.Var(&kinds, "kind", "Genres des symboles affichés, séparés par des virgules : "+strings.Join(symbolKinds, ", "))
+	pattern := cmd.String("name", "", `Motif du nom des symboles affichés ("*" et "?" acceptés), court ou qualifié (App\Models\*, User::find)`)
+	excludes := addExcludeFlags(cmd)
+	inputs, err := analyzer.parseCommandLine(cmd, args)
+	if err != nil {
+		return err
+	}
+	if err := applyExcludes(analyzer, excludes); err != nil {
+		return err
+	}
+	for _, kind := range kinds {
+		if !slices.Contains(symbolKinds, kind) {
+			fmt.Fprintf(out, "Genre de symbole inconnu : %q (attendu : %s)\n", kind, strings.Join(symbolKinds, ", "))
+			return errUsage
+		}
+	}
+	paths := scanPaths(*dirPath, inputs)
+	if len(paths) == 0 {
+		paths = []string{analyzer.abs(".")}
+	}
+	if err := analyzer.IndexProject(ctx, paths...); err != nil {
+		return err
+	}
+	symbols := analyzer.symbols.Query(kinds, *pattern)
+	if analyzer.Format == formatJSON {
+		if symbols == nil {
+			symbols = []Symbol{}
+		}
+		return writeIndentedJSON(out, symbols)
+	}
+	writeSymbols(out, symbols)
+	return nil
+}
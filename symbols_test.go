@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const symbolsSource = `<?php
+namespace App\Models;
+
+const VERSION = "1.0";
+define('APP_ROOT', __DIR__);
+
+interface Repository {
+    public function find(int $id): ?User;
+}
+
+trait Loggable {
+    protected static function log(string $message, ...$context) {}
+}
+
+abstract class User extends Model implements Repository, \JsonSerializable {
+    use Loggable;
+    public const TABLE = 'users', KEY = 'id';
+
+    final public function find(int $id): ?User {
+        return null;
+    }
+}
+
+function &helper($a,
+                 array $b = []): array {
+    $handler = new class { public function handle() {} };
+    return $b;
+}
+`
+
+func TestCollectSymbols(t *testing.T) {
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), []byte(symbolsSource))
+	assert.NoError(t, err)
+	var got []string
+	for _, s := range collectSymbols(tree.RootNode(), []byte(symbolsSource), "a.php") {
+		got = append(got, s.Kind+" "+s.Name+" | "+s.Signature)
+	}
+	assert.Equal(t, []string{
+		`constant App\Models\VERSION | const VERSION = "1.0"`,
+		`constant APP_ROOT | define('APP_ROOT', __DIR__)`,
+		`interface App\Models\Repository | interface Repository`,
+		`method App\Models\Repository::find | public function find(int $id): ?User`,
+		`trait App\Models\Loggable | trait Loggable`,
+		`method App\Models\Loggable::log | protected static function log(string $message, ...$context)`,
+		`class App\Models\User | abstract class User extends Model implements Repository, \JsonSerializable`,
+		`constant App\Models\User::TABLE | public const TABLE = 'users'`,
+		`constant App\Models\User::KEY | public const KEY = 'id'`,
+		`method App\Models\User::find | final public function find(int $id): ?User`,
+		`function App\Models\helper | function &helper($a, array $b = []): array`,
+	}, got, "anonymous class methods are not indexed")
+}
+
+func TestSymbolIndexQueries(t *testing.T) {
+	idx := newSymbolIndex([]Symbol{
+		{Kind: SymbolMethod, Name: `App\User::find`, File: "b.php", Line: 3},
+		{Kind: SymbolClass, Name: `App\User`, File: "b.php", Line: 1},
+		{Kind: SymbolFunction, Name: `find`, File: "a.php", Line: 8},
+	}, nil)
+	assert.Equal(t, "a.php", idx.Symbols[0].File, "symbols are sorted by file and line")
+	assert.Len(t, idx.Lookup(`\app\user`), 1)
+	assert.Empty(t, idx.Lookup(`User`), "lookups use qualified names")
+
+	names := func(symbols []Symbol) []string {
+		var result []string
+		for _, s := range symbols {
+			result = append(result, s.Name)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"find", `App\User::find`}, names(idx.Query(nil, "FIND")))
+	assert.Equal(t, []string{`App\User::find`}, names(idx.Query([]string{SymbolMethod}, "f*")))
+	assert.Equal(t, []string{`App\User`, `App\User::find`}, names(idx.Query(nil, `App\User*`)))
+	assert.Equal(t, []string{`App\User::find`}, names(idx.Query(nil, `*::find`)))
+	assert.Nil(t, (*SymbolIndex)(nil).Query(nil, ""))
+}
+
+func TestSymbolsCommand(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "models.php"), []byte(symbolsSource), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "compat.php"), []byte("<?php\nfunction find_user($id) {}\n"), 0o644))
+
+	var out strings.Builder
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "symbols", []string{"-kind", "function,method", "-name", "find*", dir}, &out))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 4)
+	assert.Contains(t, lines[0], "find_user  "+filepath.Join(dir, "compat.php")+":2  function find_user($id)")
+	assert.Equal(t, "3 symbole(s)", lines[3])
+
+	out.Reset()
+	analyzer := NewPHPAnalyzer()
+	analyzer.Format = formatJSON
+	assert.NoError(t, runCommand(analyzer, "symbols", []string{"-name", `App\Models\User`, dir}, &out))
+	var symbols []Symbol
+	assert.NoError(t, json.Unmarshal([]byte(out.String()), &symbols))
+	assert.Equal(t, []Symbol{{Kind: SymbolClass, Name: `App\Models\User`, File: filepath.Join(dir, "models.php"), Line: 15,
+		Signature: `abstract class User extends Model implements Repository, \JsonSerializable`}}, symbols)
+
+	out.Reset()
+	assert.Equal(t, errUsage, runCommand(NewPHPAnalyzer(), "symbols", []string{"-kind", "module", dir}, &out))
+	assert.Contains(t, out.String(), "Genre de symbole inconnu")
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// taintSources liste les superglobales dont le contenu est contrôlé par l'utilisateur.
+var taintSources = map[string]bool{
+	"$_GET":     true,
+	"$_POST":    true,
+	"$_COOKIE":  true,
+	"$_REQUEST": true,
+	"$_FILES":   true,
+	"$_SERVER":  true,
+}
+
+// dbReadFunctions retournent des données lues en base, qui peuvent avoir été écrites
+// par un attaquant (injections de second ordre, XSS stocké).
+var dbReadFunctions = map[string]bool{
+	"mysql_fetch_assoc":   true,
+	"mysql_fetch_array":   true,
+	"mysql_fetch_row":     true,
+	"mysql_fetch_object":  true,
+	"mysql_result":        true,
+	"mysqli_fetch_assoc":  true,
+	"mysqli_fetch_array":  true,
+	"mysqli_fetch_row":    true,
+	"mysqli_fetch_object": true,
+	"mysqli_fetch_all":    true,
+	"pg_fetch_assoc":      true,
+	"pg_fetch_array":      true,
+	"pg_fetch_row":        true,
+	"pg_fetch_object":     true,
+	"pg_fetch_result":     true,
+}
+
+// dbReadMethods sont les équivalents orientés objet (PDOStatement, mysqli_result, $wpdb).
+var dbReadMethods = map[string]bool{
+	"fetch":        true,
+	"fetchAll":     true,
+	"fetchColumn":  true,
+	"fetchObject":  true,
+	"fetch_assoc":  true,
+	"fetch_array":  true,
+	"fetch_row":    true,
+	"fetch_object": true,
+	"fetch_all":    true,
+	"get_results":  true,
+	"get_row":      true,
+	"get_var":      true,
+	"get_col":      true,
+}
+
+// Origines possibles d'une contamination, reprises dans les messages.
+const (
+	originUserInput = "entrée utilisateur"
+	originDBRead    = "lecture en base de données"
+	originDerived   = "valeur dérivée"
+)
+
+// xssSanitizers neutralisent une donnée avant son affichage HTML.
+var xssSanitizers = map[string]bool{
+	"htmlspecialchars": true,
+	"htmlentities":     true,
+	"strip_tags":       true,
+	"intval":           true,
+	"floatval":         true,
+	"urlencode":        true,
+	"rawurlencode":     true,
+	"number_format":    true,
+
+	// Laravel : e(), également produit par la compilation des balises Blade {{ }}
+	"e": true,
+	// Twig : échappement automatique des templates compilés (Twig 1 à 3 et EscaperRuntime)
+	"twig_escape_filter": true,
+	"escape":             true,
+	// WordPress
+	"esc_html":            true,
+	"esc_html__":          true,
+	"esc_attr":            true,
+	"esc_attr__":          true,
+	"esc_url":             true,
+	"esc_js":              true,
+	"esc_textarea":        true,
+	"wp_kses":             true,
+	"wp_kses_post":        true,
+	"sanitize_text_field": true,
+}
+
+// sqlSanitizers échappent ou convertissent une donnée avant son insertion dans une requête.
+var sqlSanitizers = map[string]bool{
+	"mysql_real_escape_string":  true,
+	"mysqli_real_escape_string": true,
+	"real_escape_string":        true,
+	"pg_escape_string":          true,
+	"pg_escape_literal":         true,
+	"addslashes":                true,
+	"quote":                     true,
+	"intval":                    true,
+	"floatval":                  true,
+}
+
+// pharSanitizers retirent le wrapper d'un chemin avant son utilisation par une fonction
+// de fichiers.
+var pharSanitizers = map[string]bool{
+	"basename": true,
+	"intval":   true,
+	"floatval": true,
+}
+
+// TaintOptions configure les sources et les fonctions de nettoyage d'un TaintTracker.
+type TaintOptions struct {
+	DBReads    bool            // les lectures en base sont des sources
+	Sanitizers map[string]bool // appels qui neutralisent la contamination de leur résultat
+}
+
+// TaintTracker suit, à l'échelle d'un fichier, les variables contaminées par une entrée
+// utilisateur. L'analyse est volontairement simple : elle est insensible au flot et à la
+// portée (deux variables homonymes dans deux fonctions sont confondues), ce qui suffit
+// pour des règles heuristiques.
+type TaintTracker struct {
+	source   []byte
+	tainted  map[string]string // variable -> origine de la contamination
+	isSource func(n *sitter.Node, source []byte) string
+	options  TaintOptions
+}
+
+// userInputOrigin reconnaît l'utilisation directe d'une superglobale et, si demandé,
+// la lecture d'une ligne en base.
+func userInputOrigin(dbReads bool) func(n *sitter.Node, source []byte) string {
+	return func(n *sitter.Node, source []byte) string {
+		switch n.Type() {
+		case "variable_name":
+			if taintSources[n.Content(source)] {
+				return originUserInput
+			}
+		case "function_call_expression":
+			if dbReads && dbReadFunctions[strings.ToLower(extractFunctionName(n, source))] {
+				return originDBRead
+			}
+		case "member_call_expression":
+			if dbReads && dbReadMethods[extractFunctionName(n, source)] {
+				return originDBRead
+			}
+		}
+		return ""
+	}
+}
+
+// NewTaintTracker propage la contamination sur les affectations du fichier jusqu'à
+// atteindre un point fixe.
+func NewTaintTracker(root *sitter.Node, source []byte, options TaintOptions) *TaintTracker {
+	return newTracker(root, source, userInputOrigin(options.DBReads), options)
+}
+
+// newDerivationTracker suit les variables dérivées des expressions reconnues par isSource,
+// ce qui permet de réutiliser la propagation pour des sources plus spécifiques qu'une
+// entrée utilisateur quelconque (par exemple le nom d'un fichier téléversé).
+func newDerivationTracker(root *sitter.Node, source []byte, isSource func(n *sitter.Node, source []byte) bool) *TaintTracker {
+	origin := func(n *sitter.Node, source []byte) string {
+		if isSource(n, source) {
+			return originDerived
+		}
+		return ""
+	}
+	return newTracker(root, source, origin, TaintOptions{})
+}
+
+func newTracker(root *sitter.Node, source []byte, isSource func(n *sitter.Node, source []byte) string, options TaintOptions) *TaintTracker {
+	t := &TaintTracker{source: source, tainted: make(map[string]string), isSource: isSource, options: options}
+	for changed := true; changed; {
+		changed = false
+		traverseAST(root, func(n *sitter.Node) {
+			if n.Type() != "assignment_expression" && n.Type() != "augmented_assignment_expression" {
+				return
+			}
+			left := n.ChildByFieldName("left")
+			right := n.ChildByFieldName("right")
+			if left == nil || right == nil {
+				return
+			}
+			origin := t.Origin(right)
+			if origin == "" {
+				return
+			}
+			traverseAST(left, func(v *sitter.Node) {
+				if v.Type() == "variable_name" && t.tainted[v.Content(source)] == "" {
+					t.tainted[v.Content(source)] = origin
+					changed = true
+				}
+			})
+		})
+	}
+	return t
+}
+
+// IsTainted indique si l'expression utilise une source ou une variable contaminée.
+func (t *TaintTracker) IsTainted(node *sitter.Node) bool {
+	return t.Origin(node) != ""
+}
+
+// Origin retourne l'origine de la contamination de l'expression, ou "" si elle est sûre.
+// Les sous-expressions passées à une fonction de nettoyage ou converties en nombre ne
+// sont pas examinées.
+func (t *TaintTracker) Origin(node *sitter.Node) string {
+	if node == nil {
+		return ""
+	}
+	if origin := t.isSource(node, t.source); origin != "" {
+		return origin
+	}
+	switch node.Type() {
+	case "variable_name":
+		return t.tainted[node.Content(t.source)]
+	case "function_call_expression", "member_call_expression":
+		if t.options.Sanitizers[sanitizerName(node, t.source)] {
+			return ""
+		}
+	case "cast_expression":
+		if isNumericCast(node, t.source) {
+			return ""
+		}
+	}
+	for i := 0; i < int(node.NamedChildCount()); i++ {
+		if origin := t.Origin(node.NamedChild(i)); origin != "" {
+			return origin
+		}
+	}
+	return ""
+}
+
+// sanitizerName retourne le nom d'une fonction ou d'une méthode appelée, sans espace de
+// noms et en minuscules (les noms de fonctions PHP ne sont pas sensibles à la casse).
+func sanitizerName(node *sitter.Node, source []byte) string {
+	name := extractFunctionName(node, source)
+	if i := strings.LastIndex(name, "\\"); i >= 0 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(name)
+}
+
+// isNumericCast reconnaît les conversions (int), (float) et (bool), qui neutralisent
+// une valeur contaminée.
+func isNumericCast(node *sitter.Node, source []byte) bool {
+	castType := node.ChildByFieldName("type")
+	if castType == nil {
+		return false
+	}
+	switch strings.ToLower(castType.Content(source)) {
+	case "int", "integer", "float", "double", "bool", "boolean":
+		return true
+	}
+	return false
+}
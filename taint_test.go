@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTaintTrackerPropagation(t *testing.T) {
+	phpCode := []byte(`<?php
+$c = $b;
+$b = "x" . $a;
+$a = $_GET["id"];
+$safe = "constant";`)
+
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), phpCode)
+	assert.NoError(t, err)
+
+	taint := NewTaintTracker(tree.RootNode(), phpCode, TaintOptions{})
+	assert.NotEmpty(t, taint.tainted["$a"])
+	assert.NotEmpty(t, taint.tainted["$b"])
+	assert.NotEmpty(t, taint.tainted["$c"], "propagation should reach a fixed point")
+	assert.Empty(t, taint.tainted["$safe"])
+}
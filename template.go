@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// templateFuncs sont les fonctions offertes aux modèles de -format=template, en plus de
+// celles de text/template.
+var templateFuncs = template.FuncMap{
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+	"trim":    strings.TrimSpace,
+	"replace": strings.ReplaceAll,
+	"join":    strings.Join,
+	"json": func(value any) (string, error) {
+		data, err := json.Marshal(value)
+		return string(data), err
+	},
+	"byFile": findingsByFile,
+	"atLeast": func(severity, threshold string) (bool, error) {
+		s, err := ParseSeverity(severity)
+		if err != nil {
+			return false, err
+		}
+		t, err := ParseSeverity(threshold)
+		return s >= t, err
+	},
+}
+
+// templateFile regroupe les résultats d'un fichier, pour la fonction byFile des modèles.
+type templateFile struct {
+	File     string
+	Findings []jsonFinding
+}
+
+// findingsByFile regroupe des résultats triés par fichier, dans leur ordre.
+func findingsByFile(findings []jsonFinding) []templateFile {
+	var files []templateFile
+	for _, f := range findings {
+		if len(files) == 0 || files[len(files)-1].File != f.File {
+			files = append(files, templateFile{File: f.File})
+		}
+		files[len(files)-1].Findings = append(files[len(files)-1].Findings, f)
+	}
+	return files
+}
+
+// Code retourne la ligne de code d'un résultat, pour les modèles.
+func (f jsonFinding) Code() string {
+	return f.code
+}
+
+// loadTemplate lit le modèle de l'option -template.
+func loadTemplate(path string) (*template.Template, error) {
+	return template.New(filepath.Base(path)).Funcs(templateFuncs).ParseFiles(path)
+}
+
+// writeTemplate écrit le rapport à travers le modèle de l'utilisateur, qui reçoit les
+// mêmes données que la sortie JSON : .Findings (triés par fichier puis par position),
+// .DeadCode, .Metrics, .Messages et .Summary.
+func writeTemplate(out io.Writer, r *jsonReport) error {
+	if r.template == nil {
+		return fmt.Errorf("l'option -template est requise avec -format=template")
+	}
+	r.sort()
+	if err := r.template.Execute(out, r); err != nil {
+		return fmt.Errorf("Erreur du modèle : %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// ClassDefinition est une classe, une interface, un trait ou une énumération du projet,
+// avec ce qui permet de résoudre ses méthodes : classe parente, traits utilisés et règles
+// de résolution des conflits entre traits (insteadof, as).
+type ClassDefinition struct {
+	Name   string   // nom qualifié, par exemple App\Http\Controller
+	Parent string   // nom qualifié de la classe parente, "" sans extends
+	Traits []string // noms qualifiés des traits utilisés, dans l'ordre du code
+	File   string
+	Line   uint32
+
+	methods   map[string]FunctionDefinition // méthodes déclarées, par nom en minuscules
+	insteadof map[string]string             // méthode en conflit (minuscules) -> trait retenu
+	aliases   map[string]traitMethod        // alias "as" (minuscules) -> méthode d'un trait
+}
+
+// traitMethod désigne la méthode d'un trait renommée par une clause as ; trait est ""
+// lorsque la clause ne le précise pas (helper as h), la méthode étant alors cherchée dans
+// chacun des traits utilisés.
+type traitMethod struct {
+	trait  string
+	method string // en minuscules
+}
+
+// collectClasses retourne les classes, interfaces, traits et énumérations nommés d'un
+// fichier, avec leurs méthodes résumées comme les fonctions (returnSummaries).
+func collectClasses(root *sitter.Node, source []byte, path string) []ClassDefinition {
+	resolver := newNameResolver(root, source)
+	var classes []ClassDefinition
+	traverseAST(root, func(n *sitter.Node) {
+		nameNode := n.ChildByFieldName("name")
+		if !isClassLike(n.Type()) || nameNode == nil {
+			return
+		}
+		line := n.StartPoint().Row + 1
+		class := ClassDefinition{
+			Name:      nameNode.Content(source),
+			File:      path,
+			Line:      line,
+			methods:   map[string]FunctionDefinition{},
+			insteadof: map[string]string{},
+			aliases:   map[string]traitMethod{},
+		}
+		if namespace := namespaceAtLine(root, line, source); namespace != "" {
+			class.Name = namespace + `\` + class.Name
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			if child := n.NamedChild(i); child.Type() == "base_clause" && child.NamedChildCount() > 0 {
+				parent := child.NamedChild(0)
+				class.Parent = resolver.resolveClass(parent.Content(source), parent.StartPoint().Row+1)
+			}
+		}
+		body := n.ChildByFieldName("body")
+		if body == nil {
+			classes = append(classes, class)
+			return
+		}
+		for i := 0; i < int(body.NamedChildCount()); i++ {
+			switch member := body.NamedChild(i); member.Type() {
+			case "method_declaration":
+				name := member.ChildByFieldName("name")
+				if name == nil {
+					continue
+				}
+				class.methods[strings.ToLower(name.Content(source))] = FunctionDefinition{
+					Name:    class.Name + "::" + name.Content(source),
+					File:    path,
+					Line:    member.StartPoint().Row + 1,
+					returns: returnSummaries(member.ChildByFieldName("body"), source),
+				}
+			case "use_declaration":
+				class.addTraitUse(member, resolver, source)
+			}
+		}
+		classes = append(classes, class)
+	})
+	return classes
+}
+
+// addTraitUse enregistre les traits d'une déclaration use de classe et les règles de son
+// bloc : "T::m insteadof A" retient la méthode m de T, "T::m as n" et "m as n" ajoutent
+// l'alias n. Une clause as qui ne change que la visibilité (m as private) est ignorée.
+func (c *ClassDefinition) addTraitUse(use *sitter.Node, resolver *nameResolver, source []byte) {
+	resolve := func(n *sitter.Node) string {
+		return resolver.resolveClass(n.Content(source), n.StartPoint().Row+1)
+	}
+	// reference retourne le trait ("" s'il n'est pas précisé) et la méthode d'une
+	// référence T::m ou m.
+	reference := func(n *sitter.Node) (string, string) {
+		if n.Type() == "class_constant_access_expression" && n.NamedChildCount() == 2 {
+			return resolve(n.NamedChild(0)), strings.ToLower(n.NamedChild(1).Content(source))
+		}
+		return "", strings.ToLower(n.Content(source))
+	}
+	for i := 0; i < int(use.NamedChildCount()); i++ {
+		child := use.NamedChild(i)
+		switch child.Type() {
+		case "name", "qualified_name":
+			if trait := resolve(child); trait != "" {
+				c.Traits = append(c.Traits, trait)
+			}
+			continue
+		case "use_list":
+		default:
+			continue
+		}
+		for j := 0; j < int(child.NamedChildCount()); j++ {
+			clause := child.NamedChild(j)
+			if clause.NamedChildCount() < 2 {
+				continue
+			}
+			switch clause.Type() {
+			case "use_instead_of_clause":
+				if trait, method := reference(clause.NamedChild(0)); trait != "" {
+					c.insteadof[method] = trait
+				}
+			case "use_as_clause":
+				alias := clause.NamedChild(int(clause.NamedChildCount()) - 1)
+				if alias.Type() != "name" {
+					continue
+				}
+				trait, method := reference(clause.NamedChild(0))
+				c.aliases[strings.ToLower(alias.Content(source))] = traitMethod{trait: trait, method: method}
+			}
+		}
+	}
+}
+
+// classIndex associe les classes du projet à leur nom qualifié en minuscules (les noms
+// de classes PHP sont insensibles à la casse).
+type classIndex map[string]ClassDefinition
+
+func (idx classIndex) add(class ClassDefinition) {
+	idx[strings.ToLower(class.Name)] = class
+}
+
+// resolveMethod retourne la définition de la méthode method appelée sur une instance de
+// class ($this->method() dans ses méthodes), dans l'ordre de PHP : méthode de la classe,
+// puis de ses traits (règles insteadof et alias as comprises), puis de la classe parente.
+func (idx classIndex) resolveMethod(class, method string) (FunctionDefinition, bool) {
+	return idx.lookupMethod(strings.ToLower(strings.TrimPrefix(class, `\`)), strings.ToLower(method), map[string]bool{})
+}
+
+// lookupMethod cherche une méthode (noms en minuscules) ; seen évite de boucler sur un
+// héritage ou des traits cycliques.
+func (idx classIndex) lookupMethod(class, method string, seen map[string]bool) (FunctionDefinition, bool) {
+	if seen[class+"::"+method] {
+		return FunctionDefinition{}, false
+	}
+	seen[class+"::"+method] = true
+	definition, ok := idx[class]
+	if !ok {
+		return FunctionDefinition{}, false
+	}
+	if m, ok := definition.methods[method]; ok {
+		return m, true
+	}
+	if alias, ok := definition.aliases[method]; ok {
+		for _, trait := range definition.traitsFor(alias.trait) {
+			if m, ok := idx.lookupMethod(strings.ToLower(trait), alias.method, seen); ok {
+				return m, true
+			}
+		}
+	}
+	for _, trait := range definition.traitsFor(definition.insteadof[method]) {
+		if m, ok := idx.lookupMethod(strings.ToLower(trait), method, seen); ok {
+			return m, true
+		}
+	}
+	if definition.Parent == "" {
+		return FunctionDefinition{}, false
+	}
+	return idx.lookupMethod(strings.ToLower(definition.Parent), method, seen)
+}
+
+// traitsFor retourne les traits où chercher une méthode : le trait désigné, sinon tous
+// les traits de la classe.
+func (c ClassDefinition) traitsFor(trait string) []string {
+	if trait != "" {
+		return []string{trait}
+	}
+	return c.Traits
+}
+
+// methodNames retourne les noms (en minuscules) des méthodes appelables sur une instance
+// de class : les siennes, les alias et méthodes de ses traits, et celles héritées.
+func (idx classIndex) methodNames(class string) []string {
+	var names []string
+	seen := map[string]bool{}
+	var visit func(class string)
+	visit = func(class string) {
+		definition, ok := idx[strings.ToLower(class)]
+		if !ok || seen[strings.ToLower(class)] {
+			return
+		}
+		seen[strings.ToLower(class)] = true
+		for name := range definition.methods {
+			names = append(names, name)
+		}
+		for name := range definition.aliases {
+			names = append(names, name)
+		}
+		for _, trait := range definition.Traits {
+			visit(trait)
+		}
+		visit(definition.Parent)
+	}
+	visit(class)
+	return names
+}
+
+// sanitizers complète des fonctions de nettoyage par les méthodes des classes classes
+// qui en enveloppent une, directement ou par l'intermédiaire d'une autre méthode : avec
+// un trait dont la méthode e($v) retourne htmlspecialchars($v), $this->e($v) est nettoyé
+// dans les classes qui l'utilisent. La méthode retenue est celle que résout
+// resolveMethod, sous son nom ou son alias.
+func (idx classIndex) sanitizers(classes []string, sanitizers map[string]bool) map[string]bool {
+	result := make(map[string]bool, len(sanitizers))
+	for name := range sanitizers {
+		result[name] = true
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, class := range classes {
+			for _, name := range idx.methodNames(class) {
+				if result[name] {
+					continue
+				}
+				if method, ok := idx.resolveMethod(class, name); ok && method.sanitizes(result) {
+					result[name] = true
+					changed = true
+				}
+			}
+		}
+	}
+	return result
+}
+
+// projectClasses retourne l'index des classes du projet complété par celles du fichier
+// analysé, ainsi que les noms de ces dernières.
+func (pa *PHPAnalyzer) projectClasses(root *sitter.Node, source []byte) (classIndex, []string) {
+	index := make(classIndex, len(pa.classes))
+	for _, class := range pa.classes {
+		index.add(class)
+	}
+	var declared []string
+	for _, class := range collectClasses(root, source, "") {
+		index.add(class)
+		declared = append(declared, class.Name)
+	}
+	return index, declared
+}
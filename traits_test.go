@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveTraitMethods(t *testing.T) {
+	source := []byte(`<?php
+namespace App;
+use App\Support\Escapes as Esc;
+
+trait Raw { public function escape($v) { return $v; } }
+trait Logs { use Raw; public function log($m) {} }
+class Base { public function render($v) { return $v; } }
+class Page extends Base {
+    use Esc, Raw, Logs {
+        Esc::escape insteadof Raw;
+        Raw::escape as protected raw;
+        log as private;
+        log as write;
+    }
+    public function render($v) { return $this->escape($v); }
+}
+class Loop extends Loop {}
+`)
+	analyzer := NewPHPAnalyzer()
+	tree, err := analyzer.Parse(context.Background(), source)
+	assert.NoError(t, err)
+	classes := collectClasses(tree.RootNode(), source, "page.php")
+	assert.Len(t, classes, 5)
+	page := classes[3]
+	assert.Equal(t, `App\Page`, page.Name)
+	assert.Equal(t, `App\Base`, page.Parent)
+	assert.Equal(t, []string{`App\Support\Escapes`, `App\Raw`, `App\Logs`}, page.Traits)
+
+	index := classIndex{}
+	for _, class := range classes {
+		index.add(class)
+	}
+	index.add(ClassDefinition{Name: `App\Support\Escapes`, File: "escapes.php", methods: map[string]FunctionDefinition{
+		"escape": {Name: `App\Support\Escapes::escape`, File: "escapes.php", Line: 3},
+	}})
+	resolved := func(class, method string) string {
+		definition, ok := index.resolveMethod(class, method)
+		if !ok {
+			return ""
+		}
+		return definition.Name
+	}
+	assert.Equal(t, `App\Page::render`, resolved(`\App\Page`, "RENDER"), "the class method wins over the parent's")
+	assert.Equal(t, `App\Support\Escapes::escape`, resolved(`App\Page`, "escape"), "insteadof picks the trait")
+	assert.Equal(t, `App\Raw::escape`, resolved(`App\Page`, "raw"), "as adds an alias")
+	assert.Equal(t, `App\Logs::log`, resolved(`App\Page`, "write"))
+	assert.Equal(t, `App\Logs::log`, resolved(`App\Page`, "log"), "a visibility-only as clause keeps the name")
+	assert.Equal(t, `App\Raw::escape`, resolved(`App\Logs`, "escape"), "traits can use traits")
+	assert.Empty(t, resolved(`App\Page`, "missing"))
+	assert.Empty(t, resolved(`App\Loop`, "missing"), "inheritance cycles terminate")
+}
+
+func TestTraitSanitizers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/Escapes.php": "<?php\nnamespace App;\ntrait Escapes {\n    public function h($v) { return htmlspecialchars($v, ENT_QUOTES); }\n}\n",
+		"src/Raw.php":     "<?php\nnamespace App;\ntrait Raw {\n    public function h($v) { return $v; }\n    public function clean($v) { return $this->h($v); }\n}\n",
+		"web/safe.php": `<?php
+namespace App;
+class SafePage {
+    use Escapes, Raw { Escapes::h insteadof Raw; Raw::h as raw; }
+    public function show() { echo $this->h($_GET['name']); echo $this->clean($_GET['name']); }
+}
+`,
+		"web/unsafe.php": `<?php
+namespace App;
+class UnsafePage {
+    use Escapes, Raw { Raw::h insteadof Escapes; Escapes::h as escaped; }
+    public function show() { echo $this->h($_GET['name']); echo $this->escaped($_GET['name']); }
+}
+`,
+	})
+
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.ContextLines = -1
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-fail-on", "critical", dir}, &out))
+	assert.NotContains(t, out.String(), string(filepath.Separator)+"safe.php", "the method kept by insteadof sanitizes, and so does its caller")
+	assert.Equal(t, 1, strings.Count(out.String(), "[xss"), out.String())
+	assert.Contains(t, out.String(), "unsafe.php")
+}
@@ -0,0 +1,460 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	sitter "github.com/smacker/go-tree-sitter"
+)
+
+// Appels qui ouvrent ou terminent une transaction (PDO, mysqli, Doctrine, façade DB de
+// Laravel), comparés en minuscules et sans la classe des appels statiques.
+var (
+	transactionBegins = map[string]bool{"begintransaction": true, "begin_transaction": true, "mysqli_begin_transaction": true}
+	transactionEnds   = map[string]bool{"commit": true, "rollback": true, "mysqli_commit": true, "mysqli_rollback": true}
+)
+
+// transactionCall retourne l'effet d'un appel sur la transaction : +1 s'il l'ouvre, -1
+// s'il la termine, 0 sinon.
+func transactionCall(n *sitter.Node, source []byte) int {
+	switch n.Type() {
+	case "function_call_expression", "member_call_expression", "scoped_call_expression":
+	default:
+		return 0
+	}
+	name := transactionName(n, source)
+	switch {
+	case transactionBegins[name]:
+		return 1
+	case transactionEnds[name]:
+		return -1
+	}
+	return 0
+}
+
+// transactionName retourne le nom d'une fonction ou méthode appelée, en minuscules et
+// sans la classe des appels statiques.
+func transactionName(n *sitter.Node, source []byte) string {
+	name := strings.ToLower(extractFunctionName(n, source))
+	return name[strings.LastIndex(name, ":")+1:]
+}
+
+// txStates est l'ensemble des états possibles de la transaction en un point de la
+// fonction : la ligne de l'appel qui l'a ouverte, ou 0 si aucune n'est ouverte.
+type txStates map[uint32]bool
+
+// key identifie un ensemble d'états, quel que soit l'ordre de ses éléments.
+func (s txStates) key() string {
+	lines := make([]int, 0, len(s))
+	for state := range s {
+		lines = append(lines, int(state))
+	}
+	sort.Ints(lines)
+	return fmt.Sprint(lines)
+}
+
+func (s txStates) union(other txStates) txStates {
+	result := make(txStates, len(s)+len(other))
+	for state := range s {
+		result[state] = true
+	}
+	for state := range other {
+		result[state] = true
+	}
+	return result
+}
+
+// transactionPaths suit les chemins d'exécution d'une fonction en propageant l'état de
+// la transaction à travers ses structures de contrôle (if, boucles, switch, try/catch/
+// finally, return, throw, exit), et relève les sorties de la fonction atteintes avec une
+// transaction ouverte.
+type transactionPaths struct {
+	source []byte
+	// exits associe la ligne de chaque ouverture de transaction restée ouverte à la
+	// première sortie de la fonction où elle l'est encore.
+	exits map[uint32]uint32
+
+	jumps    []jumpTargets  // états des break et continue de chaque boucle ou switch englobant
+	tries    []txStates     // états observés dans chaque bloc try englobant ayant un catch
+	finallys []*sitter.Node // blocs finally englobants, du plus extérieur au plus intérieur
+
+	// loops retient le résultat des boucles déjà parcourues à partir des mêmes états :
+	// une boucle imbriquée n'est pas reparcourue à chaque itération de la boucle qui
+	// la contient, dont le coût se multiplierait avec la profondeur.
+	loops map[loopRun]loopResult
+
+	// transfer remplace l'effet des appels sur la transaction, pour suivre un autre état
+	// le long des mêmes chemins (contrôles d'un fichier téléversé) : il reçoit chaque
+	// nœud d'une instruction, dans l'ordre de son évaluation, et retourne les états
+	// qui le suivent.
+	transfer func(n *sitter.Node, states txStates) txStates
+}
+
+// jumpTargets reçoit les états des break et continue d'une boucle ou d'un switch. Les
+// continue d'une boucle reprennent à son itération suivante ; ceux d'un switch, sans
+// itération, le quittent comme un break.
+type jumpTargets struct {
+	breaks, continues txStates
+}
+
+// loopRun identifie le parcours d'une boucle : son nœud et les états à son entrée.
+type loopRun struct {
+	start  uint32
+	states string
+}
+
+// loopResult est le résultat d'une boucle : les états de sa fin et ceux qu'elle a
+// transmis au bloc try englobant, à transmettre de nouveau quand il est réutilisé.
+type loopResult struct {
+	out, observed txStates
+}
+
+// exit enregistre une sortie de la fonction à la ligne line, après les blocs finally
+// englobants.
+func (tp *transactionPaths) exit(states txStates, line uint32) {
+	finallys := tp.finallys
+	for i := len(finallys) - 1; i >= 0 && len(states) > 0; i-- {
+		tp.finallys = finallys[:i]
+		states = tp.exec(finallys[i], states)
+	}
+	tp.finallys = finallys
+	for begin := range states {
+		if first, seen := tp.exits[begin]; begin != 0 && (!seen || line < first) {
+			tp.exits[begin] = line
+		}
+	}
+}
+
+// observe transmet un état intermédiaire au bloc try englobant : une exception levée à
+// ce point mène à son catch.
+func (tp *transactionPaths) observe(states txStates) {
+	if len(tp.tries) > 0 {
+		for state := range states {
+			tp.tries[len(tp.tries)-1][state] = true
+		}
+	}
+}
+
+// throw traite une exception levée : elle est capturée par le try englobant s'il y en a
+// un, sinon elle quitte la fonction.
+func (tp *transactionPaths) throw(states txStates, line uint32) {
+	if len(tp.tries) > 0 {
+		tp.observe(states)
+		return
+	}
+	tp.exit(states, line)
+}
+
+// effects applique dans l'ordre du code les appels d'une expression ou d'une
+// instruction simple, hors fonctions imbriquées. Le résultat est vide si l'instruction
+// termine le chemin (exit, die, throw).
+func (tp *transactionPaths) effects(n *sitter.Node, states txStates) txStates {
+	var visit func(n *sitter.Node)
+	visit = func(n *sitter.Node) {
+		if len(states) == 0 || isNestedScope(n) {
+			return
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			visit(n.NamedChild(i))
+		}
+		line := n.StartPoint().Row + 1
+		switch n.Type() {
+		case "throw_expression":
+			tp.throw(states, line)
+			if n.Parent() != nil && n.Parent().Type() == "expression_statement" {
+				states = nil
+			}
+			return
+		case "exit_statement":
+			states = nil
+			return
+		case "function_call_expression":
+			if name := strings.ToLower(extractFunctionName(n, tp.source)); name == "die" || name == "exit" {
+				states = nil
+				return
+			}
+		}
+		if tp.transfer != nil {
+			states = tp.transfer(n, states)
+			tp.observe(states)
+			return
+		}
+		switch transactionCall(n, tp.source) {
+		case 1:
+			states = txStates{line: true}
+		case -1:
+			states = txStates{0: true}
+		default:
+			return
+		}
+		tp.observe(states)
+	}
+	visit(n)
+	return states
+}
+
+// inTransactionTest reconnaît une condition qui teste si une transaction est ouverte :
+// 1 pour inTransaction(), -1 pour sa négation, 0 sinon.
+func inTransactionTest(condition *sitter.Node, source []byte) int {
+	result := 1
+	for condition != nil {
+		switch condition.Type() {
+		case "parenthesized_expression":
+			condition = condition.NamedChild(0)
+		case "unary_op_expression":
+			if operator := condition.ChildByFieldName("operator"); operator == nil || operator.Type() != "!" {
+				return 0
+			}
+			result = -result
+			condition = condition.NamedChild(0)
+		case "member_call_expression", "scoped_call_expression":
+			if name := transactionName(condition, source); name == "intransaction" || name == "transactionlevel" {
+				return result
+			}
+			return 0
+		default:
+			return 0
+		}
+	}
+	return 0
+}
+
+// isNestedScope vérifie si un nœud ouvre une portée analysée séparément.
+func isNestedScope(n *sitter.Node) bool {
+	switch n.Type() {
+	case "function_definition", "method_declaration", "anonymous_function_creation_expression", "arrow_function",
+		"class_declaration", "interface_declaration", "trait_declaration", "enum_declaration":
+		return true
+	}
+	return false
+}
+
+// exec propage les états à travers une instruction et retourne ceux de sa fin normale.
+func (tp *transactionPaths) exec(n *sitter.Node, states txStates) txStates {
+	if n == nil || len(states) == 0 {
+		return states
+	}
+	line := n.StartPoint().Row + 1
+	switch n.Type() {
+	case "compound_statement", "colon_block", "program":
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			states = tp.exec(n.NamedChild(i), states)
+		}
+		return states
+
+	case "if_statement":
+		rest := tp.effects(n.ChildByFieldName("condition"), states)
+		body := rest
+		// if ($db->inTransaction()) : aucune transaction n'est ouverte dans l'autre branche.
+		switch inTransactionTest(n.ChildByFieldName("condition"), tp.source) {
+		case 1:
+			rest = txStates{0: true}
+		case -1:
+			body = txStates{0: true}
+		}
+		out := tp.exec(n.ChildByFieldName("body"), body)
+		for i := 0; i < int(n.ChildCount()); i++ {
+			if n.FieldNameForChild(i) != "alternative" {
+				continue
+			}
+			alternative := n.Child(i)
+			if alternative.Type() == "else_if_clause" {
+				rest = tp.effects(alternative.ChildByFieldName("condition"), rest)
+				out = out.union(tp.exec(alternative.ChildByFieldName("body"), rest))
+				continue
+			}
+			out = out.union(tp.exec(alternative.ChildByFieldName("body"), rest))
+			rest = nil
+		}
+		return out.union(rest)
+
+	case "while_statement", "for_statement", "foreach_statement", "do_statement":
+		run := loopRun{n.StartByte(), states.key()}
+		if result, ok := tp.loops[run]; ok {
+			tp.observe(result.observed)
+			return result.out
+		}
+		// Les états transmis au try englobant pendant la boucle sont relevés à part.
+		var enclosing txStates
+		if len(tp.tries) > 0 {
+			enclosing = tp.tries[len(tp.tries)-1]
+			tp.tries[len(tp.tries)-1] = txStates{}
+		}
+		result := loopResult{out: tp.loop(n, states)}
+		if enclosing != nil {
+			result.observed = tp.tries[len(tp.tries)-1]
+			tp.tries[len(tp.tries)-1] = enclosing.union(result.observed)
+		}
+		if tp.loops == nil {
+			tp.loops = make(map[loopRun]loopResult)
+		}
+		tp.loops[run] = result
+		return result.out
+
+	case "switch_statement":
+		head := tp.effects(n.ChildByFieldName("condition"), states)
+		tp.jumps = append(tp.jumps, jumpTargets{breaks: txStates{}})
+		current, hasDefault := txStates{}, false
+		if block := n.ChildByFieldName("body"); block != nil {
+			for i := 0; i < int(block.NamedChildCount()); i++ {
+				clause := block.NamedChild(i)
+				if clause.Type() != "case_statement" && clause.Type() != "default_statement" {
+					continue
+				}
+				hasDefault = hasDefault || clause.Type() == "default_statement"
+				current = current.union(head)
+				for j := 0; j < int(clause.ChildCount()); j++ {
+					if child := clause.Child(j); child.IsNamed() && clause.FieldNameForChild(j) != "value" {
+						current = tp.exec(child, current)
+					}
+				}
+			}
+		}
+		out := current.union(tp.jumps[len(tp.jumps)-1].breaks)
+		tp.jumps = tp.jumps[:len(tp.jumps)-1]
+		if !hasDefault {
+			out = out.union(head)
+		}
+		return out
+
+	case "break_statement", "continue_statement":
+		if len(tp.jumps) > 0 {
+			target := tp.jumps[len(tp.jumps)-1].breaks
+			if n.Type() == "continue_statement" && tp.jumps[len(tp.jumps)-1].continues != nil {
+				target = tp.jumps[len(tp.jumps)-1].continues
+			}
+			for state := range states {
+				target[state] = true
+			}
+		}
+		return nil
+
+	case "try_statement":
+		var catches []*sitter.Node
+		var finally *sitter.Node
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			switch child := n.NamedChild(i); child.Type() {
+			case "catch_clause":
+				catches = append(catches, child)
+			case "finally_clause":
+				finally = child
+			}
+		}
+		if finally != nil {
+			tp.finallys = append(tp.finallys, finally.ChildByFieldName("body"))
+		}
+		if len(catches) > 0 {
+			tp.tries = append(tp.tries, states.union(nil))
+		}
+		out := tp.exec(n.ChildByFieldName("body"), states)
+		if len(catches) > 0 {
+			thrown := tp.tries[len(tp.tries)-1]
+			tp.tries = tp.tries[:len(tp.tries)-1]
+			for _, catch := range catches {
+				out = out.union(tp.exec(catch.ChildByFieldName("body"), thrown))
+			}
+		}
+		if finally != nil {
+			tp.finallys = tp.finallys[:len(tp.finallys)-1]
+			out = tp.exec(finally.ChildByFieldName("body"), out)
+		}
+		return out
+
+	case "return_statement":
+		states = tp.effects(n, states)
+		if len(states) > 0 {
+			tp.exit(states, line)
+		}
+		return nil
+	}
+	if isNestedScope(n) {
+		return states
+	}
+	return tp.effects(n, states)
+}
+
+// loop propage les états à travers une boucle jusqu'à ce que ceux de l'entrée de son
+// corps se stabilisent : les états en nombre fini ne font que croître. Les fins du
+// corps et les continue reprennent à l'itération suivante ou quittent la boucle, les
+// break la quittent.
+func (tp *transactionPaths) loop(n *sitter.Node, states txStates) txStates {
+	body := n.ChildByFieldName("body")
+	head := states
+	for i := 0; i < int(n.NamedChildCount()); i++ {
+		if child := n.NamedChild(i); child != body {
+			head = tp.effects(child, head)
+		}
+	}
+	jumps := jumpTargets{breaks: txStates{}, continues: txStates{}}
+	tp.jumps = append(tp.jumps, jumps)
+	out := head
+	if n.Type() == "do_statement" {
+		out = txStates{}
+	}
+	for entry := head; ; {
+		after := tp.exec(body, entry).union(jumps.continues)
+		next := entry.union(after)
+		out = out.union(after)
+		if len(next) == len(entry) {
+			break
+		}
+		entry = next
+	}
+	tp.jumps = tp.jumps[:len(tp.jumps)-1]
+	return out.union(jumps.breaks)
+}
+
+// transactionBody vérifie si le corps d'une fonction ouvre et termine une transaction,
+// hors fonctions imbriquées. Une fonction qui ne fait que l'ouvrir délègue sa fin à une
+// autre et n'est pas analysée.
+func transactionBody(body *sitter.Node, source []byte) bool {
+	begins, ends := false, false
+	var visit func(n *sitter.Node)
+	visit = func(n *sitter.Node) {
+		switch transactionCall(n, source) {
+		case 1:
+			begins = true
+		case -1:
+			ends = true
+		}
+		for i := 0; i < int(n.NamedChildCount()); i++ {
+			if child := n.NamedChild(i); !isNestedScope(child) {
+				visit(child)
+			}
+		}
+	}
+	visit(body)
+	return begins && ends
+}
+
+// detectUnclosedTransactions signale, pour chaque fonction (et le code de premier niveau)
+// qui ouvre et termine une transaction, les ouvertures restant sans commit ni rollback
+// sur au moins un chemin menant à la sortie de la fonction.
+func detectUnclosedTransactions(pa *PHPAnalyzer, root *sitter.Node, source []byte) []Finding {
+	var findings []Finding
+	analyze := func(body *sitter.Node, end uint32) {
+		if body == nil || !transactionBody(body, source) {
+			return
+		}
+		tp := &transactionPaths{source: source, exits: make(map[uint32]uint32)}
+		tp.exit(tp.exec(body, txStates{0: true}), end)
+		begins := make([]uint32, 0, len(tp.exits))
+		for begin := range tp.exits {
+			begins = append(begins, begin)
+		}
+		sort.Slice(begins, func(i, j int) bool { return begins[i] < begins[j] })
+		for _, begin := range begins {
+			findings = append(findings, newFinding("transaction-unclosed", begin,
+				fmt.Sprintf("transaction ouverte sans commit ni rollback sur un chemin menant à la sortie ligne %d", tp.exits[begin])))
+		}
+	}
+	analyze(root, root.EndPoint().Row+1)
+	traverseAST(root, func(n *sitter.Node) {
+		switch n.Type() {
+		case "function_definition", "method_declaration", "anonymous_function_creation_expression":
+			analyze(n.ChildByFieldName("body"), n.EndPoint().Row+1)
+		}
+	})
+	return findings
+}
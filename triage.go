@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+)
+
+// Verdicts possibles d'une entrée de triage.
+const (
+	VerdictTruePositive  = "true_positive"
+	VerdictFalsePositive = "false_positive"
+)
+
+// TriageRecord est une décision de triage sur un résultat, enregistrée une par ligne
+// (JSON Lines) dans l'historique de triage du projet.
+type TriageRecord struct {
+	Rule    string `json:"rule"`
+	File    string `json:"file"`
+	Line    uint32 `json:"line"`
+	Verdict string `json:"verdict"`
+}
+
+// RuleTriageStats résume les décisions de triage d'une règle.
+type RuleTriageStats struct {
+	Rule           string
+	Total          int
+	FalsePositives int
+}
+
+// FalsePositiveRate retourne la proportion de résultats marqués comme faux positifs.
+func (s RuleTriageStats) FalsePositiveRate() float64 {
+	if s.Total == 0 {
+		return 0
+	}
+	return float64(s.FalsePositives) / float64(s.Total)
+}
+
+// Seuils de recalibrage : une règle n'est déclassée qu'avec suffisamment d'historique.
+const (
+	minTriageSamples     = 5
+	downgradeFPThreshold = 0.5
+)
+
+// Calibration associe à chaque règle le nombre de niveaux dont sa sévérité et sa
+// confiance doivent être abaissées.
+type Calibration map[string]int
+
+// LoadTriageHistory lit un historique de triage au format JSON Lines.
+func LoadTriageHistory(path string) ([]TriageRecord, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []TriageRecord
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var record TriageRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNumber, err)
+		}
+		records = append(records, record)
+	}
+	return records, scanner.Err()
+}
+
+// AppendTriageHistory ajoute des décisions à la fin d'un historique de triage, créé s'il
+// n'existe pas.
+func AppendTriageHistory(path string, records []TriageRecord) error {
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(file)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
+
+// ComputeTriageStats agrège les décisions par règle, triées par taux de faux positifs décroissant.
+func ComputeTriageStats(records []TriageRecord) []RuleTriageStats {
+	byRule := make(map[string]*RuleTriageStats)
+	for _, record := range records {
+		stats, ok := byRule[record.Rule]
+		if !ok {
+			stats = &RuleTriageStats{Rule: record.Rule}
+			byRule[record.Rule] = stats
+		}
+		stats.Total++
+		if record.Verdict == VerdictFalsePositive {
+			stats.FalsePositives++
+		}
+	}
+
+	var result []RuleTriageStats
+	for _, stats := range byRule {
+		result = append(result, *stats)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].FalsePositiveRate() != result[j].FalsePositiveRate() {
+			return result[i].FalsePositiveRate() > result[j].FalsePositiveRate()
+		}
+		return result[i].Rule < result[j].Rule
+	})
+	return result
+}
+
+// SuggestCalibration propose un déclassement d'un niveau pour les règles dont le taux de
+// faux positifs dépasse 50 %, et de deux niveaux au-delà de 80 %.
+func SuggestCalibration(stats []RuleTriageStats) Calibration {
+	calibration := make(Calibration)
+	for _, s := range stats {
+		if s.Total < minTriageSamples || s.FalsePositiveRate() < downgradeFPThreshold {
+			continue
+		}
+		calibration[s.Rule] = 1
+		if s.FalsePositiveRate() >= 0.8 {
+			calibration[s.Rule] = 2
+		}
+	}
+	return calibration
+}
+
+// WriteTriageReport affiche le taux de faux positifs observé pour chaque règle et les
+// déclassements suggérés.
+func WriteTriageReport(out io.Writer, stats []RuleTriageStats, calibration Calibration) {
+	for _, s := range stats {
+		fmt.Fprintf(out, "%s : %d/%d faux positifs (%.0f %%)", s.Rule, s.FalsePositives, s.Total, 100*s.FalsePositiveRate())
+		if levels := calibration[s.Rule]; levels > 0 {
+			fmt.Fprintf(out, " -> déclassement suggéré de %d niveau(x)", levels)
+		}
+		fmt.Fprintln(out)
+	}
+}
+
+// SaveCalibration écrit le fichier de calibration appliqué aux analyses suivantes.
+func SaveCalibration(path string, calibration Calibration) error {
+	data, err := json.MarshalIndent(calibration, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// LoadCalibration lit un fichier de calibration ; un fichier absent donne une calibration vide.
+func LoadCalibration(path string) (Calibration, error) {
+	calibration := make(Calibration)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return calibration, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return calibration, json.Unmarshal(data, &calibration)
+}
+
+// defaultCalibrationPath est le fichier de calibration lu et écrit par défaut.
+const defaultCalibrationPath = ".phpanalyzer-calibration.json"
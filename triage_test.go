@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriageCalibration(t *testing.T) {
+	var lines []string
+	for i := 0; i < 6; i++ {
+		verdict := VerdictFalsePositive
+		if i == 0 {
+			verdict = VerdictTruePositive
+		}
+		lines = append(lines, `{"rule": "TypeJuggling / CWE-697", "file": "a.php", "line": 3, "verdict": "`+verdict+`"}`)
+	}
+	lines = append(lines, `{"rule": "CVE-2019-9025", "file": "b.php", "line": 8, "verdict": "false_positive"}`)
+	history := filepath.Join(t.TempDir(), "triage.jsonl")
+	assert.NoError(t, os.WriteFile(history, []byte(strings.Join(lines, "\n")), 0o644))
+
+	records, err := LoadTriageHistory(history)
+	assert.NoError(t, err)
+	stats := ComputeTriageStats(records)
+	assert.Len(t, stats, 2)
+	assert.Equal(t, "CVE-2019-9025", stats[0].Rule, "highest false-positive rate first")
+	assert.InDelta(t, 5.0/6.0, stats[1].FalsePositiveRate(), 0.001)
+
+	calibration := SuggestCalibration(stats)
+	assert.Equal(t, Calibration{"TypeJuggling / CWE-697": 2}, calibration,
+		"rules with too little history are not downgraded")
+
+	var out strings.Builder
+	WriteTriageReport(&out, stats, calibration)
+	assert.Contains(t, out.String(), "TypeJuggling / CWE-697 : 5/6 faux positifs (83 %) -> déclassement suggéré de 2 niveau(x)")
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/term"
+)
+
+// Fichiers écrits par défaut par la commande tui.
+const (
+	defaultBaselinePath = ".phpanalyzer-baseline.json"
+	defaultHistoryPath  = ".phpanalyzer-triage.jsonl"
+)
+
+// errNoTerminal signale une commande tui lancée sans terminal (entrée ou sortie
+// redirigée, démon).
+var errNoTerminal = errors.New("la commande tui nécessite un terminal")
+
+// triageItem est un résultat présenté dans l'interface de triage, avec la décision
+// prise pendant la session.
+type triageItem struct {
+	path    string // chemin du fichier analysé
+	file    string // chemin relatif à la racine du scan, tel qu'il entre dans l'empreinte
+	finding Finding
+	source  []byte
+	verdict string // "", VerdictFalsePositive ou VerdictTruePositive
+}
+
+// collectTriageItems analyse les fichiers des chemins donnés et retourne, dans l'ordre
+// du parcours, les résultats qui ne sont pas déjà dans la baseline.
+func (pa *PHPAnalyzer) collectTriageItems(ctx context.Context, paths []string) ([]triageItem, error) {
+	root := scanRoot(paths)
+	var items []triageItem
+	err := scanPHPFiles(ctx, paths, pa, func(ctx context.Context, fa *PHPAnalyzer, path string) []triageItem {
+		tree, content, err := fa.ParseFile(ctx, path)
+		if err != nil {
+			if !fa.interrupted(ctx, path) {
+				fa.fileError("Erreur d'analyse du fichier %q: %v", path, err)
+			}
+			return nil
+		}
+		file := fingerprintPath(root, path)
+		detections := fa.DetectVulnerabilities(ctx, tree, content)
+		fa.releaseTree(tree)
+		if fa.interrupted(ctx, path) {
+			return nil
+		}
+		assignFingerprints(file, content, detections)
+		var found []triageItem
+		for _, f := range detections {
+			if f.Severity < fa.MinSeverity || fa.Baseline.has(f.Fingerprint) {
+				continue
+			}
+			found = append(found, triageItem{path: path, file: file, finding: f, source: content})
+		}
+		return found
+	}, func(found []triageItem) {
+		items = append(items, found...)
+	})
+	if pa.MaxFindings > 0 && len(items) > pa.MaxFindings {
+		items = items[:pa.MaxFindings]
+	}
+	return items, err
+}
+
+// triageUI est l'état de l'interface de triage : la liste des résultats, le résultat
+// sélectionné et la fenêtre affichée. Il est indépendant du terminal, qui ne fait que
+// lui transmettre les touches et afficher son rendu.
+type triageUI struct {
+	items         []triageItem
+	selected      int
+	offset        int // premier résultat affiché dans la liste
+	width, height int
+}
+
+// Touches reconnues par l'interface de triage, décodées par readKey.
+const (
+	keyUp        = "up"
+	keyDown      = "down"
+	keyPageUp    = "pgup"
+	keyPageDown  = "pgdown"
+	keyHome      = "home"
+	keyEnd       = "end"
+	keyInterrupt = "ctrl-c"
+)
+
+// triageHelp résume les touches, sur la dernière ligne de l'écran.
+const triageHelp = "↑/↓ naviguer  f faux positif  t vrai positif  u annuler  q enregistrer et quitter  Ctrl-C abandonner"
+
+// handleKey applique une touche ; done indique la fin de la session et save si les
+// décisions doivent être enregistrées.
+func (ui *triageUI) handleKey(key string) (done, save bool) {
+	switch key {
+	case keyUp, "k":
+		ui.move(-1)
+	case keyDown, "j":
+		ui.move(1)
+	case keyPageUp:
+		ui.move(-ui.listHeight())
+	case keyPageDown:
+		ui.move(ui.listHeight())
+	case keyHome, "g":
+		ui.move(-len(ui.items))
+	case keyEnd, "G":
+		ui.move(len(ui.items))
+	case "f":
+		ui.mark(VerdictFalsePositive)
+	case "t":
+		ui.mark(VerdictTruePositive)
+	case "u":
+		ui.items[ui.selected].verdict = ""
+	case "q":
+		return true, true
+	case keyInterrupt:
+		return true, false
+	}
+	return false, false
+}
+
+func (ui *triageUI) move(delta int) {
+	ui.selected = min(max(ui.selected+delta, 0), len(ui.items)-1)
+}
+
+// mark enregistre une décision sur le résultat sélectionné (ou l'annule si elle est
+// déjà prise) puis passe au suivant.
+func (ui *triageUI) mark(verdict string) {
+	item := &ui.items[ui.selected]
+	if item.verdict == verdict {
+		item.verdict = ""
+		return
+	}
+	item.verdict = verdict
+	ui.move(1)
+}
+
+// Hauteurs des zones de l'écran : titre, liste, séparateur, aperçu et aide.
+func (ui *triageUI) previewHeight() int {
+	return max(min(ui.height/2, 12), 3)
+}
+
+func (ui *triageUI) listHeight() int {
+	return max(ui.height-ui.previewHeight()-3, 1)
+}
+
+// render retourne l'écran complet, à écrire en mode brut (lignes terminées par \r\n).
+func (ui *triageUI) render() string {
+	listHeight := ui.listHeight()
+	if ui.selected < ui.offset {
+		ui.offset = ui.selected
+	} else if ui.selected >= ui.offset+listHeight {
+		ui.offset = ui.selected - listHeight + 1
+	}
+
+	var lines []string
+	falsePositives, truePositives := 0, 0
+	for _, item := range ui.items {
+		switch item.verdict {
+		case VerdictFalsePositive:
+			falsePositives++
+		case VerdictTruePositive:
+			truePositives++
+		}
+	}
+	lines = append(lines, clip(fmt.Sprintf("php-analyzer tui — %d résultat(s), %d faux positif(s), %d vrai(s) positif(s)", len(ui.items), falsePositives, truePositives), ui.width))
+	for i := ui.offset; i < ui.offset+listHeight; i++ {
+		if i >= len(ui.items) {
+			lines = append(lines, "")
+			continue
+		}
+		line := clip(ui.itemLine(ui.items[i]), ui.width)
+		if i == ui.selected {
+			line = "\x1b[7m" + line + strings.Repeat(" ", max(ui.width-utf8.RuneCountInString(line), 0)) + "\x1b[0m"
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, strings.Repeat("─", max(ui.width, 1)))
+	lines = append(lines, ui.preview(ui.previewHeight())...)
+	lines = append(lines, clip(triageHelp, ui.width))
+	return "\x1b[H\x1b[2J" + strings.Join(lines, "\r\n")
+}
+
+// itemLine met en forme un résultat dans la liste : décision, sévérité, règle et position.
+func (ui *triageUI) itemLine(item triageItem) string {
+	mark := "    "
+	switch item.verdict {
+	case VerdictFalsePositive:
+		mark = "[FP]"
+	case VerdictTruePositive:
+		mark = "[TP]"
+	}
+	f := item.finding
+	return fmt.Sprintf("%s %-8s %-20s %s:%d", mark, f.Severity, f.RuleID, item.path, f.Line)
+}
+
+// preview retourne l'aperçu du résultat sélectionné sur height lignes : son message puis
+// le code qui l'entoure, les lignes signalées étant marquées d'un ">".
+func (ui *triageUI) preview(height int) []string {
+	item := ui.items[ui.selected]
+	f := item.finding
+	lines := []string{clip(f.Message, ui.width)}
+	first, last := f.Line, max(f.EndLine, f.Line)
+	context := max(height-1-int(last-first+1), 0) / 2
+	start := max(int(first)-context, 1)
+	source := strings.Split(string(item.source), "\n")
+	for n := start; len(lines) < height && n <= len(source); n++ {
+		marker := " "
+		if uint32(n) >= first && uint32(n) <= last {
+			marker = ">"
+		}
+		lines = append(lines, clip(fmt.Sprintf("%s %5d | %s", marker, n, expandTabs(strings.TrimRight(source[n-1], "\r"))), ui.width))
+	}
+	for len(lines) < height {
+		lines = append(lines, "")
+	}
+	return lines
+}
+
+// clip tronque une ligne à width caractères, sans en modifier les espaces.
+func clip(text string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(text) <= width {
+		return text
+	}
+	return string([]rune(text)[:width-1]) + ellipsis
+}
+
+// readKey lit une touche du terminal en mode brut : un caractère, ou une séquence
+// d'échappement pour les flèches et les touches de page.
+func readKey(r *bufio.Reader) (string, error) {
+	c, _, err := r.ReadRune()
+	if err != nil {
+		return "", err
+	}
+	switch c {
+	case 3:
+		return keyInterrupt, nil
+	case '\x1b':
+	default:
+		return string(c), nil
+	}
+	if r.Buffered() == 0 {
+		return "esc", nil
+	}
+	var sequence strings.Builder
+	for r.Buffered() > 0 {
+		b, _ := r.ReadByte()
+		sequence.WriteByte(b)
+		if b >= 'A' && b <= 'Z' || b == '~' {
+			break
+		}
+	}
+	switch sequence.String() {
+	case "[A", "OA":
+		return keyUp, nil
+	case "[B", "OB":
+		return keyDown, nil
+	case "[5~":
+		return keyPageUp, nil
+	case "[6~":
+		return keyPageDown, nil
+	case "[H", "OH", "[1~":
+		return keyHome, nil
+	case "[F", "OF", "[4~":
+		return keyEnd, nil
+	}
+	return "esc", nil
+}
+
+// run affiche l'interface de triage sur le terminal jusqu'à ce que l'utilisateur
+// la quitte, et retourne si les décisions doivent être enregistrées.
+func (ui *triageUI) run(in, out *os.File) (bool, error) {
+	state, err := term.MakeRaw(int(in.Fd()))
+	if err != nil {
+		return false, err
+	}
+	defer term.Restore(int(in.Fd()), state)
+	// Écran alternatif, curseur masqué : le terminal est restauré en quittant.
+	fmt.Fprint(out, "\x1b[?1049h\x1b[?25l")
+	defer fmt.Fprint(out, "\x1b[?25h\x1b[?1049l")
+
+	reader := bufio.NewReader(in)
+	for {
+		ui.width, ui.height = 80, 24
+		if width, height, err := term.GetSize(int(out.Fd())); err == nil {
+			ui.width, ui.height = width, height
+		}
+		fmt.Fprint(out, ui.render())
+		key, err := readKey(reader)
+		if err != nil {
+			return false, err
+		}
+		if done, save := ui.handleKey(key); done {
+			return save, nil
+		}
+	}
+}
+
+// saveTriage ajoute les faux positifs à la baseline et toutes les décisions à
+// l'historique de triage, puis résume ce qui a été enregistré.
+func saveTriage(items []triageItem, baselinePath, historyPath string, out io.Writer) error {
+	var entries []BaselineEntry
+	var records []TriageRecord
+	for _, item := range items {
+		if item.verdict == "" {
+			continue
+		}
+		file := filepath.ToSlash(item.file)
+		records = append(records, TriageRecord{Rule: item.finding.RuleID, File: file, Line: item.finding.Line, Verdict: item.verdict})
+		if item.verdict == VerdictFalsePositive {
+			entries = append(entries, BaselineEntry{Fingerprint: item.finding.Fingerprint, Rule: item.finding.RuleID, File: file, Line: item.finding.Line})
+		}
+	}
+	if len(records) == 0 {
+		fmt.Fprintln(out, "Aucune décision de triage à enregistrer.")
+		return nil
+	}
+	if len(entries) > 0 && baselinePath != "" {
+		if err := AddBaselineEntries(baselinePath, entries); err != nil {
+			return fmt.Errorf("Erreur lors de l'écriture de la baseline %q: %v", baselinePath, err)
+		}
+		fmt.Fprintf(out, "%d faux positif(s) ajouté(s) à la baseline %q.\n", len(entries), baselinePath)
+	}
+	if historyPath != "" {
+		if err := AppendTriageHistory(historyPath, records); err != nil {
+			return fmt.Errorf("Erreur lors de l'écriture de l'historique %q: %v", historyPath, err)
+		}
+		fmt.Fprintf(out, "%d décision(s) ajoutée(s) à l'historique de triage %q.\n", len(records), historyPath)
+	}
+	return nil
+}
+
+// runTUICommand exécute la commande tui : analyse des chemins donnés, puis triage
+// interactif des résultats qui ne sont pas déjà dans la baseline.
+func runTUICommand(ctx context.Context, analyzer *PHPAnalyzer, args []string, out io.Writer) error {
+	tuiCmd := newFlagSet("tui", out)
+	dirPath := tuiCmd.String("dir", "", "Chemin vers le dossier à analyser")
+	baselinePath := tuiCmd.String("baseline", defaultBaselinePath, "Baseline où ajouter les faux positifs (\"\" = aucune)")
+	historyPath := tuiCmd.String("history", defaultHistoryPath, "Historique de triage où ajouter les décisions, JSON Lines (\"\" = aucun)")
+	calibrationPath := tuiCmd.String("calibration", defaultCalibrationPath, "Fichier de calibration issu du triage")
+	rulesPath := tuiCmd.String("rules", "", "Fichier ou dossier de règles YAML supplémentaires")
+	advisoriesPath := tuiCmd.String("advisories", defaultAdvisoriesPath, "Fichier d'avis écrit par rules update")
+	selection := addSelectionFlags(tuiCmd)
+	filters := addFilterFlags(tuiCmd)
+	excludes := addExcludeFlags(tuiCmd)
+	inputs, err := analyzer.parseCommandLine(tuiCmd, args)
+	if err != nil {
+		return err
+	}
+	paths := scanPaths(*dirPath, inputs)
+	if len(paths) == 0 {
+		paths = []string{analyzer.abs(".")}
+	}
+	if err := applyFilters(analyzer, filters); err != nil {
+		return err
+	}
+	if err := applyExcludes(analyzer, excludes); err != nil {
+		return err
+	}
+	if err := loadCalibration(analyzer, *calibrationPath); err != nil {
+		return err
+	}
+	if err := loadSignatures(analyzer, *rulesPath, *advisoriesPath); err != nil {
+		return err
+	}
+	if err := applySelection(analyzer, selection); err != nil {
+		return err
+	}
+	// Une baseline absente sera créée par les faux positifs, pas par l'analyse.
+	analyzer.Baseline = nil
+	if _, err := os.Stat(*baselinePath); err == nil {
+		if err := loadBaseline(analyzer, *baselinePath); err != nil {
+			return err
+		}
+	}
+	in, ok := analyzer.Stdin.(*os.File)
+	if !ok || !term.IsTerminal(int(in.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return errNoTerminal
+	}
+
+	items, err := analyzer.collectTriageItems(ctx, paths)
+	if err != nil {
+		return fmt.Errorf("Erreur lors de la traversée de %s: %v", quotePaths(paths), err)
+	}
+	if len(items) == 0 {
+		fmt.Fprintln(out, "Aucun résultat à trier.")
+		return nil
+	}
+	ui := &triageUI{items: items}
+	save, err := ui.run(in, os.Stdout)
+	if err != nil {
+		return err
+	}
+	if !save {
+		fmt.Fprintln(out, "Triage abandonné : aucune décision enregistrée.")
+		return nil
+	}
+	return saveTriage(ui.items, *baselinePath, *historyPath, out)
+}
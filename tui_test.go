@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTriageUI(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a.php"), []byte("<?php\n$x = 1;\nvar_dump($a);\nprint_r($b);\nvar_dump($c);\n"), 0o644))
+
+	analyzer := NewPHPAnalyzer()
+	analyzer.MinSeverity = SeverityInfo
+	items, err := analyzer.collectTriageItems(context.Background(), []string{dir})
+	assert.NoError(t, err)
+	if !assert.Len(t, items, 3) {
+		return
+	}
+	assert.Equal(t, "a.php", items[0].file)
+
+	ui := &triageUI{items: items, width: 100, height: 20}
+	screen := ui.render()
+	assert.Contains(t, screen, "3 résultat(s)")
+	assert.Contains(t, screen, ">     3 | var_dump($a);")
+	assert.Contains(t, screen, "      2 | $x = 1;")
+
+	ui.handleKey("f")
+	assert.Equal(t, VerdictFalsePositive, ui.items[0].verdict)
+	assert.Equal(t, 1, ui.selected, "f passe au résultat suivant")
+	ui.handleKey("t")
+	ui.handleKey(keyUp)
+	ui.handleKey("u")
+	assert.Equal(t, "", ui.items[1].verdict)
+	ui.handleKey(keyEnd)
+	ui.handleKey("t")
+	assert.Equal(t, 2, ui.selected, "la sélection reste sur le dernier résultat")
+	assert.Contains(t, ui.render(), "1 faux positif(s), 1 vrai(s) positif(s)")
+	done, save := ui.handleKey("q")
+	assert.True(t, done)
+	assert.True(t, save)
+	done, save = ui.handleKey(keyInterrupt)
+	assert.True(t, done)
+	assert.False(t, save)
+
+	baselinePath := filepath.Join(dir, "baseline.json")
+	historyPath := filepath.Join(dir, "triage.jsonl")
+	var out strings.Builder
+	assert.NoError(t, saveTriage(ui.items, baselinePath, historyPath, &out))
+	assert.Contains(t, out.String(), "1 faux positif(s) ajouté(s)")
+	records, err := LoadTriageHistory(historyPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []TriageRecord{
+		{Rule: "debug-leftover", File: "a.php", Line: 3, Verdict: VerdictFalsePositive},
+		{Rule: "debug-leftover", File: "a.php", Line: 5, Verdict: VerdictTruePositive},
+	}, records)
+
+	// Le faux positif n'est plus proposé ni signalé avec la baseline.
+	assert.NoError(t, loadBaseline(analyzer, baselinePath))
+	items, err = analyzer.collectTriageItems(context.Background(), []string{dir})
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	out.Reset()
+	assert.NoError(t, runCommand(NewPHPAnalyzer(), "analyze-dir", []string{"-dir", dir, "-baseline", baselinePath, "-min-severity=info"}, &out))
+	assert.Contains(t, out.String(), "1 résultat(s) existant(s) masqué(s)")
+}
+
+func TestReadKey(t *testing.T) {
+	reader := bufio.NewReader(strings.NewReader("j\x1b[A\x1b[6~\x03"))
+	for _, expected := range []string{"j", keyUp, keyPageDown, keyInterrupt} {
+		key, err := readKey(reader)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, key)
+	}
+}
+
+func TestTUIRequiresTerminal(t *testing.T) {
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	analyzer.Stdin = strings.NewReader("")
+	assert.ErrorIs(t, runCommand(analyzer, "tui", []string{"-baseline", "", "-history", "", t.TempDir()}, &out), errNoTerminal)
+}
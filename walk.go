@@ -0,0 +1,98 @@
+package main
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// defaultExtensions sont les extensions des fichiers analysés lors du parcours d'un
+// dossier, sans distinction de casse.
+var defaultExtensions = []string{".php", ".phtml", ".inc", ".php5"}
+
+// WalkOptions règle le parcours des dossiers d'un scan.
+type WalkOptions struct {
+	// FollowSymlinks parcourt les dossiers désignés par des liens symboliques ; un
+	// dossier déjà parcouru (boucle de liens) ne l'est pas une seconde fois.
+	FollowSymlinks bool
+	// MaxDepth limite la profondeur du parcours : 1 ne retient que les fichiers du
+	// dossier lui-même (0 = aucune limite).
+	MaxDepth int
+	// Hidden parcourt aussi les dossiers cachés (.cache, .idea...), ignorés par défaut.
+	Hidden bool
+	// Extensions remplace defaultExtensions (nil = defaultExtensions).
+	Extensions []string
+}
+
+// matches indique si un fichier rencontré lors du parcours doit être analysé.
+func (o WalkOptions) matches(name string) bool {
+	extensions := o.Extensions
+	if extensions == nil {
+		extensions = defaultExtensions
+	}
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, candidate := range extensions {
+		if ext == strings.ToLower(candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// dirWalker parcourt un dossier dans l'ordre lexical, comme filepath.Walk, en appliquant
+// le filtre d'exclusion et les options du parcours.
+type dirWalker struct {
+	opts    WalkOptions
+	filter  *walkFilter
+	visited map[string]bool // chemins réels des dossiers parcourus
+	add     func(path string)
+}
+
+// walk parcourt root et passe ses fichiers à analyser à add.
+func (pa *PHPAnalyzer) walk(root string, add func(path string)) {
+	w := &dirWalker{opts: pa.Walk, filter: pa.newWalkFilter(root), visited: map[string]bool{}, add: add}
+	w.visit(root, 0)
+}
+
+// visit parcourt le dossier dir, situé à la profondeur depth sous la racine.
+func (w *dirWalker) visit(dir string, depth int) {
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		if w.visited[real] {
+			log.Printf("Dossier %q ignoré : déjà parcouru (boucle de liens symboliques ?)", dir)
+			return
+		}
+		w.visited[real] = true
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		log.Printf("Erreur d'accès à %q: %v", dir, err)
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			// Un lien cassé est retenu comme un fichier : l'erreur est signalée lors de
+			// son analyse.
+			info, err := os.Stat(path)
+			if isDir = err == nil && info.IsDir(); isDir && !w.opts.FollowSymlinks {
+				continue
+			}
+		}
+		if isDir && !w.opts.Hidden && strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if w.filter.ignored(path, isDir) {
+			continue
+		}
+		switch {
+		case !isDir:
+			if w.opts.matches(entry.Name()) {
+				w.add(path)
+			}
+		case w.opts.MaxDepth == 0 || depth+1 < w.opts.MaxDepth:
+			w.filter.enter(path)
+			w.visit(path, depth+1)
+		}
+	}
+}
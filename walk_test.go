@@ -0,0 +1,81 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// walkTree crée les fichiers donnés sous un dossier temporaire et retourne ce dossier.
+func walkTree(t *testing.T, names ...string) string {
+	dir := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, []byte("<?php echo 1;"), 0o644))
+	}
+	return dir
+}
+
+// listed retourne les fichiers retenus par le parcours de dir, relatifs à dir.
+func listed(t *testing.T, analyzer *PHPAnalyzer, dir string) []string {
+	files, err := analyzer.listPHPFiles(dir)
+	assert.NoError(t, err)
+	var rel []string
+	for _, path := range files {
+		name, _ := filepath.Rel(dir, path)
+		rel = append(rel, filepath.ToSlash(name))
+	}
+	return rel
+}
+
+func TestWalkExtensions(t *testing.T) {
+	dir := walkTree(t, "a.php", "b.PHTML", "c.inc", "d.php5", "e.txt", "f.php7")
+	analyzer := NewPHPAnalyzer()
+	assert.Equal(t, []string{"a.php", "b.PHTML", "c.inc", "d.php5"}, listed(t, analyzer, dir))
+
+	analyzer.Walk.Extensions = []string{".php", ".php7"}
+	assert.Equal(t, []string{"a.php", "f.php7"}, listed(t, analyzer, dir))
+}
+
+func TestWalkDepthAndHiddenDirectories(t *testing.T) {
+	dir := walkTree(t, "a.php", "src/b.php", "src/lib/c.php", ".cache/d.php", "src/.idea/e.php", ".hidden.php")
+	analyzer := NewPHPAnalyzer()
+	assert.Equal(t, []string{".hidden.php", "a.php", "src/b.php", "src/lib/c.php"}, listed(t, analyzer, dir),
+		"hidden directories are skipped, hidden files are not")
+
+	analyzer.Walk.Hidden = true
+	assert.Equal(t, []string{".cache/d.php", ".hidden.php", "a.php", "src/.idea/e.php", "src/b.php", "src/lib/c.php"}, listed(t, analyzer, dir))
+
+	analyzer.Walk = WalkOptions{MaxDepth: 1}
+	assert.Equal(t, []string{".hidden.php", "a.php"}, listed(t, analyzer, dir))
+	analyzer.Walk.MaxDepth = 2
+	assert.Equal(t, []string{".hidden.php", "a.php", "src/b.php"}, listed(t, analyzer, dir))
+}
+
+func TestWalkSymlinks(t *testing.T) {
+	dir := walkTree(t, "src/a.php")
+	outside := walkTree(t, "lib/b.php")
+	assert.NoError(t, os.Symlink(filepath.Join(outside, "lib"), filepath.Join(dir, "lib")))
+	assert.NoError(t, os.Symlink(dir, filepath.Join(dir, "src", "loop")))
+
+	analyzer := NewPHPAnalyzer()
+	assert.Equal(t, []string{"src/a.php"}, listed(t, analyzer, dir), "symlinked directories are not followed by default")
+
+	analyzer.Walk.FollowSymlinks = true
+	assert.Equal(t, []string{"lib/b.php", "src/a.php"}, listed(t, analyzer, dir), "the loop back to the root is walked once")
+}
+
+func TestWalkFlags(t *testing.T) {
+	dir := walkTree(t, "a.php", "b.tpl", "src/c.php")
+	var out strings.Builder
+	analyzer := NewPHPAnalyzer()
+	assert.NoError(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir, "-max-depth=1", "-extensions=php,tpl"}, &out))
+	assert.Equal(t, WalkOptions{MaxDepth: 1, Extensions: []string{".php", ".tpl"}}, analyzer.Walk)
+	assert.Equal(t, []string{"a.php", "b.tpl"}, listed(t, analyzer, dir))
+
+	assert.ErrorContains(t, runCommand(analyzer, "analyze-dir", []string{"-dir", dir, "-max-depth=-1"}, &out), "-max-depth")
+}